@@ -2265,8 +2265,9 @@ class MainActivity: FlutterFragmentActivity() {
                         }
                         "sanitizeFilename" -> {
                             val filename = call.argument<String>("filename") ?: ""
+                            val profile = call.argument<String>("profile") ?: ""
                             val response = withContext(Dispatchers.IO) {
-                                Gobackend.sanitizeFilename(filename)
+                                Gobackend.sanitizeFilename(filename, profile)
                             }
                             result.success(response)
                         }
@@ -0,0 +1,338 @@
+package gobackend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-flac/flacpicture/v2"
+	"github.com/go-flac/go-flac/v2"
+)
+
+// Strategies for picking the cover FixAlbumArtConsistency propagates.
+const (
+	// AlbumArtMajority picks the cover most tracks already have, breaking
+	// ties by resolution.
+	AlbumArtMajority = "majority"
+	// AlbumArtHighestResolution picks the largest cover by pixel count.
+	AlbumArtHighestResolution = "highest_resolution"
+)
+
+// AlbumCoverVariant is one distinct embedded cover within an album. Hash is
+// the SHA-256 of the raw embedded bytes.
+type AlbumCoverVariant struct {
+	Hash   string   `json:"hash"`
+	Size   int      `json:"size"`
+	Width  int      `json:"width,omitempty"`
+	Height int      `json:"height,omitempty"`
+	Tracks []string `json:"tracks"`
+}
+
+// AlbumArtInconsistency is an album directory whose tracks carry more than
+// one distinct cover. Outliers are the tracks not carrying Chosen.
+type AlbumArtInconsistency struct {
+	// Directory is relative to the scanned root ("." for the root itself).
+	Directory string              `json:"directory"`
+	Covers    []AlbumCoverVariant `json:"covers"`
+	Chosen    string              `json:"chosen"`
+	Outliers  []string            `json:"outliers"`
+	// Fixed and Errors are only filled by FixAlbumArtConsistency.
+	Fixed  []string          `json:"fixed,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+type AlbumArtConsistencyReport struct {
+	Root               string                  `json:"root"`
+	Strategy           string                  `json:"strategy,omitempty"`
+	Albums             int                     `json:"albums"`
+	Inconsistent       int                     `json:"inconsistent"`
+	Fixed              int                     `json:"fixed"`
+	Failed             int                     `json:"failed"`
+	QuarantinedSkipped int                     `json:"quarantined_skipped"`
+	Details            []AlbumArtInconsistency `json:"details"`
+}
+
+type albumCover struct {
+	track string
+	data  []byte
+	hash  string
+}
+
+// chooseAlbumCover orders variants by the strategy and returns the winner.
+// Hashes settle remaining ties so the choice is stable between runs.
+func chooseAlbumCover(variants []AlbumCoverVariant, strategy string) AlbumCoverVariant {
+	pixels := func(v AlbumCoverVariant) int { return v.Width * v.Height }
+	sorted := append([]AlbumCoverVariant(nil), variants...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if strategy == AlbumArtMajority && len(a.Tracks) != len(b.Tracks) {
+			return len(a.Tracks) > len(b.Tracks)
+		}
+		if pixels(a) != pixels(b) {
+			return pixels(a) > pixels(b)
+		}
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Hash < b.Hash
+	})
+	return sorted[0]
+}
+
+// groupAlbumCovers returns the distinct covers of an album in first-seen
+// order.
+func groupAlbumCovers(covers []albumCover) []AlbumCoverVariant {
+	byHash := make(map[string]*AlbumCoverVariant)
+	var order []string
+	for _, cover := range covers {
+		variant, ok := byHash[cover.hash]
+		if !ok {
+			variant = &AlbumCoverVariant{Hash: cover.hash, Size: len(cover.data)}
+			if width, height, _, err := coverDimensions(cover.data); err == nil {
+				variant.Width, variant.Height = width, height
+			}
+			byHash[cover.hash] = variant
+			order = append(order, cover.hash)
+		}
+		variant.Tracks = append(variant.Tracks, cover.track)
+	}
+	variants := make([]AlbumCoverVariant, 0, len(order))
+	for _, hash := range order {
+		variants = append(variants, *byHash[hash])
+	}
+	return variants
+}
+
+// checkAlbumCovers returns the inconsistency of one album, or nil when all
+// tracks that have a cover share it. Tracks without a cover are ignored.
+func checkAlbumCovers(directory string, covers []albumCover, strategy string) *AlbumArtInconsistency {
+	variants := groupAlbumCovers(covers)
+	if len(variants) < 2 {
+		return nil
+	}
+
+	result := &AlbumArtInconsistency{Directory: directory, Covers: variants}
+	chosen := chooseAlbumCover(result.Covers, strategy)
+	result.Chosen = chosen.Hash
+	for _, cover := range covers {
+		if cover.hash != chosen.Hash {
+			result.Outliers = append(result.Outliers, cover.track)
+		}
+	}
+	return result
+}
+
+// replaceFrontCover swaps the picture ExtractCoverArt would return (the
+// front cover, else the first picture) for coverData, keeping every other
+// picture block.
+func replaceFrontCover(f *flac.File, coverData []byte) error {
+	block, err := buildPictureBlock("", coverData)
+	if err != nil {
+		return err
+	}
+	target := -1
+	for i, meta := range f.Meta {
+		if meta.Type != flac.Picture {
+			continue
+		}
+		pic, err := parseFLACPictureMeta(*meta)
+		if err != nil {
+			continue
+		}
+		if pic.PictureType == flacpicture.PictureTypeFrontCover {
+			target = i
+			break
+		}
+		if target < 0 {
+			target = i
+		}
+	}
+	if target < 0 {
+		f.Meta = append(f.Meta, &block)
+		return nil
+	}
+	f.Meta[target] = &block
+	return nil
+}
+
+var albumArtProgress batchProgress
+
+// GetAlbumArtConsistencyProgress reports the running
+// CheckAlbumArtConsistency or FixAlbumArtConsistency, one item per album
+// directory, including the cover embeds of the fix.
+func GetAlbumArtConsistencyProgress() string {
+	return albumArtProgress.json()
+}
+
+// groupLibraryAlbums groups the audio files by directory, leaving out cue
+// sheets, and returns the directories sorted with the total size of each.
+func groupLibraryAlbums(files []libraryAudioFileInfo) (map[string][]string, []string, []int64) {
+	tracksByDir := make(map[string][]string)
+	sizeByDir := make(map[string]int64)
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file.path), ".cue") {
+			continue
+		}
+		dir := filepath.Dir(file.path)
+		tracksByDir[dir] = append(tracksByDir[dir], file.path)
+		sizeByDir[dir] += file.size
+	}
+	dirs := make([]string, 0, len(tracksByDir))
+	for dir := range tracksByDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	sizes := make([]int64, len(dirs))
+	for i, dir := range dirs {
+		sizes[i] = sizeByDir[dir]
+	}
+	return tracksByDir, dirs, sizes
+}
+
+// embedCoverBatch writes coverData as the front cover of every FLAC file in
+// filePaths, one rewrite per file. It returns the failures by path.
+func embedCoverBatch(filePaths []string, coverData []byte) map[string]error {
+	failures := make(map[string]error)
+	for _, filePath := range filePaths {
+		if !strings.EqualFold(filepath.Ext(filePath), ".flac") {
+			failures[filePath] = fmt.Errorf("cover embedding is only supported for FLAC")
+			continue
+		}
+		err := func() error {
+			release, err := acquireHeavyOperation()
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			f, err := parseFLACStaged(filePath)
+			if err != nil {
+				return fmt.Errorf("failed to parse FLAC file: %w", err)
+			}
+			before := takeTagSnapshot(f)
+			if err := replaceFrontCover(f, coverData); err != nil {
+				f.Close()
+				return err
+			}
+			return saveTaggedFLAC(f, filePath, "embed_cover", before)
+		}()
+		if err != nil {
+			failures[filePath] = err
+		}
+	}
+	return failures
+}
+
+func albumArtConsistency(rootPath, strategy string, fix bool) (string, error) {
+	if strategy == "" {
+		strategy = AlbumArtMajority
+	}
+	if strategy != AlbumArtMajority && strategy != AlbumArtHighestResolution {
+		return "", fmt.Errorf("unknown album art strategy: %q", strategy)
+	}
+	if strings.TrimSpace(rootPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(rootPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+
+	files, quarantined, err := collectWritableLibraryFiles(rootPath, nil)
+	if err != nil {
+		return "", err
+	}
+	tracksByDir, dirs, dirSizes := groupLibraryAlbums(files)
+	albumArtProgress.start(dirSizes, 1)
+	defer albumArtProgress.finish()
+
+	report := AlbumArtConsistencyReport{Root: rootPath, Details: []AlbumArtInconsistency{}}
+	report.QuarantinedSkipped = quarantined
+	if fix {
+		report.Strategy = strategy
+	}
+	for i, dir := range dirs {
+		done := albumArtProgress.item(dir, dirSizes[i])
+		tracks := tracksByDir[dir]
+		sort.Strings(tracks)
+		var covers []albumCover
+		for _, track := range tracks {
+			data, _, err := extractAnyCoverArt(track)
+			if err != nil || len(data) == 0 {
+				continue
+			}
+			sum := sha256.Sum256(data)
+			covers = append(covers, albumCover{track: track, data: data, hash: hex.EncodeToString(sum[:])})
+		}
+		report.Albums++
+
+		relDir, err := filepath.Rel(rootPath, dir)
+		if err != nil {
+			relDir = dir
+		}
+		inconsistency := checkAlbumCovers(relDir, covers, strategy)
+		if inconsistency == nil {
+			done()
+			continue
+		}
+		report.Inconsistent++
+
+		if fix {
+			var chosen []byte
+			for _, cover := range covers {
+				if cover.hash == inconsistency.Chosen {
+					chosen = cover.data
+					break
+				}
+			}
+			failures := embedCoverBatch(inconsistency.Outliers, chosen)
+			for _, track := range inconsistency.Outliers {
+				if err, failed := failures[track]; failed {
+					if inconsistency.Errors == nil {
+						inconsistency.Errors = make(map[string]string)
+					}
+					inconsistency.Errors[track] = err.Error()
+					report.Failed++
+					continue
+				}
+				inconsistency.Fixed = append(inconsistency.Fixed, track)
+				report.Fixed++
+			}
+		}
+		report.Details = append(report.Details, *inconsistency)
+		done()
+	}
+
+	GoLog("[AlbumArt] %d of %d albums under %s have mixed covers (%d tracks fixed)\n",
+		report.Inconsistent, report.Albums, rootPath, report.Fixed)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// CheckAlbumArtConsistency reports album directories under rootPath whose
+// tracks embed more than one distinct front cover, comparing raw embedded
+// bytes, and which tracks differ from the majority cover.
+func CheckAlbumArtConsistency(rootPath string) (string, error) {
+	return albumArtConsistency(rootPath, AlbumArtMajority, false)
+}
+
+// FixAlbumArtConsistency is CheckAlbumArtConsistency that also embeds the
+// cover picked by strategy ("majority" when empty, or
+// "highest_resolution") into the outlier tracks.
+func FixAlbumArtConsistency(rootPath, strategy string) (string, error) {
+	if err := checkWriteAllowed(rootPath); err != nil {
+		return "", err
+	}
+
+	return albumArtConsistency(rootPath, strategy, true)
+}
@@ -0,0 +1,61 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeMixedCoverAlbum(t *testing.T) (root string, tracks []string) {
+	t.Helper()
+	root = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "Album"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	// Two tracks share the 20px cover; the single's art is larger.
+	for i, size := range []int{20, 20, 40} {
+		tracks = append(tracks, writeShrinkFixture(t, root, filepath.Join("Album", string(rune('1'+i))+".flac"), size))
+	}
+	writeShrinkFixture(t, root, "single.flac", 30)
+	return root, tracks
+}
+
+func TestCheckAlbumArtConsistencyReportsOutliers(t *testing.T) {
+	root, tracks := writeMixedCoverAlbum(t)
+
+	raw, err := CheckAlbumArtConsistency(root)
+	report := mustDecodeJSON[AlbumArtConsistencyReport](t, raw, err)
+	if report.Albums != 2 || report.Inconsistent != 1 || report.Fixed != 0 {
+		t.Fatalf("unexpected counts: %+v", report)
+	}
+	album := report.Details[0]
+	if album.Directory != "Album" || len(album.Covers) != 2 || len(album.Outliers) != 1 || album.Outliers[0] != tracks[2] {
+		t.Fatalf("unexpected album report: %+v", album)
+	}
+	if len(album.Covers[0].Tracks) != 2 || album.Chosen != album.Covers[0].Hash {
+		t.Fatalf("expected the majority cover to be chosen: %+v", album)
+	}
+}
+
+func TestFixAlbumArtConsistencyStrategies(t *testing.T) {
+	root, tracks := writeMixedCoverAlbum(t)
+
+	raw, err := FixAlbumArtConsistency(root, AlbumArtHighestResolution)
+	report := mustDecodeJSON[AlbumArtConsistencyReport](t, raw, err)
+	if report.Fixed != 2 || report.Failed != 0 {
+		t.Fatalf("expected both 20px tracks to get the 40px cover: %+v", report)
+	}
+	for _, track := range tracks {
+		if pictures, err := GetPictures(track); err != nil || len(pictures) != 1 || pictures[0].Width != 40 {
+			t.Fatalf("%s: unexpected pictures %+v/%v", track, pictures, err)
+		}
+	}
+
+	raw, err = CheckAlbumArtConsistency(root)
+	if report = mustDecodeJSON[AlbumArtConsistencyReport](t, raw, err); report.Inconsistent != 0 {
+		t.Fatalf("expected a consistent album after fixing: %+v", report)
+	}
+	if _, err := FixAlbumArtConsistency(root, "newest"); err == nil {
+		t.Fatal("expected unknown strategy error")
+	}
+}
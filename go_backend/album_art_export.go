@@ -0,0 +1,221 @@
+package gobackend
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	stdimage "image"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Album art export statuses, one per album directory.
+const (
+	AlbumArtWritten   = "written"
+	AlbumArtUnchanged = "unchanged"
+	AlbumArtExists    = "exists"
+	AlbumArtNoArt     = "no_art"
+	AlbumArtFailed    = "failed"
+)
+
+const defaultAlbumArtFilename = "folder.jpg"
+
+type AlbumArtFolder struct {
+	Directory string `json:"directory"`
+	Status    string `json:"status"`
+	// Source is the track the cover came from.
+	Source string `json:"source,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type AlbumArtExportReport struct {
+	Root      string           `json:"root"`
+	Written   int              `json:"written"`
+	Unchanged int              `json:"unchanged"`
+	Existing  int              `json:"existing"`
+	NoArt     int              `json:"no_art"`
+	Failed    int              `json:"failed"`
+	Cancelled bool             `json:"cancelled"`
+	Folders   []AlbumArtFolder `json:"folders"`
+}
+
+var (
+	albumArtExportCancel   chan struct{}
+	albumArtExportCancelMu sync.Mutex
+)
+
+// albumArtTargetName keeps the requested base name but matches the extension
+// to the image, so PNG art is never written as folder.jpg.
+func albumArtTargetName(filename, mimeType string) string {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	switch mimeType {
+	case "image/png":
+		return base + ".png"
+	case "image/gif":
+		return base + ".gif"
+	case "image/webp":
+		return base + ".webp"
+	}
+	return base + ".jpg"
+}
+
+// suitableAlbumCover returns the first embedded cover among tracks whose
+// shorter side is at least minDim. Covers that do not decode as an image are
+// ignored.
+func suitableAlbumCover(tracks []string, minDim int) (data []byte, mimeType, source string, cfg stdimage.Config, ok bool) {
+	for _, track := range tracks {
+		data, _, err := extractAnyCoverArt(track)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		width, height, _, err := coverDimensions(data)
+		if err != nil || min(width, height) < minDim {
+			continue
+		}
+		return data, detectCoverMIME("", data), track, stdimage.Config{Width: width, Height: height}, true
+	}
+	return nil, "", "", stdimage.Config{}, false
+}
+
+func writeAlbumArt(folder *AlbumArtFolder, target string, data []byte, overwrite bool) {
+	folder.Path = target
+	if existing, err := os.ReadFile(target); err == nil {
+		if sha256.Sum256(existing) == sha256.Sum256(data) {
+			folder.Status = AlbumArtUnchanged
+			return
+		}
+		if !overwrite {
+			folder.Status = AlbumArtExists
+			return
+		}
+	}
+
+	tmpPath := target + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		folder.Status, folder.Error = AlbumArtFailed, err.Error()
+		return
+	}
+	if err := os.Rename(tmpPath, target); err != nil {
+		os.Remove(tmpPath)
+		folder.Status, folder.Error = AlbumArtFailed, err.Error()
+		return
+	}
+	folder.Status = AlbumArtWritten
+}
+
+// ExportAlbumArt writes the embedded front cover of each album directory
+// under rootPath as filename (folder.jpg when empty; the extension follows
+// the image type). The cover comes from the first track, in name order,
+// whose art is at least minDim pixels on its shorter side. An identical
+// existing file is left alone, a different one only replaced when overwrite
+// is set. Directories without suitable art are reported as "no_art".
+// CancelAlbumArtExport stops the walk between directories.
+func ExportAlbumArt(rootPath string, filename string, minDim int, overwrite bool) (string, error) {
+	filename = strings.TrimSpace(filename)
+	if filename == "" {
+		filename = defaultAlbumArtFilename
+	}
+	if filename != filepath.Base(filename) || filename == "." || filename == ".." {
+		return "", fmt.Errorf("filename must be a plain file name: %s", filename)
+	}
+	if strings.TrimSpace(rootPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(rootPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+	if err := checkWriteAllowed(rootPath); err != nil {
+		return "", err
+	}
+
+	albumArtExportCancelMu.Lock()
+	if albumArtExportCancel != nil {
+		close(albumArtExportCancel)
+	}
+	albumArtExportCancel = make(chan struct{})
+	cancelCh := albumArtExportCancel
+	albumArtExportCancelMu.Unlock()
+
+	files, err := collectLibraryAudioFiles(rootPath, cancelCh)
+	if err != nil {
+		return "", err
+	}
+	tracksByDir := make(map[string][]string)
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file.path), ".cue") {
+			continue
+		}
+		dir := filepath.Dir(file.path)
+		tracksByDir[dir] = append(tracksByDir[dir], file.path)
+	}
+	dirs := make([]string, 0, len(tracksByDir))
+	for dir := range tracksByDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	report := AlbumArtExportReport{Root: rootPath, Folders: make([]AlbumArtFolder, 0, len(dirs))}
+	for _, dir := range dirs {
+		select {
+		case <-cancelCh:
+			report.Cancelled = true
+		default:
+		}
+		if report.Cancelled {
+			break
+		}
+
+		tracks := tracksByDir[dir]
+		sort.Strings(tracks)
+		folder := AlbumArtFolder{Directory: dir}
+		data, mimeType, source, cfg, ok := suitableAlbumCover(tracks, minDim)
+		if !ok {
+			folder.Status = AlbumArtNoArt
+		} else {
+			folder.Source, folder.Width, folder.Height = source, cfg.Width, cfg.Height
+			writeAlbumArt(&folder, filepath.Join(dir, albumArtTargetName(filename, mimeType)), data, overwrite)
+		}
+
+		switch folder.Status {
+		case AlbumArtWritten:
+			report.Written++
+		case AlbumArtUnchanged:
+			report.Unchanged++
+		case AlbumArtExists:
+			report.Existing++
+		case AlbumArtNoArt:
+			report.NoArt++
+		case AlbumArtFailed:
+			report.Failed++
+		}
+		report.Folders = append(report.Folders, folder)
+	}
+
+	GoLog("[AlbumArt] Exported %d covers under %s (%d unchanged, %d without art)\n",
+		report.Written, rootPath, report.Unchanged, report.NoArt)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// CancelAlbumArtExport stops a running ExportAlbumArt after the current
+// directory; the partial report is still returned.
+func CancelAlbumArtExport() {
+	albumArtExportCancelMu.Lock()
+	defer albumArtExportCancelMu.Unlock()
+	if albumArtExportCancel != nil {
+		close(albumArtExportCancel)
+		albumArtExportCancel = nil
+	}
+}
@@ -0,0 +1,74 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportAlbumArtWritesFolderCovers(t *testing.T) {
+	root := t.TempDir()
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	withArt := writeConsistencyFixture(t, root, "Album/02.flac", Metadata{Title: "Two"})
+	if err := EmbedMetadataWithCoverData(withArt, Metadata{}, cover); err != nil {
+		t.Fatalf("embed cover: %v", err)
+	}
+	writeConsistencyFixture(t, root, "Album/01.flac", Metadata{Title: "One"})
+	writeConsistencyFixture(t, root, "Bare/01.flac", Metadata{Title: "No art"})
+
+	raw, err := ExportAlbumArt(root, "folder.jpg", 8, false)
+	report := mustDecodeJSON[AlbumArtExportReport](t, raw, err)
+	if report.Written != 1 || report.NoArt != 1 || len(report.Folders) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	album := report.Folders[0]
+	// The fixture cover is a PNG, so the extension follows the image.
+	wantPath := filepath.Join(root, "Album", "folder.png")
+	if album.Status != AlbumArtWritten || album.Path != wantPath || album.Source != withArt || album.Width != 8 {
+		t.Fatalf("unexpected album entry: %+v", album)
+	}
+	if written, err := os.ReadFile(wantPath); err != nil || string(written) != string(cover) {
+		t.Fatalf("folder cover not written: %v", err)
+	}
+	if report.Folders[1].Status != AlbumArtNoArt {
+		t.Fatalf("expected Bare to be reported without art: %+v", report.Folders[1])
+	}
+
+	raw, err = ExportAlbumArt(root, "folder.jpg", 8, false)
+	report = mustDecodeJSON[AlbumArtExportReport](t, raw, err)
+	if report.Unchanged != 1 || report.Written != 0 {
+		t.Fatalf("identical cover should be skipped: %+v", report)
+	}
+
+	if err := os.WriteFile(wantPath, []byte("user art"), 0644); err != nil {
+		t.Fatalf("write user art: %v", err)
+	}
+	raw, err = ExportAlbumArt(root, "folder.jpg", 8, false)
+	report = mustDecodeJSON[AlbumArtExportReport](t, raw, err)
+	if report.Existing != 1 {
+		t.Fatalf("different existing art must be kept without overwrite: %+v", report)
+	}
+	raw, err = ExportAlbumArt(root, "folder.jpg", 8, true)
+	report = mustDecodeJSON[AlbumArtExportReport](t, raw, err)
+	if report.Written != 1 {
+		t.Fatalf("overwrite should replace existing art: %+v", report)
+	}
+
+	raw, err = ExportAlbumArt(root, "cover.jpg", 9, true)
+	report = mustDecodeJSON[AlbumArtExportReport](t, raw, err)
+	if report.NoArt != 2 {
+		t.Fatalf("covers under minDim must not be used: %+v", report)
+	}
+}
+
+func TestExportAlbumArtRejectsBadArguments(t *testing.T) {
+	if _, err := ExportAlbumArt(t.TempDir(), "../folder.jpg", 0, false); err == nil {
+		t.Fatal("expected filename with a directory to be rejected")
+	}
+	if _, err := ExportAlbumArt(filepath.Join(t.TempDir(), "missing"), "", 0, false); err == nil {
+		t.Fatal("expected missing folder error")
+	}
+}
@@ -0,0 +1,172 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// variousArtistsName is the album artist InferAlbumArtist uses when no
+// primary artist has a majority.
+const variousArtistsName = "Various Artists"
+
+// Per-track actions of InferAlbumArtist.
+const (
+	// AlbumArtistSet: ALBUMARTIST is empty and is (or, without apply, would
+	// be) set to the inferred value.
+	AlbumArtistSet = "set"
+	// AlbumArtistKeep: the track already has an album artist, which is never
+	// overwritten.
+	AlbumArtistKeep = "keep"
+	// AlbumArtistUnsupported: ALBUMARTIST is empty but only FLAC files are
+	// written.
+	AlbumArtistUnsupported = "unsupported"
+	// AlbumArtistFailed: the track could not be read or written.
+	AlbumArtistFailed = "failed"
+)
+
+type AlbumArtistAction struct {
+	Path     string `json:"path"`
+	Artist   string `json:"artist,omitempty"`
+	Existing string `json:"existing,omitempty"`
+	Action   string `json:"action"`
+	Error    string `json:"error,omitempty"`
+}
+
+// AlbumArtistInference is the result of InferAlbumArtist. Support is how
+// many of Tracks credit AlbumArtist as a primary artist; for "Various
+// Artists" it is the support of the best candidate, which fell short.
+type AlbumArtistInference struct {
+	Directory          string              `json:"directory"`
+	AlbumArtist        string              `json:"album_artist"`
+	Various            bool                `json:"various"`
+	Support            int                 `json:"support"`
+	Tracks             int                 `json:"tracks"`
+	Threshold          float64             `json:"threshold"`
+	Applied            bool                `json:"applied"`
+	Set                int                 `json:"set"`
+	QuarantinedSkipped int                 `json:"quarantined_skipped"`
+	Actions            []AlbumArtistAction `json:"actions"`
+}
+
+// inferAlbumArtist returns the primary artist credited on more than
+// threshold of the tracks, or "Various Artists". Each track counts once per
+// primary artist, compared ignoring case; the first spelling seen is used.
+func inferAlbumArtist(artists []string, threshold float64) (artist string, support int, various bool) {
+	counts := map[string]int{}
+	spelling := map[string]string{}
+	var order []string
+	for _, raw := range artists {
+		primary, _ := splitFeaturedArtists(raw)
+		for _, name := range primary {
+			key := strings.ToLower(name)
+			if counts[key] == 0 {
+				order = append(order, key)
+				spelling[key] = name
+			}
+			counts[key]++
+		}
+	}
+	best := ""
+	for _, key := range order {
+		if counts[key] > counts[best] {
+			best = key
+		}
+	}
+	if len(artists) == 0 || float64(counts[best]) <= threshold*float64(len(artists)) {
+		return variousArtistsName, counts[best], true
+	}
+	return spelling[best], counts[best], false
+}
+
+// InferAlbumArtist works out the album artist of the tracks in dirPath and
+// its subfolders: the primary artist (featured artists ignored) most tracks
+// credit, or "Various Artists" when none is credited on more than the
+// configured AlbumArtistMajority of them. The result lists per track whether
+// ALBUMARTIST would be set; with apply it is written to the FLAC files that
+// have none. Existing album artists are never overwritten.
+func InferAlbumArtist(dirPath string, apply bool) (string, error) {
+	if strings.TrimSpace(dirPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(dirPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", dirPath)
+	}
+	if apply {
+		if err := checkWriteAllowed(dirPath); err != nil {
+			return "", err
+		}
+	}
+	files, quarantined, err := collectWritableLibraryFiles(dirPath, nil)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+
+	threshold := GetBackendConfig().AlbumArtistMajority
+	result := AlbumArtistInference{Directory: dirPath, Threshold: threshold, Applied: apply, Actions: []AlbumArtistAction{}}
+	result.QuarantinedSkipped = quarantined
+	var artists []string
+	scanTime := time.Now().UTC().Format(time.RFC3339)
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file.path), ".cue") {
+			continue
+		}
+		action := AlbumArtistAction{Path: file.path}
+		scanned, err := scanAudioFileWithKnownModTime(file.path, scanTime, file.modTime)
+		if err == nil && scanned.MetadataFromFilename {
+			err = fmt.Errorf("no readable tags")
+		}
+		if err != nil {
+			action.Action, action.Error = AlbumArtistFailed, err.Error()
+			result.Actions = append(result.Actions, action)
+			continue
+		}
+		action.Artist = scanned.ArtistName
+		action.Existing = strings.TrimSpace(scanned.AlbumArtist)
+		switch {
+		case action.Existing != "":
+			action.Action = AlbumArtistKeep
+		case strings.EqualFold(filepath.Ext(file.path), ".flac"):
+			action.Action = AlbumArtistSet
+		default:
+			action.Action = AlbumArtistUnsupported
+		}
+		artists = append(artists, scanned.ArtistName)
+		result.Actions = append(result.Actions, action)
+	}
+	result.Tracks = len(artists)
+	if result.Tracks == 0 {
+		return "", fmt.Errorf("no readable tracks in %s", dirPath)
+	}
+	result.AlbumArtist, result.Support, result.Various = inferAlbumArtist(artists, threshold)
+
+	for i := range result.Actions {
+		action := &result.Actions[i]
+		if action.Action != AlbumArtistSet {
+			continue
+		}
+		if apply {
+			if err := EditFlacFields(action.Path, map[string]string{"album_artist": result.AlbumArtist}); err != nil {
+				action.Action, action.Error = AlbumArtistFailed, err.Error()
+				continue
+			}
+		}
+		result.Set++
+	}
+
+	GoLog("[AlbumArtist] Inferred %q for %s (%d of %d tracks), %d to set (apply: %v)\n",
+		result.AlbumArtist, dirPath, result.Support, result.Tracks, result.Set, apply)
+
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
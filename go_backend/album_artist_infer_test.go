@@ -0,0 +1,68 @@
+package gobackend
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func runInferAlbumArtist(t *testing.T, dir string, apply bool) AlbumArtistInference {
+	t.Helper()
+	out, err := InferAlbumArtist(dir, apply)
+	result := mustDecodeJSON[AlbumArtistInference](t, out, err)
+	return result
+}
+
+func TestInferAlbumArtistMajority(t *testing.T) {
+	cases := []struct {
+		artists []string
+		want    string
+		support int
+	}{
+		{[]string{"Artist", "Artist feat. Guest", "artist & Other"}, "Artist", 3},
+		{[]string{"Artist", "Artist", "Other", "Third"}, variousArtistsName, 2},
+		{[]string{"A", "B", "C"}, variousArtistsName, 1},
+		{[]string{"Simon & Garfunkel", "Simon & Garfunkel feat. Guest"}, "Simon & Garfunkel", 2},
+	}
+	for _, tc := range cases {
+		got, support, various := inferAlbumArtist(tc.artists, defaultAlbumArtistMajority)
+		if got != tc.want || support != tc.support || various != (tc.want == variousArtistsName) {
+			t.Fatalf("inferAlbumArtist(%q) = %q, %d, %v", tc.artists, got, support, various)
+		}
+	}
+	if got, _, _ := inferAlbumArtist([]string{"Artist", "Artist", "Other", "Third"}, 0.4); got != "Artist" {
+		t.Fatalf("lower threshold = %q", got)
+	}
+}
+
+func TestInferAlbumArtistApply(t *testing.T) {
+	root := t.TempDir()
+	first := writeConsistencyFixture(t, root, "01.flac", Metadata{Title: "One", Artist: "Band feat. Guest"})
+	second := writeConsistencyFixture(t, root, "CD2/02.flac", Metadata{Title: "Two", Artist: "Band"})
+	tagged := writeConsistencyFixture(t, root, "03.flac", Metadata{Title: "Three", Artist: "Other", AlbumArtist: "Someone Else"})
+
+	preview := runInferAlbumArtist(t, root, false)
+	if preview.AlbumArtist != "Band" || preview.Various || preview.Support != 2 || preview.Tracks != 3 || preview.Set != 2 || preview.Applied {
+		t.Fatalf("preview = %+v", preview)
+	}
+	if meta, _ := ReadMetadata(first); meta.AlbumArtist != "" {
+		t.Fatal("preview wrote tags")
+	}
+
+	result := runInferAlbumArtist(t, root, true)
+	actions := map[string]string{}
+	for _, action := range result.Actions {
+		actions[filepath.Base(action.Path)] = action.Action
+	}
+	if result.Set != 2 || actions["01.flac"] != AlbumArtistSet || actions["02.flac"] != AlbumArtistSet || actions["03.flac"] != AlbumArtistKeep {
+		t.Fatalf("result = %+v", result)
+	}
+	for path, want := range map[string]string{first: "Band", second: "Band", tagged: "Someone Else"} {
+		if meta, _ := ReadMetadata(path); meta.AlbumArtist != want {
+			t.Fatalf("%s: album artist %q, want %q", path, meta.AlbumArtist, want)
+		}
+	}
+
+	if again := runInferAlbumArtist(t, root, true); again.Set != 0 {
+		t.Fatalf("second run set %d tracks", again.Set)
+	}
+}
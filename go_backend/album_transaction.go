@@ -0,0 +1,625 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-flac/flacvorbis/v2"
+	"github.com/go-flac/go-flac/v2"
+)
+
+const (
+	albumTransactionsDirName = "album_transactions"
+	albumTxnStagedSuffix     = ".spotiflac-txn"
+	albumTxnBackupSuffix     = ".spotiflac-txn-orig"
+)
+
+// Manifest states. A transaction interrupted while preparing is rolled back
+// on recovery; one interrupted while applying is finished.
+const (
+	albumTxnPreparing = "preparing"
+	albumTxnApplying  = "applying"
+)
+
+// errAlbumTransactionsUnavailable means no DataDir is configured, so an
+// interrupted commit could not be recovered and none is started.
+var errAlbumTransactionsUnavailable = errors.New("album transactions need a data directory")
+
+// ErrAlbumTransactionFinished is returned when a transaction is used after
+// Commit or Rollback.
+var ErrAlbumTransactionFinished = errors.New("album transaction already finished")
+
+// albumTxnStepHook, when set, runs after each staged file and each applied
+// file. Tests use it to stop a commit the way a killed process would.
+var albumTxnStepHook func(phase string, index int)
+
+// albumTxnEntry is one file of a commit. Staged holds the new content and
+// is renamed to Target once Source has been moved to Backup; an entry
+// without Staged is a plain rename of Source to Target, and one without
+// Source creates Target. Cover marks the folder cover.
+type albumTxnEntry struct {
+	Source string `json:"source,omitempty"`
+	Target string `json:"target"`
+	Staged string `json:"staged,omitempty"`
+	Backup string `json:"backup,omitempty"`
+	Cover  bool   `json:"cover,omitempty"`
+}
+
+type albumTxnManifest struct {
+	ID        string          `json:"id"`
+	Dir       string          `json:"dir"`
+	State     string          `json:"state"`
+	CreatedAt int64           `json:"created_at"`
+	Entries   []albumTxnEntry `json:"entries"`
+}
+
+// AlbumTransactionReport is the result of AlbumTransaction.Commit.
+type AlbumTransactionReport struct {
+	ID       string `json:"id"`
+	Dir      string `json:"dir"`
+	Retagged int    `json:"retagged"`
+	Renamed  int    `json:"renamed"`
+	Cover    string `json:"cover,omitempty"`
+}
+
+// AlbumRecoveryReport is the result of RecoverAlbumTransactions.
+type AlbumRecoveryReport struct {
+	Completed  int               `json:"completed"`
+	RolledBack int               `json:"rolled_back"`
+	Errors     map[string]string `json:"errors,omitempty"`
+}
+
+type albumTxnFileOp struct {
+	order   []string
+	values  map[string][]string
+	newName string
+}
+
+// AlbumTransaction groups retags, renames and a folder cover for one album
+// directory so they apply together. Commit writes every new file aside
+// first and then renames them into place in a quick final phase, recorded
+// in a manifest so RecoverAlbumTransactions can finish or revert a commit
+// cut short by a crash.
+type AlbumTransaction struct {
+	mu        sync.Mutex
+	id        string
+	dir       string
+	files     []string
+	ops       map[string]*albumTxnFileOp
+	cover     []byte
+	coverName string
+	finished  bool
+}
+
+var albumTxnSeq struct {
+	sync.Mutex
+	last int64
+}
+
+func newAlbumTxnID() string {
+	albumTxnSeq.Lock()
+	defer albumTxnSeq.Unlock()
+	id := max(time.Now().UnixNano(), albumTxnSeq.last+1)
+	albumTxnSeq.last = id
+	return strconv.FormatInt(id, 36)
+}
+
+func albumTransactionsDir() (string, error) {
+	dataDir := GetBackendConfig().DataDir
+	if dataDir == "" {
+		return "", errAlbumTransactionsUnavailable
+	}
+	return filepath.Join(dataDir, albumTransactionsDirName), nil
+}
+
+func saveAlbumTxnManifest(manifest *albumTxnManifest) error {
+	dir, err := albumTransactionsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, manifest.ID+".json")
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write transaction manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write transaction manifest: %w", err)
+	}
+	return nil
+}
+
+func removeAlbumTxnManifest(id string) {
+	if dir, err := albumTransactionsDir(); err == nil {
+		os.Remove(filepath.Join(dir, id+".json"))
+	}
+}
+
+// BeginAlbumTransaction starts a transaction over the album in dirPath.
+// Nothing is written until Commit.
+func BeginAlbumTransaction(dirPath string) (*AlbumTransaction, error) {
+	if strings.TrimSpace(dirPath) == "" {
+		return nil, fmt.Errorf("folder path is empty")
+	}
+	info, err := os.Stat(dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("folder not found: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("path is not a folder: %s", dirPath)
+	}
+	if _, err := albumTransactionsDir(); err != nil {
+		return nil, err
+	}
+	return &AlbumTransaction{
+		id:  newAlbumTxnID(),
+		dir: filepath.Clean(dirPath),
+		ops: make(map[string]*albumTxnFileOp),
+	}, nil
+}
+
+// ID returns the transaction id, which names its manifest.
+func (t *AlbumTransaction) ID() string {
+	return t.id
+}
+
+// fileOp returns the queued operation of filePath, which must be a file
+// directly inside the album directory.
+func (t *AlbumTransaction) fileOp(filePath string) (*albumTxnFileOp, error) {
+	if t.finished {
+		return nil, ErrAlbumTransactionFinished
+	}
+	filePath = filepath.Clean(filePath)
+	if filepath.Dir(filePath) != t.dir {
+		return nil, fmt.Errorf("%s is not in %s", filePath, t.dir)
+	}
+	if info, err := os.Stat(filePath); err != nil {
+		return nil, fmt.Errorf("file not found: %w", err)
+	} else if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("not a regular file: %s", filePath)
+	}
+	op, ok := t.ops[filePath]
+	if !ok {
+		op = &albumTxnFileOp{values: make(map[string][]string)}
+		t.ops[filePath] = op
+		t.files = append(t.files, filePath)
+	}
+	return op, nil
+}
+
+// QueueRetag replaces the Vorbis comments named in pairs on a FLAC file of
+// the album; a pair with an empty value removes its key. Later retags of
+// the same key win.
+func (t *AlbumTransaction) QueueRetag(filePath string, pairs []TagPair) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !strings.EqualFold(filepath.Ext(filePath), ".flac") {
+		return fmt.Errorf("only FLAC files can be retagged: %s", filePath)
+	}
+	order, values, err := groupTagPairs(pairs)
+	if err != nil {
+		return err
+	}
+	op, err := t.fileOp(filePath)
+	if err != nil {
+		return err
+	}
+	for _, key := range order {
+		if _, seen := op.values[key]; !seen {
+			op.order = append(op.order, key)
+		}
+		var kept []string
+		for _, value := range values[key] {
+			if value != "" {
+				kept = append(kept, value)
+			}
+		}
+		op.values[key] = kept
+	}
+	return nil
+}
+
+// QueueRename renames a file of the album to newName within the directory.
+func (t *AlbumTransaction) QueueRename(filePath, newName string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	newName = strings.TrimSpace(newName)
+	if newName == "" || newName != filepath.Base(newName) || newName == "." || newName == ".." {
+		return fmt.Errorf("new name must be a plain file name: %s", newName)
+	}
+	op, err := t.fileOp(filePath)
+	if err != nil {
+		return err
+	}
+	op.newName = newName
+	return nil
+}
+
+// QueueCover writes coverData as the folder cover, named filename
+// (folder.jpg when empty; the extension follows the image type).
+func (t *AlbumTransaction) QueueCover(coverData []byte, filename string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return ErrAlbumTransactionFinished
+	}
+	if len(coverData) == 0 {
+		return fmt.Errorf("cover data is empty")
+	}
+	filename = strings.TrimSpace(filename)
+	if filename == "" {
+		filename = defaultAlbumArtFilename
+	}
+	if filename != filepath.Base(filename) || filename == "." || filename == ".." {
+		return fmt.Errorf("filename must be a plain file name: %s", filename)
+	}
+	t.cover = coverData
+	t.coverName = albumArtTargetName(filename, detectCoverMIME("", coverData))
+	return nil
+}
+
+// plan lays out the entries of the commit, rejecting targets that would
+// overwrite files outside the transaction and files that are playing or
+// have a pending write, which only a deferred save may replace.
+func (t *AlbumTransaction) plan() ([]albumTxnEntry, error) {
+	var entries []albumTxnEntry
+	targets := make(map[string]bool)
+	claim := func(target string) error {
+		if targets[target] {
+			return fmt.Errorf("two operations write %s", target)
+		}
+		targets[target] = true
+		return checkWriteAllowed(target)
+	}
+
+	for _, source := range t.files {
+		op := t.ops[source]
+		if hasPendingWrite(source) || isPlaybackActive(source) {
+			return nil, fmt.Errorf("%s: %w", source, ErrWritePending)
+		}
+		target := source
+		if op.newName != "" {
+			target = filepath.Join(t.dir, op.newName)
+		}
+		if target != source {
+			if _, err := os.Lstat(target); err == nil {
+				return nil, fmt.Errorf("target already exists: %s", target)
+			}
+		}
+		if err := claim(target); err != nil {
+			return nil, err
+		}
+		entry := albumTxnEntry{Source: source, Target: target}
+		if len(op.order) > 0 {
+			entry.Staged = source + albumTxnStagedSuffix
+			entry.Backup = source + albumTxnBackupSuffix
+		} else if target == source {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if t.cover != nil {
+		target := filepath.Join(t.dir, t.coverName)
+		if err := claim(target); err != nil {
+			return nil, err
+		}
+		entry := albumTxnEntry{Target: target, Staged: target + albumTxnStagedSuffix, Cover: true}
+		if _, err := os.Stat(target); err == nil {
+			entry.Source, entry.Backup = target, target+albumTxnBackupSuffix
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// stageAlbumTxnRetag writes source with op's comment changes to staged,
+// recording them in the tag history and repairing malformed comments as a
+// normal save does.
+func stageAlbumTxnRetag(source, staged string, op *albumTxnFileOp) error {
+	f, err := flac.ParseFile(source)
+	if err != nil {
+		return fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+	// Only the metadata is needed; the audio is re-read from disk.
+	f.Close()
+	before := takeTagSnapshot(f)
+
+	cmtIdx := -1
+	cmt := flacvorbis.New()
+	for idx, meta := range f.Meta {
+		if meta.Type == flac.VorbisComment {
+			cmtIdx = idx
+			if cmt, err = parseVorbisCommentBlock(*meta); err != nil {
+				return fmt.Errorf("failed to parse vorbis comment: %w", err)
+			}
+			break
+		}
+	}
+	comments := newVorbisCommentMap(cmt.Comments)
+	for _, key := range op.order {
+		if values := op.values[key]; len(values) > 0 {
+			comments.setValues(key, values)
+		} else {
+			comments.remove(key)
+		}
+	}
+	cmt.Comments = comments.comments()
+	cmtBlock := cmt.Marshal()
+	if cmtIdx >= 0 {
+		f.Meta[cmtIdx] = &cmtBlock
+	} else {
+		f.Meta = append(f.Meta, &cmtBlock)
+	}
+	recordTagHistory(f, "album_transaction", before)
+	logMalformedComments(source, repairFLACComments(f))
+
+	blocks := normalizeFLACPadding(f.Meta, GetBackendConfig().PaddingTarget)
+	_, err = writeFLACStreamingTemp(source, staged, blocks)
+	return err
+}
+
+func albumTxnExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// applyAlbumTxnEntry moves entry into place. Steps already done by an
+// interrupted commit are skipped, so recovery can run it again.
+func applyAlbumTxnEntry(entry albumTxnEntry) error {
+	defer invalidateMetadataCache(entry.Target)
+	if entry.Staged == "" {
+		if !albumTxnExists(entry.Source) && albumTxnExists(entry.Target) {
+			return nil
+		}
+		return os.Rename(entry.Source, entry.Target)
+	}
+	if !albumTxnExists(entry.Staged) {
+		if albumTxnExists(entry.Target) {
+			return nil
+		}
+		return fmt.Errorf("staged file is missing: %s", entry.Staged)
+	}
+	if entry.Source != "" && albumTxnExists(entry.Source) {
+		if err := os.Rename(entry.Source, entry.Backup); err != nil {
+			return err
+		}
+	}
+	return os.Rename(entry.Staged, entry.Target)
+}
+
+// revertAlbumTxnEntry undoes entry, whether it was applied, staged only or
+// left half way by a crash.
+func revertAlbumTxnEntry(entry albumTxnEntry) error {
+	defer invalidateMetadataCache(entry.Target)
+	if entry.Staged == "" {
+		if !albumTxnExists(entry.Source) && albumTxnExists(entry.Target) {
+			return os.Rename(entry.Target, entry.Source)
+		}
+		return nil
+	}
+	if albumTxnExists(entry.Staged) {
+		os.Remove(entry.Staged)
+	} else if entry.Source != entry.Target {
+		// Applied: Target holds the new content.
+		if err := os.Remove(entry.Target); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if entry.Source != "" && albumTxnExists(entry.Backup) {
+		return os.Rename(entry.Backup, entry.Source)
+	}
+	return nil
+}
+
+// finishAlbumTxn removes the backups of an applied transaction and its
+// manifest.
+func finishAlbumTxn(manifest *albumTxnManifest) {
+	for _, entry := range manifest.Entries {
+		if entry.Backup != "" {
+			os.Remove(entry.Backup)
+		}
+	}
+	removeAlbumTxnManifest(manifest.ID)
+}
+
+func rollbackAlbumTxn(manifest *albumTxnManifest, entries []albumTxnEntry) error {
+	var errs []error
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := revertAlbumTxnEntry(entries[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		// Keep the manifest so recovery can try again.
+		return errors.Join(errs...)
+	}
+	removeAlbumTxnManifest(manifest.ID)
+	return nil
+}
+
+func albumTxnStep(phase string, index int) {
+	if albumTxnStepHook != nil {
+		albumTxnStepHook(phase, index)
+	}
+}
+
+// Commit applies every queued operation or none. On failure the album is
+// left as it was and the error is returned.
+func (t *AlbumTransaction) Commit() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return "", ErrAlbumTransactionFinished
+	}
+	t.finished = true
+
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	entries, err := t.plan()
+	if err != nil {
+		return "", err
+	}
+	report := AlbumTransactionReport{ID: t.id, Dir: t.dir}
+	manifest := &albumTxnManifest{
+		ID:        t.id,
+		Dir:       t.dir,
+		State:     albumTxnPreparing,
+		CreatedAt: time.Now().Unix(),
+		Entries:   entries,
+	}
+	if err := saveAlbumTxnManifest(manifest); err != nil {
+		return "", err
+	}
+
+	for i, entry := range entries {
+		var err error
+		switch {
+		case entry.Cover:
+			err = os.WriteFile(entry.Staged, t.cover, 0644)
+		case entry.Staged != "":
+			err = stageAlbumTxnRetag(entry.Source, entry.Staged, t.ops[entry.Source])
+		}
+		if err != nil {
+			rollbackAlbumTxn(manifest, entries)
+			return "", err
+		}
+		albumTxnStep(albumTxnPreparing, i)
+	}
+
+	manifest.State = albumTxnApplying
+	if err := saveAlbumTxnManifest(manifest); err != nil {
+		rollbackAlbumTxn(manifest, entries)
+		return "", err
+	}
+	for i, entry := range entries {
+		if err := applyAlbumTxnEntry(entry); err != nil {
+			if rbErr := rollbackAlbumTxn(manifest, entries[:i+1]); rbErr != nil {
+				GoLog("[AlbumTxn] Rollback of %s incomplete: %v\n", t.id, rbErr)
+			}
+			return "", fmt.Errorf("failed to apply %s: %w", entry.Target, err)
+		}
+		albumTxnStep(albumTxnApplying, i)
+	}
+	finishAlbumTxn(manifest)
+
+	for _, entry := range entries {
+		switch {
+		case entry.Cover:
+			report.Cover = entry.Target
+		case entry.Target != entry.Source:
+			report.Renamed++
+		}
+		if !entry.Cover && entry.Staged != "" {
+			report.Retagged++
+		}
+	}
+	GoLog("[AlbumTxn] Committed %s in %s: %d retagged, %d renamed\n", t.id, t.dir, report.Retagged, report.Renamed)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// Rollback discards the queued operations. Nothing has been written yet,
+// so there is nothing to undo; a failed Commit has already rolled back.
+func (t *AlbumTransaction) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.finished {
+		return ErrAlbumTransactionFinished
+	}
+	t.finished = true
+	t.files, t.ops, t.cover = nil, nil, nil
+	return nil
+}
+
+// RecoverAlbumTransactions settles commits interrupted by a crash; call it
+// at startup once DataDir is configured. A commit cut short while writing
+// its new files is rolled back, one cut short while renaming them into
+// place is finished.
+func RecoverAlbumTransactions() (string, error) {
+	dir, err := albumTransactionsDir()
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read transactions: %w", err)
+	}
+
+	report := AlbumRecoveryReport{}
+	fail := func(name string, err error) {
+		if report.Errors == nil {
+			report.Errors = make(map[string]string)
+		}
+		report.Errors[name] = err.Error()
+	}
+	for _, dirEntry := range entries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			fail(name, err)
+			continue
+		}
+		var manifest albumTxnManifest
+		if err := json.Unmarshal(data, &manifest); err != nil || manifest.ID == "" {
+			GoLog("[AlbumTxn] Discarding unreadable manifest %s\n", name)
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+
+		if manifest.State != albumTxnApplying {
+			if err := rollbackAlbumTxn(&manifest, manifest.Entries); err != nil {
+				fail(manifest.ID, err)
+				continue
+			}
+			report.RolledBack++
+			continue
+		}
+		var applyErr error
+		for _, entry := range manifest.Entries {
+			if applyErr = applyAlbumTxnEntry(entry); applyErr != nil {
+				break
+			}
+		}
+		if applyErr != nil {
+			fail(manifest.ID, applyErr)
+			continue
+		}
+		finishAlbumTxn(&manifest)
+		report.Completed++
+	}
+	if report.Completed+report.RolledBack > 0 {
+		GoLog("[AlbumTxn] Recovered %d, rolled back %d\n", report.Completed, report.RolledBack)
+	}
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,310 @@
+package gobackend
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var errSimulatedCrash = errors.New("simulated crash")
+
+// writeTransactionAlbum configures a DataDir and writes an album directory
+// with two tagged tracks.
+func writeTransactionAlbum(t *testing.T) (dir string, tracks []string) {
+	t.Helper()
+	configureQuarantineTest(t)
+	dir = filepath.Join(t.TempDir(), "album")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, name := range []string{"track1.flac", "track2.flac"} {
+		data := mustReadFile(t, writeTestFLACWithMetadata(t, Metadata{Title: name, Album: "Old Album"}))
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("write track: %v", err)
+		}
+		tracks = append(tracks, path)
+	}
+	return dir, tracks
+}
+
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func albumTagValue(t *testing.T, path, key string) string {
+	t.Helper()
+	comments, err := readVorbisCommentList(path)
+	if err != nil {
+		t.Fatalf("readVorbisCommentList(%s): %v", path, err)
+	}
+	return newVorbisCommentMap(comments).get(key)
+}
+
+// queueAlbumFix queues the retag, rename and cover used by the tests.
+func queueAlbumFix(t *testing.T, dir string, tracks []string, cover []byte) *AlbumTransaction {
+	t.Helper()
+	txn, err := BeginAlbumTransaction(dir)
+	if err != nil {
+		t.Fatalf("BeginAlbumTransaction: %v", err)
+	}
+	for _, track := range tracks {
+		if err := txn.QueueRetag(track, []TagPair{{Key: "album", Value: "New Album"}}); err != nil {
+			t.Fatalf("QueueRetag: %v", err)
+		}
+	}
+	if err := txn.QueueRename(tracks[0], "01 - First.flac"); err != nil {
+		t.Fatalf("QueueRename: %v", err)
+	}
+	if err := txn.QueueRename(tracks[1], "02 - Second.flac"); err != nil {
+		t.Fatalf("QueueRename: %v", err)
+	}
+	if err := txn.QueueCover(cover, ""); err != nil {
+		t.Fatalf("QueueCover: %v", err)
+	}
+	return txn
+}
+
+// commitCrashingAt runs Commit and stops it, like a killed process, right
+// after step index of phase.
+func commitCrashingAt(t *testing.T, txn *AlbumTransaction, phase string, index int) {
+	t.Helper()
+	albumTxnStepHook = func(p string, i int) {
+		if p == phase && i == index {
+			panic(errSimulatedCrash)
+		}
+	}
+	defer func() {
+		albumTxnStepHook = nil
+		if r := recover(); r != errSimulatedCrash {
+			t.Fatalf("expected simulated crash, got %v", r)
+		}
+	}()
+	txn.Commit()
+}
+
+func recoverAlbumTransactionsForTest(t *testing.T) AlbumRecoveryReport {
+	t.Helper()
+	out, err := RecoverAlbumTransactions()
+	report := mustDecodeJSON[AlbumRecoveryReport](t, out, err)
+	return report
+}
+
+func assertAlbumFixed(t *testing.T, dir string, cover []byte) {
+	t.Helper()
+	coverName := albumArtTargetName(defaultAlbumArtFilename, detectCoverMIME("", cover))
+	want := []string{"01 - First.flac", "02 - Second.flac", coverName}
+	sort.Strings(want)
+	if got := dirNames(t, dir); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("directory = %v, want %v", got, want)
+	}
+	for _, name := range []string{"01 - First.flac", "02 - Second.flac"} {
+		if got := albumTagValue(t, filepath.Join(dir, name), "ALBUM"); got != "New Album" {
+			t.Fatalf("%s ALBUM = %q", name, got)
+		}
+	}
+	if got := mustReadFile(t, filepath.Join(dir, coverName)); !bytes.Equal(got, cover) {
+		t.Fatalf("folder cover does not match")
+	}
+}
+
+func assertAlbumUntouched(t *testing.T, dir string, originals map[string][]byte) {
+	t.Helper()
+	var want []string
+	for name := range originals {
+		want = append(want, name)
+	}
+	sort.Strings(want)
+	if got := dirNames(t, dir); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("directory = %v, want %v", got, want)
+	}
+	for name, data := range originals {
+		if !bytes.Equal(mustReadFile(t, filepath.Join(dir, name)), data) {
+			t.Fatalf("%s changed", name)
+		}
+	}
+}
+
+func snapshotDir(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	files := make(map[string][]byte)
+	for _, name := range dirNames(t, dir) {
+		files[name] = mustReadFile(t, filepath.Join(dir, name))
+	}
+	return files
+}
+
+func TestAlbumTransactionCommit(t *testing.T) {
+	dir, tracks := writeTransactionAlbum(t)
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	txn := queueAlbumFix(t, dir, tracks, cover)
+
+	out, err := txn.Commit()
+	report := mustDecodeJSON[AlbumTransactionReport](t, out, err)
+	if report.Retagged != 2 || report.Renamed != 2 || report.Cover == "" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	assertAlbumFixed(t, dir, cover)
+	if got := albumTagValue(t, filepath.Join(dir, "01 - First.flac"), "TITLE"); got != "track1.flac" {
+		t.Fatalf("TITLE = %q, other tags must be kept", got)
+	}
+	if report := recoverAlbumTransactionsForTest(t); report.Completed+report.RolledBack != 0 {
+		t.Fatalf("manifest left behind: %+v", report)
+	}
+	if _, err := txn.Commit(); !errors.Is(err, ErrAlbumTransactionFinished) {
+		t.Fatalf("second Commit = %v", err)
+	}
+}
+
+func TestAlbumTransactionRecordsTagHistory(t *testing.T) {
+	dir, tracks := writeTransactionAlbum(t)
+	withBackendConfig(t, func(cfg *BackendConfig) { cfg.TagHistory = true })
+	txn, err := BeginAlbumTransaction(dir)
+	if err != nil {
+		t.Fatalf("BeginAlbumTransaction: %v", err)
+	}
+	if err := txn.QueueRetag(tracks[0], []TagPair{{Key: "album", Value: "New Album"}}); err != nil {
+		t.Fatalf("QueueRetag: %v", err)
+	}
+
+	out, err := txn.Commit()
+	if report := mustDecodeJSON[AlbumTransactionReport](t, out, err); report.Retagged != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	entries := readTagHistory(t, tracks[0])
+	last := entries[len(entries)-1]
+	if last.Operation != "album_transaction" || strings.Join(last.Fields, ",") != "ALBUM" {
+		t.Fatalf("unexpected history: %+v", entries)
+	}
+}
+
+func TestAlbumTransactionRejectsPlayingFile(t *testing.T) {
+	dir, tracks := writeTransactionAlbum(t)
+	originals := snapshotDir(t, dir)
+	txn, err := BeginAlbumTransaction(dir)
+	if err != nil {
+		t.Fatalf("BeginAlbumTransaction: %v", err)
+	}
+	if err := txn.QueueRetag(tracks[1], []TagPair{{Key: "album", Value: "New Album"}}); err != nil {
+		t.Fatalf("QueueRetag: %v", err)
+	}
+	SetPlaybackActive(tracks[1], true)
+	t.Cleanup(func() { SetPlaybackActive(tracks[1], false) })
+
+	if _, err := txn.Commit(); !errors.Is(err, ErrWritePending) {
+		t.Fatalf("Commit = %v, want ErrWritePending", err)
+	}
+	assertAlbumUntouched(t, dir, originals)
+}
+
+func TestAlbumTransactionRollbackAndFailedCommit(t *testing.T) {
+	dir, tracks := writeTransactionAlbum(t)
+	originals := snapshotDir(t, dir)
+
+	txn := queueAlbumFix(t, dir, tracks, []byte("cover"))
+	if err := txn.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if _, err := txn.Commit(); !errors.Is(err, ErrAlbumTransactionFinished) {
+		t.Fatalf("Commit after Rollback = %v", err)
+	}
+	assertAlbumUntouched(t, dir, originals)
+
+	// The second track stops parsing after it is queued, so staging fails
+	// once the first track has been written aside.
+	txn = queueAlbumFix(t, dir, tracks, []byte("cover"))
+	if err := os.WriteFile(tracks[1], []byte("not a flac"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	originals = snapshotDir(t, dir)
+	if _, err := txn.Commit(); err == nil {
+		t.Fatalf("expected Commit to fail")
+	}
+	assertAlbumUntouched(t, dir, originals)
+
+	txn, err := BeginAlbumTransaction(dir)
+	if err != nil {
+		t.Fatalf("BeginAlbumTransaction: %v", err)
+	}
+	if err := txn.QueueRename(tracks[0], "track2.flac"); err != nil {
+		t.Fatalf("QueueRename: %v", err)
+	}
+	if _, err := txn.Commit(); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected existing target to be refused, got %v", err)
+	}
+	if err := txn.QueueRename(filepath.Join(t.TempDir(), "x.flac"), "y.flac"); !errors.Is(err, ErrAlbumTransactionFinished) {
+		t.Fatalf("QueueRename after Commit = %v", err)
+	}
+}
+
+func TestAlbumTransactionRecoversCrashWhileApplying(t *testing.T) {
+	dir, tracks := writeTransactionAlbum(t)
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+
+	commitCrashingAt(t, queueAlbumFix(t, dir, tracks, cover), albumTxnApplying, 0)
+	names := strings.Join(dirNames(t, dir), "|")
+	if !strings.Contains(names, albumTxnStagedSuffix) {
+		t.Fatalf("expected staged files after the crash, got %s", names)
+	}
+
+	report := recoverAlbumTransactionsForTest(t)
+	if report.Completed != 1 || report.RolledBack != 0 || len(report.Errors) != 0 {
+		t.Fatalf("unexpected recovery: %+v", report)
+	}
+	assertAlbumFixed(t, dir, cover)
+}
+
+func TestAlbumTransactionRevertsCrashWhilePreparing(t *testing.T) {
+	dir, tracks := writeTransactionAlbum(t)
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "folder.png"), []byte("old cover"), 0644); err != nil {
+		t.Fatalf("write cover: %v", err)
+	}
+	originals := snapshotDir(t, dir)
+
+	commitCrashingAt(t, queueAlbumFix(t, dir, tracks, cover), albumTxnPreparing, 1)
+	report := recoverAlbumTransactionsForTest(t)
+	if report.Completed != 0 || report.RolledBack != 1 || len(report.Errors) != 0 {
+		t.Fatalf("unexpected recovery: %+v", report)
+	}
+	assertAlbumUntouched(t, dir, originals)
+}
+
+func TestBeginAlbumTransactionNeedsDataDir(t *testing.T) {
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	cfg := original
+	cfg.DataDir = ""
+	if err := SetBackendConfig(cfg); err != nil {
+		t.Fatalf("SetBackendConfig: %v", err)
+	}
+	if _, err := BeginAlbumTransaction(t.TempDir()); !errors.Is(err, errAlbumTransactionsUnavailable) {
+		t.Fatalf("BeginAlbumTransaction = %v", err)
+	}
+	if _, err := BeginAlbumTransaction(""); err == nil {
+		t.Fatalf("expected empty path to be refused")
+	}
+}
@@ -0,0 +1,365 @@
+package gobackend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of AlbumInconsistency.
+const (
+	// AlbumMixedTag means the tracks disagree on an album-level tag; Field
+	// names it and Values lists what was found.
+	AlbumMixedTag = "mixed_tag"
+	// AlbumMixedCovers means the tracks embed more than one distinct cover.
+	AlbumMixedCovers = "mixed_covers"
+	// AlbumMissingCover means some tracks have no embedded cover.
+	AlbumMissingCover = "missing_cover"
+	// AlbumMissingTrackNumber means some tracks have no track number.
+	AlbumMissingTrackNumber = "missing_track_number"
+	// AlbumDuplicateTrackNumber means two tracks share a disc and track
+	// number.
+	AlbumDuplicateTrackNumber = "duplicate_track_number"
+	// AlbumTrackGap means a disc's track numbers skip some values.
+	AlbumTrackGap = "track_gap"
+	// AlbumMixedQuality means the tracks differ in format, bit depth or
+	// sample rate.
+	AlbumMixedQuality = "mixed_quality"
+)
+
+type AlbumInconsistency struct {
+	Kind    string   `json:"kind"`
+	Field   string   `json:"field,omitempty"`
+	Values  []string `json:"values,omitempty"`
+	Tracks  []string `json:"tracks,omitempty"`
+	Message string   `json:"message"`
+}
+
+type AlbumTrack struct {
+	Path        string `json:"path"`
+	DiscNumber  int    `json:"disc_number"`
+	TrackNumber int    `json:"track_number"`
+	Title       string `json:"title"`
+	Artist      string `json:"artist"`
+	Duration    int    `json:"duration"`
+	Format      string `json:"format"`
+	BitDepth    int    `json:"bit_depth,omitempty"`
+	SampleRate  int    `json:"sample_rate,omitempty"`
+	Bitrate     int    `json:"bitrate,omitempty"`
+	Size        int64  `json:"size"`
+	HasCover    bool   `json:"has_cover"`
+}
+
+func (t AlbumTrack) ref() TrackRef {
+	return TrackRef{Path: t.Path, DiscNumber: t.DiscNumber, TrackNumber: t.TrackNumber}
+}
+
+// AlbumCoverInfo describes the cover most tracks embed. Hash is the SHA-256
+// of the raw embedded bytes, as in AlbumCoverVariant.
+type AlbumCoverInfo struct {
+	Hash            string `json:"hash"`
+	Size            int    `json:"size"`
+	Width           int    `json:"width,omitempty"`
+	Height          int    `json:"height,omitempty"`
+	TracksWithCover int    `json:"tracks_with_cover"`
+	Variants        int    `json:"variants"`
+}
+
+type AlbumReadFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// AlbumView is the merged view of one album folder returned by ReadAlbum.
+// Album-level tags hold the value most tracks agree on; disagreements are
+// listed in Inconsistencies.
+type AlbumView struct {
+	Directory       string               `json:"directory"`
+	Album           string               `json:"album"`
+	AlbumArtist     string               `json:"album_artist"`
+	Date            string               `json:"date"`
+	Genre           string               `json:"genre"`
+	Tracks          []AlbumTrack         `json:"tracks"`
+	Failed          []AlbumReadFailure   `json:"failed"`
+	TotalDuration   int                  `json:"total_duration"`
+	TotalSize       int64                `json:"total_size"`
+	Cover           *AlbumCoverInfo      `json:"cover,omitempty"`
+	Inconsistencies []AlbumInconsistency `json:"inconsistencies"`
+}
+
+// albumTagValues counts the distinct values of one album-level tag.
+type albumTagValues struct {
+	field  string
+	counts map[string]int
+	order  []string
+}
+
+func (v *albumTagValues) add(value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	if v.counts[value] == 0 {
+		v.order = append(v.order, value)
+	}
+	v.counts[value]++
+}
+
+// majority returns the most common value, the first seen on ties.
+func (v *albumTagValues) majority() string {
+	best := ""
+	for _, value := range v.order {
+		if v.counts[value] > v.counts[best] {
+			best = value
+		}
+	}
+	return best
+}
+
+func (v *albumTagValues) inconsistency() *AlbumInconsistency {
+	if len(v.order) < 2 {
+		return nil
+	}
+	return &AlbumInconsistency{
+		Kind:    AlbumMixedTag,
+		Field:   v.field,
+		Values:  append([]string(nil), v.order...),
+		Message: fmt.Sprintf("tracks disagree on %s", v.field),
+	}
+}
+
+// readAlbumView builds the view of the audio files in dirPath and its
+// subfolders, so multi-disc albums split into CD1/CD2 folders read as one.
+func readAlbumView(dirPath string) (*AlbumView, error) {
+	files, err := collectLibraryAudioFiles(dirPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &AlbumView{
+		Directory:       dirPath,
+		Tracks:          []AlbumTrack{},
+		Failed:          []AlbumReadFailure{},
+		Inconsistencies: []AlbumInconsistency{},
+	}
+	tags := []*albumTagValues{
+		{field: "album", counts: map[string]int{}},
+		{field: "album_artist", counts: map[string]int{}},
+		{field: "date", counts: map[string]int{}},
+		{field: "genre", counts: map[string]int{}},
+	}
+	var covers []albumCover
+	scanTime := time.Now().UTC().Format(time.RFC3339)
+
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file.path), ".cue") {
+			continue
+		}
+		scanned, err := scanAudioFileWithKnownModTime(file.path, scanTime, file.modTime)
+		if err == nil && scanned.MetadataFromFilename {
+			err = fmt.Errorf("no readable tags")
+		}
+		if err != nil {
+			view.Failed = append(view.Failed, AlbumReadFailure{Path: file.path, Error: err.Error()})
+			continue
+		}
+
+		track := AlbumTrack{
+			Path:        file.path,
+			DiscNumber:  scanned.DiscNumber,
+			TrackNumber: scanned.TrackNumber,
+			Title:       scanned.TrackName,
+			Artist:      scanned.ArtistName,
+			Duration:    scanned.Duration,
+			Format:      scanned.Format,
+			BitDepth:    scanned.BitDepth,
+			SampleRate:  scanned.SampleRate,
+			Bitrate:     scanned.Bitrate,
+		}
+		if info, err := os.Stat(file.path); err == nil {
+			track.Size = info.Size()
+		}
+		if data, _, err := extractAnyCoverArt(file.path); err == nil && len(data) > 0 {
+			track.HasCover = true
+			sum := sha256.Sum256(data)
+			covers = append(covers, albumCover{track: file.path, data: data, hash: hex.EncodeToString(sum[:])})
+		}
+		tags[0].add(scanned.AlbumName)
+		tags[1].add(scanned.AlbumArtist)
+		tags[2].add(scanned.ReleaseDate)
+		tags[3].add(scanned.Genre)
+
+		view.Tracks = append(view.Tracks, track)
+		view.TotalDuration += track.Duration
+		view.TotalSize += track.Size
+	}
+
+	slices.SortStableFunc(view.Tracks, func(a, b AlbumTrack) int {
+		return compareTrackRefs(a.ref(), b.ref())
+	})
+
+	view.Album, view.AlbumArtist = tags[0].majority(), tags[1].majority()
+	view.Date, view.Genre = tags[2].majority(), tags[3].majority()
+	for _, tag := range tags {
+		if inconsistency := tag.inconsistency(); inconsistency != nil {
+			view.Inconsistencies = append(view.Inconsistencies, *inconsistency)
+		}
+	}
+	view.addCover(covers)
+	view.checkTrackNumbers()
+	view.checkQuality()
+	return view, nil
+}
+
+func (v *AlbumView) addCover(covers []albumCover) {
+	if len(covers) < len(v.Tracks) {
+		var missing []string
+		for _, track := range v.Tracks {
+			if !track.HasCover {
+				missing = append(missing, track.Path)
+			}
+		}
+		v.Inconsistencies = append(v.Inconsistencies, AlbumInconsistency{
+			Kind:    AlbumMissingCover,
+			Tracks:  missing,
+			Message: fmt.Sprintf("%d of %d tracks have no cover", len(missing), len(v.Tracks)),
+		})
+	}
+	if len(covers) == 0 {
+		return
+	}
+
+	variants := groupAlbumCovers(covers)
+	chosen := chooseAlbumCover(variants, AlbumArtMajority)
+	v.Cover = &AlbumCoverInfo{
+		Hash:            chosen.Hash,
+		Size:            chosen.Size,
+		Width:           chosen.Width,
+		Height:          chosen.Height,
+		TracksWithCover: len(covers),
+		Variants:        len(variants),
+	}
+	if len(variants) > 1 {
+		var outliers []string
+		for _, cover := range covers {
+			if cover.hash != chosen.Hash {
+				outliers = append(outliers, cover.track)
+			}
+		}
+		v.Inconsistencies = append(v.Inconsistencies, AlbumInconsistency{
+			Kind:    AlbumMixedCovers,
+			Tracks:  outliers,
+			Message: fmt.Sprintf("%d distinct covers embedded", len(variants)),
+		})
+	}
+}
+
+func (v *AlbumView) checkTrackNumbers() {
+	var unnumbered []string
+	seen := make(map[[2]int]string)
+	maxTrack := make(map[int]int)
+	present := make(map[int]map[int]bool)
+	for _, track := range v.Tracks {
+		if track.TrackNumber == 0 {
+			unnumbered = append(unnumbered, track.Path)
+			continue
+		}
+		key := [2]int{track.DiscNumber, track.TrackNumber}
+		if first, dup := seen[key]; dup {
+			v.Inconsistencies = append(v.Inconsistencies, AlbumInconsistency{
+				Kind:    AlbumDuplicateTrackNumber,
+				Tracks:  []string{first, track.Path},
+				Message: fmt.Sprintf("disc %d track %d appears twice", track.DiscNumber, track.TrackNumber),
+			})
+			continue
+		}
+		seen[key] = track.Path
+		maxTrack[track.DiscNumber] = max(maxTrack[track.DiscNumber], track.TrackNumber)
+		if present[track.DiscNumber] == nil {
+			present[track.DiscNumber] = make(map[int]bool)
+		}
+		present[track.DiscNumber][track.TrackNumber] = true
+	}
+	if len(unnumbered) > 0 {
+		v.Inconsistencies = append(v.Inconsistencies, AlbumInconsistency{
+			Kind:    AlbumMissingTrackNumber,
+			Tracks:  unnumbered,
+			Message: fmt.Sprintf("%d tracks have no track number", len(unnumbered)),
+		})
+	}
+
+	discs := make([]int, 0, len(maxTrack))
+	for disc := range maxTrack {
+		discs = append(discs, disc)
+	}
+	sort.Ints(discs)
+	for _, disc := range discs {
+		var missing []string
+		for n := 1; n <= maxTrack[disc]; n++ {
+			if !present[disc][n] {
+				missing = append(missing, fmt.Sprint(n))
+			}
+		}
+		if len(missing) > 0 {
+			v.Inconsistencies = append(v.Inconsistencies, AlbumInconsistency{
+				Kind:    AlbumTrackGap,
+				Values:  missing,
+				Message: fmt.Sprintf("disc %d is missing tracks %s", disc, strings.Join(missing, ", ")),
+			})
+		}
+	}
+}
+
+func (v *AlbumView) checkQuality() {
+	var qualities []string
+	seen := make(map[string]bool)
+	for _, track := range v.Tracks {
+		quality := track.Format
+		if track.BitDepth > 0 || track.SampleRate > 0 {
+			quality = fmt.Sprintf("%s %d-bit/%d Hz", track.Format, track.BitDepth, track.SampleRate)
+		}
+		if !seen[quality] {
+			seen[quality] = true
+			qualities = append(qualities, quality)
+		}
+	}
+	if len(qualities) > 1 {
+		v.Inconsistencies = append(v.Inconsistencies, AlbumInconsistency{
+			Kind:    AlbumMixedQuality,
+			Values:  qualities,
+			Message: "tracks differ in format or quality",
+		})
+	}
+}
+
+// ReadAlbum returns the merged view of the album in dirPath: the common
+// album tags, the tracks ordered by disc and track number with their
+// duration and quality, totals, cover info and any inconsistencies. Files
+// whose tags cannot be read are listed under Failed instead of Tracks.
+func ReadAlbum(dirPath string) (string, error) {
+	if strings.TrimSpace(dirPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(dirPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", dirPath)
+	}
+
+	view, err := readAlbumView(dirPath)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(view)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
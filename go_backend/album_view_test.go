@@ -0,0 +1,78 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func albumInconsistencyKinds(view AlbumView) map[string]AlbumInconsistency {
+	kinds := make(map[string]AlbumInconsistency)
+	for _, inconsistency := range view.Inconsistencies {
+		kinds[inconsistency.Kind] = inconsistency
+	}
+	return kinds
+}
+
+func TestReadAlbumMergesTracks(t *testing.T) {
+	root := t.TempDir()
+	album := Metadata{Album: "Album", AlbumArtist: "Band", Genre: "Rock", Date: "2020"}
+	track := func(n int, title, date string) Metadata {
+		m := album
+		m.TrackNumber, m.DiscNumber, m.Title = n, 1, title
+		if date != "" {
+			m.Date = date
+		}
+		return m
+	}
+	writeConsistencyFixture(t, root, "b.flac", track(2, "Second", ""))
+	first := writeConsistencyFixture(t, root, "a.flac", track(1, "First", ""))
+	writeConsistencyFixture(t, root, "d.flac", track(4, "Fourth", "2021"))
+	if err := os.WriteFile(filepath.Join(root, "broken.flac"), []byte("not audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	if err := EmbedMetadataWithCoverData(first, Metadata{}, cover); err != nil {
+		t.Fatalf("EmbedMetadataWithCoverData: %v", err)
+	}
+
+	raw, err := ReadAlbum(root)
+	view := mustDecodeJSON[AlbumView](t, raw, err)
+
+	if view.Album != "Album" || view.AlbumArtist != "Band" || view.Date != "2020" || view.Genre != "Rock" {
+		t.Fatalf("unexpected album tags: %+v", view)
+	}
+	if len(view.Tracks) != 3 || view.Tracks[0].Title != "First" || view.Tracks[1].Title != "Second" || view.Tracks[2].Title != "Fourth" {
+		t.Fatalf("tracks not ordered: %+v", view.Tracks)
+	}
+	if len(view.Failed) != 1 || filepath.Base(view.Failed[0].Path) != "broken.flac" {
+		t.Fatalf("unexpected failures: %+v", view.Failed)
+	}
+	if view.TotalSize == 0 || view.Tracks[0].SampleRate == 0 || view.Cover == nil || view.Cover.TracksWithCover != 1 {
+		t.Fatalf("unexpected totals or cover: %+v", view)
+	}
+
+	kinds := albumInconsistencyKinds(view)
+	if date := kinds[AlbumMixedTag]; date.Field != "date" || len(date.Values) != 2 {
+		t.Fatalf("expected mixed date: %+v", view.Inconsistencies)
+	}
+	if gap := kinds[AlbumTrackGap]; len(gap.Values) != 1 || gap.Values[0] != "3" {
+		t.Fatalf("expected gap at track 3: %+v", view.Inconsistencies)
+	}
+	if missing := kinds[AlbumMissingCover]; len(missing.Tracks) != 2 {
+		t.Fatalf("expected two tracks without cover: %+v", view.Inconsistencies)
+	}
+	if _, ok := kinds[AlbumMixedQuality]; ok {
+		t.Fatalf("same-quality tracks flagged: %+v", view.Inconsistencies)
+	}
+}
+
+func TestReadAlbumRejectsFiles(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	if _, err := ReadAlbum(path); err == nil {
+		t.Fatal("expected error for a file path")
+	}
+}
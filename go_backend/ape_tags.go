@@ -181,6 +181,10 @@ func parseAPEItems(data []byte, count int) ([]APETagItem, error) {
 // If the file already has APEv2 tags, they are replaced.
 // The tag is written with both header and footer.
 func WriteAPETags(filePath string, tag *APETag) error {
+	if err := checkWriteAllowed(filePath); err != nil {
+		return err
+	}
+	defer invalidateMetadataCache(filePath)
 	existingSize, err := findExistingAPETagSize(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to check existing APE tag: %w", err)
@@ -0,0 +1,212 @@
+package gobackend
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Featuring modes for EmbedOptions.NormalizeFeaturing.
+const (
+	// FeaturingKeep keeps featured artists in ARTIST ("A feat. B") and
+	// strips any "(feat. B)" from TITLE so they are not listed twice.
+	FeaturingKeep = "keep"
+	// FeaturingTitle puts only the primary artists in ARTIST and appends
+	// "(feat. B)" to TITLE.
+	FeaturingTitle = "title"
+	// FeaturingTag puts only the primary artists in ARTIST, strips TITLE
+	// and lists featured artists in featuredArtistTag.
+	FeaturingTag = "tag"
+)
+
+const featuredArtistTag = "FEATURED_ARTIST"
+
+var (
+	// artistFeaturingPattern finds where the featured artists start in an
+	// artist string: "A feat. B", "A ft B", "A featuring B", "A (with B)".
+	// "with" only counts inside brackets; bare, it is part of names such as
+	// "Dance With the Dead".
+	artistFeaturingPattern = regexp.MustCompile(`(?i)\s*(?:[\(\[]\s*\b(?:feat\.?|ft\.?|featuring|with)|\b(?:feat\.?|ft\.?|featuring))\s+`)
+	// titleFeaturingPattern matches a featuring credit in a title, either
+	// bracketed or trailing: "Song (feat. B)", "Song feat. B".
+	titleFeaturingPattern = regexp.MustCompile(`(?i)\s*(?:[\(\[]\s*(?:feat\.?|ft\.?|featuring)\s+([^\)\]]*)[\)\]]|\s(?:feat\.?|ft\.?|featuring)\s+(.*)$)`)
+	// artistSeparatorPattern splits collaborators: "A, B", "A & B",
+	// "A x B", "A / B", "A; B", "A vs. B". The "x" must be lower case and
+	// spaced, so "Malcolm X" and "Brand X Music" stay whole.
+	artistSeparatorPattern = regexp.MustCompile(`(?i)\s*(?:,|;|&|\s/\s|(?-i:\sx\s)|\bvs\.?\s)\s*`)
+)
+
+// protectedArtistNames contain separators but are single acts. They are
+// matched case-insensitively before splitting.
+var protectedArtistNames = []string{
+	"Simon & Garfunkel",
+	"Earth, Wind & Fire",
+	"Hall & Oates",
+	"Crosby, Stills, Nash & Young",
+	"Crosby, Stills & Nash",
+	"Tyler, the Creator",
+	"Mumford & Sons",
+	"Florence + the Machine",
+	"Of Monsters and Men",
+	"Emerson, Lake & Palmer",
+	"Peter, Bjorn and John",
+	"Chase & Status",
+	"Above & Beyond",
+	"Belle & Sebastian",
+	"Kool & the Gang",
+}
+
+// protectedArtistPatterns match protectedArtistNames case-insensitively in
+// the original string, so offsets stay valid when lowercasing would change
+// the byte length of other characters.
+var protectedArtistPatterns = func() []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(protectedArtistNames))
+	for i, name := range protectedArtistNames {
+		patterns[i] = regexp.MustCompile(`(?i)` + regexp.QuoteMeta(name))
+	}
+	return patterns
+}()
+
+// splitArtistList splits a collaborator list, keeping protected names whole.
+func splitArtistList(raw string) []string {
+	var placeholders []string
+	for _, pattern := range protectedArtistPatterns {
+		raw = pattern.ReplaceAllStringFunc(raw, func(match string) string {
+			placeholders = append(placeholders, match)
+			return fmt.Sprintf("\x00%d\x00", len(placeholders)-1)
+		})
+	}
+
+	parts := artistSeparatorPattern.Split(raw, -1)
+	artists := make([]string, 0, len(parts))
+	for _, part := range parts {
+		for i, original := range placeholders {
+			part = strings.ReplaceAll(part, fmt.Sprintf("\x00%d\x00", i), original)
+		}
+		part = strings.Trim(strings.TrimSpace(part), "()[]")
+		if part = strings.TrimSpace(part); part != "" {
+			artists = append(artists, part)
+		}
+	}
+	return artists
+}
+
+func containsArtist(artists []string, artist string) bool {
+	for _, existing := range artists {
+		if strings.EqualFold(existing, artist) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUniqueArtists(dst []string, artists ...string) []string {
+	for _, artist := range artists {
+		if !containsArtist(dst, artist) {
+			dst = append(dst, artist)
+		}
+	}
+	return dst
+}
+
+// splitFeaturedArtists separates the primary artists of an artist string
+// from the featured ones.
+func splitFeaturedArtists(raw string) (primary, featured []string) {
+	raw = strings.TrimSpace(raw)
+	if loc := artistFeaturingPattern.FindStringIndex(raw); loc != nil && loc[0] > 0 {
+		featured = appendUniqueArtists(nil, splitArtistList(raw[loc[1]:])...)
+		raw = raw[:loc[0]]
+	}
+	return appendUniqueArtists(nil, splitArtistList(raw)...), featured
+}
+
+// ParseArtists splits a display artist string into individual artists,
+// primary artists first and featured artists after them. It understands
+// ",", ";", "&", " x ", " / " and "vs." separators, feat./ft./featuring
+// credits and bracketed "(with B)" ones, keeps well-known names such as "Simon & Garfunkel" whole and
+// drops case-insensitive duplicates.
+func ParseArtists(raw string) []string {
+	primary, featured := splitFeaturedArtists(raw)
+	artists := appendUniqueArtists(primary, featured...)
+	if len(artists) == 0 {
+		return nil
+	}
+	return artists
+}
+
+// joinArtistNames formats artists as "A", "A & B" or "A, B & C". The result
+// parses back to the same list.
+func joinArtistNames(artists []string) string {
+	switch len(artists) {
+	case 0:
+		return ""
+	case 1:
+		return artists[0]
+	}
+	return strings.Join(artists[:len(artists)-1], ", ") + " & " + artists[len(artists)-1]
+}
+
+// stripTitleFeaturing removes a featuring credit from title and returns the
+// artists it named.
+func stripTitleFeaturing(title string) (string, []string) {
+	var featured []string
+	for _, match := range titleFeaturingPattern.FindAllStringSubmatch(title, -1) {
+		featured = appendUniqueArtists(featured, splitArtistList(match[1]+match[2])...)
+	}
+	return strings.TrimSpace(titleFeaturingPattern.ReplaceAllString(title, "")), featured
+}
+
+func validateFeaturingMode(mode string) error {
+	switch mode {
+	case "", FeaturingKeep, FeaturingTitle, FeaturingTag:
+		return nil
+	}
+	return fmt.Errorf("unknown featuring mode: %q", mode)
+}
+
+// normalizeFeaturing rewrites Artist and Title for mode and returns every
+// artist (for ARTISTS) and the featured ones. Featured artists are gathered
+// from the artist string, the title and tagged (a previous featuredArtistTag),
+// so running it on its own output changes nothing. Metadata without an artist
+// is returned unchanged.
+func normalizeFeaturing(metadata Metadata, mode string, tagged []string) (Metadata, []string, []string) {
+	if mode == "" || strings.TrimSpace(metadata.Artist) == "" {
+		return metadata, nil, nil
+	}
+
+	primary, featured := splitFeaturedArtists(metadata.Artist)
+	title, titleFeatured := stripTitleFeaturing(metadata.Title)
+	featured = appendUniqueArtists(featured, titleFeatured...)
+	featured = appendUniqueArtists(featured, tagged...)
+	// An artist credited as both primary and featured stays primary.
+	var kept []string
+	for _, artist := range featured {
+		if !containsArtist(primary, artist) {
+			kept = append(kept, artist)
+		}
+	}
+	featured = kept
+
+	metadata.Artist = joinArtistNames(primary)
+	switch mode {
+	case FeaturingKeep:
+		if len(featured) > 0 {
+			metadata.Artist += " feat. " + joinArtistNames(featured)
+		}
+		if metadata.Title != "" {
+			metadata.Title = title
+		}
+	case FeaturingTitle:
+		if metadata.Title != "" {
+			metadata.Title = title
+			if len(featured) > 0 {
+				metadata.Title += " (feat. " + joinArtistNames(featured) + ")"
+			}
+		}
+	case FeaturingTag:
+		if metadata.Title != "" {
+			metadata.Title = title
+		}
+	}
+	return metadata, appendUniqueArtists(append([]string(nil), primary...), featured...), featured
+}
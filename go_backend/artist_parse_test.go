@@ -0,0 +1,111 @@
+package gobackend
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArtists(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{"Artist A feat. Artist B", []string{"Artist A", "Artist B"}},
+		{"A & B", []string{"A", "B"}},
+		{"A, B", []string{"A", "B"}},
+		{"A, B & C ft. D", []string{"A", "B", "C", "D"}},
+		{"A x B featuring C & D", []string{"A", "B", "C", "D"}},
+		{"A (feat. B)", []string{"A", "B"}},
+		{"A vs. B", []string{"A", "B"}},
+		{"A / B; C", []string{"A", "B", "C"}},
+		{"Simon & Garfunkel", []string{"Simon & Garfunkel"}},
+		{"Earth, Wind & Fire feat. The Emotions", []string{"Earth, Wind & Fire", "The Emotions"}},
+		{"Tyler, The Creator & Kali Uchis", []string{"Tyler, The Creator", "Kali Uchis"}},
+		{"AC/DC", []string{"AC/DC"}},
+		{"Xzibit", []string{"Xzibit"}},
+		{"Malcolm X", []string{"Malcolm X"}},
+		{"X Ambassadors", []string{"X Ambassadors"}},
+		{"Brand X Music", []string{"Brand X Music"}},
+		{"Lil Nas X x Billy Ray Cyrus", []string{"Lil Nas X", "Billy Ray Cyrus"}},
+		{"Dance With the Dead", []string{"Dance With the Dead"}},
+		{"Dance With the Dead feat. B", []string{"Dance With the Dead", "B"}},
+		{"A (with B)", []string{"A", "B"}},
+		{"A [With B & C]", []string{"A", "B", "C"}},
+		{"A & a", []string{"A"}},
+		{"ȺȺ Simon & Garfunkel", []string{"ȺȺ Simon & Garfunkel"}},
+		{"İ, Simon & Garfunkel", []string{"İ", "Simon & Garfunkel"}},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseArtists(tt.raw); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("ParseArtists(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeFeaturingIsStable(t *testing.T) {
+	tests := []struct {
+		mode       string
+		in         Metadata
+		wantArtist string
+		wantTitle  string
+	}{
+		{FeaturingTitle, Metadata{Artist: "A feat. B", Title: "Song"}, "A", "Song (feat. B)"},
+		{FeaturingTitle, Metadata{Artist: "A & C ft. B", Title: "Song [feat. D]"}, "A & C", "Song (feat. B & D)"},
+		{FeaturingKeep, Metadata{Artist: "A", Title: "Song (feat. B)"}, "A feat. B", "Song"},
+		{FeaturingKeep, Metadata{Artist: "A featuring B, C", Title: "Song"}, "A feat. B & C", "Song"},
+		{FeaturingTag, Metadata{Artist: "A feat. B", Title: "Song feat. B"}, "A", "Song"},
+		{FeaturingTitle, Metadata{Artist: "A feat. A", Title: "Song"}, "A", "Song"},
+		{FeaturingTitle, Metadata{Artist: "Dance With the Dead", Title: "Song"}, "Dance With the Dead", "Song"},
+		{FeaturingTitle, Metadata{Artist: "Brand X Music", Title: "Song"}, "Brand X Music", "Song"},
+	}
+	for _, tt := range tests {
+		once, artists, featured := normalizeFeaturing(tt.in, tt.mode, nil)
+		if once.Artist != tt.wantArtist || once.Title != tt.wantTitle {
+			t.Fatalf("%s %+v: got artist %q title %q", tt.mode, tt.in, once.Artist, once.Title)
+		}
+		var tagged []string
+		if tt.mode == FeaturingTag {
+			tagged = featured
+		}
+		twice, artistsAgain, _ := normalizeFeaturing(once, tt.mode, tagged)
+		if !reflect.DeepEqual(twice, once) || !reflect.DeepEqual(artists, artistsAgain) {
+			t.Fatalf("%s: second pass changed %+v -> %+v", tt.mode, once, twice)
+		}
+	}
+}
+
+func TestEmbedAllNormalizesFeaturing(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	opts := EmbedOptions{NormalizeFeaturing: FeaturingTitle}
+	for i := 0; i < 2; i++ {
+		if err := EmbedAll(path, Metadata{Artist: "A & C feat. B"}, Lyrics{}, nil, opts); err != nil {
+			t.Fatalf("EmbedAll: %v", err)
+		}
+	}
+	tags, err := GetTags(path, []string{"ARTIST", "ARTISTS", "TITLE"})
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if !reflect.DeepEqual(tags["ARTIST"], []string{"A & C"}) || !reflect.DeepEqual(tags["TITLE"], []string{"Song (feat. B)"}) ||
+		!reflect.DeepEqual(tags["ARTISTS"], []string{"A", "C", "B"}) {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+
+	opts.NormalizeFeaturing = FeaturingTag
+	if err := EmbedAll(path, Metadata{Artist: "A & C"}, Lyrics{}, nil, opts); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	tags, err = GetTags(path, []string{"ARTIST", "TITLE", featuredArtistTag})
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if tags["TITLE"][0] != "Song" || !reflect.DeepEqual(tags[featuredArtistTag], []string{"B"}) {
+		t.Fatalf("featured artist should move to %s: %v", featuredArtistTag, tags)
+	}
+
+	opts.NormalizeFeaturing = "subtitle"
+	if err := EmbedAll(path, Metadata{Artist: "A"}, Lyrics{}, nil, opts); err == nil {
+		t.Fatal("expected unknown featuring mode error")
+	}
+}
@@ -0,0 +1,175 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Audio problems reported by AnalyzeAudio.
+const (
+	AudioIssueLeadingSilence  = "leading_silence"
+	AudioIssueTrailingSilence = "trailing_silence"
+	AudioIssueClipping        = "clipping"
+)
+
+const (
+	// audioSilenceIssueMs is how much silence at either end is reported as
+	// a problem; album tracks commonly carry a second or two.
+	audioSilenceIssueMs = 5000
+	// audioClipRunLength consecutive full-scale samples on a channel count
+	// as clipping; single full-scale peaks are legitimate.
+	audioClipRunLength = 3
+	// audioClippingIssuePercent of clipped samples is reported as a problem.
+	audioClippingIssuePercent = 0.01
+	// audioSilentPeakDBFS stands in for the peak level of digital silence,
+	// which has none.
+	audioSilentPeakDBFS = -144
+)
+
+// AudioAnalysis is what AnalyzeAudio measures in a decoded file.
+type AudioAnalysis struct {
+	Path                 string   `json:"path"`
+	DurationMs           int64    `json:"duration_ms"`
+	LeadingSilenceMs     int64    `json:"leading_silence_ms"`
+	TrailingSilenceMs    int64    `json:"trailing_silence_ms"`
+	SilenceThresholdDBFS float64  `json:"silence_threshold_dbfs"`
+	ClippedSamples       int64    `json:"clipped_samples"`
+	ClippedPercent       float64  `json:"clipped_percent"`
+	Peak                 float64  `json:"peak"`
+	PeakDBFS             float64  `json:"peak_dbfs"`
+	Issues               []string `json:"issues"`
+}
+
+// analyzeAudio decodes filePath once. A sample frame is audible when any
+// channel exceeds thresholdDBFS; the silence at either end is the run of
+// frames that are not. Clipping counts the samples of runs of at least
+// audioClipRunLength full-scale samples on a channel.
+func analyzeAudio(filePath string, thresholdDBFS float64) (*AudioAnalysis, error) {
+	decoder, f, err := openFLACDecoder(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	format := decoder.format
+	if format.BitsPerSample <= 0 || format.BitsPerSample > 32 || format.SampleRate <= 0 {
+		return nil, fmt.Errorf("unsupported format: %d bits at %d Hz", format.BitsPerSample, format.SampleRate)
+	}
+	fullScale := int64(1) << (format.BitsPerSample - 1)
+	threshold := int64(math.Pow(10, thresholdDBFS/20) * float64(fullScale))
+
+	var (
+		pos, firstLoud, lastLoud int64 = 0, -1, -1
+		peak, clipped, total     int64
+		runs                     []int
+	)
+	for {
+		samples, err := decoder.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(runs) != len(samples) {
+			runs = make([]int, len(samples))
+		}
+		for i := range samples[0] {
+			loud := false
+			for ch := range samples {
+				s := int64(samples[ch][i])
+				a := max(s, -s)
+				peak = max(peak, a)
+				if a > threshold {
+					loud = true
+				}
+				if s >= fullScale-1 || s <= -fullScale {
+					runs[ch]++
+					if runs[ch] == audioClipRunLength {
+						clipped += audioClipRunLength
+					} else if runs[ch] > audioClipRunLength {
+						clipped++
+					}
+				} else {
+					runs[ch] = 0
+				}
+			}
+			if loud {
+				if firstLoud < 0 {
+					firstLoud = pos
+				}
+				lastLoud = pos
+			}
+			pos++
+		}
+		total += int64(len(samples[0]) * len(samples))
+	}
+
+	toMs := func(frames int64) int64 { return frames * 1000 / int64(format.SampleRate) }
+	analysis := &AudioAnalysis{
+		Path:                 filePath,
+		DurationMs:           toMs(pos),
+		LeadingSilenceMs:     toMs(pos),
+		SilenceThresholdDBFS: thresholdDBFS,
+		ClippedSamples:       clipped,
+		Peak:                 min(float64(peak)/float64(fullScale), 1),
+		PeakDBFS:             audioSilentPeakDBFS,
+		Issues:               []string{},
+	}
+	if firstLoud >= 0 {
+		analysis.LeadingSilenceMs = toMs(firstLoud)
+		analysis.TrailingSilenceMs = toMs(pos - 1 - lastLoud)
+	}
+	if total > 0 {
+		analysis.ClippedPercent = float64(clipped) * 100 / float64(total)
+	}
+	if peak > 0 {
+		analysis.PeakDBFS = max(20*math.Log10(analysis.Peak), audioSilentPeakDBFS)
+	}
+
+	if analysis.LeadingSilenceMs > audioSilenceIssueMs {
+		analysis.Issues = append(analysis.Issues, AudioIssueLeadingSilence)
+	}
+	if analysis.TrailingSilenceMs > audioSilenceIssueMs {
+		analysis.Issues = append(analysis.Issues, AudioIssueTrailingSilence)
+	}
+	if analysis.ClippedPercent > audioClippingIssuePercent {
+		analysis.Issues = append(analysis.Issues, AudioIssueClipping)
+	}
+	return analysis, nil
+}
+
+func analyzeAudioFile(filePath string) (*AudioAnalysis, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, false, func(localPath string) (*AudioAnalysis, error) {
+			analysis, err := analyzeAudioFile(localPath)
+			if analysis != nil {
+				analysis.Path = filePath
+			}
+			return analysis, err
+		})
+	}
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return analyzeAudio(filePath, GetBackendConfig().SilenceThresholdDBFS)
+}
+
+// AnalyzeAudio decodes a FLAC file and reports the silence at its start and
+// end (below the configured SilenceThresholdDBFS), how many samples are
+// clipped and its peak level, with any of these that point to a bad rip or
+// a broken download listed as issues.
+func AnalyzeAudio(filePath string) (string, error) {
+	analysis, err := analyzeAudioFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(analysis)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,78 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeSilencePaddedFLAC writes a mono 16-bit file at 48 kHz: lead seconds
+// of silence, one second of tone with clipped full-scale runs, then trail
+// seconds of silence.
+func writeSilencePaddedFLAC(t *testing.T, dir string, lead, trail int) string {
+	t.Helper()
+	const rate = 48000
+	samples := make([]int32, (lead+1+trail)*rate)
+	tone := samples[lead*rate : (lead+1)*rate]
+	for i := range tone {
+		tone[i] = 1000
+		if i%2 == 0 {
+			tone[i] = -1000
+		}
+	}
+	for i := range 100 {
+		tone[100+i] = 32767
+	}
+	data, err := encodeVerbatimFLAC([][]int32{samples}, rate, 16, 4096)
+	if err != nil {
+		t.Fatalf("encodeVerbatimFLAC: %v", err)
+	}
+	path := filepath.Join(dir, "padded.flac")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
+
+func TestAnalyzeAudio(t *testing.T) {
+	path := writeSilencePaddedFLAC(t, t.TempDir(), 6, 1)
+	out, err := AnalyzeAudio(path)
+	analysis := mustDecodeJSON[AudioAnalysis](t, out, err)
+	if analysis.DurationMs != 8000 || analysis.LeadingSilenceMs != 6000 || analysis.TrailingSilenceMs != 1000 {
+		t.Fatalf("durations = %d, %d, %d", analysis.DurationMs, analysis.LeadingSilenceMs, analysis.TrailingSilenceMs)
+	}
+	if analysis.ClippedSamples != 100 || analysis.Peak < 0.999 || analysis.PeakDBFS > 0 || analysis.PeakDBFS < -0.01 {
+		t.Fatalf("clipping = %d, peak %v (%v dBFS)", analysis.ClippedSamples, analysis.Peak, analysis.PeakDBFS)
+	}
+	if !slices.Equal(analysis.Issues, []string{AudioIssueLeadingSilence, AudioIssueClipping}) {
+		t.Fatalf("issues = %v", analysis.Issues)
+	}
+
+	// Quiet material counts as audible once the threshold is lowered below it.
+	quiet, err := analyzeAudio(path, -20)
+	if err != nil {
+		t.Fatalf("analyzeAudio: %v", err)
+	}
+	if quiet.LeadingSilenceMs != 6002 {
+		t.Fatalf("leading silence at -20 dBFS = %d", quiet.LeadingSilenceMs)
+	}
+}
+
+func TestAnalyzeAudioSilentFile(t *testing.T) {
+	data, err := encodeVerbatimFLAC([][]int32{make([]int32, 4800)}, 48000, 16, 4096)
+	if err != nil {
+		t.Fatalf("encodeVerbatimFLAC: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "silent.flac")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	analysis, err := analyzeAudio(path, defaultSilenceThresholdDBFS)
+	if err != nil {
+		t.Fatalf("analyzeAudio: %v", err)
+	}
+	if analysis.LeadingSilenceMs != 100 || analysis.TrailingSilenceMs != 0 || analysis.PeakDBFS != audioSilentPeakDBFS {
+		t.Fatalf("analysis = %+v", analysis)
+	}
+}
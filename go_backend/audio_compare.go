@@ -0,0 +1,190 @@
+package gobackend
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Methods CompareAudio uses to decide whether two files hold the same audio.
+const (
+	// CompareMethodStreamInfo means the STREAMINFO stream parameters already
+	// differ, so no hash was needed.
+	CompareMethodStreamInfo = "streaminfo"
+	// CompareMethodMD5 compares the STREAMINFO MD5s of the decoded PCM; it
+	// also matches re-encodes at another compression level.
+	CompareMethodMD5 = "streaminfo_md5"
+	// CompareMethodAudioHash compares AudioHash, used when either file has no
+	// MD5; it only matches byte-identical encodes.
+	CompareMethodAudioHash = "audio_hash"
+)
+
+// AudioComparisonFile is one side of an AudioComparison. MD5 is empty when
+// the encoder left it unset.
+type AudioComparisonFile struct {
+	Path    string       `json:"path"`
+	MD5     string       `json:"md5,omitempty"`
+	Quality AudioQuality `json:"quality"`
+}
+
+// AudioComparison is the result of CompareAudio.
+type AudioComparison struct {
+	Identical bool                `json:"identical"`
+	Method    string              `json:"method"`
+	Details   string              `json:"details"`
+	A         AudioComparisonFile `json:"a"`
+	B         AudioComparisonFile `json:"b"`
+}
+
+// readAudioComparisonFile reads the STREAMINFO of a FLAC file.
+func readAudioComparisonFile(filePath string) (AudioComparisonFile, error) {
+	side := AudioComparisonFile{Path: filePath}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return side, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return side, err
+	}
+	layout, err := scanFLACMetadataBlocks(f, info.Size())
+	if err != nil {
+		return side, err
+	}
+	if len(layout.StreamInfo) < 34 {
+		return side, fmt.Errorf("missing STREAMINFO: %s", filePath)
+	}
+
+	bitDepth, sampleRate, totalSamples := parseFLACStreamInfoQuality(layout.StreamInfo)
+	side.Quality = AudioQuality{
+		BitDepth:     bitDepth,
+		SampleRate:   sampleRate,
+		TotalSamples: totalSamples,
+		Codec:        "FLAC",
+	}
+	if sampleRate > 0 {
+		side.Quality.Duration = int(totalSamples / int64(sampleRate))
+	}
+	if md5 := layout.StreamInfo[18:34]; !bytes.Equal(md5, make([]byte, 16)) {
+		side.MD5 = hex.EncodeToString(md5)
+	}
+	return side, nil
+}
+
+func compareAudioFiles(pathA, pathB string) (*AudioComparison, error) {
+	a, err := readAudioComparisonFile(pathA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := readAudioComparisonFile(pathB)
+	if err != nil {
+		return nil, err
+	}
+	result := &AudioComparison{A: a, B: b}
+
+	qa, qb := a.Quality, b.Quality
+	switch {
+	case qa.SampleRate != qb.SampleRate || qa.BitDepth != qb.BitDepth:
+		result.Method = CompareMethodStreamInfo
+		result.Details = fmt.Sprintf("formats differ: %d-bit/%d Hz vs %d-bit/%d Hz", qa.BitDepth, qa.SampleRate, qb.BitDepth, qb.SampleRate)
+	case qa.TotalSamples != qb.TotalSamples:
+		result.Method = CompareMethodStreamInfo
+		result.Details = fmt.Sprintf("sample counts differ: %d vs %d", qa.TotalSamples, qb.TotalSamples)
+	case a.MD5 != "" && b.MD5 != "":
+		result.Method = CompareMethodMD5
+		result.Identical = a.MD5 == b.MD5
+		if result.Identical {
+			result.Details = "decoded audio MD5 matches"
+		} else {
+			result.Details = "decoded audio MD5 differs"
+		}
+	default:
+		hashA, err := AudioHash(pathA)
+		if err != nil {
+			return nil, err
+		}
+		hashB, err := AudioHash(pathB)
+		if err != nil {
+			return nil, err
+		}
+		result.Method = CompareMethodAudioHash
+		result.Identical = hashA == hashB
+		if result.Identical {
+			result.Details = "audio frames are byte-identical"
+		} else {
+			result.Details = "audio frames differ; a STREAMINFO MD5 is missing, so re-encodes of the same audio also differ"
+		}
+	}
+	return result, nil
+}
+
+// CompareAudio reports whether two FLAC files carry the same audio, whatever
+// their tags, so a duplicate can be deleted with certainty. It compares the
+// STREAMINFO MD5s when both are set and falls back to AudioHash otherwise.
+func CompareAudio(pathA, pathB string) (string, error) {
+	result, err := compareAudioFiles(pathA, pathB)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// DuplicateGroupComparison annotates one group of suspected duplicates. Each
+// other file is compared against Reference, the group's first file.
+type DuplicateGroupComparison struct {
+	Reference    string            `json:"reference"`
+	Comparisons  []AudioComparison `json:"comparisons"`
+	Errors       map[string]string `json:"errors,omitempty"`
+	AllIdentical bool              `json:"all_identical"`
+}
+
+// CompareDuplicateGroups runs CompareAudio over groupsJSON, a JSON array of
+// path arrays such as the groups of a duplicate search, and reports per group
+// whether every copy matches the first. A file that cannot be read is listed
+// under Errors and keeps its group from being AllIdentical.
+func CompareDuplicateGroups(groupsJSON string) (string, error) {
+	var groups [][]string
+	if err := json.Unmarshal([]byte(groupsJSON), &groups); err != nil {
+		return "", fmt.Errorf("invalid duplicate groups: %w", err)
+	}
+
+	results := make([]DuplicateGroupComparison, 0, len(groups))
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		entry := DuplicateGroupComparison{
+			Reference:    group[0],
+			Comparisons:  []AudioComparison{},
+			AllIdentical: len(group) > 1,
+		}
+		for _, other := range group[1:] {
+			comparison, err := compareAudioFiles(group[0], other)
+			if err != nil {
+				if entry.Errors == nil {
+					entry.Errors = make(map[string]string)
+				}
+				entry.Errors[other] = err.Error()
+				entry.AllIdentical = false
+				continue
+			}
+			entry.Comparisons = append(entry.Comparisons, *comparison)
+			entry.AllIdentical = entry.AllIdentical && comparison.Identical
+		}
+		results = append(results, entry)
+	}
+
+	jsonBytes, err := json.Marshal(results)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
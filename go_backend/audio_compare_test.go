@@ -0,0 +1,69 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// zeroStreamInfoMD5 clears the STREAMINFO MD5 of a verify fixture, which has
+// STREAMINFO as its first block.
+func zeroStreamInfoMD5(data []byte) []byte {
+	clear(data[8+18 : 8+34])
+	return data
+}
+
+func TestCompareAudioUsesMD5AcrossTags(t *testing.T) {
+	plain := writeVerifyFixture(t, nil)
+	tagged := writeTestFLACWithMetadata(t, Metadata{Title: "Song", Artist: "Artist"})
+
+	result, err := compareAudioFiles(plain, tagged)
+	if err != nil {
+		t.Fatalf("compareAudioFiles: %v", err)
+	}
+	if !result.Identical || result.Method != CompareMethodMD5 || result.A.MD5 == "" {
+		t.Fatalf("unexpected comparison: %+v", result)
+	}
+	if result.A.Quality.TotalSamples == 0 || result.A.Quality != result.B.Quality {
+		t.Fatalf("unexpected quality: %+v", result)
+	}
+}
+
+func TestCompareAudioFallsBackToAudioHash(t *testing.T) {
+	withMD5 := writeVerifyFixture(t, nil)
+	withoutMD5 := writeVerifyFixture(t, zeroStreamInfoMD5)
+
+	result, err := compareAudioFiles(withMD5, withoutMD5)
+	if err != nil {
+		t.Fatalf("compareAudioFiles: %v", err)
+	}
+	if !result.Identical || result.Method != CompareMethodAudioHash || result.B.MD5 != "" {
+		t.Fatalf("unexpected comparison: %+v", result)
+	}
+
+	changed := writeVerifyFixture(t, func(data []byte) []byte {
+		data[len(data)-10] ^= 0xFF
+		return zeroStreamInfoMD5(data)
+	})
+	result, err = compareAudioFiles(withMD5, changed)
+	if err != nil {
+		t.Fatalf("compareAudioFiles: %v", err)
+	}
+	if result.Identical || result.Method != CompareMethodAudioHash {
+		t.Fatalf("different audio compared identical: %+v", result)
+	}
+}
+
+func TestCompareDuplicateGroups(t *testing.T) {
+	a := writeVerifyFixture(t, nil)
+	b := writeTestFLACWithMetadata(t, Metadata{Title: "Copy"})
+	groupsJSON, _ := json.Marshal([][]string{{a, b}, {a, "/nonexistent.flac"}})
+
+	raw, err := CompareDuplicateGroups(string(groupsJSON))
+	groups := mustDecodeJSON[[]DuplicateGroupComparison](t, raw, err)
+	if len(groups) != 2 || !groups[0].AllIdentical || len(groups[0].Comparisons) != 1 {
+		t.Fatalf("unexpected first group: %s", raw)
+	}
+	if groups[1].AllIdentical || groups[1].Errors["/nonexistent.flac"] == "" {
+		t.Fatalf("unexpected second group: %s", raw)
+	}
+}
@@ -0,0 +1,79 @@
+package gobackend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// AudioHash returns a hex SHA-256 over the audio frames of a FLAC file,
+// i.e. every byte after the last metadata block. Tags, covers and padding
+// do not affect it, so two copies of the same rip hash the same however they
+// are tagged.
+//
+// This is not the STREAMINFO MD5: that one covers decoded PCM and so also
+// matches re-encodes at a different compression level, while AudioHash
+// matches only byte-identical encodes. In exchange it needs no decoding and
+// costs a single sequential read.
+func AudioHash(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	layout, err := scanFLACMetadataBlocks(f, info.Size())
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(layout.AudioOffset, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to read audio frames: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type AudioHashResult struct {
+	FilePath string `json:"file_path"`
+	Hash     string `json:"hash,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// AudioHashBatch hashes every path in filePathsJSON (a JSON array) and
+// returns per-file results in the same order. A failing file does not stop
+// the batch.
+func AudioHashBatch(filePathsJSON string) (string, error) {
+	var paths []string
+	if err := json.Unmarshal([]byte(filePathsJSON), &paths); err != nil {
+		return "", fmt.Errorf("invalid file list: %w", err)
+	}
+
+	results := make([]AudioHashResult, len(paths))
+	for i, path := range paths {
+		results[i].FilePath = path
+		hash, err := AudioHash(path)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Hash = hash
+	}
+
+	jsonBytes, err := json.Marshal(results)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,51 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAudioHashIgnoresTagsAndCover(t *testing.T) {
+	plain := writeVerifyFixture(t, nil)
+	tagged := writeTestFLACWithMetadata(t, Metadata{Title: "Song", Artist: "Artist", Lyrics: "la la"})
+
+	cover, _ := buildSelfTestCover()
+	if err := EmbedMetadataWithCoverData(tagged, Metadata{Album: "Album"}, cover); err != nil {
+		t.Fatalf("EmbedMetadataWithCoverData: %v", err)
+	}
+
+	plainHash, err := AudioHash(plain)
+	if err != nil {
+		t.Fatalf("AudioHash: %v", err)
+	}
+	taggedHash, err := AudioHash(tagged)
+	if err != nil {
+		t.Fatalf("AudioHash: %v", err)
+	}
+	if plainHash != taggedHash || len(plainHash) != 64 {
+		t.Fatalf("hashes differ: %s vs %s", plainHash, taggedHash)
+	}
+
+	changed := writeVerifyFixture(t, func(data []byte) []byte {
+		data[len(data)-10] ^= 0xFF
+		return data
+	})
+	if changedHash, _ := AudioHash(changed); changedHash == plainHash {
+		t.Fatal("different audio should hash differently")
+	}
+}
+
+func TestAudioHashBatchReportsPerFileErrors(t *testing.T) {
+	good := writeVerifyFixture(t, nil)
+	notFLAC := filepath.Join(t.TempDir(), "x.flac")
+	os.WriteFile(notFLAC, []byte("ID3 nope"), 0644)
+
+	pathsJSON, _ := json.Marshal([]string{good, notFLAC})
+	resultJSON, err := AudioHashBatch(string(pathsJSON))
+	results := mustDecodeJSON[[]AudioHashResult](t, resultJSON, err)
+	if len(results) != 2 || results[0].Hash == "" || results[1].Error == "" {
+		t.Fatalf("unexpected results: %s", resultJSON)
+	}
+}
@@ -35,6 +35,13 @@ type AudioMetadata struct {
 	ReplayGainTrackPeak string
 	ReplayGainAlbumGain string
 	ReplayGainAlbumPeak string
+	// Loudness comments of other taggers; see Metadata.
+	R128TrackGain               string
+	R128AlbumGain               string
+	ReplayGainReferenceLoudness string
+	// SyncedLyrics is LRC text from an ID3 SYLT frame (converted) or a
+	// SYNCEDLYRICS comment.
+	SyncedLyrics string
 }
 
 type MP3Quality struct {
@@ -313,11 +320,18 @@ func parseID3v23Frames(data []byte, metadata *AudioMetadata, version byte, tagUn
 			if v := extractLyricsFrame(frameData); v != "" && metadata.Lyrics == "" {
 				metadata.Lyrics = v
 			}
+		case "SYLT":
+			if v := extractSyncedLyricsFrame(frameData); v != "" && metadata.SyncedLyrics == "" {
+				metadata.SyncedLyrics = v
+			}
 		case "TXXX":
 			desc, userValue := extractUserTextFrame(frameData)
 			if isLyricsDescription(desc) && userValue != "" && metadata.Lyrics == "" {
 				metadata.Lyrics = userValue
 			}
+			if strings.EqualFold(strings.TrimSpace(desc), "SYNCEDLYRICS") && userValue != "" && metadata.SyncedLyrics == "" {
+				metadata.SyncedLyrics = userValue
+			}
 			upperDesc := strings.ToUpper(desc)
 			switch upperDesc {
 			case "REPLAYGAIN_TRACK_GAIN":
@@ -328,6 +342,12 @@ func parseID3v23Frames(data []byte, metadata *AudioMetadata, version byte, tagUn
 				metadata.ReplayGainAlbumGain = userValue
 			case "REPLAYGAIN_ALBUM_PEAK":
 				metadata.ReplayGainAlbumPeak = userValue
+			case replayGainReferenceLoudnessTag:
+				metadata.ReplayGainReferenceLoudness = userValue
+			case r128TrackGainTag:
+				metadata.R128TrackGain = userValue
+			case r128AlbumGainTag:
+				metadata.R128AlbumGain = userValue
 			}
 		}
 
@@ -461,6 +481,53 @@ func extractLyricsFrame(data []byte) string {
 	return extractTextFrame(framed)
 }
 
+// extractSyncedLyricsFrame converts a SYLT frame to LRC text. Only frames
+// timed in milliseconds are converted; MPEG frame timestamps would need the
+// audio to resolve and give "".
+func extractSyncedLyricsFrame(data []byte) string {
+	// encoding, language (3), timestamp format, content type
+	if len(data) < 6 || data[4] != 2 {
+		return ""
+	}
+	encoding := data[0]
+	rest := data[6:]
+	nextText := func() (string, bool) {
+		end, skip := -1, 1
+		if encoding == 1 || encoding == 2 {
+			skip = 2
+			for i := 0; i+1 < len(rest); i += 2 {
+				if rest[i] == 0 && rest[i+1] == 0 {
+					end = i
+					break
+				}
+			}
+		} else {
+			end = bytes.IndexByte(rest, 0)
+		}
+		if end < 0 {
+			return "", false
+		}
+		text := extractTextFrame(append([]byte{encoding}, rest[:end]...))
+		rest = rest[end+skip:]
+		return text, true
+	}
+
+	if _, ok := nextText(); !ok { // content descriptor
+		return ""
+	}
+	var lines []string
+	for {
+		text, ok := nextText()
+		if !ok || len(rest) < 4 {
+			break
+		}
+		ms := int64(binary.BigEndian.Uint32(rest[:4]))
+		rest = rest[4:]
+		lines = append(lines, msToLRCTimestamp(ms)+strings.Trim(text, "\r\n"))
+	}
+	return strings.Join(lines, "\n")
+}
+
 func extractUserTextFrame(data []byte) (string, string) {
 	if len(data) < 2 {
 		return "", ""
@@ -981,14 +1048,19 @@ func detectOggStreamType(packets [][]byte) oggStreamType {
 	return oggStreamUnknown
 }
 
+// vorbisCommentMaxBytes caps the comment packets parseVorbisComments reads.
+// It leaves room for a large embedded cover.
+const vorbisCommentMaxBytes = 64 << 20
+
 func parseVorbisComments(data []byte, metadata *AudioMetadata) {
-	if len(data) < 4 {
+	if len(data) < 4 || len(data) > vorbisCommentMaxBytes {
 		return
 	}
 
 	reader := bytes.NewReader(data)
 	artistValues := make([]string, 0, 1)
 	albumArtistValues := make([]string, 0, 1)
+	var trackTotalValues, discTotalValues []string
 
 	var vendorLen uint32
 	if err := binary.Read(reader, binary.LittleEndian, &vendorLen); err != nil {
@@ -1056,6 +1128,10 @@ func parseVorbisComments(data []byte, metadata *AudioMetadata) {
 			metadata.TrackNumber, metadata.TotalTracks = parseIndexPair(value)
 		case "DISCNUMBER", "DISC":
 			metadata.DiscNumber, metadata.TotalDiscs = parseIndexPair(value)
+		case "TOTALTRACKS", "TRACKTOTAL":
+			trackTotalValues = append(trackTotalValues, value)
+		case "TOTALDISCS", "DISCTOTAL":
+			discTotalValues = append(discTotalValues, value)
 		case "ISRC":
 			metadata.ISRC = value
 		case "COMPOSER":
@@ -1066,6 +1142,10 @@ func parseVorbisComments(data []byte, metadata *AudioMetadata) {
 			if metadata.Lyrics == "" {
 				metadata.Lyrics = value
 			}
+		case "SYNCEDLYRICS":
+			if metadata.SyncedLyrics == "" {
+				metadata.SyncedLyrics = value
+			}
 		case "ORGANIZATION", "LABEL", "PUBLISHER":
 			metadata.Label = value
 		case "COPYRIGHT":
@@ -1078,6 +1158,12 @@ func parseVorbisComments(data []byte, metadata *AudioMetadata) {
 			metadata.ReplayGainAlbumGain = value
 		case "REPLAYGAIN_ALBUM_PEAK":
 			metadata.ReplayGainAlbumPeak = value
+		case replayGainReferenceLoudnessTag:
+			metadata.ReplayGainReferenceLoudness = value
+		case r128TrackGainTag:
+			metadata.R128TrackGain = value
+		case r128AlbumGainTag:
+			metadata.R128AlbumGain = value
 		}
 	}
 
@@ -1087,6 +1173,8 @@ func parseVorbisComments(data []byte, metadata *AudioMetadata) {
 	if len(albumArtistValues) > 0 {
 		metadata.AlbumArtist = joinVorbisCommentValues(albumArtistValues)
 	}
+	metadata.TotalTracks = resolveIndexTotal("", "track", metadata.TotalTracks, trackTotalValues)
+	metadata.TotalDiscs = resolveIndexTotal("", "disc", metadata.TotalDiscs, discTotalValues)
 }
 
 func GetOggQuality(filePath string) (*OggQuality, error) {
@@ -1449,7 +1537,7 @@ func extractPictureFromVorbisComments(data []byte) ([]byte, string) {
 		if err := binary.Read(reader, binary.LittleEndian, &commentLen); err != nil {
 			break
 		}
-		if commentLen > 10000000 {
+		if commentLen > uint32(reader.Len()) {
 			break
 		}
 
@@ -1510,7 +1598,7 @@ func parseFLACPictureBlock(data []byte) ([]byte, string) {
 
 	var dataLen uint32
 	binary.Read(reader, binary.BigEndian, &dataLen)
-	if dataLen > 10000000 {
+	if dataLen > 10000000 || int64(dataLen) > int64(reader.Len()) {
 		return nil, ""
 	}
 
@@ -0,0 +1,146 @@
+package gobackend
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// flacQualityHeaderSize covers the "fLaC" marker, the STREAMINFO block
+// header and the 34 byte STREAMINFO body.
+const flacQualityHeaderSize = 4 + 4 + 34
+
+// maxQualityProbeBytes bounds how much GetAudioQualityFromReader buffers
+// looking for an MP4 moov atom placed after the media data.
+const maxQualityProbeBytes = 64 << 20
+
+// ErrNeedMoreData matches every NeedMoreDataError via errors.Is.
+var ErrNeedMoreData = errors.New("need more data")
+
+// NeedMoreDataError is returned when a buffer ends before the audio format
+// information. Required is the total buffer length, from the start of the
+// stream, needed to make progress; it may grow again for MP4 streams.
+type NeedMoreDataError struct {
+	Required int
+}
+
+func (e *NeedMoreDataError) Error() string {
+	return fmt.Sprintf("need more data: %d bytes required", e.Required)
+}
+
+func (e *NeedMoreDataError) Is(target error) bool {
+	return target == ErrNeedMoreData
+}
+
+// parseFLACQualityHeader reads the audio format from the first
+// flacQualityHeaderSize bytes of a FLAC stream.
+func parseFLACQualityHeader(data []byte) (AudioQuality, error) {
+	if len(data) < flacQualityHeaderSize {
+		return AudioQuality{}, &NeedMoreDataError{Required: flacQualityHeaderSize}
+	}
+	if string(data[:4]) != "fLaC" {
+		return AudioQuality{}, fmt.Errorf("not a FLAC stream")
+	}
+	if data[4]&0x7F != 0 {
+		return AudioQuality{}, fmt.Errorf("first block is not STREAMINFO")
+	}
+
+	bitsPerSample, sampleRate, totalSamples := parseFLACStreamInfoQuality(data[8:flacQualityHeaderSize])
+	return AudioQuality{
+		BitDepth:           bitsPerSample,
+		SampleRate:         sampleRate,
+		TotalSamples:       totalSamples,
+		Duration:           flacDurationSeconds(totalSamples, sampleRate),
+		Codec:              "flac",
+		Channels:           parseFLACStreamInfoChannels(data[8:flacQualityHeaderSize]),
+		MissingSampleCount: totalSamples == 0,
+	}, nil
+}
+
+// m4aMoovEnd walks the top-level atoms of data and returns the end offset of
+// the moov atom, or a NeedMoreDataError when data stops before it.
+func m4aMoovEnd(data []byte) (int64, error) {
+	size := int64(len(data))
+	for pos := int64(0); ; {
+		if pos+8 > size {
+			return 0, &NeedMoreDataError{Required: int(pos + 8)}
+		}
+		header, err := readAtomHeaderAt(bytes.NewReader(data), pos, size)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// A 64-bit size follows the 8 byte header.
+			return 0, &NeedMoreDataError{Required: int(pos + 16)}
+		}
+		if err != nil {
+			return 0, err
+		}
+		if header.size == 0 {
+			return 0, fmt.Errorf("moov atom not found")
+		}
+		if header.size < header.headerSize {
+			return 0, fmt.Errorf("invalid atom size for %s", header.typ)
+		}
+		end := pos + header.size
+		if header.typ == "moov" {
+			if end > size {
+				return 0, &NeedMoreDataError{Required: int(end)}
+			}
+			return end, nil
+		}
+		pos = end
+	}
+}
+
+// GetAudioQualityFromBytes is GetAudioQuality for a stream held in memory,
+// typically the first few KB of a download, so the advertised quality can be
+// checked before the file is written. A buffer that ends before the format
+// information returns a NeedMoreDataError. The bitrate is not estimated since
+// data may be only a prefix of the stream.
+func GetAudioQualityFromBytes(data []byte) (AudioQuality, error) {
+	if len(data) >= 4 && string(data[:4]) == "fLaC" {
+		return parseFLACQualityHeader(data)
+	}
+	if len(data) < 8 {
+		return AudioQuality{}, &NeedMoreDataError{Required: 8}
+	}
+	if string(data[4:8]) != "ftyp" {
+		return AudioQuality{}, fmt.Errorf("unsupported file format (not FLAC or M4A)")
+	}
+
+	moovEnd, err := m4aMoovEnd(data)
+	if err != nil {
+		return AudioQuality{}, err
+	}
+	quality, err := readM4AQuality(bytes.NewReader(data[:moovEnd]), moovEnd)
+	if err != nil {
+		return AudioQuality{}, err
+	}
+	quality.Bitrate = 0
+	return quality, nil
+}
+
+// GetAudioQualityFromReader reads from r only as far as
+// GetAudioQualityFromBytes needs. If r ends first, the NeedMoreDataError is
+// returned.
+func GetAudioQualityFromReader(r io.Reader) (AudioQuality, error) {
+	var data []byte
+	required := flacQualityHeaderSize
+	for {
+		buf := make([]byte, required-len(data))
+		n, readErr := io.ReadFull(r, buf)
+		data = append(data, buf[:n]...)
+		if readErr != nil && readErr != io.ErrUnexpectedEOF && readErr != io.EOF {
+			return AudioQuality{}, fmt.Errorf("failed to read stream: %w", readErr)
+		}
+
+		quality, err := GetAudioQualityFromBytes(data)
+		var needMore *NeedMoreDataError
+		if !errors.As(err, &needMore) {
+			return quality, err
+		}
+		if readErr != nil || needMore.Required <= len(data) || needMore.Required > maxQualityProbeBytes {
+			return AudioQuality{}, err
+		}
+		required = needMore.Required
+	}
+}
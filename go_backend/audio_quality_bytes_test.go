@@ -0,0 +1,75 @@
+package gobackend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestGetAudioQualityFromBytesFLAC(t *testing.T) {
+	data, err := buildSelfTestFLAC()
+	if err != nil {
+		t.Fatalf("buildSelfTestFLAC: %v", err)
+	}
+	want, err := parseFLACQualityHeader(data)
+	if err != nil || want.SampleRate == 0 {
+		t.Fatalf("parse full header: %#v/%v", want, err)
+	}
+
+	got, err := GetAudioQualityFromBytes(data[:flacQualityHeaderSize])
+	if err != nil || got != want {
+		t.Fatalf("GetAudioQualityFromBytes = %#v/%v, want %#v", got, err, want)
+	}
+
+	_, err = GetAudioQualityFromBytes(data[:20])
+	var needMore *NeedMoreDataError
+	if !errors.As(err, &needMore) || needMore.Required != flacQualityHeaderSize || !errors.Is(err, ErrNeedMoreData) {
+		t.Fatalf("expected need more data error, got %v", err)
+	}
+
+	r := bytes.NewReader(data)
+	if got, err := GetAudioQualityFromReader(r); err != nil || got != want {
+		t.Fatalf("GetAudioQualityFromReader = %#v/%v", got, err)
+	}
+	if consumed := len(data) - r.Len(); consumed != flacQualityHeaderSize {
+		t.Fatalf("reader consumed %d bytes, want %d", consumed, flacQualityHeaderSize)
+	}
+	if _, err := GetAudioQualityFromReader(bytes.NewReader(data[:10])); !errors.Is(err, ErrNeedMoreData) {
+		t.Fatalf("expected need more data from short reader, got %v", err)
+	}
+}
+
+func TestGetAudioQualityFromBytesM4AWithTrailingMoov(t *testing.T) {
+	mvhd := make([]byte, 20)
+	binary.BigEndian.PutUint32(mvhd[12:16], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:20], 180000)
+	sampleEntry := make([]byte, 32)
+	copy(sampleEntry[0:4], "mp4a")
+	sampleEntry[28] = 0xAC
+	sampleEntry[29] = 0x44
+	data := append(buildM4AAtom("ftyp", []byte("M4A \x00\x00\x00\x00")), buildM4AAtom("mdat", make([]byte, 4096))...)
+	moovStart := len(data)
+	data = append(data, buildM4AAtom("moov", append(buildM4AAtom("mvhd", mvhd), sampleEntry...))...)
+
+	_, err := GetAudioQualityFromBytes(data[:1024])
+	var needMore *NeedMoreDataError
+	if !errors.As(err, &needMore) || needMore.Required != moovStart+8 {
+		t.Fatalf("expected request for the next atom header at %d, got %v", moovStart+8, err)
+	}
+	_, err = GetAudioQualityFromBytes(data[:moovStart+8])
+	if !errors.As(err, &needMore) || needMore.Required != len(data) {
+		t.Fatalf("expected request for the whole moov atom, got %v", err)
+	}
+
+	got, err := GetAudioQualityFromReader(bytes.NewReader(data))
+	if err != nil || got.Codec != "aac" || got.SampleRate != 44100 || got.Duration != 180 || got.Bitrate != 0 {
+		t.Fatalf("GetAudioQualityFromReader = %#v/%v", got, err)
+	}
+}
+
+func TestGetAudioQualityFromBytesRejectsUnknownFormat(t *testing.T) {
+	if _, err := GetAudioQualityFromBytes([]byte("RIFF\x00\x00\x00\x00WAVE")); err == nil || errors.Is(err, ErrNeedMoreData) {
+		t.Fatalf("expected unsupported format error, got %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultMaxConcurrentOperations = 4
+	defaultCoverCacheMaxBytes      = 64 << 20
+	defaultConnectTimeoutMs        = 30000
+	defaultPaddingTarget           = standardPaddingSize
+	defaultPaddingMax              = 256 << 10
+	defaultSilenceThresholdDBFS    = -60
+	defaultAlbumArtistMajority     = 0.5
+	defaultFuzzyMatchThreshold     = 0.85
+	defaultFuzzyMatchToleranceMs   = 3000
+)
+
+// BackendConfig holds process-wide tuning knobs set from Dart via Configure.
+// Fields missing from the JSON passed to Configure keep their current value.
+type BackendConfig struct {
+	// DataDir is an app-private directory for small persistent state such
+	// as the offline enrichment queue.
+	DataDir string `json:"data_dir"`
+	// MaxConcurrentOperations caps how many heavy operations (full rewrites,
+	// decodes, network fetches) run at once. Extra calls queue unless they
+	// were made non-blocking, such as FetchCoverNonBlocking.
+	MaxConcurrentOperations int `json:"max_concurrent_operations"`
+	// CoverCacheDir is where FetchCover keeps downloaded artwork. Empty
+	// disables the on-disk cache.
+	CoverCacheDir string `json:"cover_cache_dir"`
+	// CoverCacheMaxBytes caps the cover cache; least recently used entries
+	// are evicted first.
+	CoverCacheMaxBytes int64 `json:"cover_cache_max_bytes"`
+	// ConnectTimeoutMs bounds TCP connect time for all outgoing requests.
+	ConnectTimeoutMs int `json:"connect_timeout_ms"`
+	// ReadTimeoutMs bounds how long to wait for response headers. Body reads
+	// are left to per-client timeouts so large downloads are not cut off.
+	ReadTimeoutMs int `json:"read_timeout_ms"`
+	// ProxyURL routes requests through an http://, https:// or socks5://
+	// proxy. Empty means direct connections.
+	ProxyURL string `json:"proxy_url"`
+	// UserAgent overrides the randomized browser User-Agent when set.
+	UserAgent string `json:"user_agent"`
+	// WifiOnly blocks network calls while Cellular is reported by the app.
+	WifiOnly bool `json:"wifi_only"`
+	Cellular bool `json:"cellular"`
+	// FetchMaxAttempts is how many times cover, lyrics-search and metadata
+	// fetches are tried before giving up (1 disables retries).
+	FetchMaxAttempts int `json:"fetch_max_attempts"`
+	// HostRateLimits overrides the per-host request pacing. Keys are host
+	// names; a rate of 0 lifts the default limit for that host.
+	HostRateLimits map[string]HostRateLimit `json:"host_rate_limits,omitempty"`
+	// TagHistory records each tag write (time, app version, changed fields)
+	// in an APPLICATION block inside the file, keeping the last
+	// TagHistoryMaxEntries entries.
+	TagHistory           bool `json:"tag_history"`
+	TagHistoryMaxEntries int  `json:"tag_history_max_entries"`
+	// MetadataCacheEntries caps how many files' read results (tags, audio
+	// quality, embedded lyrics) are kept in memory.
+	MetadataCacheEntries int `json:"metadata_cache_entries"`
+	// PreserveMultilineWhitespace writes lyrics, descriptions and comments
+	// exactly as given. By default their line endings and whitespace are
+	// normalized on write.
+	PreserveMultilineWhitespace bool `json:"preserve_multiline_whitespace"`
+	// PlaceholderPatterns are the regular expressions QualityCheck uses to
+	// flag placeholder tag values. Each must match the whole value and is
+	// case-insensitive. Nil uses the built-in list.
+	PlaceholderPatterns []string `json:"placeholder_patterns,omitempty"`
+	// SkipTotalAliases writes track and disc totals only in the "n/total"
+	// form, without the TOTALTRACKS/TRACKTOTAL and TOTALDISCS/DISCTOTAL
+	// keys some players need.
+	SkipTotalAliases bool `json:"skip_total_aliases"`
+	// ReadOnly puts the backend in browse-only mode: every API that modifies
+	// the library fails with ErrReadOnlyMode while reads keep working.
+	ReadOnly bool `json:"read_only"`
+	// PaddingTarget is the PADDING block size a full FLAC rewrite leaves,
+	// so later tag edits fit in place. PaddingMax is the most padding an
+	// in-place edit may leave behind; beyond it the file is rewritten to
+	// reclaim the space.
+	PaddingTarget int `json:"padding_target"`
+	PaddingMax    int `json:"padding_max"`
+	// AtomicTagWrites always rewrites FLAC files through a temporary file,
+	// even when the new metadata fits the existing padding. In-place edits
+	// only rewrite the metadata, but a crash during one can damage it.
+	AtomicTagWrites bool `json:"atomic_tag_writes"`
+	// ISRCIndexPath is a BuildISRCIndex file kept current as downloads are
+	// indexed. Empty leaves index files alone.
+	ISRCIndexPath string `json:"isrc_index_path"`
+	// QualityPrecedence orders the dimensions CompareQuality weighs; the
+	// first that differs decides. Nil uses lossless, bit_depth, sample_rate,
+	// bitrate.
+	QualityPrecedence []string `json:"quality_precedence,omitempty"`
+	// GenreAliases adds to or overrides the genre spellings NormalizeGenres
+	// folds, as spelling to canonical genre. Spellings match ignoring case,
+	// spaces, dashes, underscores and dots; an empty canonical genre drops a
+	// built-in entry.
+	GenreAliases map[string]string `json:"genre_aliases,omitempty"`
+	// SilenceThresholdDBFS is the level below which AnalyzeAudio counts
+	// samples as silence. It must be negative; -60 by default.
+	SilenceThresholdDBFS float64 `json:"silence_threshold_dbfs"`
+	// AlbumArtistMajority is the share of an album's tracks that one primary
+	// artist must be credited on, strictly more than, for InferAlbumArtist
+	// to pick it over "Various Artists". Between 0 and 1; 0.5 by default.
+	AlbumArtistMajority float64 `json:"album_artist_majority"`
+	// KeepMalformedComments re-emits Vorbis comments without a valid
+	// "KEY=" prefix as MALFORMED_COMMENT=<text> when a FLAC file is saved.
+	// By default they are dropped.
+	KeepMalformedComments bool `json:"keep_malformed_comments"`
+	// FuzzyMatchThreshold is the similarity, between 0 and 1, at which
+	// MatchTracks and the fuzzy tier of FindDuplicates treat two "artist –
+	// title" strings as the same track; 0.85 by default. Their durations
+	// must also be within FuzzyMatchToleranceMs (3000 by default) when both
+	// are known.
+	FuzzyMatchThreshold   float64 `json:"fuzzy_match_threshold"`
+	FuzzyMatchToleranceMs int64   `json:"fuzzy_match_tolerance_ms"`
+	// WriteYearTag writes a 4-digit YEAR next to DATE whenever a date is
+	// written, for players that only read YEAR. By default only DATE is
+	// written; the strict-car compatibility profile turns it on per embed.
+	WriteYearTag bool `json:"write_year_tag"`
+	// IgnorePatterns are globs, in filepath.Match syntax, of files and
+	// folders the library scan and batch operations skip on top of the
+	// built-in rules (dotfiles such as .trashed-* and ._* files, *.part and
+	// *.tmp). A pattern matches the entry's name, or with a "/" its path
+	// relative to the library root. Single-file calls ignore them.
+	IgnorePatterns []string `json:"ignore_patterns,omitempty"`
+	// MultiValueTags is how the embeds write GENRE and MOOD: "split" writes
+	// each value as its own comment, "join" joins them into one comment with
+	// "; ". Combined values are split on MultiValueSeparators (";" and "/"
+	// when nil) and duplicates dropped ignoring case. Empty writes them as
+	// given.
+	MultiValueTags       string   `json:"multi_value_tags"`
+	MultiValueSeparators []string `json:"multi_value_separators,omitempty"`
+	// WriteR128Gain writes R128_TRACK_GAIN and R128_ALBUM_GAIN, the Opus
+	// convention, next to every REPLAYGAIN_*_GAIN written to a FLAC file,
+	// so players that only read one of them agree on the level.
+	WriteR128Gain bool `json:"write_r128_gain"`
+}
+
+var defaultBackendConfig = BackendConfig{
+	MaxConcurrentOperations: defaultMaxConcurrentOperations,
+	CoverCacheMaxBytes:      defaultCoverCacheMaxBytes,
+	ConnectTimeoutMs:        defaultConnectTimeoutMs,
+	ReadTimeoutMs:           0,
+	FetchMaxAttempts:        defaultFetchMaxAttempts,
+	TagHistoryMaxEntries:    defaultTagHistoryMaxEntries,
+	MetadataCacheEntries:    defaultMetadataCacheEntries,
+	PaddingTarget:           defaultPaddingTarget,
+	PaddingMax:              defaultPaddingMax,
+	SilenceThresholdDBFS:    defaultSilenceThresholdDBFS,
+	AlbumArtistMajority:     defaultAlbumArtistMajority,
+	FuzzyMatchThreshold:     defaultFuzzyMatchThreshold,
+	FuzzyMatchToleranceMs:   defaultFuzzyMatchToleranceMs,
+}
+
+var (
+	backendConfigMu sync.RWMutex
+	backendConfig   = defaultBackendConfig
+)
+
+func normalizeBackendConfig(cfg BackendConfig) BackendConfig {
+	if cfg.MaxConcurrentOperations <= 0 {
+		cfg.MaxConcurrentOperations = defaultMaxConcurrentOperations
+	}
+	if cfg.CoverCacheMaxBytes <= 0 {
+		cfg.CoverCacheMaxBytes = defaultCoverCacheMaxBytes
+	}
+	if cfg.ConnectTimeoutMs <= 0 {
+		cfg.ConnectTimeoutMs = defaultConnectTimeoutMs
+	}
+	if cfg.FetchMaxAttempts <= 0 {
+		cfg.FetchMaxAttempts = defaultFetchMaxAttempts
+	}
+	if cfg.TagHistoryMaxEntries <= 0 {
+		cfg.TagHistoryMaxEntries = defaultTagHistoryMaxEntries
+	}
+	if cfg.MetadataCacheEntries <= 0 {
+		cfg.MetadataCacheEntries = defaultMetadataCacheEntries
+	}
+	if cfg.PaddingTarget <= 0 {
+		cfg.PaddingTarget = defaultPaddingTarget
+	}
+	if cfg.PaddingTarget > maxFLACBlockLength {
+		cfg.PaddingTarget = maxFLACBlockLength
+	}
+	if cfg.PaddingMax <= 0 {
+		cfg.PaddingMax = defaultPaddingMax
+	}
+	if cfg.PaddingMax < cfg.PaddingTarget {
+		cfg.PaddingMax = cfg.PaddingTarget
+	}
+	if cfg.SilenceThresholdDBFS >= 0 {
+		cfg.SilenceThresholdDBFS = defaultSilenceThresholdDBFS
+	}
+	if cfg.AlbumArtistMajority <= 0 || cfg.AlbumArtistMajority >= 1 {
+		cfg.AlbumArtistMajority = defaultAlbumArtistMajority
+	}
+	if cfg.FuzzyMatchThreshold <= 0 || cfg.FuzzyMatchThreshold > 1 {
+		cfg.FuzzyMatchThreshold = defaultFuzzyMatchThreshold
+	}
+	if cfg.FuzzyMatchToleranceMs <= 0 {
+		cfg.FuzzyMatchToleranceMs = defaultFuzzyMatchToleranceMs
+	}
+	if cfg.ReadTimeoutMs < 0 {
+		cfg.ReadTimeoutMs = 0
+	}
+	cfg.DataDir = strings.TrimSpace(cfg.DataDir)
+	cfg.ISRCIndexPath = strings.TrimSpace(cfg.ISRCIndexPath)
+	cfg.ProxyURL = strings.TrimSpace(cfg.ProxyURL)
+	cfg.UserAgent = strings.TrimSpace(cfg.UserAgent)
+	return cfg
+}
+
+func SetBackendConfig(cfg BackendConfig) error {
+	normalized := normalizeBackendConfig(cfg)
+	proxy, err := parseProxyURL(normalized.ProxyURL)
+	if err != nil {
+		return err
+	}
+
+	patterns := defaultPlaceholderPatterns
+	if normalized.PlaceholderPatterns != nil {
+		patterns = normalized.PlaceholderPatterns
+	}
+	compiledPatterns, err := compilePlaceholderPatterns(patterns)
+	if err != nil {
+		return err
+	}
+
+	if err := validateQualityPrecedence(normalized.QualityPrecedence); err != nil {
+		return err
+	}
+	if err := validateIgnorePatterns(normalized.IgnorePatterns); err != nil {
+		return err
+	}
+	if err := validateMultiValueConfig(normalized.MultiValueTags, normalized.MultiValueSeparators); err != nil {
+		return err
+	}
+
+	normalized.HostRateLimits = maps.Clone(normalized.HostRateLimits)
+	normalized.PlaceholderPatterns = slices.Clone(normalized.PlaceholderPatterns)
+	normalized.QualityPrecedence = slices.Clone(normalized.QualityPrecedence)
+	normalized.GenreAliases = maps.Clone(normalized.GenreAliases)
+	normalized.IgnorePatterns = slices.Clone(normalized.IgnorePatterns)
+	normalized.MultiValueSeparators = slices.Clone(normalized.MultiValueSeparators)
+
+	backendConfigMu.Lock()
+	backendConfig = normalized
+	backendConfigMu.Unlock()
+
+	heavyOperationLimiter.setLimit(normalized.MaxConcurrentOperations)
+	metadataReadCache.setCapacity(normalized.MetadataCacheEntries)
+	applyNetworkConfig(normalized, proxy)
+	applyHostRateLimits(normalized.HostRateLimits)
+	setPlaceholderPatterns(compiledPatterns)
+
+	GoLog("[Config] Backend config set: max_concurrent_operations=%d proxy=%v wifi_only=%v read_only=%v\n",
+		normalized.MaxConcurrentOperations,
+		proxy != nil,
+		normalized.WifiOnly,
+		normalized.ReadOnly,
+	)
+	return nil
+}
+
+func GetBackendConfig() BackendConfig {
+	backendConfigMu.RLock()
+	defer backendConfigMu.RUnlock()
+	cfg := backendConfig
+	// Callers may mutate the copy (Configure unmarshals into it), so it must
+	// not share the map or slices with the live config.
+	cfg.HostRateLimits = maps.Clone(cfg.HostRateLimits)
+	cfg.PlaceholderPatterns = slices.Clone(cfg.PlaceholderPatterns)
+	cfg.QualityPrecedence = slices.Clone(cfg.QualityPrecedence)
+	cfg.GenreAliases = maps.Clone(cfg.GenreAliases)
+	cfg.IgnorePatterns = slices.Clone(cfg.IgnorePatterns)
+	cfg.MultiValueSeparators = slices.Clone(cfg.MultiValueSeparators)
+	return cfg
+}
+
+// Configure merges configJSON into the current backend config. An empty
+// string leaves the config unchanged.
+func Configure(configJSON string) error {
+	current := GetBackendConfig()
+	cfg := current
+	if strings.TrimSpace(configJSON) != "" {
+		if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+			return fmt.Errorf("invalid config JSON: %w", err)
+		}
+	}
+	if cfg.ProxyURL != current.ProxyURL && cfg.ProxyURL == redactProxyURL(current.ProxyURL) {
+		cfg.ProxyURL = current.ProxyURL
+	}
+
+	return SetBackendConfig(cfg)
+}
+
+// redactProxyURL masks the password of a proxy URL.
+func redactProxyURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	proxy, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return proxy.Redacted()
+}
+
+// redactedConfig returns the current config with the password of ProxyURL
+// masked, for output that may be shared off the device.
+func redactedConfig() BackendConfig {
+	cfg := GetBackendConfig()
+	cfg.ProxyURL = redactProxyURL(cfg.ProxyURL)
+	return cfg
+}
+
+// GetConfigJSON returns the current config with the proxy password masked.
+// The proxy is write-only: passing the masked URL back to Configure keeps
+// the stored one.
+func GetConfigJSON() (string, error) {
+	jsonBytes, err := json.Marshal(redactedConfig())
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,190 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// batchETAAlpha is the weight of the latest item in the moving averages:
+// high enough to follow a change of speed within a few items, low enough
+// that one slow file does not make the estimate jump.
+const batchETAAlpha = 0.3
+
+// batchETA estimates the time left in a batch from a moving average of
+// per-item duration, recalculated at each item boundary. Items of known
+// size are averaged per byte, so a long album after a run of singles does
+// not throw the estimate off; items of unknown size use the per-item
+// average. Workers divides the estimate for batches run in parallel.
+type batchETA struct {
+	mu             sync.Mutex
+	workers        int
+	remainingItems int
+	remainingBytes int64
+	unsizedItems   int
+	nsPerByte      float64
+	nsPerItem      float64
+	sizedSamples   int
+	samples        int
+	// fallback estimates the batch before any item has finished; without
+	// one the estimate is zero until then.
+	fallback func(remainingItems int) time.Duration
+}
+
+// newBatchETA starts an estimate for items of the given sizes, in bytes;
+// zero marks an unknown size.
+func newBatchETA(sizes []int64, workers int) *batchETA {
+	e := &batchETA{workers: max(workers, 1), remainingItems: len(sizes)}
+	for _, size := range sizes {
+		if size > 0 {
+			e.remainingBytes += size
+		} else {
+			e.unsizedItems++
+		}
+	}
+	return e
+}
+
+func movingAverage(average, sample float64, samples int) float64 {
+	if samples == 0 {
+		return sample
+	}
+	return average + batchETAAlpha*(sample-average)
+}
+
+// done records that an item of size bytes finished after took.
+func (e *batchETA) done(size int64, took time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.remainingItems == 0 {
+		return
+	}
+	e.remainingItems--
+	if size > 0 {
+		e.remainingBytes = max(e.remainingBytes-size, 0)
+		e.nsPerByte = movingAverage(e.nsPerByte, float64(took)/float64(size), e.sizedSamples)
+		e.sizedSamples++
+	} else if e.unsizedItems > 0 {
+		e.unsizedItems--
+	}
+	e.nsPerItem = movingAverage(e.nsPerItem, float64(took), e.samples)
+	e.samples++
+}
+
+// remaining returns the estimated time left.
+func (e *batchETA) remaining() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.remainingItems == 0 {
+		return 0
+	}
+	if e.samples == 0 {
+		if e.fallback != nil {
+			return e.fallback(e.remainingItems)
+		}
+		return 0
+	}
+	var ns float64
+	if e.sizedSamples > 0 {
+		ns = float64(e.remainingBytes)*e.nsPerByte + float64(e.unsizedItems)*e.nsPerItem
+	} else {
+		ns = float64(e.remainingItems) * e.nsPerItem
+	}
+	return time.Duration(ns / float64(e.workers))
+}
+
+// remainingMs is remaining in whole milliseconds, as progress payloads
+// report it.
+func (e *batchETA) remainingMs() int64 {
+	return e.remaining().Milliseconds()
+}
+
+// BatchProgress is the progress payload of the library batch operations
+// that report through a batchProgress.
+type BatchProgress struct {
+	Total       int     `json:"total"`
+	Completed   int     `json:"completed"`
+	CurrentFile string  `json:"current_file"`
+	ProgressPct float64 `json:"progress_pct"`
+	IsComplete  bool    `json:"is_complete"`
+	// EstimatedRemainingMs is zero until the first item is done.
+	EstimatedRemainingMs int64 `json:"estimated_remaining_ms"`
+}
+
+// batchProgress publishes the BatchProgress of one kind of batch, feeding
+// EstimatedRemainingMs from a batchETA. A nil batchProgress records
+// nothing, for internal callers that run a batch without reporting it.
+type batchProgress struct {
+	mu       sync.RWMutex
+	progress BatchProgress
+	eta      *batchETA
+}
+
+// start resets the progress for items of the given sizes, as newBatchETA
+// takes them.
+func (b *batchProgress) start(sizes []int64, workers int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.progress = BatchProgress{Total: len(sizes)}
+	b.eta = newBatchETA(sizes, workers)
+}
+
+// item marks name as being worked on and returns the func that records it
+// done, at the item boundary where the estimate is recalculated.
+func (b *batchProgress) item(name string, size int64) func() {
+	if b == nil {
+		return func() {}
+	}
+	b.mu.Lock()
+	b.progress.CurrentFile = name
+	eta := b.eta
+	b.mu.Unlock()
+
+	started := time.Now()
+	return func() {
+		eta.done(size, time.Since(started))
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.eta != eta {
+			return
+		}
+		b.progress.Completed++
+		b.progress.ProgressPct = float64(b.progress.Completed) / float64(max(b.progress.Total, 1)) * 100
+		b.progress.EstimatedRemainingMs = eta.remainingMs()
+	}
+}
+
+// finish marks the batch complete, cancelled or not.
+func (b *batchProgress) finish() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.progress.CurrentFile = ""
+	b.progress.IsComplete = true
+	b.progress.EstimatedRemainingMs = 0
+}
+
+func (b *batchProgress) json() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	jsonBytes, _ := json.Marshal(b.progress)
+	return string(jsonBytes)
+}
+
+// fileSizes returns the sizes of paths for newBatchETA, zero for any that
+// cannot be read.
+func fileSizes(paths []string) []int64 {
+	sizes := make([]int64, len(paths))
+	for i, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			sizes[i] = info.Size()
+		}
+	}
+	return sizes
+}
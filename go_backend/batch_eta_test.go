@@ -0,0 +1,151 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBatchETAMovingAverage(t *testing.T) {
+	eta := newBatchETA([]int64{0, 0, 0, 0}, 1)
+	if got := eta.remaining(); got != 0 {
+		t.Fatalf("ETA before any item = %v", got)
+	}
+	eta.done(0, 4*time.Second)
+	if got := eta.remaining(); got != 12*time.Second {
+		t.Fatalf("ETA after first item = %v", got)
+	}
+	// 4s + 0.3*(1s-4s) = 3.1s per item, two items left.
+	eta.done(0, time.Second)
+	if got := eta.remaining(); got != 6200*time.Millisecond {
+		t.Fatalf("ETA after second item = %v", got)
+	}
+	eta.done(0, time.Second)
+	eta.done(0, time.Second)
+	if got := eta.remainingMs(); got != 0 {
+		t.Fatalf("ETA after last item = %d", got)
+	}
+	// Extra reports past the end are ignored.
+	eta.done(0, time.Second)
+	if got := eta.remaining(); got != 0 {
+		t.Fatalf("ETA after overrun = %v", got)
+	}
+}
+
+func TestBatchETAWeightsBySize(t *testing.T) {
+	eta := newBatchETA([]int64{1000, 1000, 8000, 0}, 1)
+	eta.done(1000, time.Second)
+	// 1ms per byte for 9000 sized bytes, plus one unsized item at the
+	// per-item average of 1s.
+	if got := eta.remaining(); got != 10*time.Second {
+		t.Fatalf("size-weighted ETA = %v", got)
+	}
+
+	parallel := newBatchETA([]int64{1000, 1000, 1000, 1000, 1000}, 2)
+	parallel.done(1000, 2*time.Second)
+	if got := parallel.remaining(); got != 4*time.Second {
+		t.Fatalf("ETA over two workers = %v", got)
+	}
+}
+
+func TestBatchETAFallback(t *testing.T) {
+	eta := newBatchETA([]int64{0, 0, 0}, 1)
+	eta.fallback = func(remaining int) time.Duration { return time.Duration(remaining) * time.Minute }
+	if got := eta.remaining(); got != 3*time.Minute {
+		t.Fatalf("fallback ETA = %v", got)
+	}
+	eta.done(0, time.Second)
+	if got := eta.remaining(); got != 2*time.Second {
+		t.Fatalf("ETA after first item = %v", got)
+	}
+}
+
+func TestLibraryScanProgressReportsETA(t *testing.T) {
+	root := t.TempDir()
+	data := mustReadFile(t, writeTestFLACWithMetadata(t, Metadata{Title: "Track"}))
+	for _, name := range []string{"a.flac", "b.flac", "c.flac"} {
+		if err := os.WriteFile(filepath.Join(root, name), data, 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if _, err := ScanLibraryFolder(root); err != nil {
+		t.Fatalf("ScanLibraryFolder: %v", err)
+	}
+	var progress LibraryScanProgress
+	if err := json.Unmarshal([]byte(GetLibraryScanProgress()), &progress); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !progress.IsComplete || progress.EstimatedRemainingMs != 0 {
+		t.Fatalf("unexpected final progress: %+v", progress)
+	}
+	files, err := collectLibraryAudioFiles(root, nil)
+	if err != nil {
+		t.Fatalf("collectLibraryAudioFiles: %v", err)
+	}
+	for _, file := range files {
+		if file.size != int64(len(data)) {
+			t.Fatalf("size of %s = %d, want %d", file.path, file.size, len(data))
+		}
+	}
+}
+
+func TestBatchProgress(t *testing.T) {
+	var progress batchProgress
+	progress.start([]int64{100, 100}, 1)
+	stale := progress.item("a.flac", 100)
+	progress.start([]int64{100, 100}, 1)
+
+	done := progress.item("a.flac", 100)
+	time.Sleep(5 * time.Millisecond)
+	done()
+	// An item of a batch that has been restarted does not count.
+	stale()
+	got := mustDecodeJSON[BatchProgress](t, progress.json(), nil)
+	if got.Total != 2 || got.Completed != 1 || got.ProgressPct != 50 || got.EstimatedRemainingMs < 5 || got.CurrentFile != "a.flac" {
+		t.Fatalf("progress after one item = %+v", got)
+	}
+
+	progress.finish()
+	if got := mustDecodeJSON[BatchProgress](t, progress.json(), nil); !got.IsComplete || got.EstimatedRemainingMs != 0 || got.CurrentFile != "" {
+		t.Fatalf("final progress = %+v", got)
+	}
+
+	var nilProgress *batchProgress
+	nilProgress.start([]int64{1}, 1)
+	nilProgress.item("a.flac", 1)()
+	nilProgress.finish()
+}
+
+func TestLibraryBatchesReportProgress(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"A/01.flac", "A/02.flac", "B/01.flac"} {
+		writeConsistencyFixture(t, root, name, Metadata{Title: name, TrackNumber: 1})
+	}
+	manifest := filepath.Join(t.TempDir(), "library.sha256.json")
+
+	tests := []struct {
+		name     string
+		run      func() (string, error)
+		progress func() string
+		total    int
+	}{
+		{"checksum", func() (string, error) { return WriteChecksumManifest(root, manifest) }, GetChecksumManifestProgress, 3},
+		{"loudness", func() (string, error) { return LoudnessReport(root) }, GetLoudnessReportProgress, 3},
+		{"album art", func() (string, error) { return FixAlbumArtConsistency(root, "") }, GetAlbumArtConsistencyProgress, 2},
+		{"cover repair", func() (string, error) { return RepairAlbumCovers(root) }, GetAlbumCoverRepairProgress, 2},
+		{"metadata diff", func() (string, error) {
+			return DiffMetadataDir(filepath.Join(root, "A"), `[{"track_number":1},{"track_number":2}]`, "")
+		}, GetMetadataDiffProgress, 2},
+	}
+	for _, tt := range tests {
+		if _, err := tt.run(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got := mustDecodeJSON[BatchProgress](t, tt.progress(), nil)
+		if !got.IsComplete || got.Total != tt.total || got.Completed != tt.total || got.EstimatedRemainingMs != 0 {
+			t.Fatalf("%s progress = %+v", tt.name, got)
+		}
+	}
+}
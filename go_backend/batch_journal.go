@@ -0,0 +1,282 @@
+package gobackend
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Batch operations that can be journaled and resumed.
+const (
+	batchOpCanonicalizeTags = "canonicalize_tags"
+	batchOpFixFilenames     = "fix_filenames"
+	batchOpLoudnessReport   = "loudness_report"
+)
+
+// batchJournalRecord is one line of a batch journal. The first line names
+// the operation and its arguments, each completed item adds one line, and a
+// finished run ends with a Complete line.
+type batchJournalRecord struct {
+	Op        string          `json:"op,omitempty"`
+	Args      json.RawMessage `json:"args,omitempty"`
+	StartedAt string          `json:"started_at,omitempty"`
+	Item      string          `json:"item,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Complete  bool            `json:"complete,omitempty"`
+}
+
+// batchJournal appends to a line-delimited JSON journal so a batch killed
+// mid-run can be resumed with Resume. A nil *batchJournal journals nothing,
+// so batch loops need no special casing when the caller passes no path.
+type batchJournal struct {
+	file    *os.File
+	done    map[string]bool
+	results map[string]json.RawMessage
+}
+
+// parsedBatchJournal is what readBatchJournal recovers from disk. validSize
+// is the length of the well-formed prefix; a torn last line is beyond it.
+type parsedBatchJournal struct {
+	header    batchJournalRecord
+	done      map[string]bool
+	results   map[string]json.RawMessage
+	complete  bool
+	validSize int64
+}
+
+// readBatchJournal parses the journal at path. A final line without its
+// newline, or one that is not valid JSON, is what a kill mid-append leaves
+// behind and is discarded.
+func readBatchJournal(path string) (*parsedBatchJournal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	parsed := &parsedBatchJournal{done: make(map[string]bool), results: make(map[string]json.RawMessage)}
+	var offset int64
+	first := true
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			GoLog("[Journal] Discarding partial last line of %s\n", path)
+			break
+		}
+		line := data[:end]
+		data = data[end+1:]
+
+		var record batchJournalRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			if len(data) == 0 {
+				GoLog("[Journal] Discarding unreadable last line of %s\n", path)
+				break
+			}
+			return nil, fmt.Errorf("corrupt batch journal at byte %d: %w", offset, err)
+		}
+		offset += int64(end + 1)
+
+		switch {
+		case first:
+			first = false
+			parsed.header = record
+		case record.Complete:
+			parsed.complete = true
+		case record.Item != "" && record.Error == "":
+			parsed.done[record.Item] = true
+			if record.Result != nil {
+				parsed.results[record.Item] = record.Result
+			}
+		case record.Item != "":
+			// A failed item is retried on resume.
+			delete(parsed.done, record.Item)
+			delete(parsed.results, record.Item)
+		}
+	}
+	if parsed.header.Op == "" {
+		return nil, fmt.Errorf("batch journal has no header: %s", path)
+	}
+	parsed.validSize = offset
+	return parsed, nil
+}
+
+// openBatchJournal opens the journal at path for the operation op. An
+// unfinished journal of the same operation is continued and its completed
+// items are skipped; a missing, finished or torn-header journal starts
+// over. An empty path disables journaling.
+func openBatchJournal(path, op string, args interface{}) (*batchJournal, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	parsed, err := readBatchJournal(path)
+	switch {
+	case err == nil && parsed.header.Op != op:
+		return nil, fmt.Errorf("journal belongs to a %s job, not %s", parsed.header.Op, op)
+	case err == nil && !parsed.complete:
+		file, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open batch journal: %w", err)
+		}
+		if err := file.Truncate(parsed.validSize); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to trim batch journal: %w", err)
+		}
+		if _, err := file.Seek(parsed.validSize, 0); err != nil {
+			file.Close()
+			return nil, err
+		}
+		GoLog("[Journal] Resuming %s with %d items done\n", op, len(parsed.done))
+		return &batchJournal{file: file, done: parsed.done, results: parsed.results}, nil
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		GoLog("[Journal] Starting over: %v\n", err)
+	}
+
+	rawArgs, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create journal folder: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create batch journal: %w", err)
+	}
+	journal := &batchJournal{file: file, done: make(map[string]bool), results: make(map[string]json.RawMessage)}
+	header := batchJournalRecord{Op: op, Args: rawArgs, StartedAt: time.Now().UTC().Format(time.RFC3339)}
+	if err := journal.append(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return journal, nil
+}
+
+func (j *batchJournal) append(record batchJournalRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write batch journal: %w", err)
+	}
+	return j.file.Sync()
+}
+
+// completed reports whether a previous run already finished item.
+func (j *batchJournal) completed(item string) bool {
+	return j != nil && j.done[item]
+}
+
+// record notes that item was processed. Journal write errors are logged
+// rather than failing the item, whose change is already on disk.
+func (j *batchJournal) record(item string, itemErr error) {
+	if j == nil {
+		return
+	}
+	record := batchJournalRecord{Item: item}
+	if itemErr != nil {
+		record.Error = itemErr.Error()
+	}
+	if err := j.append(record); err != nil {
+		GoLog("[Journal] %v\n", err)
+	}
+}
+
+// recordResult notes that item was processed into result, which a resumed
+// run reads back with result instead of processing item again.
+func (j *batchJournal) recordResult(item string, result interface{}) {
+	if j == nil {
+		return
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		GoLog("[Journal] %v\n", err)
+		return
+	}
+	if err := j.append(batchJournalRecord{Item: item, Result: raw}); err != nil {
+		GoLog("[Journal] %v\n", err)
+	}
+}
+
+// result returns what a previous run recorded for item with recordResult,
+// or nil.
+func (j *batchJournal) result(item string) json.RawMessage {
+	if j == nil {
+		return nil
+	}
+	return j.results[item]
+}
+
+// finish marks the run complete and closes the journal.
+func (j *batchJournal) finish() {
+	if j == nil || j.file == nil {
+		return
+	}
+	if err := j.append(batchJournalRecord{Complete: true}); err != nil {
+		GoLog("[Journal] %v\n", err)
+	}
+	j.close()
+}
+
+// close closes the journal without finishing it, so a run that stopped
+// early can be resumed.
+func (j *batchJournal) close() {
+	if j != nil && j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+}
+
+type canonicalizeTagsJournalArgs struct {
+	Root    string `json:"root"`
+	Mapping string `json:"mapping"`
+}
+
+type fixFilenamesJournalArgs struct {
+	Root     string `json:"root"`
+	Template string `json:"template"`
+}
+
+type loudnessReportJournalArgs struct {
+	Root string `json:"root"`
+}
+
+// Resume continues the batch job recorded in the journal at journalPath,
+// skipping the items it already completed, and returns that job's report.
+// Only the items done in this run are in the report, except in a loudness
+// report, which reads the earlier results back from the journal.
+func Resume(journalPath string) (string, error) {
+	parsed, err := readBatchJournal(journalPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read batch journal: %w", err)
+	}
+	if parsed.complete {
+		return "", fmt.Errorf("batch job already complete: %s", journalPath)
+	}
+
+	switch parsed.header.Op {
+	case batchOpCanonicalizeTags:
+		var args canonicalizeTagsJournalArgs
+		if err := json.Unmarshal(parsed.header.Args, &args); err != nil {
+			return "", fmt.Errorf("invalid journal arguments: %w", err)
+		}
+		return CanonicalizeLibraryTagsWithJournal(args.Root, args.Mapping, journalPath)
+	case batchOpFixFilenames:
+		var args fixFilenamesJournalArgs
+		if err := json.Unmarshal(parsed.header.Args, &args); err != nil {
+			return "", fmt.Errorf("invalid journal arguments: %w", err)
+		}
+		return FixFilenameConsistencyWithJournal(args.Root, args.Template, journalPath)
+	case batchOpLoudnessReport:
+		var args loudnessReportJournalArgs
+		if err := json.Unmarshal(parsed.header.Args, &args); err != nil {
+			return "", fmt.Errorf("invalid journal arguments: %w", err)
+		}
+		return LoudnessReportWithJournal(args.Root, journalPath)
+	}
+	return "", fmt.Errorf("unknown batch operation: %s", parsed.header.Op)
+}
@@ -0,0 +1,97 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeAliasedLibrary(t *testing.T) (root string, files []string) {
+	t.Helper()
+	root = t.TempDir()
+	for _, name := range []string{"a.flac", "b.flac"} {
+		path := filepath.Join(root, name)
+		if err := os.Rename(writeAliasedFLAC(t, "YEAR=2020"), path); err != nil {
+			t.Fatalf("move fixture: %v", err)
+		}
+		files = append(files, path)
+	}
+	return root, files
+}
+
+func TestResumeSkipsJournaledItems(t *testing.T) {
+	root, files := writeAliasedLibrary(t)
+	journalPath := filepath.Join(t.TempDir(), "jobs", "canonicalize.jsonl")
+
+	// Simulate a run killed after the first file, mid-way through
+	// appending the second.
+	journal, err := openBatchJournal(journalPath, batchOpCanonicalizeTags, canonicalizeTagsJournalArgs{Root: root})
+	if err != nil {
+		t.Fatalf("openBatchJournal: %v", err)
+	}
+	journal.record(files[0], nil)
+	journal.file.WriteString(`{"item":"` + files[1])
+	journal.close()
+
+	raw, err := Resume(journalPath)
+	report := mustDecodeJSON[TagCanonicalizationReport](t, raw, err)
+	if report.Resumed != 1 || report.Checked != 1 || report.Changed != 1 || report.Files[0].Path != files[1] {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	// The skipped file still has its alias; the resumed one was fixed.
+	skipped, _ := GetTags(files[0], []string{"YEAR"})
+	fixed, _ := GetTags(files[1], []string{"YEAR", "DATE"})
+	if len(skipped["YEAR"]) != 1 || len(fixed["YEAR"]) != 0 || !slices.Equal(fixed["DATE"], []string{"2020"}) {
+		t.Fatalf("unexpected tags: skipped=%v fixed=%v", skipped, fixed)
+	}
+
+	parsed, err := readBatchJournal(journalPath)
+	if err != nil || !parsed.complete || !parsed.done[files[0]] || !parsed.done[files[1]] {
+		t.Fatalf("journal not completed cleanly: %+v %v", parsed, err)
+	}
+	if _, err := Resume(journalPath); err == nil {
+		t.Fatal("resuming a complete job should fail")
+	}
+}
+
+func TestBatchJournalRetriesFailedItems(t *testing.T) {
+	journalPath := filepath.Join(t.TempDir(), "job.jsonl")
+	journal, err := openBatchJournal(journalPath, batchOpFixFilenames, fixFilenamesJournalArgs{Root: "/music"})
+	if err != nil {
+		t.Fatalf("openBatchJournal: %v", err)
+	}
+	journal.record("/music/a.flac", nil)
+	journal.record("/music/b.flac", os.ErrPermission)
+	journal.close()
+
+	reopened, err := openBatchJournal(journalPath, batchOpFixFilenames, nil)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.close()
+	if !reopened.completed("/music/a.flac") || reopened.completed("/music/b.flac") {
+		t.Fatalf("unexpected completed set: %v", reopened.done)
+	}
+
+	if _, err := openBatchJournal(journalPath, batchOpCanonicalizeTags, nil); err == nil {
+		t.Fatal("a journal of another operation should be rejected")
+	}
+}
+
+func TestFixFilenameConsistencyWithJournal(t *testing.T) {
+	root := t.TempDir()
+	path := writeConsistencyFixture(t, root, "wrong.flac", Metadata{Title: "Song", Artist: "Artist"})
+	journalPath := filepath.Join(t.TempDir(), "rename.jsonl")
+
+	raw, err := FixFilenameConsistencyWithJournal(root, "{artist} - {title}", journalPath)
+	report := mustDecodeJSON[FilenameConsistencyReport](t, raw, err)
+	if report.Renamed != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	parsed, err := readBatchJournal(journalPath)
+	if err != nil || !parsed.complete || !parsed.done[path] || parsed.header.Op != batchOpFixFilenames {
+		t.Fatalf("unexpected journal: %+v %v", parsed, err)
+	}
+}
@@ -0,0 +1,214 @@
+package gobackend
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Case styles for NormalizeCase.
+const (
+	CaseStyleTitle    = "title"
+	CaseStyleSentence = "sentence"
+)
+
+// titleCaseSmallWords stay lower case inside a title-cased phrase.
+var titleCaseSmallWords = map[string]bool{
+	"a": true, "an": true, "and": true, "as": true, "at": true, "but": true,
+	"by": true, "for": true, "from": true, "in": true, "nor": true, "of": true,
+	"on": true, "or": true, "the": true, "to": true, "via": true, "vs": true,
+	"vs.": true,
+}
+
+// featuringWords are lower case everywhere, including right after "(".
+var featuringWords = map[string]bool{"feat.": true, "feat": true, "ft.": true, "featuring": true}
+
+// defaultCaseExceptions are stylings that casing rules would otherwise
+// destroy. Matching is case-insensitive on whole words.
+var defaultCaseExceptions = []string{
+	"iPhone", "iPod", "iTunes", "AC/DC", "LCD Soundsystem", "DJ", "MC", "EP",
+	"LP", "UK", "USA", "TV", "II", "III", "IV",
+}
+
+var defaultCaseExceptionPatterns = compileCaseExceptions(defaultCaseExceptions)
+
+type caseException struct {
+	re   *regexp.Regexp
+	form string
+}
+
+func compileCaseExceptions(exceptions []string) []caseException {
+	compiled := make([]caseException, 0, len(exceptions))
+	for _, form := range exceptions {
+		form = strings.TrimSpace(form)
+		if form == "" {
+			continue
+		}
+		compiled = append(compiled, caseException{
+			re:   regexp.MustCompile(`(?i)(^|[^\pL\pN])` + regexp.QuoteMeta(form) + `($|[^\pL\pN])`),
+			form: form,
+		})
+	}
+	return compiled
+}
+
+func applyCaseExceptions(text string, exceptions []caseException) string {
+	for _, exc := range exceptions {
+		text = exc.re.ReplaceAllStringFunc(text, func(match string) string {
+			sub := exc.re.FindStringSubmatch(match)
+			return sub[1] + exc.form + sub[2]
+		})
+	}
+	return text
+}
+
+// usesTurkishCasing reports whether word contains letters only Turkish (and
+// Azerbaijani) use, where I/ı and İ/i are distinct pairs.
+func usesTurkishCasing(word string) bool {
+	return strings.ContainsAny(word, "İıŞşĞğ")
+}
+
+func lowerWord(word string) string {
+	if usesTurkishCasing(word) {
+		return strings.ToLowerSpecial(unicode.TurkishCase, word)
+	}
+	runes := []rune(strings.ToLower(word))
+	// Greek capital sigma lowers to the final form at the end of a word.
+	for i, r := range runes {
+		if r == 'σ' && i > 0 && unicode.IsLetter(runes[i-1]) && (i == len(runes)-1 || !unicode.IsLetter(runes[i+1])) {
+			runes[i] = 'ς'
+		}
+	}
+	return string(runes)
+}
+
+// capitalizeWord upper-cases the first letter of an already lower-cased
+// word, skipping leading punctuation such as "(" or quotes.
+func capitalizeWord(word, original string) string {
+	runes := []rune(word)
+	for i, r := range runes {
+		if unicode.IsLetter(r) {
+			if usesTurkishCasing(original) {
+				runes[i] = unicode.TurkishCase.ToTitle(r)
+			} else {
+				runes[i] = unicode.ToTitle(r)
+			}
+			break
+		}
+		if unicode.IsNumber(r) {
+			break
+		}
+	}
+	return string(runes)
+}
+
+// hasMixedCase reports whether word has both upper and lower case letters,
+// which marks an intentional styling such as "McCartney".
+func hasMixedCase(word string) bool {
+	hasUpper, hasLower := false, false
+	for _, r := range word {
+		hasUpper = hasUpper || unicode.IsUpper(r)
+		hasLower = hasLower || unicode.IsLower(r)
+	}
+	return hasUpper && hasLower
+}
+
+func isAllUpper(text string) bool {
+	hasUpper := false
+	for _, r := range text {
+		if unicode.IsLower(r) {
+			return false
+		}
+		hasUpper = hasUpper || unicode.IsUpper(r)
+	}
+	return hasUpper
+}
+
+func trimWordPunct(word string) string {
+	return strings.TrimFunc(word, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '.'
+	})
+}
+
+func normalizeCaseText(text, style string, exceptions []caseException) string {
+	if strings.TrimSpace(text) == "" {
+		return text
+	}
+	// In a shouting string every word is recased; otherwise all-caps words
+	// are taken to be acronyms and kept.
+	shouting := isAllUpper(text)
+
+	words := strings.Split(text, " ")
+	lastWord := -1
+	for i := len(words) - 1; i >= 0; i-- {
+		if words[i] != "" {
+			lastWord = i
+			break
+		}
+	}
+
+	phraseStart := true
+	for i, word := range words {
+		if word == "" {
+			continue
+		}
+		opensSubtitle := strings.HasPrefix(word, "(") || strings.HasPrefix(word, "[")
+		startsPhrase := phraseStart || (opensSubtitle && style == CaseStyleTitle)
+		endsPhrase := i == lastWord || strings.HasSuffix(word, ")") || strings.HasSuffix(word, "]")
+		phraseStart = strings.HasSuffix(word, ":") || word == "-" || word == "–" || word == "—"
+
+		if hasMixedCase(word) || (!shouting && isAllUpper(word) && len([]rune(trimWordPunct(word))) > 1) {
+			continue
+		}
+
+		bare := strings.ToLower(trimWordPunct(word))
+		lowered := lowerWord(word)
+		switch {
+		case featuringWords[bare]:
+			words[i] = lowered
+		case startsPhrase:
+			words[i] = capitalizeWord(lowered, word)
+		case style == CaseStyleSentence:
+			words[i] = lowered
+		case titleCaseSmallWords[bare] && !endsPhrase:
+			words[i] = lowered
+		default:
+			words[i] = capitalizeWord(lowered, word)
+		}
+	}
+	return applyCaseExceptions(strings.Join(words, " "), exceptions)
+}
+
+func validateCaseStyle(style string) error {
+	switch style {
+	case CaseStyleTitle, CaseStyleSentence:
+		return nil
+	}
+	return fmt.Errorf("unknown case style: %q", style)
+}
+
+// NormalizeCaseText recases a single string, for previewing NormalizeCase
+// in the UI. style is "title" or "sentence".
+func NormalizeCaseText(text, style string) (string, error) {
+	if err := validateCaseStyle(style); err != nil {
+		return "", err
+	}
+	return normalizeCaseText(text, style, defaultCaseExceptionPatterns), nil
+}
+
+// NormalizeCase recases the title, artist, album and album artist of
+// metadata. Title case keeps small words (a, an, the, of, feat. ...) lower
+// case except at the start of the string or of a parenthesized subtitle.
+// Words with mixed case and known stylings (iPhone, AC/DC) are kept, as are
+// all-caps acronyms unless the whole string is in capitals. Scripts without
+// case pass through unchanged.
+func NormalizeCase(metadata Metadata, style string) (Metadata, error) {
+	if err := validateCaseStyle(style); err != nil {
+		return metadata, err
+	}
+	for _, field := range []*string{&metadata.Title, &metadata.Artist, &metadata.Album, &metadata.AlbumArtist} {
+		*field = normalizeCaseText(*field, style, defaultCaseExceptionPatterns)
+	}
+	return metadata, nil
+}
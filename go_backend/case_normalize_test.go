@@ -0,0 +1,98 @@
+package gobackend
+
+import "testing"
+
+func TestNormalizeCaseTextTitleStyle(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"THE SOUND OF THE CITY", "The Sound of the City"},
+		{"a night at the opera", "A Night at the Opera"},
+		{"WHAT IT'S MADE OF", "What It's Made Of"},
+		{"SONG (FEAT. SOMEONE ELSE)", "Song (feat. Someone Else)"},
+		{"song (the remix)", "Song (The Remix)"},
+		{"intro: the beginning", "Intro: The Beginning"},
+		{"MY IPHONE IS BROKEN", "My iPhone Is Broken"},
+		{"ac/dc live at donington", "AC/DC Live at Donington"},
+		{"LCD SOUNDSYSTEM", "LCD Soundsystem"},
+		{"Song by McCartney", "Song by McCartney"},
+		{"live at the BBC", "Live at the BBC"},
+		{"part ii", "Part II"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeCaseText(tt.in, CaseStyleTitle)
+		if err != nil {
+			t.Fatalf("NormalizeCaseText(%q): %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Fatalf("NormalizeCaseText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCaseTextSentenceStyle(t *testing.T) {
+	got, err := NormalizeCaseText("THE SOUND OF SILENCE (LIVE VERSION)", CaseStyleSentence)
+	if err != nil {
+		t.Fatalf("NormalizeCaseText: %v", err)
+	}
+	if got != "The sound of silence (live version)" {
+		t.Fatalf("unexpected sentence case: %q", got)
+	}
+	if _, err := NormalizeCaseText("x", "shouty"); err == nil {
+		t.Fatal("expected unknown style error")
+	}
+}
+
+func TestNormalizeCaseTextUnicodeScripts(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		// Turkish: I/ı and İ/i must stay distinct pairs.
+		{"DİYARBAKIR GECELERİ", "Diyarbakır Geceleri"},
+		{"ıslak şarkı", "Islak Şarkı"},
+		// Greek final sigma.
+		{"ΤΟ ΤΕΛΟΣ ΤΟΥ ΚΟΣΜΟΥ", "Το Τελος Του Κοσμου"},
+		// Scripts without case pass through untouched.
+		{"東京の夜", "東京の夜"},
+		{"ПЕСНЯ О ЛЮБВИ", "Песня О Любви"},
+		{"أغنية الحب", "أغنية الحب"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeCaseText(tt.in, CaseStyleTitle)
+		if err != nil {
+			t.Fatalf("NormalizeCaseText(%q): %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Fatalf("NormalizeCaseText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCaseMetadataAndPresetRule(t *testing.T) {
+	got, err := NormalizeCase(Metadata{
+		Title:   "A DAY IN THE LIFE",
+		Artist:  "THE BEATLES",
+		Album:   "sgt. pepper's lonely hearts club band",
+		Comment: "LEAVE ME ALONE",
+	}, CaseStyleTitle)
+	if err != nil {
+		t.Fatalf("NormalizeCase: %v", err)
+	}
+	if got.Title != "A Day in the Life" || got.Artist != "The Beatles" ||
+		got.Album != "Sgt. Pepper's Lonely Hearts Club Band" || got.Comment != "LEAVE ME ALONE" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+
+	preset := `{"rules":[{"op":"normalize_case","field":"title","style":"title"}]}`
+	applied, err := ApplyPreset(Metadata{Title: "HELLO WORLD", Artist: "LOUD ARTIST"}, preset)
+	if err != nil {
+		t.Fatalf("ApplyPreset: %v", err)
+	}
+	if applied.Title != "Hello World" || applied.Artist != "LOUD ARTIST" {
+		t.Fatalf("normalize_case rule should touch only its field: %+v", applied)
+	}
+	if _, err := ApplyPreset(Metadata{}, `{"rules":[{"op":"normalize_case","field":"title","style":"wavy"}]}`); err == nil {
+		t.Fatal("expected invalid style to be rejected")
+	}
+}
@@ -0,0 +1,227 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// changeReportInlineLimit is the largest number of files whose change
+// report is returned inline with the batch result; larger reports are only
+// written to the report path.
+const changeReportInlineLimit = 200
+
+// FieldChange is one tag field whose values changed. Old is empty for an
+// added field and New for a removed one.
+type FieldChange struct {
+	Field string   `json:"field"`
+	Old   []string `json:"old,omitempty"`
+	New   []string `json:"new,omitempty"`
+}
+
+type RenameChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// CoverChange describes embedded pictures that were re-encoded or removed,
+// by count and size only.
+type CoverChange struct {
+	Reencoded   int   `json:"reencoded,omitempty"`
+	Removed     int   `json:"removed,omitempty"`
+	BytesBefore int64 `json:"bytes_before"`
+	BytesAfter  int64 `json:"bytes_after"`
+}
+
+type FileChangeEntry struct {
+	Path   string        `json:"path"`
+	Fields []FieldChange `json:"fields,omitempty"`
+	Rename *RenameChange `json:"rename,omitempty"`
+	Cover  *CoverChange  `json:"cover,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// ChangeReport lists what a batch operation changed (or would change, with
+// DryRun) per file, including the files that failed.
+type ChangeReport struct {
+	Operation   string            `json:"operation"`
+	Root        string            `json:"root"`
+	DryRun      bool              `json:"dry_run"`
+	GeneratedAt string            `json:"generated_at"`
+	Changed     int               `json:"changed"`
+	Failed      int               `json:"failed"`
+	Files       []FileChangeEntry `json:"files"`
+}
+
+// ChangeReportOutput is embedded in batch reports run with a change report.
+// ChangeReport is only filled for batches of up to changeReportInlineLimit
+// files; ChangeReportError is set when the report files could not be
+// written, which does not fail the batch itself.
+type ChangeReportOutput struct {
+	ChangeReport      *ChangeReport `json:"change_report,omitempty"`
+	ChangeReportPath  string        `json:"change_report_path,omitempty"`
+	ChangeReportError string        `json:"change_report_error,omitempty"`
+}
+
+// changeReport collects the entries of a ChangeReport during a batch. A nil
+// *changeReport records nothing, so batch loops call it unconditionally.
+type changeReport struct {
+	report ChangeReport
+}
+
+func newChangeReport(operation, root string, dryRun bool) *changeReport {
+	return &changeReport{report: ChangeReport{Operation: operation, Root: root, DryRun: dryRun, Files: []FileChangeEntry{}}}
+}
+
+func (r *changeReport) add(entry FileChangeEntry) {
+	if r == nil {
+		return
+	}
+	if entry.Error != "" {
+		r.report.Failed++
+	} else if len(entry.Fields) == 0 && entry.Rename == nil && entry.Cover == nil {
+		return
+	} else {
+		r.report.Changed++
+	}
+	r.report.Files = append(r.report.Files, entry)
+}
+
+func (r *changeReport) failed(path string, err error) {
+	r.add(FileChangeEntry{Path: path, Error: err.Error()})
+}
+
+// finish writes the report to reportPath, if given, and returns what the
+// batch result embeds.
+func (r *changeReport) finish(reportPath string) ChangeReportOutput {
+	var out ChangeReportOutput
+	if r == nil {
+		return out
+	}
+	sort.SliceStable(r.report.Files, func(i, j int) bool { return r.report.Files[i].Path < r.report.Files[j].Path })
+	r.report.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
+	if len(r.report.Files) <= changeReportInlineLimit {
+		out.ChangeReport = &r.report
+	}
+	if strings.TrimSpace(reportPath) != "" {
+		if err := writeChangeReport(reportPath, &r.report); err != nil {
+			GoLog("[ChangeReport] Failed to write %s: %v\n", reportPath, err)
+			out.ChangeReportError = err.Error()
+		} else {
+			out.ChangeReportPath = reportPath
+		}
+	}
+	return out
+}
+
+// changeReportTextPath is where the readable copy of the report at
+// reportPath goes: its ".json" extension replaced by ".txt", or ".txt"
+// appended.
+func changeReportTextPath(reportPath string) string {
+	if strings.EqualFold(filepath.Ext(reportPath), ".json") {
+		return strings.TrimSuffix(reportPath, filepath.Ext(reportPath)) + ".txt"
+	}
+	return reportPath + ".txt"
+}
+
+func writeReportFile(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// writeChangeReport writes report as JSON to reportPath and as text next to
+// it (see changeReportTextPath).
+func writeChangeReport(reportPath string, report *ChangeReport) error {
+	if dir := filepath.Dir(reportPath); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeReportFile(reportPath, data); err != nil {
+		return err
+	}
+	return writeReportFile(changeReportTextPath(reportPath), []byte(formatChangeReport(report)))
+}
+
+func formatChangeValues(values []string) string {
+	if len(values) == 0 {
+		return "(none)"
+	}
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("%q", value)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// formatChangeReport renders report for reading: a summary line, then each
+// file with one indented line per change.
+func formatChangeReport(report *ChangeReport) string {
+	var b strings.Builder
+	mode := ""
+	if report.DryRun {
+		mode = " (dry run)"
+	}
+	fmt.Fprintf(&b, "%s%s under %s at %s\n", report.Operation, mode, report.Root, report.GeneratedAt)
+	fmt.Fprintf(&b, "%d changed, %d failed\n", report.Changed, report.Failed)
+	for _, file := range report.Files {
+		fmt.Fprintf(&b, "\n%s\n", file.Path)
+		if file.Error != "" {
+			fmt.Fprintf(&b, "  error: %s\n", file.Error)
+		}
+		if file.Rename != nil {
+			fmt.Fprintf(&b, "  renamed: %s -> %s\n", file.Rename.From, file.Rename.To)
+		}
+		for _, field := range file.Fields {
+			fmt.Fprintf(&b, "  %s: %s -> %s\n", field.Field, formatChangeValues(field.Old), formatChangeValues(field.New))
+		}
+		if cover := file.Cover; cover != nil {
+			fmt.Fprintf(&b, "  cover: %d re-encoded, %d removed, %d -> %d bytes\n",
+				cover.Reencoded, cover.Removed, cover.BytesBefore, cover.BytesAfter)
+		}
+	}
+	return b.String()
+}
+
+// diffCommentFields compares two Vorbis comment lists by upper-cased key.
+func diffCommentFields(before, after []string) []FieldChange {
+	group := func(comments []string) map[string][]string {
+		fields := make(map[string][]string)
+		for _, comment := range comments {
+			key, value := splitVorbisComment(comment)
+			key = strings.ToUpper(key)
+			fields[key] = append(fields[key], value)
+		}
+		return fields
+	}
+	old, updated := group(before), group(after)
+	var changes []FieldChange
+	for key, values := range updated {
+		if !slices.Equal(values, old[key]) {
+			changes = append(changes, FieldChange{Field: key, Old: old[key], New: values})
+		}
+	}
+	for key, values := range old {
+		if _, ok := updated[key]; !ok {
+			changes = append(changes, FieldChange{Field: key, Old: values})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
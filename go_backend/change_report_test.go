@@ -0,0 +1,106 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalizeLibraryTagsWithReport(t *testing.T) {
+	root := t.TempDir()
+	aliased := writeAliasedFLAC(t, "YEAR=2020")
+	if err := os.Rename(aliased, filepath.Join(root, "a.flac")); err != nil {
+		t.Fatalf("move fixture: %v", err)
+	}
+	writeConsistencyFixture(t, root, "b.flac", Metadata{Title: "Clean"})
+	if err := os.WriteFile(filepath.Join(root, "broken.flac"), []byte("not a flac"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	reportPath := filepath.Join(t.TempDir(), "reports", "tags.json")
+	raw, err := CanonicalizeLibraryTagsWithReport(root, "", false, reportPath)
+	report := mustDecodeJSON[TagCanonicalizationReport](t, raw, err)
+	changes := report.ChangeReport
+	if changes == nil || report.ChangeReportPath != reportPath || report.ChangeReportError != "" {
+		t.Fatalf("expected inline change report and path: %+v", report.ChangeReportOutput)
+	}
+	if changes.Changed != 1 || changes.Failed != 1 || len(changes.Files) != 2 {
+		t.Fatalf("unexpected change report: %+v", changes)
+	}
+	changed := changes.Files[0]
+	want := []FieldChange{{Field: "DATE", New: []string{"2020"}}, {Field: "YEAR", Old: []string{"2020"}}}
+	if changed.Path != filepath.Join(root, "a.flac") || len(changed.Fields) != 2 ||
+		changed.Fields[0].Field != want[0].Field || !slices.Equal(changed.Fields[0].New, want[0].New) ||
+		changed.Fields[1].Field != want[1].Field || !slices.Equal(changed.Fields[1].Old, want[1].Old) {
+		t.Fatalf("unexpected field changes: %+v", changed)
+	}
+	if changes.Files[1].Error == "" {
+		t.Fatalf("expected the broken file to be reported as failed: %+v", changes.Files[1])
+	}
+
+	var written ChangeReport
+	if err := json.Unmarshal(mustReadFile(t, reportPath), &written); err != nil {
+		t.Fatalf("decode written report: %v", err)
+	}
+	if written.Operation != batchOpCanonicalizeTags || len(written.Files) != 2 {
+		t.Fatalf("unexpected written report: %+v", written)
+	}
+	text := string(mustReadFile(t, filepath.Join(filepath.Dir(reportPath), "tags.txt")))
+	for _, line := range []string{"1 changed, 1 failed", `DATE: (none) -> "2020"`, "error:"} {
+		if !strings.Contains(text, line) {
+			t.Fatalf("text report lacks %q:\n%s", line, text)
+		}
+	}
+}
+
+func TestFixFilenameConsistencyWithReport(t *testing.T) {
+	root := t.TempDir()
+	writeConsistencyFixture(t, root, "old name.flac", Metadata{Artist: "Artist", Title: "Song"})
+
+	// A report that cannot be written does not fail the batch.
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	raw, err := FixFilenameConsistencyWithReport(root, "{artist} - {title}", false, filepath.Join(blocker, "report.json"))
+	report := mustDecodeJSON[FilenameConsistencyReport](t, raw, err)
+	if report.ChangeReportError == "" || report.ChangeReportPath != "" || report.ChangeReport == nil {
+		t.Fatalf("expected report error with inline report: %+v", report.ChangeReportOutput)
+	}
+	entry := report.ChangeReport.Files[0]
+	if !report.ChangeReport.DryRun || entry.Rename == nil || entry.Rename.From != "old name.flac" || entry.Rename.To != "Artist - Song.flac" {
+		t.Fatalf("unexpected rename entry: %+v", report.ChangeReport)
+	}
+
+	raw, err = FixFilenameConsistency(root, "{artist} - {title}", false)
+	if report = mustDecodeJSON[FilenameConsistencyReport](t, raw, err); report.ChangeReport != nil {
+		t.Fatalf("plain run must not report changes: %+v", report.ChangeReportOutput)
+	}
+}
+
+func TestChangeReportInlineLimit(t *testing.T) {
+	changes := newChangeReport("test", "/music", false)
+	for i := range changeReportInlineLimit + 1 {
+		changes.add(FileChangeEntry{Path: filepath.Join("/music", strings.Repeat("a", i+1)), Fields: []FieldChange{{Field: "TITLE"}}})
+	}
+	changes.add(FileChangeEntry{Path: "/music/unchanged.flac"})
+	reportPath := filepath.Join(t.TempDir(), "report")
+	out := changes.finish(reportPath)
+	if out.ChangeReport != nil || out.ChangeReportPath != reportPath {
+		t.Fatalf("large report must only be written: %+v", out)
+	}
+	if _, err := os.Stat(reportPath + ".txt"); err != nil {
+		t.Fatalf("text report: %v", err)
+	}
+	if changes.report.Changed != changeReportInlineLimit+1 {
+		t.Fatalf("Changed = %d", changes.report.Changed)
+	}
+	var none *changeReport
+	none.add(FileChangeEntry{Path: "x", Error: "failed"})
+	if out := none.finish(reportPath); out.ChangeReport != nil || out.ChangeReportPath != "" {
+		t.Fatalf("nil report must record nothing: %+v", out)
+	}
+}
@@ -0,0 +1,68 @@
+package gobackend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// changeTokenVersion prefixes every token so a later scheme never matches
+// an older token by accident.
+const changeTokenVersion = "ct1"
+
+// changeTokenHeadSize bounds what is hashed of files other than FLAC, whose
+// tags normally sit at the start of the file.
+const changeTokenHeadSize = 64 << 10
+
+// GetChangeToken returns an opaque token for the current state of filePath,
+// built from its size, its modification time in milliseconds and a SHA-256
+// of its metadata region: everything before the first audio frame of a
+// FLAC file, or the first 64 KB of other formats. The audio is never read,
+// so tokens are cheap for large files.
+//
+// Tokens are plain ASCII strings that mean the same on every platform and
+// are meant to be stored and compared for equality only; a tag edit always
+// changes the token, while reading the file never does.
+func GetChangeToken(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	regionSize := min(info.Size(), changeTokenHeadSize)
+	if layout, err := scanFLACMetadataBlocks(f, info.Size()); err == nil {
+		regionSize = layout.AudioOffset
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	if _, err := io.CopyN(hash, f, regionSize); err != nil {
+		return "", fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	return fmt.Sprintf("%s-%x-%x-%s", changeTokenVersion, info.Size(), info.ModTime().UnixMilli(),
+		hex.EncodeToString(hash.Sum(nil)[:16])), nil
+}
+
+// HasChanged reports whether filePath differs from the state token was
+// taken from. A token from another scheme version counts as changed.
+func HasChanged(filePath, token string) (bool, error) {
+	current, err := GetChangeToken(filePath)
+	if err != nil {
+		return false, err
+	}
+	if !strings.HasPrefix(token, changeTokenVersion+"-") {
+		return true, nil
+	}
+	return current != token, nil
+}
@@ -0,0 +1,59 @@
+package gobackend
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestChangeTokenTracksMetadataEdits(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	token, err := GetChangeToken(path)
+	if err != nil {
+		t.Fatalf("GetChangeToken: %v", err)
+	}
+
+	if _, err := ReadMetadata(path); err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if changed, err := HasChanged(path, token); err != nil || changed {
+		t.Fatalf("reading changed the token: %v %v", changed, err)
+	}
+
+	// Restore the mtime so only the metadata hash can tell the edit apart.
+	info, _ := os.Stat(path)
+	if err := EmbedLyrics(path, "la la"); err != nil {
+		t.Fatalf("EmbedLyrics: %v", err)
+	}
+	if err := os.Chtimes(path, time.Now(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := HasChanged(path, token); err != nil || !changed {
+		t.Fatalf("metadata edit not detected: %v %v", changed, err)
+	}
+
+	if changed, _ := HasChanged(path, "ct0-whatever"); !changed {
+		t.Fatal("a token from another version should count as changed")
+	}
+}
+
+func TestChangeTokenIgnoresAudioBytes(t *testing.T) {
+	path := writeVerifyFixture(t, nil)
+	token, err := GetChangeToken(path)
+	if err != nil {
+		t.Fatalf("GetChangeToken: %v", err)
+	}
+	info, _ := os.Stat(path)
+
+	data := mustReadFile(t, path)
+	data[len(data)-10] ^= 0xFF
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, time.Now(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := HasChanged(path, token); changed {
+		t.Fatal("audio bytes should not be hashed")
+	}
+}
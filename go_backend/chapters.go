@@ -0,0 +1,253 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+// Chapter sources reported in ChapterList.Source.
+const (
+	ChapterSourceComments  = "comments"
+	ChapterSourceCueFile   = "cue_file"
+	ChapterSourceCueSheet  = "cuesheet"
+	maxChapterCommentIndex = 999
+)
+
+// chapterKeyPattern matches the keys of the Vorbis chapter convention:
+// CHAPTER001=00:00:00.000 holds a start time and CHAPTER001NAME=Intro its
+// title.
+var chapterKeyPattern = regexp.MustCompile(`^CHAPTER(\d{3})(NAME)?$`)
+
+// Chapter is one navigation point of a long file such as a DJ mix.
+type Chapter struct {
+	StartMs int64  `json:"start_ms"`
+	Title   string `json:"title"`
+}
+
+// ChapterList is the result of ParseChapters. Source is empty when the
+// file has no chapter information.
+type ChapterList struct {
+	Source   string    `json:"source"`
+	Chapters []Chapter `json:"chapters"`
+}
+
+// parseChapterTime parses a chapter start as HH:MM:SS.sss, also accepting
+// MM:SS.sss and plain seconds.
+func parseChapterTime(value string) (int64, bool) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) > 3 {
+		return 0, false
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	var whole int64
+	for _, part := range parts[:len(parts)-1] {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		whole = whole*60 + n
+	}
+	return whole*60*1000 + int64(seconds*1000+0.5), true
+}
+
+func formatChapterTime(ms int64) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// chaptersFromComments reads the CHAPTERxxx convention. Chapters without a
+// valid start time are dropped; a missing name leaves the title empty.
+func chaptersFromComments(comments *vorbisCommentMap) []Chapter {
+	starts := make(map[string]int64)
+	names := make(map[string]string)
+	for _, raw := range comments.comments() {
+		key, value := splitVorbisComment(raw)
+		match := chapterKeyPattern.FindStringSubmatch(strings.ToUpper(key))
+		if match == nil {
+			continue
+		}
+		if match[2] != "" {
+			names[match[1]] = value
+		} else if ms, ok := parseChapterTime(value); ok {
+			starts[match[1]] = ms
+		}
+	}
+
+	chapters := make([]Chapter, 0, len(starts))
+	for number, start := range starts {
+		chapters = append(chapters, Chapter{StartMs: start, Title: names[number]})
+	}
+	sortChapters(chapters)
+	return chapters
+}
+
+func sortChapters(chapters []Chapter) {
+	sort.SliceStable(chapters, func(i, j int) bool { return chapters[i].StartMs < chapters[j].StartMs })
+}
+
+// chaptersFromCueSheet turns the tracks of an embedded CUESHEET into
+// chapters. The block carries no titles, so tracks are named by number.
+func chaptersFromCueSheet(sheet *EmbeddedCueSheet) []Chapter {
+	chapters := make([]Chapter, 0, len(sheet.Tracks))
+	for _, track := range sheet.Tracks {
+		chapters = append(chapters, Chapter{
+			StartMs: int64(track.StartTime*1000 + 0.5),
+			Title:   fmt.Sprintf("Track %02d", track.Number),
+		})
+	}
+	return chapters
+}
+
+// sidecarCuePath returns the .cue file next to filePath, named either
+// "mix.cue" or "mix.flac.cue", or "" when there is none.
+func sidecarCuePath(filePath string) string {
+	base := strings.TrimSuffix(filePath, filepath.Ext(filePath))
+	for _, candidate := range []string{base + ".cue", filePath + ".cue"} {
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() {
+			return candidate
+		}
+	}
+	return ""
+}
+
+func chaptersFromCueFile(cuePath string) ([]Chapter, error) {
+	sheet, err := ParseCueFile(cuePath)
+	if err != nil {
+		return nil, err
+	}
+	chapters := make([]Chapter, 0, len(sheet.Tracks))
+	for _, track := range sheet.Tracks {
+		title := track.Title
+		if track.Performer != "" && track.Performer != sheet.Performer {
+			title = track.Performer + " - " + title
+		}
+		chapters = append(chapters, Chapter{StartMs: int64(track.StartTime*1000 + 0.5), Title: title})
+	}
+	sortChapters(chapters)
+	return chapters, nil
+}
+
+// readChapters looks for chapters in the CHAPTERxxx comments, then in a
+// sidecar .cue, then in the embedded CUESHEET. The sidecar comes before the
+// CUESHEET because it has track titles and the block does not.
+func readChapters(filePath string) (*ChapterList, error) {
+	f, err := flac.ParseFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+	defer f.Close()
+
+	var sampleRate int
+	var cueData []byte
+	comments := newVorbisCommentMap(nil)
+	for _, meta := range f.Meta {
+		switch meta.Type {
+		case flac.StreamInfo:
+			_, sampleRate, _ = parseFLACStreamInfoQuality(meta.Data)
+		case flac.CueSheet:
+			if cueData == nil {
+				cueData = meta.Data
+			}
+		case flac.VorbisComment:
+			if cmt, err := parseVorbisCommentBlock(*meta); err == nil {
+				comments = newVorbisCommentMap(cmt.Comments)
+			}
+		}
+	}
+
+	if chapters := chaptersFromComments(comments); len(chapters) > 0 {
+		return &ChapterList{Source: ChapterSourceComments, Chapters: chapters}, nil
+	}
+	if cuePath := sidecarCuePath(filePath); cuePath != "" {
+		chapters, err := chaptersFromCueFile(cuePath)
+		if err != nil {
+			GoLog("[Chapters] Ignoring unreadable %s: %v\n", cuePath, err)
+		} else if len(chapters) > 0 {
+			return &ChapterList{Source: ChapterSourceCueFile, Chapters: chapters}, nil
+		}
+	}
+	if cueData != nil {
+		sheet, err := parseFLACCueSheet(cueData, sampleRate)
+		if err != nil {
+			GoLog("[Chapters] Ignoring broken CUESHEET in %s: %v\n", filePath, err)
+		} else if len(sheet.Tracks) > 0 {
+			return &ChapterList{Source: ChapterSourceCueSheet, Chapters: chaptersFromCueSheet(sheet)}, nil
+		}
+	}
+	return &ChapterList{Chapters: []Chapter{}}, nil
+}
+
+// ParseChapters returns the chapters of a long single-file FLAC, such as a
+// DJ mix, as JSON sorted by start time. They come from CHAPTERxxx comments,
+// a sidecar .cue, or the embedded CUESHEET, in that order of preference.
+func ParseChapters(filePath string) (string, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, false, func(localPath string) (string, error) {
+			return ParseChapters(localPath)
+		})
+	}
+	list, err := readChapters(filePath)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// WriteChapterComments stores chapters as CHAPTERxxx and CHAPTERxxxNAME
+// comments, numbered from 001 in start order, replacing any chapters
+// already in the file. An empty list removes them. Players that do not
+// know the convention ignore the extra comments.
+func WriteChapterComments(filePath string, chapters []Chapter) error {
+	if isOpenerPath(filePath) {
+		return viaFileOpenerErr(filePath, true, func(localPath string) error {
+			return WriteChapterComments(localPath, chapters)
+		})
+	}
+	if err := checkWriteAllowed(filePath); err != nil {
+		return err
+	}
+	if len(chapters) > maxChapterCommentIndex {
+		return fmt.Errorf("too many chapters: %d (max %d)", len(chapters), maxChapterCommentIndex)
+	}
+	sorted := make([]Chapter, len(chapters))
+	copy(sorted, chapters)
+	for _, chapter := range sorted {
+		if chapter.StartMs < 0 {
+			return fmt.Errorf("chapter %q has a negative start", chapter.Title)
+		}
+	}
+	sortChapters(sorted)
+
+	return editVorbisCommentList(filePath, "write_chapters", func(raw []string) ([]string, bool) {
+		kept := make([]string, 0, len(raw)+len(sorted)*2)
+		for _, comment := range raw {
+			key, _ := splitVorbisComment(comment)
+			if chapterKeyPattern.MatchString(strings.ToUpper(key)) {
+				continue
+			}
+			kept = append(kept, comment)
+		}
+		for i, chapter := range sorted {
+			number := fmt.Sprintf("CHAPTER%03d", i+1)
+			kept = append(kept, number+"="+formatChapterTime(chapter.StartMs))
+			if chapter.Title != "" {
+				kept = append(kept, number+"NAME="+multilineTagValue(chapter.Title))
+			}
+		}
+		return kept, true
+	})
+}
@@ -0,0 +1,120 @@
+package gobackend
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func parseChaptersForTest(t *testing.T, path string) ChapterList {
+	t.Helper()
+	raw, err := ParseChapters(path)
+	list := mustDecodeJSON[ChapterList](t, raw, err)
+	return list
+}
+
+func TestParseChapterTime(t *testing.T) {
+	cases := map[string]int64{
+		"00:00:00.000": 0,
+		"01:02:03.456": 3723456,
+		"12:30.5":      750500,
+		"90":           90000,
+	}
+	for value, want := range cases {
+		if got, ok := parseChapterTime(value); !ok || got != want {
+			t.Fatalf("parseChapterTime(%q) = %d, %v, want %d", value, got, ok, want)
+		}
+	}
+	for _, value := range []string{"", "ab:cd", "1:2:3:4", "-5"} {
+		if _, ok := parseChapterTime(value); ok {
+			t.Fatalf("parseChapterTime(%q) accepted", value)
+		}
+	}
+	if got := formatChapterTime(3723456); got != "01:02:03.456" {
+		t.Fatalf("formatChapterTime = %q", got)
+	}
+}
+
+func TestWriteChapterCommentsRoundTrip(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Mix", Artist: "DJ"})
+	if list := parseChaptersForTest(t, path); list.Source != "" || len(list.Chapters) != 0 {
+		t.Fatalf("expected no chapters, got %+v", list)
+	}
+
+	chapters := []Chapter{
+		{StartMs: 754250, Title: "Second"},
+		{StartMs: 0, Title: "Intro"},
+		{StartMs: 3723456, Title: ""},
+	}
+	if err := WriteChapterComments(path, chapters); err != nil {
+		t.Fatalf("WriteChapterComments: %v", err)
+	}
+	comments, err := readVorbisCommentList(path)
+	if err != nil {
+		t.Fatalf("readVorbisCommentList: %v", err)
+	}
+	joined := strings.Join(comments, "\n")
+	for _, want := range []string{"CHAPTER001=00:00:00.000", "CHAPTER001NAME=Intro", "CHAPTER002=00:12:34.250", "CHAPTER003=01:02:03.456", "TITLE=Mix"} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("missing %q in %q", want, joined)
+		}
+	}
+	if strings.Contains(joined, "CHAPTER003NAME") {
+		t.Fatalf("empty title written: %q", joined)
+	}
+
+	list := parseChaptersForTest(t, path)
+	if list.Source != ChapterSourceComments || len(list.Chapters) != 3 {
+		t.Fatalf("unexpected chapters: %+v", list)
+	}
+	if list.Chapters[0] != (Chapter{StartMs: 0, Title: "Intro"}) || list.Chapters[1] != (Chapter{StartMs: 754250, Title: "Second"}) {
+		t.Fatalf("unexpected chapters: %+v", list.Chapters)
+	}
+
+	// A shorter list replaces every old chapter; an empty one removes them.
+	if err := WriteChapterCommentsJSON(path, `[{"start_ms":1000,"title":"Only"}]`); err != nil {
+		t.Fatalf("WriteChapterCommentsJSON: %v", err)
+	}
+	if list := parseChaptersForTest(t, path); len(list.Chapters) != 1 || list.Chapters[0].Title != "Only" {
+		t.Fatalf("unexpected chapters after replace: %+v", list)
+	}
+	if err := WriteChapterComments(path, nil); err != nil {
+		t.Fatalf("WriteChapterComments: %v", err)
+	}
+	if list := parseChaptersForTest(t, path); len(list.Chapters) != 0 {
+		t.Fatalf("chapters left after clearing: %+v", list)
+	}
+
+	if err := WriteChapterComments(path, []Chapter{{StartMs: -1}}); err == nil {
+		t.Fatalf("expected negative start to be rejected")
+	}
+	if err := WriteChapterCommentsJSON(path, `{"start_ms":0}`); err == nil {
+		t.Fatalf("expected a non-array chapters JSON to be rejected")
+	}
+}
+
+func TestParseChaptersFromCueSources(t *testing.T) {
+	path := writeCueSheetFLAC(t, buildTestCueSheetBlock("", testCueSheetTracks()...))
+
+	list := parseChaptersForTest(t, path)
+	if list.Source != ChapterSourceCueSheet || len(list.Chapters) != 2 {
+		t.Fatalf("unexpected chapters: %+v", list)
+	}
+	if list.Chapters[0] != (Chapter{StartMs: 0, Title: "Track 01"}) || list.Chapters[1] != (Chapter{StartMs: 12000, Title: "Track 02"}) {
+		t.Fatalf("unexpected CUESHEET chapters: %+v", list.Chapters)
+	}
+
+	cue := "PERFORMER \"DJ\"\nTITLE \"Mix\"\nFILE \"album.flac\" WAVE\n" +
+		"  TRACK 01 AUDIO\n    TITLE \"Opening\"\n    INDEX 01 00:00:00\n" +
+		"  TRACK 02 AUDIO\n    TITLE \"Peak\"\n    PERFORMER \"Guest\"\n    INDEX 01 01:30:00\n"
+	if err := os.WriteFile(strings.TrimSuffix(path, ".flac")+".cue", []byte(cue), 0644); err != nil {
+		t.Fatalf("write cue: %v", err)
+	}
+	list = parseChaptersForTest(t, path)
+	if list.Source != ChapterSourceCueFile || len(list.Chapters) != 2 {
+		t.Fatalf("unexpected chapters: %+v", list)
+	}
+	if list.Chapters[0] != (Chapter{StartMs: 0, Title: "Opening"}) || list.Chapters[1] != (Chapter{StartMs: 90000, Title: "Guest - Peak"}) {
+		t.Fatalf("unexpected sidecar chapters: %+v", list.Chapters)
+	}
+}
@@ -0,0 +1,380 @@
+package gobackend
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const checksumManifestVersion = 1
+
+// Kinds of ChecksumEntry hashes.
+const (
+	// ChecksumAudio hashes the audio frames of a FLAC file, as AudioHash
+	// does, so re-tagging does not change it.
+	ChecksumAudio = "audio"
+	// ChecksumFile hashes a whole file of a format without a
+	// metadata-independent hash.
+	ChecksumFile = "file"
+)
+
+// ChecksumEntry is one file of a checksum manifest. Path is relative to the
+// library root with forward slashes; Size is the number of bytes hashed.
+type ChecksumEntry struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	Hash string `json:"hash"`
+	Kind string `json:"kind"`
+}
+
+type ChecksumManifest struct {
+	Version     int             `json:"version"`
+	GeneratedAt string          `json:"generated_at"`
+	Files       []ChecksumEntry `json:"files"`
+}
+
+// ChecksumManifestSummary is the result of WriteChecksumManifest.
+type ChecksumManifestSummary struct {
+	Root     string `json:"root"`
+	Manifest string `json:"manifest"`
+	Files    int    `json:"files"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// ChecksumMismatch is a manifest file whose content no longer matches.
+type ChecksumMismatch struct {
+	Path         string `json:"path"`
+	ExpectedHash string `json:"expected_hash"`
+	ActualHash   string `json:"actual_hash,omitempty"`
+	ExpectedSize int64  `json:"expected_size"`
+	ActualSize   int64  `json:"actual_size,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// ChecksumVerifyReport is the result of VerifyChecksumManifest. A cancelled
+// verification reports what was checked so far; Unchecked counts the
+// manifest files it did not get to.
+type ChecksumVerifyReport struct {
+	Root      string             `json:"root"`
+	Manifest  string             `json:"manifest"`
+	Expected  int                `json:"expected"`
+	Verified  int                `json:"verified"`
+	Unchecked int                `json:"unchecked"`
+	Cancelled bool               `json:"cancelled"`
+	Missing   []string           `json:"missing"`
+	Added     []string           `json:"added"`
+	Corrupted []ChecksumMismatch `json:"corrupted"`
+}
+
+var (
+	checksumCancel   context.CancelFunc
+	checksumCancelMu sync.Mutex
+
+	checksumProgress batchProgress
+)
+
+// GetChecksumManifestProgress reports the running WriteChecksumManifest or
+// VerifyChecksumManifest.
+func GetChecksumManifestProgress() string {
+	return checksumProgress.json()
+}
+
+// CancelChecksumManifest stops a running WriteChecksumManifest, which then
+// writes nothing, or VerifyChecksumManifest, which returns a partial report.
+func CancelChecksumManifest() {
+	checksumCancelMu.Lock()
+	defer checksumCancelMu.Unlock()
+	if checksumCancel != nil {
+		checksumCancel()
+		checksumCancel = nil
+	}
+}
+
+func startChecksumRun() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	checksumCancelMu.Lock()
+	if checksumCancel != nil {
+		checksumCancel()
+	}
+	checksumCancel = cancel
+	checksumCancelMu.Unlock()
+	return ctx, cancel
+}
+
+// contextReader fails reads once ctx is done, so a long hash stops between
+// chunks when cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// hashAudioContent streams the audio of filePath through SHA-256 without
+// loading it: the frames of a FLAC file, or the whole file otherwise.
+func hashAudioContent(ctx context.Context, filePath string) (ChecksumEntry, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return ChecksumEntry{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return ChecksumEntry{}, err
+	}
+
+	entry := ChecksumEntry{Kind: ChecksumFile}
+	if strings.EqualFold(filepath.Ext(filePath), ".flac") {
+		layout, err := scanFLACMetadataBlocks(f, info.Size())
+		if err != nil {
+			return ChecksumEntry{}, err
+		}
+		if _, err := f.Seek(layout.AudioOffset, io.SeekStart); err != nil {
+			return ChecksumEntry{}, err
+		}
+		entry.Kind = ChecksumAudio
+	}
+
+	hash := sha256.New()
+	n, err := io.CopyBuffer(hash, contextReader{ctx: ctx, r: f}, make([]byte, flacRewriteChunkSize))
+	if err != nil {
+		return ChecksumEntry{}, err
+	}
+	entry.Size, entry.Hash = n, hex.EncodeToString(hash.Sum(nil))
+	return entry, nil
+}
+
+// hashChecksumFiles hashes relPaths under rootPath with
+// MaxConcurrentOperations workers, reporting to progress. On cancellation
+// the entries not reached are left with an empty Kind and ctx.Err() is
+// returned.
+func hashChecksumFiles(ctx context.Context, rootPath string, relPaths []string, progress *batchProgress) ([]ChecksumEntry, []error, error) {
+	entries := make([]ChecksumEntry, len(relPaths))
+	errs := make([]error, len(relPaths))
+	workers := min(GetBackendConfig().MaxConcurrentOperations, max(len(relPaths), 1))
+	paths := make([]string, len(relPaths))
+	for i, rel := range relPaths {
+		paths[i] = filepath.Join(rootPath, filepath.FromSlash(rel))
+	}
+	sizes := fileSizes(paths)
+	progress.start(sizes, workers)
+	defer progress.finish()
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				done := progress.item(paths[i], sizes[i])
+				entry, err := hashAudioContent(ctx, paths[i])
+				if ctx.Err() != nil {
+					continue
+				}
+				done()
+				entry.Path = relPaths[i]
+				entries[i], errs[i] = entry, err
+				if err != nil {
+					entries[i].Kind = ""
+				}
+			}
+		}()
+	}
+dispatch:
+	for i := range relPaths {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return entries, errs, ctx.Err()
+}
+
+// checksumLibraryFiles lists the audio files under rootPath relative to it,
+// sorted, with forward slashes.
+func checksumLibraryFiles(rootPath string) ([]string, error) {
+	if strings.TrimSpace(rootPath) == "" {
+		return nil, fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(rootPath); err != nil {
+		return nil, fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+	files, err := collectLibraryAudioFiles(rootPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	relPaths := make([]string, 0, len(files))
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file.path), ".cue") {
+			continue
+		}
+		rel, err := filepath.Rel(rootPath, file.path)
+		if err != nil {
+			return nil, err
+		}
+		relPaths = append(relPaths, filepath.ToSlash(rel))
+	}
+	sort.Strings(relPaths)
+	return relPaths, nil
+}
+
+// WriteChecksumManifest hashes every audio file under rootPath and writes
+// the hashes and sizes as a JSON manifest to outPath, to be checked with
+// VerifyChecksumManifest after copying the library. FLAC files are hashed
+// by audio content only, so later tag edits do not count as corruption; a
+// file that cannot be read fails the whole manifest.
+func WriteChecksumManifest(rootPath, outPath string) (string, error) {
+	relPaths, err := checksumLibraryFiles(rootPath)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := startChecksumRun()
+	defer cancel()
+
+	entries, errs, err := hashChecksumFiles(ctx, rootPath, relPaths, &checksumProgress)
+	if err != nil {
+		return "", fmt.Errorf("checksum manifest cancelled: %w", err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			return "", fmt.Errorf("failed to hash %s: %w", relPaths[i], err)
+		}
+	}
+
+	manifest := ChecksumManifest{
+		Version:     checksumManifestVersion,
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Files:       entries,
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := outPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, outPath); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to write manifest: %w", err)
+	}
+	summary := ChecksumManifestSummary{Root: rootPath, Manifest: outPath, Files: len(entries)}
+	for _, entry := range entries {
+		summary.Bytes += entry.Size
+	}
+	GoLog("[Checksum] Wrote %d file hashes for %s to %s\n", summary.Files, rootPath, outPath)
+
+	jsonBytes, err := json.Marshal(summary)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// VerifyChecksumManifest re-hashes the files listed in the manifest at
+// manifestPath under rootPath and reports, as JSON, the files that are
+// missing, the audio files not in the manifest, and the files whose hash or
+// size changed. CancelChecksumManifest stops it with a partial report.
+func VerifyChecksumManifest(rootPath, manifestPath string) (string, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest ChecksumManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.Version != checksumManifestVersion {
+		return "", fmt.Errorf("unsupported manifest version %d", manifest.Version)
+	}
+	relPaths, err := checksumLibraryFiles(rootPath)
+	if err != nil {
+		return "", err
+	}
+
+	report := ChecksumVerifyReport{
+		Root:      rootPath,
+		Manifest:  manifestPath,
+		Expected:  len(manifest.Files),
+		Missing:   []string{},
+		Added:     []string{},
+		Corrupted: []ChecksumMismatch{},
+	}
+	onDisk := make(map[string]bool, len(relPaths))
+	for _, rel := range relPaths {
+		onDisk[rel] = true
+	}
+	expected := make(map[string]ChecksumEntry, len(manifest.Files))
+	var present []string
+	for _, entry := range manifest.Files {
+		expected[entry.Path] = entry
+		if onDisk[entry.Path] {
+			present = append(present, entry.Path)
+		} else {
+			report.Missing = append(report.Missing, entry.Path)
+		}
+	}
+	for _, rel := range relPaths {
+		if _, ok := expected[rel]; !ok {
+			report.Added = append(report.Added, rel)
+		}
+	}
+
+	ctx, cancel := startChecksumRun()
+	defer cancel()
+	actual, errs, err := hashChecksumFiles(ctx, rootPath, present, &checksumProgress)
+	report.Cancelled = errors.Is(err, context.Canceled)
+	for i, rel := range present {
+		want := expected[rel]
+		switch {
+		case errs[i] != nil:
+			report.Verified++
+			report.Corrupted = append(report.Corrupted, ChecksumMismatch{
+				Path: rel, ExpectedHash: want.Hash, ExpectedSize: want.Size, Error: errs[i].Error(),
+			})
+		case actual[i].Kind == "":
+			report.Unchecked++
+		default:
+			report.Verified++
+			if actual[i].Hash != want.Hash || actual[i].Size != want.Size {
+				report.Corrupted = append(report.Corrupted, ChecksumMismatch{
+					Path:         rel,
+					ExpectedHash: want.Hash,
+					ActualHash:   actual[i].Hash,
+					ExpectedSize: want.Size,
+					ActualSize:   actual[i].Size,
+				})
+			}
+		}
+	}
+
+	GoLog("[Checksum] Verified %d of %d files under %s: %d missing, %d added, %d corrupted (cancelled: %v)\n",
+		report.Verified, report.Expected, rootPath, len(report.Missing), len(report.Added), len(report.Corrupted), report.Cancelled)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,93 @@
+package gobackend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func verifyChecksumFixture(t *testing.T, root, manifest string) ChecksumVerifyReport {
+	t.Helper()
+	out, err := VerifyChecksumManifest(root, manifest)
+	report := mustDecodeJSON[ChecksumVerifyReport](t, out, err)
+	return report
+}
+
+func TestChecksumManifestRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	writeConsistencyFixture(t, root, "A/01 Keep.flac", Metadata{Title: "Keep", Artist: "Artist"})
+	retag := writeConsistencyFixture(t, root, "A/02 Retag.flac", Metadata{Title: "Retag", Artist: "Artist"})
+	corrupt := writeConsistencyFixture(t, root, "B/03 Corrupt.flac", Metadata{Title: "Corrupt", Artist: "Artist"})
+	gone := writeConsistencyFixture(t, root, "B/04 Gone.flac", Metadata{Title: "Gone", Artist: "Artist"})
+	manifest := filepath.Join(t.TempDir(), "library.sha256.json")
+
+	out, err := WriteChecksumManifest(root, manifest)
+	summary := mustDecodeJSON[ChecksumManifestSummary](t, out, err)
+	if summary.Files != 4 || summary.Bytes <= 0 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if report := verifyChecksumFixture(t, root, manifest); report.Verified != 4 || len(report.Corrupted) != 0 ||
+		len(report.Missing) != 0 || len(report.Added) != 0 {
+		t.Fatalf("clean report = %+v", report)
+	}
+
+	if err := EditFlacFields(retag, map[string]string{"title": "A much longer retagged title"}); err != nil {
+		t.Fatalf("EditFlacFields: %v", err)
+	}
+	data := mustReadFile(t, corrupt)
+	data[len(data)-1] ^= 0xFF
+	if err := os.WriteFile(corrupt, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Remove(gone); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	writeConsistencyFixture(t, root, "C/05 New.flac", Metadata{Title: "New", Artist: "Artist"})
+
+	report := verifyChecksumFixture(t, root, manifest)
+	if report.Expected != 4 || report.Verified != 3 || report.Cancelled {
+		t.Fatalf("report = %+v", report)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != "B/04 Gone.flac" {
+		t.Fatalf("missing = %v", report.Missing)
+	}
+	if len(report.Added) != 1 || report.Added[0] != "C/05 New.flac" {
+		t.Fatalf("added = %v", report.Added)
+	}
+	if len(report.Corrupted) != 1 || report.Corrupted[0].Path != "B/03 Corrupt.flac" {
+		t.Fatalf("corrupted = %+v", report.Corrupted)
+	}
+}
+
+func TestHashChecksumFilesCancelled(t *testing.T) {
+	root := t.TempDir()
+	writeConsistencyFixture(t, root, "01.flac", Metadata{Title: "One"})
+	writeConsistencyFixture(t, root, "02.flac", Metadata{Title: "Two"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	entries, errs, err := hashChecksumFiles(ctx, root, []string{"01.flac", "02.flac"}, nil)
+	if err == nil {
+		t.Fatalf("expected cancellation error")
+	}
+	for i := range entries {
+		if entries[i].Kind != "" || errs[i] != nil {
+			t.Fatalf("entry %d hashed after cancel: %+v %v", i, entries[i], errs[i])
+		}
+	}
+}
+
+func TestHashAudioContentWholeFileForOtherFormats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "track.mp3")
+	if err := os.WriteFile(path, []byte("not really an mp3"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	entry, err := hashAudioContent(context.Background(), path)
+	if err != nil {
+		t.Fatalf("hashAudioContent: %v", err)
+	}
+	if entry.Kind != ChecksumFile || entry.Size != 17 {
+		t.Fatalf("entry = %+v", entry)
+	}
+}
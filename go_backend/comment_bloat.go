@@ -0,0 +1,135 @@
+package gobackend
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Above these a comment block is reported as bloated by ReadMetadata. Real
+// tags stay far below them; a tagger bug appending a comment per play
+// does not.
+const (
+	bloatedCommentCount     = 1000
+	bloatedCommentBlockSize = 1 << 20
+)
+
+// commentBloatWarning returns the ReadMetadata warning for a comment block
+// of count comments and size bytes, or "" when neither is over its limit.
+// The warning is logged against filePath.
+func commentBloatWarning(filePath string, count, size int) string {
+	if count <= bloatedCommentCount && size <= bloatedCommentBlockSize {
+		return ""
+	}
+	warning := fmt.Sprintf("comment block has %d comments in %d bytes; see RepairBloatedComments", count, size)
+	GoLog("[Metadata] Warning: %s: %s\n", warning, filePath)
+	return warning
+}
+
+// BloatedCommentKey is how many comments of one key RepairBloatedComments
+// kept and removed.
+type BloatedCommentKey struct {
+	Key     string `json:"key"`
+	Kept    int    `json:"kept"`
+	Removed int    `json:"removed"`
+}
+
+// BloatRepairResult lists every comment RepairBloatedComments removed, or
+// would remove with DryRun, verbatim and in file order.
+type BloatRepairResult struct {
+	Path            string              `json:"path"`
+	DryRun          bool                `json:"dry_run"`
+	CommentsBefore  int                 `json:"comments_before"`
+	CommentsAfter   int                 `json:"comments_after"`
+	BlockSizeBefore int                 `json:"block_size_before"`
+	BlockSizeAfter  int                 `json:"block_size_after"`
+	Keys            []BloatedCommentKey `json:"keys"`
+	Removed         []string            `json:"removed"`
+}
+
+// trimRepeatedComments keeps the first maxPerKey comments of each key not
+// in keep, which are kept in full, and returns the kept and removed
+// comments. Entries without a key are left for the save path to repair.
+func trimRepeatedComments(comments []string, keep map[string]bool, maxPerKey int) ([]string, []string, []BloatedCommentKey) {
+	counts := make(map[string]*BloatedCommentKey)
+	var order []string
+	kept := make([]string, 0, len(comments))
+	var removed []string
+	for _, comment := range comments {
+		key, ok := vorbisCommentKey(comment)
+		if !ok || keep[key] {
+			kept = append(kept, comment)
+			continue
+		}
+		count := counts[key]
+		if count == nil {
+			count = &BloatedCommentKey{Key: key}
+			counts[key] = count
+			order = append(order, key)
+		}
+		if count.Kept < maxPerKey {
+			count.Kept++
+			kept = append(kept, comment)
+			continue
+		}
+		count.Removed++
+		removed = append(removed, comment)
+	}
+
+	keys := []BloatedCommentKey{}
+	for _, key := range order {
+		if counts[key].Removed > 0 {
+			keys = append(keys, *counts[key])
+		}
+	}
+	slices.SortStableFunc(keys, func(a, b BloatedCommentKey) int { return b.Removed - a.Removed })
+	return kept, removed, keys
+}
+
+// RepairBloatedComments trims every key of a FLAC file's comments to its
+// first maxPerKey values, except keepKeys, whose values are all kept. It
+// fixes blocks bloated by taggers that append a comment per play. The
+// result lists exactly what was removed; with dryRun the file is only read.
+func RepairBloatedComments(filePath string, keepKeys []string, maxPerKey int, dryRun bool) (*BloatRepairResult, error) {
+	if maxPerKey < 1 {
+		return nil, fmt.Errorf("max per key must be at least 1, got %d", maxPerKey)
+	}
+	normalized, err := normalizeTagKeys(keepKeys)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool, len(normalized))
+	for _, key := range normalized {
+		keep[key] = true
+	}
+
+	result := &BloatRepairResult{Path: filePath, DryRun: dryRun, Keys: []BloatedCommentKey{}, Removed: []string{}}
+	trim := func(raw []string) ([]string, bool) {
+		kept, removed, keys := trimRepeatedComments(raw, keep, maxPerKey)
+		result.CommentsBefore, result.CommentsAfter = len(raw), len(kept)
+		result.BlockSizeBefore = vorbisCommentBlockSize("", raw)
+		result.BlockSizeAfter = vorbisCommentBlockSize("", kept)
+		result.Keys = keys
+		if removed != nil {
+			result.Removed = removed
+		}
+		return kept, len(removed) > 0
+	}
+
+	if dryRun {
+		raw, err := readVorbisCommentList(filePath)
+		if err != nil {
+			return nil, err
+		}
+		trim(raw)
+		return result, nil
+	}
+	if err := checkWriteAllowed(filePath); err != nil {
+		return nil, err
+	}
+	if err := editVorbisCommentList(filePath, "repair_bloated_comments", trim); err != nil {
+		return nil, err
+	}
+	GoLog("[Metadata] Removed %d repeated comment(s), %d left: %s\n",
+		len(result.Removed), result.CommentsAfter, filePath)
+	return result, nil
+}
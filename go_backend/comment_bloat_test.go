@@ -0,0 +1,69 @@
+package gobackend
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTrimRepeatedComments(t *testing.T) {
+	comments := []string{"TITLE=Song", "PLAYED=1", "played=2", "PLAYED=3", "ARTIST=A", "ARTIST=B", "BARE", "ARTIST=C"}
+	kept, removed, keys := trimRepeatedComments(comments, map[string]bool{"ARTIST": true}, 1)
+	if !reflect.DeepEqual(kept, []string{"TITLE=Song", "PLAYED=1", "ARTIST=A", "ARTIST=B", "BARE", "ARTIST=C"}) {
+		t.Fatalf("kept = %q", kept)
+	}
+	if !reflect.DeepEqual(removed, []string{"played=2", "PLAYED=3"}) {
+		t.Fatalf("removed = %q", removed)
+	}
+	if !reflect.DeepEqual(keys, []BloatedCommentKey{{Key: "PLAYED", Kept: 1, Removed: 2}}) {
+		t.Fatalf("keys = %+v", keys)
+	}
+}
+
+func TestRepairBloatedComments(t *testing.T) {
+	comments := []string{"TITLE=Song"}
+	for i := range bloatedCommentCount + 5 {
+		comments = append(comments, fmt.Sprintf("PLAYCOUNT=%d", i))
+	}
+	comments = append(comments, "ARTISTS=A", "ARTISTS=B", "ARTISTS=C")
+	path := writeMalformedCommentFLAC(t, comments...)
+
+	metadata, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if !strings.Contains(metadata.CommentWarning, fmt.Sprintf("%d comments", len(comments))) {
+		t.Fatalf("warning = %q", metadata.CommentWarning)
+	}
+
+	out, err := RepairBloatedCommentsJSON(path, `["artists"]`, 2, true)
+	preview := mustDecodeJSON[BloatRepairResult](t, out, err)
+	if !preview.DryRun || len(preview.Removed) != bloatedCommentCount+3 || preview.Removed[0] != "PLAYCOUNT=2" ||
+		preview.CommentsAfter != 6 || preview.BlockSizeAfter >= preview.BlockSizeBefore {
+		t.Fatalf("preview = %+v", preview)
+	}
+	if raw, _ := readVorbisCommentList(path); len(raw) != len(comments) {
+		t.Fatalf("dry run changed the file: %d comments", len(raw))
+	}
+
+	result, err := RepairBloatedComments(path, []string{"ARTISTS"}, 2, false)
+	if err != nil {
+		t.Fatalf("RepairBloatedComments: %v", err)
+	}
+	raw, err := readVorbisCommentList(path)
+	if err != nil {
+		t.Fatalf("readVorbisCommentList: %v", err)
+	}
+	want := []string{"TITLE=Song", "PLAYCOUNT=0", "PLAYCOUNT=1", "ARTISTS=A", "ARTISTS=B", "ARTISTS=C"}
+	if !reflect.DeepEqual(raw, want) || !reflect.DeepEqual(result.Removed, preview.Removed) {
+		t.Fatalf("comments = %q, removed %d", raw, len(result.Removed))
+	}
+	if metadata, _ := ReadMetadata(path); metadata.CommentWarning != "" {
+		t.Fatalf("warning after repair = %q", metadata.CommentWarning)
+	}
+
+	if _, err := RepairBloatedComments(path, nil, 0, true); err == nil {
+		t.Fatal("expected error for max per key 0")
+	}
+}
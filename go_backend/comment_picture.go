@@ -0,0 +1,170 @@
+package gobackend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/go-flac/flacpicture/v2"
+	"github.com/go-flac/go-flac/v2"
+)
+
+// commentPictureKey is the Vorbis comment Ogg Vorbis and Opus use for cover
+// art: a base64 encoded FLAC PICTURE block.
+const commentPictureKey = "METADATA_BLOCK_PICTURE"
+
+// decodeCommentPicture parses the value of a METADATA_BLOCK_PICTURE comment.
+// Whitespace and missing padding, both common from hand-rolled taggers, are
+// tolerated.
+func decodeCommentPicture(value string) (*flacpicture.MetadataBlockPicture, error) {
+	value = strings.Join(strings.Fields(value), "")
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		if raw, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(value, "=")); err != nil {
+			return nil, fmt.Errorf("invalid base64 picture: %w", err)
+		}
+	}
+	pic, err := parseFLACPictureMeta(flac.MetaDataBlock{Type: flac.Picture, Data: raw})
+	if err != nil {
+		return nil, fmt.Errorf("invalid picture block: %w", err)
+	}
+	if len(pic.ImageData) == 0 {
+		return nil, fmt.Errorf("picture block has no image data")
+	}
+	return pic, nil
+}
+
+// encodeCommentPicture is the reverse of decodeCommentPicture, for writing
+// cover art into Ogg Vorbis and Opus tags.
+func encodeCommentPicture(coverPath string, coverData []byte) (string, error) {
+	block, err := buildPictureBlock(coverPath, coverData)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(block.Data), nil
+}
+
+// commentPictureFromFLAC returns the first decodable METADATA_BLOCK_PICTURE
+// comment of f, preferring a front cover.
+func commentPictureFromFLAC(f *flac.File) *flacpicture.MetadataBlockPicture {
+	var first *flacpicture.MetadataBlockPicture
+	for _, meta := range f.Meta {
+		if meta.Type != flac.VorbisComment {
+			continue
+		}
+		cmt, err := parseVorbisCommentBlock(*meta)
+		if err != nil {
+			continue
+		}
+		for _, comment := range cmt.Comments {
+			key, value := splitVorbisComment(comment)
+			if !strings.EqualFold(key, commentPictureKey) {
+				continue
+			}
+			pic, err := decodeCommentPicture(value)
+			if err != nil {
+				continue
+			}
+			if pic.PictureType == flacpicture.PictureTypeFrontCover {
+				return pic
+			}
+			if first == nil {
+				first = pic
+			}
+		}
+	}
+	return first
+}
+
+// MigrateCommentPicture turns METADATA_BLOCK_PICTURE comments of a FLAC file
+// into real PICTURE blocks, which every FLAC player reads, and drops the
+// base64 comments. A picture already present as a block with the same image
+// is not duplicated. Comments that do not decode are kept untouched. It
+// returns the number of comments migrated; 0 leaves the file unchanged.
+func MigrateCommentPicture(filePath string) (int, error) {
+	if err := checkWriteAllowed(filePath); err != nil {
+		return 0, err
+	}
+
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	f, err := parseFLACStaged(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+	before := takeTagSnapshot(f)
+
+	var existing [][]byte
+	for _, meta := range f.Meta {
+		if meta.Type == flac.Picture {
+			if pic, err := parseFLACPictureMeta(*meta); err == nil {
+				existing = append(existing, pic.ImageData)
+			}
+		}
+	}
+
+	migrated := 0
+	var newBlocks []*flac.MetaDataBlock
+	for idx, meta := range f.Meta {
+		if meta.Type != flac.VorbisComment {
+			continue
+		}
+		cmt, err := parseVorbisCommentBlock(*meta)
+		if err != nil {
+			f.Close()
+			return 0, fmt.Errorf("failed to parse vorbis comment: %w", err)
+		}
+		kept := cmt.Comments[:0]
+		for _, comment := range cmt.Comments {
+			key, value := splitVorbisComment(comment)
+			if !strings.EqualFold(key, commentPictureKey) {
+				kept = append(kept, comment)
+				continue
+			}
+			pic, err := decodeCommentPicture(value)
+			if err != nil {
+				GoLog("[Metadata] Warning: keeping undecodable %s comment in %s: %v\n", commentPictureKey, filePath, err)
+				kept = append(kept, comment)
+				continue
+			}
+			migrated++
+			if !slices.ContainsFunc(existing, func(data []byte) bool { return bytes.Equal(data, pic.ImageData) }) {
+				block := pic.Marshal()
+				newBlocks = append(newBlocks, &block)
+				existing = append(existing, pic.ImageData)
+			}
+		}
+		cmt.Comments = kept
+		block := cmt.Marshal()
+		f.Meta[idx] = &block
+	}
+
+	if migrated == 0 {
+		f.Close()
+		return 0, nil
+	}
+	f.Meta = append(f.Meta, newBlocks...)
+	if err := saveTaggedFLAC(f, filePath, "migrate_comment_picture", before); err != nil {
+		return 0, err
+	}
+	GoLog("[Metadata] Migrated %d %s comment(s) to picture blocks: %s\n", migrated, commentPictureKey, filePath)
+	return migrated, nil
+}
+
+// EncodeCommentPicture returns the METADATA_BLOCK_PICTURE value for the
+// image at coverPath, for writers of Ogg Vorbis and Opus tags. Unlike a
+// hand-built block it carries the real dimensions and colour depth.
+func EncodeCommentPicture(coverPath string) (string, error) {
+	data, err := os.ReadFile(coverPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cover file: %w", err)
+	}
+	return encodeCommentPicture(coverPath, data)
+}
@@ -0,0 +1,103 @@
+package gobackend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestMigrateCommentPictureToPictureBlock(t *testing.T) {
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	encoded, err := encodeCommentPicture("", cover)
+	if err != nil {
+		t.Fatalf("encodeCommentPicture: %v", err)
+	}
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Converted"})
+	if err := SetTags(path, []TagPair{
+		{Key: commentPictureKey, Value: encoded},
+		{Key: commentPictureKey, Value: "!!not base64!!"},
+	}, false); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+
+	meta, err := ReadMetadata(path)
+	if err != nil || !meta.HasCommentPicture {
+		t.Fatalf("ReadMetadata should flag the comment picture: %+v, %v", meta, err)
+	}
+	if data, err := ExtractCoverArt(path); err != nil || !bytes.Equal(data, cover) {
+		t.Fatalf("ExtractCoverArt should fall back to the comment picture, err=%v", err)
+	}
+
+	migrated, err := MigrateCommentPicture(path)
+	if err != nil || migrated != 1 {
+		t.Fatalf("MigrateCommentPicture = %d, %v", migrated, err)
+	}
+	pictures, err := GetPictures(path)
+	if err != nil || len(pictures) != 1 || pictures[0].Width != 8 || pictures[0].TypeName != "Front Cover" {
+		t.Fatalf("expected one real picture block, got %+v, %v", pictures, err)
+	}
+	tags, err := GetTags(path, []string{commentPictureKey, "TITLE"})
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if len(tags[commentPictureKey]) != 1 || tags[commentPictureKey][0] != "!!not base64!!" || tags["TITLE"][0] != "Converted" {
+		t.Fatalf("only the decodable comment should be removed: %v", tags)
+	}
+
+	if migrated, err := MigrateCommentPicture(path); err != nil || migrated != 0 {
+		t.Fatalf("second migration = %d, %v", migrated, err)
+	}
+}
+
+func TestMigrateCommentPictureSkipsDuplicateBlock(t *testing.T) {
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Both"})
+	if err := EmbedMetadataWithCoverData(path, Metadata{}, cover); err != nil {
+		t.Fatalf("embed cover: %v", err)
+	}
+	encoded, _ := encodeCommentPicture("", cover)
+	if err := SetTags(path, []TagPair{{Key: commentPictureKey, Value: encoded}}, false); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+
+	if migrated, err := MigrateCommentPicture(path); err != nil || migrated != 1 {
+		t.Fatalf("MigrateCommentPicture = %d, %v", migrated, err)
+	}
+	if pictures, err := GetPictures(path); err != nil || len(pictures) != 1 {
+		t.Fatalf("identical image must not be duplicated: %+v, %v", pictures, err)
+	}
+}
+
+func TestEncodeCommentPictureIsReadByOggReader(t *testing.T) {
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	encoded, err := encodeCommentPicture("", cover)
+	if err != nil {
+		t.Fatalf("encodeCommentPicture: %v", err)
+	}
+
+	// Vorbis comment packet body: vendor, count, one comment.
+	comment := []byte(commentPictureKey + "=" + encoded)
+	var packet bytes.Buffer
+	binary.Write(&packet, binary.LittleEndian, uint32(4))
+	packet.WriteString("test")
+	binary.Write(&packet, binary.LittleEndian, uint32(1))
+	binary.Write(&packet, binary.LittleEndian, uint32(len(comment)))
+	packet.Write(comment)
+
+	data, mimeType := extractPictureFromVorbisComments(packet.Bytes())
+	if !bytes.Equal(data, cover) || mimeType != "image/png" {
+		t.Fatalf("Ogg reader decoded %d bytes as %q", len(data), mimeType)
+	}
+	if pic, err := decodeCommentPicture(encoded); err != nil || pic.Width != 8 || pic.Height != 8 {
+		t.Fatalf("encoded picture should carry real dimensions: %+v, %v", pic, err)
+	}
+}
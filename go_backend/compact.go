@@ -0,0 +1,230 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+// defaultCompactMinSavings is the CompactLibrary threshold when none is
+// given: below it a full rewrite is not worth the write wear.
+const defaultCompactMinSavings = 64 << 10
+
+// CompactResult reports one compacted file. BytesReclaimed is negative when
+// a file with less than PaddingTarget of padding grew.
+type CompactResult struct {
+	Path           string `json:"path"`
+	BytesBefore    int64  `json:"bytes_before"`
+	BytesAfter     int64  `json:"bytes_after"`
+	BytesReclaimed int64  `json:"bytes_reclaimed"`
+	PaddingBefore  int64  `json:"padding_before"`
+	PaddingAfter   int64  `json:"padding_after"`
+	Error          string `json:"error,omitempty"`
+	io             flacSaveStats
+}
+
+// CompactLibraryReport is the result of CompactLibrary. Files lists the
+// files compacted (or, in a dry run, that would be) and the failures.
+type CompactLibraryReport struct {
+	Root               string          `json:"root"`
+	DryRun             bool            `json:"dry_run"`
+	MinSavings         int64           `json:"min_savings"`
+	Checked            int             `json:"checked"`
+	Compacted          int             `json:"compacted"`
+	BelowThreshold     int             `json:"below_threshold"`
+	Failed             int             `json:"failed"`
+	QuarantinedSkipped int             `json:"quarantined_skipped"`
+	BytesReclaimed     int64           `json:"bytes_reclaimed"`
+	IO                 WriteStats      `json:"io"`
+	Files              []CompactResult `json:"files"`
+}
+
+// compactSavings reads the metadata block headers of filePath and returns
+// its size, its padding and the bytes a rewrite with PaddingTarget of
+// padding would reclaim.
+func compactSavings(filePath string) (size, padding, savings int64, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	layout, err := scanFLACMetadataBlocks(f, info.Size())
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	compacted := int64(4 + 4 + GetBackendConfig().PaddingTarget)
+	for _, block := range layout.Blocks {
+		if block.Type == byte(flac.Padding) {
+			padding += int64(block.Length)
+			continue
+		}
+		compacted += 4 + int64(block.Length)
+	}
+	return info.Size(), padding, layout.AudioOffset - compacted, nil
+}
+
+func compactFLACFile(filePath string) CompactResult {
+	result := CompactResult{Path: filePath}
+	size, padding, _, err := compactSavings(filePath)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.BytesBefore, result.PaddingBefore = size, padding
+
+	f, err := parseFLACStaged(filePath)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to parse FLAC file: %v", err)
+		return result
+	}
+	stats, err := rewriteFLACFile(f, filePath)
+	recordWriteStats("compact", stats, err)
+	if err != nil {
+		result.Error = classifyWriteError(filePath, err).Error()
+		return result
+	}
+	result.io = stats
+	result.PaddingAfter = int64(GetBackendConfig().PaddingTarget)
+	// bytesWritten is the whole new file, also for a write deferred until
+	// playback stops.
+	result.BytesAfter = stats.bytesWritten
+	result.BytesReclaimed = result.BytesBefore - result.BytesAfter
+	return result
+}
+
+// CompactFile rewrites a FLAC file with its metadata packed and its padding
+// reset to PaddingTarget, reclaiming the space left behind by removed
+// covers, lyrics and oversized padding. The audio frames are copied
+// verbatim. Unlike a tag save it always rewrites the whole file.
+func CompactFile(filePath string) (string, error) {
+	result, err := compactFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+func compactFile(filePath string) (CompactResult, error) {
+	if isOpenerPath(filePath) {
+		result, err := viaFileOpener(filePath, true, compactFile)
+		result.Path = filePath
+		return result, err
+	}
+	if err := checkWriteAllowed(filePath); err != nil {
+		return CompactResult{}, err
+	}
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return CompactResult{}, err
+	}
+	defer release()
+
+	result := compactFLACFile(filePath)
+	if result.Error != "" {
+		return CompactResult{}, fmt.Errorf("failed to compact %s: %s", filePath, result.Error)
+	}
+	GoLog("[Compact] Reclaimed %d bytes: %s\n", result.BytesReclaimed, filePath)
+	return result, nil
+}
+
+// CompactLibrary runs CompactFile on every FLAC file under rootPath that
+// would shrink by at least minSavings bytes (64 KiB when <= 0); the saving
+// is worked out from the block headers, so files below the threshold are
+// never rewritten. A dry run only reports the expected savings.
+func CompactLibrary(rootPath string, minSavings int64, dryRun bool) (string, error) {
+	if strings.TrimSpace(rootPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(rootPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+	if !dryRun {
+		if err := checkWriteAllowed(rootPath); err != nil {
+			return "", err
+		}
+	}
+	if minSavings <= 0 {
+		minSavings = defaultCompactMinSavings
+	}
+
+	files, quarantined, err := collectWritableLibraryFiles(rootPath, nil)
+	if err != nil {
+		return "", err
+	}
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file.path), ".flac") {
+			paths = append(paths, file.path)
+		}
+	}
+	sort.Strings(paths)
+
+	started := time.Now()
+	report := CompactLibraryReport{Root: rootPath, DryRun: dryRun, MinSavings: minSavings, Files: []CompactResult{}}
+	report.QuarantinedSkipped = quarantined
+	for _, path := range paths {
+		report.Checked++
+		size, padding, savings, err := compactSavings(path)
+		if err != nil {
+			report.Failed++
+			report.Files = append(report.Files, CompactResult{Path: path, Error: err.Error()})
+			continue
+		}
+		if savings < minSavings {
+			report.BelowThreshold++
+			continue
+		}
+
+		result := CompactResult{
+			Path:           path,
+			BytesBefore:    size,
+			BytesAfter:     size - savings,
+			BytesReclaimed: savings,
+			PaddingBefore:  padding,
+			PaddingAfter:   int64(GetBackendConfig().PaddingTarget),
+		}
+		if !dryRun {
+			release, err := acquireHeavyOperation()
+			if err != nil {
+				return "", err
+			}
+			result = compactFLACFile(path)
+			release()
+		}
+		if result.Error != "" {
+			report.Failed++
+			GoLog("[Compact] %s: %s\n", path, result.Error)
+		} else {
+			report.Compacted++
+			report.BytesReclaimed += result.BytesReclaimed
+			report.IO.add(result.io)
+		}
+		report.Files = append(report.Files, result)
+	}
+	report.IO.DurationMs = time.Since(started).Milliseconds()
+
+	GoLog("[Compact] %d of %d files compacted under %s, %d bytes reclaimed (dry run: %v)\n",
+		report.Compacted, report.Checked, rootPath, report.BytesReclaimed, dryRun)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
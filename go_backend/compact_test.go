@@ -0,0 +1,107 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+// padFLAC rewrites path with a single padding block of padding bytes.
+func padFLAC(t *testing.T, path string, padding int) {
+	t.Helper()
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	f.Close()
+	if _, err := rewriteFLACStreaming(path, normalizeFLACPadding(f.Meta, padding)); err != nil {
+		t.Fatalf("rewriteFLACStreaming: %v", err)
+	}
+}
+
+func TestCompactFile(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", Artist: "Artist"})
+	padFLAC(t, path, 1<<20)
+	before := mustReadFile(t, path)
+
+	out, err := CompactFile(path)
+	result := mustDecodeJSON[CompactResult](t, out, err)
+	want := int64(1<<20 - defaultPaddingTarget)
+	if result.BytesReclaimed != want || result.PaddingBefore != 1<<20 || result.PaddingAfter != defaultPaddingTarget {
+		t.Fatalf("result = %+v", result)
+	}
+	after := mustReadFile(t, path)
+	if int64(len(before)-len(after)) != want {
+		t.Fatalf("file shrank by %d bytes, reported %d", len(before)-len(after), want)
+	}
+	// The audio frames are copied byte for byte.
+	audio := len(after) - 200
+	if string(after[audio:]) != string(before[len(before)-200:]) {
+		t.Fatal("audio frames changed")
+	}
+	if meta, _ := ReadMetadata(path); meta.Title != "Song" {
+		t.Fatalf("tags lost: %+v", meta)
+	}
+
+	// It always rewrites, even with nothing to reclaim.
+	rewrites := flacRewriteCount.Load()
+	if _, err := CompactFile(path); err != nil || flacRewriteCount.Load() != rewrites+1 {
+		t.Fatalf("second CompactFile: %v", err)
+	}
+}
+
+func TestCompactFileThroughFileOpener(t *testing.T) {
+	opener := useDirFileOpener(t)
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	padFLAC(t, path, 1<<20)
+	uri := serveThroughOpener(t, opener, path, "song.flac")
+
+	out, err := CompactFile(uri)
+	result := mustDecodeJSON[CompactResult](t, out, err)
+	if result.Path != uri || result.PaddingAfter != defaultPaddingTarget || opener.writes != 1 {
+		t.Fatalf("result = %+v after %d writes", result, opener.writes)
+	}
+	if footprint, err := GetMetadataFootprint(filepath.Join(opener.dir, "song.flac")); err != nil || footprint.Padding != 4+defaultPaddingTarget {
+		t.Fatalf("document not compacted: %+v %v", footprint, err)
+	}
+}
+
+func TestCompactLibraryThreshold(t *testing.T) {
+	root := t.TempDir()
+	bloated := writeConsistencyFixture(t, root, "a/bloated.flac", Metadata{Title: "A"})
+	slim := writeConsistencyFixture(t, root, "b/slim.flac", Metadata{Title: "B"})
+	padFLAC(t, bloated, 512<<10)
+	padFLAC(t, slim, defaultPaddingTarget+1024)
+	os.WriteFile(filepath.Join(root, "notes.mp3"), []byte("not flac"), 0644)
+
+	compact := func(dryRun bool) CompactLibraryReport {
+		t.Helper()
+		out, err := CompactLibrary(root, 0, dryRun)
+		report := mustDecodeJSON[CompactLibraryReport](t, out, err)
+		return report
+	}
+
+	sizeBefore := len(mustReadFile(t, bloated))
+	report := compact(true)
+	want := int64(512<<10 - defaultPaddingTarget)
+	if report.Checked != 2 || report.Compacted != 1 || report.BelowThreshold != 1 || report.BytesReclaimed != want {
+		t.Fatalf("dry run = %+v", report)
+	}
+	if len(mustReadFile(t, bloated)) != sizeBefore {
+		t.Fatal("dry run modified a file")
+	}
+
+	slimBefore := mustReadFile(t, slim)
+	report = compact(false)
+	if report.Compacted != 1 || report.Files[0].Path != bloated || report.BytesReclaimed != want || report.IO.Writes != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if int64(sizeBefore-len(mustReadFile(t, bloated))) != want {
+		t.Fatal("bloated file not compacted")
+	}
+	if string(mustReadFile(t, slim)) != string(slimBefore) {
+		t.Fatal("file below the threshold was rewritten")
+	}
+}
@@ -0,0 +1,119 @@
+package gobackend
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Compatibility profiles for EmbedOptions.CompatProfile.
+const (
+	// CompatProfileUnlimited writes tags as they are (the default).
+	CompatProfileUnlimited = "default-unlimited"
+	// CompatProfileStrictCar keeps values short enough for head units that
+	// mangle long titles and crash on comment blocks near 64 KB.
+	CompatProfileStrictCar = "strict-car"
+)
+
+type compatProfile struct {
+	// fieldLimits caps value bytes per Vorbis key; defaultFieldLimit covers
+	// other keys. Zero means no limit.
+	fieldLimits       map[string]int
+	defaultFieldLimit int
+	// blockLimit caps the whole VORBIS_COMMENT block in bytes.
+	blockLimit int
+	// writeYear adds a YEAR comment derived from DATE, as
+	// BackendConfig.WriteYearTag does.
+	writeYear bool
+}
+
+var compatProfiles = map[string]compatProfile{
+	CompatProfileUnlimited: {},
+	CompatProfileStrictCar: {
+		fieldLimits: map[string]int{
+			"LYRICS":         16 * 1024,
+			"UNSYNCEDLYRICS": 16 * 1024,
+			"COMMENT":        1024,
+			"DESCRIPTION":    1024,
+		},
+		defaultFieldLimit: 255,
+		blockLimit:        60 * 1024,
+		writeYear:         true,
+	},
+}
+
+// compatBlockShedOrder lists the keys dropped, in order, while a block is
+// over its limit. UNSYNCEDLYRICS duplicates LYRICS, and players read cover
+// art from PICTURE blocks.
+var compatBlockShedOrder = []string{"UNSYNCEDLYRICS", commentPictureKey, "DESCRIPTION", "COMMENT", "LYRICS"}
+
+func validateCompatProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := compatProfiles[name]; !ok {
+		return fmt.Errorf("unknown compatibility profile: %q", name)
+	}
+	return nil
+}
+
+func (p compatProfile) fieldLimit(key string) int {
+	if limit, ok := p.fieldLimits[key]; ok {
+		return limit
+	}
+	return p.defaultFieldLimit
+}
+
+func vorbisCommentBlockSize(vendor string, comments []string) int {
+	size := 4 + len(vendor) + 4
+	for _, comment := range comments {
+		size += 4 + len(comment)
+	}
+	return size
+}
+
+// applyCompatProfile truncates comment values to the profile's per-field
+// limits, never splitting a UTF-8 sequence or grapheme cluster, then sheds
+// whole comments in compatBlockShedOrder until the block fits. Every change
+// is logged against filePath.
+func applyCompatProfile(name, filePath, vendor string, comments []string) []string {
+	profile := compatProfiles[name]
+	if profile.defaultFieldLimit == 0 && len(profile.fieldLimits) == 0 && profile.blockLimit == 0 {
+		return comments
+	}
+
+	result := make([]string, 0, len(comments))
+	for _, comment := range comments {
+		key, value := splitVorbisComment(comment)
+		upper := strings.ToUpper(key)
+		// Pictures are bounded by the block limit, not the field limit.
+		if limit := profile.fieldLimit(upper); limit > 0 && upper != commentPictureKey && len(value) > limit {
+			truncated := truncateUTF8Bytes(value, limit)
+			GoLog("[Metadata] %s: truncated %s from %d to %d bytes: %s\n", name, upper, len(value), len(truncated), filePath)
+			comment = key + "=" + truncated
+		}
+		result = append(result, comment)
+	}
+
+	if profile.blockLimit <= 0 {
+		return result
+	}
+	for _, shed := range compatBlockShedOrder {
+		if vorbisCommentBlockSize(vendor, result) <= profile.blockLimit {
+			break
+		}
+		kept := result[:0]
+		for _, comment := range result {
+			if key, ok := vorbisCommentKey(comment); ok && key == shed {
+				GoLog("[Metadata] %s: dropped %s (%d bytes) to fit the %d byte comment block: %s\n",
+					name, shed, len(comment), profile.blockLimit, filePath)
+				continue
+			}
+			kept = append(kept, comment)
+		}
+		result = kept
+	}
+	if size := vorbisCommentBlockSize(vendor, result); size > profile.blockLimit {
+		GoLog("[Metadata] %s: comment block still %d bytes after shedding: %s\n", name, size, filePath)
+	}
+	return result
+}
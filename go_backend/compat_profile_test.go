@@ -0,0 +1,74 @@
+package gobackend
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestApplyCompatProfileTruncatesFieldsSafely(t *testing.T) {
+	// 100 × "é" as e + U+0301 is 300 bytes; 86 × "あ" is 258 bytes.
+	title := strings.Repeat("é", 100)
+	artist := strings.Repeat("あ", 86)
+	got := applyCompatProfile(CompatProfileStrictCar, "test.flac", "vendor", []string{
+		"TITLE=" + title,
+		"ARTIST=" + artist,
+		"ALBUM=Short",
+	})
+
+	gotTitle := strings.TrimPrefix(got[0], "TITLE=")
+	if len(gotTitle) > 255 || !utf8.ValidString(gotTitle) || strings.Count(gotTitle, "e") != strings.Count(gotTitle, "́") {
+		t.Fatalf("title truncated unsafely to %d bytes: %q", len(gotTitle), gotTitle)
+	}
+	gotArtist := strings.TrimPrefix(got[1], "ARTIST=")
+	if gotArtist != strings.Repeat("あ", 85) {
+		t.Fatalf("artist should keep 85 whole characters, got %d bytes", len(gotArtist))
+	}
+	if got[2] != "ALBUM=Short" {
+		t.Fatalf("short values must be untouched: %q", got[2])
+	}
+
+	unlimited := applyCompatProfile(CompatProfileUnlimited, "test.flac", "vendor", []string{"TITLE=" + title})
+	if unlimited[0] != "TITLE="+title {
+		t.Fatal("default-unlimited must not truncate")
+	}
+}
+
+func TestApplyCompatProfileShedsCommentsOverBlockLimit(t *testing.T) {
+	lyrics := strings.Repeat("la ", 5000)
+	picture := strings.Repeat("A", 50*1024)
+	got := applyCompatProfile(CompatProfileStrictCar, "test.flac", "vendor", []string{
+		"TITLE=Song",
+		"LYRICS=" + lyrics,
+		"UNSYNCEDLYRICS=" + lyrics,
+		commentPictureKey + "=" + picture,
+	})
+
+	if size := vorbisCommentBlockSize("vendor", got); size > 60*1024 {
+		t.Fatalf("block is %d bytes", size)
+	}
+	keys := make([]string, 0, len(got))
+	for _, comment := range got {
+		key, _ := vorbisCommentKey(comment)
+		keys = append(keys, key)
+	}
+	if strings.Join(keys, ",") != "TITLE,LYRICS" {
+		t.Fatalf("expected duplicate lyrics and the picture comment to be shed first, kept %v", keys)
+	}
+}
+
+func TestEmbedAllAppliesCompatProfile(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{})
+	long := strings.Repeat("x", 400)
+	if err := EmbedAll(path, Metadata{Title: long}, Lyrics{}, nil, EmbedOptions{CompatProfile: CompatProfileStrictCar}); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	meta, err := ReadMetadata(path)
+	if err != nil || meta.Title != long[:255] {
+		t.Fatalf("expected title capped at 255 bytes, got %d bytes, %v", len(meta.Title), err)
+	}
+
+	if err := EmbedAll(path, Metadata{}, Lyrics{}, nil, EmbedOptions{CompatProfile: "toaster"}); err == nil {
+		t.Fatal("expected unknown profile error")
+	}
+}
@@ -52,6 +52,12 @@ func downloadCoverToMemory(coverURL string, maxQuality bool) ([]byte, error) {
 
 	GoLog("[Cover] Final URL: %s", downloadURL)
 
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	client := NewHTTPClientWithTimeout(DefaultTimeout)
 
 	req, err := http.NewRequest("GET", downloadURL, nil)
@@ -59,8 +65,10 @@ func downloadCoverToMemory(coverURL string, maxQuality bool) ([]byte, error) {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := DoRequestWithUserAgent(client, req)
+	req.Header.Set("User-Agent", userAgentForURL(req.URL))
+	resp, err := doFetchWithRetry(client, req, "cover download")
 	if err != nil {
+		CheckAndLogISPBlocking(err, downloadURL, "HTTP")
 		return nil, fmt.Errorf("failed to download cover: %w", err)
 	}
 	defer resp.Body.Close()
@@ -0,0 +1,335 @@
+package gobackend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const coverCacheFileExt = ".cover"
+
+var (
+	coverDimensionRegex = regexp.MustCompile(`/(\d+)x(\d+)(?:[-.])`)
+	qobuzDimensionRegex = regexp.MustCompile(`_(\d+|max|org)\.jpg$`)
+)
+
+var coverCacheMu sync.Mutex
+
+type coverCandidate struct {
+	url           string
+	dim           int
+	contentLength int64
+}
+
+// coverDimensionFromURL infers the edge length of a cover from well-known
+// CDN URL patterns. Returns 0 when the size cannot be told from the URL.
+func coverDimensionFromURL(coverURL string) int {
+	switch {
+	case strings.Contains(coverURL, spotifySizeMax):
+		return 2000
+	case strings.Contains(coverURL, spotifySize640):
+		return 640
+	case strings.Contains(coverURL, spotifySize300):
+		return 300
+	}
+
+	if strings.Contains(coverURL, "resources.tidal.com") && strings.HasSuffix(coverURL, "/origin.jpg") {
+		return 3000
+	}
+
+	if m := qobuzDimensionRegex.FindStringSubmatch(coverURL); m != nil && strings.Contains(coverURL, "static.qobuz.com") {
+		if dim, err := strconv.Atoi(m[1]); err == nil {
+			return dim
+		}
+		return 3000
+	}
+
+	if m := coverDimensionRegex.FindStringSubmatch(coverURL); m != nil {
+		w, _ := strconv.Atoi(m[1])
+		h, _ := strconv.Atoi(m[2])
+		return max(w, h)
+	}
+	return 0
+}
+
+// rankCoverCandidates orders candidates best-first. With a preferred
+// dimension, the smallest known size that still covers it wins; otherwise
+// larger is better. Candidates of unknown size follow the ones that satisfy
+// the preference, biggest Content-Length first.
+func rankCoverCandidates(candidates []coverCandidate, preferredDim int) {
+	satisfies := func(c coverCandidate) bool {
+		return c.dim > 0 && (preferredDim <= 0 || c.dim >= preferredDim)
+	}
+	tier := func(c coverCandidate) int {
+		switch {
+		case satisfies(c):
+			return 0
+		case c.dim == 0:
+			return 1
+		default:
+			return 2
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		ta, tb := tier(a), tier(b)
+		if ta != tb {
+			return ta < tb
+		}
+		switch ta {
+		case 0:
+			if preferredDim > 0 {
+				return a.dim < b.dim
+			}
+			return a.dim > b.dim
+		case 1:
+			return a.contentLength > b.contentLength
+		default:
+			return a.dim > b.dim
+		}
+	})
+}
+
+func coverCachePath(cacheDir, coverURL string) string {
+	sum := sha256.Sum256([]byte(coverURL))
+	return filepath.Join(cacheDir, hex.EncodeToString(sum[:])+coverCacheFileExt)
+}
+
+func readCoverCache(cacheDir, coverURL string) ([]byte, bool) {
+	if cacheDir == "" {
+		return nil, false
+	}
+
+	coverCacheMu.Lock()
+	defer coverCacheMu.Unlock()
+
+	path := coverCachePath(cacheDir, coverURL)
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return nil, false
+	}
+	// Bump mtime so eviction treats this entry as recently used.
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, true
+}
+
+func writeCoverCache(cacheDir, coverURL string, data []byte, maxBytes int64) {
+	if cacheDir == "" || len(data) == 0 {
+		return
+	}
+
+	coverCacheMu.Lock()
+	defer coverCacheMu.Unlock()
+
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		GoLog("[CoverCache] Failed to create cache dir: %v\n", err)
+		return
+	}
+
+	path := coverCachePath(cacheDir, coverURL)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		GoLog("[CoverCache] Failed to write cache entry: %v\n", err)
+		return
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		GoLog("[CoverCache] Failed to commit cache entry: %v\n", err)
+		return
+	}
+
+	evictCoverCacheLocked(cacheDir, maxBytes)
+}
+
+// evictCoverCacheLocked removes least recently used entries until the cache
+// fits in maxBytes. coverCacheMu must be held.
+func evictCoverCacheLocked(cacheDir string, maxBytes int64) {
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return
+	}
+
+	type cacheEntry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []cacheEntry
+	var total int64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), coverCacheFileExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cacheEntry{
+			path:    filepath.Join(cacheDir, entry.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+		total += info.Size()
+	}
+	if total <= maxBytes {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	for _, f := range files {
+		if total <= maxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err == nil {
+			total -= f.size
+		}
+	}
+}
+
+func headCoverContentLength(client *http.Client, coverURL string) int64 {
+	req, err := http.NewRequest(http.MethodHead, coverURL, nil)
+	if err != nil {
+		return 0
+	}
+	resp, err := DoRequestWithUserAgent(client, req)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ContentLength < 0 {
+		return 0
+	}
+	return resp.ContentLength
+}
+
+func fetchCoverBytes(client *http.Client, coverURL string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, coverURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", userAgentForURL(req.URL))
+
+	resp, err := doFetchWithRetry(client, req, "cover download")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download cover: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cover download failed: HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cover data: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("cover download returned no data")
+	}
+	return data, nil
+}
+
+// FetchCover picks the best of several candidate URLs for the same cover,
+// closest to preferredDim without going under it (0 = largest), and returns
+// the image bytes and MIME type. Responses are kept in the on-disk cover
+// cache from BackendConfig, and a cached best candidate is returned without
+// touching the network.
+func FetchCover(urls []string, preferredDim int) ([]byte, string, error) {
+	return fetchCover(urls, preferredDim, false)
+}
+
+// FetchCoverNonBlocking is FetchCover for callers that would rather skip a
+// cover than wait, such as thumbnails during a fast scroll: when every heavy
+// operation slot is busy and the cover is not cached it fails with
+// ErrOperationLimitReached instead of queueing.
+func FetchCoverNonBlocking(urls []string, preferredDim int) ([]byte, string, error) {
+	return fetchCover(urls, preferredDim, true)
+}
+
+func fetchCover(urls []string, preferredDim int, nonBlocking bool) ([]byte, string, error) {
+	candidates := make([]coverCandidate, 0, len(urls))
+	seen := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		u = strings.TrimSpace(u)
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		candidates = append(candidates, coverCandidate{url: u, dim: coverDimensionFromURL(u)})
+	}
+	if len(candidates) == 0 {
+		return nil, "", fmt.Errorf("no cover URL provided")
+	}
+
+	cfg := GetBackendConfig()
+	rankCoverCandidates(candidates, preferredDim)
+
+	if data, ok := readCoverCache(cfg.CoverCacheDir, candidates[0].url); ok {
+		GoLog("[Cover] Cache hit for %s\n", candidates[0].url)
+		return data, detectCoverMIME(candidates[0].url, data), nil
+	}
+
+	acquire := acquireHeavyOperation
+	if nonBlocking {
+		acquire = tryAcquireHeavyOperation
+	}
+	release, err := acquire()
+	if err != nil {
+		return nil, "", err
+	}
+	defer release()
+
+	client := NewHTTPClientWithTimeout(DefaultTimeout)
+
+	// Only probe sizes when the URLs alone cannot settle the choice.
+	if len(candidates) > 1 && (candidates[0].dim == 0 || (preferredDim > 0 && candidates[0].dim < preferredDim)) {
+		for i := range candidates {
+			if candidates[i].dim == 0 {
+				candidates[i].contentLength = headCoverContentLength(client, candidates[i].url)
+			}
+		}
+		rankCoverCandidates(candidates, preferredDim)
+	}
+
+	var lastErr error
+	for _, c := range candidates {
+		if data, ok := readCoverCache(cfg.CoverCacheDir, c.url); ok {
+			return data, detectCoverMIME(c.url, data), nil
+		}
+
+		data, err := fetchCoverBytes(client, c.url)
+		if err != nil {
+			GoLog("[Cover] Candidate failed (%s): %v\n", c.url, err)
+			lastErr = err
+			continue
+		}
+
+		writeCoverCache(cfg.CoverCacheDir, c.url, data, cfg.CoverCacheMaxBytes)
+		GoLog("[Cover] Fetched %d KB from %s\n", len(data)/1024, c.url)
+		return data, detectCoverMIME(c.url, data), nil
+	}
+
+	// Offline: fall back to whatever candidate is still in the cache.
+	for _, c := range candidates {
+		if data, ok := readCoverCache(cfg.CoverCacheDir, c.url); ok {
+			return data, detectCoverMIME(c.url, data), nil
+		}
+	}
+	return nil, "", lastErr
+}
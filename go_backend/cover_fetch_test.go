@@ -0,0 +1,133 @@
+package gobackend
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCoverDimensionFromURL(t *testing.T) {
+	cases := map[string]int{
+		"https://i.scdn.co/image/" + spotifySize300 + "abc":                     300,
+		"https://i.scdn.co/image/" + spotifySize640 + "abc":                     640,
+		"https://i.scdn.co/image/" + spotifySizeMax + "abc":                     2000,
+		"https://cdn-images.dzcdn.net/images/cover/x/500x500-000000-80-0-0.jpg": 500,
+		"https://resources.tidal.com/images/a/b/c/1280x1280.jpg":                1280,
+		"https://static.qobuz.com/images/covers/ab/cd/abcd_600.jpg":             600,
+		"https://example.com/cover.jpg":                                         0,
+	}
+	for url, want := range cases {
+		if got := coverDimensionFromURL(url); got != want {
+			t.Fatalf("coverDimensionFromURL(%q) = %d, want %d", url, got, want)
+		}
+	}
+}
+
+func TestRankCoverCandidatesPrefersSmallestCoveringSize(t *testing.T) {
+	candidates := []coverCandidate{
+		{url: "a", dim: 1800},
+		{url: "b", dim: 250},
+		{url: "c", dim: 1000},
+		{url: "d"},
+	}
+	rankCoverCandidates(candidates, 600)
+	if candidates[0].url != "c" {
+		t.Fatalf("best candidate = %s", candidates[0].url)
+	}
+
+	rankCoverCandidates(candidates, 0)
+	if candidates[0].url != "a" {
+		t.Fatalf("largest candidate = %s", candidates[0].url)
+	}
+}
+
+func TestFetchCoverUsesHeadSizeAndServesCacheOffline(t *testing.T) {
+	small := append([]byte{0xFF, 0xD8, 0xFF}, bytes.Repeat([]byte{1}, 100)...)
+	large := append([]byte{0xFF, 0xD8, 0xFF}, bytes.Repeat([]byte{2}, 4000)...)
+
+	var gets atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := small
+		if strings.HasSuffix(r.URL.Path, "/large.jpg") {
+			body = large
+		}
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body)
+	}))
+
+	original := GetBackendConfig()
+	defer SetBackendConfig(original)
+	cfg := original
+	cfg.CoverCacheDir = filepath.Join(t.TempDir(), "covers")
+	SetBackendConfig(cfg)
+
+	urls := []string{server.URL + "/small.jpg", server.URL + "/large.jpg"}
+	data, mimeType, err := FetchCover(urls, 0)
+	if err != nil {
+		t.Fatalf("FetchCover: %v", err)
+	}
+	if !bytes.Equal(data, large) || mimeType != "image/jpeg" {
+		t.Fatalf("got %d bytes (%s), want the larger cover", len(data), mimeType)
+	}
+	if gets.Load() != 1 {
+		t.Fatalf("expected one GET, got %d", gets.Load())
+	}
+
+	server.Close()
+
+	data, _, err = FetchCover(urls, 0)
+	if err != nil {
+		t.Fatalf("offline FetchCover: %v", err)
+	}
+	if !bytes.Equal(data, large) {
+		t.Fatalf("offline fetch returned %d bytes", len(data))
+	}
+}
+
+func TestFetchCoverNonBlockingFailsWhenBusy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("cover"))
+	}))
+	defer server.Close()
+	withBackendConfig(t, func(cfg *BackendConfig) {
+		cfg.MaxConcurrentOperations = 1
+		cfg.CoverCacheDir = ""
+	})
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls := []string{server.URL + "/c.jpg"}
+	if _, _, err := FetchCoverNonBlocking(urls, 0); !errors.Is(err, ErrOperationLimitReached) {
+		t.Fatalf("expected ErrOperationLimitReached, got %v", err)
+	}
+	release()
+	if data, _, err := FetchCoverNonBlocking(urls, 0); err != nil || string(data) != "cover" {
+		t.Fatalf("FetchCoverNonBlocking after release = %q, %v", data, err)
+	}
+}
+
+func TestCoverCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	writeCoverCache(dir, "first", bytes.Repeat([]byte{1}, 600), 1000)
+	writeCoverCache(dir, "second", bytes.Repeat([]byte{2}, 600), 1000)
+
+	if _, err := os.Stat(coverCachePath(dir, "first")); !os.IsNotExist(err) {
+		t.Fatalf("expected oldest entry to be evicted, stat err = %v", err)
+	}
+	if _, ok := readCoverCache(dir, "second"); !ok {
+		t.Fatal("expected newest entry to stay cached")
+	}
+}
@@ -0,0 +1,86 @@
+package gobackend
+
+import (
+	"fmt"
+)
+
+// Cover policies for EmbedOptions.CoverPolicy.
+const (
+	// CoverPolicyReplaceAlways replaces the pictures with the given cover
+	// and keeps them when none is given (the default when empty).
+	CoverPolicyReplaceAlways = "replace_always"
+	// CoverPolicyKeepExisting only embeds the given cover into a file
+	// without one.
+	CoverPolicyKeepExisting = "keep_existing"
+	// CoverPolicyReplaceIfSmaller keeps an existing cover whose shorter side
+	// is at least EmbedOptions.CoverMinDim pixels and replaces a smaller
+	// one. Without a cover to replace it with, the result asks for one.
+	CoverPolicyReplaceIfSmaller = "replace_if_smaller"
+	// CoverPolicyRemoveAll drops every picture block, ignoring the given
+	// cover.
+	CoverPolicyRemoveAll = "remove_all"
+)
+
+// Cover decisions reported in EmbedResult.CoverDecision.
+const (
+	CoverDecisionEmbedded = "embedded"
+	CoverDecisionKept     = "kept_existing"
+	// CoverDecisionNeedsCover: under CoverPolicyReplaceIfSmaller the file
+	// has no cover, or one below the minimum, and none was given. Whatever
+	// the file had is left alone so the app can fetch a better one.
+	CoverDecisionNeedsCover = "needs_cover"
+	CoverDecisionRemoved    = "removed"
+	// CoverDecisionNone: the file has no cover and none was given.
+	CoverDecisionNone = "none"
+)
+
+func validateCoverPolicy(opts EmbedOptions) error {
+	switch opts.CoverPolicy {
+	case "", CoverPolicyReplaceAlways, CoverPolicyKeepExisting, CoverPolicyRemoveAll:
+		return nil
+	case CoverPolicyReplaceIfSmaller:
+		if opts.CoverMinDim <= 0 {
+			return fmt.Errorf("cover policy %s needs a positive cover_min_dim", CoverPolicyReplaceIfSmaller)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown cover policy: %q", opts.CoverPolicy)
+}
+
+// decideCover applies opts.CoverPolicy to the existing cover image (nil
+// when the file has none) and whether a new cover was given. It returns
+// the decision and the dimensions of the existing cover, zero when it
+// cannot be decoded.
+func decideCover(opts EmbedOptions, existing []byte, haveCover bool) (decision string, width, height int) {
+	if existing != nil {
+		if w, h, _, err := coverDimensions(existing); err == nil {
+			width, height = w, h
+		}
+	}
+	replace := func() string {
+		if haveCover {
+			return CoverDecisionEmbedded
+		}
+		if existing != nil {
+			return CoverDecisionKept
+		}
+		return CoverDecisionNone
+	}
+
+	switch opts.CoverPolicy {
+	case CoverPolicyRemoveAll:
+		return CoverDecisionRemoved, width, height
+	case CoverPolicyKeepExisting:
+		if existing != nil {
+			return CoverDecisionKept, width, height
+		}
+	case CoverPolicyReplaceIfSmaller:
+		if existing != nil && min(width, height) >= opts.CoverMinDim {
+			return CoverDecisionKept, width, height
+		}
+		if !haveCover {
+			return CoverDecisionNeedsCover, width, height
+		}
+	}
+	return replace(), width, height
+}
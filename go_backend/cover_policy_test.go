@@ -0,0 +1,77 @@
+package gobackend
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func testCoverOfSize(t *testing.T, dim int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, dim, dim))); err != nil {
+		t.Fatalf("encode cover: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestEmbedAllCoverPolicy(t *testing.T) {
+	small, large := testCoverOfSize(t, 8), testCoverOfSize(t, 32)
+	withCover := func(cover []byte) string {
+		t.Helper()
+		path := writeTestFLACWithMetadata(t, Metadata{Title: "Track"})
+		if cover != nil {
+			if err := EmbedAll(path, Metadata{}, Lyrics{}, cover, EmbedOptions{}); err != nil {
+				t.Fatalf("EmbedAll: %v", err)
+			}
+		}
+		return path
+	}
+
+	cases := []struct {
+		name     string
+		existing []byte
+		given    []byte
+		opts     EmbedOptions
+		decision string
+		want     []byte
+	}{
+		{"default keeps without input", small, nil, EmbedOptions{}, CoverDecisionKept, small},
+		{"default replaces", small, large, EmbedOptions{}, CoverDecisionEmbedded, large},
+		{"default without any cover", nil, nil, EmbedOptions{}, CoverDecisionNone, nil},
+		{"keep existing", small, large, EmbedOptions{CoverPolicy: CoverPolicyKeepExisting}, CoverDecisionKept, small},
+		{"keep existing fills a missing cover", nil, large, EmbedOptions{CoverPolicy: CoverPolicyKeepExisting}, CoverDecisionEmbedded, large},
+		{"large enough is kept", large, small, EmbedOptions{CoverPolicy: CoverPolicyReplaceIfSmaller, CoverMinDim: 16}, CoverDecisionKept, large},
+		{"too small is replaced", small, large, EmbedOptions{CoverPolicy: CoverPolicyReplaceIfSmaller, CoverMinDim: 16}, CoverDecisionEmbedded, large},
+		{"too small without input", small, nil, EmbedOptions{CoverPolicy: CoverPolicyReplaceIfSmaller, CoverMinDim: 16}, CoverDecisionNeedsCover, small},
+		{"missing without input", nil, nil, EmbedOptions{CoverPolicy: CoverPolicyReplaceIfSmaller, CoverMinDim: 16}, CoverDecisionNeedsCover, nil},
+		{"remove all", small, large, EmbedOptions{CoverPolicy: CoverPolicyRemoveAll}, CoverDecisionRemoved, nil},
+	}
+	for _, tc := range cases {
+		path := withCover(tc.existing)
+		result, err := EmbedAllWithResult(path, Metadata{Album: "Album"}, Lyrics{}, tc.given, tc.opts)
+		if err != nil {
+			t.Fatalf("%s: EmbedAllWithResult: %v", tc.name, err)
+		}
+		if result.CoverDecision != tc.decision {
+			t.Fatalf("%s: decision = %q, want %q", tc.name, result.CoverDecision, tc.decision)
+		}
+		embedded, _ := ExtractCoverArt(path)
+		if !bytes.Equal(embedded, tc.want) {
+			t.Fatalf("%s: embedded cover of %d bytes, want %d", tc.name, len(embedded), len(tc.want))
+		}
+		if tc.existing != nil && (result.ExistingCoverWidth == 0 || result.ExistingCoverWidth != result.ExistingCoverHeight) {
+			t.Fatalf("%s: existing cover size %dx%d", tc.name, result.ExistingCoverWidth, result.ExistingCoverHeight)
+		}
+	}
+}
+
+func TestEmbedAllRejectsInvalidCoverPolicy(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Track"})
+	for _, opts := range []EmbedOptions{{CoverPolicy: "sometimes"}, {CoverPolicy: CoverPolicyReplaceIfSmaller}} {
+		if err := EmbedAll(path, Metadata{}, Lyrics{}, nil, opts); err == nil {
+			t.Fatalf("expected %+v to be refused", opts)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package gobackend
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	stdimage "image"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// coverDecodeMaxPixels caps the images validateCoverImage decodes, so a
+// header claiming huge dimensions cannot make it allocate the pixels.
+const coverDecodeMaxPixels = 25_000_000
+
+// validateCoverImage checks that data is a complete image. JPEG, PNG and GIF
+// are decoded in full, which catches truncated and garbled data; WebP cannot
+// be decoded here, and images over coverDecodeMaxPixels are not, so for
+// those only the header is checked.
+func validateCoverImage(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("cover is empty")
+	}
+	width, height, format, err := coverDimensions(data)
+	if err != nil {
+		return err
+	}
+	if format == "webp" {
+		if riffSize := int64(binary.LittleEndian.Uint32(data[4:8])) + 8; riffSize > int64(len(data)) {
+			return fmt.Errorf("webp is truncated: %d of %d bytes", len(data), riffSize)
+		}
+		return nil
+	}
+	if int64(width)*int64(height) > coverDecodeMaxPixels {
+		return nil
+	}
+	if _, _, err := stdimage.Decode(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("%s does not decode: %w", format, err)
+	}
+	return nil
+}
+
+// CorruptCover is a track whose embedded cover failed validateCoverImage.
+type CorruptCover struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// AlbumCoverRepair lists the corrupt covers of one album directory and what
+// RepairAlbumCovers did about them. Donor is the track the replacement was
+// taken from; it is empty when no sibling had a usable cover, and NoDonor
+// then says why.
+type AlbumCoverRepair struct {
+	// Directory is relative to the scanned root ("." for the root itself).
+	Directory string            `json:"directory"`
+	Corrupt   []CorruptCover    `json:"corrupt"`
+	Donor     string            `json:"donor,omitempty"`
+	DonorHash string            `json:"donor_hash,omitempty"`
+	NoDonor   string            `json:"no_donor,omitempty"`
+	Repaired  []string          `json:"repaired,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// AlbumCoverRepairReport is the result of RepairAlbumCovers. Details holds
+// only the albums with at least one corrupt cover.
+type AlbumCoverRepairReport struct {
+	Root               string             `json:"root"`
+	Albums             int                `json:"albums"`
+	Covers             int                `json:"covers"`
+	Corrupt            int                `json:"corrupt"`
+	Repaired           int                `json:"repaired"`
+	Failed             int                `json:"failed"`
+	QuarantinedSkipped int                `json:"quarantined_skipped"`
+	NoDonorAlbums      int                `json:"no_donor_albums"`
+	Details            []AlbumCoverRepair `json:"details"`
+}
+
+// albumCoverDonor picks the valid cover held by more tracks than any other.
+// It returns false with a reason when there is no valid cover or the top
+// variants tie, since a tie leaves no way to tell which cover is the
+// album's.
+func albumCoverDonor(valid []albumCover) (albumCover, string, bool) {
+	if len(valid) == 0 {
+		return albumCover{}, "no sibling track has a valid cover", false
+	}
+	variants := groupAlbumCovers(valid)
+	sort.SliceStable(variants, func(i, j int) bool { return len(variants[i].Tracks) > len(variants[j].Tracks) })
+	if len(variants) > 1 && len(variants[0].Tracks) == len(variants[1].Tracks) {
+		return albumCover{}, "sibling tracks disagree on the cover", false
+	}
+	for _, cover := range valid {
+		if cover.hash == variants[0].Hash {
+			return cover, "", true
+		}
+	}
+	return albumCover{}, "no sibling track has a valid cover", false
+}
+
+var coverRepairProgress batchProgress
+
+// GetAlbumCoverRepairProgress reports the running RepairAlbumCovers, one
+// item per album directory.
+func GetAlbumCoverRepairProgress() string {
+	return coverRepairProgress.json()
+}
+
+// RepairAlbumCovers validates the embedded cover of every track under
+// dirPath and replaces each corrupt one with the cover most valid siblings
+// in the same directory share. Tracks without a cover are left alone.
+// Albums where no sibling has a valid cover, or where the valid covers tie,
+// are reported without changes.
+func RepairAlbumCovers(dirPath string) (string, error) {
+	if strings.TrimSpace(dirPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(dirPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", dirPath)
+	}
+	if err := checkWriteAllowed(dirPath); err != nil {
+		return "", err
+	}
+
+	files, quarantined, err := collectWritableLibraryFiles(dirPath, nil)
+	if err != nil {
+		return "", err
+	}
+	tracksByDir, dirs, dirSizes := groupLibraryAlbums(files)
+	coverRepairProgress.start(dirSizes, 1)
+	defer coverRepairProgress.finish()
+
+	report := AlbumCoverRepairReport{Root: dirPath, Details: []AlbumCoverRepair{}}
+	report.QuarantinedSkipped = quarantined
+	for i, dir := range dirs {
+		done := coverRepairProgress.item(dir, dirSizes[i])
+		tracks := tracksByDir[dir]
+		sort.Strings(tracks)
+		report.Albums++
+
+		album := AlbumCoverRepair{Directory: dir}
+		if rel, err := filepath.Rel(dirPath, dir); err == nil {
+			album.Directory = rel
+		}
+		var valid []albumCover
+		var corrupt []string
+		for _, track := range tracks {
+			data, _, err := extractAnyCoverArt(track)
+			if err != nil || len(data) == 0 {
+				continue
+			}
+			report.Covers++
+			if err := validateCoverImage(data); err != nil {
+				album.Corrupt = append(album.Corrupt, CorruptCover{Path: track, Error: err.Error()})
+				corrupt = append(corrupt, track)
+				continue
+			}
+			sum := sha256.Sum256(data)
+			valid = append(valid, albumCover{track: track, data: data, hash: hex.EncodeToString(sum[:])})
+		}
+		if len(corrupt) == 0 {
+			done()
+			continue
+		}
+		report.Corrupt += len(corrupt)
+
+		donor, reason, ok := albumCoverDonor(valid)
+		if !ok {
+			album.NoDonor = reason
+			report.NoDonorAlbums++
+			report.Details = append(report.Details, album)
+			done()
+			continue
+		}
+		album.Donor, album.DonorHash = donor.track, donor.hash
+		failures := embedCoverBatch(corrupt, donor.data)
+		for _, track := range corrupt {
+			if err, failed := failures[track]; failed {
+				if album.Errors == nil {
+					album.Errors = make(map[string]string)
+				}
+				album.Errors[track] = err.Error()
+				report.Failed++
+				continue
+			}
+			album.Repaired = append(album.Repaired, track)
+			report.Repaired++
+		}
+		report.Details = append(report.Details, album)
+		done()
+	}
+
+	GoLog("[CoverRepair] %d corrupt covers under %s: %d repaired, %d failed, %d albums without a donor\n",
+		report.Corrupt, dirPath, report.Repaired, report.Failed, report.NoDonorAlbums)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
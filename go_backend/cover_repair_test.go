@@ -0,0 +1,86 @@
+package gobackend
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateCoverImage(t *testing.T) {
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	if err := validateCoverImage(cover); err != nil {
+		t.Fatalf("valid cover rejected: %v", err)
+	}
+	if err := validateCoverImage(cover[:len(cover)/2]); err == nil {
+		t.Fatal("truncated PNG accepted")
+	}
+	if err := validateCoverImage([]byte("not an image")); err == nil {
+		t.Fatal("garbage accepted")
+	}
+
+	webp := make([]byte, 30)
+	copy(webp, "RIFF")
+	copy(webp[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(webp[16:20], 5)
+	webp[20] = 0x2F
+	binary.LittleEndian.PutUint32(webp[4:8], uint32(len(webp)-8))
+	if err := validateCoverImage(webp); err != nil {
+		t.Fatalf("webp rejected: %v", err)
+	}
+	binary.LittleEndian.PutUint32(webp[4:8], 1000)
+	if err := validateCoverImage(webp); err == nil {
+		t.Fatal("truncated webp accepted")
+	}
+}
+
+func TestRepairAlbumCovers(t *testing.T) {
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	broken := cover[:len(cover)-20]
+
+	root := t.TempDir()
+	a := writeConsistencyFixture(t, root, "Album/01.flac", Metadata{Title: "1"})
+	b := writeConsistencyFixture(t, root, "Album/02.flac", Metadata{Title: "2"})
+	bad := writeConsistencyFixture(t, root, "Album/03.flac", Metadata{Title: "3"})
+	writeConsistencyFixture(t, root, "Album/04.flac", Metadata{Title: "4"})
+	lonely := writeConsistencyFixture(t, root, "Single/01.flac", Metadata{Title: "S"})
+	writeConsistencyFixture(t, root, "Single/02.flac", Metadata{Title: "T"})
+	for path, data := range map[string][]byte{a: cover, b: cover, bad: broken, lonely: broken} {
+		if failures := embedCoverBatch([]string{path}, data); len(failures) > 0 {
+			t.Fatalf("embedCoverBatch: %v", failures)
+		}
+	}
+
+	out, err := RepairAlbumCovers(root)
+	report := mustDecodeJSON[AlbumCoverRepairReport](t, out, err)
+	if report.Albums != 2 || report.Covers != 4 || report.Corrupt != 2 || report.Repaired != 1 || report.NoDonorAlbums != 1 || len(report.Details) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	album := report.Details[0]
+	if album.Directory != "Album" || album.Donor != a || len(album.Repaired) != 1 || album.Repaired[0] != bad {
+		t.Fatalf("album = %+v", album)
+	}
+	if single := report.Details[1]; single.Directory != "Single" || single.NoDonor == "" || single.Donor != "" {
+		t.Fatalf("single = %+v", single)
+	}
+	if got, err := ExtractCoverArt(bad); err != nil || string(got) != string(cover) {
+		t.Fatalf("cover not repaired: %v", err)
+	}
+	if got, err := ExtractCoverArt(lonely); err != nil || string(got) != string(broken) {
+		t.Fatalf("cover without donor changed: %v", err)
+	}
+
+	out, err = RepairAlbumCovers(filepath.Join(root, "Album"))
+	if err != nil {
+		t.Fatalf("second RepairAlbumCovers: %v", err)
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil || report.Corrupt != 0 || len(report.Details) != 0 {
+		t.Fatalf("second run = %+v %v", report, err)
+	}
+}
@@ -0,0 +1,87 @@
+package gobackend
+
+import (
+	"bytes"
+	"fmt"
+	stdimage "image"
+	"image/draw"
+	"image/jpeg"
+)
+
+const defaultCoverJPEGQuality = 90
+
+// resizeCoverJPEG scales cover art down so neither side exceeds maxDim and
+// re-encodes it as JPEG at quality (defaultCoverJPEGQuality when 0). Smaller
+// images are only re-encoded. Pixels are box-averaged, which is sharp enough
+// for the large reductions covers need and avoids an imaging dependency.
+func resizeCoverJPEG(data []byte, maxDim, quality int) ([]byte, stdimage.Rectangle, error) {
+	if maxDim <= 0 {
+		return nil, stdimage.Rectangle{}, fmt.Errorf("max dimension must be positive")
+	}
+	if quality == 0 {
+		quality = defaultCoverJPEGQuality
+	}
+	if quality < 1 || quality > 100 {
+		return nil, stdimage.Rectangle{}, fmt.Errorf("JPEG quality must be between 1 and 100")
+	}
+
+	src, _, err := stdimage.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, stdimage.Rectangle{}, fmt.Errorf("failed to decode cover: %w", err)
+	}
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, stdimage.Rectangle{}, fmt.Errorf("cover has no pixels")
+	}
+
+	dstWidth, dstHeight := width, height
+	if longest := max(width, height); longest > maxDim {
+		dstWidth = max(1, width*maxDim/longest)
+		dstHeight = max(1, height*maxDim/longest)
+	}
+
+	rgba := stdimage.NewRGBA(stdimage.Rect(0, 0, width, height))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	dst := rgba
+	if dstWidth != width || dstHeight != height {
+		dst = boxDownscale(rgba, dstWidth, dstHeight)
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, stdimage.Rectangle{}, fmt.Errorf("failed to encode cover: %w", err)
+	}
+	return out.Bytes(), dst.Bounds(), nil
+}
+
+// boxDownscale averages every source pixel into the destination pixel whose
+// area covers it.
+func boxDownscale(src *stdimage.RGBA, dstWidth, dstHeight int) *stdimage.RGBA {
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := stdimage.NewRGBA(stdimage.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		y0, y1 := y*srcHeight/dstHeight, max((y+1)*srcHeight/dstHeight, y*srcHeight/dstHeight+1)
+		for x := 0; x < dstWidth; x++ {
+			x0, x1 := x*srcWidth/dstWidth, max((x+1)*srcWidth/dstWidth, x*srcWidth/dstWidth+1)
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+			o := dst.PixOffset(x, y)
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(b / n)
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
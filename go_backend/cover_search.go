@@ -0,0 +1,285 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	deezerAlbumCoverDim       = 1800
+	itunesAlbumCoverDim       = 3000
+	coverSearchMinSimilarity  = 0.5
+	coverSearchDefaultMinDim  = 500
+	coverSearchResultsPerSite = 10
+)
+
+// Search endpoints are vars so tests can serve canned responses.
+var (
+	deezerCoverSearchURL = "https://api.deezer.com/search/album"
+	itunesCoverSearchURL = "https://itunes.apple.com/search"
+)
+
+type CoverArtCandidate struct {
+	URL    string  `json:"url"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	Source string  `json:"source"`
+	Artist string  `json:"artist"`
+	Album  string  `json:"album"`
+	Score  float64 `json:"score"`
+}
+
+type deezerAlbumSearchResponse struct {
+	Data []struct {
+		Title   string `json:"title"`
+		CoverXL string `json:"cover_xl"`
+		Artist  struct {
+			Name string `json:"name"`
+		} `json:"artist"`
+	} `json:"data"`
+}
+
+type itunesAlbumSearchResponse struct {
+	Results []struct {
+		CollectionName string `json:"collectionName"`
+		ArtistName     string `json:"artistName"`
+		ArtworkURL100  string `json:"artworkUrl100"`
+	} `json:"results"`
+}
+
+func fetchCoverSearchJSON(reqURL string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("User-Agent", userAgentForURL(req.URL))
+
+	client := NewMetadataHTTPClient(15 * time.Second)
+	resp, err := doFetchWithRetry(client, req, "cover search")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("search API returned status: %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func searchDeezerCovers(artist, album string) ([]CoverArtCandidate, error) {
+	query := fmt.Sprintf(`artist:"%s" album:"%s"`, artist, album)
+	reqURL := fmt.Sprintf("%s?q=%s&limit=%d", deezerCoverSearchURL, url.QueryEscape(query), coverSearchResultsPerSite)
+
+	var payload deezerAlbumSearchResponse
+	if err := fetchCoverSearchJSON(reqURL, &payload); err != nil {
+		return nil, fmt.Errorf("deezer cover search failed: %w", err)
+	}
+
+	var candidates []CoverArtCandidate
+	for _, item := range payload.Data {
+		if item.CoverXL == "" {
+			continue
+		}
+		coverURL := item.CoverXL
+		dim := coverDimensionFromURL(coverURL)
+		if upgraded := upgradeDeezerCover(coverURL); upgraded != coverURL {
+			coverURL, dim = upgraded, deezerAlbumCoverDim
+		}
+		candidates = append(candidates, CoverArtCandidate{
+			URL:    coverURL,
+			Width:  dim,
+			Height: dim,
+			Source: "deezer",
+			Artist: item.Artist.Name,
+			Album:  item.Title,
+		})
+	}
+	return candidates, nil
+}
+
+func searchITunesCovers(artist, album string) ([]CoverArtCandidate, error) {
+	reqURL := fmt.Sprintf(
+		"%s?term=%s&entity=album&limit=%d",
+		itunesCoverSearchURL,
+		url.QueryEscape(artist+" "+album),
+		coverSearchResultsPerSite,
+	)
+
+	var payload itunesAlbumSearchResponse
+	if err := fetchCoverSearchJSON(reqURL, &payload); err != nil {
+		return nil, fmt.Errorf("itunes cover search failed: %w", err)
+	}
+
+	var candidates []CoverArtCandidate
+	for _, item := range payload.Results {
+		if item.ArtworkURL100 == "" {
+			continue
+		}
+		// The artwork CDN renders any requested size up to the original.
+		coverURL := strings.Replace(item.ArtworkURL100, "100x100bb", fmt.Sprintf("%dx%dbb", itunesAlbumCoverDim, itunesAlbumCoverDim), 1)
+		dim := 100
+		if coverURL != item.ArtworkURL100 {
+			dim = itunesAlbumCoverDim
+		}
+		candidates = append(candidates, CoverArtCandidate{
+			URL:    coverURL,
+			Width:  dim,
+			Height: dim,
+			Source: "itunes",
+			Artist: item.ArtistName,
+			Album:  item.CollectionName,
+		})
+	}
+	return candidates, nil
+}
+
+// scoreCoverArtCandidate weighs name similarity over resolution, so a
+// bigger cover never beats the right album.
+func scoreCoverArtCandidate(c CoverArtCandidate, artist, album string) (float64, float64) {
+	artistSim := calculateStringSimilarity(normalizeLooseArtistName(artist), normalizeLooseArtistName(c.Artist))
+	albumSim := calculateStringSimilarity(normalizeLooseTitle(album), normalizeLooseTitle(c.Album))
+	similarity := (artistSim + albumSim) / 2
+
+	resolution := min(float64(min(c.Width, c.Height))/itunesAlbumCoverDim, 1)
+	return similarity, similarity*0.8 + resolution*0.2
+}
+
+// rankCoverArtCandidates drops poor matches and covers under minDim, then
+// sorts best first. Ties break on source and URL so identical responses
+// always produce the same order.
+func rankCoverArtCandidates(candidates []CoverArtCandidate, artist, album string, minDim int) []CoverArtCandidate {
+	ranked := make([]CoverArtCandidate, 0, len(candidates))
+	seen := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		if seen[c.URL] || min(c.Width, c.Height) < minDim {
+			continue
+		}
+		similarity, score := scoreCoverArtCandidate(c, artist, album)
+		if similarity < coverSearchMinSimilarity {
+			continue
+		}
+		seen[c.URL] = true
+		c.Score = score
+		ranked = append(ranked, c)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.URL < b.URL
+	})
+	return ranked
+}
+
+func searchCoverArt(artist, album string, minDim int) ([]CoverArtCandidate, error) {
+	artist, album = strings.TrimSpace(artist), strings.TrimSpace(album)
+	if artist == "" && album == "" {
+		return nil, fmt.Errorf("artist and album are empty")
+	}
+
+	var all []CoverArtCandidate
+	var errs []error
+	for _, search := range []func(string, string) ([]CoverArtCandidate, error){searchDeezerCovers, searchITunesCovers} {
+		candidates, err := search(artist, album)
+		if err != nil {
+			GoLog("[CoverSearch] %v\n", err)
+			errs = append(errs, err)
+			continue
+		}
+		all = append(all, candidates...)
+	}
+	if len(errs) == 2 {
+		return nil, fmt.Errorf("cover search failed: %w", errors.Join(errs...))
+	}
+
+	return rankCoverArtCandidates(all, artist, album, minDim), nil
+}
+
+// SearchCoverArt looks up album art on the Deezer and iTunes search APIs and
+// returns a JSON array of candidates, best first.
+func SearchCoverArt(artist, album string, minDim int) (string, error) {
+	candidates, err := searchCoverArt(artist, album, minDim)
+	if err != nil {
+		return "", err
+	}
+
+	jsonBytes, err := json.Marshal(candidates)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// FindAndEmbedBestCover searches artwork for a FLAC file's artist and album,
+// downloads the best candidate through FetchCover and embeds it with the
+// candidate URL as its TagCoverArtSource. Returns the chosen candidate as
+// JSON.
+func FindAndEmbedBestCover(filePath string) (string, error) {
+	return findAndEmbedBestCover(filePath, EmbedOptions{})
+}
+
+// FindAndEmbedBestCoverWithOptions is FindAndEmbedBestCover with EmbedOptions
+// passed as JSON, e.g. {"omit_cover_source": true} to leave the source out.
+// CoverSource is always the chosen candidate's URL.
+func FindAndEmbedBestCoverWithOptions(filePath, optionsJSON string) (string, error) {
+	var opts EmbedOptions
+	if strings.TrimSpace(optionsJSON) != "" {
+		if err := json.Unmarshal([]byte(optionsJSON), &opts); err != nil {
+			return "", fmt.Errorf("invalid embed options JSON: %w", err)
+		}
+	}
+	return findAndEmbedBestCover(filePath, opts)
+}
+
+func findAndEmbedBestCover(filePath string, opts EmbedOptions) (string, error) {
+	meta, err := ReadMetadata(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	artist := meta.AlbumArtist
+	if artist == "" {
+		artist = meta.Artist
+	}
+	candidates, err := searchCoverArt(artist, meta.Album, coverSearchDefaultMinDim)
+	if err != nil {
+		return "", err
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no cover art found for %s - %s", artist, meta.Album)
+	}
+
+	var lastErr error
+	for _, c := range candidates {
+		data, _, err := FetchCover([]string{c.URL}, coverSearchDefaultMinDim)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		opts.CoverSource = c.URL
+		if err := EmbedAll(filePath, Metadata{}, Lyrics{}, data, opts); err != nil {
+			return "", err
+		}
+
+		GoLog("[CoverSearch] Embedded %s cover (%dx%d) into %s\n", c.Source, c.Width, c.Height, filePath)
+		jsonBytes, err := json.Marshal(c)
+		if err != nil {
+			return "", err
+		}
+		return string(jsonBytes), nil
+	}
+	return "", fmt.Errorf("failed to download any cover candidate: %w", lastErr)
+}
@@ -0,0 +1,96 @@
+package gobackend
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func useTestCoverSearchServer(t *testing.T, coverData []byte) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/deezer":
+			fmt.Fprintf(w, `{"data":[
+				{"title":"Album (Deluxe)","cover_xl":"%[1]s/dz/deluxe/1000x1000-000000-80-0-0.jpg","artist":{"name":"Artist"}},
+				{"title":"Album","cover_xl":"%[1]s/dz/album/1000x1000-000000-80-0-0.jpg","artist":{"name":"Artist"}},
+				{"title":"Unrelated","cover_xl":"%[1]s/dz/other/1000x1000-000000-80-0-0.jpg","artist":{"name":"Someone Else"}},
+				{"title":"Album","cover_xl":"%[1]s/dz/tiny/250x250-000000-80-0-0.jpg","artist":{"name":"Artist"}}
+			]}`, server.URL)
+		case r.URL.Path == "/itunes":
+			fmt.Fprintf(w, `{"results":[
+				{"collectionName":"Album","artistName":"Artist","artworkUrl100":"%s/it/album/100x100bb.jpg"}
+			]}`, server.URL)
+		case strings.HasPrefix(r.URL.Path, "/it/") || strings.HasPrefix(r.URL.Path, "/dz/"):
+			w.Write(coverData)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	origDeezer, origITunes := deezerCoverSearchURL, itunesCoverSearchURL
+	deezerCoverSearchURL = server.URL + "/deezer"
+	itunesCoverSearchURL = server.URL + "/itunes"
+	t.Cleanup(func() {
+		deezerCoverSearchURL, itunesCoverSearchURL = origDeezer, origITunes
+	})
+	return server
+}
+
+func TestSearchCoverArtRanksDeterministically(t *testing.T) {
+	server := useTestCoverSearchServer(t, nil)
+
+	first, err := SearchCoverArt("Artist", "Album", 500)
+	if err != nil {
+		t.Fatalf("SearchCoverArt: %v", err)
+	}
+	second, _ := SearchCoverArt("Artist", "Album", 500)
+	if first != second {
+		t.Fatalf("results not deterministic:\n%s\n%s", first, second)
+	}
+
+	var candidates []CoverArtCandidate
+	if err := json.Unmarshal([]byte(first), &candidates); err != nil {
+		t.Fatalf("result JSON: %v", err)
+	}
+	if len(candidates) != 3 {
+		t.Fatalf("expected 3 candidates (unrelated and undersized dropped), got %d: %s", len(candidates), first)
+	}
+	if candidates[0].Source != "itunes" || candidates[0].URL != server.URL+"/it/album/3000x3000bb.jpg" {
+		t.Fatalf("expected exact-match hi-res iTunes cover first, got %+v", candidates[0])
+	}
+	if candidates[1].URL != server.URL+"/dz/album/1000x1000-000000-80-0-0.jpg" {
+		t.Fatalf("expected exact-match Deezer cover second, got %+v", candidates[1])
+	}
+}
+
+func TestFindAndEmbedBestCover(t *testing.T) {
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	useTestCoverSearchServer(t, cover)
+	withBackendConfig(t, func(cfg *BackendConfig) { cfg.CoverCacheDir = "" })
+
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", Artist: "Artist", Album: "Album"})
+	if _, err := FindAndEmbedBestCover(path); err != nil {
+		t.Fatalf("FindAndEmbedBestCover: %v", err)
+	}
+
+	embedded, err := ExtractCoverArt(path)
+	if err != nil {
+		t.Fatalf("ExtractCoverArt: %v", err)
+	}
+	if !bytes.Equal(embedded, cover) {
+		t.Fatalf("embedded cover mismatch: %d bytes", len(embedded))
+	}
+	if meta, _ := ReadMetadata(path); meta.Title != "Song" {
+		t.Fatalf("existing tags lost: %+v", meta)
+	}
+}
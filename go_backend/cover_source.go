@@ -0,0 +1,71 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/go-flac/flacvorbis/v2"
+)
+
+// TagCoverArtSource records where an auto-fetched cover came from, as a URL
+// or provider name, so a wrong cover can be traced back to its source.
+const TagCoverArtSource = "COVERART_SOURCE"
+
+// applyCoverSource keeps TagCoverArtSource in step with the cover decision:
+// an embedded cover gets opts.CoverSource unless the caller opted out, and a
+// cover replaced by bytes of unknown origin or removed loses the old one.
+func (m *vorbisCommentMap) applyCoverSource(decision string, opts EmbedOptions) {
+	switch decision {
+	case CoverDecisionEmbedded:
+		if source := strings.TrimSpace(opts.CoverSource); source != "" && !opts.OmitCoverSource {
+			m.set(TagCoverArtSource, source)
+			return
+		}
+		m.remove(TagCoverArtSource)
+	case CoverDecisionRemoved:
+		m.remove(TagCoverArtSource)
+	}
+}
+
+// clearCoverSource drops TagCoverArtSource from cmt, for the embeds that
+// replace the cover with caller-provided bytes.
+func clearCoverSource(cmt *flacvorbis.MetaDataBlockVorbisComment) {
+	comments := newVorbisCommentMap(cmt.Comments)
+	comments.remove(TagCoverArtSource)
+	cmt.Comments = comments.comments()
+}
+
+// CoverInfo describes the cover ExtractCoverArt returns for a FLAC file.
+// Source is its TagCoverArtSource, empty when the cover was not fetched
+// automatically or the file predates the tag.
+type CoverInfo struct {
+	HasCover bool   `json:"has_cover"`
+	MIMEType string `json:"mime_type,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	Size     int    `json:"size,omitempty"`
+	Source   string `json:"source,omitempty"`
+}
+
+// GetCoverInfo returns the CoverInfo of a FLAC file as JSON.
+func GetCoverInfo(filePath string) (string, error) {
+	metadata, err := ReadMetadata(filePath)
+	if err != nil {
+		return "", err
+	}
+	info := CoverInfo{Source: metadata.CoverSource}
+	if data, err := ExtractCoverArt(filePath); err == nil && len(data) > 0 {
+		info.HasCover = true
+		info.MIMEType = detectCoverMIME("", data)
+		info.Size = len(data)
+		if width, height, _, err := coverDimensions(data); err == nil {
+			info.Width, info.Height = width, height
+		}
+	}
+
+	jsonBytes, err := json.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,68 @@
+package gobackend
+
+import "testing"
+
+func readCoverInfo(t *testing.T, path string) CoverInfo {
+	t.Helper()
+	out, err := GetCoverInfo(path)
+	info := mustDecodeJSON[CoverInfo](t, out, err)
+	return info
+}
+
+func TestCoverSourceFollowsTheCover(t *testing.T) {
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	server := useTestCoverSearchServer(t, cover)
+	withBackendConfig(t, func(cfg *BackendConfig) { cfg.CoverCacheDir = "" })
+
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", Artist: "Artist", Album: "Album"})
+	if _, err := FindAndEmbedBestCover(path); err != nil {
+		t.Fatalf("FindAndEmbedBestCover: %v", err)
+	}
+	info := readCoverInfo(t, path)
+	if !info.HasCover || info.MIMEType == "" || info.Width == 0 || info.Source != server.URL+"/it/album/3000x3000bb.jpg" {
+		t.Fatalf("info = %+v", info)
+	}
+
+	if err := EmbedMetadataWithCoverData(path, Metadata{}, cover); err != nil {
+		t.Fatalf("EmbedMetadataWithCoverData: %v", err)
+	}
+	if info := readCoverInfo(t, path); !info.HasCover || info.Source != "" {
+		t.Fatalf("user cover kept the source: %+v", info)
+	}
+
+	if err := EmbedAll(path, Metadata{}, Lyrics{}, cover, EmbedOptions{CoverSource: "deezer"}); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	if info := readCoverInfo(t, path); info.Source != "deezer" {
+		t.Fatalf("info = %+v", info)
+	}
+	if err := EmbedAll(path, Metadata{}, Lyrics{}, nil, EmbedOptions{CoverPolicy: CoverPolicyRemoveAll}); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	if info := readCoverInfo(t, path); info.HasCover || info.Source != "" {
+		t.Fatalf("removed cover kept the source: %+v", info)
+	}
+}
+
+func TestCoverSourceOptOut(t *testing.T) {
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	useTestCoverSearchServer(t, cover)
+	withBackendConfig(t, func(cfg *BackendConfig) { cfg.CoverCacheDir = "" })
+
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", Artist: "Artist", Album: "Album"})
+	if err := EmbedAll(path, Metadata{}, Lyrics{}, cover, EmbedOptions{CoverSource: "deezer"}); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	if _, err := FindAndEmbedBestCoverWithOptions(path, `{"omit_cover_source": true}`); err != nil {
+		t.Fatalf("FindAndEmbedBestCoverWithOptions: %v", err)
+	}
+	if info := readCoverInfo(t, path); !info.HasCover || info.Source != "" {
+		t.Fatalf("info = %+v", info)
+	}
+}
@@ -196,6 +196,20 @@ func mustReadFile(t *testing.T, path string) []byte {
 	return data
 }
 
+// mustDecodeJSON fails t unless err is nil and raw decodes as a T, for the
+// exported APIs that return their result as JSON.
+func mustDecodeJSON[T any](t *testing.T, raw string, err error) T {
+	t.Helper()
+	var result T
+	if err != nil {
+		t.Fatalf("%T result: %v", result, err)
+	}
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		t.Fatalf("decode %T: %v", result, err)
+	}
+	return result
+}
+
 func buildID3v23Tag(frames ...[]byte) []byte {
 	body := bytes.Join(frames, nil)
 	header := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, 0}
@@ -0,0 +1,44 @@
+package gobackend
+
+// yearFromDate returns the leading 4-digit year of a DATE value such as
+// "2021-03-05" or "2021", or "" when it does not start with one.
+func yearFromDate(date string) string {
+	if len(date) < 4 {
+		return ""
+	}
+	for i := range 4 {
+		if date[i] < '0' || date[i] > '9' {
+			return ""
+		}
+	}
+	if len(date) > 4 && date[4] >= '0' && date[4] <= '9' {
+		return ""
+	}
+	return date[:4]
+}
+
+// syncYearTag sets YEAR to the year of the map's DATE, for players that
+// read only YEAR. A DATE without a year leaves YEAR alone.
+func (m *vorbisCommentMap) syncYearTag() {
+	if year := yearFromDate(m.get("DATE")); year != "" {
+		m.set("YEAR", year)
+	}
+}
+
+// reconcileDateYear picks the date from the DATE and YEAR comments: DATE
+// wins, YEAR only fills in when DATE is missing. When both have a year and
+// they differ, the YEAR value is returned as the conflict and logged.
+func reconcileDateYear(filePath, date, year string) (string, string) {
+	if date == "" {
+		return year, ""
+	}
+	if year == "" {
+		return date, ""
+	}
+	dateYear, yearYear := yearFromDate(date), yearFromDate(year)
+	if dateYear != "" && yearYear != "" && dateYear != yearYear {
+		GoLog("[Metadata] Warning: YEAR %q disagrees with DATE %q, using DATE %s\n", year, date, filePath)
+		return date, year
+	}
+	return date, ""
+}
@@ -0,0 +1,92 @@
+package gobackend
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestYearFromDate(t *testing.T) {
+	for date, want := range map[string]string{
+		"2021-03-05": "2021",
+		"2021":       "2021",
+		"1999/12":    "1999",
+		"20210305":   "",
+		"99":         "",
+		"March 2021": "",
+		"":           "",
+	} {
+		if got := yearFromDate(date); got != want {
+			t.Fatalf("yearFromDate(%q) = %q, want %q", date, got, want)
+		}
+	}
+}
+
+func TestReconcileDateYear(t *testing.T) {
+	tests := []struct {
+		date, year, want, conflict string
+	}{
+		{"2021-03-05", "2021", "2021-03-05", ""},
+		{"2021-03-05", "2019", "2021-03-05", "2019"},
+		{"", "2019", "2019", ""},
+		{"2021", "", "2021", ""},
+		{"unknown", "2019", "unknown", ""},
+	}
+	for _, tt := range tests {
+		got, conflict := reconcileDateYear("test.flac", tt.date, tt.year)
+		if got != tt.want || conflict != tt.conflict {
+			t.Fatalf("reconcileDateYear(%q, %q) = %q, %q", tt.date, tt.year, got, conflict)
+		}
+	}
+}
+
+func readTestComments(t *testing.T, path string) []string {
+	t.Helper()
+	comments, err := readVorbisCommentList(path)
+	if err != nil {
+		t.Fatalf("readVorbisCommentList: %v", err)
+	}
+	return comments
+}
+
+func TestYearTagWriting(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	if _, err := EmbedAllWithResult(path, Metadata{Date: "2021-03-05"}, Lyrics{}, nil, EmbedOptions{}); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	if comments := readTestComments(t, path); slices.ContainsFunc(comments, func(c string) bool { return vorbisCommentValue(c) == "2021" }) {
+		t.Fatalf("YEAR written by default: %q", comments)
+	}
+
+	if _, err := EmbedAllWithResult(path, Metadata{}, Lyrics{}, nil, EmbedOptions{CompatProfile: CompatProfileStrictCar}); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	if comments := readTestComments(t, path); !slices.Contains(comments, "YEAR=2021") {
+		t.Fatalf("strict-car profile did not write YEAR: %q", comments)
+	}
+
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	cfg := original
+	cfg.WriteYearTag = true
+	if err := SetBackendConfig(cfg); err != nil {
+		t.Fatalf("SetBackendConfig: %v", err)
+	}
+	if err := EditFlacFields(path, map[string]string{"date": "1999-12-31"}); err != nil {
+		t.Fatalf("EditFlacFields: %v", err)
+	}
+	comments := readTestComments(t, path)
+	if !slices.Contains(comments, "DATE=1999-12-31") || !slices.Contains(comments, "YEAR=1999") || slices.Contains(comments, "YEAR=2021") {
+		t.Fatalf("comments = %q", comments)
+	}
+}
+
+func TestReadMetadataReportsYearConflict(t *testing.T) {
+	path := writeMalformedCommentFLAC(t, "TITLE=Song", "DATE=2021-03-05", "YEAR=2019")
+	metadata, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if metadata.Date != "2021-03-05" || metadata.YearConflict != "2019" {
+		t.Fatalf("date %q, conflict %q", metadata.Date, metadata.YearConflict)
+	}
+}
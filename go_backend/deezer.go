@@ -25,10 +25,8 @@ const (
 
 	deezerMaxParallelISRC = 10
 
-	// Deezer API timeout and retry configuration for mobile networks
+	// Deezer API timeout for mobile networks
 	deezerAPITimeoutMobile = 25 * time.Second
-	deezerMaxRetries       = 2
-	deezerRetryDelay       = 500 * time.Millisecond
 
 	deezerMaxSearchCacheEntries = 300
 	deezerMaxAlbumCacheEntries  = 200
@@ -1251,42 +1249,9 @@ func (c *DeezerClient) GetExtendedMetadataByISRC(ctx context.Context, isrc strin
 	return c.GetExtendedMetadataByTrackID(ctx, deezerID)
 }
 
+// getJSON fetches endpoint into dst. Timeouts, dropped connections and 5xx
+// responses are retried by doFetchWithRetry.
 func (c *DeezerClient) getJSON(ctx context.Context, endpoint string, dst interface{}) error {
-	var lastErr error
-
-	for attempt := 0; attempt <= deezerMaxRetries; attempt++ {
-		if attempt > 0 {
-			delay := deezerRetryDelay * time.Duration(1<<(attempt-1))
-			GoLog("[Deezer] Retry %d/%d after %v...\n", attempt, deezerMaxRetries, delay)
-			time.Sleep(delay)
-		}
-
-		err := c.doGetJSON(ctx, endpoint, dst)
-		if err == nil {
-			return nil
-		}
-
-		lastErr = err
-		errStr := err.Error()
-
-		isRetryable := strings.Contains(errStr, "timeout") ||
-			strings.Contains(errStr, "connection reset") ||
-			strings.Contains(errStr, "connection refused") ||
-			strings.Contains(errStr, "EOF") ||
-			strings.Contains(errStr, "status 5") ||
-			strings.Contains(errStr, "status 429")
-
-		if !isRetryable {
-			return err
-		}
-
-		GoLog("[Deezer] Attempt %d failed (retryable): %v\n", attempt+1, err)
-	}
-
-	return fmt.Errorf("all %d attempts failed: %w", deezerMaxRetries+1, lastErr)
-}
-
-func (c *DeezerClient) doGetJSON(ctx context.Context, endpoint string, dst interface{}) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
 		return err
@@ -1294,7 +1259,7 @@ func (c *DeezerClient) doGetJSON(ctx context.Context, endpoint string, dst inter
 
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := doFetchWithRetry(c.httpClient, req, "deezer api")
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,355 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Kinds of DeviceCompatIssue.
+const (
+	DeviceIssueSampleRate = "sample_rate"
+	DeviceIssueBitDepth   = "bit_depth"
+	DeviceIssueChannels   = "channels"
+)
+
+// DeviceProfile lists what a playback device can decode. Zero limits are
+// unlimited. SampleRates, when set, is the exact list of supported rates,
+// for units that cannot play rates between them either.
+type DeviceProfile struct {
+	Name          string `json:"name,omitempty"`
+	Description   string `json:"description,omitempty"`
+	MaxSampleRate int    `json:"max_sample_rate,omitempty"`
+	MaxBitDepth   int    `json:"max_bit_depth,omitempty"`
+	MaxChannels   int    `json:"max_channels,omitempty"`
+	SampleRates   []int  `json:"sample_rates,omitempty"`
+}
+
+// deviceProfilePresets are the named profiles for common device classes,
+// ordered from the most to the least restrictive.
+var deviceProfilePresets = []DeviceProfile{
+	{Name: "cd_player", Description: "CD players and older car units reading USB sticks", MaxSampleRate: 44100, MaxBitDepth: 16, MaxChannels: 2},
+	{Name: "bluetooth_receiver", Description: "Bluetooth receivers and adapters", MaxSampleRate: 48000, MaxBitDepth: 16, MaxChannels: 2},
+	{Name: "car_head_unit", Description: "Car head units playing FLAC from USB", MaxSampleRate: 48000, MaxBitDepth: 24, MaxChannels: 2},
+	{Name: "portable_dac", Description: "Dongle DACs and portable players", MaxSampleRate: 96000, MaxBitDepth: 24, MaxChannels: 2},
+	{Name: "hires_dac", Description: "Hi-res desktop DACs", MaxSampleRate: 192000, MaxBitDepth: 24, MaxChannels: 2},
+	{Name: "studio_dac", Description: "32-bit and multichannel studio interfaces", MaxSampleRate: 384000, MaxBitDepth: 32, MaxChannels: 8},
+}
+
+// DeviceCompatIssue is one property of a file the profile cannot play.
+type DeviceCompatIssue struct {
+	Kind    string `json:"kind"`
+	Value   int    `json:"value"`
+	Limit   int    `json:"limit,omitempty"`
+	Message string `json:"message"`
+}
+
+type DeviceCompatResult struct {
+	Path       string `json:"path"`
+	SampleRate int    `json:"sample_rate,omitempty"`
+	// SampleRateFamily is 44100 or 48000 for rates that are a multiple of
+	// either, 0 otherwise.
+	SampleRateFamily int                 `json:"sample_rate_family,omitempty"`
+	BitDepth         int                 `json:"bit_depth,omitempty"`
+	Channels         int                 `json:"channels,omitempty"`
+	Compatible       bool                `json:"compatible"`
+	Issues           []DeviceCompatIssue `json:"issues,omitempty"`
+	Error            string              `json:"error,omitempty"`
+}
+
+// DeviceCompatReport lists only the files the profile cannot play or that
+// could not be read; compatible files are only counted.
+type DeviceCompatReport struct {
+	Root         string               `json:"root"`
+	Profile      DeviceProfile        `json:"profile"`
+	Checked      int                  `json:"checked"`
+	Compatible   int                  `json:"compatible"`
+	Incompatible int                  `json:"incompatible"`
+	Failed       int                  `json:"failed"`
+	Files        []DeviceCompatResult `json:"files"`
+}
+
+// DeviceProfileSummary tells whether every readable file under a library
+// root plays on Profile.
+type DeviceProfileSummary struct {
+	Profile      DeviceProfile `json:"profile"`
+	Satisfied    bool          `json:"satisfied"`
+	Incompatible int           `json:"incompatible"`
+}
+
+type LibraryDeviceProfilesReport struct {
+	Root          string                 `json:"root"`
+	Checked       int                    `json:"checked"`
+	Failed        int                    `json:"failed"`
+	MaxSampleRate int                    `json:"max_sample_rate"`
+	MaxBitDepth   int                    `json:"max_bit_depth"`
+	MaxChannels   int                    `json:"max_channels"`
+	Profiles      []DeviceProfileSummary `json:"profiles"`
+}
+
+// parseDeviceProfile reads profileJSON, an object of DeviceProfile fields
+// or {"preset": "<name>"} for one of deviceProfilePresets.
+func parseDeviceProfile(profileJSON string) (DeviceProfile, error) {
+	var input struct {
+		DeviceProfile
+		Preset string `json:"preset"`
+	}
+	if strings.TrimSpace(profileJSON) == "" {
+		return DeviceProfile{}, fmt.Errorf("device profile is empty")
+	}
+	if err := json.Unmarshal([]byte(profileJSON), &input); err != nil {
+		return DeviceProfile{}, fmt.Errorf("invalid device profile: %w", err)
+	}
+	if input.Preset != "" {
+		for _, preset := range deviceProfilePresets {
+			if preset.Name == input.Preset {
+				return preset, nil
+			}
+		}
+		return DeviceProfile{}, fmt.Errorf("unknown device profile preset: %s", input.Preset)
+	}
+	profile := input.DeviceProfile
+	if profile.MaxSampleRate < 0 || profile.MaxBitDepth < 0 || profile.MaxChannels < 0 {
+		return DeviceProfile{}, fmt.Errorf("device profile limits must not be negative")
+	}
+	if profile.MaxSampleRate == 0 && profile.MaxBitDepth == 0 && profile.MaxChannels == 0 && len(profile.SampleRates) == 0 {
+		return DeviceProfile{}, fmt.Errorf("device profile has no limits")
+	}
+	return profile, nil
+}
+
+// sampleRateFamily returns 44100 or 48000 when rate is a multiple of it
+// (88.2/176.4 kHz or 96/192 kHz), else 0.
+func sampleRateFamily(rate int) int {
+	switch {
+	case rate <= 0:
+		return 0
+	case rate%44100 == 0:
+		return 44100
+	case rate%48000 == 0:
+		return 48000
+	}
+	return 0
+}
+
+func formatKHz(rate int) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(rate)/1000), ".0") + " kHz"
+}
+
+// readDeviceAudioFormat reads the sample rate, bit depth and channel count
+// of filePath. Zero means the format does not say (bit depth of lossy
+// streams, channels of MP3 and Ogg).
+func readDeviceAudioFormat(filePath string) (sampleRate, bitDepth, channels int, err error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".wav":
+		q, err := GetWAVQuality(filePath)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return q.SampleRate, q.BitDepth, q.Channels, nil
+	case ".aif", ".aiff":
+		q, err := GetAIFFQuality(filePath)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return q.SampleRate, q.BitDepth, q.Channels, nil
+	case ".mp3":
+		q, err := GetMP3Quality(filePath)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return q.SampleRate, 0, 0, nil
+	case ".ogg", ".opus":
+		q, err := GetOggQuality(filePath)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return q.SampleRate, 0, 0, nil
+	}
+	q, err := GetAudioQuality(filePath)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return q.SampleRate, q.BitDepth, q.Channels, nil
+}
+
+// deviceCompatIssues lists what of the given format profile cannot play.
+// Unknown (zero) properties are never reported.
+func deviceCompatIssues(profile DeviceProfile, sampleRate, bitDepth, channels int) []DeviceCompatIssue {
+	var issues []DeviceCompatIssue
+	if sampleRate > 0 {
+		family := ""
+		if f := sampleRateFamily(sampleRate); f != 0 {
+			family = fmt.Sprintf(" (%s family)", formatKHz(f))
+		}
+		switch {
+		case profile.MaxSampleRate > 0 && sampleRate > profile.MaxSampleRate:
+			issues = append(issues, DeviceCompatIssue{
+				Kind: DeviceIssueSampleRate, Value: sampleRate, Limit: profile.MaxSampleRate,
+				Message: fmt.Sprintf("sample rate %s%s exceeds %s", formatKHz(sampleRate), family, formatKHz(profile.MaxSampleRate)),
+			})
+		case len(profile.SampleRates) > 0 && !slices.Contains(profile.SampleRates, sampleRate):
+			issues = append(issues, DeviceCompatIssue{
+				Kind: DeviceIssueSampleRate, Value: sampleRate,
+				Message: fmt.Sprintf("sample rate %s%s is not supported", formatKHz(sampleRate), family),
+			})
+		}
+	}
+	if bitDepth > 0 && profile.MaxBitDepth > 0 && bitDepth > profile.MaxBitDepth {
+		issues = append(issues, DeviceCompatIssue{
+			Kind: DeviceIssueBitDepth, Value: bitDepth, Limit: profile.MaxBitDepth,
+			Message: fmt.Sprintf("%d-bit exceeds %d-bit", bitDepth, profile.MaxBitDepth),
+		})
+	}
+	if channels > 0 && profile.MaxChannels > 0 && channels > profile.MaxChannels {
+		issues = append(issues, DeviceCompatIssue{
+			Kind: DeviceIssueChannels, Value: channels, Limit: profile.MaxChannels,
+			Message: fmt.Sprintf("%d channels exceed %d", channels, profile.MaxChannels),
+		})
+	}
+	return issues
+}
+
+func checkDeviceCompat(filePath string, profile DeviceProfile) DeviceCompatResult {
+	result := DeviceCompatResult{Path: filePath}
+	sampleRate, bitDepth, channels, err := readDeviceAudioFormat(filePath)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.SampleRate, result.BitDepth, result.Channels = sampleRate, bitDepth, channels
+	result.SampleRateFamily = sampleRateFamily(sampleRate)
+	result.Issues = deviceCompatIssues(profile, sampleRate, bitDepth, channels)
+	result.Compatible = len(result.Issues) == 0
+	return result
+}
+
+// CheckDeviceCompat tells whether filePath plays on the device described by
+// profileJSON: an object with max_sample_rate, max_bit_depth, max_channels
+// and optionally sample_rates, or {"preset": "<name>"} for a named profile
+// (see LibraryDeviceProfiles). Each property the device cannot play is
+// listed as an issue, with the sample-rate family of the file.
+func CheckDeviceCompat(filePath, profileJSON string) (string, error) {
+	profile, err := parseDeviceProfile(profileJSON)
+	if err != nil {
+		return "", err
+	}
+	result := checkDeviceCompat(filePath, profile)
+	if result.Error != "" {
+		return "", fmt.Errorf("%s", result.Error)
+	}
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// deviceCompatFiles collects the audio files under rootPath, sorted, without
+// .cue sheets whose tracks live in another file.
+func deviceCompatFiles(rootPath string) ([]string, error) {
+	if strings.TrimSpace(rootPath) == "" {
+		return nil, fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(rootPath); err != nil {
+		return nil, fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+	files, err := collectLibraryAudioFiles(rootPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		if !strings.EqualFold(filepath.Ext(file.path), ".cue") {
+			paths = append(paths, file.path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// AuditDeviceCompat runs CheckDeviceCompat on every audio file under
+// rootPath and lists the ones the device cannot play, so users can find
+// every track their car or receiver skips.
+func AuditDeviceCompat(rootPath, profileJSON string) (string, error) {
+	profile, err := parseDeviceProfile(profileJSON)
+	if err != nil {
+		return "", err
+	}
+	paths, err := deviceCompatFiles(rootPath)
+	if err != nil {
+		return "", err
+	}
+
+	report := DeviceCompatReport{Root: rootPath, Profile: profile, Files: []DeviceCompatResult{}}
+	for _, path := range paths {
+		report.Checked++
+		result := checkDeviceCompat(path, profile)
+		switch {
+		case result.Error != "":
+			report.Failed++
+		case result.Compatible:
+			report.Compatible++
+			continue
+		default:
+			report.Incompatible++
+		}
+		report.Files = append(report.Files, result)
+	}
+
+	GoLog("[DeviceCompat] %d of %d files under %s cannot play on %s\n",
+		report.Incompatible, report.Checked, rootPath, profile.Name)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// LibraryDeviceProfiles reads the format of every audio file under rootPath
+// once and reports, for each named device profile, whether the whole
+// library plays on it and how many files do not. Unreadable files are
+// counted as failed and do not affect the profiles.
+func LibraryDeviceProfiles(rootPath string) (string, error) {
+	paths, err := deviceCompatFiles(rootPath)
+	if err != nil {
+		return "", err
+	}
+
+	report := LibraryDeviceProfilesReport{Root: rootPath, Profiles: make([]DeviceProfileSummary, len(deviceProfilePresets))}
+	for i, preset := range deviceProfilePresets {
+		report.Profiles[i] = DeviceProfileSummary{Profile: preset}
+	}
+	for _, path := range paths {
+		report.Checked++
+		sampleRate, bitDepth, channels, err := readDeviceAudioFormat(path)
+		if err != nil {
+			report.Failed++
+			continue
+		}
+		report.MaxSampleRate = max(report.MaxSampleRate, sampleRate)
+		report.MaxBitDepth = max(report.MaxBitDepth, bitDepth)
+		report.MaxChannels = max(report.MaxChannels, channels)
+		for i := range report.Profiles {
+			if len(deviceCompatIssues(report.Profiles[i].Profile, sampleRate, bitDepth, channels)) > 0 {
+				report.Profiles[i].Incompatible++
+			}
+		}
+	}
+	for i := range report.Profiles {
+		report.Profiles[i].Satisfied = report.Profiles[i].Incompatible == 0
+	}
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
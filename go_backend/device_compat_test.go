@@ -0,0 +1,88 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFormatFLAC writes a FLAC whose STREAMINFO claims the given format.
+func writeFormatFLAC(t *testing.T, dir, name string, sampleRate, bitDepth, channels int) string {
+	t.Helper()
+	data, err := buildSelfTestFLAC()
+	if err != nil {
+		t.Fatalf("buildSelfTestFLAC: %v", err)
+	}
+	info := data[8:]
+	info[10] = byte(sampleRate >> 12)
+	info[11] = byte(sampleRate >> 4)
+	info[12] = byte(sampleRate&0x0F)<<4 | byte(channels-1)<<1 | byte(bitDepth-1)>>4
+	info[13] = byte(bitDepth-1)<<4 | info[13]&0x0F
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
+
+func TestCheckDeviceCompat(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFormatFLAC(t, dir, "hires.flac", 88200, 24, 2)
+
+	out, err := CheckDeviceCompat(path, `{"preset":"car_head_unit"}`)
+	result := mustDecodeJSON[DeviceCompatResult](t, out, err)
+	if result.Compatible || result.SampleRateFamily != 44100 || result.BitDepth != 24 || result.Channels != 2 ||
+		len(result.Issues) != 1 || result.Issues[0].Kind != DeviceIssueSampleRate || result.Issues[0].Limit != 48000 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if want := "sample rate 88.2 kHz (44.1 kHz family) exceeds 48 kHz"; result.Issues[0].Message != want {
+		t.Fatalf("message = %q, want %q", result.Issues[0].Message, want)
+	}
+
+	out, err = CheckDeviceCompat(path, `{"max_bit_depth":16,"sample_rates":[44100,48000,96000]}`)
+	if err != nil {
+		t.Fatalf("CheckDeviceCompat: %v", err)
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(result.Issues) != 2 || result.Issues[0].Kind != DeviceIssueSampleRate || result.Issues[1].Kind != DeviceIssueBitDepth {
+		t.Fatalf("unexpected issues: %+v", result.Issues)
+	}
+
+	for _, profile := range []string{"", `{}`, `{"preset":"toaster"}`, `{"max_channels":-1}`} {
+		if _, err := CheckDeviceCompat(path, profile); err == nil {
+			t.Fatalf("expected profile %q to be refused", profile)
+		}
+	}
+}
+
+func TestAuditDeviceCompatAndLibraryProfiles(t *testing.T) {
+	root := t.TempDir()
+	writeFormatFLAC(t, root, "cd.flac", 44100, 16, 2)
+	writeFormatFLAC(t, root, "hires.flac", 96000, 24, 2)
+	writeFormatFLAC(t, root, "deep.flac", 48000, 32, 6)
+	if err := os.WriteFile(filepath.Join(root, "broken.flac"), []byte("not a flac"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	out, err := AuditDeviceCompat(root, `{"preset":"car_head_unit"}`)
+	report := mustDecodeJSON[DeviceCompatReport](t, out, err)
+	if report.Checked != 4 || report.Compatible != 1 || report.Incompatible != 2 || report.Failed != 1 || len(report.Files) != 3 {
+		t.Fatalf("unexpected audit: %+v", report)
+	}
+
+	out, err = LibraryDeviceProfiles(root)
+	summary := mustDecodeJSON[LibraryDeviceProfilesReport](t, out, err)
+	if summary.Checked != 4 || summary.Failed != 1 || summary.MaxSampleRate != 96000 || summary.MaxBitDepth != 32 || summary.MaxChannels != 6 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	satisfied := make(map[string]bool)
+	for _, profile := range summary.Profiles {
+		satisfied[profile.Profile.Name] = profile.Satisfied
+	}
+	if !satisfied["studio_dac"] || satisfied["hires_dac"] || satisfied["cd_player"] {
+		t.Fatalf("unexpected profiles: %+v", summary.Profiles)
+	}
+}
@@ -0,0 +1,45 @@
+package gobackend
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Well-known EmbedOptions.ExtraTags keys recording where a file was
+// downloaded from, for troubleshooting. They are written only when the app
+// passes them and read back into Metadata and LibraryScanResult.
+const (
+	// TagDownloadSource is the name of the service the file came from.
+	TagDownloadSource = "DOWNLOAD_SOURCE"
+	// TagDownloadSourceQuality is the quality the service claimed, as it
+	// reported it (e.g. "LOSSLESS", "HI_RES 24/96").
+	TagDownloadSourceQuality = "DOWNLOAD_SOURCE_QUALITY"
+	// TagDownloadedAt is the download time in RFC 3339.
+	TagDownloadedAt = "DOWNLOADED_AT"
+)
+
+// unknownDownloadSource groups files without TagDownloadSource in the
+// library audit.
+const unknownDownloadSource = "unknown"
+
+// validateDownloadSourceTags rejects a TagDownloadedAt extra tag that is not
+// RFC 3339, so the timestamps stay sortable and parseable.
+func validateDownloadSourceTags(values map[string][]string) error {
+	for _, value := range values[TagDownloadedAt] {
+		if _, err := time.Parse(time.RFC3339, strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("tag %s must be an RFC 3339 timestamp: %q", TagDownloadedAt, value)
+		}
+	}
+	return nil
+}
+
+// downloadSourceKey is the audit group of a file's download source,
+// compared ignoring case.
+func downloadSourceKey(source string) string {
+	source = strings.ToLower(strings.TrimSpace(source))
+	if source == "" {
+		return unknownDownloadSource
+	}
+	return source
+}
@@ -0,0 +1,44 @@
+package gobackend
+
+import "testing"
+
+func TestDownloadSourceTagsRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	qobuz := writeConsistencyFixture(t, root, "01.flac", Metadata{Title: "One", Artist: "Artist", Album: "Album"})
+	tidal := writeConsistencyFixture(t, root, "02.flac", Metadata{Title: "Two", Artist: "Artist", Album: "Album"})
+	writeConsistencyFixture(t, root, "03.flac", Metadata{Title: "Three", Artist: "Artist", Album: "Album"})
+
+	opts := EmbedOptions{ExtraTags: []TagPair{
+		{Key: TagDownloadSource, Value: "Qobuz"},
+		{Key: TagDownloadSourceQuality, Value: "HI_RES 24/96"},
+		{Key: TagDownloadedAt, Value: "2026-10-16T09:30:00Z"},
+	}}
+	if err := EmbedAll(qobuz, Metadata{}, Lyrics{}, nil, opts); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	if err := EmbedAll(tidal, Metadata{}, Lyrics{}, nil, EmbedOptions{ExtraTags: []TagPair{{Key: "download_source", Value: "Tidal"}}}); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+
+	meta, err := ReadMetadata(qobuz)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if meta.Source != "Qobuz" || meta.SourceQuality != "HI_RES 24/96" || meta.DownloadedAt != "2026-10-16T09:30:00Z" {
+		t.Fatalf("source fields = %q %q %q", meta.Source, meta.SourceQuality, meta.DownloadedAt)
+	}
+
+	out, err := AuditLibrary(root, "")
+	report := mustDecodeJSON[LibraryAuditReport](t, out, err)
+	if len(report.Sources) != 3 || report.Sources["qobuz"] != 1 || report.Sources["tidal"] != 1 || report.Sources[unknownDownloadSource] != 1 {
+		t.Fatalf("sources = %v", report.Sources)
+	}
+}
+
+func TestEmbedAllRejectsInvalidDownloadedAt(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	opts := EmbedOptions{ExtraTags: []TagPair{{Key: TagDownloadedAt, Value: "16/10/2026"}}}
+	if err := EmbedAll(path, Metadata{}, Lyrics{}, nil, opts); err == nil {
+		t.Fatal("expected invalid timestamp error")
+	}
+}
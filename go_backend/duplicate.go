@@ -252,4 +252,10 @@ func AddToISRCIndex(outputDir, isrc, filePath string) {
 	if exists {
 		idx.Add(isrc, filePath)
 	}
+
+	if indexPath := GetBackendConfig().ISRCIndexPath; indexPath != "" {
+		if err := UpdateISRCIndex(indexPath, filePath); err != nil {
+			GoLog("[ISRCIndex] Failed to update %s: %v\n", indexPath, err)
+		}
+	}
 }
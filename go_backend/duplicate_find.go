@@ -0,0 +1,197 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// How FindDuplicates matched a group.
+const (
+	// DuplicateTierISRC: every file is linked to another by the same ISRC.
+	DuplicateTierISRC = "isrc"
+	// DuplicateTierFuzzy: at least one file was linked only by a fuzzy
+	// artist and title match; see MatchTracks.
+	DuplicateTierFuzzy = "fuzzy"
+)
+
+type DuplicateFile struct {
+	Path       string `json:"path"`
+	Artist     string `json:"artist"`
+	Title      string `json:"title"`
+	ISRC       string `json:"isrc,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+}
+
+// DuplicateGroup is a set of files FindDuplicates considers the same track.
+// Score is the weakest fuzzy link in the group, or 1 for an ISRC group.
+type DuplicateGroup struct {
+	Tier  string          `json:"tier"`
+	Score float64         `json:"score"`
+	Files []DuplicateFile `json:"files"`
+}
+
+type DuplicateReport struct {
+	Root    string           `json:"root"`
+	Checked int              `json:"checked"`
+	Failed  int              `json:"failed"`
+	Groups  []DuplicateGroup `json:"groups"`
+}
+
+type duplicateLink struct {
+	a, b  int
+	score float64
+	fuzzy bool
+}
+
+// findDuplicateLinks links files with the same ISRC, then files whose
+// artist and title match fuzzily. Files with different ISRCs are distinct
+// recordings and never linked. Only files within the duration tolerance of
+// each other are compared; files of unknown duration are compared with all.
+func findDuplicateLinks(files []DuplicateFile, cfg BackendConfig) []duplicateLink {
+	var links []duplicateLink
+	byISRC := make(map[string]int)
+	for i, file := range files {
+		isrc := strings.ToUpper(strings.TrimSpace(file.ISRC))
+		if isrc == "" {
+			continue
+		}
+		if first, ok := byISRC[isrc]; ok {
+			links = append(links, duplicateLink{a: first, b: i, score: 1})
+		} else {
+			byISRC[isrc] = i
+		}
+	}
+
+	keys := make([]string, len(files))
+	var known, unknown []int
+	for i, file := range files {
+		keys[i] = normalizeTrackMatchKey(file.Artist, file.Title)
+		if file.DurationMs > 0 {
+			known = append(known, i)
+		} else {
+			unknown = append(unknown, i)
+		}
+	}
+	sort.SliceStable(known, func(i, j int) bool { return files[known[i]].DurationMs < files[known[j]].DurationMs })
+
+	compare := func(a, b int) {
+		isrcA, isrcB := files[a].ISRC, files[b].ISRC
+		if isrcA != "" && isrcB != "" {
+			// Same ISRC is already linked; different ISRCs never are.
+			return
+		}
+		if score, ok := fuzzyTrackMatch(keys[a], keys[b], files[a].DurationMs, files[b].DurationMs, cfg); ok {
+			links = append(links, duplicateLink{a: a, b: b, score: score, fuzzy: true})
+		}
+	}
+	for n, a := range known {
+		for _, b := range known[n+1:] {
+			if files[b].DurationMs-files[a].DurationMs > cfg.FuzzyMatchToleranceMs {
+				break
+			}
+			compare(a, b)
+		}
+	}
+	for n, a := range unknown {
+		for _, b := range known {
+			compare(a, b)
+		}
+		for _, b := range unknown[n+1:] {
+			compare(a, b)
+		}
+	}
+	return links
+}
+
+// groupDuplicates joins linked files into groups of two or more.
+func groupDuplicates(files []DuplicateFile, links []duplicateLink) []DuplicateGroup {
+	parent := make([]int, len(files))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for _, link := range links {
+		parent[find(link.a)] = find(link.b)
+	}
+
+	score := make(map[int]float64)
+	fuzzy := make(map[int]bool)
+	for _, link := range links {
+		root := find(link.a)
+		if current, ok := score[root]; !ok || link.score < current {
+			score[root] = link.score
+		}
+		fuzzy[root] = fuzzy[root] || link.fuzzy
+	}
+
+	members := make(map[int][]DuplicateFile)
+	var roots []int
+	for i, file := range files {
+		root := find(i)
+		if _, ok := score[root]; !ok {
+			continue
+		}
+		if members[root] == nil {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], file)
+	}
+
+	groups := make([]DuplicateGroup, 0, len(roots))
+	for _, root := range roots {
+		group := DuplicateGroup{Tier: DuplicateTierISRC, Score: score[root], Files: members[root]}
+		if fuzzy[root] {
+			group.Tier = DuplicateTierFuzzy
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// FindDuplicates looks for the same track stored more than once under
+// rootPath: first by ISRC, then, for files without one such as old rips, by
+// a fuzzy match of artist and title with durations within
+// FuzzyMatchToleranceMs. The groups, in path order, can be passed to
+// CompareDuplicateGroups to confirm identical audio before deleting.
+func FindDuplicates(rootPath string) (string, error) {
+	paths, err := deviceCompatFiles(rootPath)
+	if err != nil {
+		return "", err
+	}
+
+	report := DuplicateReport{Root: rootPath}
+	files := make([]DuplicateFile, 0, len(paths))
+	scanTime := time.Now().UTC().Format(time.RFC3339)
+	for _, path := range paths {
+		report.Checked++
+		scanned, err := scanAudioFileWithKnownModTime(path, scanTime, 0)
+		if err != nil {
+			report.Failed++
+			continue
+		}
+		files = append(files, DuplicateFile{
+			Path:       path,
+			Artist:     scanned.ArtistName,
+			Title:      scanned.TrackName,
+			ISRC:       scanned.ISRC,
+			DurationMs: int64(scanned.Duration) * 1000,
+		})
+	}
+
+	report.Groups = groupDuplicates(files, findDuplicateLinks(files, GetBackendConfig()))
+	GoLog("[Duplicates] %d duplicate group(s) among %d files under %s\n", len(report.Groups), report.Checked, rootPath)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,46 @@
+package gobackend
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	root := t.TempDir()
+	writeConsistencyFixture(t, root, "a/Yesterday.flac", Metadata{Title: "Yesterday", Artist: "The Beatles", ISRC: "GBAYE0601477"})
+	writeConsistencyFixture(t, root, "b/Yesterday.flac", Metadata{Title: "Yesterday (Remastered)", Artist: "Beatles", ISRC: "gbaye0601477"})
+	writeConsistencyFixture(t, root, "c/Yesterday.flac", Metadata{Title: "Yesterday - 2009 Remaster", Artist: "The Beatles"})
+	writeConsistencyFixture(t, root, "d/Lithium.flac", Metadata{Title: "Lithium", Artist: "Nirvana"})
+	writeConsistencyFixture(t, root, "e/Lithium.flac", Metadata{Title: "Lithium", Artist: "Nirvana"})
+	writeConsistencyFixture(t, root, "f/Lithium Live.flac", Metadata{Title: "Lithium (Live)", Artist: "Nirvana"})
+	writeConsistencyFixture(t, root, "g/Hello.flac", Metadata{Title: "Hello", Artist: "Adele", ISRC: "GBBKS1500214"})
+	writeConsistencyFixture(t, root, "h/Hello.flac", Metadata{Title: "Hello", Artist: "Adele", ISRC: "GBBKS1500999"})
+
+	out, err := FindDuplicates(root)
+	report := mustDecodeJSON[DuplicateReport](t, out, err)
+	if report.Checked != 8 || report.Failed != 0 || len(report.Groups) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+
+	yesterday, lithium := report.Groups[0], report.Groups[1]
+	if yesterday.Tier != DuplicateTierFuzzy || len(yesterday.Files) != 3 ||
+		yesterday.Files[0].Path != filepath.Join(root, "a", "Yesterday.flac") {
+		t.Fatalf("yesterday group = %+v", yesterday)
+	}
+	if lithium.Tier != DuplicateTierFuzzy || lithium.Score != 1 || len(lithium.Files) != 2 ||
+		lithium.Files[1].Path != filepath.Join(root, "e", "Lithium.flac") {
+		t.Fatalf("lithium group = %+v", lithium)
+	}
+}
+
+func TestGroupDuplicatesISRCTier(t *testing.T) {
+	files := []DuplicateFile{
+		{Path: "1.flac", Artist: "A", Title: "Song", ISRC: "X1"},
+		{Path: "2.flac", Artist: "Someone Else", Title: "Other Name", ISRC: "X1"},
+		{Path: "3.flac", Artist: "B", Title: "Tune"},
+	}
+	groups := groupDuplicates(files, findDuplicateLinks(files, GetBackendConfig()))
+	if len(groups) != 1 || groups[0].Tier != DuplicateTierISRC || groups[0].Score != 1 || len(groups[0].Files) != 2 {
+		t.Fatalf("groups = %+v", groups)
+	}
+}
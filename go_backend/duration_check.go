@@ -0,0 +1,92 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+const (
+	DurationStatusOK       = "ok"
+	DurationStatusMismatch = "mismatch"
+	DurationStatusUnknown  = "unknown"
+)
+
+// defaultDurationToleranceMs absorbs encoder padding and catalog rounding
+// while still catching a radio edit swapped for the album version.
+const defaultDurationToleranceMs = 2000
+
+type DurationCheck struct {
+	Status      string `json:"status"`
+	FileMs      int64  `json:"file_ms"`
+	ExpectedMs  int64  `json:"expected_ms"`
+	DeltaMs     int64  `json:"delta_ms"`
+	ToleranceMs int64  `json:"tolerance_ms"`
+}
+
+// checkDuration compares the STREAMINFO duration with expectedMs. A file that
+// does not declare its total sample count is "unknown", never "ok".
+func checkDuration(filePath string, expectedMs, toleranceMs int64) (DurationCheck, error) {
+	if toleranceMs <= 0 {
+		toleranceMs = defaultDurationToleranceMs
+	}
+	check := DurationCheck{ExpectedMs: expectedMs, ToleranceMs: toleranceMs, Status: DurationStatusUnknown}
+
+	quality, err := GetAudioQuality(filePath)
+	if err != nil {
+		return check, err
+	}
+	if quality.SampleRate <= 0 || quality.TotalSamples <= 0 {
+		return check, nil
+	}
+
+	check.FileMs = quality.TotalSamples * 1000 / int64(quality.SampleRate)
+	check.DeltaMs = check.FileMs - expectedMs
+	check.Status = DurationStatusOK
+	if absInt64(check.DeltaMs) > toleranceMs {
+		check.Status = DurationStatusMismatch
+	}
+	return check, nil
+}
+
+func absInt64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// CheckDuration reports whether a file's duration is within toleranceMs of
+// expectedMs (2s when toleranceMs is 0), as a DurationCheck JSON object.
+func CheckDuration(filePath string, expectedMs int, toleranceMs int) (string, error) {
+	check, err := checkDuration(filePath, int64(expectedMs), int64(toleranceMs))
+	if err != nil {
+		return "", err
+	}
+
+	jsonBytes, err := json.Marshal(check)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// durationFieldDiff expresses a duration check as a diff entry so album
+// verification reports it next to the tag fields.
+func durationFieldDiff(filePath string, expectedMs, toleranceMs int64) MetadataFieldDiff {
+	diff := MetadataFieldDiff{
+		Field:         "duration_ms",
+		ExpectedValue: strconv.FormatInt(expectedMs, 10),
+		Status:        DiffStatusUnknown,
+	}
+	check, err := checkDuration(filePath, expectedMs, toleranceMs)
+	if err != nil || check.Status == DurationStatusUnknown {
+		return diff
+	}
+
+	diff.FileValue = strconv.FormatInt(check.FileMs, 10)
+	diff.Status = DiffStatusMatch
+	if check.Status == DurationStatusMismatch {
+		diff.Status = DiffStatusMismatch
+	}
+	return diff
+}
@@ -0,0 +1,58 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckDurationToleranceAndUnknown(t *testing.T) {
+	// The self-test fixture is 0.25s long.
+	path := writeVerifyFixture(t, nil)
+
+	check, err := checkDuration(path, 250, 10)
+	if err != nil || check.Status != DurationStatusOK || check.FileMs != 250 {
+		t.Fatalf("expected ok, got %+v (%v)", check, err)
+	}
+	check, _ = checkDuration(path, 60250, 0)
+	if check.Status != DurationStatusMismatch || check.DeltaMs != -60000 {
+		t.Fatalf("expected mismatch, got %+v", check)
+	}
+
+	// Zero the STREAMINFO total-samples field.
+	unknown := writeVerifyFixture(t, func(data []byte) []byte {
+		data[8+13] &= 0xF0
+		copy(data[8+14:8+18], []byte{0, 0, 0, 0})
+		return data
+	})
+	resultJSON, err := CheckDuration(unknown, 250, 1000)
+	if err != nil {
+		t.Fatalf("CheckDuration: %v", err)
+	}
+	check = DurationCheck{}
+	_ = json.Unmarshal([]byte(resultJSON), &check)
+	if check.Status != DurationStatusUnknown {
+		t.Fatalf("unknown total samples must not pass: %s", resultJSON)
+	}
+}
+
+func TestDiffMetadataDirFlagsDurationMismatch(t *testing.T) {
+	dir := t.TempDir()
+	data, _ := os.ReadFile(writeTestFLACWithMetadata(t, Metadata{Title: "One", TrackNumber: 1}))
+	os.WriteFile(filepath.Join(dir, "01.flac"), data, 0644)
+
+	reportJSON, err := DiffMetadataDir(dir, `[{"track_number": 1, "duration_ms": 215000}]`, "")
+	if err != nil {
+		t.Fatalf("DiffMetadataDir: %v", err)
+	}
+	var report DirectoryDiffReport
+	_ = json.Unmarshal([]byte(reportJSON), &report)
+	if report.AllMatch || report.Tracks[0].Mismatches != 1 {
+		t.Fatalf("expected duration mismatch: %s", reportJSON)
+	}
+	d := findFieldDiff(t, report.Tracks[0].Diffs, "duration_ms")
+	if d.FileValue != "250" || d.ExpectedValue != "215000" || d.Status != DiffStatusMismatch {
+		t.Fatalf("unexpected duration diff: %+v", d)
+	}
+}
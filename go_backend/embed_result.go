@@ -0,0 +1,177 @@
+package gobackend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-flac/flacpicture/v2"
+	"github.com/go-flac/go-flac/v2"
+)
+
+// Cover actions reported by EmbedResult.
+const (
+	CoverKept     = "kept"
+	CoverAdded    = "added"
+	CoverReplaced = "replaced"
+	CoverRemoved  = "removed"
+)
+
+// EmbedResult describes what one call of the embed family changed, for a
+// summary such as "updated 6 tags, replaced cover (1.2 MB → 240 KB)". Cover
+// sizes are of the front cover image, or the first picture without one.
+type EmbedResult struct {
+	TagsAdded        int      `json:"tags_added"`
+	TagsUpdated      int      `json:"tags_updated"`
+	TagsRemoved      int      `json:"tags_removed"`
+	AddedKeys        []string `json:"added_keys"`
+	UpdatedKeys      []string `json:"updated_keys"`
+	RemovedKeys      []string `json:"removed_keys"`
+	CoverAction      string   `json:"cover_action"`
+	CoverBytesBefore int64    `json:"cover_bytes_before"`
+	CoverBytesAfter  int64    `json:"cover_bytes_after"`
+	// CoverDecision is what EmbedAll did about the cover under
+	// EmbedOptions.CoverPolicy, one of the CoverDecision values, with the
+	// size of the cover the file had before. Other embeds leave it empty.
+	CoverDecision       string `json:"cover_decision,omitempty"`
+	ExistingCoverWidth  int    `json:"existing_cover_width,omitempty"`
+	ExistingCoverHeight int    `json:"existing_cover_height,omitempty"`
+	// RewriteKind is how the file was written, such as RewriteAtomic or
+	// RewriteInPlace; InPlace is set for the latter.
+	RewriteKind string `json:"rewrite_kind"`
+	InPlace     bool   `json:"in_place"`
+	// BytesRead counts audio bytes copied from the original file, which a
+	// full rewrite costs and an in-place write avoids.
+	BytesRead    int64   `json:"bytes_read"`
+	BytesWritten int64   `json:"bytes_written"`
+	FsyncMs      float64 `json:"fsync_ms"`
+	// DurationMs is the wall time of the whole call, parsing included.
+	DurationMs int64    `json:"duration_ms"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+func newEmbedResult() *EmbedResult {
+	return &EmbedResult{AddedKeys: []string{}, UpdatedKeys: []string{}, RemovedKeys: []string{}}
+}
+
+// flacPictureImage returns the picture type and image bytes of a PICTURE
+// block body without copying the image.
+func flacPictureImage(data []byte) (flacpicture.PictureType, []byte, bool) {
+	readLength := func(pos int) (int, bool) {
+		if pos+4 > len(data) {
+			return 0, false
+		}
+		return int(binary.BigEndian.Uint32(data[pos:])), true
+	}
+	if len(data) < 4 {
+		return 0, nil, false
+	}
+	pictureType := flacpicture.PictureType(binary.BigEndian.Uint32(data))
+	pos := 4
+	for range 2 {
+		// MIME type, then description.
+		n, ok := readLength(pos)
+		if !ok {
+			return 0, nil, false
+		}
+		pos += 4 + n
+	}
+	pos += 16 // width, height, depth, indexed colors
+	n, ok := readLength(pos)
+	if !ok || pos+4+n > len(data) {
+		return 0, nil, false
+	}
+	return pictureType, data[pos+4 : pos+4+n], true
+}
+
+// diffSnapshots fills the tag and cover parts of r from the state of the
+// file before and after the embed.
+func (r *EmbedResult) diffSnapshots(before, after tagSnapshot) {
+	for key, values := range after.comments {
+		old, existed := before.comments[key]
+		switch {
+		case !existed:
+			r.AddedKeys = append(r.AddedKeys, key)
+		case !stringSlicesEqual(values, old):
+			r.UpdatedKeys = append(r.UpdatedKeys, key)
+		}
+	}
+	for key := range before.comments {
+		if _, kept := after.comments[key]; !kept {
+			r.RemovedKeys = append(r.RemovedKeys, key)
+		}
+	}
+	sort.Strings(r.AddedKeys)
+	sort.Strings(r.UpdatedKeys)
+	sort.Strings(r.RemovedKeys)
+	r.TagsAdded, r.TagsUpdated, r.TagsRemoved = len(r.AddedKeys), len(r.UpdatedKeys), len(r.RemovedKeys)
+
+	r.CoverBytesBefore, r.CoverBytesAfter = int64(len(before.cover)), int64(len(after.cover))
+	switch {
+	case before.cover == nil && after.cover != nil:
+		r.CoverAction = CoverAdded
+	case before.cover != nil && after.cover == nil:
+		r.CoverAction = CoverRemoved
+	case !bytes.Equal(before.cover, after.cover):
+		r.CoverAction = CoverReplaced
+	default:
+		r.CoverAction = CoverKept
+	}
+}
+
+func (r *EmbedResult) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// dedupeFLACComments collapses exactly repeated comments in f, which some
+// rippers write and a preserving embed would otherwise carry forward, and
+// returns how many were removed.
+func dedupeFLACComments(f *flac.File) int {
+	for i, meta := range f.Meta {
+		if meta.Type != flac.VorbisComment {
+			continue
+		}
+		cmt, err := parseVorbisCommentBlock(*meta)
+		if err != nil {
+			return 0
+		}
+		comments, removed := dedupeVorbisComments(cmt.Comments)
+		if removed > 0 {
+			cmt.Comments = comments
+			block := cmt.Marshal()
+			f.Meta[i] = &block
+		}
+		return removed
+	}
+	return 0
+}
+
+// saveEmbedResult saves f like saveTaggedFLAC, after removing duplicate
+// comments and repairing malformed ones, and completes r with what changed
+// and how the file was written.
+func saveEmbedResult(f *flac.File, filePath, op string, before tagSnapshot, r *EmbedResult, started time.Time) (*EmbedResult, error) {
+	if removed := dedupeFLACComments(f); removed > 0 {
+		r.warn("removed %d duplicate comment(s)", removed)
+	}
+	keepMalformed := GetBackendConfig().KeepMalformedComments
+	for _, comment := range repairFLACComments(f) {
+		if keepMalformed {
+			r.warn("malformed comment %q kept as %s", comment, malformedCommentKey)
+		} else {
+			r.warn("dropped malformed comment %q", comment)
+		}
+	}
+	r.diffSnapshots(before, takeTagSnapshot(f))
+	recordTagHistory(f, op, before)
+	stats, err := saveFLACAtomicStats(f, filePath, op)
+	if err != nil {
+		return nil, err
+	}
+	r.RewriteKind, r.InPlace = stats.kind, stats.kind == RewriteInPlace
+	r.BytesRead, r.BytesWritten = stats.bytesRead, stats.bytesWritten
+	r.FsyncMs = durationMs(stats.fsync)
+	r.DurationMs = time.Since(started).Milliseconds()
+	return r, nil
+}
@@ -0,0 +1,125 @@
+package gobackend
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestEmbedResultReportsChanges(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Old", Artist: "Artist", Genre: "Rock"})
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+
+	result, err := EmbedAllWithResult(path, Metadata{Title: "New", Album: "Album"}, Lyrics{}, cover, EmbedOptions{})
+	if err != nil {
+		t.Fatalf("EmbedAllWithResult: %v", err)
+	}
+	if !slices.Contains(result.UpdatedKeys, "TITLE") || !slices.Contains(result.AddedKeys, "ALBUM") {
+		t.Fatalf("unexpected tag diff: %+v", result)
+	}
+	if result.TagsUpdated != len(result.UpdatedKeys) || result.TagsRemoved != 0 {
+		t.Fatalf("unexpected counts: %+v", result)
+	}
+	if result.CoverAction != CoverAdded || result.CoverBytesBefore != 0 || result.CoverBytesAfter != int64(len(cover)) {
+		t.Fatalf("unexpected cover result: %+v", result)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	// The fixture was padded when it was first tagged.
+	if result.RewriteKind != RewriteInPlace || result.BytesWritten <= 0 || result.BytesWritten >= info.Size() {
+		t.Fatalf("rewrite = %s/%d, file is %d bytes", result.RewriteKind, result.BytesWritten, info.Size())
+	}
+
+	result, err = EmbedAllWithResult(path, Metadata{}, Lyrics{}, cover, EmbedOptions{TagPolicy: TagPolicyReplace, KeepKeys: []string{"TITLE"}})
+	if err != nil {
+		t.Fatalf("EmbedAllWithResult: %v", err)
+	}
+	if result.CoverAction != CoverKept || !slices.Contains(result.RemovedKeys, "GENRE") || slices.Contains(result.RemovedKeys, "TITLE") {
+		t.Fatalf("unexpected second result: %+v", result)
+	}
+}
+
+func TestEmbedMetadataWithResultWarnsAboutMissingCover(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+
+	result, err := EmbedMetadataWithResult(path, Metadata{Title: "Song"}, "/nonexistent/cover.jpg")
+	if err != nil {
+		t.Fatalf("EmbedMetadataWithResult: %v", err)
+	}
+	if len(result.Warnings) != 1 || result.CoverAction != CoverKept {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.TagsAdded != 0 || result.TagsUpdated != 0 || result.TagsRemoved != 0 {
+		t.Fatalf("rewriting the same title reported changes: %+v", result)
+	}
+}
+
+func TestEmbedResultReportsIO(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Old"})
+
+	result, err := EmbedMetadataWithResult(path, Metadata{Title: "New"}, "")
+	if err != nil {
+		t.Fatalf("EmbedMetadataWithResult: %v", err)
+	}
+	if !result.InPlace || result.BytesRead != 0 || result.FsyncMs < 0 {
+		t.Fatalf("in-place write should read no audio: %+v", result)
+	}
+
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	cfg := original
+	cfg.AtomicTagWrites = true
+	if err := SetBackendConfig(cfg); err != nil {
+		t.Fatalf("SetBackendConfig: %v", err)
+	}
+	result, err = EmbedMetadataWithResult(path, Metadata{Title: "Newer"}, "")
+	if err != nil {
+		t.Fatalf("EmbedMetadataWithResult: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if result.InPlace || result.RewriteKind != RewriteAtomic || result.BytesRead <= 0 || result.BytesWritten != info.Size() {
+		t.Fatalf("rewrite should copy the audio: %+v (file is %d bytes)", result, info.Size())
+	}
+}
+
+func TestEmbedCollapsesDuplicateComments(t *testing.T) {
+	// Some rippers repeat ARTIST; a preserving embed used to keep them all.
+	path := writeAliasedFLAC(t,
+		"ARTIST=Band", "ARTIST=Band", "artist=Band", "ARTIST=Band", "ARTIST=Band",
+		"PERFORMER=Singer", "PERFORMER=Drummer",
+		"COMMENT=live", "COMMENT=Live",
+	)
+
+	result, err := EmbedAllWithResult(path, Metadata{Album: "Album"}, Lyrics{}, nil, EmbedOptions{})
+	if err != nil {
+		t.Fatalf("EmbedAllWithResult: %v", err)
+	}
+	if !slices.Contains(result.Warnings, "removed 4 duplicate comment(s)") || !slices.Contains(result.UpdatedKeys, "ARTIST") {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	comments, err := readVorbisCommentList(path)
+	if err != nil {
+		t.Fatalf("readVorbisCommentList: %v", err)
+	}
+	counts := map[string]int{}
+	for _, comment := range comments {
+		counts[comment]++
+	}
+	if counts["ARTIST=Band"] != 1 || counts["artist=Band"] != 0 || counts["PERFORMER=Singer"] != 1 ||
+		counts["PERFORMER=Drummer"] != 1 || counts["COMMENT=live"] != 1 || counts["COMMENT=Live"] != 1 {
+		t.Fatalf("comments after embed: %q", comments)
+	}
+
+	result, err = EmbedAllWithResult(path, Metadata{Album: "Album"}, Lyrics{}, nil, EmbedOptions{})
+	if err != nil || len(result.Warnings) != 0 {
+		t.Fatalf("second embed = %+v, %v", result, err)
+	}
+}
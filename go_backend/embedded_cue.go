@@ -0,0 +1,276 @@
+package gobackend
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+// CUESHEET block layout (FLAC format, metadata block type 5): a 396-byte
+// header (catalog number, lead-in, CD flag, reserved bytes, track count),
+// then 36 bytes per track followed by 12 bytes per index point. Track and
+// index offsets are in samples; index offsets are relative to their track.
+const (
+	cueSheetHeaderSize = 128 + 8 + 1 + 258 + 1
+	cueSheetTrackSize  = 8 + 1 + 12 + 1 + 13 + 1
+	cueSheetIndexSize  = 8 + 1 + 3
+)
+
+// EmbeddedCueIndex is one index point of an embedded CUESHEET track.
+// OffsetSamples is absolute, from the start of the audio.
+type EmbeddedCueIndex struct {
+	Number        int     `json:"number"`
+	OffsetSamples uint64  `json:"offset_samples"`
+	Time          float64 `json:"time"`
+}
+
+type EmbeddedCueTrack struct {
+	Number        int                `json:"number"`
+	OffsetSamples uint64             `json:"offset_samples"`
+	ISRC          string             `json:"isrc,omitempty"`
+	Audio         bool               `json:"audio"`
+	PreEmphasis   bool               `json:"pre_emphasis"`
+	StartTime     float64            `json:"start_time"` // INDEX 01 in seconds
+	PreGap        float64            `json:"pre_gap"`    // INDEX 00 in seconds (or -1 if not present)
+	Indexes       []EmbeddedCueIndex `json:"indexes"`
+}
+
+// EmbeddedCueSheet is the decoded CUESHEET block of a FLAC file. The
+// lead-out track is reported as LeadOutSamples rather than as a track.
+type EmbeddedCueSheet struct {
+	MediaCatalogNumber string             `json:"media_catalog_number,omitempty"`
+	LeadInSamples      uint64             `json:"lead_in_samples"`
+	IsCD               bool               `json:"is_cd"`
+	SampleRate         int                `json:"sample_rate"`
+	LeadOutSamples     uint64             `json:"lead_out_samples"`
+	Tracks             []EmbeddedCueTrack `json:"tracks"`
+}
+
+func cueSheetString(b []byte) string {
+	if i := strings.IndexByte(string(b), 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// parseFLACCueSheet decodes a CUESHEET block body. sampleRate converts
+// sample offsets to seconds; with 0 the times are left at 0.
+func parseFLACCueSheet(data []byte, sampleRate int) (*EmbeddedCueSheet, error) {
+	if len(data) < cueSheetHeaderSize {
+		return nil, fmt.Errorf("CUESHEET block is %d bytes, want at least %d", len(data), cueSheetHeaderSize)
+	}
+	seconds := func(samples uint64) float64 {
+		if sampleRate <= 0 {
+			return 0
+		}
+		return float64(samples) / float64(sampleRate)
+	}
+
+	sheet := &EmbeddedCueSheet{
+		MediaCatalogNumber: cueSheetString(data[0:128]),
+		LeadInSamples:      binary.BigEndian.Uint64(data[128:136]),
+		IsCD:               data[136]&0x80 != 0,
+		SampleRate:         sampleRate,
+		Tracks:             []EmbeddedCueTrack{},
+	}
+	trackCount := int(data[cueSheetHeaderSize-1])
+	pos := cueSheetHeaderSize
+	for i := range trackCount {
+		if pos+cueSheetTrackSize > len(data) {
+			return nil, fmt.Errorf("CUESHEET track %d runs past the end of the block", i+1)
+		}
+		raw := data[pos : pos+cueSheetTrackSize]
+		pos += cueSheetTrackSize
+
+		track := EmbeddedCueTrack{
+			Number:        int(raw[8]),
+			OffsetSamples: binary.BigEndian.Uint64(raw[0:8]),
+			ISRC:          cueSheetString(raw[9:21]),
+			Audio:         raw[21]&0x80 == 0,
+			PreEmphasis:   raw[21]&0x40 != 0,
+			PreGap:        -1,
+			Indexes:       []EmbeddedCueIndex{},
+		}
+		indexCount := int(raw[cueSheetTrackSize-1])
+		for j := range indexCount {
+			if pos+cueSheetIndexSize > len(data) {
+				return nil, fmt.Errorf("CUESHEET track %d index %d runs past the end of the block", track.Number, j+1)
+			}
+			offset := track.OffsetSamples + binary.BigEndian.Uint64(data[pos:pos+8])
+			index := EmbeddedCueIndex{Number: int(data[pos+8]), OffsetSamples: offset, Time: seconds(offset)}
+			pos += cueSheetIndexSize
+			switch index.Number {
+			case 0:
+				track.PreGap = index.Time
+			case 1:
+				track.StartTime = index.Time
+			}
+			track.Indexes = append(track.Indexes, index)
+		}
+
+		// The last track is the lead-out: 170 on CDs, 255 otherwise.
+		if i == trackCount-1 && (track.Number == 170 || track.Number == 255) {
+			sheet.LeadOutSamples = track.OffsetSamples
+			continue
+		}
+		sheet.Tracks = append(sheet.Tracks, track)
+	}
+	return sheet, nil
+}
+
+// readEmbeddedCueSheet returns the CUESHEET block of filePath together with
+// its Vorbis comments, which ExportEmbeddedCue uses for album fields.
+func readEmbeddedCueSheet(filePath string) (*EmbeddedCueSheet, *vorbisCommentMap, error) {
+	f, err := parseFLACStaged(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+	defer f.Close()
+
+	var sampleRate int
+	var cueData []byte
+	comments := newVorbisCommentMap(nil)
+	for _, meta := range f.Meta {
+		switch meta.Type {
+		case flac.StreamInfo:
+			_, sampleRate, _ = parseFLACStreamInfoQuality(meta.Data)
+		case flac.CueSheet:
+			if cueData == nil {
+				cueData = meta.Data
+			}
+		case flac.VorbisComment:
+			if cmt, err := parseVorbisCommentBlock(*meta); err == nil {
+				comments = newVorbisCommentMap(cmt.Comments)
+			}
+		}
+	}
+	if cueData == nil {
+		return nil, nil, fmt.Errorf("no CUESHEET block in %s", filePath)
+	}
+	sheet, err := parseFLACCueSheet(cueData, sampleRate)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sheet, comments, nil
+}
+
+// ParseEmbeddedCueSheet returns the tracks, index points and ISRCs of the
+// CUESHEET block embedded in a FLAC file as JSON. It is the counterpart of
+// ParseCueSheet for single-file rips that carry no separate .cue file.
+func ParseEmbeddedCueSheet(filePath string) (string, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, false, func(localPath string) (string, error) {
+			return ParseEmbeddedCueSheet(localPath)
+		})
+	}
+	sheet, _, err := readEmbeddedCueSheet(filePath)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(sheet)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// formatCueIndexTime formats a sample offset as a .cue MM:SS:FF timestamp,
+// with 75 frames per second as on a CD.
+func formatCueIndexTime(samples uint64, sampleRate int) string {
+	if sampleRate <= 0 {
+		return "00:00:00"
+	}
+	frames := samples * 75 / uint64(sampleRate)
+	return fmt.Sprintf("%02d:%02d:%02d", frames/(75*60), frames/75%60, frames%75)
+}
+
+// quoteCueValue quotes a .cue field. The format has no escapes, so double
+// quotes inside the value become single quotes.
+func quoteCueValue(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, "'") + `"`
+}
+
+// buildEmbeddedCueText renders sheet as .cue text referring to audioName.
+func buildEmbeddedCueText(sheet *EmbeddedCueSheet, comments *vorbisCommentMap, audioName string) string {
+	first := func(key string) string {
+		if values := comments.getValues(key); len(values) > 0 {
+			return strings.TrimSpace(values[0])
+		}
+		return ""
+	}
+
+	var b strings.Builder
+	if genre := first("GENRE"); genre != "" {
+		fmt.Fprintf(&b, "REM GENRE %s\n", quoteCueValue(genre))
+	}
+	if date := first("DATE"); date != "" {
+		fmt.Fprintf(&b, "REM DATE %s\n", date)
+	}
+	if sheet.MediaCatalogNumber != "" {
+		fmt.Fprintf(&b, "CATALOG %s\n", sheet.MediaCatalogNumber)
+	}
+	performer := first("ALBUMARTIST")
+	if performer == "" {
+		performer = first("ARTIST")
+	}
+	if performer != "" {
+		fmt.Fprintf(&b, "PERFORMER %s\n", quoteCueValue(performer))
+	}
+	if album := first("ALBUM"); album != "" {
+		fmt.Fprintf(&b, "TITLE %s\n", quoteCueValue(album))
+	}
+	fmt.Fprintf(&b, "FILE %s WAVE\n", quoteCueValue(audioName))
+	for _, track := range sheet.Tracks {
+		kind := "AUDIO"
+		if !track.Audio {
+			kind = "MODE1/2352"
+		}
+		fmt.Fprintf(&b, "  TRACK %02d %s\n", track.Number, kind)
+		if track.PreEmphasis {
+			b.WriteString("    FLAGS PRE\n")
+		}
+		if track.ISRC != "" {
+			fmt.Fprintf(&b, "    ISRC %s\n", track.ISRC)
+		}
+		for _, index := range track.Indexes {
+			fmt.Fprintf(&b, "    INDEX %02d %s\n", index.Number, formatCueIndexTime(index.OffsetSamples, sheet.SampleRate))
+		}
+	}
+	return b.String()
+}
+
+// ExportEmbeddedCue writes a .cue file derived from the CUESHEET block of
+// filePath to outPath. Track layout and ISRCs come from the block; album
+// title, performer, genre and date come from the Vorbis comments.
+func ExportEmbeddedCue(filePath, outPath string) error {
+	if isOpenerPath(filePath) {
+		return viaFileOpenerErr(filePath, false, func(localPath string) error {
+			return ExportEmbeddedCue(localPath, outPath)
+		})
+	}
+	if strings.TrimSpace(outPath) == "" {
+		return fmt.Errorf("output path is empty")
+	}
+	if err := checkWriteAllowed(outPath); err != nil {
+		return err
+	}
+	sheet, comments, err := readEmbeddedCueSheet(filePath)
+	if err != nil {
+		return err
+	}
+	if len(sheet.Tracks) == 0 {
+		return fmt.Errorf("CUESHEET block in %s has no tracks", filePath)
+	}
+
+	text := buildEmbeddedCueText(sheet, comments, filepath.Base(filePath))
+	if err := os.WriteFile(outPath, []byte(text), 0644); err != nil {
+		return fmt.Errorf("failed to write CUE file: %w", err)
+	}
+	GoLog("[CUE] Exported embedded cue sheet of %s to %s (%d tracks)\n", filePath, outPath, len(sheet.Tracks))
+	return nil
+}
@@ -0,0 +1,175 @@
+package gobackend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+type testCueTrack struct {
+	offset  uint64
+	number  byte
+	isrc    string
+	indexes [][2]uint64 // {relative offset, number}
+}
+
+func buildTestCueSheetBlock(catalog string, tracks ...testCueTrack) []byte {
+	data := make([]byte, cueSheetHeaderSize)
+	copy(data, catalog)
+	binary.BigEndian.PutUint64(data[128:136], 88200)
+	data[136] = 0x80
+	data[cueSheetHeaderSize-1] = byte(len(tracks))
+	for _, track := range tracks {
+		raw := make([]byte, cueSheetTrackSize)
+		binary.BigEndian.PutUint64(raw[0:8], track.offset)
+		raw[8] = track.number
+		copy(raw[9:21], track.isrc)
+		raw[cueSheetTrackSize-1] = byte(len(track.indexes))
+		data = append(data, raw...)
+		for _, index := range track.indexes {
+			point := make([]byte, cueSheetIndexSize)
+			binary.BigEndian.PutUint64(point[0:8], index[0])
+			point[8] = byte(index[1])
+			data = append(data, point...)
+		}
+	}
+	return data
+}
+
+// writeCueSheetFLAC writes a tagged fixture with a CUESHEET block placed
+// directly after STREAMINFO, ahead of the Vorbis comments.
+func writeCueSheetFLAC(t *testing.T, block []byte) string {
+	t.Helper()
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Full Album", Artist: "Band", Album: "Live", Genre: "Rock", Date: "1999"})
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	cue := &flac.MetaDataBlock{Type: flac.CueSheet, Data: block}
+	f.Meta = slices.Insert(f.Meta, 1, cue)
+	if err := saveFLACAtomic(f, path); err != nil {
+		t.Fatalf("saveFLACAtomic: %v", err)
+	}
+	dir := filepath.Join(t.TempDir(), "rip")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	album := filepath.Join(dir, "album.flac")
+	if err := os.Rename(path, album); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	return album
+}
+
+func testCueSheetTracks() []testCueTrack {
+	return []testCueTrack{
+		{offset: 0, number: 1, isrc: "USABC9900001", indexes: [][2]uint64{{0, 1}}},
+		{offset: 441000, number: 2, isrc: "USABC9900002", indexes: [][2]uint64{{0, 0}, {88200, 1}}},
+		{offset: 1323000, number: 170},
+	}
+}
+
+func TestParseEmbeddedCueSheet(t *testing.T) {
+	path := writeCueSheetFLAC(t, buildTestCueSheetBlock("0123456789012", testCueSheetTracks()...))
+
+	out, err := ParseEmbeddedCueSheet(path)
+	sheet := mustDecodeJSON[EmbeddedCueSheet](t, out, err)
+	if sheet.MediaCatalogNumber != "0123456789012" || !sheet.IsCD || sheet.SampleRate != 44100 || sheet.LeadOutSamples != 1323000 {
+		t.Fatalf("unexpected sheet header: %+v", sheet)
+	}
+	if len(sheet.Tracks) != 2 {
+		t.Fatalf("expected 2 tracks without the lead-out, got %+v", sheet.Tracks)
+	}
+	second := sheet.Tracks[1]
+	if second.ISRC != "USABC9900002" || !second.Audio || second.PreGap != 10 || second.StartTime != 12 {
+		t.Fatalf("unexpected second track: %+v", second)
+	}
+	if len(second.Indexes) != 2 || second.Indexes[1].OffsetSamples != 529200 {
+		t.Fatalf("index offsets should be absolute: %+v", second.Indexes)
+	}
+	if sheet.Tracks[0].PreGap != -1 {
+		t.Fatalf("track without INDEX 00 should have pre-gap -1: %+v", sheet.Tracks[0])
+	}
+}
+
+func TestParseEmbeddedCueSheetRejectsTruncatedBlock(t *testing.T) {
+	block := buildTestCueSheetBlock("", testCueSheetTracks()...)
+	if _, err := parseFLACCueSheet(block[:len(block)-20], 44100); err == nil {
+		t.Fatal("expected truncated CUESHEET to be rejected")
+	}
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "No Cue"})
+	if _, err := ParseEmbeddedCueSheet(path); err == nil {
+		t.Fatal("expected error for a file without a CUESHEET block")
+	}
+}
+
+func TestEmbedMetadataPreservesCueSheet(t *testing.T) {
+	block := buildTestCueSheetBlock("", testCueSheetTracks()...)
+	path := writeCueSheetFLAC(t, block)
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	coverPath := filepath.Join(t.TempDir(), "cover.png")
+	if err := os.WriteFile(coverPath, cover, 0644); err != nil {
+		t.Fatalf("write cover: %v", err)
+	}
+
+	if err := EmbedMetadata(path, Metadata{Title: "Retagged", Artist: "Band", Album: "Live"}, coverPath); err != nil {
+		t.Fatalf("EmbedMetadata: %v", err)
+	}
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	defer f.Close()
+	var types []flac.BlockType
+	for _, meta := range f.Meta {
+		types = append(types, meta.Type)
+		if meta.Type == flac.CueSheet && !bytes.Equal(meta.Data, block) {
+			t.Fatal("CUESHEET block changed by EmbedMetadata")
+		}
+	}
+	cueIdx, cmtIdx := slices.Index(types, flac.CueSheet), slices.Index(types, flac.VorbisComment)
+	if cueIdx != 1 || cmtIdx < cueIdx {
+		t.Fatalf("CUESHEET block dropped or moved: %v", types)
+	}
+}
+
+func TestExportEmbeddedCue(t *testing.T) {
+	path := writeCueSheetFLAC(t, buildTestCueSheetBlock("0123456789012", testCueSheetTracks()...))
+	outPath := filepath.Join(filepath.Dir(path), "album.cue")
+
+	if err := ExportEmbeddedCue(path, outPath); err != nil {
+		t.Fatalf("ExportEmbeddedCue: %v", err)
+	}
+	text := string(mustReadFile(t, outPath))
+	for _, want := range []string{
+		"CATALOG 0123456789012\n",
+		"PERFORMER \"Band\"\n",
+		"TITLE \"Live\"\n",
+		"FILE \"album.flac\" WAVE\n",
+		"  TRACK 02 AUDIO\n    ISRC USABC9900002\n    INDEX 00 00:10:00\n    INDEX 01 00:12:00\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("exported cue missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "TRACK 170") {
+		t.Fatalf("lead-out exported as a track:\n%s", text)
+	}
+
+	sheet, err := ParseCueFile(outPath)
+	if err != nil {
+		t.Fatalf("re-parse exported cue: %v", err)
+	}
+	if len(sheet.Tracks) != 2 || sheet.Tracks[1].StartTime != 12 || sheet.Tracks[1].ISRC != "USABC9900002" {
+		t.Fatalf("exported cue does not round-trip: %+v", sheet.Tracks)
+	}
+}
@@ -0,0 +1,386 @@
+package gobackend
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	enrichmentQueueFileName    = "enrichment_queue.json"
+	enrichmentQueueMaxAttempts = 5
+
+	EnrichmentKindLyrics = "lyrics"
+	EnrichmentKindCover  = "cover"
+)
+
+type EnrichmentTask struct {
+	ID        string `json:"id"`
+	FilePath  string `json:"file_path"`
+	Kind      string `json:"kind"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type EnrichmentQueueReport struct {
+	Completed int  `json:"completed"`
+	Skipped   int  `json:"skipped"`
+	Failed    int  `json:"failed"`
+	Remaining int  `json:"remaining"`
+	Stopped   bool `json:"stopped"`
+}
+
+// errEnrichmentAlreadyDone tells ProcessQueue the file no longer needs the
+// task, e.g. lyrics were added by another path while it was queued.
+var errEnrichmentAlreadyDone = errors.New("already enriched")
+
+// enrichmentRunners are vars so tests can run the queue without the network.
+var enrichmentRunners = map[string]func(filePath string) error{
+	EnrichmentKindLyrics: enrichLyricsForFile,
+	EnrichmentKindCover:  enrichCoverForFile,
+}
+
+var (
+	enrichmentQueueMu     sync.Mutex
+	enrichmentQueueCancel context.CancelFunc
+)
+
+func enrichmentTaskID(filePath, kind string) string {
+	sum := sha256.Sum256([]byte(filePath + "\x00" + kind))
+	return hex.EncodeToString(sum[:8])
+}
+
+func enrichmentQueuePath() (string, error) {
+	dataDir := GetBackendConfig().DataDir
+	if dataDir == "" {
+		return "", fmt.Errorf("data directory is not configured")
+	}
+	return filepath.Join(dataDir, enrichmentQueueFileName), nil
+}
+
+// loadEnrichmentQueueLocked reads the queue file. enrichmentQueueMu must be held.
+func loadEnrichmentQueueLocked() ([]EnrichmentTask, error) {
+	path, err := enrichmentQueuePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read enrichment queue: %w", err)
+	}
+
+	var tasks []EnrichmentTask
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		GoLog("[EnrichQueue] Discarding unreadable queue file: %v\n", err)
+		return nil, nil
+	}
+	return tasks, nil
+}
+
+// saveEnrichmentQueueLocked writes the queue atomically so a process death
+// mid-write never leaves a truncated file. enrichmentQueueMu must be held.
+func saveEnrichmentQueueLocked(tasks []EnrichmentTask) error {
+	path, err := enrichmentQueuePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	if tasks == nil {
+		tasks = []EnrichmentTask{}
+	}
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write enrichment queue: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to commit enrichment queue: %w", err)
+	}
+	return nil
+}
+
+// EnqueueEnrichment queues lyrics and/or cover enrichment for filePath to run
+// on the next ProcessQueue. Tasks already queued for the same file and kind
+// are kept as they are.
+func EnqueueEnrichment(filePath string, kinds []string) error {
+	filePath = strings.TrimSpace(filePath)
+	if filePath == "" {
+		return fmt.Errorf("file path is empty")
+	}
+	for _, kind := range kinds {
+		if _, ok := enrichmentRunners[kind]; !ok {
+			return fmt.Errorf("unsupported enrichment kind: %s", kind)
+		}
+	}
+
+	enrichmentQueueMu.Lock()
+	defer enrichmentQueueMu.Unlock()
+
+	tasks, err := loadEnrichmentQueueLocked()
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		existing[task.ID] = true
+	}
+
+	added := 0
+	for _, kind := range kinds {
+		id := enrichmentTaskID(filePath, kind)
+		if existing[id] {
+			continue
+		}
+		existing[id] = true
+		tasks = append(tasks, EnrichmentTask{
+			ID:        id,
+			FilePath:  filePath,
+			Kind:      kind,
+			CreatedAt: time.Now().UnixMilli(),
+		})
+		added++
+	}
+	if added == 0 {
+		return nil
+	}
+	return saveEnrichmentQueueLocked(tasks)
+}
+
+func ListQueue() (string, error) {
+	enrichmentQueueMu.Lock()
+	tasks, err := loadEnrichmentQueueLocked()
+	enrichmentQueueMu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	if tasks == nil {
+		tasks = []EnrichmentTask{}
+	}
+
+	jsonBytes, err := json.Marshal(tasks)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+func RemoveFromQueue(taskID string) error {
+	enrichmentQueueMu.Lock()
+	defer enrichmentQueueMu.Unlock()
+
+	tasks, err := loadEnrichmentQueueLocked()
+	if err != nil {
+		return err
+	}
+	for i, task := range tasks {
+		if task.ID == taskID {
+			return saveEnrichmentQueueLocked(append(tasks[:i], tasks[i+1:]...))
+		}
+	}
+	return fmt.Errorf("task not found: %s", taskID)
+}
+
+// isOfflineError reports whether err means the network is unreachable right
+// now, in which case the rest of the queue should wait for the next run.
+func isOfflineError(err error) bool {
+	return errors.Is(err, ErrNetworkDisallowed) ||
+		errors.Is(err, ErrMusicBrainzUnavailable) ||
+		isRetryableFetchError(err) ||
+		strings.Contains(strings.ToLower(err.Error()), "no such host") ||
+		strings.Contains(strings.ToLower(err.Error()), "network is unreachable")
+}
+
+// ProcessQueue runs queued tasks in order. Tasks whose file was deleted or
+// already enriched are dropped; an offline error stops the run and leaves
+// the remaining tasks queued. The queue file is rewritten after every task,
+// so progress survives the process being killed.
+func ProcessQueue(ctx context.Context) (EnrichmentQueueReport, error) {
+	var report EnrichmentQueueReport
+
+	enrichmentQueueMu.Lock()
+	tasks, err := loadEnrichmentQueueLocked()
+	enrichmentQueueMu.Unlock()
+	if err != nil {
+		return report, err
+	}
+
+	for _, task := range tasks {
+		if ctx.Err() != nil {
+			report.Stopped = true
+			break
+		}
+
+		outcome, runErr := runEnrichmentTask(task)
+		if outcome == "offline" {
+			GoLog("[EnrichQueue] Offline, pausing queue: %v\n", runErr)
+			report.Stopped = true
+			break
+		}
+
+		enrichmentQueueMu.Lock()
+		current, err := loadEnrichmentQueueLocked()
+		if err == nil {
+			current = updateEnrichmentTask(current, task.ID, outcome, runErr)
+			err = saveEnrichmentQueueLocked(current)
+		}
+		enrichmentQueueMu.Unlock()
+		if err != nil {
+			return report, err
+		}
+
+		switch outcome {
+		case "done":
+			report.Completed++
+		case "skipped":
+			report.Skipped++
+		default:
+			report.Failed++
+		}
+	}
+
+	enrichmentQueueMu.Lock()
+	remaining, err := loadEnrichmentQueueLocked()
+	enrichmentQueueMu.Unlock()
+	if err != nil {
+		return report, err
+	}
+	report.Remaining = len(remaining)
+
+	GoLog("[EnrichQueue] Run finished: %d done, %d skipped, %d failed, %d remaining\n",
+		report.Completed, report.Skipped, report.Failed, report.Remaining)
+	return report, nil
+}
+
+func runEnrichmentTask(task EnrichmentTask) (string, error) {
+	if _, err := os.Stat(task.FilePath); os.IsNotExist(err) {
+		return "skipped", nil
+	}
+
+	runner, ok := enrichmentRunners[task.Kind]
+	if !ok {
+		return "skipped", nil
+	}
+
+	err := runner(task.FilePath)
+	switch {
+	case err == nil:
+		return "done", nil
+	case errors.Is(err, errEnrichmentAlreadyDone):
+		return "skipped", nil
+	case isOfflineError(err):
+		return "offline", err
+	}
+	return "failed", err
+}
+
+// updateEnrichmentTask removes finished tasks and records failures, dropping
+// a task once it has failed enrichmentQueueMaxAttempts times.
+func updateEnrichmentTask(tasks []EnrichmentTask, id, outcome string, runErr error) []EnrichmentTask {
+	for i := range tasks {
+		if tasks[i].ID != id {
+			continue
+		}
+		if outcome == "failed" {
+			tasks[i].Attempts++
+			tasks[i].LastError = runErr.Error()
+			if tasks[i].Attempts < enrichmentQueueMaxAttempts {
+				return tasks
+			}
+		}
+		return append(tasks[:i], tasks[i+1:]...)
+	}
+	return tasks
+}
+
+func enrichLyricsForFile(filePath string) error {
+	if existing, err := ExtractLyrics(filePath); err == nil && strings.TrimSpace(existing) != "" {
+		return errEnrichmentAlreadyDone
+	}
+
+	meta, err := ReadMetadata(filePath)
+	if err != nil {
+		return err
+	}
+	quality, err := GetAudioQuality(filePath)
+	if err != nil {
+		return err
+	}
+
+	lyrics, err := NewLyricsClient().FetchLyricsAllSources("", meta.Title, meta.Artist, float64(quality.Duration))
+	if err != nil {
+		return fmt.Errorf("lyrics not found: %w", err)
+	}
+	if lyrics.Instrumental {
+		return errEnrichmentAlreadyDone
+	}
+
+	lrc := convertToLRCWithMetadata(lyrics, meta.Title, meta.Artist)
+	if lrc == "" {
+		return fmt.Errorf("failed to generate LRC content")
+	}
+	return EmbedLyrics(filePath, lrc)
+}
+
+func enrichCoverForFile(filePath string) error {
+	if data, err := ExtractCoverArt(filePath); err == nil && len(data) > 0 {
+		return errEnrichmentAlreadyDone
+	}
+	_, err := FindAndEmbedBestCover(filePath)
+	return err
+}
+
+// ProcessEnrichmentQueue runs ProcessQueue until it finishes or
+// CancelEnrichmentQueue is called, returning the run report as JSON.
+func ProcessEnrichmentQueue() (string, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	enrichmentQueueMu.Lock()
+	if enrichmentQueueCancel != nil {
+		enrichmentQueueCancel()
+	}
+	enrichmentQueueCancel = cancel
+	enrichmentQueueMu.Unlock()
+	defer cancel()
+
+	report, err := ProcessQueue(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+func CancelEnrichmentQueue() {
+	enrichmentQueueMu.Lock()
+	defer enrichmentQueueMu.Unlock()
+
+	if enrichmentQueueCancel != nil {
+		enrichmentQueueCancel()
+		enrichmentQueueCancel = nil
+	}
+}
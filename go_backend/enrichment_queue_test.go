@@ -0,0 +1,167 @@
+package gobackend
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func useTestEnrichmentRunners(t *testing.T, runners map[string]func(string) error) {
+	t.Helper()
+	withBackendConfig(t, func(cfg *BackendConfig) { cfg.DataDir = t.TempDir() })
+
+	original := enrichmentRunners
+	enrichmentRunners = runners
+	t.Cleanup(func() { enrichmentRunners = original })
+}
+
+func listTestQueue(t *testing.T) []EnrichmentTask {
+	t.Helper()
+	queueJSON, err := ListQueue()
+	tasks := mustDecodeJSON[[]EnrichmentTask](t, queueJSON, err)
+	return tasks
+}
+
+func TestEnqueueEnrichmentDeduplicatesAndPersists(t *testing.T) {
+	noop := func(string) error { return nil }
+	useTestEnrichmentRunners(t, map[string]func(string) error{
+		EnrichmentKindLyrics: noop,
+		EnrichmentKindCover:  noop,
+	})
+
+	if err := EnqueueEnrichment("/music/a.flac", []string{"lyrics", "cover"}); err != nil {
+		t.Fatalf("EnqueueEnrichment: %v", err)
+	}
+	if err := EnqueueEnrichment("/music/a.flac", []string{"lyrics"}); err != nil {
+		t.Fatalf("EnqueueEnrichment: %v", err)
+	}
+	if err := EnqueueEnrichment("/music/a.flac", []string{"karaoke"}); err == nil {
+		t.Fatal("expected unsupported kind error")
+	}
+
+	tasks := listTestQueue(t)
+	if len(tasks) != 2 {
+		t.Fatalf("expected 2 deduplicated tasks, got %d", len(tasks))
+	}
+
+	// The queue lives on disk, so a fresh process sees the same tasks.
+	data, err := os.ReadFile(filepath.Join(GetBackendConfig().DataDir, enrichmentQueueFileName))
+	if err != nil || len(data) == 0 {
+		t.Fatalf("queue file not written: %v", err)
+	}
+
+	if err := RemoveFromQueue(tasks[0].ID); err != nil {
+		t.Fatalf("RemoveFromQueue: %v", err)
+	}
+	if got := listTestQueue(t); len(got) != 1 || got[0].ID != tasks[1].ID {
+		t.Fatalf("unexpected queue after remove: %+v", got)
+	}
+}
+
+func TestProcessQueueSkipsDeletedAndEnrichedFiles(t *testing.T) {
+	dir := t.TempDir()
+	present := filepath.Join(dir, "present.flac")
+	enriched := filepath.Join(dir, "enriched.flac")
+	for _, p := range []string{present, enriched} {
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ran []string
+	useTestEnrichmentRunners(t, map[string]func(string) error{
+		EnrichmentKindLyrics: func(path string) error {
+			ran = append(ran, path)
+			if path == enriched {
+				return errEnrichmentAlreadyDone
+			}
+			return nil
+		},
+	})
+
+	for _, p := range []string{present, enriched, filepath.Join(dir, "deleted.flac")} {
+		if err := EnqueueEnrichment(p, []string{"lyrics"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := ProcessQueue(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessQueue: %v", err)
+	}
+	if report.Completed != 1 || report.Skipped != 2 || report.Remaining != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(ran) != 2 {
+		t.Fatalf("deleted file should not run, ran = %v", ran)
+	}
+}
+
+func TestProcessQueueStopsWhenOfflineAndKeepsFailures(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.flac")
+	second := filepath.Join(dir, "second.flac")
+	for _, p := range []string{first, second} {
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	offline := true
+	useTestEnrichmentRunners(t, map[string]func(string) error{
+		EnrichmentKindCover: func(path string) error {
+			if offline {
+				return ErrNetworkDisallowed
+			}
+			if path == first {
+				return errors.New("no cover art found")
+			}
+			return nil
+		},
+	})
+	for _, p := range []string{first, second} {
+		if err := EnqueueEnrichment(p, []string{"cover"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := ProcessQueue(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessQueue: %v", err)
+	}
+	if !report.Stopped || report.Remaining != 2 {
+		t.Fatalf("offline run should keep everything queued: %+v", report)
+	}
+
+	offline = false
+	report, err = ProcessQueue(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessQueue: %v", err)
+	}
+	if report.Completed != 1 || report.Failed != 1 || report.Remaining != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	tasks := listTestQueue(t)
+	if tasks[0].Attempts != 1 || tasks[0].LastError == "" {
+		t.Fatalf("failure not recorded: %+v", tasks[0])
+	}
+}
+
+func TestEnrichmentRunnersReportOfflineOnCellular(t *testing.T) {
+	withBackendConfig(t, func(cfg *BackendConfig) { cfg.WifiOnly, cfg.Cellular = true, true })
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Offline Enrichment Probe", Artist: "Nobody", Album: "Nowhere"})
+
+	// Every source fails with ErrNetworkDisallowed; the combined error must
+	// still say so, or the task burns an attempt instead of waiting.
+	for kind, runner := range map[string]func(string) error{
+		EnrichmentKindLyrics: enrichLyricsForFile,
+		EnrichmentKindCover:  enrichCoverForFile,
+	} {
+		err := runner(path)
+		if !errors.Is(err, ErrNetworkDisallowed) || !isOfflineError(err) {
+			t.Fatalf("%s: err = %v, want an offline error", kind, err)
+		}
+	}
+}
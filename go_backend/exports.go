@@ -160,7 +160,11 @@ func FetchMusicBrainzAlbumArtistByISRC(isrc string, albumName string) (string, e
 	var resp *http.Response
 	var lastErr error
 	for attempt := 0; attempt < 3; attempt++ {
-		resp, lastErr = client.Do(req)
+		slotReq, err := reserveHostSlot(req)
+		if err != nil {
+			return "", err
+		}
+		resp, lastErr = client.Do(slotReq)
 		if lastErr == nil && resp.StatusCode == http.StatusOK {
 			break
 		}
@@ -197,6 +201,202 @@ func FetchMusicBrainzAlbumArtistByISRC(isrc string, albumName string) (string, e
 	return "", fmt.Errorf("no MusicBrainz album artist found for ISRC: %s", normalizedISRC)
 }
 
+// EnrichFileJSON is EnrichFile with the field list passed as a JSON array.
+func EnrichFileJSON(filePath, applyFieldsJSON string) (string, error) {
+	var fields []string
+	if err := json.Unmarshal([]byte(applyFieldsJSON), &fields); err != nil {
+		return "", fmt.Errorf("invalid field list: %w", err)
+	}
+	return EnrichFile(filePath, fields)
+}
+
+// EnqueueEnrichmentJSON is EnqueueEnrichment with the kinds passed as a JSON array.
+func EnqueueEnrichmentJSON(filePath, kindsJSON string) error {
+	var kinds []string
+	if err := json.Unmarshal([]byte(kindsJSON), &kinds); err != nil {
+		return fmt.Errorf("invalid enrichment kinds: %w", err)
+	}
+	return EnqueueEnrichment(filePath, kinds)
+}
+
+// DiffMetadataJSON is DiffMetadata with the expected values passed as an
+// ExpectedTrack JSON object and the options as a MetadataDiffOptions object.
+func DiffMetadataJSON(filePath, expectedJSON, optionsJSON string) (string, error) {
+	var expected ExpectedTrack
+	if err := json.Unmarshal([]byte(expectedJSON), &expected); err != nil {
+		return "", fmt.Errorf("invalid expected track JSON: %w", err)
+	}
+	var opts MetadataDiffOptions
+	if strings.TrimSpace(optionsJSON) != "" {
+		if err := json.Unmarshal([]byte(optionsJSON), &opts); err != nil {
+			return "", fmt.Errorf("invalid diff options JSON: %w", err)
+		}
+	}
+	opts.ExpectCover = opts.ExpectCover || expected.HasCover
+	if expected.ExpectedCoverSize > 0 {
+		opts.ExpectedCoverSize = expected.ExpectedCoverSize
+	}
+
+	diffs, err := diffExpectedTrack(filePath, expected, opts)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(diffs)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// StripMetadataJSON is StripMetadata with the keep list passed as a JSON
+// array and the options as a StripOptions object. It returns the StripReport.
+func StripMetadataJSON(filePath, keepJSON, optionsJSON string) (string, error) {
+	var keep []string
+	if strings.TrimSpace(keepJSON) != "" {
+		if err := json.Unmarshal([]byte(keepJSON), &keep); err != nil {
+			return "", fmt.Errorf("invalid keep list: %w", err)
+		}
+	}
+	var opts StripOptions
+	if strings.TrimSpace(optionsJSON) != "" {
+		if err := json.Unmarshal([]byte(optionsJSON), &opts); err != nil {
+			return "", fmt.Errorf("invalid strip options JSON: %w", err)
+		}
+	}
+
+	report, err := StripMetadata(filePath, keep, opts)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// EmbedAllJSON is EmbedAll for callers that cannot pass Go structs, such as
+// the gomobile bindings. metadataJSON is a Metadata object keyed by its
+// field names, matched case-insensitively ("title", "albumartist",
+// "tracknumber"); lyricsJSON is {"text","language"}; optionsJSON is an
+// EmbedOptions object. Any of them may be empty. It returns the EmbedResult.
+func EmbedAllJSON(filePath, metadataJSON, lyricsJSON string, coverData []byte, optionsJSON string) (string, error) {
+	var metadata Metadata
+	if strings.TrimSpace(metadataJSON) != "" {
+		if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+			return "", fmt.Errorf("invalid metadata JSON: %w", err)
+		}
+	}
+	var lyrics Lyrics
+	if strings.TrimSpace(lyricsJSON) != "" {
+		if err := json.Unmarshal([]byte(lyricsJSON), &lyrics); err != nil {
+			return "", fmt.Errorf("invalid lyrics JSON: %w", err)
+		}
+	}
+	var opts EmbedOptions
+	if strings.TrimSpace(optionsJSON) != "" {
+		if err := json.Unmarshal([]byte(optionsJSON), &opts); err != nil {
+			return "", fmt.Errorf("invalid embed options JSON: %w", err)
+		}
+	}
+
+	result, err := EmbedAllWithResult(filePath, metadata, lyrics, coverData, opts)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// SetTagsJSON is SetTags with the pairs passed as a JSON array of
+// {"key","value"} objects.
+func SetTagsJSON(filePath, pairsJSON string, replace bool) error {
+	var pairs []TagPair
+	if err := json.Unmarshal([]byte(pairsJSON), &pairs); err != nil {
+		return fmt.Errorf("invalid tag pairs JSON: %w", err)
+	}
+	return SetTags(filePath, pairs, replace)
+}
+
+// RepairBloatedCommentsJSON is RepairBloatedComments with keepKeys passed as
+// a JSON array. It returns the BloatRepairResult as JSON.
+func RepairBloatedCommentsJSON(filePath, keepKeysJSON string, maxPerKey int, dryRun bool) (string, error) {
+	var keepKeys []string
+	if strings.TrimSpace(keepKeysJSON) != "" {
+		if err := json.Unmarshal([]byte(keepKeysJSON), &keepKeys); err != nil {
+			return "", fmt.Errorf("invalid keep keys JSON: %w", err)
+		}
+	}
+	result, err := RepairBloatedComments(filePath, keepKeys, maxPerKey, dryRun)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// GetTagsJSON is GetTags with the keys passed as a JSON array. It returns a
+// JSON object mapping each key to its values.
+func GetTagsJSON(filePath, keysJSON string) (string, error) {
+	var keys []string
+	if strings.TrimSpace(keysJSON) != "" {
+		if err := json.Unmarshal([]byte(keysJSON), &keys); err != nil {
+			return "", fmt.Errorf("invalid tag keys JSON: %w", err)
+		}
+	}
+
+	tags, err := GetTags(filePath, keys)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(tags)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// DeleteTagsJSON is DeleteTags with the keys passed as a JSON array.
+func DeleteTagsJSON(filePath, keysJSON string) error {
+	var keys []string
+	if err := json.Unmarshal([]byte(keysJSON), &keys); err != nil {
+		return fmt.Errorf("invalid tag keys JSON: %w", err)
+	}
+	return DeleteTags(filePath, keys)
+}
+
+// ParseArtistsJSON is ParseArtists returning a JSON array.
+func ParseArtistsJSON(raw string) (string, error) {
+	artists := ParseArtists(raw)
+	if artists == nil {
+		artists = []string{}
+	}
+	jsonBytes, err := json.Marshal(artists)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// WriteChapterCommentsJSON is WriteChapterComments with the chapters passed
+// as a JSON array of {"start_ms","title"} objects, as ParseChapters returns
+// them.
+func WriteChapterCommentsJSON(filePath, chaptersJSON string) error {
+	var chapters []Chapter
+	if strings.TrimSpace(chaptersJSON) != "" {
+		if err := json.Unmarshal([]byte(chaptersJSON), &chapters); err != nil {
+			return fmt.Errorf("invalid chapters JSON: %w", err)
+		}
+	}
+	return WriteChapterComments(filePath, chapters)
+}
+
 func FetchMusicBrainzGenreByISRC(isrc string) (string, error) {
 	normalizedISRC := strings.ToUpper(strings.TrimSpace(isrc))
 	if normalizedISRC == "" {
@@ -220,7 +420,11 @@ func FetchMusicBrainzGenreByISRC(isrc string) (string, error) {
 	var resp *http.Response
 	var lastErr error
 	for attempt := 0; attempt < 3; attempt++ {
-		resp, lastErr = client.Do(req)
+		slotReq, err := reserveHostSlot(req)
+		if err != nil {
+			return "", err
+		}
+		resp, lastErr = client.Do(slotReq)
 		if lastErr == nil && resp.StatusCode == http.StatusOK {
 			break
 		}
@@ -1092,6 +1296,9 @@ func DownloadTrack(requestJSON string) (string, error) {
 
 // DownloadByStrategy routes all download requests through extension providers.
 func DownloadByStrategy(requestJSON string) (string, error) {
+	if err := checkReadOnlyMode(); err != nil {
+		return errorResponse(err.Error())
+	}
 	var req DownloadRequest
 	if err := json.Unmarshal([]byte(requestJSON), &req); err != nil {
 		return errorResponse("Invalid request: " + err.Error())
@@ -1152,6 +1359,9 @@ func CleanupConnections() {
 }
 
 func ReadFileMetadata(filePath string) (string, error) {
+	if err := checkIncompleteAudioFile(filePath); err != nil {
+		return "", err
+	}
 	lower := strings.ToLower(filePath)
 	isFlac := strings.HasSuffix(lower, ".flac")
 	isM4A := strings.HasSuffix(lower, ".m4a") || strings.HasSuffix(lower, ".mp4") || strings.HasSuffix(lower, ".aac")
@@ -1238,6 +1448,9 @@ func ReadFileMetadata(filePath string) (string, error) {
 			result["isrc"] = metadata.ISRC
 			result["lyrics"] = metadata.Lyrics
 			result["genre"] = metadata.Genre
+			if len(metadata.Genres) > 1 {
+				result["genres"] = metadata.Genres
+			}
 			result["label"] = metadata.Label
 			result["copyright"] = metadata.Copyright
 			result["composer"] = metadata.Composer
@@ -1246,6 +1459,14 @@ func ReadFileMetadata(filePath string) (string, error) {
 			result["replaygain_track_peak"] = metadata.ReplayGainTrackPeak
 			result["replaygain_album_gain"] = metadata.ReplayGainAlbumGain
 			result["replaygain_album_peak"] = metadata.ReplayGainAlbumPeak
+			if metadata.Loudness != nil {
+				result["loudness"] = metadata.Loudness
+			}
+			if tags, err := GetTags(filePath, nil); err == nil {
+				if extra := gaplessTags(tags); len(extra) > 0 {
+					result["extra_tags"] = extra
+				}
+			}
 
 			quality, qualityErr := GetAudioQuality(filePath)
 			if qualityErr == nil {
@@ -1328,6 +1549,9 @@ func ReadFileMetadata(filePath string) (string, error) {
 			result["replaygain_track_peak"] = meta.ReplayGainTrackPeak
 			result["replaygain_album_gain"] = meta.ReplayGainAlbumGain
 			result["replaygain_album_peak"] = meta.ReplayGainAlbumPeak
+			if loudness := meta.loudness(); loudness != nil {
+				result["loudness"] = loudness
+			}
 		}
 		quality, qualityErr := GetMP3Quality(filePath)
 		if qualityErr == nil {
@@ -1366,6 +1590,9 @@ func ReadFileMetadata(filePath string) (string, error) {
 			result["replaygain_track_peak"] = meta.ReplayGainTrackPeak
 			result["replaygain_album_gain"] = meta.ReplayGainAlbumGain
 			result["replaygain_album_peak"] = meta.ReplayGainAlbumPeak
+			if loudness := meta.loudness(); loudness != nil {
+				result["loudness"] = loudness
+			}
 		}
 		quality, qualityErr := GetOggQuality(filePath)
 		if qualityErr == nil {
@@ -1457,6 +1684,10 @@ func ReadFileMetadata(filePath string) (string, error) {
 		return "", fmt.Errorf("unsupported file format: %s", filePath)
 	}
 
+	if issues := checkPlaceholderFields(placeholderFieldsFromResult(result)); len(issues) > 0 {
+		result["quality_issues"] = issues
+	}
+
 	jsonBytes, err := json.Marshal(result)
 	if err != nil {
 		return "", err
@@ -1762,8 +1993,41 @@ func BuildFilename(template string, metadataJSON string) (string, error) {
 	return filename, nil
 }
 
-func SanitizeFilename(filename string) string {
-	return sanitizeFilename(filename)
+// BuildFilenameJSON renders template like BuildFilename and sanitizes the
+// result as one path component with a FilenameSanitizeOptions JSON. An empty
+// profile means FilenameProfileAndroid.
+func BuildFilenameJSON(template, metadataJSON, optionsJSON string) (string, error) {
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		return "", err
+	}
+	var opts FilenameSanitizeOptions
+	if strings.TrimSpace(optionsJSON) != "" {
+		if err := json.Unmarshal([]byte(optionsJSON), &opts); err != nil {
+			return "", fmt.Errorf("invalid filename options JSON: %w", err)
+		}
+	}
+	if opts.Profile == "" {
+		opts.Profile = FilenameProfileAndroid
+	}
+	if err := validateFilenameSanitizeOptions(opts); err != nil {
+		return "", err
+	}
+	return sanitizeFilenameForProfile(buildFilenameFromTemplate(template, metadata), opts), nil
+}
+
+// SanitizeFilenameJSON is SanitizeFilename with a FilenameSanitizeOptions
+// JSON, for a custom replacement character or a shorter length limit.
+// Unlike SanitizeFilename it rejects unknown profiles.
+func SanitizeFilenameJSON(name, optionsJSON string) (string, error) {
+	var opts FilenameSanitizeOptions
+	if err := json.Unmarshal([]byte(optionsJSON), &opts); err != nil {
+		return "", fmt.Errorf("invalid filename options JSON: %w", err)
+	}
+	if err := validateFilenameSanitizeOptions(opts); err != nil {
+		return "", err
+	}
+	return sanitizeFilenameForProfile(name, opts), nil
 }
 
 func FetchLyrics(spotifyID, trackName, artistName string, durationMs int64) (string, error) {
@@ -2474,7 +2738,8 @@ func classifyDownloadErrorType(msg string) string {
 		strings.Contains(lowerMsg, "429") ||
 		strings.Contains(lowerMsg, "too many requests") {
 		return "rate_limit"
-	} else if strings.Contains(lowerMsg, "permission") ||
+	} else if strings.Contains(lowerMsg, "[hint:") ||
+		strings.Contains(lowerMsg, "permission") ||
 		strings.Contains(lowerMsg, "operation not permitted") ||
 		strings.Contains(lowerMsg, "access denied") ||
 		strings.Contains(lowerMsg, "failed to create file") ||
@@ -2544,7 +2809,66 @@ func ExtractCoverToFile(audioPath string, outputPath string) error {
 	return nil
 }
 
+// GetPicturesJSON is GetPictures returning a JSON array.
+func GetPicturesJSON(filePath string) (string, error) {
+	pictures, err := GetPictures(filePath)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(pictures)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// ExtractPictureToFile is the gomobile-friendly form of ExtractPicture. It
+// writes the picture at index to outputPath and returns its MIME type.
+func ExtractPictureToFile(filePath string, index int, outputPath string) (string, error) {
+	data, mimeType, err := ExtractPicture(filePath, index)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write picture file: %w", err)
+	}
+	return mimeType, nil
+}
+
+// FetchCoverToFile is the gomobile-friendly form of FetchCover. urlsJSON is a
+// JSON array of candidate URLs; the returned JSON carries the MIME type and size.
+func FetchCoverToFile(urlsJSON string, preferredDim int, outputPath string) (string, error) {
+	var urls []string
+	if err := json.Unmarshal([]byte(urlsJSON), &urls); err != nil {
+		return "", fmt.Errorf("invalid cover URL list: %w", err)
+	}
+
+	data, mimeType, err := FetchCover(urls, preferredDim)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write cover file: %w", err)
+	}
+
+	jsonBytes, err := json.Marshal(map[string]interface{}{
+		"mime_type": mimeType,
+		"size":      len(data),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
 func FetchAndSaveLyrics(trackName, artistName, spotifyID string, durationMs int64, outputPath string, audioFilePath string) error {
+	return fetchAndSaveLyrics(context.Background(), trackName, artistName, spotifyID, durationMs, outputPath, audioFilePath)
+}
+
+// fetchAndSaveLyrics is FetchAndSaveLyrics with the provider requests bound
+// to ctx, so a cancelled lyrics batch aborts fetches already in flight.
+func fetchAndSaveLyrics(ctx context.Context, trackName, artistName, spotifyID string, durationMs int64, outputPath string, audioFilePath string) error {
 	// If the audio file already has embedded lyrics or a sidecar .lrc,
 	// use those directly instead of making redundant network requests.
 	if audioFilePath != "" {
@@ -2561,7 +2885,7 @@ func FetchAndSaveLyrics(trackName, artistName, spotifyID string, durationMs int6
 	client := NewLyricsClient()
 	durationSec := float64(durationMs) / 1000.0
 
-	lyrics, err := client.FetchLyricsAllSources(spotifyID, trackName, artistName, durationSec)
+	lyrics, err := client.fetchLyricsAllSources(ctx, spotifyID, trackName, artistName, durationSec)
 	if err != nil {
 		return fmt.Errorf("lyrics not found: %w", err)
 	}
@@ -2853,12 +3177,14 @@ func ReEnrichFile(requestJSON string) (string, error) {
 			metadata.Composer = req.Composer
 		}
 
+		var embedResult *EmbedResult
+		var err error
 		if len(coverDataBytes) > 0 {
-			if err := EmbedMetadataWithCoverData(req.FilePath, metadata, coverDataBytes); err != nil {
+			if embedResult, err = EmbedMetadataWithCoverDataResult(req.FilePath, metadata, coverDataBytes); err != nil {
 				return "", fmt.Errorf("failed to embed metadata with cover: %w", err)
 			}
 		} else {
-			if err := EmbedMetadata(req.FilePath, metadata, ""); err != nil {
+			if embedResult, err = EmbedMetadataWithResult(req.FilePath, metadata, ""); err != nil {
 				return "", fmt.Errorf("failed to embed metadata: %w", err)
 			}
 		}
@@ -2879,6 +3205,7 @@ func ReEnrichFile(requestJSON string) (string, error) {
 			"method":            "native",
 			"success":           true,
 			"enriched_metadata": enrichedMeta,
+			"embed_result":      embedResult,
 			"lyrics":            lyricsLRC,
 			"write_external_lrc": req.EmbedLyrics &&
 				req.shouldUpdateField("lyrics") &&
@@ -3977,3 +4304,16 @@ func ReadAudioMetadataWithHintJSON(filePath, displayName string) (string, error)
 func ReadAudioMetadataWithHintAndCoverCacheKeyJSON(filePath, displayName, coverCacheKey string) (string, error) {
 	return ReadAudioMetadataWithDisplayNameAndCoverCacheKey(filePath, displayName, coverCacheKey)
 }
+
+// GetMetadataFootprintJSON is GetMetadataFootprint for the platform bridge.
+func GetMetadataFootprintJSON(filePath string) (string, error) {
+	footprint, err := GetMetadataFootprint(filePath)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(footprint)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
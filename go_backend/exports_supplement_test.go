@@ -141,7 +141,7 @@ func TestExportsJSONWrappersAndExtensionManagerSurface(t *testing.T) {
 	if _, err := BuildFilename("{title}", `not-json`); err == nil {
 		t.Fatal("expected BuildFilename JSON error")
 	}
-	if got := SanitizeFilename(`A/B:C*D?`); strings.ContainsAny(got, `/:*?`) {
+	if got := SanitizeFilename(`A/B:C*D?`, ""); strings.ContainsAny(got, `/:*?`) {
 		t.Fatalf("SanitizeFilename = %q", got)
 	}
 
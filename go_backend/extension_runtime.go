@@ -253,12 +253,13 @@ func newExtensionHTTPClient(ext *loadedExtension, jar http.CookieJar, timeout ti
 	// spotify-web) will redirect http -> https and can end up in 301 loops.
 	// API calls can use response compression for faster metadata/search loads,
 	// while media downloads keep identity transfer semantics for progress/streaming.
+	// The policy layer still applies wifi-only mode and per-host limits.
 	transport := sharedTransport
 	if compressResponses {
 		transport = extensionAPITransport
 	}
 	client := &http.Client{
-		Transport: transport,
+		Transport: newPolicyTransport(transport),
 		Timeout:   timeout,
 		Jar:       jar,
 	}
@@ -19,6 +19,15 @@ type HTTPResponse struct {
 
 const maxExtensionHTTPResponseBytes = 16 << 20
 
+// extensionUserAgent is the User-Agent for extension requests that set
+// none: the configured override when there is one.
+func extensionUserAgent() string {
+	if ua := configuredUserAgent(); ua != "" {
+		return ua
+	}
+	return "Spotiflac-Extension/1.0"
+}
+
 func readExtensionHTTPResponseBody(resp *http.Response) ([]byte, error) {
 	body, err := io.ReadAll(
 		io.LimitReader(resp.Body, maxExtensionHTTPResponseBytes+1),
@@ -107,7 +116,7 @@ func (r *extensionRuntime) httpGet(call goja.FunctionCall) goja.Value {
 	}
 
 	if req.Header.Get("User-Agent") == "" {
-		req.Header.Set("User-Agent", "Spotiflac-Extension/1.0")
+		req.Header.Set("User-Agent", extensionUserAgent())
 	}
 
 	resp, err := r.httpClient.Do(req)
@@ -202,7 +211,7 @@ func (r *extensionRuntime) httpPost(call goja.FunctionCall) goja.Value {
 	}
 
 	if req.Header.Get("User-Agent") == "" {
-		req.Header.Set("User-Agent", "Spotiflac-Extension/1.0")
+		req.Header.Set("User-Agent", extensionUserAgent())
 	}
 	if req.Header.Get("Content-Type") == "" {
 		req.Header.Set("Content-Type", "application/json")
@@ -312,7 +321,7 @@ func (r *extensionRuntime) httpRequest(call goja.FunctionCall) goja.Value {
 	}
 
 	if req.Header.Get("User-Agent") == "" {
-		req.Header.Set("User-Agent", "Spotiflac-Extension/1.0")
+		req.Header.Set("User-Agent", extensionUserAgent())
 	}
 	if bodyStr != "" && req.Header.Get("Content-Type") == "" {
 		req.Header.Set("Content-Type", "application/json")
@@ -438,7 +447,7 @@ func (r *extensionRuntime) httpMethodShortcut(method string, call goja.FunctionC
 		req.Header.Set(k, v)
 	}
 	if req.Header.Get("User-Agent") == "" {
-		req.Header.Set("User-Agent", "Spotiflac-Extension/1.0")
+		req.Header.Set("User-Agent", extensionUserAgent())
 	}
 	if bodyStr != "" && req.Header.Get("Content-Type") == "" {
 		req.Header.Set("Content-Type", "application/json")
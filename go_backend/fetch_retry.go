@@ -0,0 +1,132 @@
+package gobackend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+const defaultFetchMaxAttempts = 3
+
+// Backoff bounds are vars so tests can shrink them.
+var (
+	fetchRetryBaseDelay = 500 * time.Millisecond
+	fetchRetryMaxDelay  = 8 * time.Second
+)
+
+// isRetryableFetchError reports whether err is a transient transport
+// failure: a timeout or a connection dropped mid-request. An
+// http.Client.Timeout wraps context.DeadlineExceeded and counts as a
+// timeout. DNS failures, refused connections, wifi-only blocks and
+// cancellation are final, as is any error once the caller's own context is
+// done, which doFetchWithRetry checks separately.
+func isRetryableFetchError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrNetworkDisallowed) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// fetchRetryDelay returns the wait before retry number attempt (1-based):
+// exponential growth capped at fetchRetryMaxDelay, jittered into the upper
+// half of the window so parallel clients do not retry in lockstep.
+func fetchRetryDelay(attempt int) time.Duration {
+	delay := fetchRetryBaseDelay << (attempt - 1)
+	if delay <= 0 || delay > fetchRetryMaxDelay {
+		delay = fetchRetryMaxDelay
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// doFetchWithRetry sends req, retrying timeouts, connection resets and 5xx
+// responses with jittered exponential backoff. 4xx responses are returned
+// as-is. Each attempt waits for its host's rate-limit slot before the
+// client's timeout starts. The request context is honored between attempts;
+// once attempts run out the last 5xx response is returned for the caller to
+// report.
+func doFetchWithRetry(client *http.Client, req *http.Request, op string) (*http.Response, error) {
+	maxAttempts := GetBackendConfig().FetchMaxAttempts
+	ctx := req.Context()
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq.Body = body
+		}
+
+		attemptReq, err := reserveHostSlot(attemptReq)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(attemptReq)
+
+		var reason string
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return nil, err
+		case err != nil && isRetryableFetchError(err):
+			reason = err.Error()
+		case err != nil:
+			return nil, err
+		case resp.StatusCode >= 500:
+			reason = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		default:
+			if attempt > 1 {
+				GoLog("[Fetch] %s succeeded on attempt %d/%d\n", op, attempt, maxAttempts)
+			}
+			return resp, nil
+		}
+
+		if attempt >= maxAttempts {
+			GoLog("[Fetch] %s failed after %d attempt(s): %s\n", op, attempt, reason)
+			if err != nil {
+				return nil, fmt.Errorf("%s failed after %d attempt(s): %w", op, attempt, err)
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		delay := fetchRetryDelay(attempt)
+		GoLog("[Fetch] %s attempt %d/%d failed (%s), retrying in %v\n", op, attempt, maxAttempts, reason, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
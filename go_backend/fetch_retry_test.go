@@ -0,0 +1,216 @@
+package gobackend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func useFastFetchRetry(t *testing.T) {
+	t.Helper()
+	origBase, origMax := fetchRetryBaseDelay, fetchRetryMaxDelay
+	fetchRetryBaseDelay, fetchRetryMaxDelay = time.Millisecond, 4*time.Millisecond
+	t.Cleanup(func() {
+		fetchRetryBaseDelay, fetchRetryMaxDelay = origBase, origMax
+	})
+}
+
+func TestDoFetchWithRetryRecoversFrom5xx(t *testing.T) {
+	useFastFetchRetry(t)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := doFetchWithRetry(server.Client(), req, "test")
+	if err != nil {
+		t.Fatalf("doFetchWithRetry: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" || calls.Load() != 3 {
+		t.Fatalf("status=%d body=%q calls=%d", resp.StatusCode, body, calls.Load())
+	}
+}
+
+func TestDoFetchWithRetryNeverRetries4xx(t *testing.T) {
+	useFastFetchRetry(t)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := doFetchWithRetry(server.Client(), req, "test")
+	if err != nil {
+		t.Fatalf("doFetchWithRetry: %v", err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 1 {
+		t.Fatalf("4xx was retried: %d calls", calls.Load())
+	}
+}
+
+func TestDoFetchWithRetryStopsAtMaxAttempts(t *testing.T) {
+	useFastFetchRetry(t)
+	withBackendConfig(t, func(cfg *BackendConfig) { cfg.FetchMaxAttempts = 2 })
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := doFetchWithRetry(server.Client(), req, "test")
+	if err != nil {
+		t.Fatalf("doFetchWithRetry: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 2 {
+		t.Fatalf("status=%d calls=%d", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestDoFetchWithRetryHonorsCancellationBetweenAttempts(t *testing.T) {
+	origBase := fetchRetryBaseDelay
+	fetchRetryBaseDelay = time.Minute
+	defer func() { fetchRetryBaseDelay = origBase }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := doFetchWithRetry(server.Client(), req, "test")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("cancellation did not interrupt backoff")
+	}
+}
+
+func TestDoFetchWithRetryRetriesClientTimeout(t *testing.T) {
+	useFastFetchRetry(t)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			<-release
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := server.Client()
+	client.Timeout = 50 * time.Millisecond
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := doFetchWithRetry(client, req, "test")
+	if err != nil {
+		t.Fatalf("doFetchWithRetry: %v", err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 2 {
+		t.Fatalf("client timeout was not retried: %d calls", calls.Load())
+	}
+
+	// The caller's own deadline is final.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	calls.Store(0)
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if _, err := doFetchWithRetry(server.Client(), req, "test"); !errors.Is(err, context.DeadlineExceeded) || calls.Load() != 1 {
+		t.Fatalf("caller deadline: err=%v calls=%d", err, calls.Load())
+	}
+}
+
+func TestIsRetryableFetchError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{io.ErrUnexpectedEOF, true},
+		{fmt.Errorf("dial: %w", syscall.ECONNREFUSED), false},
+		{ErrNetworkDisallowed, false},
+		{context.Canceled, false},
+		{fmt.Errorf("client timeout: %w", context.DeadlineExceeded), true},
+		{errors.New("no such host"), false},
+	}
+	for _, tc := range cases {
+		if got := isRetryableFetchError(tc.err); got != tc.want {
+			t.Fatalf("isRetryableFetchError(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestFetchRetryDelayGrowsWithJitter(t *testing.T) {
+	for attempt := 1; attempt <= 6; attempt++ {
+		window := min(fetchRetryBaseDelay<<(attempt-1), fetchRetryMaxDelay)
+		delay := fetchRetryDelay(attempt)
+		if delay < window/2 || delay > window {
+			t.Fatalf("attempt %d: delay %v outside [%v, %v]", attempt, delay, window/2, window)
+		}
+	}
+}
+
+func TestDeezerGetJSONRetriesAndHonorsCancellation(t *testing.T) {
+	useFastFetchRetry(t)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer server.Close()
+
+	client := &DeezerClient{httpClient: server.Client()}
+	var out struct {
+		ID int `json:"id"`
+	}
+	if err := client.getJSON(context.Background(), server.URL, &out); err != nil || out.ID != 1 || calls.Load() != 2 {
+		t.Fatalf("getJSON = %+v %v after %d call(s)", out, err, calls.Load())
+	}
+
+	fetchRetryBaseDelay, fetchRetryMaxDelay = time.Minute, time.Minute
+	calls.Store(-100)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	if err := client.getJSON(ctx, server.URL, &out); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("cancellation did not interrupt the Deezer backoff")
+	}
+}
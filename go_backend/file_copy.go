@@ -0,0 +1,238 @@
+package gobackend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrFileCopyCancelled is returned by CopyAudioFile after CancelFileCopy.
+var ErrFileCopyCancelled = errors.New("file copy cancelled")
+
+type FileCopyProgress struct {
+	Source      string  `json:"source"`
+	Destination string  `json:"destination"`
+	BytesCopied int64   `json:"bytes_copied"`
+	BytesTotal  int64   `json:"bytes_total"`
+	Progress    float64 `json:"progress"`
+	// Verifying is set while the copy's audio hash is compared.
+	Verifying bool `json:"verifying"`
+	IsActive  bool `json:"is_active"`
+}
+
+// FileCopyResult is the result of CopyAudioFile. Hash is the audio hash
+// both files were found to share, set only when verifying.
+type FileCopyResult struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Bytes       int64  `json:"bytes"`
+	Verified    bool   `json:"verified"`
+	Hash        string `json:"hash,omitempty"`
+	DurationMs  int64  `json:"duration_ms"`
+}
+
+var (
+	fileCopyProgress   FileCopyProgress
+	fileCopyProgressMu sync.RWMutex
+
+	fileCopyCancel   context.CancelFunc
+	fileCopyCancelMu sync.Mutex
+)
+
+func updateFileCopyProgress(update func(p *FileCopyProgress)) {
+	fileCopyProgressMu.Lock()
+	update(&fileCopyProgress)
+	if fileCopyProgress.BytesTotal > 0 {
+		fileCopyProgress.Progress = float64(fileCopyProgress.BytesCopied) / float64(fileCopyProgress.BytesTotal)
+	}
+	fileCopyProgressMu.Unlock()
+}
+
+// GetFileCopyProgress reports the running CopyAudioFile.
+func GetFileCopyProgress() string {
+	fileCopyProgressMu.RLock()
+	defer fileCopyProgressMu.RUnlock()
+
+	jsonBytes, _ := json.Marshal(fileCopyProgress)
+	return string(jsonBytes)
+}
+
+// CancelFileCopy stops a running CopyAudioFile, which removes what it has
+// written of the destination.
+func CancelFileCopy() {
+	fileCopyCancelMu.Lock()
+	defer fileCopyCancelMu.Unlock()
+	if fileCopyCancel != nil {
+		fileCopyCancel()
+		fileCopyCancel = nil
+	}
+}
+
+func startFileCopy() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	fileCopyCancelMu.Lock()
+	if fileCopyCancel != nil {
+		fileCopyCancel()
+	}
+	fileCopyCancel = cancel
+	fileCopyCancelMu.Unlock()
+	return ctx, cancel
+}
+
+// progressWriter counts the bytes written through it into the copy
+// progress.
+type progressWriter struct {
+	w io.Writer
+}
+
+func (p progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	updateFileCopyProgress(func(progress *FileCopyProgress) { progress.BytesCopied += int64(n) })
+	return n, err
+}
+
+// CopyAudioFile copies src to dst, which must not exist yet, for moves
+// across storage volumes where a rename is not possible. The data is
+// streamed into dst+".tmp", synced and checked against the source size
+// before it is renamed to dst, so a full disk or a cancelled copy
+// (CancelFileCopy) never leaves a truncated dst behind. The source's
+// permissions and modification time are carried over where the
+// destination filesystem allows it. With verify the audio hashes of both
+// files are compared afterwards and a mismatching copy is removed.
+// Progress is reported by GetFileCopyProgress.
+func CopyAudioFile(src, dst string, verify bool) (string, error) {
+	if src == "" || dst == "" {
+		return "", fmt.Errorf("source or destination path is empty")
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return "", fmt.Errorf("source not found: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("source is not a file: %s", src)
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return "", fmt.Errorf("destination already exists: %s", dst)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if err := checkWriteAllowed(dst); err != nil {
+		return "", err
+	}
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	ctx, cancel := startFileCopy()
+	defer cancel()
+	started := time.Now()
+	updateFileCopyProgress(func(p *FileCopyProgress) {
+		*p = FileCopyProgress{Source: src, Destination: dst, BytesTotal: info.Size(), IsActive: true}
+	})
+	defer updateFileCopyProgress(func(p *FileCopyProgress) { p.IsActive, p.Verifying = false, false })
+
+	written, err := copyFileToTemp(ctx, src, dst, info)
+	if err != nil {
+		return "", err
+	}
+	result := FileCopyResult{Source: src, Destination: dst, Bytes: written}
+
+	if verify {
+		updateFileCopyProgress(func(p *FileCopyProgress) { p.Verifying = true })
+		hash, err := verifyCopiedAudio(ctx, src, dst)
+		if err != nil {
+			os.Remove(dst)
+			if ctx.Err() != nil {
+				err = ErrFileCopyCancelled
+			}
+			return "", err
+		}
+		result.Verified, result.Hash = true, hash
+	}
+	result.DurationMs = time.Since(started).Milliseconds()
+	GoLog("[Copy] Copied %d bytes %s -> %s (verified: %v)\n", written, src, dst, result.Verified)
+
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// copyFileToTemp streams src into dst+".tmp" and renames it to dst once
+// all of it is on disk. The temporary file is removed on any failure.
+func copyFileToTemp(ctx context.Context, src, dst string, info os.FileInfo) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open source: %w", err)
+	}
+	defer in.Close()
+
+	tmpPath := dst + ".tmp"
+	out, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, classifyWriteError(dst, fmt.Errorf("failed to create destination: %w", err))
+	}
+	fail := func(err error) (int64, error) {
+		out.Close()
+		os.Remove(tmpPath)
+		if ctx.Err() != nil {
+			return 0, ErrFileCopyCancelled
+		}
+		return 0, classifyWriteError(dst, err)
+	}
+
+	written, err := io.CopyBuffer(progressWriter{w: out}, contextReader{ctx: ctx, r: in}, make([]byte, flacRewriteChunkSize))
+	if err != nil {
+		return fail(fmt.Errorf("failed to copy %s: %w", filepath.Base(src), err))
+	}
+	if written != info.Size() {
+		return fail(fmt.Errorf("copied %d of %d bytes of %s", written, info.Size(), src))
+	}
+	if err := out.Sync(); err != nil {
+		return fail(fmt.Errorf("failed to sync destination: %w", err))
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return 0, classifyWriteError(dst, fmt.Errorf("failed to close destination: %w", err))
+	}
+
+	// Some filesystems (FAT on SD cards, SAF mounts) reject these; the copy
+	// is still good.
+	if err := os.Chmod(tmpPath, info.Mode().Perm()); err != nil {
+		GoLog("[Copy] Could not keep permissions of %s: %v\n", src, err)
+	}
+	if err := os.Chtimes(tmpPath, info.ModTime(), info.ModTime()); err != nil {
+		GoLog("[Copy] Could not keep modification time of %s: %v\n", src, err)
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return 0, classifyWriteError(dst, fmt.Errorf("failed to move copy into place: %w", err))
+	}
+	return written, nil
+}
+
+// verifyCopiedAudio compares the audio hashes of src and its copy dst and
+// returns the shared hash.
+func verifyCopiedAudio(ctx context.Context, src, dst string) (string, error) {
+	want, err := hashAudioContent(ctx, src)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash source: %w", err)
+	}
+	got, err := hashAudioContent(ctx, dst)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash copy: %w", err)
+	}
+	if got.Hash != want.Hash || got.Size != want.Size {
+		return "", fmt.Errorf("copy of %s does not match the source", src)
+	}
+	return want.Hash, nil
+}
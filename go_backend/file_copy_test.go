@@ -0,0 +1,90 @@
+package gobackend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopyAudioFilePreservesContentAndTimes(t *testing.T) {
+	src := writeTestFLACWithMetadata(t, Metadata{Title: "Copy", Artist: "A"})
+	modTime := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(src, modTime, modTime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if err := os.Chmod(src, 0640); err != nil {
+		t.Fatalf("Chmod: %v", err)
+	}
+	dst := filepath.Join(t.TempDir(), "copy.flac")
+
+	out, err := CopyAudioFile(src, dst, true)
+	result := mustDecodeJSON[FileCopyResult](t, out, err)
+	if !result.Verified || result.Hash == "" || result.Bytes != int64(len(mustReadFile(t, src))) {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if string(mustReadFile(t, dst)) != string(mustReadFile(t, src)) {
+		t.Fatal("copy differs from source")
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if !info.ModTime().Equal(modTime) || info.Mode().Perm() != 0640 {
+		t.Fatalf("mtime %v mode %v not preserved", info.ModTime(), info.Mode())
+	}
+	if _, err := os.Stat(dst + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary file left behind: %v", err)
+	}
+
+	var progress FileCopyProgress
+	if err := json.Unmarshal([]byte(GetFileCopyProgress()), &progress); err != nil {
+		t.Fatalf("decode progress: %v", err)
+	}
+	if progress.IsActive || progress.BytesCopied != result.Bytes || progress.Progress != 1 {
+		t.Fatalf("unexpected final progress: %+v", progress)
+	}
+
+	if _, err := CopyAudioFile(src, dst, false); err == nil {
+		t.Fatal("expected an existing destination to be rejected")
+	}
+}
+
+func TestCopyAudioFileCancelledRemovesPartialCopy(t *testing.T) {
+	src := writeTestFLACWithMetadata(t, Metadata{Title: "Cancel"})
+	info, err := os.Stat(src)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	dst := filepath.Join(t.TempDir(), "copy.flac")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := copyFileToTemp(ctx, src, dst, info); !errors.Is(err, ErrFileCopyCancelled) {
+		t.Fatalf("expected ErrFileCopyCancelled, got %v", err)
+	}
+	for _, path := range []string{dst, dst + ".tmp"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s left behind: %v", path, err)
+		}
+	}
+}
+
+func TestVerifyCopiedAudioDetectsMismatch(t *testing.T) {
+	a := writeTestFLACWithMetadata(t, Metadata{Title: "A"})
+	b := filepath.Join(t.TempDir(), "b.flac")
+	data := mustReadFile(t, a)
+	data[len(data)-1] ^= 0xFF
+	if err := os.WriteFile(b, data, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := verifyCopiedAudio(context.Background(), a, b); err == nil {
+		t.Fatal("expected differing audio to fail verification")
+	}
+	if _, err := verifyCopiedAudio(context.Background(), a, a); err != nil {
+		t.Fatalf("identical audio failed verification: %v", err)
+	}
+}
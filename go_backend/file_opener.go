@@ -0,0 +1,262 @@
+package gobackend
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// FileHandle is an open file returned by a FileOpener.
+//
+// ReadChunk returns the next bytes of the file, at most n of them, and an
+// empty slice at the end of the file. It returns the data rather than
+// filling a buffer because gomobile hands a foreign implementation a copy
+// of any byte slice argument, so bytes written into it never reach Go.
+type FileHandle interface {
+	ReadChunk(n int) ([]byte, error)
+	Write(p []byte) (int, error)
+	Seek(offset int64, whence int) (int64, error)
+	Truncate(size int64) error
+	Close() error
+}
+
+// FileOpener opens names the backend cannot reach through the filesystem,
+// such as content:// URIs on removable SD cards or cloud providers. The app
+// implements it over its content resolver and registers it with
+// SetFileOpener.
+type FileOpener interface {
+	Open(name string, write bool) (FileHandle, error)
+}
+
+// osFileHandle is a FileHandle over an *os.File.
+type osFileHandle struct{ *os.File }
+
+func (h osFileHandle) ReadChunk(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := h.Read(buf)
+	if err == io.EOF {
+		err = nil
+	}
+	return buf[:read], err
+}
+
+// openOSFileHandle opens path for reading, or for reading and writing with
+// write.
+func openOSFileHandle(path string, write bool) (FileHandle, error) {
+	flag := os.O_RDONLY
+	if write {
+		flag = os.O_RDWR
+	}
+	f, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	return osFileHandle{f}, nil
+}
+
+// osFileOpener is the default FileOpener. It understands file:// URIs and
+// plain paths, so desktop and test usage needs no registration.
+type osFileOpener struct{}
+
+func (osFileOpener) Open(name string, write bool) (FileHandle, error) {
+	if u, err := url.Parse(name); err == nil && u.Scheme == "file" {
+		name = u.Path
+	}
+	return openOSFileHandle(name, write)
+}
+
+// fileHandleReader reads a FileHandle as an io.Reader, copying each chunk
+// into the caller's buffer.
+type fileHandleReader struct{ h FileHandle }
+
+func (r fileHandleReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	chunk, err := r.h.ReadChunk(len(p))
+	if len(chunk) > len(p) {
+		return 0, fmt.Errorf("file handle returned %d bytes, asked for %d", len(chunk), len(p))
+	}
+	n := copy(p, chunk)
+	if err == nil && n == 0 {
+		err = io.EOF
+	}
+	return n, err
+}
+
+var (
+	fileOpenerMu sync.RWMutex
+	fileOpener   FileOpener = osFileOpener{}
+)
+
+// SetFileOpener registers the opener used for paths with a URI scheme.
+// Passing nil restores the default, which only handles file:// URIs.
+func SetFileOpener(opener FileOpener) {
+	if opener == nil {
+		opener = osFileOpener{}
+	}
+	fileOpenerMu.Lock()
+	fileOpener = opener
+	fileOpenerMu.Unlock()
+}
+
+func currentFileOpener() FileOpener {
+	fileOpenerMu.RLock()
+	defer fileOpenerMu.RUnlock()
+	return fileOpener
+}
+
+var uriSchemePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*://`)
+
+// isOpenerPath reports whether filePath is a URI such as content://... that
+// must go through the registered FileOpener.
+func isOpenerPath(filePath string) bool {
+	return uriSchemePattern.MatchString(filePath)
+}
+
+// openerLocalExt picks the extension for the local copy of name, which the
+// path-based APIs use to detect the format. Content URIs rarely carry one,
+// so the header is sniffed as a fallback.
+func openerLocalExt(name string, header []byte) string {
+	if u, err := url.Parse(name); err == nil {
+		if ext := strings.ToLower(path.Ext(u.Path)); ext != "" && len(ext) <= 5 {
+			return ext
+		}
+	}
+	switch {
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return ".flac"
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return ".ogg"
+	case bytes.HasPrefix(header, []byte("ID3")), len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		return ".mp3"
+	case len(header) >= 8 && string(header[4:8]) == "ftyp":
+		return ".m4a"
+	case bytes.HasPrefix(header, []byte("RIFF")):
+		return ".wav"
+	case bytes.HasPrefix(header, []byte("FORM")):
+		return ".aiff"
+	}
+	return ""
+}
+
+// viaFileOpener runs fn on a local copy of the file behind name. With write,
+// the copy is written back through the opener when fn succeeds and changed
+// it. Content providers cannot rename over a document, so unlike the
+// path-based save the write-back is not atomic: when it fails the edited
+// local copy is kept and its path is in the error, so the app can recover
+// the file.
+func viaFileOpener[T any](name string, write bool, fn func(localPath string) (T, error)) (T, error) {
+	var zero T
+	if write {
+		if err := checkReadOnlyMode(); err != nil {
+			return zero, err
+		}
+	}
+	opener := currentFileOpener()
+
+	src, err := opener.Open(name, false)
+	if err != nil {
+		return zero, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	header := make([]byte, 12)
+	n, _ := io.ReadFull(fileHandleReader{src}, header)
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		src.Close()
+		return zero, fmt.Errorf("failed to rewind %s: %w", name, err)
+	}
+
+	local, err := os.CreateTemp("", "spotiflac-opener-*"+openerLocalExt(name, header[:n]))
+	if err != nil {
+		src.Close()
+		return zero, fmt.Errorf("failed to create local copy: %w", err)
+	}
+	localPath := local.Name()
+	keepLocal := false
+	defer func() {
+		if !keepLocal {
+			os.Remove(localPath)
+		}
+	}()
+	defer invalidateMetadataCache(localPath)
+
+	_, copyErr := io.Copy(local, fileHandleReader{src})
+	src.Close()
+	if closeErr := local.Close(); copyErr == nil {
+		copyErr = closeErr
+	}
+	if copyErr != nil {
+		return zero, fmt.Errorf("failed to copy %s: %w", name, copyErr)
+	}
+	before, err := os.Stat(localPath)
+	if err != nil {
+		return zero, err
+	}
+
+	result, err := fn(localPath)
+	if err != nil || !write {
+		return result, err
+	}
+
+	after, err := os.Stat(localPath)
+	if err != nil {
+		return zero, err
+	}
+	if after.Size() == before.Size() && after.ModTime().Equal(before.ModTime()) {
+		return result, nil
+	}
+	if err := copyBackThroughOpener(opener, name, localPath); err != nil {
+		keepLocal = true
+		GoLog("[FileOpener] Write-back to %s failed, edited copy kept at %s: %v\n", name, localPath, err)
+		return zero, fmt.Errorf("%w (edited copy kept at %s)", err, localPath)
+	}
+	return result, nil
+}
+
+// viaFileOpenerErr is viaFileOpener for functions that only return an error.
+func viaFileOpenerErr(name string, write bool, fn func(localPath string) error) error {
+	_, err := viaFileOpener(name, write, func(localPath string) (struct{}, error) {
+		return struct{}{}, fn(localPath)
+	})
+	return err
+}
+
+// copyBackThroughOpener overwrites name with the file at localPath. The
+// document is truncated only after the new bytes are written, so a failed
+// write leaves at worst a mix of old and new bytes rather than an empty
+// file.
+func copyBackThroughOpener(opener FileOpener, name, localPath string) error {
+	local, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer local.Close()
+
+	dst, err := opener.Open(name, true)
+	if err != nil {
+		return fmt.Errorf("failed to open %s for writing: %w", name, err)
+	}
+	if _, err := dst.Seek(0, io.SeekStart); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to rewind %s: %w", name, err)
+	}
+	written, err := io.Copy(dst, local)
+	if err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := dst.Truncate(written); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to truncate %s: %w", name, err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package gobackend
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-flac/flacpicture/v2"
+)
+
+// dirFileOpener serves test://<name> from a directory, like a content
+// resolver handing out descriptors.
+type dirFileOpener struct {
+	dir    string
+	writes int
+}
+
+func (o *dirFileOpener) Open(name string, write bool) (FileHandle, error) {
+	path := filepath.Join(o.dir, strings.TrimPrefix(name, "test://"))
+	if write {
+		o.writes++
+	}
+	return openOSFileHandle(path, write)
+}
+
+func useDirFileOpener(t *testing.T) *dirFileOpener {
+	t.Helper()
+	opener := &dirFileOpener{dir: t.TempDir()}
+	SetFileOpener(opener)
+	t.Cleanup(func() { SetFileOpener(nil) })
+	return opener
+}
+
+// serveThroughOpener moves the file at path into the opener's directory and
+// returns its test:// URI.
+func serveThroughOpener(t *testing.T, opener *dirFileOpener, path, name string) string {
+	t.Helper()
+	if err := os.Rename(path, filepath.Join(opener.dir, name)); err != nil {
+		t.Fatal(err)
+	}
+	return "test://" + name
+}
+
+func TestFileOpenerRoutesURIPaths(t *testing.T) {
+	opener := useDirFileOpener(t)
+	fixture := writeTestFLACWithMetadata(t, Metadata{Title: "Before"})
+	// No extension: the format is sniffed from the header.
+	if err := os.Rename(fixture, filepath.Join(opener.dir, "document42")); err != nil {
+		t.Fatal(err)
+	}
+	const uri = "test://document42"
+
+	if err := EmbedMetadata(uri, Metadata{Title: "After", Artist: "Artist"}, ""); err != nil {
+		t.Fatalf("EmbedMetadata: %v", err)
+	}
+	if opener.writes != 1 {
+		t.Fatalf("expected one write-back, got %d", opener.writes)
+	}
+	meta, err := ReadMetadata(uri)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if meta.Title != "After" || meta.Artist != "Artist" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+
+	// Deleting a tag the file does not have changes nothing.
+	if err := DeleteTags(uri, []string{"MOOD"}); err != nil {
+		t.Fatalf("DeleteTags: %v", err)
+	}
+	if opener.writes != 1 {
+		t.Fatalf("unchanged file was written back")
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(os.TempDir(), "spotiflac-opener-*"))
+	if len(matches) != 0 {
+		t.Fatalf("local copies left behind: %v", matches)
+	}
+}
+
+func TestDefaultFileOpenerHandlesFileURIs(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Local"})
+
+	tags, err := GetTags("file://"+path, []string{"TITLE"})
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if len(tags["TITLE"]) != 1 || tags["TITLE"][0] != "Local" {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+
+	if _, err := ReadMetadata("content://media/external/audio/1"); err == nil {
+		t.Fatal("default opener should not resolve content URIs")
+	}
+}
+
+func TestFileOpenerRespectsReadOnlyMode(t *testing.T) {
+	opener := useDirFileOpener(t)
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	if err := Configure(`{"read_only":true}`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EmbedLyrics("test://missing.flac", "la"); err != ErrReadOnlyMode {
+		t.Fatalf("expected ErrReadOnlyMode, got %v", err)
+	}
+	if opener.writes != 0 {
+		t.Fatal("read-only mode opened the file for writing")
+	}
+}
+
+// failingWriteOpener hands out write handles whose writes fail, like a
+// provider whose storage vanished mid-save.
+type failingWriteOpener struct{ dirFileOpener }
+
+type failingWriteHandle struct{ f *os.File }
+
+func (h failingWriteHandle) ReadChunk(n int) ([]byte, error)           { return osFileHandle{h.f}.ReadChunk(n) }
+func (failingWriteHandle) Write([]byte) (int, error)                   { return 0, errors.New("device unplugged") }
+func (h failingWriteHandle) Seek(off int64, whence int) (int64, error) { return h.f.Seek(off, whence) }
+func (h failingWriteHandle) Truncate(size int64) error                 { return h.f.Truncate(size) }
+func (h failingWriteHandle) Close() error                              { return h.f.Close() }
+
+func (o *failingWriteOpener) Open(name string, write bool) (FileHandle, error) {
+	f, err := o.dirFileOpener.Open(name, write)
+	if err != nil || !write {
+		return f, err
+	}
+	return failingWriteHandle{f.(osFileHandle).File}, nil
+}
+
+func TestFileOpenerKeepsLocalCopyWhenWriteBackFails(t *testing.T) {
+	opener := &failingWriteOpener{dirFileOpener{dir: t.TempDir()}}
+	SetFileOpener(opener)
+	t.Cleanup(func() { SetFileOpener(nil) })
+	document := filepath.Join(opener.dir, "song.flac")
+	if err := os.Rename(writeTestFLACWithMetadata(t, Metadata{Title: "Before"}), document); err != nil {
+		t.Fatal(err)
+	}
+	original, _ := os.ReadFile(document)
+
+	err := EmbedMetadata("test://song.flac", Metadata{Title: "After"}, "")
+	if err == nil {
+		t.Fatal("EmbedMetadata succeeded with a failing write-back")
+	}
+	if current, _ := os.ReadFile(document); !bytes.Equal(current, original) {
+		t.Fatalf("document changed by a failed write: %d bytes, was %d", len(current), len(original))
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(os.TempDir(), "spotiflac-opener-*"))
+	var kept string
+	for _, match := range matches {
+		if strings.Contains(err.Error(), match) {
+			kept = match
+		}
+	}
+	if kept == "" {
+		t.Fatalf("error does not name a kept copy: %v", err)
+	}
+	defer os.Remove(kept)
+	meta, readErr := ReadMetadata(kept)
+	if readErr != nil || meta.Title != "After" {
+		t.Fatalf("kept copy = %+v, %v", meta, readErr)
+	}
+}
+
+func TestEmbedGenreLabelThroughFileOpener(t *testing.T) {
+	opener := useDirFileOpener(t)
+	uri := serveThroughOpener(t, opener, writeTestFLACWithMetadata(t, Metadata{Title: "Song"}), "song.flac")
+
+	if err := EmbedGenreLabel(uri, "Jazz", "Blue Note"); err != nil {
+		t.Fatalf("EmbedGenreLabel: %v", err)
+	}
+	if opener.writes != 1 {
+		t.Fatalf("expected one write-back, got %d", opener.writes)
+	}
+	if meta, err := ReadMetadata(uri); err != nil || meta.Genre != "Jazz" || meta.Label != "Blue Note" {
+		t.Fatalf("ReadMetadata = %+v, %v", meta, err)
+	}
+}
+
+func TestRewriteSplitArtistTagsThroughFileOpener(t *testing.T) {
+	opener := useDirFileOpener(t)
+	uri := serveThroughOpener(t, opener, writeTestFLACWithMetadata(t, Metadata{Title: "Song", Artist: "A, B"}), "song.flac")
+
+	if err := RewriteSplitArtistTags(uri, "A, B", "A"); err != nil {
+		t.Fatalf("RewriteSplitArtistTags: %v", err)
+	}
+	if opener.writes != 1 {
+		t.Fatalf("expected one write-back, got %d", opener.writes)
+	}
+	tags, err := GetTags(uri, []string{"ARTIST"})
+	if err != nil || len(tags["ARTIST"]) != 2 {
+		t.Fatalf("GetTags = %v, %v", tags, err)
+	}
+}
+
+func TestGetAudioQualityThroughFileOpener(t *testing.T) {
+	opener := useDirFileOpener(t)
+	uri := serveThroughOpener(t, opener, writeTestFLACWithMetadata(t, Metadata{Title: "Song"}), "document7")
+
+	quality, err := GetAudioQuality(uri)
+	if err != nil || quality.SampleRate == 0 || quality.BitDepth == 0 {
+		t.Fatalf("GetAudioQuality = %+v, %v", quality, err)
+	}
+	if opener.writes != 0 {
+		t.Fatal("read opened the file for writing")
+	}
+}
+
+func TestGetPicturesThroughFileOpener(t *testing.T) {
+	opener := useDirFileOpener(t)
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	path := writeMultiPictureFLAC(t, &flacpicture.MetadataBlockPicture{
+		PictureType: flacpicture.PictureTypeFrontCover, MIME: "image/png", Width: 8, Height: 8, ImageData: cover,
+	})
+	uri := serveThroughOpener(t, opener, path, "song.flac")
+
+	pictures, err := GetPictures(uri)
+	if err != nil || len(pictures) != 1 || pictures[0].TypeName != "Front Cover" {
+		t.Fatalf("GetPictures = %+v, %v", pictures, err)
+	}
+}
+
+func TestVerifyFLACThroughFileOpener(t *testing.T) {
+	opener := useDirFileOpener(t)
+	uri := serveThroughOpener(t, opener, writeVerifyFixture(t, nil), "song.flac")
+
+	raw, err := VerifyFLAC(uri, true)
+	report := mustDecodeJSON[FLACVerifyReport](t, raw, err)
+	if !report.Valid || report.FrameCount == 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+// memoryFileOpener serves documents from memory the way a gomobile-bound
+// Kotlin opener does: ReadChunk returns freshly allocated, short chunks and
+// Write only sees a copy of the caller's bytes.
+type memoryFileOpener struct{ docs map[string][]byte }
+
+type memoryFileHandle struct {
+	opener *memoryFileOpener
+	name   string
+	pos    int64
+}
+
+func (o *memoryFileOpener) Open(name string, write bool) (FileHandle, error) {
+	if _, ok := o.docs[name]; !ok {
+		return nil, os.ErrNotExist
+	}
+	return &memoryFileHandle{opener: o, name: name}, nil
+}
+
+func (h *memoryFileHandle) ReadChunk(n int) ([]byte, error) {
+	doc := h.opener.docs[h.name]
+	n = min(n, 7, len(doc)-int(h.pos))
+	chunk := append([]byte(nil), doc[h.pos:h.pos+int64(n)]...)
+	h.pos += int64(n)
+	return chunk, nil
+}
+
+func (h *memoryFileHandle) Write(p []byte) (int, error) {
+	doc := h.opener.docs[h.name]
+	if end := h.pos + int64(len(p)); end > int64(len(doc)) {
+		doc = append(doc, make([]byte, end-int64(len(doc)))...)
+	}
+	copy(doc[h.pos:], append([]byte(nil), p...))
+	h.opener.docs[h.name] = doc
+	h.pos += int64(len(p))
+	return len(p), nil
+}
+
+func (h *memoryFileHandle) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += h.pos
+	case io.SeekEnd:
+		offset += int64(len(h.opener.docs[h.name]))
+	}
+	h.pos = offset
+	return offset, nil
+}
+
+func (h *memoryFileHandle) Truncate(size int64) error {
+	h.opener.docs[h.name] = h.opener.docs[h.name][:size]
+	return nil
+}
+
+func (h *memoryFileHandle) Close() error { return nil }
+
+func TestFileOpenerReadsChunksReturnedByTheHandle(t *testing.T) {
+	const uri = "content://media/42"
+	opener := &memoryFileOpener{docs: map[string][]byte{
+		uri: mustReadFile(t, writeTestFLACWithMetadata(t, Metadata{Title: "Before"})),
+	}}
+	SetFileOpener(opener)
+	t.Cleanup(func() { SetFileOpener(nil) })
+
+	if err := EmbedMetadata(uri, Metadata{Title: "After", Artist: "Artist"}, ""); err != nil {
+		t.Fatalf("EmbedMetadata: %v", err)
+	}
+	meta, err := ReadMetadata(uri)
+	if err != nil || meta.Title != "After" || meta.Artist != "Artist" {
+		t.Fatalf("ReadMetadata = %+v, %v", meta, err)
+	}
+	if _, err := GetAudioQuality(uri); err != nil {
+		t.Fatalf("GetAudioQuality: %v", err)
+	}
+}
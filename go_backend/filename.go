@@ -61,22 +61,10 @@ func sanitizeFilename(filename string) string {
 }
 
 func truncateUTF8Bytes(value string, maxBytes int) string {
-	if maxBytes <= 0 || len(value) <= maxBytes {
+	if maxBytes <= 0 {
 		return value
 	}
-
-	used := 0
-	for i, r := range value {
-		runeLen := utf8.RuneLen(r)
-		if runeLen < 0 {
-			runeLen = len(string(r))
-		}
-		if used+runeLen > maxBytes {
-			return value[:i]
-		}
-		used += runeLen
-	}
-	return value
+	return truncateGraphemes(value, maxBytes, func(prefix string) int { return len(prefix) })
 }
 
 func buildFilenameFromTemplate(template string, metadata map[string]interface{}) string {
@@ -0,0 +1,296 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Filename consistency modes.
+const (
+	filenameCheckReport = "report"
+	filenameCheckDryRun = "dry_run"
+	filenameCheckApply  = "apply"
+)
+
+// FilenameMismatch is a file whose name does not match what the template
+// renders from its tags. Expected is a base name in the same directory.
+type FilenameMismatch struct {
+	Path     string `json:"path"`
+	Actual   string `json:"actual"`
+	Expected string `json:"expected"`
+	// Action is "would_rename" in dry-run mode and "renamed" or "failed"
+	// in apply mode; empty for a plain report. "collision" marks a file left
+	// alone in either mode because Expected differs from another file in the
+	// folder only by case.
+	Action string `json:"action,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type FilenameConsistencyDirectory struct {
+	// Directory is relative to the scanned root ("." for the root itself).
+	Directory  string             `json:"directory"`
+	Mismatches []FilenameMismatch `json:"mismatches"`
+}
+
+type FilenameConsistencyReport struct {
+	Root       string `json:"root"`
+	Template   string `json:"template"`
+	Mode       string `json:"mode"`
+	Checked    int    `json:"checked"`
+	Mismatched int    `json:"mismatched"`
+	Renamed    int    `json:"renamed"`
+	Failed     int    `json:"failed"`
+	Skipped    int    `json:"skipped"`
+	// Resumed counts files skipped because the journal shows them done.
+	Resumed     int                            `json:"resumed,omitempty"`
+	Directories []FilenameConsistencyDirectory `json:"directories"`
+	Warnings    []PathWarning                  `json:"warnings,omitempty"`
+	ChangeReportOutput
+}
+
+// filenameComparisonKey drops everything a sanitizer may replace or strip,
+// so "AC_DC - Live" and "AC DC - Live" compare equal but "Live" and "Love"
+// do not.
+func filenameComparisonKey(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFC.String(name) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// expectedFilenameFromTags renders template from the tags of result the way
+// downloads name their files, keeping the file's own extension.
+func expectedFilenameFromTags(template string, result *LibraryScanResult, ext string) string {
+	metadata := map[string]interface{}{
+		"title":        result.TrackName,
+		"artist":       result.ArtistName,
+		"album":        result.AlbumName,
+		"album_artist": result.AlbumArtist,
+		"track":        result.TrackNumber,
+		"total_tracks": result.TotalTracks,
+		"disc":         result.DiscNumber,
+		"total_discs":  result.TotalDiscs,
+		"year":         extractYear(result.ReleaseDate),
+		"date":         result.ReleaseDate,
+		"isrc":         result.ISRC,
+		"composer":     result.Composer,
+	}
+	filename := buildFilenameFromTemplate(template, metadata)
+	if strings.TrimSpace(filename) == "" {
+		filename = fmt.Sprintf("%s - %s", result.ArtistName, result.TrackName)
+	}
+	return sanitizeFilename(filename) + ext
+}
+
+// renameLibraryFile moves filePath to newName in the same directory, taking
+// its .lrc sidecar along. It never replaces an existing file.
+// A case-only rename goes through a temporary name, since on
+// case-insensitive storage the target already "exists" as the file itself.
+func renameLibraryFile(filePath, newName string) error {
+	target := filepath.Join(filepath.Dir(filePath), newName)
+	source := filePath
+	if _, err := os.Lstat(target); err == nil {
+		if !sameFile(target, filePath) {
+			return fmt.Errorf("target already exists: %s", newName)
+		}
+		source = filePath + ".rename.tmp"
+		if err := os.Rename(filePath, source); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(source, target); err != nil {
+		if source != filePath {
+			os.Rename(source, filePath)
+		}
+		return err
+	}
+	invalidateMetadataCache(filePath)
+
+	oldLRC := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".lrc"
+	newLRC := strings.TrimSuffix(target, filepath.Ext(target)) + ".lrc"
+	if fileExists(oldLRC) && !fileExists(newLRC) {
+		if err := os.Rename(oldLRC, newLRC); err != nil {
+			GoLog("[Filename] Warning: failed to move lyrics sidecar %s: %v\n", oldLRC, err)
+		}
+	}
+	return nil
+}
+
+func checkFilenameConsistency(rootPath, template, mode string, journal *batchJournal, changes *changeReport) (*FilenameConsistencyReport, error) {
+	if strings.TrimSpace(rootPath) == "" {
+		return nil, fmt.Errorf("folder path is empty")
+	}
+	info, err := os.Stat(rootPath)
+	if err != nil {
+		return nil, fmt.Errorf("folder not found: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+
+	files, warnings, err := collectLibraryAudioFilesChecked(rootPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &FilenameConsistencyReport{Root: rootPath, Template: template, Mode: mode, Warnings: warnings}
+	byDirectory := make(map[string][]FilenameMismatch)
+	names := make(map[string]folderNameIndex)
+	scanTime := time.Now().UTC().Format(time.RFC3339)
+	for _, file := range files {
+		ext := filepath.Ext(file.path)
+		if strings.EqualFold(ext, ".cue") {
+			continue
+		}
+		if journal.completed(file.path) {
+			report.Resumed++
+			continue
+		}
+		result, err := scanAudioFileWithKnownModTime(file.path, scanTime, file.modTime)
+		if err != nil || result == nil || result.MetadataFromFilename {
+			// Without tags the expected name would come from the name itself.
+			report.Skipped++
+			continue
+		}
+		report.Checked++
+
+		actual := filepath.Base(file.path)
+		expected := expectedFilenameFromTags(template, result, ext)
+		if filenameComparisonKey(actual) == filenameComparisonKey(expected) {
+			continue
+		}
+
+		report.Mismatched++
+		mismatch := FilenameMismatch{Path: file.path, Actual: actual, Expected: expected}
+		fileDir := filepath.Dir(file.path)
+		if names[fileDir] == nil {
+			names[fileDir] = newFolderNameIndex(fileDir)
+		}
+		if occupant := names[fileDir].claim(file.path, expected); occupant != "" {
+			mismatch.Action = "collision"
+			mismatch.Error = fmt.Sprintf("%s differs from %s only by case", expected, filepath.Base(occupant))
+			report.Warnings = append(report.Warnings, PathWarning{
+				Kind:    PathWarningCaseCollision,
+				Path:    file.path,
+				Target:  occupant,
+				Message: mismatch.Error,
+			})
+		}
+		switch {
+		case mismatch.Action == "collision":
+		case mode == filenameCheckDryRun:
+			mismatch.Action = "would_rename"
+		case mode == filenameCheckApply:
+			err := renameLibraryFile(file.path, expected)
+			journal.record(file.path, err)
+			if err != nil {
+				mismatch.Action = "failed"
+				mismatch.Error = err.Error()
+				report.Failed++
+			} else {
+				mismatch.Action = "renamed"
+				report.Renamed++
+			}
+		}
+		if mismatch.Action == "would_rename" || mismatch.Action == "renamed" || mismatch.Action == "failed" {
+			changes.add(FileChangeEntry{Path: file.path, Rename: &RenameChange{From: actual, To: expected}, Error: mismatch.Error})
+		}
+
+		dir, err := filepath.Rel(rootPath, filepath.Dir(file.path))
+		if err != nil {
+			dir = filepath.Dir(file.path)
+		}
+		byDirectory[dir] = append(byDirectory[dir], mismatch)
+	}
+
+	report.Directories = make([]FilenameConsistencyDirectory, 0, len(byDirectory))
+	for dir, mismatches := range byDirectory {
+		sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].Actual < mismatches[j].Actual })
+		report.Directories = append(report.Directories, FilenameConsistencyDirectory{Directory: dir, Mismatches: mismatches})
+	}
+	sort.Slice(report.Directories, func(i, j int) bool {
+		return report.Directories[i].Directory < report.Directories[j].Directory
+	})
+	return report, nil
+}
+
+func marshalFilenameConsistency(rootPath, template, mode, journalPath string, changes *changeReport, reportPath string) (string, error) {
+	journal, err := openBatchJournal(journalPath, batchOpFixFilenames, fixFilenamesJournalArgs{Root: rootPath, Template: template})
+	if err != nil {
+		return "", err
+	}
+	defer journal.close()
+
+	report, err := checkFilenameConsistency(rootPath, template, mode, journal, changes)
+	if err != nil {
+		return "", err
+	}
+	journal.finish()
+	report.ChangeReportOutput = changes.finish(reportPath)
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// CheckFilenameConsistency renders template (as used for downloads) from the
+// tags of every audio file under rootPath and reports the files whose names
+// differ, grouped by directory. Differences that only come from sanitizing
+// (punctuation, spacing, replaced characters) are ignored, and files without
+// tags are counted as skipped. Nothing is renamed.
+func CheckFilenameConsistency(rootPath string, template string) (string, error) {
+	return marshalFilenameConsistency(rootPath, template, filenameCheckReport, "", nil, "")
+}
+
+// FixFilenameConsistency is CheckFilenameConsistency that also renames the
+// mismatched files when apply is true, or marks what it would rename when
+// apply is false. A rename never replaces an existing file; failures are
+// reported per file and do not stop the run.
+func FixFilenameConsistency(rootPath string, template string, apply bool) (string, error) {
+	mode := filenameCheckDryRun
+	if apply {
+		if err := checkWriteAllowed(rootPath); err != nil {
+			return "", err
+		}
+		mode = filenameCheckApply
+	}
+	return marshalFilenameConsistency(rootPath, template, mode, "", nil, "")
+}
+
+// FixFilenameConsistencyWithReport is FixFilenameConsistency that also
+// reports every rename, done or planned, and every failed one (see
+// ChangeReportOutput), written as JSON to reportPath and as text next to it
+// when reportPath is not empty.
+func FixFilenameConsistencyWithReport(rootPath string, template string, apply bool, reportPath string) (string, error) {
+	mode := filenameCheckDryRun
+	if apply {
+		if err := checkWriteAllowed(rootPath); err != nil {
+			return "", err
+		}
+		mode = filenameCheckApply
+	}
+	changes := newChangeReport(batchOpFixFilenames, rootPath, !apply)
+	return marshalFilenameConsistency(rootPath, template, mode, "", changes, reportPath)
+}
+
+// FixFilenameConsistencyWithJournal applies FixFilenameConsistency and
+// records each renamed file in the journal at journalPath, so an
+// interrupted run continues with Resume(journalPath).
+func FixFilenameConsistencyWithJournal(rootPath, template, journalPath string) (string, error) {
+	if err := checkWriteAllowed(rootPath); err != nil {
+		return "", err
+	}
+	return marshalFilenameConsistency(rootPath, template, filenameCheckApply, journalPath, nil, "")
+}
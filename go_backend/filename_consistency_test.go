@@ -0,0 +1,84 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConsistencyFixture(t *testing.T, root, relPath string, metadata Metadata) string {
+	t.Helper()
+	src := writeTestFLACWithMetadata(t, metadata)
+	dst := filepath.Join(root, relPath)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		t.Fatalf("move fixture: %v", err)
+	}
+	return dst
+}
+
+func TestCheckFilenameConsistencyReportsMismatches(t *testing.T) {
+	root := t.TempDir()
+	writeConsistencyFixture(t, root, "Album/Artist - Song.flac", Metadata{Artist: "Artist", Title: "Song"})
+	// Only sanitization differs: "/" became "_" instead of a space.
+	writeConsistencyFixture(t, root, "Album/AC_DC - Thunder.flac", Metadata{Artist: "AC/DC", Title: "Thunder"})
+	manual := writeConsistencyFixture(t, root, "Album/my copy.flac", Metadata{Artist: "Artist", Title: "Other"})
+	writeConsistencyFixture(t, root, "Live/Wrong.flac", Metadata{Artist: "Band", Title: "Live Song"})
+
+	raw, err := CheckFilenameConsistency(root, "{artist} - {title}")
+	report := mustDecodeJSON[FilenameConsistencyReport](t, raw, err)
+	if report.Checked != 4 || report.Mismatched != 2 || report.Renamed != 0 {
+		t.Fatalf("unexpected counts: %+v", report)
+	}
+	if len(report.Directories) != 2 || report.Directories[0].Directory != "Album" || report.Directories[1].Directory != "Live" {
+		t.Fatalf("expected mismatches grouped by directory: %+v", report.Directories)
+	}
+	got := report.Directories[0].Mismatches[0]
+	if got.Path != manual || got.Actual != "my copy.flac" || got.Expected != "Artist - Other.flac" || got.Action != "" {
+		t.Fatalf("unexpected mismatch: %+v", got)
+	}
+	if !fileExists(manual) {
+		t.Fatal("a report must not rename anything")
+	}
+}
+
+func TestFixFilenameConsistencyDryRunAndApply(t *testing.T) {
+	root := t.TempDir()
+	path := writeConsistencyFixture(t, root, "old name.flac", Metadata{Artist: "Artist", Title: "Song"})
+	if err := os.WriteFile(filepath.Join(root, "old name.lrc"), []byte("[00:01.00]line"), 0644); err != nil {
+		t.Fatalf("write lrc: %v", err)
+	}
+	blocked := writeConsistencyFixture(t, root, "blocked.flac", Metadata{Artist: "Artist", Title: "Taken"})
+	if err := os.WriteFile(filepath.Join(root, "Artist - Taken.flac"), []byte("not audio"), 0644); err != nil {
+		t.Fatalf("write blocker: %v", err)
+	}
+
+	raw, err := FixFilenameConsistency(root, "{artist} - {title}", false)
+	report := mustDecodeJSON[FilenameConsistencyReport](t, raw, err)
+	if report.Mode != "dry_run" || report.Mismatched != 2 || report.Directories[0].Mismatches[0].Action != "would_rename" {
+		t.Fatalf("unexpected dry run: %+v", report)
+	}
+	if !fileExists(path) {
+		t.Fatal("dry run must not rename")
+	}
+
+	raw, err = FixFilenameConsistency(root, "{artist} - {title}", true)
+	report = mustDecodeJSON[FilenameConsistencyReport](t, raw, err)
+	if report.Renamed != 1 || report.Failed != 1 {
+		t.Fatalf("unexpected apply counts: %+v", report)
+	}
+	if fileExists(path) || !fileExists(filepath.Join(root, "Artist - Song.flac")) || !fileExists(filepath.Join(root, "Artist - Song.lrc")) {
+		t.Fatal("expected file and lyrics sidecar to be renamed")
+	}
+	if !fileExists(blocked) {
+		t.Fatal("a rename must never replace an existing file")
+	}
+}
+
+func TestCheckFilenameConsistencyRejectsMissingRoot(t *testing.T) {
+	if _, err := CheckFilenameConsistency(filepath.Join(t.TempDir(), "missing"), "{title}"); err == nil {
+		t.Fatal("expected missing folder error")
+	}
+}
@@ -0,0 +1,198 @@
+package gobackend
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Filename profiles for SanitizeFilename. An empty profile keeps the legacy
+// desktop-compatible behavior of sanitizeFilename.
+const (
+	// FilenameProfileAndroid only rejects what ext4/f2fs cannot store: "/",
+	// NUL and control characters. Names are limited to 255 bytes.
+	FilenameProfileAndroid = "android"
+	// FilenameProfileFAT32 is for SD cards formatted as FAT32 or exFAT. It
+	// also rejects "<>:\"\\|?*", reserved device names and trailing dots or
+	// spaces, and limits names to 255 UTF-16 code units.
+	FilenameProfileFAT32 = "fat32"
+	// FilenameProfileWindows is FAT32 plus the superscript device names
+	// (COM¹, LPT²) Windows also reserves, for libraries synced to a PC.
+	FilenameProfileWindows = "windows"
+)
+
+const (
+	defaultFilenameReplacement = "_"
+	maxFilenameComponentLength = 255
+	// maxFilenameExtensionLength bounds what counts as an extension when
+	// truncating, so "Vol. 2 - Something Long" is not split at the dot.
+	maxFilenameExtensionLength = 10
+)
+
+// FilenameSanitizeOptions configures SanitizeFilenameJSON.
+type FilenameSanitizeOptions struct {
+	Profile string `json:"profile"`
+	// Replacement substitutes every rejected character. Empty means "_";
+	// it must itself be valid for the profile.
+	Replacement string `json:"replacement"`
+	// MaxLength overrides the profile's limit (bytes for android, UTF-16
+	// units otherwise) when it is smaller.
+	MaxLength int `json:"max_length"`
+	// Transliterate reduces the name to ASCII first (ü → u, 東京 → Tokyo)
+	// for car stereos and players without Unicode fonts. Characters with no
+	// ASCII form are dropped; a name left empty becomes "Unknown".
+	Transliterate bool `json:"transliterate"`
+}
+
+var reservedDeviceNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+var windowsReservedDeviceNames = map[string]bool{
+	"COM¹": true, "COM²": true, "COM³": true,
+	"LPT¹": true, "LPT²": true, "LPT³": true,
+}
+
+func validFilenameProfile(profile string) bool {
+	switch profile {
+	case FilenameProfileAndroid, FilenameProfileFAT32, FilenameProfileWindows:
+		return true
+	}
+	return false
+}
+
+func isFilenameRuneAllowed(r rune, profile string) bool {
+	if r == '/' || r == 0 || r == unicode.ReplacementChar || unicode.IsControl(r) {
+		return false
+	}
+	if profile == FilenameProfileAndroid {
+		return true
+	}
+	return !strings.ContainsRune(`<>:"\|?*`, r)
+}
+
+// filenameLength measures name the way the profile's filesystem limits it.
+func filenameLength(name, profile string) int {
+	if profile == FilenameProfileAndroid {
+		return len(name)
+	}
+	return len(utf16.Encode([]rune(name)))
+}
+
+// truncateFilenameLength cuts name to at most maxLength without splitting a
+// grapheme cluster, so no accent or emoji sequence is left half-encoded.
+func truncateFilenameLength(name, profile string, maxLength int) string {
+	return truncateGraphemes(name, maxLength, func(prefix string) int {
+		return filenameLength(prefix, profile)
+	})
+}
+
+// splitFilenameExtension returns name's extension including the dot, or ""
+// when the suffix after the last dot does not look like one.
+func splitFilenameExtension(name string) (string, string) {
+	dot := strings.LastIndexByte(name, '.')
+	if dot <= 0 || len(name)-dot-1 == 0 || len(name)-dot-1 > maxFilenameExtensionLength {
+		return name, ""
+	}
+	for _, r := range name[dot+1:] {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return name, ""
+		}
+	}
+	return name[:dot], name[dot:]
+}
+
+func isReservedDeviceName(base, profile string) bool {
+	upper := strings.ToUpper(strings.TrimRight(base, ". "))
+	return reservedDeviceNames[upper] || (profile == FilenameProfileWindows && windowsReservedDeviceNames[upper])
+}
+
+func sanitizeFilenameForProfile(name string, opts FilenameSanitizeOptions) string {
+	profile := opts.Profile
+	replacement := opts.Replacement
+	if replacement == "" {
+		replacement = defaultFilenameReplacement
+	}
+	maxLength := maxFilenameComponentLength
+	if opts.MaxLength > 0 && opts.MaxLength < maxLength {
+		maxLength = opts.MaxLength
+	}
+
+	name = strings.ToValidUTF8(name, replacement)
+	if opts.Transliterate {
+		name = transliterateASCII(name)
+	}
+	var builder strings.Builder
+	for _, r := range name {
+		if isFilenameRuneAllowed(r, profile) {
+			builder.WriteRune(r)
+		} else if r != 0 && !unicode.IsControl(r) {
+			builder.WriteString(replacement)
+		}
+	}
+	sanitized := strings.TrimSpace(builder.String())
+
+	trimTrailing := func(s string) string {
+		if profile == FilenameProfileAndroid {
+			return strings.TrimSpace(s)
+		}
+		return strings.TrimRight(s, ". ")
+	}
+	sanitized = trimTrailing(sanitized)
+	if sanitized == "" || sanitized == "." || sanitized == ".." {
+		return "Unknown"
+	}
+
+	base, ext := splitFilenameExtension(sanitized)
+	if profile != FilenameProfileAndroid && isReservedDeviceName(base, profile) {
+		base += replacement
+	}
+	if filenameLength(base+ext, profile) > maxLength {
+		if budget := maxLength - filenameLength(ext, profile); budget > 0 {
+			base = trimTrailing(truncateFilenameLength(base, profile, budget))
+		} else {
+			base, ext = trimTrailing(truncateFilenameLength(base+ext, profile, maxLength)), ""
+		}
+	}
+	if base == "" {
+		base = "Unknown"
+	}
+	return base + ext
+}
+
+// SanitizeFilename makes a single path component safe to create. profile is
+// FilenameProfileAndroid, FilenameProfileFAT32 or FilenameProfileWindows;
+// empty keeps the legacy behavior used by filename templates, and an unknown
+// profile is treated as Windows, the most restrictive one. Rejected
+// characters, "/" included, become "_" so titles such as "AC/DC: Live?" never
+// create nested directories. Over-long names are cut between grapheme
+// clusters with the extension kept.
+func SanitizeFilename(name string, profile string) string {
+	if profile == "" {
+		return sanitizeFilename(name)
+	}
+	if !validFilenameProfile(profile) {
+		profile = FilenameProfileWindows
+	}
+	return sanitizeFilenameForProfile(name, FilenameSanitizeOptions{Profile: profile})
+}
+
+func validateFilenameSanitizeOptions(opts FilenameSanitizeOptions) error {
+	if !validFilenameProfile(opts.Profile) {
+		return fmt.Errorf("unknown filename profile: %q", opts.Profile)
+	}
+	for _, r := range opts.Replacement {
+		if !isFilenameRuneAllowed(r, opts.Profile) {
+			return fmt.Errorf("replacement %q is not valid for profile %s", opts.Replacement, opts.Profile)
+		}
+	}
+	if opts.MaxLength < 0 {
+		return fmt.Errorf("max_length must not be negative")
+	}
+	return nil
+}
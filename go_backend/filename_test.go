@@ -112,3 +112,133 @@ func TestSanitizeFilenameTruncatesWithoutSplittingUTF8(t *testing.T) {
 		t.Fatalf("sanitizeFilename length = %d, want <= 200", len(got))
 	}
 }
+
+func TestSanitizeFilenameProfiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		want    string
+	}{
+		{"AC/DC: Live?.flac", FilenameProfileAndroid, "AC_DC: Live?.flac"},
+		{"AC/DC: Live?.flac", FilenameProfileFAT32, "AC_DC_ Live_.flac"},
+		{`a<b>c"d\e|f*g.flac`, FilenameProfileWindows, "a_b_c_d_e_f_g.flac"},
+		{"Tab\there", FilenameProfileFAT32, "Tabhere"},
+		{"Song... ", FilenameProfileFAT32, "Song"},
+		{"Song... ", FilenameProfileAndroid, "Song..."},
+		{"CON", FilenameProfileFAT32, "CON_"},
+		{"nul.flac", FilenameProfileWindows, "nul_.flac"},
+		{"COM¹.flac", FilenameProfileWindows, "COM¹_.flac"},
+		{"COM¹.flac", FilenameProfileFAT32, "COM¹.flac"},
+		{"CON", FilenameProfileAndroid, "CON"},
+		{"Console", FilenameProfileWindows, "Console"},
+		{"..", FilenameProfileAndroid, "Unknown"},
+		{"?*", FilenameProfileWindows, "__"},
+		{"A/B", "bogus", "A_B"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeFilename(tt.name, tt.profile); got != tt.want {
+			t.Fatalf("SanitizeFilename(%q, %q) = %q, want %q", tt.name, tt.profile, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeFilenameProfileTruncationKeepsExtension(t *testing.T) {
+	long := strings.Repeat("あ", 200) + ".flac"
+
+	android := SanitizeFilename(long, FilenameProfileAndroid)
+	if !utf8.ValidString(android) || len(android) > 255 || !strings.HasSuffix(android, ".flac") {
+		t.Fatalf("android truncation = %d bytes %q", len(android), android)
+	}
+
+	// Each あ is one UTF-16 unit, so FAT32 keeps 250 of them plus ".flac".
+	fat := SanitizeFilename(strings.Repeat("あ", 300)+".flac", FilenameProfileFAT32)
+	if fat != strings.Repeat("あ", 250)+".flac" {
+		t.Fatalf("fat32 truncation kept %d runes", utf8.RuneCountInString(fat))
+	}
+
+	// A dotted phrase is not mistaken for an extension.
+	got := SanitizeFilename("Vol. 2 - "+strings.Repeat("x", 300), FilenameProfileFAT32)
+	if len(got) != 255 || !strings.HasPrefix(got, "Vol. 2 - ") {
+		t.Fatalf("dotted name truncation = %q", got)
+	}
+}
+
+func TestSanitizeFilenameJSONOptions(t *testing.T) {
+	got, err := SanitizeFilenameJSON("A/B: C.flac", `{"profile":"windows","replacement":"-","max_length":8}`)
+	if err != nil || got != "A-B.flac" {
+		t.Fatalf("SanitizeFilenameJSON = %q, %v", got, err)
+	}
+	for _, options := range []string{`{"profile":"ntfs"}`, `{"profile":"fat32","replacement":":"}`, `{"profile":"android","max_length":-1}`, `nope`} {
+		if _, err := SanitizeFilenameJSON("x", options); err == nil {
+			t.Fatalf("expected %s to be rejected", options)
+		}
+	}
+}
+
+func TestTruncateKeepsGraphemeClustersWhole(t *testing.T) {
+	tests := []struct {
+		value    string
+		maxBytes int
+		want     string
+	}{
+		// "e" + U+0301 must not lose its accent.
+		{"café", 5, "caf"},
+		{"café", 6, "café"},
+		// Family emoji joined by ZWJ is one cluster of 18 bytes.
+		{"a👨‍👩‍👧", 10, "a"},
+		// Flags are pairs of regional indicators.
+		{"🇯🇵🇫🇷", 12, "🇯🇵"},
+		{"👍🏽x", 5, ""},
+		{"한가", 4, "한"},
+	}
+	for _, tt := range tests {
+		got := truncateUTF8Bytes(tt.value, tt.maxBytes)
+		if got != tt.want {
+			t.Fatalf("truncateUTF8Bytes(%q, %d) = %q, want %q", tt.value, tt.maxBytes, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeFilenameTruncatesCombiningCharactersByBytes(t *testing.T) {
+	// 100 decomposed "é" are 300 bytes; the android limit is 255 bytes.
+	name := strings.Repeat("é", 100) + ".flac"
+	got := SanitizeFilename(name, FilenameProfileAndroid)
+	if len(got) > 255 || !strings.HasSuffix(got, ".flac") {
+		t.Fatalf("got %d bytes: %q", len(got), got)
+	}
+	base := strings.TrimSuffix(got, ".flac")
+	if strings.Count(base, "e") != strings.Count(base, "́") {
+		t.Fatalf("truncation split a combining sequence: %q", base)
+	}
+}
+
+func TestTransliterateASCII(t *testing.T) {
+	tests := map[string]string{
+		"Motörhead – Über Alles": "Motorhead - Uber Alles",
+		"東京フラッシュ":                "Tokyo furasshu",
+		"Café del Mar":           "Cafe del Mar",
+		"Straße ❤":               "Strasse",
+		"Ø é":                   "O e",
+		"漢字":                     "",
+	}
+	for in, want := range tests {
+		if got := transliterateASCII(in); got != want {
+			t.Fatalf("transliterateASCII(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestBuildFilenameJSONOptions(t *testing.T) {
+	metadata := `{"artist":"Motörhead","title":"東京: Live?"}`
+	got, err := BuildFilenameJSON("{artist} - {title}", metadata, `{"profile":"fat32","transliterate":true}`)
+	if err != nil || got != "Motorhead - Tokyo_ Live_" {
+		t.Fatalf("BuildFilenameJSON = %q, %v", got, err)
+	}
+	got, err = BuildFilenameJSON("{title}", `{"title":"漢字"}`, `{"transliterate":true}`)
+	if err != nil || got != "Unknown" {
+		t.Fatalf("untransliterable title = %q, %v", got, err)
+	}
+	if _, err := BuildFilenameJSON("{title}", metadata, `{"profile":"hfs"}`); err == nil {
+		t.Fatal("expected unknown profile error")
+	}
+}
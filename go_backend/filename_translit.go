@@ -0,0 +1,163 @@
+package gobackend
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// isGraphemeExtender reports whether r attaches to the rune before it:
+// combining marks, variation selectors, emoji skin tones, tag characters
+// and Hangul medial/final jamo.
+func isGraphemeExtender(r rune) bool {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r == 0x200C || r == 0x200D:
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r >= 0xE0100 && r <= 0xE01EF:
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF:
+		return true
+	case r >= 0xE0020 && r <= 0xE007F:
+		return true
+	case r >= 0x1160 && r <= 0x11FF:
+		return true
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// nextGraphemeBoundary returns the byte offset where the user-perceived
+// character starting at s[start:] ends. It covers combining sequences,
+// ZWJ emoji sequences, flags and skin tones, which is what a filename needs
+// to avoid leaving a dangling accent or half an emoji after truncation.
+func nextGraphemeBoundary(s string, start int) int {
+	first, size := utf8.DecodeRuneInString(s[start:])
+	i := start + size
+	if isRegionalIndicator(first) {
+		if next, nextSize := utf8.DecodeRuneInString(s[i:]); isRegionalIndicator(next) {
+			i += nextSize
+		}
+	}
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !isGraphemeExtender(r) {
+			break
+		}
+		i += size
+		if r == 0x200D && i < len(s) {
+			// A zero width joiner glues the following pictograph on.
+			_, joined := utf8.DecodeRuneInString(s[i:])
+			i += joined
+		}
+	}
+	return i
+}
+
+// truncateGraphemes keeps the longest prefix of value made of whole
+// grapheme clusters whose measure does not exceed limit.
+func truncateGraphemes(value string, limit int, measure func(string) int) string {
+	if measure(value) <= limit {
+		return value
+	}
+	end := 0
+	for end < len(value) {
+		next := nextGraphemeBoundary(value, end)
+		if measure(value[:next]) > limit {
+			break
+		}
+		end = next
+	}
+	return value[:end]
+}
+
+// romanizedWords is a small table of common Japanese and Chinese words,
+// mostly place names, that appear in titles. Anything else in kanji or
+// hanzi has no reliable reading and is dropped by transliterateASCII.
+var romanizedWords = map[string]string{
+	"東京": "Tokyo", "大阪": "Osaka", "京都": "Kyoto", "日本": "Nihon",
+	"横浜": "Yokohama", "名古屋": "Nagoya", "札幌": "Sapporo", "福岡": "Fukuoka",
+	"沖縄": "Okinawa", "広島": "Hiroshima", "北京": "Beijing", "上海": "Shanghai",
+	"香港": "Hong Kong", "台北": "Taipei", "中国": "China", "韓国": "Korea",
+	"桜": "Sakura", "愛": "Ai", "夢": "Yume", "空": "Sora", "花": "Hana",
+	"月": "Tsuki", "星": "Hoshi", "雨": "Ame", "夏": "Natsu", "冬": "Fuyu",
+}
+
+var romanizedWordMaxRunes = func() int {
+	longest := 0
+	for word := range romanizedWords {
+		longest = max(longest, utf8.RuneCountInString(word))
+	}
+	return longest
+}()
+
+// asciiLetterFolds covers letters that do not decompose to an ASCII base.
+var asciiLetterFolds = map[rune]string{
+	'ß': "ss", 'ẞ': "SS", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE",
+	'ø': "o", 'Ø': "O", 'đ': "d", 'Đ': "D", 'ł': "l", 'Ł': "L",
+	'þ': "th", 'Þ': "Th", 'ð': "d", 'Ð': "D", 'ı': "i", 'ħ': "h",
+	'‘': "'", '’': "'", '“': "\"", '”': "\"", '–': "-", '—': "-",
+	'…': "...", '×': "x", '・': " ", '　': " ", '〜': "~",
+}
+
+func isLetterOrDigit(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func replaceRomanizedWords(text string) string {
+	runes := []rune(text)
+	var b strings.Builder
+	for i := 0; i < len(runes); {
+		matched := false
+		for n := min(romanizedWordMaxRunes, len(runes)-i); n > 0; n-- {
+			if romaji, ok := romanizedWords[string(runes[i:i+n])]; ok {
+				// Keep romanized words apart from neighboring letters.
+				if i > 0 && isLetterOrDigit(runes[i-1]) {
+					b.WriteByte(' ')
+				}
+				b.WriteString(romaji)
+				if i+n < len(runes) && isLetterOrDigit(runes[i+n]) {
+					b.WriteByte(' ')
+				}
+				i += n
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteRune(runes[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// transliterateASCII reduces text to printable ASCII for players that cannot
+// show Unicode: known words are romanized, kana goes through
+// JapaneseToRomaji, accents are stripped (ü → u) and whatever is left
+// without an ASCII form is dropped.
+func transliterateASCII(text string) string {
+	text = JapaneseToRomaji(replaceRomanizedWords(text))
+
+	var b strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		switch {
+		case r < utf8.RuneSelf:
+			if r >= 0x20 && r != 0x7F {
+				b.WriteRune(r)
+			}
+		case unicode.Is(unicode.Mn, r):
+		case asciiLetterFolds[r] != "":
+			b.WriteString(asciiLetterFolds[r])
+		case unicode.IsSpace(r):
+			b.WriteByte(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,405 @@
+package gobackend
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+)
+
+// flacStreamFormat is the part of STREAMINFO a decoder needs.
+type flacStreamFormat struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	TotalSamples  int64
+}
+
+// flacBitReader reads big-endian bit fields. It pulls whole bytes from r only
+// as they are needed, so once it is byte-aligned with no bits left the
+// underlying reader is positioned exactly after the last consumed byte.
+type flacBitReader struct {
+	r *bufio.Reader
+	// cache holds n unread bits, left-aligned; the bits below them are zero.
+	cache uint64
+	n     uint
+}
+
+func (b *flacBitReader) fill(need uint) error {
+	for b.n < need {
+		c, err := b.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		b.cache |= uint64(c) << (56 - b.n)
+		b.n += 8
+	}
+	return nil
+}
+
+// read returns the next n bits, n <= 32.
+func (b *flacBitReader) read(n uint) (uint64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	if err := b.fill(n); err != nil {
+		return 0, err
+	}
+	v := b.cache >> (64 - n)
+	b.cache <<= n
+	b.n -= n
+	return v, nil
+}
+
+// readSigned returns the next n bits as a two's complement number.
+func (b *flacBitReader) readSigned(n uint) (int64, error) {
+	v, err := b.read(n)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	return int64(v<<(64-n)) >> (64 - n), nil
+}
+
+// readUnary counts zero bits up to and including the next one bit.
+func (b *flacBitReader) readUnary() (uint64, error) {
+	var count uint64
+	for {
+		if b.n == 0 {
+			if err := b.fill(8); err != nil {
+				return 0, err
+			}
+		}
+		if b.cache == 0 {
+			count += uint64(b.n)
+			b.n = 0
+			continue
+		}
+		zeros := uint(bits.LeadingZeros64(b.cache))
+		b.cache <<= zeros + 1
+		b.n -= zeros + 1
+		return count + uint64(zeros), nil
+	}
+}
+
+// align drops the bits left of the current byte.
+func (b *flacBitReader) align() {
+	drop := b.n % 8
+	b.cache <<= drop
+	b.n -= drop
+}
+
+var errFLACDecode = errors.New("invalid FLAC frame")
+
+// flacDecoder decodes the audio frames of a FLAC stream to PCM, one frame at
+// a time. It implements the whole subframe format (constant, verbatim,
+// fixed and LPC prediction, stereo decorrelation) but does not check CRCs or
+// the audio MD5; VerifyFLAC does that.
+type flacDecoder struct {
+	format  flacStreamFormat
+	br      flacBitReader
+	samples [][]int32
+}
+
+// openFLACDecoder opens filePath for decoding. The caller closes the file.
+func openFLACDecoder(filePath string) (*flacDecoder, *os.File, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	layout, err := scanFLACMetadataBlocks(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if layout.StreamInfo == nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("missing STREAMINFO")
+	}
+	if _, err := f.Seek(layout.AudioOffset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	bitsPerSample, sampleRate, totalSamples := parseFLACStreamInfoQuality(layout.StreamInfo)
+	format := flacStreamFormat{
+		SampleRate:    sampleRate,
+		Channels:      parseFLACStreamInfoChannels(layout.StreamInfo),
+		BitsPerSample: bitsPerSample,
+		TotalSamples:  totalSamples,
+	}
+	return &flacDecoder{format: format, br: flacBitReader{r: bufio.NewReaderSize(f, 256*1024)}}, f, nil
+}
+
+// next decodes the next frame and returns its samples per channel. The
+// slices are reused by the following call. It returns io.EOF after the last
+// frame.
+func (d *flacDecoder) next() ([][]int32, error) {
+	peek, _ := d.br.r.Peek(flacMaxFrameHeaderLen)
+	if len(peek) == 0 {
+		return nil, io.EOF
+	}
+	hdr, ok := parseFLACFrameHeader(peek)
+	if !ok {
+		return nil, fmt.Errorf("%w: bad frame header", errFLACDecode)
+	}
+	d.br.r.Discard(hdr.Length)
+	d.br.cache, d.br.n = 0, 0
+
+	bps := hdr.BitsPerSample
+	if bps == 0 {
+		bps = d.format.BitsPerSample
+	}
+	channelCode := peek[3] >> 4
+	if len(d.samples) != hdr.Channels {
+		d.samples = make([][]int32, hdr.Channels)
+	}
+	for ch := range d.samples {
+		if cap(d.samples[ch]) < hdr.BlockSize {
+			d.samples[ch] = make([]int32, hdr.BlockSize)
+		}
+		d.samples[ch] = d.samples[ch][:hdr.BlockSize]
+
+		// The side channel carries one extra bit.
+		chBPS := bps
+		if (channelCode == 8 && ch == 1) || (channelCode == 9 && ch == 0) || (channelCode == 10 && ch == 1) {
+			chBPS++
+		}
+		if err := d.decodeSubframe(d.samples[ch], uint(chBPS)); err != nil {
+			return nil, err
+		}
+	}
+
+	left, right := d.samples[0], d.samples[len(d.samples)-1]
+	switch channelCode {
+	case 8: // left/side
+		for i := range left {
+			right[i] = left[i] - right[i]
+		}
+	case 9: // side/right
+		for i := range left {
+			left[i] += right[i]
+		}
+	case 10: // mid/side
+		for i := range left {
+			side := right[i]
+			mid := left[i]<<1 | side&1
+			left[i], right[i] = (mid+side)>>1, (mid-side)>>1
+		}
+	}
+
+	d.br.align()
+	if _, err := d.br.read(16); err != nil { // CRC-16
+		return nil, err
+	}
+	return d.samples, nil
+}
+
+func (d *flacDecoder) decodeSubframe(out []int32, bps uint) error {
+	br := &d.br
+	header, err := br.read(8)
+	if err != nil {
+		return err
+	}
+	if header&0x80 != 0 {
+		return fmt.Errorf("%w: subframe padding bit set", errFLACDecode)
+	}
+	kind := header >> 1 & 0x3F
+	var wasted uint
+	if header&0x01 != 0 {
+		k, err := br.readUnary()
+		if err != nil {
+			return err
+		}
+		wasted = uint(k) + 1
+		if wasted >= bps {
+			return fmt.Errorf("%w: %d wasted bits of %d", errFLACDecode, wasted, bps)
+		}
+		bps -= wasted
+	}
+
+	switch {
+	case kind == 0:
+		v, err := br.readSigned(bps)
+		if err != nil {
+			return err
+		}
+		for i := range out {
+			out[i] = int32(v)
+		}
+	case kind == 1:
+		for i := range out {
+			v, err := br.readSigned(bps)
+			if err != nil {
+				return err
+			}
+			out[i] = int32(v)
+		}
+	case kind >= 8 && kind <= 12:
+		if err := d.decodeFixed(out, bps, int(kind-8)); err != nil {
+			return err
+		}
+	case kind >= 32:
+		if err := d.decodeLPC(out, bps, int(kind-31)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: reserved subframe type %d", errFLACDecode, kind)
+	}
+
+	if wasted > 0 {
+		for i := range out {
+			out[i] <<= wasted
+		}
+	}
+	return nil
+}
+
+func (d *flacDecoder) readWarmup(out []int32, bps uint, order int) error {
+	if order > len(out) {
+		return fmt.Errorf("%w: predictor order %d exceeds block size %d", errFLACDecode, order, len(out))
+	}
+	for i := range order {
+		v, err := d.br.readSigned(bps)
+		if err != nil {
+			return err
+		}
+		out[i] = int32(v)
+	}
+	return nil
+}
+
+func (d *flacDecoder) decodeFixed(out []int32, bps uint, order int) error {
+	if err := d.readWarmup(out, bps, order); err != nil {
+		return err
+	}
+	if err := d.decodeResidual(out, order); err != nil {
+		return err
+	}
+	switch order {
+	case 1:
+		for i := 1; i < len(out); i++ {
+			out[i] += out[i-1]
+		}
+	case 2:
+		for i := 2; i < len(out); i++ {
+			out[i] += 2*out[i-1] - out[i-2]
+		}
+	case 3:
+		for i := 3; i < len(out); i++ {
+			out[i] += 3*out[i-1] - 3*out[i-2] + out[i-3]
+		}
+	case 4:
+		for i := 4; i < len(out); i++ {
+			out[i] += 4*out[i-1] - 6*out[i-2] + 4*out[i-3] - out[i-4]
+		}
+	}
+	return nil
+}
+
+func (d *flacDecoder) decodeLPC(out []int32, bps uint, order int) error {
+	if err := d.readWarmup(out, bps, order); err != nil {
+		return err
+	}
+	precision, err := d.br.read(4)
+	if err != nil {
+		return err
+	}
+	if precision == 15 {
+		return fmt.Errorf("%w: invalid LPC precision", errFLACDecode)
+	}
+	shift, err := d.br.readSigned(5)
+	if err != nil {
+		return err
+	}
+	if shift < 0 {
+		return fmt.Errorf("%w: negative LPC shift", errFLACDecode)
+	}
+	coeffs := make([]int64, order)
+	for i := range coeffs {
+		if coeffs[i], err = d.br.readSigned(uint(precision) + 1); err != nil {
+			return err
+		}
+	}
+	if err := d.decodeResidual(out, order); err != nil {
+		return err
+	}
+	for i := order; i < len(out); i++ {
+		var sum int64
+		for j, c := range coeffs {
+			sum += c * int64(out[i-1-j])
+		}
+		out[i] += int32(sum >> shift)
+	}
+	return nil
+}
+
+// decodeResidual reads the Rice-coded residual of out after the first
+// order warm-up samples.
+func (d *flacDecoder) decodeResidual(out []int32, order int) error {
+	br := &d.br
+	method, err := br.read(2)
+	if err != nil {
+		return err
+	}
+	if method > 1 {
+		return fmt.Errorf("%w: reserved residual coding method", errFLACDecode)
+	}
+	paramBits, escape := uint(4), uint64(15)
+	if method == 1 {
+		paramBits, escape = 5, 31
+	}
+	partitionOrder, err := br.read(4)
+	if err != nil {
+		return err
+	}
+	partitions := 1 << partitionOrder
+	if len(out)%partitions != 0 || len(out)>>partitionOrder < order {
+		return fmt.Errorf("%w: bad residual partition order", errFLACDecode)
+	}
+
+	i := order
+	for p := range partitions {
+		end := (p + 1) * (len(out) >> partitionOrder)
+		param, err := br.read(paramBits)
+		if err != nil {
+			return err
+		}
+		if param == escape {
+			n, err := br.read(5)
+			if err != nil {
+				return err
+			}
+			for ; i < end; i++ {
+				v, err := br.readSigned(uint(n))
+				if err != nil {
+					return err
+				}
+				out[i] = int32(v)
+			}
+			continue
+		}
+		k := uint(param)
+		for ; i < end; i++ {
+			q, err := br.readUnary()
+			if err != nil {
+				return err
+			}
+			r, err := br.read(k)
+			if err != nil {
+				return err
+			}
+			v := q<<k | r
+			out[i] = int32(v>>1) ^ -int32(v&1)
+		}
+	}
+	return nil
+}
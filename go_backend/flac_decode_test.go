@@ -0,0 +1,206 @@
+package gobackend
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// flacTestBitWriter writes the big-endian bit fields of hand-built frames.
+type flacTestBitWriter struct {
+	buf []byte
+	n   uint // bits used in the last byte
+}
+
+func (w *flacTestBitWriter) write(v uint64, bits uint) {
+	for i := int(bits) - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		w.buf[len(w.buf)-1] |= byte(v>>uint(i)&1) << (7 - w.n%8)
+		w.n++
+	}
+}
+
+func (w *flacTestBitWriter) writeSigned(v int64, bits uint) {
+	w.write(uint64(v)&(1<<bits-1), bits)
+}
+
+func (w *flacTestBitWriter) writeUnary(q uint64) {
+	for range q {
+		w.write(0, 1)
+	}
+	w.write(1, 1)
+}
+
+func (w *flacTestBitWriter) writeRice(v int64, k uint) {
+	u := uint64(v<<1) ^ uint64(v>>63)
+	w.writeUnary(u >> k)
+	w.write(u&(1<<k-1), k)
+}
+
+// testFLACFrame wraps the subframe bits written by body in a frame header
+// with a 16-bit block size and a CRC-16 footer.
+func testFLACFrame(number, blockSize int, channelCode byte, body func(w *flacTestBitWriter)) []byte {
+	frame := []byte{0xFF, 0xF8, 0x70, channelCode<<4 | 4<<1} // 16 bits per sample
+	frame = appendFLACUTF8Number(frame, uint64(number))
+	frame = append(frame, byte((blockSize-1)>>8), byte(blockSize-1))
+	frame = append(frame, flacCRC8(frame))
+	w := &flacTestBitWriter{buf: frame, n: uint(len(frame)) * 8}
+	body(w)
+	crc := flacCRC16(w.buf)
+	return append(w.buf, byte(crc>>8), byte(crc))
+}
+
+func writeTestFLACFrames(t *testing.T, channels, blockSize int, totalSamples int64, frames ...[]byte) string {
+	t.Helper()
+	streamInfo := buildFLACStreamInfo(blockSize, blockSize, 0, 0, 44100, channels, 16, totalSamples, make([]byte, 16))
+	data := append([]byte("fLaC"), 0x80, 0, 0, byte(len(streamInfo)))
+	data = append(data, streamInfo...)
+	for _, frame := range frames {
+		data = append(data, frame...)
+	}
+	path := filepath.Join(t.TempDir(), "frames.flac")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
+
+func decodeAllFLAC(t *testing.T, path string) [][]int32 {
+	t.Helper()
+	decoder, f, err := openFLACDecoder(path)
+	if err != nil {
+		t.Fatalf("openFLACDecoder: %v", err)
+	}
+	defer f.Close()
+	var out [][]int32
+	for {
+		samples, err := decoder.next()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if out == nil {
+			out = make([][]int32, len(samples))
+		}
+		for ch := range samples {
+			out[ch] = append(out[ch], samples[ch]...)
+		}
+	}
+}
+
+func TestFLACDecoderPredictedSubframes(t *testing.T) {
+	const blockSize = 16
+	left := make([]int32, blockSize)
+	right := make([]int32, blockSize)
+	for i := range left {
+		left[i] = int32(i*i*37 - 900)
+		right[i] = int32(500 - i*211)
+	}
+
+	// Mid/side: mid is FIXED order 2 over two partitions, side is LPC
+	// order 1 with an escaped second partition.
+	mid := make([]int64, blockSize)
+	side := make([]int64, blockSize)
+	for i := range left {
+		mid[i] = int64(left[i]+right[i]) >> 1
+		side[i] = int64(left[i] - right[i])
+	}
+	const coeff, shift = 3, 2
+	frame := testFLACFrame(0, blockSize, 10, func(w *flacTestBitWriter) {
+		w.write(10<<1, 8) // FIXED, order 2
+		w.writeSigned(mid[0], 16)
+		w.writeSigned(mid[1], 16)
+		w.write(0, 2)
+		w.write(1, 4) // partition order 1
+		for p := range 2 {
+			w.write(6, 4)
+			for i := max(p*blockSize/2, 2); i < (p+1)*blockSize/2; i++ {
+				w.writeRice(mid[i]-2*mid[i-1]+mid[i-2], 6)
+			}
+		}
+
+		w.write(32<<1, 8) // LPC, order 1
+		w.writeSigned(side[0], 17)
+		w.write(3, 4) // precision 4
+		w.writeSigned(shift, 5)
+		w.writeSigned(coeff, 4)
+		w.write(0, 2)
+		w.write(1, 4)
+		w.write(7, 4)
+		for i := 1; i < blockSize/2; i++ {
+			w.writeRice(side[i]-(coeff*side[i-1])>>shift, 7)
+		}
+		w.write(15, 4) // escape: raw 18-bit residuals
+		w.write(18, 5)
+		for i := blockSize / 2; i < blockSize; i++ {
+			w.writeSigned(side[i]-(coeff*side[i-1])>>shift, 18)
+		}
+	})
+
+	// Left/side with a constant left channel and a verbatim side channel
+	// using wasted bits.
+	constant := testFLACFrame(1, blockSize, 8, func(w *flacTestBitWriter) {
+		w.write(0, 8) // CONSTANT
+		w.writeSigned(-1200, 16)
+		w.write(1<<1|1, 8) // VERBATIM with wasted bits
+		w.writeUnary(2)    // 3 wasted bits
+		for i := range blockSize {
+			w.writeSigned(int64(i), 14)
+		}
+	})
+	path := writeTestFLACFrames(t, 2, blockSize, 2*blockSize, frame, constant)
+
+	got := decodeAllFLAC(t, path)
+	wantLeft := append(slices.Clone(left), slices.Repeat([]int32{-1200}, blockSize)...)
+	wantRight := slices.Clone(right)
+	for i := range blockSize {
+		wantRight = append(wantRight, -1200-int32(i*8))
+	}
+	if !slices.Equal(got[0], wantLeft) || !slices.Equal(got[1], wantRight) {
+		t.Fatalf("decoded\n left  %v\n right %v\nwant\n left  %v\n right %v", got[0], got[1], wantLeft, wantRight)
+	}
+}
+
+func TestFLACDecoderVerbatimFixture(t *testing.T) {
+	left := make([]int32, 40)
+	right := make([]int32, 40)
+	for i := range left {
+		left[i] = int32(i*1601%65536 - 32768)
+		right[i] = int32(-i)
+	}
+	data, err := encodeVerbatimFLAC([][]int32{left, right}, 44100, 16, 16)
+	if err != nil {
+		t.Fatalf("encodeVerbatimFLAC: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "verbatim.flac")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	got := decodeAllFLAC(t, path)
+	if !slices.Equal(got[0], left) || !slices.Equal(got[1], right) {
+		t.Fatalf("decoded %v, want %v %v", got, left, right)
+	}
+
+	if err := os.WriteFile(path, data[:len(data)-20], 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	decoder, f, err := openFLACDecoder(path)
+	if err != nil {
+		t.Fatalf("openFLACDecoder: %v", err)
+	}
+	defer f.Close()
+	for {
+		if _, err = decoder.next(); err != nil {
+			break
+		}
+	}
+	if err == io.EOF {
+		t.Fatal("expected a truncated stream to fail")
+	}
+}
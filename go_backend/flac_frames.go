@@ -0,0 +1,406 @@
+package gobackend
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// CRC-8 (poly 0x07) protects FLAC frame headers; CRC-16 (poly 0x8005)
+// covers the whole frame and sits in the frame footer.
+var (
+	flacCRC8Table  = buildFLACCRC8Table()
+	flacCRC16Table = buildFLACCRC16Table()
+)
+
+func buildFLACCRC8Table() [256]byte {
+	var table [256]byte
+	for i := 0; i < 256; i++ {
+		crc := byte(i)
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}
+
+func buildFLACCRC16Table() [256]uint16 {
+	var table [256]uint16
+	for i := 0; i < 256; i++ {
+		crc := uint16(i) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}
+
+func flacCRC8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc = flacCRC8Table[crc^b]
+	}
+	return crc
+}
+
+func flacCRC16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = crc<<8 ^ flacCRC16Table[byte(crc>>8)^b]
+	}
+	return crc
+}
+
+// appendFLACUTF8Number encodes a frame/sample number with the extended
+// UTF-8 scheme used in FLAC frame headers (up to 36 bits).
+func appendFLACUTF8Number(dst []byte, n uint64) []byte {
+	switch {
+	case n < 0x80:
+		return append(dst, byte(n))
+	case n < 0x800:
+		return append(dst, 0xC0|byte(n>>6), 0x80|byte(n&0x3F))
+	case n < 0x10000:
+		return append(dst, 0xE0|byte(n>>12), 0x80|byte(n>>6&0x3F), 0x80|byte(n&0x3F))
+	case n < 0x200000:
+		return append(dst, 0xF0|byte(n>>18), 0x80|byte(n>>12&0x3F), 0x80|byte(n>>6&0x3F), 0x80|byte(n&0x3F))
+	case n < 0x4000000:
+		return append(dst, 0xF8|byte(n>>24), 0x80|byte(n>>18&0x3F), 0x80|byte(n>>12&0x3F), 0x80|byte(n>>6&0x3F), 0x80|byte(n&0x3F))
+	case n < 0x80000000:
+		return append(dst, 0xFC|byte(n>>30), 0x80|byte(n>>24&0x3F), 0x80|byte(n>>18&0x3F), 0x80|byte(n>>12&0x3F), 0x80|byte(n>>6&0x3F), 0x80|byte(n&0x3F))
+	default:
+		return append(dst, 0xFE, 0x80|byte(n>>30&0x3F), 0x80|byte(n>>24&0x3F), 0x80|byte(n>>18&0x3F), 0x80|byte(n>>12&0x3F), 0x80|byte(n>>6&0x3F), 0x80|byte(n&0x3F))
+	}
+}
+
+func flacSampleSizeCode(bitsPerSample int) (byte, error) {
+	switch bitsPerSample {
+	case 8:
+		return 0x1, nil
+	case 16:
+		return 0x4, nil
+	case 24:
+		return 0x6, nil
+	}
+	return 0, fmt.Errorf("unsupported bits per sample: %d", bitsPerSample)
+}
+
+// buildFLACStreamInfo marshals a 34-byte STREAMINFO payload.
+func buildFLACStreamInfo(minBlock, maxBlock, minFrame, maxFrame, sampleRate, channels, bitsPerSample int, totalSamples int64, audioMD5 []byte) []byte {
+	info := make([]byte, 34)
+	binary.BigEndian.PutUint16(info[0:2], uint16(minBlock))
+	binary.BigEndian.PutUint16(info[2:4], uint16(maxBlock))
+	info[4], info[5], info[6] = byte(minFrame>>16), byte(minFrame>>8), byte(minFrame)
+	info[7], info[8], info[9] = byte(maxFrame>>16), byte(maxFrame>>8), byte(maxFrame)
+
+	// 20 bits sample rate, 3 bits channels-1, 5 bits bps-1, 36 bits total samples.
+	packed := uint64(sampleRate)<<44 |
+		uint64(channels-1)<<41 |
+		uint64(bitsPerSample-1)<<36 |
+		uint64(totalSamples)&0xFFFFFFFFF
+	binary.BigEndian.PutUint64(info[10:18], packed)
+	copy(info[18:34], audioMD5)
+	return info
+}
+
+// encodeVerbatimFLAC produces a complete, spec-valid FLAC stream (marker,
+// STREAMINFO as the only and last metadata block, and frames) from
+// per-channel PCM using uncompressed VERBATIM subframes. It is used for
+// embedded fixtures such as the self-test, so it favours simplicity over
+// compression.
+func encodeVerbatimFLAC(channels [][]int32, sampleRate, bitsPerSample, blockSize int) ([]byte, error) {
+	if len(channels) == 0 || len(channels) > 8 {
+		return nil, fmt.Errorf("unsupported channel count: %d", len(channels))
+	}
+	sizeCode, err := flacSampleSizeCode(bitsPerSample)
+	if err != nil {
+		return nil, err
+	}
+	if blockSize < 16 || blockSize > 65535 {
+		return nil, fmt.Errorf("invalid block size: %d", blockSize)
+	}
+	totalSamples := len(channels[0])
+	for _, ch := range channels {
+		if len(ch) != totalSamples {
+			return nil, fmt.Errorf("channels have different lengths")
+		}
+	}
+
+	bytesPerSample := bitsPerSample / 8
+	pcmHash := md5.New()
+	var frames bytes.Buffer
+	minFrame, maxFrame := 0, 0
+	sampleBuf := make([]byte, 4)
+
+	for frameNum, start := 0, 0; start < totalSamples; frameNum, start = frameNum+1, start+blockSize {
+		n := min(blockSize, totalSamples-start)
+
+		frame := []byte{0xFF, 0xF8, 0x70, byte(len(channels)-1)<<4 | sizeCode<<1}
+		frame = appendFLACUTF8Number(frame, uint64(frameNum))
+		frame = append(frame, byte((n-1)>>8), byte(n-1))
+		frame = append(frame, flacCRC8(frame))
+
+		for _, ch := range channels {
+			frame = append(frame, 0x02) // VERBATIM subframe, no wasted bits
+			for _, s := range ch[start : start+n] {
+				for b := bytesPerSample - 1; b >= 0; b-- {
+					frame = append(frame, byte(s>>(8*b)))
+				}
+			}
+		}
+		crc := flacCRC16(frame)
+		frame = append(frame, byte(crc>>8), byte(crc))
+
+		for i := start; i < start+n; i++ {
+			for _, ch := range channels {
+				binary.LittleEndian.PutUint32(sampleBuf, uint32(ch[i]))
+				pcmHash.Write(sampleBuf[:bytesPerSample])
+			}
+		}
+
+		if minFrame == 0 || len(frame) < minFrame {
+			minFrame = len(frame)
+		}
+		maxFrame = max(maxFrame, len(frame))
+		frames.Write(frame)
+	}
+
+	// The minimum block size excludes the final (short) block per the spec.
+	streamInfo := buildFLACStreamInfo(blockSize, blockSize, minFrame, maxFrame, sampleRate, len(channels), bitsPerSample, int64(totalSamples), pcmHash.Sum(nil))
+
+	var out bytes.Buffer
+	out.WriteString("fLaC")
+	out.Write([]byte{0x80, 0, 0, byte(len(streamInfo))})
+	out.Write(streamInfo)
+	out.Write(frames.Bytes())
+	return out.Bytes(), nil
+}
+
+// flacFrameHeader is the decoded part of a frame header that tools walking
+// the stream care about.
+type flacFrameHeader struct {
+	VariableBlockSize bool
+	BlockSize         int
+	SampleRate        int // 0 means "take it from STREAMINFO"
+	Channels          int
+	BitsPerSample     int // 0 means "take it from STREAMINFO"
+	// Number is the frame number for fixed-blocksize streams and the first
+	// sample number for variable-blocksize streams.
+	Number uint64
+	Length int
+}
+
+var flacFrameSampleRates = [12]int{0, 88200, 176400, 192000, 8000, 16000, 22050, 24000, 32000, 44100, 48000, 96000}
+
+var flacFrameSampleSizes = [8]int{0, 8, 12, 0, 16, 20, 24, 32}
+
+// flacMaxFrameHeaderLen covers sync, 7-byte UTF-8 number, 2-byte blocksize,
+// 2-byte sample rate and the CRC-8.
+const flacMaxFrameHeaderLen = 16
+
+// parseFLACFrameHeader decodes and CRC-checks the frame header at the start
+// of b. ok is false when b does not begin with a valid header.
+func parseFLACFrameHeader(b []byte) (hdr flacFrameHeader, ok bool) {
+	if len(b) < 6 || b[0] != 0xFF || b[1]&0xFE != 0xF8 {
+		return hdr, false
+	}
+	hdr.VariableBlockSize = b[1]&0x01 != 0
+
+	blockCode := b[2] >> 4
+	rateCode := b[2] & 0x0F
+	channelCode := b[3] >> 4
+	sizeCode := (b[3] >> 1) & 0x07
+	if blockCode == 0 || rateCode == 0x0F || channelCode > 10 || sizeCode == 3 || b[3]&0x01 != 0 {
+		return hdr, false
+	}
+
+	pos := 4
+	first := b[pos]
+	n := 0
+	switch {
+	case first&0x80 == 0:
+		n = 0
+	case first&0xE0 == 0xC0:
+		n = 1
+	case first&0xF0 == 0xE0:
+		n = 2
+	case first&0xF8 == 0xF0:
+		n = 3
+	case first&0xFC == 0xF8:
+		n = 4
+	case first&0xFE == 0xFC:
+		n = 5
+	case first == 0xFE:
+		n = 6
+	default:
+		return hdr, false
+	}
+	if len(b) < pos+1+n {
+		return hdr, false
+	}
+	if n == 0 {
+		hdr.Number = uint64(first)
+	} else {
+		hdr.Number = uint64(first & (0x7F >> (n + 1)))
+		for i := 1; i <= n; i++ {
+			c := b[pos+i]
+			if c&0xC0 != 0x80 {
+				return hdr, false
+			}
+			hdr.Number = hdr.Number<<6 | uint64(c&0x3F)
+		}
+	}
+	pos += 1 + n
+
+	switch {
+	case blockCode == 1:
+		hdr.BlockSize = 192
+	case blockCode <= 5:
+		hdr.BlockSize = 576 << (blockCode - 2)
+	case blockCode == 6:
+		if len(b) < pos+1 {
+			return hdr, false
+		}
+		hdr.BlockSize = int(b[pos]) + 1
+		pos++
+	case blockCode == 7:
+		if len(b) < pos+2 {
+			return hdr, false
+		}
+		hdr.BlockSize = int(b[pos])<<8 | int(b[pos+1]) + 1
+		pos += 2
+	default:
+		hdr.BlockSize = 256 << (blockCode - 8)
+	}
+
+	switch {
+	case rateCode < 12:
+		hdr.SampleRate = flacFrameSampleRates[rateCode]
+	case rateCode == 12:
+		if len(b) < pos+1 {
+			return hdr, false
+		}
+		hdr.SampleRate = int(b[pos]) * 1000
+		pos++
+	case rateCode == 13, rateCode == 14:
+		if len(b) < pos+2 {
+			return hdr, false
+		}
+		hdr.SampleRate = int(b[pos])<<8 | int(b[pos+1])
+		if rateCode == 14 {
+			hdr.SampleRate *= 10
+		}
+		pos += 2
+	}
+
+	if channelCode < 8 {
+		hdr.Channels = int(channelCode) + 1
+	} else {
+		hdr.Channels = 2
+	}
+	hdr.BitsPerSample = flacFrameSampleSizes[sizeCode]
+
+	if len(b) < pos+1 || flacCRC8(b[:pos]) != b[pos] {
+		return hdr, false
+	}
+	hdr.Length = pos + 1
+	return hdr, true
+}
+
+// flacFrameInfo describes one frame found by walkFLACFrames.
+type flacFrameInfo struct {
+	Offset   int64
+	Size     int64
+	Header   flacFrameHeader
+	CRCValid bool
+}
+
+// flacMaxFrameSize bounds the size of a frame with header hdr: VERBATIM
+// subframes (the side channel one bit wider) with room for their headers
+// and wasted-bits field, plus the frame header and CRC-16.
+func flacMaxFrameSize(hdr flacFrameHeader) int64 {
+	bps := int64(hdr.BitsPerSample)
+	if bps == 0 {
+		bps = 32
+	}
+	return int64(hdr.Length) + int64(hdr.Channels)*((int64(hdr.BlockSize)*(bps+1)+7)/8+6) + 2
+}
+
+// walkFLACFrames scans the audio region starting at audioOffset without
+// decoding it. A sync code with a valid header CRC-8 starts a new frame
+// when it is the first, when its number is the expected next one, or when
+// the CRC-16 of the frame so far checks out at that boundary; a sync code
+// inside frame data passes the CRC-8 now and then, and taking it would
+// split the frame. Only once a frame has outgrown flacMaxFrameSize, so
+// frames must be missing, is any valid header taken. Each frame's CRC-16
+// is checked using the property that the CRC over a frame including its
+// footer is zero. Bytes before the first valid header are reported via
+// leading.
+func walkFLACFrames(r io.Reader, audioOffset int64, fn func(frame flacFrameInfo) error) (leading int64, err error) {
+	br := bufio.NewReaderSize(r, 256*1024)
+	pos := audioOffset
+
+	var (
+		inFrame  bool
+		current  flacFrameInfo
+		crc      uint16
+		expected uint64
+	)
+
+	finish := func() error {
+		if !inFrame {
+			return nil
+		}
+		current.Size = pos - current.Offset
+		current.CRCValid = crc == 0 && current.Size > int64(current.Header.Length)+2
+		return fn(current)
+	}
+
+	for {
+		if peek, _ := br.Peek(flacMaxFrameHeaderLen); len(peek) >= 2 && peek[0] == 0xFF && peek[1]&0xFE == 0xF8 {
+			if hdr, ok := parseFLACFrameHeader(peek); ok {
+				accept := !inFrame || crc == 0 || hdr.Number == expected ||
+					pos-current.Offset > flacMaxFrameSize(current.Header)
+				if accept {
+					if err := finish(); err != nil {
+						return leading, err
+					}
+					inFrame = true
+					current = flacFrameInfo{Offset: pos, Header: hdr}
+					crc = 0
+					if hdr.VariableBlockSize {
+						expected = hdr.Number + uint64(hdr.BlockSize)
+					} else {
+						expected = hdr.Number + 1
+					}
+				}
+			}
+		}
+
+		b, readErr := br.ReadByte()
+		if readErr == io.EOF {
+			return leading, finish()
+		}
+		if readErr != nil {
+			return leading, readErr
+		}
+		if inFrame {
+			crc = crc<<8 ^ flacCRC16Table[byte(crc>>8)^b]
+		} else {
+			leading++
+		}
+		pos++
+	}
+}
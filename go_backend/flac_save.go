@@ -0,0 +1,365 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+// flacRewriteChunkSize bounds the memory a rewrite uses for audio data no
+// matter how large the file is.
+const flacRewriteChunkSize = 1 << 20
+
+type FLACRewriteProgress struct {
+	FilePath     string  `json:"file_path"`
+	BytesWritten int64   `json:"bytes_written"`
+	BytesTotal   int64   `json:"bytes_total"`
+	Progress     float64 `json:"progress"`
+	IsActive     bool    `json:"is_active"`
+}
+
+var (
+	flacRewriteProgress   FLACRewriteProgress
+	flacRewriteProgressMu sync.RWMutex
+
+	// flacRewriteCount counts completed temp-file/rename cycles so tests can
+	// assert how many full rewrites an operation costs.
+	flacRewriteCount atomic.Int64
+	// flacInPlaceWriteCount counts metadata regions overwritten in place.
+	flacInPlaceWriteCount atomic.Int64
+)
+
+func setFLACRewriteProgress(p FLACRewriteProgress) {
+	if p.BytesTotal > 0 {
+		p.Progress = float64(p.BytesWritten) / float64(p.BytesTotal)
+	}
+	flacRewriteProgressMu.Lock()
+	flacRewriteProgress = p
+	flacRewriteProgressMu.Unlock()
+}
+
+// GetFLACRewriteProgress reports the tag save currently copying audio data,
+// so the UI can show progress when re-tagging very large files.
+func GetFLACRewriteProgress() string {
+	flacRewriteProgressMu.RLock()
+	defer flacRewriteProgressMu.RUnlock()
+
+	jsonBytes, _ := json.Marshal(flacRewriteProgress)
+	return string(jsonBytes)
+}
+
+// saveFLACAtomic writes f's metadata back to filePath. When the new metadata
+// fits the file's existing padding, leaving no more than PaddingMax of it,
+// only the metadata region is overwritten. Otherwise the metadata, with its
+// padding normalized to PaddingTarget, and the audio frames are written to a
+// temporary file that is renamed over the original: a crash or full disk
+// mid-write leaves the old file intact, and audio is streamed in fixed-size
+// chunks so memory use does not grow with file size. go-flac's in-place Save
+// offers neither guarantee. Cached reads of filePath are dropped afterwards.
+func saveFLACAtomic(f *flac.File, filePath string) error {
+	_, err := saveFLACAtomicStats(f, filePath, "save")
+	return err
+}
+
+// How a FLAC save wrote the file.
+const (
+	// RewriteInPlace overwrote only the metadata region; the audio was not
+	// touched.
+	RewriteInPlace = "in_place"
+	// RewriteAtomic is a full copy to a temporary file renamed over the
+	// original.
+	RewriteAtomic = "atomic"
+	// RewriteDescriptor is a full rewrite of a SAF descriptor, which cannot
+	// be renamed over.
+	RewriteDescriptor = "descriptor"
+	// RewriteDeferred wrote the new file next to the original, which is
+	// being played; CommitPendingWrites renames it over the original later.
+	RewriteDeferred = "deferred"
+)
+
+type flacSaveStats struct {
+	kind string
+	// bytesRead counts audio bytes copied from the original; in-place
+	// writes read none.
+	bytesRead    int64
+	bytesWritten int64
+	fsync        time.Duration
+}
+
+// saveFLACAtomicStats is saveFLACAtomic reporting how the file was written.
+// The save is counted under op in the "writes" section of GetStats, and
+// storage permission failures come back as a PermissionError.
+func saveFLACAtomicStats(f *flac.File, filePath, op string) (flacSaveStats, error) {
+	stats, err := saveFLACFile(f, filePath)
+	recordWriteStats(op, stats, err)
+	return stats, classifyWriteError(filePath, err)
+}
+
+func saveFLACFile(f *flac.File, filePath string) (flacSaveStats, error) {
+	cfg := GetBackendConfig()
+	if cfg.ReadOnly || cfg.AtomicTagWrites || isPlaybackActive(filePath) || hasPendingWrite(filePath) || strings.HasPrefix(filePath, "/proc/self/fd/") {
+		return rewriteFLACFile(f, filePath)
+	}
+	logMalformedComments(filePath, repairFLACComments(f))
+	region, ok := fitFLACMetadataInPlace(filePath, f.Meta, int64(cfg.PaddingMax))
+	if !ok {
+		return rewriteFLACFile(f, filePath)
+	}
+	f.Close()
+	return writeFLACMetadataInPlace(filePath, region)
+}
+
+// fitFLACMetadataInPlace lays blocks out over the metadata region of the
+// file at filePath. Blocks that fill the region exactly are written without
+// a padding block. It reports false when they do not fit, when less than a
+// padding block header or more than maxPadding bytes of padding would be
+// left, or when the file layout is not clean.
+func fitFLACMetadataInPlace(filePath string, blocks []*flac.MetaDataBlock, maxPadding int64) ([]byte, bool) {
+	src, err := os.Open(filePath)
+	if err != nil {
+		return nil, false
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return nil, false
+	}
+	layout, err := scanFLACMetadataBlocks(src, info.Size())
+	if err != nil || len(layout.Issues) > 0 {
+		return nil, false
+	}
+
+	regionSize := layout.AudioOffset - 4
+	var used int64
+	for _, block := range blocks {
+		if block.Type != flac.Padding {
+			used += 4 + int64(len(block.Data))
+		}
+	}
+	if used != regionSize {
+		if padding := regionSize - used - 4; padding < 0 || padding > maxPadding {
+			return nil, false
+		}
+	}
+	return marshalFLACMetadataRegion(blocks, regionSize)
+}
+
+// normalizeFLACPadding replaces every padding block with one block of
+// target bytes at the end.
+func normalizeFLACPadding(blocks []*flac.MetaDataBlock, target int) []*flac.MetaDataBlock {
+	kept := make([]*flac.MetaDataBlock, 0, len(blocks)+1)
+	for _, block := range blocks {
+		if block.Type != flac.Padding {
+			kept = append(kept, block)
+		}
+	}
+	return append(kept, &flac.MetaDataBlock{Type: flac.Padding, Data: make([]byte, target)})
+}
+
+// rewriteFLACFile writes the whole file, audio included, with its padding
+// normalized to PaddingTarget and its malformed comments repaired.
+func rewriteFLACFile(f *flac.File, filePath string) (flacSaveStats, error) {
+	defer invalidateMetadataCache(filePath)
+	if err := checkReadOnlyMode(); err != nil {
+		f.Close()
+		return flacSaveStats{}, err
+	}
+	logMalformedComments(filePath, repairFLACComments(f))
+	f.Meta = normalizeFLACPadding(f.Meta, GetBackendConfig().PaddingTarget)
+	if strings.HasPrefix(filePath, "/proc/self/fd/") {
+		// SAF descriptors cannot be renamed over; rewrite them in place.
+		if err := f.Save(filePath); err != nil {
+			return flacSaveStats{}, err
+		}
+		stats := flacSaveStats{kind: RewriteDescriptor}
+		if info, err := os.Stat(filePath); err == nil {
+			stats.bytesWritten = info.Size()
+		}
+		return stats, nil
+	}
+	// Only the metadata is needed; the audio is re-read from disk.
+	f.Close()
+	// A file with a pending write keeps deferring until it is committed, or
+	// the commit would find the original changed and drop the write.
+	if isPlaybackActive(filePath) || hasPendingWrite(filePath) {
+		if stats, err := deferFLACRewrite(filePath, f.Meta); err != errPendingWritesUnavailable {
+			return stats, err
+		}
+		GoLog("[FLACSave] No data directory to defer the write of %s; replacing it now\n", filePath)
+	}
+	return rewriteFLACStreaming(filePath, f.Meta)
+}
+
+func rewriteFLACStreaming(filePath string, blocks []*flac.MetaDataBlock) (flacSaveStats, error) {
+	tmpPath := filePath + ".tmp"
+	stats, err := writeFLACStreamingTemp(filePath, tmpPath, blocks)
+	if err != nil {
+		return flacSaveStats{}, err
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		setFLACRewriteProgress(FLACRewriteProgress{})
+		return flacSaveStats{}, fmt.Errorf("failed to replace FLAC file: %w", err)
+	}
+	flacRewriteCount.Add(1)
+	stats.kind = RewriteAtomic
+	return stats, nil
+}
+
+// writeFLACStreamingTemp writes blocks followed by the audio frames of
+// filePath to tmpPath. The returned stats have no kind; the caller renames
+// the file and sets it.
+func writeFLACStreamingTemp(filePath, tmpPath string, blocks []*flac.MetaDataBlock) (flacSaveStats, error) {
+	src, err := os.Open(filePath)
+	if err != nil {
+		return flacSaveStats{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return flacSaveStats{}, err
+	}
+	layout, err := scanFLACMetadataBlocks(src, info.Size())
+	if err != nil {
+		return flacSaveStats{}, err
+	}
+	if _, err := src.Seek(layout.AudioOffset, io.SeekStart); err != nil {
+		return flacSaveStats{}, err
+	}
+
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return flacSaveStats{}, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	fail := func(err error) (flacSaveStats, error) {
+		out.Close()
+		os.Remove(tmpPath)
+		setFLACRewriteProgress(FLACRewriteProgress{})
+		return flacSaveStats{}, err
+	}
+
+	written := int64(4)
+	if _, err := out.Write([]byte("fLaC")); err != nil {
+		return fail(fmt.Errorf("failed to write FLAC file: %w", err))
+	}
+	for i, block := range blocks {
+		n, err := out.Write(block.Marshal(i == len(blocks)-1))
+		if err != nil {
+			return fail(fmt.Errorf("failed to write metadata: %w", err))
+		}
+		written += int64(n)
+	}
+
+	progress := FLACRewriteProgress{
+		FilePath:   filePath,
+		BytesTotal: info.Size() - layout.AudioOffset,
+		IsActive:   true,
+	}
+	setFLACRewriteProgress(progress)
+
+	buf := make([]byte, flacRewriteChunkSize)
+	for {
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			if _, err := out.Write(buf[:n]); err != nil {
+				return fail(fmt.Errorf("failed to write audio frames: %w", err))
+			}
+			progress.BytesWritten += int64(n)
+			setFLACRewriteProgress(progress)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return fail(fmt.Errorf("failed to read audio frames: %w", readErr))
+		}
+	}
+
+	syncStarted := time.Now()
+	if err := out.Sync(); err != nil {
+		return fail(fmt.Errorf("failed to sync FLAC file: %w", err))
+	}
+	fsync := time.Since(syncStarted)
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		setFLACRewriteProgress(FLACRewriteProgress{})
+		return flacSaveStats{}, err
+	}
+
+	progress.IsActive = false
+	setFLACRewriteProgress(progress)
+	return flacSaveStats{
+		bytesRead:    progress.BytesWritten,
+		bytesWritten: written + progress.BytesWritten,
+		fsync:        fsync,
+	}, nil
+}
+
+// maxFLACBlockLength is the largest body a metadata block header can encode.
+const maxFLACBlockLength = 1<<24 - 1
+
+// marshalFLACMetadataRegion lays out blocks, padding dropped, to exactly
+// regionSize bytes by filling the remainder with one padding block, so the
+// metadata can be overwritten without moving the audio. It reports false
+// when the blocks do not fit.
+func marshalFLACMetadataRegion(blocks []*flac.MetaDataBlock, regionSize int64) ([]byte, bool) {
+	kept := make([]*flac.MetaDataBlock, 0, len(blocks))
+	var size int64
+	for _, block := range blocks {
+		if block.Type == flac.Padding {
+			continue
+		}
+		kept = append(kept, block)
+		size += 4 + int64(len(block.Data))
+	}
+	remaining := regionSize - size
+	if remaining < 0 || (remaining > 0 && remaining < 4) || remaining-4 > maxFLACBlockLength {
+		return nil, false
+	}
+	if remaining > 0 {
+		kept = append(kept, &flac.MetaDataBlock{Type: flac.Padding, Data: make([]byte, remaining-4)})
+	}
+
+	region := make([]byte, 0, regionSize)
+	for i, block := range kept {
+		region = append(region, block.Marshal(i == len(kept)-1)...)
+	}
+	return region, true
+}
+
+// writeFLACMetadataInPlace overwrites the metadata blocks of filePath, which
+// start right after the "fLaC" marker, with region in a single write. The
+// caller must ensure region is exactly as long as the existing blocks.
+func writeFLACMetadataInPlace(filePath string, region []byte) (flacSaveStats, error) {
+	defer invalidateMetadataCache(filePath)
+	if err := checkReadOnlyMode(); err != nil {
+		return flacSaveStats{}, err
+	}
+	out, err := os.OpenFile(filePath, os.O_WRONLY, 0)
+	if err != nil {
+		return flacSaveStats{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	if _, err := out.WriteAt(region, 4); err != nil {
+		out.Close()
+		return flacSaveStats{}, fmt.Errorf("failed to write metadata: %w", err)
+	}
+	syncStarted := time.Now()
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return flacSaveStats{}, fmt.Errorf("failed to sync FLAC file: %w", err)
+	}
+	fsync := time.Since(syncStarted)
+	if err := out.Close(); err != nil {
+		return flacSaveStats{}, err
+	}
+	flacInPlaceWriteCount.Add(1)
+	return flacSaveStats{kind: RewriteInPlace, bytesWritten: int64(len(region)), fsync: fsync}, nil
+}
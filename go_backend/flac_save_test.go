@@ -0,0 +1,182 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+func TestSaveFLACStreamsLargeFileWithBoundedMemory(t *testing.T) {
+	path := writeVerifyFixture(t, nil)
+	// Extend the fixture into a large sparse file; the rewrite copies the
+	// audio region without looking at it.
+	const size = 64 << 20
+	if err := os.Truncate(path, size); err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	if err := EmbedMetadata(path, Metadata{Title: "Large", Artist: "Artist"}, ""); err != nil {
+		t.Fatalf("EmbedMetadata: %v", err)
+	}
+
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 16<<20 {
+		t.Fatalf("embed allocated %d bytes for a %d byte file", allocated, size)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() < size {
+		t.Fatalf("file shrank to %d bytes", info.Size())
+	}
+	if meta, _ := ReadMetadata(path); meta.Title != "Large" {
+		t.Fatalf("tags not written: %+v", meta)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatal("temp file left behind")
+	}
+
+	var progress FLACRewriteProgress
+	if err := json.Unmarshal([]byte(GetFLACRewriteProgress()), &progress); err != nil {
+		t.Fatal(err)
+	}
+	if progress.IsActive || progress.BytesTotal == 0 || progress.BytesWritten != progress.BytesTotal {
+		t.Fatalf("unexpected final progress: %+v", progress)
+	}
+}
+
+func TestSaveFLACKeepsOriginalOnFailure(t *testing.T) {
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	if err := Configure(`{"atomic_tag_writes":true}`); err != nil {
+		t.Fatal(err)
+	}
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Original"})
+	before, _ := os.ReadFile(path)
+
+	// A directory where the temp file should go makes the rewrite fail
+	// before the original is touched.
+	if err := os.Mkdir(path+".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := EmbedMetadata(path, Metadata{Title: "Changed"}, ""); err == nil {
+		t.Fatal("expected save to fail")
+	}
+
+	current, _ := os.ReadFile(path)
+	if string(current) != string(before) {
+		t.Fatal("original file modified by failed save")
+	}
+}
+
+func TestSaveFLACNormalizesPadding(t *testing.T) {
+	path := writeVerifyFixture(t, nil)
+	if footprint, err := GetMetadataFootprint(path); err != nil || !footprint.RepadRecommended {
+		t.Fatalf("unpadded file should be a repad candidate: %+v %v", footprint, err)
+	}
+
+	rewrites := flacRewriteCount.Load()
+	if err := EmbedMetadata(path, Metadata{Title: "Song", Artist: "Artist"}, ""); err != nil {
+		t.Fatalf("EmbedMetadata: %v", err)
+	}
+	footprint, err := GetMetadataFootprint(path)
+	if err != nil {
+		t.Fatalf("GetMetadataFootprint: %v", err)
+	}
+	if footprint.Padding != 4+defaultPaddingTarget || footprint.RepadRecommended || flacRewriteCount.Load() != rewrites+1 {
+		t.Fatalf("first tag pass did not pad: %+v", footprint)
+	}
+
+	// A lyrics update now fits the padding and leaves the audio alone.
+	rewrites, inPlace := flacRewriteCount.Load(), flacInPlaceWriteCount.Load()
+	if err := EmbedLyrics(path, "[00:01.00]Hello"); err != nil {
+		t.Fatalf("EmbedLyrics: %v", err)
+	}
+	if flacRewriteCount.Load() != rewrites || flacInPlaceWriteCount.Load() != inPlace+1 {
+		t.Fatal("lyrics update was not written in place")
+	}
+	if meta, _ := ReadMetadata(path); meta.Title != "Song" {
+		t.Fatalf("tags lost: %+v", meta)
+	}
+}
+
+func TestSaveFLACReclaimsExcessPadding(t *testing.T) {
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	if err := Configure(`{"padding_target":300000,"padding_max":300000}`); err != nil {
+		t.Fatal(err)
+	}
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Padded"})
+	SetBackendConfig(original)
+
+	if footprint, err := GetMetadataFootprint(path); err != nil || !footprint.RepadRecommended {
+		t.Fatalf("oversized padding should be a repad candidate: %+v %v", footprint, err)
+	}
+	before, _ := os.Stat(path)
+	if err := EmbedMetadata(path, Metadata{Title: "Trimmed"}, ""); err != nil {
+		t.Fatalf("EmbedMetadata: %v", err)
+	}
+	footprint, err := GetMetadataFootprint(path)
+	if err != nil {
+		t.Fatalf("GetMetadataFootprint: %v", err)
+	}
+	if footprint.Padding != 4+defaultPaddingTarget || footprint.FileSize >= before.Size() {
+		t.Fatalf("padding not reclaimed: %+v (was %d bytes)", footprint, before.Size())
+	}
+}
+
+func TestFitFLACMetadataInPlaceExactFit(t *testing.T) {
+	const padding = 1000
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	padFLAC(t, path, padding)
+	hashBefore, _ := AudioHash(path)
+
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	withApplication := func(size int) []*flac.MetaDataBlock {
+		blocks := []*flac.MetaDataBlock{}
+		for _, block := range f.Meta {
+			if block.Type != flac.Padding {
+				blocks = append(blocks, block)
+			}
+		}
+		return append(blocks, &flac.MetaDataBlock{Type: flac.Application, Data: append([]byte("test"), make([]byte, size-4)...)})
+	}
+
+	// One byte short of the padding block header cannot be laid out.
+	if _, ok := fitFLACMetadataInPlace(path, withApplication(padding-1), padding); ok {
+		t.Fatal("a 1-byte gap cannot hold a padding block")
+	}
+	// Filling the old padding block exactly leaves no padding at all.
+	blocks := withApplication(padding)
+	region, ok := fitFLACMetadataInPlace(path, blocks, padding)
+	if !ok {
+		t.Fatal("exact fit was rejected")
+	}
+	if _, err := writeFLACMetadataInPlace(path, region); err != nil {
+		t.Fatalf("writeFLACMetadataInPlace: %v", err)
+	}
+
+	footprint, err := GetMetadataFootprint(path)
+	if err != nil || footprint.Padding != 0 {
+		t.Fatalf("footprint after exact fit = %+v %v", footprint, err)
+	}
+	if hashAfter, _ := AudioHash(path); hashAfter != hashBefore {
+		t.Fatal("audio frames changed")
+	}
+	if meta, _ := ReadMetadata(path); meta.Title != "Song" {
+		t.Fatalf("tags lost: %+v", meta)
+	}
+}
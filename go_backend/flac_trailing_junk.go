@@ -0,0 +1,175 @@
+package gobackend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Kinds of tag data found appended after the last FLAC frame, typically
+// left over from an MP3 the file was converted from.
+const (
+	TrailingJunkAPE     = "ape"
+	TrailingJunkLyrics3 = "lyrics3"
+	TrailingJunkID3v1   = "id3v1"
+)
+
+const (
+	apeTagFooterSize = 32
+	id3v1TagSize     = 128
+	lyrics3v1MaxSize = 5100
+	lyrics3v2Trailer = 15 // six-digit size plus "LYRICS200"
+	lyrics3BeginMark = "LYRICSBEGIN"
+	lyrics3v1EndMark = "LYRICSEND"
+	lyrics3v2EndMark = "LYRICS200"
+	apeTagHasHeader  = 1 << 31
+)
+
+// FLACTrailingJunk is one tag appended after the audio frames.
+type FLACTrailingJunk struct {
+	Type   string `json:"type"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+}
+
+// TrimJunkResult reports what TrimTrailingJunk removed.
+type TrimJunkResult struct {
+	Path         string             `json:"path"`
+	Removed      []FLACTrailingJunk `json:"removed"`
+	BytesRemoved int64              `json:"bytes_removed"`
+}
+
+func readAtExactly(r io.ReaderAt, offset int64, n int) []byte {
+	buf := make([]byte, n)
+	if _, err := r.ReadAt(buf, offset); err != nil {
+		return nil
+	}
+	return buf
+}
+
+// findFLACTrailingJunk peels APEv2, LYRICS3 (v1 and v2) and ID3v1 tags off
+// the end of a file, in any order and stacked, stopping at audioOffset. The
+// tags are returned in file order.
+func findFLACTrailingJunk(r io.ReaderAt, audioOffset, size int64) []FLACTrailingJunk {
+	var junk []FLACTrailingJunk
+	end := size
+	found := func(kind string, start int64) {
+		junk = append([]FLACTrailingJunk{{Type: kind, Offset: start, Size: end - start}}, junk...)
+		end = start
+	}
+	for {
+		if start := end - id3v1TagSize; start >= audioOffset {
+			if tag := readAtExactly(r, start, 3); string(tag) == "TAG" {
+				found(TrailingJunkID3v1, start)
+				continue
+			}
+		}
+		if end-apeTagFooterSize >= audioOffset {
+			footer := readAtExactly(r, end-apeTagFooterSize, apeTagFooterSize)
+			if footer != nil && string(footer[:8]) == "APETAGEX" {
+				total := int64(binary.LittleEndian.Uint32(footer[12:16]))
+				if binary.LittleEndian.Uint32(footer[20:24])&apeTagHasHeader != 0 {
+					total += apeTagFooterSize
+				}
+				if start := end - total; total >= apeTagFooterSize && start >= audioOffset {
+					found(TrailingJunkAPE, start)
+					continue
+				}
+			}
+		}
+		if end-lyrics3v2Trailer >= audioOffset {
+			trailer := readAtExactly(r, end-lyrics3v2Trailer, lyrics3v2Trailer)
+			if trailer != nil && string(trailer[6:]) == lyrics3v2EndMark {
+				if n, err := strconv.Atoi(string(trailer[:6])); err == nil {
+					start := end - lyrics3v2Trailer - int64(n)
+					if start >= audioOffset && string(readAtExactly(r, start, len(lyrics3BeginMark))) == lyrics3BeginMark {
+						found(TrailingJunkLyrics3, start)
+						continue
+					}
+				}
+			}
+		}
+		if end-int64(len(lyrics3v1EndMark)) >= audioOffset &&
+			string(readAtExactly(r, end-int64(len(lyrics3v1EndMark)), len(lyrics3v1EndMark))) == lyrics3v1EndMark {
+			from := max(end-int64(len(lyrics3v1EndMark)+len(lyrics3BeginMark)+lyrics3v1MaxSize), audioOffset)
+			if window := readAtExactly(r, from, int(end-from)); window != nil {
+				if i := bytes.LastIndex(window, []byte(lyrics3BeginMark)); i >= 0 {
+					found(TrailingJunkLyrics3, from+int64(i))
+					continue
+				}
+			}
+		}
+		return junk
+	}
+}
+
+func trimFLACTrailingJunk(filePath string) (*TrimJunkResult, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	layout, err := scanFLACMetadataBlocks(f, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	result := &TrimJunkResult{Path: filePath, Removed: findFLACTrailingJunk(f, layout.AudioOffset, info.Size())}
+	if len(result.Removed) == 0 {
+		result.Removed = []FLACTrailingJunk{}
+		return result, nil
+	}
+	cut := result.Removed[0].Offset
+
+	if _, err := f.Seek(layout.AudioOffset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var last flacFrameInfo
+	_, err = walkFLACFrames(io.LimitReader(f, cut-layout.AudioOffset), layout.AudioOffset, func(frame flacFrameInfo) error {
+		last = frame
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan frames: %w", err)
+	}
+	if last.Size == 0 || !last.CRCValid || last.Offset+last.Size != cut {
+		return nil, fmt.Errorf("last FLAC frame does not end at offset %d; not trimming", cut)
+	}
+
+	if err := os.Truncate(filePath, cut); err != nil {
+		return nil, classifyWriteError(filePath, fmt.Errorf("failed to truncate file: %w", err))
+	}
+	invalidateMetadataCache(filePath)
+	result.BytesRemoved = info.Size() - cut
+	GoLog("[TrimJunk] Removed %d bytes of trailing tags: %s\n", result.BytesRemoved, filePath)
+	return result, nil
+}
+
+// TrimTrailingJunk truncates the APE, LYRICS3 and ID3v1 tags VerifyFLAC
+// reports after the last frame of a FLAC file. It first walks the frames
+// up to the cut and refuses unless the last one ends exactly there with a
+// valid CRC, so no audio is ever lost. A file without junk is left alone.
+func TrimTrailingJunk(filePath string) (string, error) {
+	if err := checkWriteAllowed(filePath); err != nil {
+		return "", err
+	}
+	if hasPendingWrite(filePath) {
+		return "", ErrWritePending
+	}
+	result, err := trimFLACTrailingJunk(filePath)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
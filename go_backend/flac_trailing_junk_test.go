@@ -0,0 +1,92 @@
+package gobackend
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+// appendMP3Leftovers appends a LYRICS3v2 tag, an APEv2 tag with header and
+// an ID3v1 tag, the way MP3 taggers stack them.
+func appendMP3Leftovers(data []byte) []byte {
+	lyrics := lyrics3BeginMark + "IND00003110LYR00011[00:01]Hi"
+	data = append(data, lyrics...)
+	data = append(data, fmt.Sprintf("%06d", len(lyrics))+lyrics3v2EndMark...)
+
+	item := append(binary.LittleEndian.AppendUint32(nil, 6), 0, 0, 0, 0)
+	item = append(append(item, "Artist"...), 0)
+	item = append(item, "Nobody"...)
+	ape := func(flags uint32) []byte {
+		b := append([]byte("APETAGEX"), binary.LittleEndian.AppendUint32(nil, 2000)...)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(item)+apeTagFooterSize))
+		b = binary.LittleEndian.AppendUint32(b, 1)
+		b = binary.LittleEndian.AppendUint32(b, flags)
+		return append(b, make([]byte, 8)...)
+	}
+	data = append(data, ape(apeTagHasHeader|1<<29)...)
+	data = append(data, item...)
+	data = append(data, ape(apeTagHasHeader)...)
+
+	id3 := make([]byte, id3v1TagSize)
+	copy(id3, "TAGSong")
+	return append(data, id3...)
+}
+
+func TestVerifyFLACReportsTrailingJunk(t *testing.T) {
+	clean := writeVerifyFixture(t, nil)
+	cleanReport, _ := verifyFLAC(clean, true)
+	path := writeVerifyFixture(t, appendMP3Leftovers)
+
+	for _, deep := range []bool{false, true} {
+		report, err := verifyFLAC(path, deep)
+		if err != nil {
+			t.Fatalf("verifyFLAC(deep=%v): %v", deep, err)
+		}
+		if !report.Valid || len(report.TrailingJunk) != 3 {
+			t.Fatalf("deep=%v: report %+v", deep, report)
+		}
+		kinds := []string{TrailingJunkLyrics3, TrailingJunkAPE, TrailingJunkID3v1}
+		offset := cleanReport.FileSize
+		for i, junk := range report.TrailingJunk {
+			if junk.Type != kinds[i] || junk.Offset != offset {
+				t.Fatalf("junk %d = %+v, want %s at %d", i, junk, kinds[i], offset)
+			}
+			offset += junk.Size
+		}
+		if offset != report.FileSize {
+			t.Fatalf("junk ends at %d of %d", offset, report.FileSize)
+		}
+	}
+	if report, _ := verifyFLAC(path, true); report.FrameCount != cleanReport.FrameCount || len(report.BadFrames) != 0 {
+		t.Fatalf("junk counted as audio: %+v", report)
+	}
+
+	out, err := TrimTrailingJunk(path)
+	result := mustDecodeJSON[TrimJunkResult](t, out, err)
+	if len(result.Removed) != 3 || string(mustReadFile(t, path)) != string(mustReadFile(t, clean)) {
+		t.Fatalf("trim result %+v did not restore the clean file", result)
+	}
+	report, err := verifyFLAC(path, true)
+	if err != nil || !report.Valid || report.TrailingJunk != nil {
+		t.Fatalf("verify after trim = %+v, %v", report, err)
+	}
+	if out, err := TrimTrailingJunk(path); err != nil || out != fmt.Sprintf(`{"path":%q,"removed":[],"bytes_removed":0}`, path) {
+		t.Fatalf("second TrimTrailingJunk = %s, %v", out, err)
+	}
+}
+
+func TestTrimTrailingJunkRefusesDamagedLastFrame(t *testing.T) {
+	path := writeVerifyFixture(t, func(data []byte) []byte {
+		data[len(data)-10] ^= 0x55
+		id3 := make([]byte, id3v1TagSize)
+		copy(id3, "TAG")
+		return append(data, id3...)
+	})
+	before := mustReadFile(t, path)
+	if _, err := TrimTrailingJunk(path); err == nil {
+		t.Fatal("expected a damaged last frame to stop the trim")
+	}
+	if string(mustReadFile(t, path)) != string(before) {
+		t.Fatal("file modified")
+	}
+}
@@ -0,0 +1,275 @@
+package gobackend
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// flacMetadataBlockInfo locates one metadata block in a FLAC file.
+type flacMetadataBlockInfo struct {
+	Type   byte  `json:"type"`
+	Offset int64 `json:"offset"`
+	Length int   `json:"length"`
+	IsLast bool  `json:"is_last"`
+}
+
+// flacBlockLayout is the result of walking the metadata section.
+type flacBlockLayout struct {
+	Blocks      []flacMetadataBlockInfo
+	AudioOffset int64
+	StreamInfo  []byte
+	Issues      []string
+	// FirstBadOffset is the offset of the first structural problem, or -1.
+	FirstBadOffset int64
+}
+
+func (l *flacBlockLayout) addIssue(offset int64, format string, args ...interface{}) {
+	l.Issues = append(l.Issues, fmt.Sprintf("@%d: ", offset)+fmt.Sprintf(format, args...))
+	if l.FirstBadOffset < 0 || offset < l.FirstBadOffset {
+		l.FirstBadOffset = offset
+	}
+}
+
+// flacMaxMetadataBlocks caps how many metadata blocks
+// scanFLACMetadataBlocks walks. Real files have a handful; a hostile one can
+// chain millions of empty blocks.
+const flacMaxMetadataBlocks = 1024
+
+// scanFLACMetadataBlocks walks the metadata block headers of a FLAC file,
+// checking each length against the file size and the last-block flag
+// against where audio actually begins. Only STREAMINFO is read in full.
+func scanFLACMetadataBlocks(f io.ReadSeeker, fileSize int64) (*flacBlockLayout, error) {
+	layout := &flacBlockLayout{FirstBadOffset: -1}
+
+	marker := make([]byte, 4)
+	if _, err := io.ReadFull(f, marker); err != nil {
+		return nil, fmt.Errorf("failed to read marker: %w", err)
+	}
+	if string(marker) != "fLaC" {
+		return nil, fmt.Errorf("not a FLAC file")
+	}
+
+	pos := int64(4)
+	header := make([]byte, 4)
+	for {
+		if len(layout.Blocks) >= flacMaxMetadataBlocks {
+			layout.addIssue(pos, "more than %d metadata blocks", flacMaxMetadataBlocks)
+			layout.AudioOffset = fileSize
+			return layout, nil
+		}
+		if pos+4 > fileSize {
+			layout.addIssue(pos, "metadata ends before the last-block flag")
+			layout.AudioOffset = fileSize
+			return layout, nil
+		}
+		if _, err := f.Seek(pos, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(f, header); err != nil {
+			return nil, fmt.Errorf("failed to read block header: %w", err)
+		}
+
+		// A frame sync where a block header should be means the previous
+		// block was the last one but its flag was never set.
+		if len(layout.Blocks) > 0 && header[0] == 0xFF && header[1]&0xFE == 0xF8 {
+			layout.addIssue(pos, "last metadata block is missing its last-block flag")
+			layout.AudioOffset = pos
+			return layout, nil
+		}
+
+		block := flacMetadataBlockInfo{
+			Type:   header[0] & 0x7F,
+			Offset: pos,
+			Length: int(header[1])<<16 | int(header[2])<<8 | int(header[3]),
+			IsLast: header[0]&0x80 != 0,
+		}
+
+		switch {
+		case block.Type == 127:
+			layout.addIssue(pos, "invalid metadata block type 127")
+		case len(layout.Blocks) == 0 && block.Type != 0:
+			layout.addIssue(pos, "first metadata block is type %d, not STREAMINFO", block.Type)
+		case len(layout.Blocks) > 0 && block.Type == 0:
+			layout.addIssue(pos, "duplicate STREAMINFO block")
+		case block.Type == 0 && block.Length != 34:
+			layout.addIssue(pos, "STREAMINFO length is %d, want 34", block.Length)
+		}
+
+		end := pos + 4 + int64(block.Length)
+		if end > fileSize {
+			layout.addIssue(pos, "block type %d length %d runs past end of file", block.Type, block.Length)
+			layout.Blocks = append(layout.Blocks, block)
+			layout.AudioOffset = fileSize
+			return layout, nil
+		}
+
+		if block.Type == 0 && block.Length == 34 && layout.StreamInfo == nil {
+			layout.StreamInfo = make([]byte, 34)
+			if _, err := io.ReadFull(f, layout.StreamInfo); err != nil {
+				return nil, fmt.Errorf("failed to read STREAMINFO: %w", err)
+			}
+		}
+
+		layout.Blocks = append(layout.Blocks, block)
+		pos = end
+		if block.IsLast {
+			layout.AudioOffset = pos
+			return layout, nil
+		}
+	}
+}
+
+type FLACBadFrame struct {
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	Reason string `json:"reason"`
+}
+
+type FLACVerifyReport struct {
+	Valid       bool           `json:"valid"`
+	Deep        bool           `json:"deep"`
+	FileSize    int64          `json:"file_size"`
+	AudioOffset int64          `json:"audio_offset"`
+	BlockCount  int            `json:"block_count"`
+	BlockIssues []string       `json:"block_issues,omitempty"`
+	FrameCount  int            `json:"frame_count"`
+	BadFrames   []FLACBadFrame `json:"bad_frames,omitempty"`
+	// SamplesFound is the sum of frame block sizes seen in a deep scan;
+	// SamplesExpected comes from STREAMINFO (0 = unknown).
+	SamplesFound    int64 `json:"samples_found,omitempty"`
+	SamplesExpected int64 `json:"samples_expected,omitempty"`
+	// FirstCorruptionOffset is where a re-download would need to resume
+	// from, or -1 when nothing is wrong.
+	FirstCorruptionOffset int64 `json:"first_corruption_offset"`
+	// TrailingJunk lists APE, LYRICS3 and ID3v1 tags appended after the
+	// last frame. Players skip them, so they do not make the file invalid,
+	// but they change the audio hash; TrimTrailingJunk removes them.
+	TrailingJunk []FLACTrailingJunk `json:"trailing_junk,omitempty"`
+}
+
+// flacVerifyMaxBadFrames caps the report size for badly damaged files.
+const flacVerifyMaxBadFrames = 100
+
+func verifyFLAC(filePath string, deep bool) (*FLACVerifyReport, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	layout, err := scanFLACMetadataBlocks(f, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &FLACVerifyReport{
+		Deep:                  deep,
+		FileSize:              info.Size(),
+		AudioOffset:           layout.AudioOffset,
+		BlockCount:            len(layout.Blocks),
+		BlockIssues:           layout.Issues,
+		FirstCorruptionOffset: layout.FirstBadOffset,
+	}
+	markCorrupt := func(offset int64) {
+		if report.FirstCorruptionOffset < 0 || offset < report.FirstCorruptionOffset {
+			report.FirstCorruptionOffset = offset
+		}
+	}
+	if layout.StreamInfo != nil {
+		report.SamplesExpected = int64(layout.StreamInfo[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(layout.StreamInfo[14:18]))
+	}
+
+	report.TrailingJunk = findFLACTrailingJunk(f, layout.AudioOffset, info.Size())
+	audioEnd := info.Size()
+	if len(report.TrailingJunk) > 0 {
+		audioEnd = report.TrailingJunk[0].Offset
+	}
+
+	if _, err := f.Seek(layout.AudioOffset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if !deep {
+		head := make([]byte, flacMaxFrameHeaderLen)
+		n, _ := io.ReadFull(f, head)
+		if _, ok := parseFLACFrameHeader(head[:n]); !ok {
+			report.BadFrames = append(report.BadFrames, FLACBadFrame{Offset: layout.AudioOffset, Reason: "no valid frame header where audio should start"})
+			markCorrupt(layout.AudioOffset)
+		}
+		report.Valid = report.FirstCorruptionOffset < 0
+		return report, nil
+	}
+
+	leading, err := walkFLACFrames(io.LimitReader(f, audioEnd-layout.AudioOffset), layout.AudioOffset, func(frame flacFrameInfo) error {
+		report.FrameCount++
+		report.SamplesFound += int64(frame.Header.BlockSize)
+		if frame.CRCValid {
+			return nil
+		}
+		markCorrupt(frame.Offset)
+		if len(report.BadFrames) < flacVerifyMaxBadFrames {
+			report.BadFrames = append(report.BadFrames, FLACBadFrame{
+				Offset: frame.Offset,
+				Size:   frame.Size,
+				Reason: "frame CRC-16 mismatch",
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan frames: %w", err)
+	}
+
+	if leading > 0 {
+		report.BadFrames = append([]FLACBadFrame{{
+			Offset: layout.AudioOffset,
+			Size:   leading,
+			Reason: "bytes before the first valid frame header",
+		}}, report.BadFrames...)
+		markCorrupt(layout.AudioOffset)
+	}
+	if report.FrameCount == 0 && layout.AudioOffset < audioEnd {
+		markCorrupt(layout.AudioOffset)
+	}
+	if report.SamplesExpected > 0 && report.SamplesFound < report.SamplesExpected {
+		report.BlockIssues = append(report.BlockIssues, fmt.Sprintf(
+			"stream is truncated: %d of %d samples present", report.SamplesFound, report.SamplesExpected))
+		markCorrupt(info.Size())
+	}
+
+	report.Valid = report.FirstCorruptionOffset < 0
+	return report, nil
+}
+
+// VerifyFLAC checks a FLAC file's structure without decoding audio: metadata
+// block lengths and the last-block flag, and with deep set every frame's
+// header CRC-8 and footer CRC-16. The JSON report includes the byte offset
+// of the first corruption so a download can be resumed from there.
+func VerifyFLAC(filePath string, deep bool) (string, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, false, func(localPath string) (string, error) {
+			return VerifyFLAC(localPath, deep)
+		})
+	}
+	report, err := verifyFLAC(filePath, deep)
+	if reason := brokenFLACReason(report, err); reason != "" && GetBackendConfig().DataDir != "" {
+		quarantineFile(filePath, reason, "verify_flac")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
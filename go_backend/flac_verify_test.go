@@ -0,0 +1,167 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeVerifyFixture(t *testing.T, mutate func([]byte) []byte) string {
+	t.Helper()
+	data, err := buildSelfTestFLAC()
+	if err != nil {
+		t.Fatalf("buildSelfTestFLAC: %v", err)
+	}
+	if mutate != nil {
+		data = mutate(data)
+	}
+	path := filepath.Join(t.TempDir(), "verify.flac")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerifyFLACAcceptsCleanFile(t *testing.T) {
+	path := writeVerifyFixture(t, nil)
+
+	for _, deep := range []bool{false, true} {
+		report, err := verifyFLAC(path, deep)
+		if err != nil {
+			t.Fatalf("verifyFLAC(deep=%v): %v", deep, err)
+		}
+		if !report.Valid || report.FirstCorruptionOffset != -1 || report.AudioOffset != 42 {
+			t.Fatalf("deep=%v: unexpected report %+v", deep, report)
+		}
+	}
+
+	report, _ := verifyFLAC(path, true)
+	if report.FrameCount != 3 || report.SamplesFound != report.SamplesExpected {
+		t.Fatalf("frames=%d samples=%d/%d", report.FrameCount, report.SamplesFound, report.SamplesExpected)
+	}
+}
+
+func TestVerifyFLACFindsCorruptFrame(t *testing.T) {
+	var secondFrame int64
+	path := writeVerifyFixture(t, nil)
+	_, err := walkFLACFramesFromFile(path, func(frame flacFrameInfo) {
+		if secondFrame == 0 && frame.Header.Number == 1 {
+			secondFrame = frame.Offset
+		}
+	})
+	if err != nil || secondFrame == 0 {
+		t.Fatalf("could not locate second frame: %v", err)
+	}
+
+	corrupted := writeVerifyFixture(t, func(data []byte) []byte {
+		data[secondFrame+100] ^= 0x55
+		return data
+	})
+	report, err := verifyFLAC(corrupted, true)
+	if err != nil {
+		t.Fatalf("verifyFLAC: %v", err)
+	}
+	if report.Valid || report.FirstCorruptionOffset != secondFrame || len(report.BadFrames) != 1 {
+		t.Fatalf("expected one bad frame at %d, got %+v", secondFrame, report)
+	}
+
+	// The shallow check only looks at structure, so it still passes.
+	if shallow, _ := verifyFLAC(corrupted, false); !shallow.Valid {
+		t.Fatalf("shallow check should not decode frames: %+v", shallow)
+	}
+}
+
+func TestVerifyFLACReportsTruncationAndBlockIssues(t *testing.T) {
+	truncated := writeVerifyFixture(t, func(data []byte) []byte {
+		return data[:len(data)-500]
+	})
+	report, err := verifyFLAC(truncated, true)
+	if err != nil {
+		t.Fatalf("verifyFLAC: %v", err)
+	}
+	if report.Valid || len(report.BadFrames) != 1 || report.BadFrames[0].Offset != report.FirstCorruptionOffset {
+		t.Fatalf("expected the cut-off final frame to be reported: %+v", report)
+	}
+
+	noLastFlag := writeVerifyFixture(t, func(data []byte) []byte {
+		data[4] &^= 0x80
+		return data
+	})
+	report, err = verifyFLAC(noLastFlag, false)
+	if err != nil {
+		t.Fatalf("verifyFLAC: %v", err)
+	}
+	if report.Valid || report.FirstCorruptionOffset != 42 || report.AudioOffset != 42 {
+		t.Fatalf("expected missing last-block flag at 42: %+v", report)
+	}
+
+	badLength := writeVerifyFixture(t, func(data []byte) []byte {
+		data[5], data[6], data[7] = 0xFF, 0xFF, 0xFF
+		return data
+	})
+	report, err = verifyFLAC(badLength, false)
+	if err != nil {
+		t.Fatalf("verifyFLAC: %v", err)
+	}
+	if report.Valid || report.FirstCorruptionOffset != 4 {
+		t.Fatalf("expected oversized block at 4: %+v", report)
+	}
+
+	if _, err := VerifyFLAC(filepath.Join(t.TempDir(), "missing.flac"), true); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
+
+func walkFLACFramesFromFile(path string, fn func(flacFrameInfo)) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	f.Seek(42, 0)
+	return walkFLACFrames(f, 42, func(frame flacFrameInfo) error {
+		fn(frame)
+		return nil
+	})
+}
+
+func TestWalkFLACFramesIgnoresSyncCodeInsideFrame(t *testing.T) {
+	var first flacFrameInfo
+	path := writeVerifyFixture(t, nil)
+	if _, err := walkFLACFramesFromFile(path, func(frame flacFrameInfo) {
+		if frame.Header.Number == 0 {
+			first = frame
+		}
+	}); err != nil || first.Size == 0 {
+		t.Fatalf("could not locate first frame: %v", err)
+	}
+
+	// A header for frame 1000 hidden in the samples of frame 0, whose CRC-16
+	// is fixed up so the frame itself stays valid.
+	sizeCode, _ := flacSampleSizeCode(selfTestBitDepth)
+	fake := []byte{0xFF, 0xF8, 0x70, 1<<4 | sizeCode<<1}
+	fake = appendFLACUTF8Number(fake, 1000)
+	last := selfTestBlockSize - 1
+	fake = append(fake, byte(last>>8), byte(last))
+	fake = append(fake, flacCRC8(fake))
+	withFake := writeVerifyFixture(t, func(data []byte) []byte {
+		frame := data[first.Offset : first.Offset+first.Size]
+		copy(frame[100:], fake)
+		crc := flacCRC16(frame[:len(frame)-2])
+		frame[len(frame)-2], frame[len(frame)-1] = byte(crc>>8), byte(crc)
+		return data
+	})
+
+	var numbers []uint64
+	if _, err := walkFLACFramesFromFile(withFake, func(frame flacFrameInfo) {
+		if !frame.CRCValid {
+			t.Fatalf("frame %d at %d reported corrupt", frame.Header.Number, frame.Offset)
+		}
+		numbers = append(numbers, frame.Header.Number)
+	}); err != nil {
+		t.Fatalf("walkFLACFrames: %v", err)
+	}
+	if len(numbers) != 3 || numbers[0] != 0 || numbers[1] != 1 || numbers[2] != 2 {
+		t.Fatalf("frame numbers = %v", numbers)
+	}
+}
@@ -0,0 +1,151 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TrackMatchInput is one track for MatchTracks. ID is opaque and echoed back
+// so the caller can map results to its own records.
+type TrackMatchInput struct {
+	ID         string `json:"id"`
+	Artist     string `json:"artist"`
+	Title      string `json:"title"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// TrackMatch is a candidate MatchTracks considers the same track.
+// DurationDiffMs is -1 when either duration is unknown.
+type TrackMatch struct {
+	ID             string  `json:"id"`
+	Artist         string  `json:"artist"`
+	Title          string  `json:"title"`
+	Score          float64 `json:"score"`
+	DurationDiffMs int64   `json:"duration_diff_ms"`
+}
+
+// romanNumeralPattern matches the Roman numerals 2 to 39. Single letters are
+// left out, since "I" and "X" are more often words and names.
+var romanNumeralPattern = regexp.MustCompile(`^(?:x{0,3})(?:ix|iv|v?i{0,3})$`)
+
+// trackKeyNumbers returns the numbers in a normalizeTrackMatchKey string,
+// digits and Roman numerals alike, sorted: "pt 2" and "part ii" both give
+// [2].
+func trackKeyNumbers(key string) []int {
+	var numbers []int
+	for _, word := range strings.Fields(key) {
+		if n, err := strconv.Atoi(word); err == nil {
+			numbers = append(numbers, n)
+		} else if len(word) > 1 && romanNumeralPattern.MatchString(word) {
+			numbers = append(numbers, romanNumeralValue(word))
+		}
+	}
+	slices.Sort(numbers)
+	return numbers
+}
+
+var romanDigitValues = map[byte]int{'i': 1, 'v': 5, 'x': 10}
+
+func romanNumeralValue(numeral string) int {
+	values := romanDigitValues
+	total := 0
+	for i := 0; i < len(numeral); i++ {
+		v := values[numeral[i]]
+		if i+1 < len(numeral) && values[numeral[i+1]] > v {
+			v = -v
+		}
+		total += v
+	}
+	return total
+}
+
+// trackKeySimilarity scores two normalizeTrackMatchKey strings between 0
+// and 1: the normalized Levenshtein similarity of the keys, or of their
+// words sorted when that is higher, so "A & B" matches "B & A". Keys whose
+// numbers differ score 0, since "Pt. 1" and "Pt. 3" or "Interlude 1" and
+// "Interlude 2" are different tracks however alike the rest is.
+func trackKeySimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	if !slices.Equal(trackKeyNumbers(a), trackKeyNumbers(b)) {
+		return 0
+	}
+	sortWords := func(s string) string {
+		words := strings.Fields(s)
+		slices.Sort(words)
+		return strings.Join(words, " ")
+	}
+	return max(calculateStringSimilarity(a, b), calculateStringSimilarity(sortWords(a), sortWords(b)))
+}
+
+// durationDiffMs returns how far apart two durations are, or -1 when either
+// is unknown.
+func durationDiffMs(a, b int64) int64 {
+	if a <= 0 || b <= 0 {
+		return -1
+	}
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// fuzzyTrackMatch reports whether two tracks are the same by key similarity
+// and duration, with the similarity score.
+func fuzzyTrackMatch(keyA, keyB string, durationA, durationB int64, cfg BackendConfig) (float64, bool) {
+	if diff := durationDiffMs(durationA, durationB); diff > cfg.FuzzyMatchToleranceMs {
+		return 0, false
+	}
+	score := trackKeySimilarity(keyA, keyB)
+	return score, score >= cfg.FuzzyMatchThreshold
+}
+
+// MatchTracks answers the downloader's "already have it?" check for tracks
+// without an ISRC. candidatesJSON is {"track": TrackMatchInput, "candidates":
+// [TrackMatchInput...]}; the result lists the candidates whose normalized
+// "artist – title" reaches FuzzyMatchThreshold and whose duration is within
+// FuzzyMatchToleranceMs, best first. Featured artists, case, punctuation and
+// remaster suffixes are ignored.
+func MatchTracks(candidatesJSON string) (string, error) {
+	var input struct {
+		Track      TrackMatchInput   `json:"track"`
+		Candidates []TrackMatchInput `json:"candidates"`
+	}
+	if err := json.Unmarshal([]byte(candidatesJSON), &input); err != nil {
+		return "", fmt.Errorf("invalid match candidates: %w", err)
+	}
+	if strings.TrimSpace(input.Track.Title) == "" {
+		return "", fmt.Errorf("track title is empty")
+	}
+
+	cfg := GetBackendConfig()
+	key := normalizeTrackMatchKey(input.Track.Artist, input.Track.Title)
+	matches := []TrackMatch{}
+	for _, candidate := range input.Candidates {
+		score, ok := fuzzyTrackMatch(key, normalizeTrackMatchKey(candidate.Artist, candidate.Title),
+			input.Track.DurationMs, candidate.DurationMs, cfg)
+		if !ok {
+			continue
+		}
+		matches = append(matches, TrackMatch{
+			ID:             candidate.ID,
+			Artist:         candidate.Artist,
+			Title:          candidate.Title,
+			Score:          score,
+			DurationDiffMs: durationDiffMs(input.Track.DurationMs, candidate.DurationMs),
+		})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+
+	jsonBytes, err := json.Marshal(matches)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
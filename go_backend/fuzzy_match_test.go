@@ -0,0 +1,28 @@
+package gobackend
+
+import "testing"
+
+func TestMatchTracks(t *testing.T) {
+	input := `{
+		"track": {"artist": "The Beatles feat. Nobody", "title": "Yesterday", "duration_ms": 125000},
+		"candidates": [
+			{"id": "remaster", "artist": "Beatles", "title": "Yesterday - Remastered 2009", "duration_ms": 126500},
+			{"id": "exact", "artist": "The Beatles", "title": "Yesterday", "duration_ms": 125000},
+			{"id": "long", "artist": "The Beatles", "title": "Yesterday", "duration_ms": 190000},
+			{"id": "cover", "artist": "Frank Sinatra", "title": "Yesterday", "duration_ms": 125000},
+			{"id": "typo", "artist": "The Beatels", "title": "Yesterday"}
+		]
+	}`
+	out, err := MatchTracks(input)
+	matches := mustDecodeJSON[[]TrackMatch](t, out, err)
+	if len(matches) != 3 || matches[0].Score != 1 || matches[2].ID != "typo" {
+		t.Fatalf("matches = %+v", matches)
+	}
+	if matches[2].DurationDiffMs != -1 || (matches[0].ID == "remaster" && matches[0].DurationDiffMs != 1500) {
+		t.Fatalf("duration diffs = %+v", matches)
+	}
+
+	if _, err := MatchTracks(`{"track": {"artist": "A"}}`); err == nil {
+		t.Fatal("expected error for a track without title")
+	}
+}
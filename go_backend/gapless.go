@@ -0,0 +1,129 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// gaplessTagKeys are the comments, with spaces, underscores and dashes
+// removed, that carry encoder delay and padding. ffmpeg and iTunes-derived
+// files use ITUNSMPB; other tools write the delay and padding as decimals.
+var gaplessTagKeys = map[string]bool{
+	"ITUNSMPB":       true,
+	"ITUNPGAP":       true,
+	"ENCODERDELAY":   true,
+	"ENCODERPADDING": true,
+}
+
+var gaplessKeyReplacer = strings.NewReplacer(" ", "", "_", "", "-", "")
+
+// isGaplessTagKey reports whether an upper-cased comment key carries gapless
+// playback info. These comments describe the audio rather than the release,
+// so every embed keeps them.
+func isGaplessTagKey(key string) bool {
+	return gaplessTagKeys[gaplessKeyReplacer.Replace(key)]
+}
+
+// gaplessTags picks the gapless comments out of tags as returned by GetTags,
+// first value per key. ReadFileMetadata exposes them as extra tags.
+func gaplessTags(tags map[string][]string) map[string]string {
+	gapless := make(map[string]string)
+	for key, values := range tags {
+		if isGaplessTagKey(key) && len(values) > 0 {
+			gapless[key] = values[0]
+		}
+	}
+	return gapless
+}
+
+// GaplessInfo is the encoder delay and padding of a file, in samples.
+// Derivable is false when the file has no gapless comments or none could be
+// parsed; the sample counts are then zero and nothing is guessed.
+type GaplessInfo struct {
+	Derivable       bool   `json:"derivable"`
+	LeadingSamples  int64  `json:"leading_samples"`
+	TrailingSamples int64  `json:"trailing_samples"`
+	ValidSamples    int64  `json:"valid_samples,omitempty"`
+	Source          string `json:"source,omitempty"`
+	// Tags holds the gapless comments verbatim.
+	Tags map[string]string `json:"tags"`
+}
+
+// parseITunSMPB reads an iTunSMPB value: space-separated hex fields of which
+// the second is the encoder delay, the third the padding and the fourth the
+// number of valid samples.
+func parseITunSMPB(value string) (leading, trailing, valid int64, ok bool) {
+	fields := strings.Fields(value)
+	if len(fields) < 4 {
+		return 0, 0, 0, false
+	}
+	parsed := make([]int64, 3)
+	for i, field := range fields[1:4] {
+		n, err := strconv.ParseInt(field, 16, 64)
+		if err != nil || n < 0 {
+			return 0, 0, 0, false
+		}
+		parsed[i] = n
+	}
+	return parsed[0], parsed[1], parsed[2], true
+}
+
+func gaplessInfoFromTags(tags map[string]string) GaplessInfo {
+	info := GaplessInfo{Tags: map[string]string{}}
+	var delay, padding string
+	var delayKey, paddingKey string
+	for key, value := range tags {
+		info.Tags[key] = value
+		switch gaplessKeyReplacer.Replace(key) {
+		case "ITUNSMPB":
+			if leading, trailing, valid, ok := parseITunSMPB(value); ok {
+				info.Derivable = true
+				info.LeadingSamples, info.TrailingSamples, info.ValidSamples = leading, trailing, valid
+				info.Source = key
+			}
+		case "ENCODERDELAY":
+			delay, delayKey = value, key
+		case "ENCODERPADDING":
+			padding, paddingKey = value, key
+		}
+	}
+	if info.Derivable || (delay == "" && padding == "") {
+		return info
+	}
+
+	// Decimal delay/padding pairs; a missing half is left at zero only when
+	// the other half parsed.
+	leading, leadErr := strconv.ParseInt(strings.TrimSpace(delay), 10, 64)
+	trailing, trailErr := strconv.ParseInt(strings.TrimSpace(padding), 10, 64)
+	if delay != "" && (leadErr != nil || leading < 0) || padding != "" && (trailErr != nil || trailing < 0) {
+		return info
+	}
+	info.Derivable = true
+	if delay != "" {
+		info.LeadingSamples = leading
+		info.Source = delayKey
+	}
+	if padding != "" {
+		info.TrailingSamples = trailing
+		if info.Source == "" {
+			info.Source = paddingKey
+		}
+	}
+	return info
+}
+
+// ParseGaplessInfo returns the leading and trailing padding samples recorded
+// in a FLAC file's gapless comments, when they can be derived. It only reads
+// what the encoder wrote; nothing is computed from the audio.
+func ParseGaplessInfo(filePath string) (string, error) {
+	tags, err := GetTags(filePath, nil)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(gaplessInfoFromTags(gaplessTags(tags)))
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
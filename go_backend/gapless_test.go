@@ -0,0 +1,46 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseGaplessInfoFromITunSMPB(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	if err := SetTags(path, []TagPair{{Key: "iTunSMPB", Value: " 00000000 00000840 000001CA 00000000000E8BF6 00000000"}}, false); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+
+	raw, err := ParseGaplessInfo(path)
+	info := mustDecodeJSON[GaplessInfo](t, raw, err)
+	if !info.Derivable || info.LeadingSamples != 0x840 || info.TrailingSamples != 0x1CA || info.ValidSamples != 0xE8BF6 || info.Source != "ITUNSMPB" {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+
+	raw, err = ReadFileMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadFileMetadata: %v", err)
+	}
+	var meta struct {
+		ExtraTags map[string]string `json:"extra_tags"`
+	}
+	if err := json.Unmarshal([]byte(raw), &meta); err != nil || meta.ExtraTags["ITUNSMPB"] == "" {
+		t.Fatalf("gapless tag missing from extra tags: %s", raw)
+	}
+}
+
+func TestGaplessInfoFromDecimalKeys(t *testing.T) {
+	info := gaplessInfoFromTags(map[string]string{"ENCODER DELAY": "576", "ENCODER_PADDING": "1200"})
+	if !info.Derivable || info.LeadingSamples != 576 || info.TrailingSamples != 1200 {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+
+	info = gaplessInfoFromTags(map[string]string{"ENCODERDELAY": "unknown"})
+	if info.Derivable || info.LeadingSamples != 0 || info.Tags["ENCODERDELAY"] != "unknown" {
+		t.Fatalf("unparseable value should not be derivable: %+v", info)
+	}
+
+	if info := gaplessInfoFromTags(nil); info.Derivable {
+		t.Fatalf("no tags should not be derivable: %+v", info)
+	}
+}
@@ -0,0 +1,271 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// genreSeparator joins the genres of a multi-genre GENRE value.
+const genreSeparator = "; "
+
+// defaultGenreAliases maps folded spellings (see foldGenre) to the genre
+// NormalizeGenres writes. Genres not listed are kept as spelled.
+var defaultGenreAliases = map[string]string{
+	"hiphop":           "Hip-Hop",
+	"triphop":          "Trip-Hop",
+	"rnb":              "R&B",
+	"r&b":              "R&B",
+	"rhythmandblues":   "R&B",
+	"rhythm&blues":     "R&B",
+	"drumandbass":      "Drum & Bass",
+	"drum&bass":        "Drum & Bass",
+	"drumnbass":        "Drum & Bass",
+	"dnb":              "Drum & Bass",
+	"rockandroll":      "Rock & Roll",
+	"rock&roll":        "Rock & Roll",
+	"rocknroll":        "Rock & Roll",
+	"synthpop":         "Synth-Pop",
+	"kpop":             "K-Pop",
+	"jpop":             "J-Pop",
+	"lofi":             "Lo-Fi",
+	"postrock":         "Post-Rock",
+	"postpunk":         "Post-Punk",
+	"newwave":          "New Wave",
+	"hardrock":         "Hard Rock",
+	"heavymetal":       "Heavy Metal",
+	"altrock":          "Alternative Rock",
+	"alternativerock":  "Alternative Rock",
+	"indierock":        "Indie Rock",
+	"indiepop":         "Indie Pop",
+	"singersongwriter": "Singer-Songwriter",
+	"ost":              "Soundtrack",
+	"soundtrack":       "Soundtrack",
+	"electronic":       "Electronic",
+	"electronica":      "Electronica",
+}
+
+var genreFoldReplacer = strings.NewReplacer(" ", "", "-", "", "_", "", ".", "")
+
+// foldGenre is the spelling-insensitive key of a genre: lower case without
+// spaces, dashes, underscores or dots, so "Hip Hop", "Hip-Hop" and "HipHop"
+// are one genre.
+func foldGenre(genre string) string {
+	return genreFoldReplacer.Replace(strings.ToLower(strings.TrimSpace(genre)))
+}
+
+// genreAliases merges BackendConfig GenreAliases over the built-in table.
+func genreAliases() map[string]string {
+	aliases := make(map[string]string, len(defaultGenreAliases))
+	for folded, canonical := range defaultGenreAliases {
+		aliases[folded] = canonical
+	}
+	for spelling, canonical := range GetBackendConfig().GenreAliases {
+		folded := foldGenre(spelling)
+		if canonical = strings.TrimSpace(canonical); canonical == "" {
+			delete(aliases, folded)
+			continue
+		}
+		aliases[folded] = canonical
+	}
+	return aliases
+}
+
+// normalizeGenreValues splits each GENRE value on ";" and "/", maps every
+// genre through aliases, drops genres already seen in this or an earlier
+// value, and rejoins the rest with genreSeparator. mapped[i] is the new
+// form of values[i], empty when nothing of it is left; out holds the
+// non-empty ones.
+func normalizeGenreValues(values []string, aliases map[string]string) (out, mapped []string) {
+	seen := make(map[string]bool)
+	mapped = make([]string, len(values))
+	for i, value := range values {
+		var genres []string
+		for _, part := range strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == '/' }) {
+			genre := strings.TrimSpace(part)
+			if genre == "" {
+				continue
+			}
+			if canonical, ok := aliases[foldGenre(genre)]; ok {
+				genre = canonical
+			}
+			if key := foldGenre(genre); !seen[key] {
+				seen[key] = true
+				genres = append(genres, genre)
+			}
+		}
+		if len(genres) > 0 {
+			mapped[i] = strings.Join(genres, genreSeparator)
+			out = append(out, mapped[i])
+		}
+	}
+	return out, mapped
+}
+
+// GenreValueChange counts how often a GENRE value was (or would be)
+// rewritten to To across the library. An empty To means the value was
+// dropped as a duplicate.
+type GenreValueChange struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int    `json:"count"`
+}
+
+type GenreFileChange struct {
+	Path   string   `json:"path"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+type GenreNormalizationReport struct {
+	Root               string             `json:"root"`
+	DryRun             bool               `json:"dry_run"`
+	Checked            int                `json:"checked"`
+	Changed            int                `json:"changed"`
+	Failed             int                `json:"failed"`
+	QuarantinedSkipped int                `json:"quarantined_skipped"`
+	Skipped            int                `json:"skipped"`
+	IO                 WriteStats         `json:"io"`
+	Values             []GenreValueChange `json:"values"`
+	Files              []GenreFileChange  `json:"files"`
+	ChangeReportOutput
+}
+
+// normalizeFileGenres rewrites the GENRE values of filePath and returns
+// them with their normalized forms, as normalizeGenreValues maps them, and
+// how the file was written.
+func normalizeFileGenres(filePath string, aliases map[string]string, dryRun bool) (before, mapped []string, stats flacSaveStats, err error) {
+	if dryRun {
+		raw, err := readVorbisCommentList(filePath)
+		if err != nil {
+			return nil, nil, flacSaveStats{}, err
+		}
+		before = newVorbisCommentMap(raw).getValues("GENRE")
+		_, mapped = normalizeGenreValues(before, aliases)
+		return before, mapped, flacSaveStats{}, nil
+	}
+
+	if err := checkWriteAllowed(filePath); err != nil {
+		return nil, nil, flacSaveStats{}, err
+	}
+	stats, err = editVorbisCommentsStats(filePath, "normalize_genres", func(comments *vorbisCommentMap) bool {
+		before = comments.getValues("GENRE")
+		var normalized []string
+		normalized, mapped = normalizeGenreValues(before, aliases)
+		if slices.Equal(before, mapped) {
+			return false
+		}
+		if len(normalized) == 0 {
+			comments.remove("GENRE")
+		} else {
+			comments.setValues("GENRE", normalized)
+		}
+		return true
+	})
+	return before, mapped, stats, err
+}
+
+// NormalizeGenres rewrites the GENRE values of every FLAC file under
+// rootPath to canonical spellings from the built-in table and BackendConfig
+// GenreAliases. Values holding several genres separated by ";" or "/" are
+// split, normalized, deduplicated and rejoined with "; ". The report counts
+// each distinct value change and lists the files that changed (or would,
+// with dryRun) or failed; other formats are counted as skipped.
+func NormalizeGenres(rootPath string, dryRun bool) (string, error) {
+	return normalizeGenres(rootPath, dryRun, nil, "")
+}
+
+// NormalizeGenresWithReport is NormalizeGenres that also reports the old and
+// new GENRE values per file (see ChangeReportOutput), written as JSON to
+// reportPath and as text next to it when reportPath is not empty.
+func NormalizeGenresWithReport(rootPath string, dryRun bool, reportPath string) (string, error) {
+	return normalizeGenres(rootPath, dryRun, newChangeReport("normalize_genres", rootPath, dryRun), reportPath)
+}
+
+func normalizeGenres(rootPath string, dryRun bool, changes *changeReport, reportPath string) (string, error) {
+	if strings.TrimSpace(rootPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(rootPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+	if !dryRun {
+		if err := checkWriteAllowed(rootPath); err != nil {
+			return "", err
+		}
+	}
+
+	files, quarantined, err := collectWritableLibraryFiles(rootPath, nil)
+	if err != nil {
+		return "", err
+	}
+	report := GenreNormalizationReport{Root: rootPath, DryRun: dryRun, Values: []GenreValueChange{}, Files: []GenreFileChange{}}
+	report.QuarantinedSkipped = quarantined
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file.path), ".flac") {
+			paths = append(paths, file.path)
+		} else {
+			report.Skipped++
+		}
+	}
+	sort.Strings(paths)
+
+	started := time.Now()
+	aliases := genreAliases()
+	counts := make(map[GenreValueChange]int)
+	for _, path := range paths {
+		report.Checked++
+		before, mapped, stats, err := normalizeFileGenres(path, aliases, dryRun)
+		if err != nil {
+			report.Failed++
+			report.Files = append(report.Files, GenreFileChange{Path: path, Error: err.Error()})
+			changes.failed(path, err)
+			continue
+		}
+		if slices.Equal(before, mapped) {
+			continue
+		}
+		report.Changed++
+		report.IO.add(stats)
+		after := slices.DeleteFunc(slices.Clone(mapped), func(v string) bool { return v == "" })
+		report.Files = append(report.Files, GenreFileChange{Path: path, Before: before, After: after})
+		changes.add(FileChangeEntry{Path: path, Fields: []FieldChange{{Field: "GENRE", Old: before, New: after}}})
+		for i, value := range before {
+			if mapped[i] != value {
+				counts[GenreValueChange{From: value, To: mapped[i]}]++
+			}
+		}
+	}
+
+	report.IO.DurationMs = time.Since(started).Milliseconds()
+	report.ChangeReportOutput = changes.finish(reportPath)
+
+	for change, count := range counts {
+		change.Count = count
+		report.Values = append(report.Values, change)
+	}
+	sort.Slice(report.Values, func(i, j int) bool {
+		if report.Values[i].Count != report.Values[j].Count {
+			return report.Values[i].Count > report.Values[j].Count
+		}
+		return report.Values[i].From < report.Values[j].From
+	})
+
+	GoLog("[Genres] Normalized genres in %d of %d files under %s (dry run: %v)\n",
+		report.Changed, report.Checked, rootPath, dryRun)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,73 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestNormalizeGenreValues(t *testing.T) {
+	aliases := genreAliases()
+	cases := []struct {
+		in, want []string
+	}{
+		{[]string{"Hip Hop"}, []string{"Hip-Hop"}},
+		{[]string{"HipHop; rnb / Jazz"}, []string{"Hip-Hop; R&B; Jazz"}},
+		{[]string{"hip-hop;Hip Hop"}, []string{"Hip-Hop"}},
+		{[]string{"Rock", "rock/Drum n Bass"}, []string{"Rock", "Drum & Bass"}},
+		{[]string{"Jazz", "jazz"}, []string{"Jazz"}},
+		{[]string{" ; "}, nil},
+	}
+	for _, c := range cases {
+		if got, _ := normalizeGenreValues(c.in, aliases); !slices.Equal(got, c.want) {
+			t.Fatalf("normalizeGenreValues(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestNormalizeGenresAcrossLibrary(t *testing.T) {
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	if err := Configure(`{"genre_aliases":{"Hip Hop":"Hip Hop","Chillhop":"Lo-Fi"}}`); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+
+	root := t.TempDir()
+	a := writeConsistencyFixture(t, root, "a.flac", Metadata{Title: "A", Genre: "HipHop"})
+	b := writeConsistencyFixture(t, root, "b.flac", Metadata{Title: "B", Genre: "hip-hop/Chill hop"})
+	c := writeConsistencyFixture(t, root, "c.flac", Metadata{Title: "C", Genre: "Hip Hop"})
+	before := mustReadFile(t, a)
+
+	decode := func(out string, err error) GenreNormalizationReport {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("NormalizeGenres: %v", err)
+		}
+		var report GenreNormalizationReport
+		if err := json.Unmarshal([]byte(out), &report); err != nil {
+			t.Fatalf("decode report: %v", err)
+		}
+		return report
+	}
+
+	report := decode(NormalizeGenres(root, true))
+	if report.Checked != 3 || report.Changed != 2 || len(report.Values) != 2 {
+		t.Fatalf("unexpected dry run: %+v", report)
+	}
+	if string(mustReadFile(t, a)) != string(before) {
+		t.Fatal("dry run modified a file")
+	}
+
+	report = decode(NormalizeGenres(root, false))
+	if report.Changed != 2 || report.Failed != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	for path, want := range map[string]string{a: "Hip Hop", b: "Hip Hop; Lo-Fi", c: "Hip Hop"} {
+		if metadata, err := ReadMetadata(path); err != nil || metadata.Genre != want {
+			t.Fatalf("%s: genre %q (%v), want %q", path, metadata.Genre, err, want)
+		}
+	}
+	if report = decode(NormalizeGenres(root, false)); report.Changed != 0 {
+		t.Fatalf("second run should change nothing: %+v", report)
+	}
+}
@@ -0,0 +1,180 @@
+package gobackend
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HostRateLimit is a token bucket for one host: RequestsPerSecond refill
+// rate and Burst capacity. A non-positive rate removes the limit.
+type HostRateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+}
+
+// defaultHostRateLimits follows each service's published or observed
+// tolerance. Hosts not listed here are not paced.
+var defaultHostRateLimits = map[string]HostRateLimit{
+	"lrclib.net":          {RequestsPerSecond: 2, Burst: 4},
+	"lyrics.paxsenix.org": {RequestsPerSecond: 2, Burst: 4},
+	"musicbrainz.org":     {RequestsPerSecond: 1, Burst: 1},
+	"api.deezer.com":      {RequestsPerSecond: 8, Burst: 10},
+	"itunes.apple.com":    {RequestsPerSecond: 0.3, Burst: 3},
+}
+
+type hostTokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newHostTokenBucket(limit HostRateLimit) *hostTokenBucket {
+	burst := float64(max(limit.Burst, 1))
+	return &hostTokenBucket{
+		rate:   limit.RequestsPerSecond,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token, going into debt when the bucket is empty, and
+// returns how long the caller must wait before using it. Debt makes
+// concurrent callers queue in arrival order without holding the lock
+// while they sleep.
+func (b *hostTokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancelReservation returns a token taken by reserve when the caller gave up.
+func (b *hostTokenBucket) cancelReservation() {
+	b.mu.Lock()
+	b.tokens = min(b.burst, b.tokens+1)
+	b.mu.Unlock()
+}
+
+var (
+	hostRateLimitersMu sync.RWMutex
+	hostRateLimiters   = buildHostRateLimiters(nil)
+)
+
+func normalizeRateLimitHost(host string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(host)), "www.")
+}
+
+func buildHostRateLimiters(overrides map[string]HostRateLimit) map[string]*hostTokenBucket {
+	limits := make(map[string]HostRateLimit, len(defaultHostRateLimits)+len(overrides))
+	for host, limit := range defaultHostRateLimits {
+		limits[host] = limit
+	}
+	for host, limit := range overrides {
+		limits[normalizeRateLimitHost(host)] = limit
+	}
+
+	buckets := make(map[string]*hostTokenBucket, len(limits))
+	for host, limit := range limits {
+		if limit.RequestsPerSecond <= 0 {
+			continue
+		}
+		buckets[host] = newHostTokenBucket(limit)
+	}
+	return buckets
+}
+
+func applyHostRateLimits(overrides map[string]HostRateLimit) {
+	buckets := buildHostRateLimiters(overrides)
+	hostRateLimitersMu.Lock()
+	hostRateLimiters = buckets
+	hostRateLimitersMu.Unlock()
+}
+
+func hostRateLimiterFor(host string) *hostTokenBucket {
+	host = normalizeRateLimitHost(host)
+	hostRateLimitersMu.RLock()
+	defer hostRateLimitersMu.RUnlock()
+	return hostRateLimiters[host]
+}
+
+// waitForHostSlot blocks until host may be contacted again or ctx ends.
+func waitForHostSlot(ctx context.Context, host string) error {
+	bucket := hostRateLimiterFor(host)
+	if bucket == nil {
+		return nil
+	}
+
+	delay := bucket.reserve(time.Now())
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		bucket.cancelReservation()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// hostSlotReservation marks a request whose host slot was taken by
+// reserveHostSlot. It is consumed by the first round trip to that host, so
+// redirects and later requests sharing the context still wait their turn.
+type hostSlotReservation struct {
+	host string
+	used atomic.Bool
+}
+
+type hostSlotReservationKey struct{}
+
+// reserveHostSlot waits for a slot on req's host before the request is
+// handed to an http.Client. Waiting inside the transport would count the
+// time spent queued against the client's Timeout, failing requests that
+// never reached the network.
+func reserveHostSlot(req *http.Request) (*http.Request, error) {
+	host := normalizeRateLimitHost(req.URL.Hostname())
+	if hostRateLimiterFor(host) == nil {
+		return req, nil
+	}
+	if err := waitForHostSlot(req.Context(), host); err != nil {
+		return nil, err
+	}
+	reservation := &hostSlotReservation{host: host}
+	return req.WithContext(context.WithValue(req.Context(), hostSlotReservationKey{}, reservation)), nil
+}
+
+// takeHostSlotReservation reports whether req already holds a slot for its
+// host from reserveHostSlot, consuming the reservation.
+func takeHostSlotReservation(req *http.Request) bool {
+	reservation, ok := req.Context().Value(hostSlotReservationKey{}).(*hostSlotReservation)
+	if !ok || reservation.host != normalizeRateLimitHost(req.URL.Hostname()) {
+		return false
+	}
+	return reservation.used.CompareAndSwap(false, true)
+}
+
+// estimateHostPacing returns the minimum time the limiter needs to let
+// requests more calls through to host, ignoring tokens already banked.
+func estimateHostPacing(host string, requests int) time.Duration {
+	bucket := hostRateLimiterFor(host)
+	if bucket == nil || requests <= 0 {
+		return 0
+	}
+	return time.Duration(float64(requests) / bucket.rate * float64(time.Second))
+}
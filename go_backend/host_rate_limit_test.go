@@ -0,0 +1,206 @@
+package gobackend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHostTokenBucketPacesConcurrentCallers(t *testing.T) {
+	withBackendConfig(t, func(cfg *BackendConfig) {
+		cfg.HostRateLimits = map[string]HostRateLimit{
+			"paced.example": {RequestsPerSecond: 20, Burst: 2},
+		}
+	})
+
+	const callers = 8
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := waitForHostSlot(context.Background(), "paced.example"); err != nil {
+				t.Errorf("waitForHostSlot: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Two calls ride the burst; the other six need 6/20 s = 300 ms. Allow
+	// generous slack for slow CI machines in both directions.
+	elapsed := time.Since(start)
+	if elapsed < 200*time.Millisecond || elapsed > 3*time.Second {
+		t.Fatalf("8 calls at 20/s with burst 2 took %v", elapsed)
+	}
+}
+
+func TestHostRateLimitsDefaultsAndOverrides(t *testing.T) {
+	if hostRateLimiterFor("unlisted.example") != nil {
+		t.Fatal("unlisted hosts should not be paced")
+	}
+	if hostRateLimiterFor("www.MusicBrainz.org") == nil {
+		t.Fatal("expected default limit for musicbrainz.org")
+	}
+
+	withBackendConfig(t, func(cfg *BackendConfig) {})
+	if err := Configure(`{"host_rate_limits":{"musicbrainz.org":{"requests_per_second":0}}}`); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if hostRateLimiterFor("musicbrainz.org") != nil {
+		t.Fatal("zero rate should lift the default limit")
+	}
+	if hostRateLimiterFor("lrclib.net") == nil {
+		t.Fatal("other defaults should remain")
+	}
+}
+
+func TestWaitForHostSlotHonorsCancellation(t *testing.T) {
+	withBackendConfig(t, func(cfg *BackendConfig) {
+		cfg.HostRateLimits = map[string]HostRateLimit{
+			"slow.example": {RequestsPerSecond: 0.01, Burst: 1},
+		}
+	})
+
+	if err := waitForHostSlot(context.Background(), "slow.example"); err != nil {
+		t.Fatalf("first call should use the burst: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := waitForHostSlot(ctx, "slow.example"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+}
+
+func TestQueuedRequestWaitsOutsideClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	withBackendConfig(t, func(cfg *BackendConfig) {
+		cfg.FetchMaxAttempts = 1
+		cfg.HostRateLimits = map[string]HostRateLimit{
+			serverURL.Hostname(): {RequestsPerSecond: 4, Burst: 1},
+		}
+	})
+
+	// The second request queues for 250 ms behind the first, longer than
+	// the client timeout, and must still go through.
+	client := NewMetadataHTTPClient(150 * time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := doFetchWithRetry(client, req, "paced test")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("second request was not paced: %v", elapsed)
+	}
+}
+
+func TestFetchLyricsBatchReportsProgressAndETA(t *testing.T) {
+	original := fetchLyricsBatchItem
+	defer func() { fetchLyricsBatchItem = original }()
+
+	var mu sync.Mutex
+	seen := 0
+	fetchLyricsBatchItem = func(ctx context.Context, item LyricsBatchItem) error {
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		seen++
+		if item.TrackName == "bad" {
+			return fmt.Errorf("lyrics not found")
+		}
+		return nil
+	}
+
+	items := []LyricsBatchItem{{TrackName: "a", OutputPath: "a.lrc"}, {TrackName: "bad", OutputPath: "b.lrc"}, {TrackName: "c", OutputPath: "c.lrc"}}
+	itemsJSON, _ := json.Marshal(items)
+
+	resultJSON, err := FetchLyricsBatch(string(itemsJSON), 2)
+	results := mustDecodeJSON[[]LyricsBatchResult](t, resultJSON, err)
+	if len(results) != 3 || !results[0].Success || results[1].Success || results[1].OutputPath != "b.lrc" {
+		t.Fatalf("unexpected results: %s", resultJSON)
+	}
+
+	var progress LyricsBatchProgress
+	_ = json.Unmarshal([]byte(GetLyricsBatchProgress()), &progress)
+	if !progress.IsComplete || progress.Completed != 3 || progress.Failed != 1 || progress.ETASeconds != 0 {
+		t.Fatalf("unexpected progress: %+v", progress)
+	}
+	if seen != 3 {
+		t.Fatalf("expected 3 fetches, got %d", seen)
+	}
+}
+
+func TestCancelLyricsBatchAbortsInFlightFetches(t *testing.T) {
+	original := fetchLyricsBatchItem
+	defer func() { fetchLyricsBatchItem = original }()
+
+	started := make(chan struct{}, 2)
+	fetchLyricsBatchItem = func(ctx context.Context, item LyricsBatchItem) error {
+		started <- struct{}{}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+			return nil
+		}
+	}
+
+	items := []LyricsBatchItem{{TrackName: "a", OutputPath: "a.lrc"}, {TrackName: "b", OutputPath: "b.lrc"}, {TrackName: "c", OutputPath: "c.lrc"}}
+	itemsJSON, _ := json.Marshal(items)
+
+	type batchResult struct {
+		json string
+		err  error
+	}
+	done := make(chan batchResult, 1)
+	go func() {
+		resultJSON, err := FetchLyricsBatch(string(itemsJSON), 2)
+		done <- batchResult{resultJSON, err}
+	}()
+
+	<-started
+	<-started
+	CancelLyricsBatch()
+
+	select {
+	case res := <-done:
+		results := mustDecodeJSON[[]LyricsBatchResult](t, res.json, res.err)
+		for _, result := range results {
+			if result.Success || result.Error != ErrDownloadCancelled.Error() {
+				t.Fatalf("expected every item cancelled: %s", res.json)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("CancelLyricsBatch did not abort the in-flight fetches")
+	}
+}
+
+func TestLyricsBatchETA(t *testing.T) {
+	// Before any item finishes, lrclib's 2 req/s default bounds the batch.
+	eta := newLyricsBatchETA(10, 1)
+	if got := eta.remaining(); got != 5*time.Second {
+		t.Fatalf("ETA from rate limit = %v", got)
+	}
+	for range 5 {
+		eta.done(0, 2*time.Second)
+	}
+	if got := eta.remaining(); got != 10*time.Second {
+		t.Fatalf("ETA from throughput = %v", got)
+	}
+}
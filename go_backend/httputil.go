@@ -17,6 +17,9 @@ import (
 )
 
 func userAgentForURL(u *url.URL) string {
+	if ua := configuredUserAgent(); ua != "" {
+		return ua
+	}
 	if u == nil {
 		return getRandomUserAgent()
 	}
@@ -182,6 +185,36 @@ func applyTLSCompatibility(transport *http.Transport, insecureTLS bool) {
 	transport.TLSClientConfig = newTLSCompatibilityConfig(insecureTLS)
 }
 
+// checkRequestPolicy applies the rules every outgoing request obeys,
+// whichever transport sends it: the wifi-only block and the per-host rate
+// limit. Requests that went through reserveHostSlot already hold their slot.
+func checkRequestPolicy(req *http.Request) error {
+	if err := checkNetworkAllowed(); err != nil {
+		return err
+	}
+	if req != nil && req.URL != nil && !takeHostSlotReservation(req) {
+		return waitForHostSlot(req.Context(), req.URL.Hostname())
+	}
+	return nil
+}
+
+// policyTransport applies checkRequestPolicy in front of base, for clients
+// that must not get the allow_http downgrade of compatibilityTransport.
+type policyTransport struct {
+	base http.RoundTripper
+}
+
+func newPolicyTransport(base http.RoundTripper) http.RoundTripper {
+	return &policyTransport{base: base}
+}
+
+func (t *policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := checkRequestPolicy(req); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
 type compatibilityTransport struct {
 	base http.RoundTripper
 }
@@ -191,6 +224,9 @@ func newCompatibilityTransport(base http.RoundTripper) http.RoundTripper {
 }
 
 func (t *compatibilityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := checkRequestPolicy(req); err != nil {
+		return nil, err
+	}
 	if req == nil || req.URL == nil {
 		return t.base.RoundTrip(req)
 	}
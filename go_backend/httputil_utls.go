@@ -3,32 +3,33 @@
 package gobackend
 
 import (
+	"bufio"
 	"context"
 	"crypto/tls"
+	"encoding/base64"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	utls "github.com/refraction-networking/utls"
 	"golang.org/x/net/http2"
+	"golang.org/x/net/proxy"
 )
 
-type utlsTransport struct {
-	dialer *net.Dialer
-}
+type utlsTransport struct{}
 
 func newUTLSTransport() *utlsTransport {
-	return &utlsTransport{
-		dialer: &net.Dialer{
-			Timeout:   30 * Second,
-			KeepAlive: 30 * Second,
-		},
-	}
+	return &utlsTransport{}
 }
 
 func (t *utlsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := checkRequestPolicy(req); err != nil {
+		return nil, err
+	}
 	if req.URL.Scheme != "https" {
 		return sharedTransport.RoundTrip(req)
 	}
@@ -37,7 +38,7 @@ func (t *utlsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	port := t.getPort(req.URL)
 	addr := net.JoinHostPort(host, port)
 
-	conn, err := t.dialer.DialContext(req.Context(), "tcp", addr)
+	conn, err := t.dial(req.Context(), addr)
 	if err != nil {
 		return nil, err
 	}
@@ -78,6 +79,84 @@ func (t *utlsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return transport.RoundTrip(req)
 }
 
+// dial connects to addr with the configured connect timeout, through the
+// configured proxy if there is one, as the shared transports do.
+func (t *utlsTransport) dial(ctx context.Context, addr string) (net.Conn, error) {
+	cfg := GetBackendConfig()
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(cfg.ConnectTimeoutMs) * time.Millisecond,
+		KeepAlive: 30 * Second,
+	}
+	proxyURL, err := parseProxyURL(cfg.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL == nil {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	switch strings.ToLower(proxyURL.Scheme) {
+	case "socks5", "socks5h":
+		socks, err := proxy.FromURL(proxyURL, dialer)
+		if err != nil {
+			return nil, err
+		}
+		return socks.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	}
+	return dialHTTPConnect(ctx, dialer, proxyURL, addr)
+}
+
+// dialHTTPConnect opens a tunnel to addr through an http:// or https://
+// proxy with CONNECT.
+func dialHTTPConnect(ctx context.Context, dialer *net.Dialer, proxyURL *url.URL, addr string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if strings.EqualFold(proxyURL.Scheme, "https") {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach proxy: %w", err)
+	}
+	if strings.EqualFold(proxyURL.Scheme, "https") {
+		conn = tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname(), RootCAs: supplementalRootCAs()})
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		connectReq.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := connectReq.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT failed: %w", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), connectReq)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT failed: %s", resp.Status)
+	}
+	return conn, nil
+}
+
 func (t *utlsTransport) getPort(u *url.URL) string {
 	if u.Port() != "" {
 		return u.Port()
@@ -0,0 +1,141 @@
+package gobackend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// imageHeaderScanLimit caps how far into an image coverDimensions looks for
+// its size. Real covers reach the JPEG frame header within their EXIF and
+// ICC segments; a corrupt file is not walked to its end.
+const imageHeaderScanLimit = 512 << 10
+
+var errImageHeader = errors.New("malformed or unsupported image header")
+
+// coverDimensions reads the width and height of a JPEG, PNG, GIF or WebP
+// image from its header without decoding the pixels. format is named as
+// image.DecodeConfig names it ("jpeg", "png", "gif", "webp").
+func coverDimensions(data []byte) (width, height int, format string, err error) {
+	if len(data) > imageHeaderScanLimit {
+		data = data[:imageHeaderScanLimit]
+	}
+	switch {
+	case len(data) >= 2 && data[0] == 0xFF && data[1] == 0xD8:
+		format = "jpeg"
+		width, height, err = jpegDimensions(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		format = "png"
+		width, height, err = pngDimensions(data)
+	case bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a")):
+		format = "gif"
+		width, height, err = gifDimensions(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		format = "webp"
+		width, height, err = webpDimensions(data)
+	default:
+		return 0, 0, "", errImageHeader
+	}
+	if err == nil && (width <= 0 || height <= 0) {
+		err = errImageHeader
+	}
+	if err != nil {
+		return 0, 0, "", err
+	}
+	return width, height, format, nil
+}
+
+// jpegDimensions walks the marker segments after SOI up to the first
+// start-of-frame header.
+func jpegDimensions(data []byte) (int, int, error) {
+	i := 2
+	for i+1 < len(data) {
+		if data[i] != 0xFF {
+			return 0, 0, errImageHeader
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte before a marker.
+			i++
+			continue
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD8):
+			// Markers without a length.
+			i += 2
+			continue
+		case marker == 0xD9 || marker == 0xDA:
+			// End of image or start of scan before any frame header.
+			return 0, 0, errImageHeader
+		}
+		if i+4 > len(data) {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 {
+			return 0, 0, errImageHeader
+		}
+		isFrame := marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC
+		if isFrame {
+			if length < 7 || i+9 > len(data) {
+				return 0, 0, errImageHeader
+			}
+			height := int(binary.BigEndian.Uint16(data[i+5:]))
+			width := int(binary.BigEndian.Uint16(data[i+7:]))
+			return width, height, nil
+		}
+		i += 2 + length
+	}
+	return 0, 0, errImageHeader
+}
+
+func pngDimensions(data []byte) (int, int, error) {
+	if len(data) < 24 || string(data[12:16]) != "IHDR" {
+		return 0, 0, errImageHeader
+	}
+	width := binary.BigEndian.Uint32(data[16:])
+	height := binary.BigEndian.Uint32(data[20:])
+	if width > 1<<31-1 || height > 1<<31-1 {
+		return 0, 0, errImageHeader
+	}
+	return int(width), int(height), nil
+}
+
+func gifDimensions(data []byte) (int, int, error) {
+	if len(data) < 10 {
+		return 0, 0, errImageHeader
+	}
+	return int(binary.LittleEndian.Uint16(data[6:])), int(binary.LittleEndian.Uint16(data[8:])), nil
+}
+
+// webpDimensions reads the first chunk of a WebP file: the canvas of an
+// extended (VP8X) file, or the frame of a lossy (VP8) or lossless (VP8L)
+// one.
+func webpDimensions(data []byte) (int, int, error) {
+	if len(data) < 20 {
+		return 0, 0, errImageHeader
+	}
+	chunk := data[20:]
+	switch string(data[12:16]) {
+	case "VP8X":
+		if len(chunk) < 10 {
+			return 0, 0, errImageHeader
+		}
+		width := int(chunk[4]) | int(chunk[5])<<8 | int(chunk[6])<<16
+		height := int(chunk[7]) | int(chunk[8])<<8 | int(chunk[9])<<16
+		return width + 1, height + 1, nil
+	case "VP8 ":
+		if len(chunk) < 10 || chunk[3] != 0x9D || chunk[4] != 0x01 || chunk[5] != 0x2A {
+			return 0, 0, errImageHeader
+		}
+		width := int(binary.LittleEndian.Uint16(chunk[6:]) & 0x3FFF)
+		height := int(binary.LittleEndian.Uint16(chunk[8:]) & 0x3FFF)
+		return width, height, nil
+	case "VP8L":
+		if len(chunk) < 5 || chunk[0] != 0x2F {
+			return 0, 0, errImageHeader
+		}
+		bits := binary.LittleEndian.Uint32(chunk[1:])
+		return int(bits&0x3FFF) + 1, int(bits>>14&0x3FFF) + 1, nil
+	}
+	return 0, 0, errImageHeader
+}
@@ -0,0 +1,112 @@
+package gobackend
+
+import (
+	"bytes"
+	"encoding/binary"
+	stdimage "image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func webpFixture(chunk string, payload []byte) []byte {
+	data := []byte("RIFF\x00\x00\x00\x00WEBP" + chunk)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(payload)))
+	return append(data, payload...)
+}
+
+func TestCoverDimensionsFromHeaders(t *testing.T) {
+	img := stdimage.NewRGBA(stdimage.Rect(0, 0, 40, 30))
+	var jpegBuf, pngBuf, gifBuf bytes.Buffer
+	if err := jpeg.Encode(&jpegBuf, img, nil); err != nil {
+		t.Fatalf("jpeg: %v", err)
+	}
+	if err := png.Encode(&pngBuf, img); err != nil {
+		t.Fatalf("png: %v", err)
+	}
+	if err := gif.Encode(&gifBuf, img, nil); err != nil {
+		t.Fatalf("gif: %v", err)
+	}
+
+	// A JPEG whose frame header follows a large APP1 segment and fill bytes.
+	app1 := append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF}, make([]byte, 0xFFFD)...)
+	bigJPEG := append(app1, append([]byte{0xFF}, jpegBuf.Bytes()[2:]...)...)
+
+	lossy := webpFixture("VP8 ", []byte{0, 0, 0, 0x9D, 0x01, 0x2A, 40, 0, 30, 0})
+	lossless := make([]byte, 5)
+	lossless[0] = 0x2F
+	binary.LittleEndian.PutUint32(lossless[1:], uint32(40-1)|uint32(30-1)<<14)
+	extended := webpFixture("VP8X", []byte{0, 0, 0, 0, 39, 0, 0, 29, 0, 0})
+
+	cases := []struct {
+		name   string
+		data   []byte
+		format string
+	}{
+		{"jpeg", jpegBuf.Bytes(), "jpeg"},
+		{"jpeg after app1", bigJPEG, "jpeg"},
+		{"png", pngBuf.Bytes(), "png"},
+		{"gif", gifBuf.Bytes(), "gif"},
+		{"webp lossy", lossy, "webp"},
+		{"webp lossless", webpFixture("VP8L", lossless), "webp"},
+		{"webp extended", extended, "webp"},
+	}
+	for _, c := range cases {
+		width, height, format, err := coverDimensions(c.data)
+		if err != nil || width != 40 || height != 30 || format != c.format {
+			t.Fatalf("%s: got %dx%d %q %v", c.name, width, height, format, err)
+		}
+	}
+}
+
+func TestCoverDimensionsRejectsMalformedHeaders(t *testing.T) {
+	img := stdimage.NewRGBA(stdimage.Rect(0, 0, 8, 8))
+	var jpegBuf bytes.Buffer
+	if err := jpeg.Encode(&jpegBuf, img, nil); err != nil {
+		t.Fatalf("jpeg: %v", err)
+	}
+
+	// APP segments filling more than the scan limit before any frame header.
+	var padded []byte
+	padded = append(padded, 0xFF, 0xD8)
+	for len(padded) <= imageHeaderScanLimit {
+		padded = append(padded, 0xFF, 0xE2, 0xFF, 0xFF)
+		padded = append(padded, make([]byte, 0xFFFD)...)
+	}
+	padded = append(padded, jpegBuf.Bytes()[2:]...)
+
+	cases := map[string][]byte{
+		"empty":               nil,
+		"unknown":             []byte("not an image at all"),
+		"jpeg soi only":       {0xFF, 0xD8},
+		"jpeg truncated":      jpegBuf.Bytes()[:20],
+		"jpeg scan first":     {0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x08},
+		"jpeg bad length":     {0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x01, 0xFF, 0xC0},
+		"jpeg junk":           {0xFF, 0xD8, 0x12, 0x34},
+		"jpeg beyond limit":   padded,
+		"png no ihdr":         []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIDAT\x00\x00\x00\x01\x00\x00\x00\x01"),
+		"png zero width":      []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR\x00\x00\x00\x00\x00\x00\x00\x01"),
+		"gif truncated":       []byte("GIF89a\x01"),
+		"webp unknown chunk":  webpFixture("ALPH", make([]byte, 10)),
+		"webp bad start code": webpFixture("VP8 ", make([]byte, 10)),
+		"webp lossless sig":   webpFixture("VP8L", make([]byte, 5)),
+		"webp truncated":      []byte("RIFF\x00\x00\x00\x00WEBPVP8X"),
+	}
+	for name, data := range cases {
+		if width, height, format, err := coverDimensions(data); err == nil {
+			t.Fatalf("%s: expected an error, got %dx%d %q", name, width, height, format)
+		}
+	}
+}
+
+func TestBuildPictureBlockReadsWebPDimensions(t *testing.T) {
+	data := webpFixture("VP8X", []byte{0, 0, 0, 0, 0xFF, 0x01, 0, 0xFF, 0x01, 0})
+	block, err := buildPictureBlock("", data)
+	if err != nil {
+		t.Fatalf("buildPictureBlock: %v", err)
+	}
+	if width := binary.BigEndian.Uint32(block.Data[len(block.Data)-len(data)-20:]); width != 512 {
+		t.Fatalf("picture width = %d, want 512", width)
+	}
+}
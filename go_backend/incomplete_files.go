@@ -0,0 +1,209 @@
+package gobackend
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrEmptyFile and ErrFileTooShort match every IncompleteFileError via
+// errors.Is: the 0-byte and truncated files a crashed download leaves.
+var (
+	ErrEmptyFile    = errors.New("file is empty")
+	ErrFileTooShort = errors.New("file is too short to hold audio")
+)
+
+// IncompleteFileError is returned by the read APIs for a file that is empty
+// or ends before its audio starts, instead of a parser error.
+type IncompleteFileError struct {
+	Path string
+	Size int64
+	Err  error
+}
+
+func (e *IncompleteFileError) Error() string {
+	return fmt.Sprintf("%s: %v (%d bytes)", e.Path, e.Err, e.Size)
+}
+
+func (e *IncompleteFileError) Unwrap() error {
+	return e.Err
+}
+
+// checkIncompleteAudioFile is the prelude of the read APIs. It returns an
+// IncompleteFileError for an empty file, a FLAC file too short to hold
+// STREAMINFO, or one with intact metadata followed by fewer audio bytes
+// than its STREAMINFO implies, as a download cut off mid-audio is. Any other
+// file, including one that cannot be opened, a FLAC file whose metadata is
+// cut off and so is quarantined as broken, or a short file of another
+// format the scanner names from its filename, is left to the caller.
+func checkIncompleteAudioFile(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return nil
+	}
+
+	size := info.Size()
+	incomplete := func(reason error) error {
+		return &IncompleteFileError{Path: filePath, Size: size, Err: reason}
+	}
+	if size == 0 {
+		return incomplete(ErrEmptyFile)
+	}
+	marker := make([]byte, 4)
+	n, _ := f.Read(marker)
+	isFLAC := n == 4 && string(marker) == "fLaC"
+	if !isFLAC && !strings.EqualFold(filepath.Ext(filePath), ".flac") {
+		return nil
+	}
+	if size <= flacQualityHeaderSize {
+		return incomplete(ErrFileTooShort)
+	}
+	if !isFLAC {
+		return nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil
+	}
+	layout, err := scanFLACMetadataBlocks(f, size)
+	if err == nil && len(layout.Issues) == 0 && size-layout.AudioOffset < flacMinAudioBytes(layout.StreamInfo) {
+		return incomplete(ErrFileTooShort)
+	}
+	return nil
+}
+
+// flacMinAudioBytes is the fewest audio bytes a stream described by
+// streamInfo can take: one frame per max_block_size samples, each at least
+// min_frame_size bytes, or a bare frame header and CRC-16 when the encoder
+// left min_frame_size unknown. Without a sample count it is 1, so only
+// metadata with nothing after it is caught.
+func flacMinAudioBytes(streamInfo []byte) int64 {
+	if len(streamInfo) < 18 {
+		return 1
+	}
+	_, _, totalSamples := parseFLACStreamInfoQuality(streamInfo)
+	maxBlockSize := int64(binary.BigEndian.Uint16(streamInfo[2:4]))
+	if totalSamples == 0 || maxBlockSize == 0 {
+		return 1
+	}
+	minFrameSize := int64(streamInfo[4])<<16 | int64(streamInfo[5])<<8 | int64(streamInfo[6])
+	if minFrameSize == 0 {
+		// Sync code and header fields, header CRC-8, frame CRC-16.
+		minFrameSize = 6 + 2
+	}
+	frames := (totalSamples + maxBlockSize - 1) / maxBlockSize
+	return frames * minFrameSize
+}
+
+func isIncompleteFileError(err error) bool {
+	return errors.Is(err, ErrEmptyFile) || errors.Is(err, ErrFileTooShort)
+}
+
+// IncompleteFile is a file a library scan or CleanupIncomplete found empty
+// or truncated. Reason is "empty" or "too_short".
+type IncompleteFile struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	Reason  string `json:"reason"`
+	ModTime int64  `json:"mod_time"`
+}
+
+func newIncompleteFile(filePath string, modTime int64, err error) IncompleteFile {
+	file := IncompleteFile{Path: filePath, Reason: "too_short", ModTime: modTime}
+	if errors.Is(err, ErrEmptyFile) {
+		file.Reason = "empty"
+	}
+	var incompleteErr *IncompleteFileError
+	if errors.As(err, &incompleteErr) {
+		file.Size = incompleteErr.Size
+	}
+	return file
+}
+
+type IncompleteCleanupReport struct {
+	Root       string           `json:"root"`
+	Checked    int              `json:"checked"`
+	Incomplete []IncompleteFile `json:"incomplete"`
+	// Skipped counts incomplete files newer than olderThan, which may still
+	// be downloading.
+	Skipped int      `json:"skipped"`
+	Deleted int      `json:"deleted"`
+	Failed  []string `json:"failed,omitempty"`
+	DryRun  bool     `json:"dry_run"`
+}
+
+// CleanupIncomplete lists the empty and truncated audio files under
+// rootPath last modified more than olderThanMs milliseconds ago and, when
+// delete is set, removes them. Newer ones are counted as skipped so a
+// download still in progress is not touched.
+func CleanupIncomplete(rootPath string, olderThanMs int64, delete bool) (string, error) {
+	return cleanupIncomplete(rootPath, time.Duration(olderThanMs)*time.Millisecond, delete)
+}
+
+func cleanupIncomplete(rootPath string, olderThan time.Duration, delete bool) (string, error) {
+	if rootPath == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	info, err := os.Stat(rootPath)
+	if err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+	if delete {
+		if err := checkWriteAllowed(rootPath); err != nil {
+			return "", err
+		}
+	}
+
+	files, err := collectLibraryAudioFiles(rootPath, nil)
+	if err != nil {
+		return "", err
+	}
+	report := IncompleteCleanupReport{Root: rootPath, Incomplete: []IncompleteFile{}, DryRun: !delete}
+	cutoff := time.Now().Add(-olderThan).UnixMilli()
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file.path), ".cue") {
+			continue
+		}
+		report.Checked++
+		err := checkIncompleteAudioFile(file.path)
+		if err == nil {
+			continue
+		}
+		if file.modTime > cutoff {
+			report.Skipped++
+			continue
+		}
+		report.Incomplete = append(report.Incomplete, newIncompleteFile(file.path, file.modTime, err))
+		if !delete {
+			continue
+		}
+		if err := os.Remove(file.path); err != nil {
+			GoLog("[Cleanup] Failed to delete %s: %v\n", file.path, err)
+			report.Failed = append(report.Failed, file.path)
+			continue
+		}
+		invalidateMetadataCache(file.path)
+		report.Deleted++
+	}
+	GoLog("[Cleanup] %d incomplete file(s) under %s, %d deleted, %d too recent\n",
+		len(report.Incomplete), rootPath, report.Deleted, report.Skipped)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,132 @@
+package gobackend
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeIncompleteFixtures writes the files a crashed download leaves: empty,
+// a few bytes, intact metadata without audio, and audio cut off early.
+func writeIncompleteFixtures(t *testing.T, root string) map[string]error {
+	t.Helper()
+	good := mustReadFile(t, writeTestFLACWithMetadata(t, Metadata{Title: "Song"}))
+	layout, err := scanFLACMetadataBlocks(bytes.NewReader(good), int64(len(good)))
+	if err != nil {
+		t.Fatalf("scanFLACMetadataBlocks: %v", err)
+	}
+	fixtures := map[string][]byte{
+		"empty.flac":       nil,
+		"short.flac":       []byte("fLa"),
+		"header-only.flac": good[:layout.AudioOffset],
+		"truncated.flac":   good[:layout.AudioOffset+(int64(len(good))-layout.AudioOffset)/4],
+	}
+	want := map[string]error{}
+	for name, data := range fixtures {
+		path := filepath.Join(root, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		want[path] = ErrFileTooShort
+	}
+	want[filepath.Join(root, "empty.flac")] = ErrEmptyFile
+	return want
+}
+
+func TestReadAPIsRejectIncompleteFiles(t *testing.T) {
+	fixtures := writeIncompleteFixtures(t, t.TempDir())
+	for path, want := range fixtures {
+		reads := map[string]func() error{
+			"ReadMetadata":         func() error { _, err := ReadMetadata(path); return err },
+			"GetAudioQuality":      func() error { _, err := GetAudioQuality(path); return err },
+			"ExtractCoverArt":      func() error { _, err := ExtractCoverArt(path); return err },
+			"GetPictures":          func() error { _, err := GetPictures(path); return err },
+			"GetTags":              func() error { _, err := GetTags(path, nil); return err },
+			"GetMetadataFootprint": func() error { _, err := GetMetadataFootprint(path); return err },
+			"ReadFileMetadata":     func() error { _, err := ReadFileMetadata(path); return err },
+			"ReadAudioMetadata":    func() error { _, err := ReadAudioMetadata(path); return err },
+			"GetCoverInfo":         func() error { _, err := GetCoverInfo(path); return err },
+			"scanAudioFile":        func() error { _, err := scanAudioFileWithKnownModTime(path, "", 0); return err },
+		}
+		for name, read := range reads {
+			err := read()
+			var incompleteErr *IncompleteFileError
+			if !errors.Is(err, want) || !errors.As(err, &incompleteErr) || incompleteErr.Path != path {
+				t.Fatalf("%s(%s) = %v, want %v", name, filepath.Base(path), err, want)
+			}
+		}
+	}
+}
+
+func TestScanListsIncompleteFiles(t *testing.T) {
+	root := t.TempDir()
+	writeConsistencyFixture(t, root, "good.flac", Metadata{Title: "Good", Artist: "Artist"})
+	fixtures := writeIncompleteFixtures(t, root)
+
+	out, err := ScanLibraryFolder(root)
+	results := mustDecodeJSON[[]LibraryScanResult](t, out, err)
+	var progress LibraryScanProgress
+	if err := json.Unmarshal([]byte(GetLibraryScanProgress()), &progress); err != nil {
+		t.Fatalf("decode progress: %v", err)
+	}
+	if len(results) != 1 || progress.ErrorCount != 0 || len(progress.Incomplete) != len(fixtures) {
+		t.Fatalf("results %d, progress %+v", len(results), progress)
+	}
+	for _, file := range progress.Incomplete {
+		want := "too_short"
+		if fixtures[file.Path] == ErrEmptyFile {
+			want = "empty"
+		}
+		if file.Reason != want {
+			t.Fatalf("incomplete = %+v", file)
+		}
+	}
+
+	out, err = ScanLibraryFolderIncremental(root, "")
+	incremental := mustDecodeJSON[IncrementalScanResult](t, out, err)
+	if len(incremental.Scanned) != 1 || len(incremental.Incomplete) != len(fixtures) {
+		t.Fatalf("incremental = %+v", incremental)
+	}
+}
+
+func TestCleanupIncomplete(t *testing.T) {
+	root := t.TempDir()
+	good := writeConsistencyFixture(t, root, "good.flac", Metadata{Title: "Good"})
+	fixtures := writeIncompleteFixtures(t, root)
+
+	cleanup := func(olderThan time.Duration, delete bool) IncompleteCleanupReport {
+		t.Helper()
+		out, err := CleanupIncomplete(root, olderThan.Milliseconds(), delete)
+		report := mustDecodeJSON[IncompleteCleanupReport](t, out, err)
+		return report
+	}
+
+	if report := cleanup(time.Hour, true); report.Skipped != len(fixtures) || report.Deleted != 0 {
+		t.Fatalf("recent files touched: %+v", report)
+	}
+	report := cleanup(0, false)
+	if !report.DryRun || len(report.Incomplete) != len(fixtures) || report.Checked != len(fixtures)+1 {
+		t.Fatalf("dry run = %+v", report)
+	}
+	for path := range fixtures {
+		if !fileExists(path) {
+			t.Fatalf("dry run deleted %s", path)
+		}
+	}
+
+	if report := cleanup(0, true); report.Deleted != len(fixtures) {
+		t.Fatalf("delete = %+v", report)
+	}
+	for path := range fixtures {
+		if fileExists(path) {
+			t.Fatalf("%s not deleted", path)
+		}
+	}
+	if !fileExists(good) {
+		t.Fatal("complete file deleted")
+	}
+}
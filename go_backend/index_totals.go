@@ -0,0 +1,54 @@
+package gobackend
+
+import "strconv"
+
+// Standalone total keys, written next to the "n/total" slash form. Vorbis
+// taggers disagree on the name, so both are kept in sync.
+var (
+	trackTotalKeys = []string{"TOTALTRACKS", "TRACKTOTAL"}
+	discTotalKeys  = []string{"TOTALDISCS", "DISCTOTAL"}
+)
+
+// resolveIndexTotal picks the total from the slash form of TRACKNUMBER or
+// DISCNUMBER, falling back to the first positive standalone alias. Aliases
+// that disagree with the chosen total are logged as a warning.
+func resolveIndexTotal(filePath, label string, slashTotal int, aliasValues []string) int {
+	total := slashTotal
+	for _, value := range aliasValues {
+		alias := parsePositiveInt(value)
+		if alias <= 0 {
+			continue
+		}
+		if total == 0 {
+			total = alias
+			continue
+		}
+		if alias != total {
+			GoLog("[Metadata] Warning: %s total alias %d disagrees with %d, using %d %s\n", label, alias, total, total, filePath)
+		}
+	}
+	return total
+}
+
+func (m *vorbisCommentMap) indexTotalAliases(keys []string) []string {
+	var values []string
+	for _, key := range keys {
+		values = append(values, m.getValues(key)...)
+	}
+	return values
+}
+
+// setIndexTotal writes total under every alias in keys, or removes them when
+// total is zero or the backend config turns aliases off, so no stale alias
+// contradicts the slash form.
+func (m *vorbisCommentMap) setIndexTotal(keys []string, total int) {
+	if total <= 0 || GetBackendConfig().SkipTotalAliases {
+		for _, key := range keys {
+			m.remove(key)
+		}
+		return
+	}
+	for _, key := range keys {
+		m.set(key, strconv.Itoa(total))
+	}
+}
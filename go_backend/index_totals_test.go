@@ -0,0 +1,73 @@
+package gobackend
+
+import "testing"
+
+func TestEmbedMetadataWritesTotalAliases(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", TrackNumber: 3, TotalTracks: 12, DiscNumber: 1, TotalDiscs: 2})
+
+	tags, err := GetTags(path, []string{"TOTALTRACKS", "TRACKTOTAL", "TOTALDISCS", "DISCTOTAL"})
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	for key, want := range map[string]string{"TOTALTRACKS": "12", "TRACKTOTAL": "12", "TOTALDISCS": "2", "DISCTOTAL": "2"} {
+		if got := tags[key]; len(got) != 1 || got[0] != want {
+			t.Fatalf("%s = %q, want %q", key, got, want)
+		}
+	}
+
+	if err := EditFlacFields(path, map[string]string{"track_total": ""}); err != nil {
+		t.Fatalf("EditFlacFields: %v", err)
+	}
+	tags, _ = GetTags(path, []string{"TOTALTRACKS", "TRACKTOTAL"})
+	if len(tags) != 0 {
+		t.Fatalf("clearing the total should drop its aliases: %v", tags)
+	}
+}
+
+func TestReadMetadataAcceptsTotalAliases(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", TrackNumber: 3, DiscNumber: 1})
+	if err := SetTags(path, []TagPair{{Key: "TRACKTOTAL", Value: "9"}, {Key: "TOTALDISCS", Value: "2"}}, true); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+	metadata, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if metadata.TotalTracks != 9 || metadata.TotalDiscs != 2 {
+		t.Fatalf("expected totals from aliases, got %d/%d", metadata.TotalTracks, metadata.TotalDiscs)
+	}
+
+	// The slash form wins over a disagreeing alias.
+	if err := SetTags(path, []TagPair{{Key: "TRACKNUMBER", Value: "3/10"}}, true); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+	if metadata, _ = ReadMetadata(path); metadata.TotalTracks != 10 {
+		t.Fatalf("expected slash total 10, got %d", metadata.TotalTracks)
+	}
+}
+
+func TestSkipTotalAliasesConfig(t *testing.T) {
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	if err := Configure(`{"skip_total_aliases":true}`); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", TrackNumber: 3, TotalTracks: 12})
+	tags, err := GetTags(path, []string{"TOTALTRACKS", "TRACKTOTAL", "TRACKNUMBER"})
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if len(tags) != 1 || tags["TRACKNUMBER"][0] != "3/12" {
+		t.Fatalf("expected only the slash form, got %v", tags)
+	}
+}
+
+func TestResolveIndexTotal(t *testing.T) {
+	if got := resolveIndexTotal("", "track", 0, []string{"", "x", "7", "8"}); got != 7 {
+		t.Fatalf("expected first valid alias, got %d", got)
+	}
+	if got := resolveIndexTotal("", "track", 5, []string{"7"}); got != 5 {
+		t.Fatalf("expected slash total to win, got %d", got)
+	}
+}
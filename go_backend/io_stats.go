@@ -0,0 +1,101 @@
+package gobackend
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// WriteStats sums the file writes of a batch operation, to show where
+// tagging time goes on slow storage. DurationMs is the wall time of the
+// whole batch, reads and skipped files included.
+type WriteStats struct {
+	Writes       int     `json:"writes"`
+	InPlace      int     `json:"in_place"`
+	BytesRead    int64   `json:"bytes_read"`
+	BytesWritten int64   `json:"bytes_written"`
+	FsyncMs      float64 `json:"fsync_ms"`
+	DurationMs   int64   `json:"duration_ms"`
+}
+
+// add counts one save. Stats without a kind are of a file left untouched.
+func (s *WriteStats) add(stats flacSaveStats) {
+	if stats.kind == "" {
+		return
+	}
+	s.Writes++
+	if stats.kind == RewriteInPlace {
+		s.InPlace++
+	}
+	s.BytesRead += stats.bytesRead
+	s.BytesWritten += stats.bytesWritten
+	s.FsyncMs += durationMs(stats.fsync)
+}
+
+// SessionWriteStats is the "writes" section of GetStats: file saves by
+// operation and by how the file was written, failures by operation, and
+// cumulative I/O since startup or the last ResetStats.
+type SessionWriteStats struct {
+	Since        string           `json:"since"`
+	Operations   map[string]int64 `json:"operations"`
+	RewriteKinds map[string]int64 `json:"rewrite_kinds"`
+	Errors       map[string]int64 `json:"errors"`
+	BytesRead    int64            `json:"bytes_read"`
+	BytesWritten int64            `json:"bytes_written"`
+	FsyncMs      float64          `json:"fsync_ms"`
+}
+
+var (
+	sessionWriteStatsMu sync.Mutex
+	sessionWriteStats   = newSessionWriteStats()
+)
+
+func newSessionWriteStats() SessionWriteStats {
+	return SessionWriteStats{
+		Since:        time.Now().UTC().Format(time.RFC3339),
+		Operations:   map[string]int64{},
+		RewriteKinds: map[string]int64{},
+		Errors:       map[string]int64{},
+	}
+}
+
+// recordWriteStats adds one save of operation op to the session counters.
+func recordWriteStats(op string, stats flacSaveStats, err error) {
+	sessionWriteStatsMu.Lock()
+	defer sessionWriteStatsMu.Unlock()
+	sessionWriteStats.Operations[op]++
+	if err != nil {
+		sessionWriteStats.Errors[op]++
+		return
+	}
+	if stats.kind != "" {
+		sessionWriteStats.RewriteKinds[stats.kind]++
+	}
+	sessionWriteStats.BytesRead += stats.bytesRead
+	sessionWriteStats.BytesWritten += stats.bytesWritten
+	sessionWriteStats.FsyncMs += durationMs(stats.fsync)
+}
+
+// sessionWriteStatsSnapshot returns a copy of the session counters.
+func sessionWriteStatsSnapshot() SessionWriteStats {
+	sessionWriteStatsMu.Lock()
+	defer sessionWriteStatsMu.Unlock()
+
+	snapshot := sessionWriteStats
+	snapshot.Operations = maps.Clone(sessionWriteStats.Operations)
+	snapshot.RewriteKinds = maps.Clone(sessionWriteStats.RewriteKinds)
+	snapshot.Errors = maps.Clone(sessionWriteStats.Errors)
+	return snapshot
+}
+
+// ResetStats clears the write counters reported by GetStats, so the app can
+// show figures for one session.
+func ResetStats() {
+	sessionWriteStatsMu.Lock()
+	sessionWriteStats = newSessionWriteStats()
+	sessionWriteStatsMu.Unlock()
+}
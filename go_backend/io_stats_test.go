@@ -0,0 +1,73 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+func sessionWritesFromGetStats(t *testing.T) SessionWriteStats {
+	t.Helper()
+	var stats struct {
+		Writes SessionWriteStats `json:"writes"`
+	}
+	if err := json.Unmarshal([]byte(GetStats()), &stats); err != nil {
+		t.Fatalf("GetStats JSON: %v", err)
+	}
+	return stats.Writes
+}
+
+func TestGetStatsCountsWritesUntilReset(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	ResetStats()
+	t.Cleanup(ResetStats)
+
+	if err := EmbedMetadata(path, Metadata{Title: "Changed"}, ""); err != nil {
+		t.Fatalf("EmbedMetadata: %v", err)
+	}
+	if err := SetTags(path, []TagPair{{Key: "MOOD", Value: "Calm"}}, true); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	cfg := original
+	cfg.ReadOnly = true
+	if err := SetBackendConfig(cfg); err != nil {
+		t.Fatalf("SetBackendConfig: %v", err)
+	}
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	if _, err := saveFLACAtomicStats(f, path, "set_tags"); err == nil {
+		t.Fatal("expected read-only save to fail")
+	}
+
+	stats := sessionWritesFromGetStats(t)
+	if stats.Operations["embed_metadata"] != 1 || stats.Operations["set_tags"] != 2 || stats.Errors["set_tags"] != 1 {
+		t.Fatalf("unexpected operation counts: %+v", stats)
+	}
+	if stats.RewriteKinds[RewriteInPlace] != 2 || stats.BytesWritten <= 0 {
+		t.Fatalf("unexpected write totals: %+v", stats)
+	}
+
+	ResetStats()
+	if stats := sessionWritesFromGetStats(t); len(stats.Operations) != 0 || stats.BytesWritten != 0 {
+		t.Fatalf("expected counters cleared, got %+v", stats)
+	}
+}
+
+func TestBatchReportsAggregateWriteStats(t *testing.T) {
+	root := t.TempDir()
+	writeConsistencyFixture(t, root, "a.flac", Metadata{Title: "A", Genre: "HipHop"})
+	writeConsistencyFixture(t, root, "b.flac", Metadata{Title: "B", Genre: "rnb"})
+	writeConsistencyFixture(t, root, "c.flac", Metadata{Title: "C", Genre: "Jazz"})
+
+	out, err := NormalizeGenres(root, false)
+	report := mustDecodeJSON[GenreNormalizationReport](t, out, err)
+	if report.IO.Writes != 2 || report.IO.InPlace != 2 || report.IO.BytesWritten <= 0 || report.IO.BytesRead != 0 {
+		t.Fatalf("unexpected batch I/O: %+v", report.IO)
+	}
+}
@@ -0,0 +1,333 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spotifyIDTagKeys are the comments, with underscores removed, that tools
+// use for the Spotify track ID. Values may also be open.spotify.com URLs.
+var spotifyIDTagKeys = map[string]bool{
+	"SPOTIFYID":      true,
+	"SPOTIFYTRACKID": true,
+}
+
+// ISRCIndexEntry is one indexed file.
+type ISRCIndexEntry struct {
+	Path      string       `json:"path"`
+	ISRC      string       `json:"isrc,omitempty"`
+	SpotifyID string       `json:"spotify_id,omitempty"`
+	Quality   AudioQuality `json:"quality"`
+}
+
+// ISRCIndexFile is the on-disk index written by BuildISRCIndex. Keys of ISRC
+// are upper-cased; each key lists every file carrying it, so duplicates of
+// a track stay visible instead of the last one winning.
+type ISRCIndexFile struct {
+	Root    string                      `json:"root"`
+	BuiltAt string                      `json:"built_at"`
+	ISRC    map[string][]ISRCIndexEntry `json:"isrc"`
+	Spotify map[string][]ISRCIndexEntry `json:"spotify"`
+}
+
+// ISRCIndexReport summarizes a BuildISRCIndex run. Collisions counts the
+// keys shared by more than one file.
+type ISRCIndexReport struct {
+	IndexPath  string `json:"index_path"`
+	Files      int    `json:"files"`
+	Indexed    int    `json:"indexed"`
+	ISRCs      int    `json:"isrcs"`
+	SpotifyIDs int    `json:"spotify_ids"`
+	Collisions int    `json:"collisions"`
+	Failed     int    `json:"failed"`
+}
+
+// isrcIndexFileMu serializes read-modify-write cycles on index files.
+var isrcIndexFileMu sync.Mutex
+
+func newISRCIndexFile(root string) *ISRCIndexFile {
+	return &ISRCIndexFile{
+		Root:    root,
+		BuiltAt: time.Now().UTC().Format(time.RFC3339),
+		ISRC:    make(map[string][]ISRCIndexEntry),
+		Spotify: make(map[string][]ISRCIndexEntry),
+	}
+}
+
+// spotifyIDFromTags returns the Spotify track ID in tags, taken from the
+// path of a URL value when needed.
+func spotifyIDFromTags(tags map[string][]string) string {
+	for key, values := range tags {
+		if !spotifyIDTagKeys[strings.ReplaceAll(key, "_", "")] || len(values) == 0 {
+			continue
+		}
+		value := strings.TrimSpace(values[0])
+		if idx := strings.LastIndex(value, "/track/"); idx >= 0 {
+			value = value[idx+len("/track/"):]
+		}
+		value = strings.TrimPrefix(value, "spotify:track:")
+		if cut, _, found := strings.Cut(value, "?"); found {
+			value = cut
+		}
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// readISRCIndexEntry reads the keys and quality of one FLAC file. ok is
+// false when the file carries neither an ISRC nor a Spotify ID.
+func readISRCIndexEntry(filePath string) (entry ISRCIndexEntry, ok bool, err error) {
+	tags, err := GetTags(filePath, nil)
+	if err != nil {
+		return entry, false, err
+	}
+	entry.Path = filePath
+	if values := tags["ISRC"]; len(values) > 0 {
+		entry.ISRC = strings.ToUpper(strings.TrimSpace(values[0]))
+	}
+	entry.SpotifyID = spotifyIDFromTags(tags)
+	if entry.ISRC == "" && entry.SpotifyID == "" {
+		return entry, false, nil
+	}
+	if quality, err := GetAudioQuality(filePath); err == nil {
+		entry.Quality = quality
+	}
+	return entry, true, nil
+}
+
+func (idx *ISRCIndexFile) add(entry ISRCIndexEntry) {
+	if entry.ISRC != "" {
+		idx.ISRC[entry.ISRC] = append(idx.ISRC[entry.ISRC], entry)
+	}
+	if entry.SpotifyID != "" {
+		idx.Spotify[entry.SpotifyID] = append(idx.Spotify[entry.SpotifyID], entry)
+	}
+}
+
+// removePath drops every entry for filePath.
+func (idx *ISRCIndexFile) removePath(filePath string) {
+	for _, keyed := range []map[string][]ISRCIndexEntry{idx.ISRC, idx.Spotify} {
+		for key, entries := range keyed {
+			entries = slices.DeleteFunc(entries, func(e ISRCIndexEntry) bool { return e.Path == filePath })
+			if len(entries) == 0 {
+				delete(keyed, key)
+			} else {
+				keyed[key] = entries
+			}
+		}
+	}
+}
+
+func loadISRCIndexFile(indexPath string) (*ISRCIndexFile, error) {
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	var idx ISRCIndexFile
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("invalid ISRC index: %w", err)
+	}
+	if idx.ISRC == nil {
+		idx.ISRC = make(map[string][]ISRCIndexEntry)
+	}
+	if idx.Spotify == nil {
+		idx.Spotify = make(map[string][]ISRCIndexEntry)
+	}
+	return &idx, nil
+}
+
+// saveISRCIndexFile writes idx through a temporary file so readers never see
+// a half-written index.
+func saveISRCIndexFile(indexPath string, idx *ISRCIndexFile) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(indexPath), 0755); err != nil {
+		return fmt.Errorf("failed to create index folder: %w", err)
+	}
+	tmpPath := indexPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write ISRC index: %w", err)
+	}
+	if err := os.Rename(tmpPath, indexPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write ISRC index: %w", err)
+	}
+	return nil
+}
+
+// indexISRCFiles reads the ISRC, Spotify ID and quality of every FLAC file
+// under rootPath.
+func indexISRCFiles(rootPath string) (*ISRCIndexFile, ISRCIndexReport, error) {
+	files, err := collectLibraryAudioFiles(rootPath, nil)
+	if err != nil {
+		return nil, ISRCIndexReport{}, err
+	}
+	var flacFiles []string
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file.path), ".flac") {
+			flacFiles = append(flacFiles, file.path)
+		}
+	}
+
+	type result struct {
+		entry ISRCIndexEntry
+		ok    bool
+		err   error
+	}
+	results := make([]result, len(flacFiles))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	workers := min(GetBackendConfig().MaxConcurrentOperations, max(len(flacFiles), 1))
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				entry, ok, err := readISRCIndexEntry(flacFiles[i])
+				results[i] = result{entry: entry, ok: ok, err: err}
+			}
+		}()
+	}
+	for i := range flacFiles {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	idx := newISRCIndexFile(rootPath)
+	report := ISRCIndexReport{Files: len(flacFiles)}
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			report.Failed++
+		case r.ok:
+			idx.add(r.entry)
+			report.Indexed++
+		}
+	}
+	report.ISRCs = len(idx.ISRC)
+	report.SpotifyIDs = len(idx.Spotify)
+	for _, keyed := range []map[string][]ISRCIndexEntry{idx.ISRC, idx.Spotify} {
+		for _, entries := range keyed {
+			if len(entries) > 1 {
+				report.Collisions++
+			}
+		}
+	}
+
+	return idx, report, nil
+}
+
+// BuildISRCIndex reads every FLAC file under rootPath and writes to outPath
+// a JSON index from ISRC, and Spotify track ID when tagged, to the files'
+// paths and audio quality. Unlike the in-memory index behind CheckDuplicate,
+// it survives restarts and keeps every file sharing a key. Keep it current
+// with UpdateISRCIndex and query it with LookupInIndex.
+func BuildISRCIndex(rootPath, outPath string) (string, error) {
+	if strings.TrimSpace(rootPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(rootPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+	if strings.TrimSpace(outPath) == "" {
+		return "", fmt.Errorf("index path is empty")
+	}
+
+	idx, report, err := indexISRCFiles(rootPath)
+	if err != nil {
+		return "", err
+	}
+	report.IndexPath = outPath
+
+	isrcIndexFileMu.Lock()
+	err = saveISRCIndexFile(outPath, idx)
+	isrcIndexFileMu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	GoLog("[ISRCIndex] Wrote %s: %d of %d files, %d collisions\n",
+		outPath, report.Indexed, report.Files, report.Collisions)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// LookupInIndex returns the entries of the index at indexPath for key, an
+// ISRC (any case) or a Spotify track ID, as a JSON array that is empty when
+// nothing matches.
+func LookupInIndex(indexPath, key string) (string, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("lookup key is empty")
+	}
+
+	isrcIndexFileMu.Lock()
+	idx, err := loadISRCIndexFile(indexPath)
+	isrcIndexFileMu.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("failed to read ISRC index: %w", err)
+	}
+
+	entries := idx.ISRC[strings.ToUpper(key)]
+	if entries == nil {
+		entries = idx.Spotify[key]
+	}
+	if entries == nil {
+		entries = []ISRCIndexEntry{}
+	}
+	jsonBytes, err := json.Marshal(entries)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// UpdateISRCIndex re-indexes filePath in the index at indexPath after it was
+// tagged, moved in or deleted: its old entries are dropped and, if it still
+// exists, its current ISRC and Spotify ID are added. A missing index is
+// created.
+func UpdateISRCIndex(indexPath, filePath string) error {
+	if strings.TrimSpace(indexPath) == "" {
+		return fmt.Errorf("index path is empty")
+	}
+
+	var entry ISRCIndexEntry
+	var ok bool
+	if _, err := os.Stat(filePath); err == nil {
+		if entry, ok, err = readISRCIndexEntry(filePath); err != nil {
+			return err
+		}
+	}
+
+	isrcIndexFileMu.Lock()
+	defer isrcIndexFileMu.Unlock()
+
+	idx, err := loadISRCIndexFile(indexPath)
+	if errors.Is(err, os.ErrNotExist) {
+		idx = newISRCIndexFile("")
+	} else if err != nil {
+		return err
+	}
+	idx.removePath(filePath)
+	if ok {
+		idx.add(entry)
+	}
+	return saveISRCIndexFile(indexPath, idx)
+}
@@ -0,0 +1,85 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func lookupIndexEntries(t *testing.T, indexPath, key string) []ISRCIndexEntry {
+	t.Helper()
+	out, err := LookupInIndex(indexPath, key)
+	entries := mustDecodeJSON[[]ISRCIndexEntry](t, out, err)
+	return entries
+}
+
+func TestBuildISRCIndexKeepsCollisions(t *testing.T) {
+	root := t.TempDir()
+	a := writeConsistencyFixture(t, root, "a/one.flac", Metadata{Title: "One", ISRC: "USRC17607839"})
+	b := writeConsistencyFixture(t, root, "b/one.flac", Metadata{Title: "One", ISRC: "usrc17607839"})
+	writeConsistencyFixture(t, root, "c/untagged.flac", Metadata{Title: "Untagged"})
+	spotify := writeAliasedFLAC(t, "SPOTIFY_TRACK_ID=https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC?si=x")
+	spotifyDst := filepath.Join(root, "d", "spotify.flac")
+	os.MkdirAll(filepath.Dir(spotifyDst), 0755)
+	if err := os.Rename(spotify, spotifyDst); err != nil {
+		t.Fatalf("move fixture: %v", err)
+	}
+
+	indexPath := filepath.Join(t.TempDir(), "index", "isrc.json")
+	out, err := BuildISRCIndex(root, indexPath)
+	report := mustDecodeJSON[ISRCIndexReport](t, out, err)
+	if report.Files != 4 || report.Indexed != 3 || report.Collisions != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	entries := lookupIndexEntries(t, indexPath, "usrc17607839")
+	if len(entries) != 2 {
+		t.Fatalf("expected both files for the shared ISRC, got %+v", entries)
+	}
+	paths := map[string]bool{entries[0].Path: true, entries[1].Path: true}
+	if !paths[a] || !paths[b] || entries[0].Quality.SampleRate == 0 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	entries = lookupIndexEntries(t, indexPath, "4uLU6hMCjMI75M1A2tKUQC")
+	if len(entries) != 1 || entries[0].Path != spotifyDst {
+		t.Fatalf("unexpected Spotify lookup: %+v", entries)
+	}
+	if entries := lookupIndexEntries(t, indexPath, "NOTINDEXED01"); len(entries) != 0 {
+		t.Fatalf("expected no entries, got %+v", entries)
+	}
+}
+
+func TestUpdateISRCIndexReindexesFile(t *testing.T) {
+	root := t.TempDir()
+	path := writeConsistencyFixture(t, root, "one.flac", Metadata{Title: "One", ISRC: "GBAYE0000001"})
+	indexPath := filepath.Join(t.TempDir(), "isrc.json")
+
+	if err := UpdateISRCIndex(indexPath, path); err != nil {
+		t.Fatalf("UpdateISRCIndex: %v", err)
+	}
+	if entries := lookupIndexEntries(t, indexPath, "GBAYE0000001"); len(entries) != 1 {
+		t.Fatalf("expected new file indexed, got %+v", entries)
+	}
+
+	if err := EmbedMetadata(path, Metadata{Title: "One", ISRC: "GBAYE0000002"}, ""); err != nil {
+		t.Fatalf("EmbedMetadata: %v", err)
+	}
+	if err := UpdateISRCIndex(indexPath, path); err != nil {
+		t.Fatalf("UpdateISRCIndex: %v", err)
+	}
+	if entries := lookupIndexEntries(t, indexPath, "GBAYE0000001"); len(entries) != 0 {
+		t.Fatalf("expected old ISRC dropped, got %+v", entries)
+	}
+	if entries := lookupIndexEntries(t, indexPath, "GBAYE0000002"); len(entries) != 1 {
+		t.Fatalf("expected retagged file indexed, got %+v", entries)
+	}
+
+	os.Remove(path)
+	if err := UpdateISRCIndex(indexPath, path); err != nil {
+		t.Fatalf("UpdateISRCIndex after delete: %v", err)
+	}
+	if entries := lookupIndexEntries(t, indexPath, "GBAYE0000002"); len(entries) != 0 {
+		t.Fatalf("expected deleted file dropped, got %+v", entries)
+	}
+}
@@ -0,0 +1,89 @@
+package gobackend
+
+import (
+	"math"
+	"os"
+	"testing"
+)
+
+// writeSparseLargeFLAC grows a tagged fixture past 2 GB with a hole so no
+// real data is written, and sets the full 36-bit STREAMINFO sample count.
+func writeSparseLargeFLAC(t *testing.T, size int64) string {
+	t.Helper()
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Live Set", Artist: "Artist"})
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	defer f.Close()
+
+	// STREAMINFO starts at byte 8; total samples are the low nibble of byte
+	// 13 followed by bytes 14-17. The minimum frame size, bytes 4-6, is
+	// cleared as unknown: no real frame is small enough to fit that many
+	// samples into the sparse size.
+	head := make([]byte, 18)
+	if _, err := f.ReadAt(head, 8); err != nil {
+		t.Fatalf("read STREAMINFO: %v", err)
+	}
+	head[4], head[5], head[6] = 0, 0, 0
+	head[13] |= 0x0F
+	head[14], head[15], head[16], head[17] = 0xFF, 0xFF, 0xFF, 0xFF
+	if _, err := f.WriteAt(head, 8); err != nil {
+		t.Fatalf("write STREAMINFO: %v", err)
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatalf("grow fixture: %v", err)
+	}
+	return path
+}
+
+func TestLargeSparseFLACReads(t *testing.T) {
+	const size = 3 << 30
+	path := writeSparseLargeFLAC(t, size)
+
+	quality, err := GetAudioQuality(path)
+	if err != nil {
+		t.Fatalf("GetAudioQuality: %v", err)
+	}
+	const maxSamples = 1<<36 - 1
+	if quality.TotalSamples != maxSamples {
+		t.Fatalf("expected 36-bit sample count %d, got %d", int64(maxSamples), quality.TotalSamples)
+	}
+	if want := int(maxSamples / 44100); quality.Duration != want {
+		t.Fatalf("expected duration %d, got %d", want, quality.Duration)
+	}
+
+	meta, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if meta.Title != "Live Set" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	layout, err := scanFLACMetadataBlocks(f, size)
+	if err != nil {
+		t.Fatalf("scanFLACMetadataBlocks: %v", err)
+	}
+	if len(layout.Issues) != 0 || layout.AudioOffset <= 0 || layout.AudioOffset >= size {
+		t.Fatalf("unexpected layout: offset=%d issues=%v", layout.AudioOffset, layout.Issues)
+	}
+}
+
+func TestFLACDurationSecondsClampsTo32Bit(t *testing.T) {
+	if got := flacDurationSeconds(1<<36-1, 1); got != math.MaxInt32 {
+		t.Fatalf("expected clamp to MaxInt32, got %d", got)
+	}
+	if got := flacDurationSeconds(441000, 44100); got != 10 {
+		t.Fatalf("expected 10 seconds, got %d", got)
+	}
+	if got := flacDurationSeconds(441000, 0); got != 0 {
+		t.Fatalf("expected 0 for missing sample rate, got %d", got)
+	}
+}
@@ -0,0 +1,142 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LibraryAuditOptions selects the checks AuditLibrary runs besides the tag
+// quality check, which always runs.
+type LibraryAuditOptions struct {
+	// DeviceProfile is a CheckDeviceCompat profile; empty skips the check.
+	DeviceProfile json.RawMessage `json:"device_profile,omitempty"`
+	// AnalyzeAudio decodes every FLAC file with AnalyzeAudio. It reads all
+	// the audio and is much slower than the other checks.
+	AnalyzeAudio bool `json:"analyze_audio"`
+}
+
+// LibraryAuditFile lists what the audit found wrong with one file.
+type LibraryAuditFile struct {
+	Path          string              `json:"path"`
+	TagIssues     []TagQualityIssue   `json:"tag_issues,omitempty"`
+	DeviceIssues  []DeviceCompatIssue `json:"device_issues,omitempty"`
+	AudioAnalysis *AudioAnalysis      `json:"audio_analysis,omitempty"`
+	// MissingSampleCount marks a FLAC with no total sample count in
+	// STREAMINFO; RepairStreamInfo can fix it.
+	MissingSampleCount bool   `json:"missing_sample_count,omitempty"`
+	Error              string `json:"error,omitempty"`
+}
+
+// LibraryAuditReport is the result of AuditLibrary. Files holds only the
+// files with a problem or an error. Sources counts the readable files by
+// TagDownloadSource, lowercased, with "unknown" for files without one.
+type LibraryAuditReport struct {
+	Root                    string             `json:"root"`
+	Checked                 int                `json:"checked"`
+	Flagged                 int                `json:"flagged"`
+	Failed                  int                `json:"failed"`
+	TagIssueFiles           int                `json:"tag_issue_files"`
+	IncompatibleFiles       int                `json:"incompatible_files"`
+	AudioIssueFiles         int                `json:"audio_issue_files"`
+	MissingSampleCountFiles int                `json:"missing_sample_count_files"`
+	AudioAnalyzed           bool               `json:"audio_analyzed"`
+	Sources                 map[string]int     `json:"sources"`
+	Files                   []LibraryAuditFile `json:"files"`
+}
+
+func auditLibraryFile(path, scanTime string, profile *DeviceProfile, analyze bool, report *LibraryAuditReport) LibraryAuditFile {
+	entry := LibraryAuditFile{Path: path}
+	scanned, err := scanAudioFileWithKnownModTime(path, scanTime, 0)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	report.Sources[downloadSourceKey(scanned.Source)]++
+	if len(scanned.QualityIssues) > 0 {
+		entry.TagIssues = scanned.QualityIssues
+		report.TagIssueFiles++
+	}
+	if strings.EqualFold(filepath.Ext(path), ".flac") {
+		if quality, err := GetAudioQuality(path); err == nil && quality.MissingSampleCount {
+			entry.MissingSampleCount = true
+			report.MissingSampleCountFiles++
+		}
+	}
+	if profile != nil {
+		result := checkDeviceCompat(path, *profile)
+		if result.Error != "" {
+			entry.Error = result.Error
+			return entry
+		}
+		if !result.Compatible {
+			entry.DeviceIssues = result.Issues
+			report.IncompatibleFiles++
+		}
+	}
+	if analyze && strings.EqualFold(filepath.Ext(path), ".flac") {
+		analysis, err := analyzeAudioFile(path)
+		if err != nil {
+			entry.Error = err.Error()
+			return entry
+		}
+		if len(analysis.Issues) > 0 {
+			entry.AudioAnalysis = analysis
+			report.AudioIssueFiles++
+		}
+	}
+	return entry
+}
+
+// AuditLibrary checks every audio file under rootPath for placeholder or
+// empty tags and, as optionsJSON (a LibraryAuditOptions) asks, for formats
+// a device cannot play and for leading or trailing silence and clipping, so
+// problem tracks can be fixed or downloaded again. FLAC files whose
+// STREAMINFO lacks a sample count are flagged for RepairStreamInfo.
+func AuditLibrary(rootPath, optionsJSON string) (string, error) {
+	var options LibraryAuditOptions
+	if strings.TrimSpace(optionsJSON) != "" {
+		if err := json.Unmarshal([]byte(optionsJSON), &options); err != nil {
+			return "", fmt.Errorf("invalid audit options: %w", err)
+		}
+	}
+	var profile *DeviceProfile
+	if len(options.DeviceProfile) > 0 && string(options.DeviceProfile) != "null" {
+		parsed, err := parseDeviceProfile(string(options.DeviceProfile))
+		if err != nil {
+			return "", err
+		}
+		profile = &parsed
+	}
+	paths, err := deviceCompatFiles(rootPath)
+	if err != nil {
+		return "", err
+	}
+
+	report := LibraryAuditReport{Root: rootPath, AudioAnalyzed: options.AnalyzeAudio, Sources: map[string]int{}, Files: []LibraryAuditFile{}}
+	scanTime := time.Now().UTC().Format(time.RFC3339)
+	for _, path := range paths {
+		report.Checked++
+		entry := auditLibraryFile(path, scanTime, profile, options.AnalyzeAudio, &report)
+		switch {
+		case entry.Error != "":
+			report.Failed++
+		case entry.TagIssues != nil || entry.DeviceIssues != nil || entry.AudioAnalysis != nil || entry.MissingSampleCount:
+			report.Flagged++
+		default:
+			continue
+		}
+		report.Files = append(report.Files, entry)
+	}
+
+	GoLog("[LibraryAudit] %d of %d files under %s flagged, %d failed\n",
+		report.Flagged, report.Checked, rootPath, report.Failed)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,89 @@
+package gobackend
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditLibrary(t *testing.T) {
+	root := t.TempDir()
+	padded := writeSilencePaddedFLAC(t, root, 6, 0)
+	if err := EmbedAll(padded, Metadata{Title: "Padded", Artist: "Artist", Album: "Album"}, Lyrics{}, nil, EmbedOptions{}); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	placeholder := writeConsistencyFixture(t, root, "placeholder.flac", Metadata{Title: "Song", Artist: "Unknown Artist", Album: "Album"})
+	writeConsistencyFixture(t, root, "clean.flac", Metadata{Title: "Song", Artist: "Artist", Album: "Album"})
+
+	audit := func(options string) LibraryAuditReport {
+		t.Helper()
+		out, err := AuditLibrary(root, options)
+		report := mustDecodeJSON[LibraryAuditReport](t, out, err)
+		return report
+	}
+
+	report := audit("")
+	if report.Checked != 3 || report.Flagged != 1 || report.TagIssueFiles != 1 || report.AudioAnalyzed {
+		t.Fatalf("tag-only audit = %+v", report)
+	}
+	if report.Files[0].Path != placeholder || report.Files[0].TagIssues[0].Field != "artist" {
+		t.Fatalf("flagged %+v", report.Files[0])
+	}
+
+	report = audit(`{"analyze_audio":true,"device_profile":{"preset":"cd_player"}}`)
+	if !report.AudioAnalyzed || report.AudioIssueFiles != 1 || report.IncompatibleFiles != 1 || report.Flagged != 2 {
+		t.Fatalf("deep audit = %+v", report)
+	}
+	for _, file := range report.Files {
+		if filepath.Base(file.Path) != "padded.flac" {
+			continue
+		}
+		if file.AudioAnalysis == nil || file.AudioAnalysis.LeadingSilenceMs != 6000 || len(file.DeviceIssues) != 1 {
+			t.Fatalf("padded file = %+v", file)
+		}
+	}
+
+	if _, err := AuditLibrary(root, `{"device_profile":{"preset":"walkman"}}`); err == nil {
+		t.Fatal("expected an unknown preset to be refused")
+	}
+}
+
+func TestAuditLibraryDecodesWithinTheOperationLimit(t *testing.T) {
+	root := t.TempDir()
+	writeConsistencyFixture(t, root, "a.flac", Metadata{Title: "A", Artist: "Artist"})
+	withBackendConfig(t, func(cfg *BackendConfig) { cfg.MaxConcurrentOperations = 1 })
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type auditResult struct {
+		out string
+		err error
+	}
+	done := make(chan auditResult)
+	go func() {
+		out, err := AuditLibrary(root, `{"analyze_audio":true}`)
+		done <- auditResult{out, err}
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for heavyOperationLimiter.stats().Queued != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("audit did not wait for a slot: %+v", heavyOperationLimiter.stats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	release()
+
+	select {
+	case result := <-done:
+		if report := mustDecodeJSON[LibraryAuditReport](t, result.out, result.err); report.Checked != 1 || report.Failed != 0 {
+			t.Fatalf("audit = %+v", report)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("audit did not finish after the slot was released")
+	}
+	if stats := heavyOperationLimiter.stats(); stats.Active != 0 {
+		t.Fatalf("audit left slots taken: %+v", stats)
+	}
+}
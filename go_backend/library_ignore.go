@@ -0,0 +1,55 @@
+package gobackend
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// defaultIgnorePatterns are always skipped by library walks: dotfiles and
+// dot-folders (Android's .trashed-* files, macOS ._* AppleDouble files,
+// .thumbnails) and the leftovers of interrupted copies.
+var defaultIgnorePatterns = []string{".*", "*.part", "*.tmp"}
+
+func validateIgnorePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("ignore pattern is empty")
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// isLibraryIgnored reports whether a library walk of root skips path, by the
+// default rules or one of patterns. Names match case-insensitively; a
+// pattern with a "/" is matched against the slash path relative to root.
+func isLibraryIgnored(root, path string, patterns []string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	for _, pattern := range defaultIgnorePatterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	if len(patterns) == 0 {
+		return false
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	rel = strings.ToLower(filepath.ToSlash(rel))
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		target := name
+		if strings.Contains(pattern, "/") {
+			target = rel
+		}
+		if ok, _ := filepath.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsLibraryIgnored(t *testing.T) {
+	root := "/music"
+	patterns := []string{"*.bak.flac", "podcasts/*"}
+	tests := map[string]bool{
+		"/music/Artist/Song.flac":              false,
+		"/music/Artist/.trashed-123-Song.flac": true,
+		"/music/Artist/._Song.flac":            true,
+		"/music/.thumbnails":                   true,
+		"/music/Artist/Song.flac.part":         true,
+		"/music/Artist/Song.TMP":               true,
+		"/music/Artist/Song.BAK.flac":          true,
+		"/music/Podcasts/Episode.mp3":          true,
+		"/music/Artist/Podcasts/Episode.mp3":   false,
+	}
+	for path, want := range tests {
+		if got := isLibraryIgnored(root, path, patterns); got != want {
+			t.Fatalf("isLibraryIgnored(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestScanSkipsIgnoredFiles(t *testing.T) {
+	withBackendConfig(t, func(cfg *BackendConfig) { cfg.IgnorePatterns = []string{"Skip*"} })
+	root := t.TempDir()
+	writeConsistencyFixture(t, root, "Artist/Song.flac", Metadata{Title: "Song", Artist: "Artist"})
+	hidden := writeConsistencyFixture(t, root, "Artist/._Song.flac", Metadata{Title: "Song"})
+	writeConsistencyFixture(t, root, "Artist/.trashed-1700000000-Old.flac", Metadata{Title: "Old"})
+	writeConsistencyFixture(t, root, "Skipped/Other.flac", Metadata{Title: "Other"})
+	if err := os.WriteFile(filepath.Join(root, "Artist", "Song.flac.part"), []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ScanLibraryFolder(root)
+	results := mustDecodeJSON[[]LibraryScanResult](t, out, err)
+	var progress LibraryScanProgress
+	if err := json.Unmarshal([]byte(GetLibraryScanProgress()), &progress); err != nil {
+		t.Fatalf("decode progress: %v", err)
+	}
+	if len(results) != 1 || results[0].TrackName != "Song" || progress.ErrorCount != 0 || progress.IgnoredCount != 3 {
+		t.Fatalf("results %+v, progress %+v", results, progress)
+	}
+
+	out, err = ScanLibraryFolderIncremental(root, "")
+	incremental := mustDecodeJSON[IncrementalScanResult](t, out, err)
+	if len(incremental.Scanned) != 1 || incremental.IgnoredCount != 3 {
+		t.Fatalf("incremental = %+v", incremental)
+	}
+
+	// A single-file call reads an ignored file as usual.
+	if metadata, err := ReadMetadata(hidden); err != nil || metadata.Title != "Song" {
+		t.Fatalf("ReadMetadata(%s) = %+v, %v", hidden, metadata, err)
+	}
+}
+
+func TestIgnorePatternsAreValidated(t *testing.T) {
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	for _, patterns := range [][]string{{"[unclosed"}, {" "}} {
+		cfg := original
+		cfg.IgnorePatterns = patterns
+		if err := SetBackendConfig(cfg); err == nil {
+			t.Fatalf("expected error for %q", patterns)
+		}
+	}
+}
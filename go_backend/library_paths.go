@@ -0,0 +1,180 @@
+package gobackend
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Kinds of PathWarning.
+const (
+	// PathWarningSymlinkEscape is a symlink under the library root whose
+	// target lies outside it. It is neither read nor written.
+	PathWarningSymlinkEscape = "symlink_escape"
+	// PathWarningCaseCollision is a rename target that differs from another
+	// file in the same folder only by case. On case-insensitive storage
+	// (FAT and exFAT SD cards) both names are the same file.
+	PathWarningCaseCollision = "case_collision"
+)
+
+// PathWarning reports a path that a library operation skipped instead of
+// leaving the root or overwriting a file.
+type PathWarning struct {
+	Kind    string `json:"kind"`
+	Path    string `json:"path"`
+	Target  string `json:"target,omitempty"`
+	Message string `json:"message"`
+}
+
+// collectLibraryAudioFilesChecked is collectLibraryAudioFiles that also
+// follows symlinked files resolving inside folderPath and reports the
+// symlinks that escape it. Symlinked folders are never descended into: one
+// inside the root is already walked through its real path, and one outside
+// it is reported.
+func collectLibraryAudioFilesChecked(folderPath string, cancelCh <-chan struct{}) ([]libraryAudioFileInfo, []PathWarning, error) {
+	files, warnings, ignored, err := walkLibraryAudioFiles(folderPath, cancelCh)
+	if ignored > 0 {
+		GoLog("[LibraryScan] Ignored %d hidden, temporary or excluded entries under %s\n", ignored, folderPath)
+	}
+	return files, warnings, err
+}
+
+// walkLibraryAudioFiles is collectLibraryAudioFilesChecked that also returns
+// how many entries the ignore rules skipped; see isLibraryIgnored.
+func walkLibraryAudioFiles(folderPath string, cancelCh <-chan struct{}) ([]libraryAudioFileInfo, []PathWarning, int, error) {
+	realRoot, err := filepath.EvalSymlinks(folderPath)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("folder not found: %w", err)
+	}
+	realRoot, _ = filepath.Abs(realRoot)
+
+	ignorePatterns := GetBackendConfig().IgnorePatterns
+	var files []libraryAudioFileInfo
+	var warnings []PathWarning
+	ignored := 0
+	err = filepath.WalkDir(folderPath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		select {
+		case <-cancelCh:
+			return fmt.Errorf("scan cancelled")
+		default:
+		}
+
+		if path != folderPath && isLibraryIgnored(folderPath, path, ignorePatterns) {
+			if entry.IsDir() {
+				ignored++
+				return filepath.SkipDir
+			}
+			if supportedAudioFormats[strings.ToLower(filepath.Ext(path))] {
+				ignored++
+			}
+			return nil
+		}
+		if entry.IsDir() || isLibraryStagingFile(path) {
+			return nil
+		}
+
+		var info fs.FileInfo
+		if entry.Type()&fs.ModeSymlink != 0 {
+			target, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				// Dangling link.
+				return nil
+			}
+			if !pathWithin(target, realRoot) {
+				warnings = append(warnings, PathWarning{
+					Kind:    PathWarningSymlinkEscape,
+					Path:    path,
+					Target:  target,
+					Message: "symlink points outside the library folder",
+				})
+				return nil
+			}
+			info, err = os.Stat(path)
+			if err != nil || info.IsDir() {
+				return nil
+			}
+		}
+
+		ext := strings.ToLower(filepath.Ext(path))
+		if !supportedAudioFormats[ext] {
+			return nil
+		}
+
+		if info == nil {
+			info, err = entry.Info()
+			if err != nil {
+				return nil
+			}
+		}
+
+		files = append(files, libraryAudioFileInfo{
+			path:    path,
+			modTime: info.ModTime().UnixMilli(),
+			size:    info.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return files, warnings, ignored, nil
+}
+
+// caseFoldName is the key under which case-insensitive filesystems treat
+// two names as the same file.
+func caseFoldName(name string) string {
+	return strings.ToLower(norm.NFC.String(name))
+}
+
+// folderNameIndex maps the case-folded names in one folder to their actual
+// paths, including names already claimed by pending renames.
+type folderNameIndex map[string]string
+
+func newFolderNameIndex(dir string) folderNameIndex {
+	index := make(folderNameIndex)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return index
+	}
+	for _, entry := range entries {
+		index[caseFoldName(entry.Name())] = filepath.Join(dir, entry.Name())
+	}
+	return index
+}
+
+// claim reserves newName for filePath and returns the path whose name
+// differs from newName only by case, or "" otherwise. A case-only rename of
+// the file itself is not a collision, and an exact name match is left to
+// the rename, which refuses to replace an existing file.
+func (index folderNameIndex) claim(filePath, newName string) string {
+	key := caseFoldName(newName)
+	occupant, ok := index[key]
+	if !ok || occupant == filePath || sameFile(occupant, filePath) {
+		index[key] = filepath.Join(filepath.Dir(filePath), newName)
+		return ""
+	}
+	if filepath.Base(occupant) == newName {
+		return ""
+	}
+	return occupant
+}
+
+func sameFile(a, b string) bool {
+	infoA, err := os.Lstat(a)
+	if err != nil {
+		return false
+	}
+	infoB, err := os.Lstat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(infoA, infoB)
+}
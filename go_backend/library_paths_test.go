@@ -0,0 +1,93 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestCollectLibraryAudioFilesStaysInsideRoot(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	inside := writeConsistencyFixture(t, root, "Album/a.flac", Metadata{Artist: "Artist", Title: "A"})
+	stray := writeConsistencyFixture(t, outside, "Other/x.flac", Metadata{Artist: "Artist", Title: "X"})
+
+	links := map[string]string{
+		filepath.Join(root, "Escape"):             filepath.Join(outside, "Other"),
+		filepath.Join(root, "Album", "link.flac"): stray,
+		filepath.Join(root, "Album", "same.flac"): inside,
+		filepath.Join(root, "Album", "Loop"):      filepath.Join(root, "Album"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks unavailable: %v", err)
+		}
+	}
+
+	files, warnings, err := collectLibraryAudioFilesChecked(root, nil)
+	if err != nil {
+		t.Fatalf("collectLibraryAudioFilesChecked: %v", err)
+	}
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.path)
+	}
+	sort.Strings(paths)
+	want := []string{inside, filepath.Join(root, "Album", "same.flac")}
+	if len(paths) != 2 || paths[0] != want[0] || paths[1] != want[1] {
+		t.Fatalf("files = %v, want %v", paths, want)
+	}
+
+	if len(warnings) != 2 {
+		t.Fatalf("warnings = %+v, want 2 escapes", warnings)
+	}
+	for _, warning := range warnings {
+		if warning.Kind != PathWarningSymlinkEscape {
+			t.Fatalf("unexpected warning: %+v", warning)
+		}
+	}
+
+	raw, err := FixFilenameConsistency(root, "{artist} - {title}", true)
+	report := mustDecodeJSON[FilenameConsistencyReport](t, raw, err)
+	if len(report.Warnings) != 2 {
+		t.Fatalf("report warnings = %+v", report.Warnings)
+	}
+	if !fileExists(stray) {
+		t.Fatal("renamer followed a symlink out of the root")
+	}
+}
+
+func TestFixFilenameConsistencyDetectsCaseCollision(t *testing.T) {
+	root := t.TempDir()
+	// On a case-insensitive card "Song.flac" would overwrite "song.flac".
+	colliding := writeConsistencyFixture(t, root, "a.flac", Metadata{Title: "Song", Artist: "Artist"})
+	existing := writeConsistencyFixture(t, root, "song.flac", Metadata{Title: "song", Artist: "Artist"})
+	recased := writeConsistencyFixture(t, root, "other.flac", Metadata{Title: "Other", Artist: "Artist"})
+	if err := os.Rename(recased, filepath.Join(root, "oTHER.flac")); err != nil {
+		t.Fatalf("rename fixture: %v", err)
+	}
+
+	raw, err := FixFilenameConsistency(root, "{title}", false)
+	report := mustDecodeJSON[FilenameConsistencyReport](t, raw, err)
+	if len(report.Warnings) != 1 || report.Warnings[0].Kind != PathWarningCaseCollision || report.Warnings[0].Path != colliding {
+		t.Fatalf("dry run warnings = %+v", report.Warnings)
+	}
+
+	raw, err = FixFilenameConsistency(root, "{title}", true)
+	report = mustDecodeJSON[FilenameConsistencyReport](t, raw, err)
+	if report.Renamed != 1 || report.Failed != 0 || len(report.Warnings) != 1 {
+		t.Fatalf("unexpected apply report: %+v", report)
+	}
+	for _, mismatch := range report.Directories[0].Mismatches {
+		if mismatch.Path == colliding && mismatch.Action != "collision" {
+			t.Fatalf("colliding file action = %q", mismatch.Action)
+		}
+	}
+	if !fileExists(colliding) || !fileExists(existing) {
+		t.Fatal("collision must leave both files in place")
+	}
+	if !fileExists(filepath.Join(root, "Other.flac")) {
+		t.Fatal("case-only rename of a file onto its own name was refused")
+	}
+}
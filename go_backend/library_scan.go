@@ -38,6 +38,13 @@ type LibraryScanResult struct {
 	Copyright            string `json:"copyright,omitempty"`
 	Format               string `json:"format,omitempty"`
 	MetadataFromFilename bool   `json:"metadataFromFilename,omitempty"`
+	// QualityIssues lists empty or placeholder tag values; see QualityCheck.
+	QualityIssues []TagQualityIssue `json:"qualityIssues,omitempty"`
+	// Source, SourceQuality and DownloadedAt are the download source tags
+	// of a FLAC file; see TagDownloadSource.
+	Source        string `json:"source,omitempty"`
+	SourceQuality string `json:"sourceQuality,omitempty"`
+	DownloadedAt  string `json:"downloadedAt,omitempty"`
 }
 
 type LibraryScanProgress struct {
@@ -47,6 +54,16 @@ type LibraryScanProgress struct {
 	ErrorCount   int     `json:"error_count"`
 	ProgressPct  float64 `json:"progress_pct"`
 	IsComplete   bool    `json:"is_complete"`
+	// EstimatedRemainingMs is zero until the first file has been scanned.
+	EstimatedRemainingMs int64 `json:"estimated_remaining_ms"`
+	// Warnings lists the paths skipped to stay inside the folder.
+	Warnings []PathWarning `json:"warnings,omitempty"`
+	// Incomplete lists the empty and truncated files left by crashed
+	// downloads. They are not counted as errors; see CleanupIncomplete.
+	Incomplete []IncompleteFile `json:"incomplete,omitempty"`
+	// IgnoredCount is how many audio files and folders the ignore rules
+	// skipped; see BackendConfig.IgnorePatterns.
+	IgnoredCount int `json:"ignored_count"`
 }
 
 type IncrementalScanResult struct {
@@ -54,6 +71,9 @@ type IncrementalScanResult struct {
 	DeletedPaths []string            `json:"deletedPaths"` // Files that no longer exist
 	SkippedCount int                 `json:"skippedCount"` // Files that were unchanged
 	TotalFiles   int                 `json:"totalFiles"`   // Total files in folder
+	Warnings     []PathWarning       `json:"warnings,omitempty"`
+	Incomplete   []IncompleteFile    `json:"incomplete,omitempty"` // Empty or truncated files, not counted as errors
+	IgnoredCount int                 `json:"ignoredCount"`         // Entries skipped by the ignore rules
 }
 
 var (
@@ -85,6 +105,16 @@ var supportedAudioFormats = map[string]bool{
 type libraryAudioFileInfo struct {
 	path    string
 	modTime int64
+	size    int64
+}
+
+// libraryFileSizes returns the sizes of files for newBatchETA.
+func libraryFileSizes(files []libraryAudioFileInfo) []int64 {
+	sizes := make([]int64, len(files))
+	for i, file := range files {
+		sizes[i] = file.size
+	}
+	return sizes
 }
 
 type scannedCueFileInfo struct {
@@ -105,48 +135,17 @@ func isLibraryStagingFile(path string) bool {
 	return false
 }
 
+// collectLibraryAudioFiles lists the audio and cue files under folderPath.
+// Symlinks escaping the folder are skipped and only logged; callers that
+// report them use collectLibraryAudioFilesChecked.
 func collectLibraryAudioFiles(folderPath string, cancelCh <-chan struct{}) ([]libraryAudioFileInfo, error) {
-	var files []libraryAudioFileInfo
-
-	err := filepath.WalkDir(folderPath, func(path string, entry os.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
-
-		select {
-		case <-cancelCh:
-			return fmt.Errorf("scan cancelled")
-		default:
-		}
-
-		if entry.IsDir() {
-			return nil
-		}
-		if isLibraryStagingFile(path) {
-			return nil
-		}
-
-		ext := strings.ToLower(filepath.Ext(path))
-		if !supportedAudioFormats[ext] {
-			return nil
-		}
-
-		info, err := entry.Info()
-		if err != nil {
-			return nil
-		}
-
-		files = append(files, libraryAudioFileInfo{
-			path:    path,
-			modTime: info.ModTime().UnixMilli(),
-		})
-		return nil
-	})
-
+	files, warnings, err := collectLibraryAudioFilesChecked(folderPath, cancelCh)
+	for _, warning := range warnings {
+		GoLog("[LibraryScan] Skipping %s: %s (%s)\n", warning.Path, warning.Message, warning.Target)
+	}
 	if err != nil {
 		return nil, err
 	}
-
 	return files, nil
 }
 
@@ -181,7 +180,7 @@ func ScanLibraryFolder(folderPath string) (string, error) {
 	cancelCh := libraryScanCancel
 	libraryScanCancelMu.Unlock()
 
-	audioFileInfos, err := collectLibraryAudioFiles(folderPath, cancelCh)
+	audioFileInfos, warnings, ignored, err := walkLibraryAudioFiles(folderPath, cancelCh)
 	if err != nil {
 		return "[]", err
 	}
@@ -189,6 +188,8 @@ func ScanLibraryFolder(folderPath string) (string, error) {
 	totalFiles := len(audioFileInfos)
 	libraryScanProgressMu.Lock()
 	libraryScanProgress.TotalFiles = totalFiles
+	libraryScanProgress.Warnings = warnings
+	libraryScanProgress.IgnoredCount = ignored
 	libraryScanProgressMu.Unlock()
 
 	if totalFiles == 0 {
@@ -198,11 +199,12 @@ func ScanLibraryFolder(folderPath string) (string, error) {
 		return "[]", nil
 	}
 
-	GoLog("[LibraryScan] Found %d audio files to scan\n", totalFiles)
+	GoLog("[LibraryScan] Found %d audio files to scan, %d ignored\n", totalFiles, ignored)
 
 	results := make([]LibraryScanResult, 0, totalFiles)
 	scanTime := time.Now().UTC().Format(time.RFC3339)
 	errorCount := 0
+	var incomplete []IncompleteFile
 
 	cueReferencedAudioFiles := make(map[string]bool)
 	parsedCueFiles := make(map[string]scannedCueFileInfo)
@@ -225,6 +227,8 @@ func ScanLibraryFolder(folderPath string) (string, error) {
 		}
 	}
 
+	eta := newBatchETA(libraryFileSizes(audioFileInfos), 1)
+	var itemStarted time.Time
 	for i, fileInfo := range audioFileInfos {
 		filePath := fileInfo.path
 		select {
@@ -233,10 +237,15 @@ func ScanLibraryFolder(folderPath string) (string, error) {
 		default:
 		}
 
+		if i > 0 {
+			eta.done(audioFileInfos[i-1].size, time.Since(itemStarted))
+		}
+		itemStarted = time.Now()
 		libraryScanProgressMu.Lock()
 		libraryScanProgress.ScannedFiles = i + 1
 		libraryScanProgress.CurrentFile = filepath.Base(filePath)
 		libraryScanProgress.ProgressPct = float64(i+1) / float64(totalFiles) * 100
+		libraryScanProgress.EstimatedRemainingMs = eta.remainingMs()
 		libraryScanProgressMu.Unlock()
 
 		ext := strings.ToLower(filepath.Ext(filePath))
@@ -273,6 +282,10 @@ func ScanLibraryFolder(folderPath string) (string, error) {
 		}
 
 		result, err := scanAudioFileWithKnownModTime(filePath, scanTime, fileInfo.modTime)
+		if isIncompleteFileError(err) {
+			incomplete = append(incomplete, newIncompleteFile(filePath, fileInfo.modTime, err))
+			continue
+		}
 		if err != nil {
 			errorCount++
 			GoLog("[LibraryScan] Error scanning %s: %v\n", filePath, err)
@@ -284,10 +297,12 @@ func ScanLibraryFolder(folderPath string) (string, error) {
 
 	libraryScanProgressMu.Lock()
 	libraryScanProgress.ErrorCount = errorCount
+	libraryScanProgress.Incomplete = incomplete
 	libraryScanProgress.IsComplete = true
+	libraryScanProgress.EstimatedRemainingMs = 0
 	libraryScanProgressMu.Unlock()
 
-	GoLog("[LibraryScan] Scan complete: %d tracks found, %d errors\n", len(results), errorCount)
+	GoLog("[LibraryScan] Scan complete: %d tracks found, %d errors, %d incomplete\n", len(results), errorCount, len(incomplete))
 
 	jsonBytes, err := json.Marshal(results)
 	if err != nil {
@@ -303,6 +318,9 @@ func scanAudioFileWithKnownModTime(filePath, scanTime string, knownModTime int64
 
 func scanAudioFileWithKnownModTimeAndDisplayNameAndCoverCacheKey(filePath, displayNameHint, coverCacheKey, scanTime string, knownModTime int64) (*LibraryScanResult, error) {
 	ext := resolveLibraryAudioExt(filePath, displayNameHint)
+	if err := checkIncompleteAudioFile(filePath); err != nil {
+		return nil, err
+	}
 
 	result := &LibraryScanResult{
 		ID:        generateLibraryID(filePath),
@@ -368,6 +386,8 @@ func libraryDisplayNameOrPath(filePath, displayNameHint string) string {
 }
 
 func applyDefaultLibraryMetadata(filePath, displayNameHint string, result *LibraryScanResult) {
+	// Check the tags before the defaults below fill in "Unknown Artist".
+	result.QualityIssues = libraryScanQualityIssues(result)
 	nameSource := libraryDisplayNameOrPath(filePath, displayNameHint)
 	if result.TrackName == "" {
 		result.TrackName = strings.TrimSuffix(filepath.Base(nameSource), filepath.Ext(nameSource))
@@ -400,6 +420,9 @@ func scanFLACFile(filePath string, result *LibraryScanResult, displayNameHint st
 	result.Composer = metadata.Composer
 	result.Label = metadata.Label
 	result.Copyright = metadata.Copyright
+	result.Source = metadata.Source
+	result.SourceQuality = metadata.SourceQuality
+	result.DownloadedAt = metadata.DownloadedAt
 
 	quality, err := GetAudioQuality(filePath)
 	if err == nil {
@@ -689,13 +712,31 @@ func ReadAudioMetadataWithDisplayName(filePath, displayNameHint string) (string,
 
 func ReadAudioMetadataWithDisplayNameAndCoverCacheKey(filePath, displayNameHint, coverCacheKey string) (string, error) {
 	scanTime := time.Now().UTC().Format(time.RFC3339)
-	result, err := scanAudioFileWithKnownModTimeAndDisplayNameAndCoverCacheKey(
-		filePath,
-		displayNameHint,
-		coverCacheKey,
-		scanTime,
-		0,
-	)
+	scan := func(localPath string) (*LibraryScanResult, error) {
+		return scanAudioFileWithKnownModTimeAndDisplayNameAndCoverCacheKey(
+			localPath,
+			displayNameHint,
+			coverCacheKey,
+			scanTime,
+			0,
+		)
+	}
+	var result *LibraryScanResult
+	var err error
+	if isOpenerPath(filePath) {
+		// Key the result and its cached cover by the URI, not the
+		// short-lived local copy.
+		if coverCacheKey == "" {
+			coverCacheKey = filePath
+		}
+		result, err = viaFileOpener(filePath, false, scan)
+		if result != nil {
+			result.ID = generateLibraryID(filePath)
+			result.FilePath = filePath
+		}
+	} else {
+		result, err = scan(filePath)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -771,7 +812,7 @@ func scanLibraryFolderIncrementalWithExistingFiles(folderPath string, existingFi
 	cancelCh := libraryScanCancel
 	libraryScanCancelMu.Unlock()
 
-	currentFiles, err := collectLibraryAudioFiles(folderPath, cancelCh)
+	currentFiles, warnings, ignored, err := walkLibraryAudioFiles(folderPath, cancelCh)
 	if err != nil {
 		return "{}", err
 	}
@@ -783,6 +824,7 @@ func scanLibraryFolderIncrementalWithExistingFiles(folderPath string, existingFi
 	totalFiles := len(currentFiles)
 	libraryScanProgressMu.Lock()
 	libraryScanProgress.TotalFiles = totalFiles
+	libraryScanProgress.IgnoredCount = ignored
 	libraryScanProgressMu.Unlock()
 
 	var filesToScan []libraryAudioFileInfo
@@ -846,6 +888,8 @@ func scanLibraryFolderIncrementalWithExistingFiles(folderPath string, existingFi
 			DeletedPaths: deletedPaths,
 			SkippedCount: skippedCount,
 			TotalFiles:   totalFiles,
+			Warnings:     warnings,
+			IgnoredCount: ignored,
 		}
 		jsonBytes, _ := json.Marshal(result)
 		return string(jsonBytes), nil
@@ -854,6 +898,7 @@ func scanLibraryFolderIncrementalWithExistingFiles(folderPath string, existingFi
 	results := make([]LibraryScanResult, 0, len(filesToScan))
 	scanTime := time.Now().UTC().Format(time.RFC3339)
 	errorCount := 0
+	var incomplete []IncompleteFile
 
 	cueReferencedAudioFilesInc := make(map[string]bool)
 	parsedCueFiles := make(map[string]scannedCueFileInfo)
@@ -874,6 +919,8 @@ func scanLibraryFolderIncrementalWithExistingFiles(folderPath string, existingFi
 		}
 	}
 
+	eta := newBatchETA(libraryFileSizes(filesToScan), 1)
+	var itemStarted time.Time
 	for i, f := range filesToScan {
 		select {
 		case <-cancelCh:
@@ -881,10 +928,15 @@ func scanLibraryFolderIncrementalWithExistingFiles(folderPath string, existingFi
 		default:
 		}
 
+		if i > 0 {
+			eta.done(filesToScan[i-1].size, time.Since(itemStarted))
+		}
+		itemStarted = time.Now()
 		libraryScanProgressMu.Lock()
 		libraryScanProgress.ScannedFiles = skippedCount + i + 1
 		libraryScanProgress.CurrentFile = filepath.Base(f.path)
 		libraryScanProgress.ProgressPct = float64(skippedCount+i+1) / float64(totalFiles) * 100
+		libraryScanProgress.EstimatedRemainingMs = eta.remainingMs()
 		libraryScanProgressMu.Unlock()
 
 		ext := strings.ToLower(filepath.Ext(f.path))
@@ -919,6 +971,10 @@ func scanLibraryFolderIncrementalWithExistingFiles(folderPath string, existingFi
 		}
 
 		result, err := scanAudioFileWithKnownModTime(f.path, scanTime, f.modTime)
+		if isIncompleteFileError(err) {
+			incomplete = append(incomplete, newIncompleteFile(f.path, f.modTime, err))
+			continue
+		}
 		if err != nil {
 			errorCount++
 			GoLog("[LibraryScan] Error scanning %s: %v\n", f.path, err)
@@ -930,19 +986,24 @@ func scanLibraryFolderIncrementalWithExistingFiles(folderPath string, existingFi
 
 	libraryScanProgressMu.Lock()
 	libraryScanProgress.ErrorCount = errorCount
+	libraryScanProgress.Incomplete = incomplete
 	libraryScanProgress.IsComplete = true
 	libraryScanProgress.ScannedFiles = totalFiles
 	libraryScanProgress.ProgressPct = 100
+	libraryScanProgress.EstimatedRemainingMs = 0
 	libraryScanProgressMu.Unlock()
 
-	GoLog("[LibraryScan] Incremental scan complete: %d scanned, %d skipped, %d deleted, %d errors\n",
-		len(results), skippedCount, len(deletedPaths), errorCount)
+	GoLog("[LibraryScan] Incremental scan complete: %d scanned, %d skipped, %d deleted, %d errors, %d incomplete\n",
+		len(results), skippedCount, len(deletedPaths), errorCount, len(incomplete))
 
 	scanResult := IncrementalScanResult{
 		Scanned:      results,
 		DeletedPaths: deletedPaths,
 		SkippedCount: skippedCount,
 		TotalFiles:   totalFiles,
+		Warnings:     warnings,
+		Incomplete:   incomplete,
+		IgnoredCount: ignored,
 	}
 
 	jsonBytes, err := json.Marshal(scanResult)
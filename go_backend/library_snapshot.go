@@ -0,0 +1,463 @@
+package gobackend
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const librarySnapshotVersion = 1
+
+// Names inside a library snapshot archive.
+const (
+	snapshotManifestName = "manifest.json"
+	snapshotCoverDir     = "covers/"
+	snapshotAudioDir     = "audio/"
+)
+
+// How ImportLibrarySnapshot matched a snapshot entry to a file.
+const (
+	// SnapshotMatchHash is a file with the same audio hash; only these are
+	// written to.
+	SnapshotMatchHash = "hash"
+	// SnapshotMatchISRC is a file with the same ISRC but different audio,
+	// possibly another master, reported for review.
+	SnapshotMatchISRC = "isrc"
+	// SnapshotMatchAmbiguous is an entry whose hash or ISRC several files
+	// share.
+	SnapshotMatchAmbiguous = "ambiguous"
+	SnapshotMatchNone      = "none"
+)
+
+// LibrarySnapshotEntry is one audio file of a snapshot. Path is relative to
+// the exported root with forward slashes. Tags holds the Vorbis comments of
+// a FLAC file without pictures, Lyrics the text of its .lrc sidecar, and
+// Cover the archive name of its cover image. Archived is set when the
+// audio itself is in the archive.
+type LibrarySnapshotEntry struct {
+	Path      string              `json:"path"`
+	Size      int64               `json:"size"`
+	AudioHash string              `json:"audio_hash"`
+	HashKind  string              `json:"hash_kind"`
+	ISRC      string              `json:"isrc,omitempty"`
+	Tags      map[string][]string `json:"tags,omitempty"`
+	Lyrics    string              `json:"lyrics,omitempty"`
+	Cover     string              `json:"cover,omitempty"`
+	Archived  bool                `json:"archived,omitempty"`
+}
+
+type LibrarySnapshot struct {
+	Version       int                    `json:"version"`
+	GeneratedAt   string                 `json:"generated_at"`
+	IncludesAudio bool                   `json:"includes_audio"`
+	Files         []LibrarySnapshotEntry `json:"files"`
+}
+
+// LibrarySnapshotSummary is the result of ExportLibrarySnapshot.
+type LibrarySnapshotSummary struct {
+	Root          string `json:"root"`
+	Archive       string `json:"archive"`
+	Files         int    `json:"files"`
+	Covers        int    `json:"covers"`
+	Lyrics        int    `json:"lyrics"`
+	IncludesAudio bool   `json:"includes_audio"`
+	Bytes         int64  `json:"bytes"`
+}
+
+// SnapshotConflict is a field the target file already has with another
+// value than the snapshot. It is not overwritten.
+type SnapshotConflict struct {
+	Field    string   `json:"field"`
+	Snapshot []string `json:"snapshot"`
+	Current  []string `json:"current"`
+}
+
+type SnapshotImportFile struct {
+	Path  string `json:"path"`
+	Match string `json:"match"`
+	// Target is the file written to, set for a hash match.
+	Target string `json:"target,omitempty"`
+	// Candidates are the files of an ISRC or ambiguous match.
+	Candidates []string           `json:"candidates,omitempty"`
+	Applied    []string           `json:"applied,omitempty"`
+	Conflicts  []SnapshotConflict `json:"conflicts,omitempty"`
+	Error      string             `json:"error,omitempty"`
+}
+
+type SnapshotImportReport struct {
+	Snapshot  string               `json:"snapshot"`
+	Root      string               `json:"root"`
+	DryRun    bool                 `json:"dry_run"`
+	Entries   int                  `json:"entries"`
+	Matched   int                  `json:"matched"`
+	Partial   int                  `json:"partial"`
+	Unmatched int                  `json:"unmatched"`
+	Changed   int                  `json:"changed"`
+	Conflicts int                  `json:"conflicts"`
+	Failed    int                  `json:"failed"`
+	Files     []SnapshotImportFile `json:"files"`
+}
+
+// lyricsSidecarPath is the .lrc file next to an audio file.
+func lyricsSidecarPath(filePath string) string {
+	return strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".lrc"
+}
+
+// snapshotTags reads the Vorbis comments of a FLAC file for a snapshot,
+// without embedded pictures. Other formats have none.
+func snapshotTags(filePath string) (map[string][]string, error) {
+	if !strings.EqualFold(filepath.Ext(filePath), ".flac") {
+		return nil, nil
+	}
+	tags, err := GetTags(filePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	delete(tags, commentPictureKey)
+	return tags, nil
+}
+
+// ExportLibrarySnapshot writes the library under rootPath to a zip archive
+// at outPath for moving it to another device: a JSON manifest with every
+// audio file's audio hash, ISRC and tags, its .lrc lyrics, and its cover,
+// stored once per distinct image. With includeAudio the audio files are
+// stored too. ImportLibrarySnapshot reapplies a snapshot.
+func ExportLibrarySnapshot(rootPath, outPath string, includeAudio bool) (string, error) {
+	if strings.TrimSpace(outPath) == "" {
+		return "", fmt.Errorf("output path is empty")
+	}
+	relPaths, err := checksumLibraryFiles(rootPath)
+	if err != nil {
+		return "", err
+	}
+	entries, errs, err := hashChecksumFiles(context.Background(), rootPath, relPaths, nil)
+	if err != nil {
+		return "", err
+	}
+	for i, err := range errs {
+		if err != nil {
+			return "", fmt.Errorf("failed to hash %s: %w", relPaths[i], err)
+		}
+	}
+
+	tmpPath := outPath + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	summary, err := writeLibrarySnapshot(out, rootPath, entries, includeAudio)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, outPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	summary.Root, summary.Archive = rootPath, outPath
+	if info, err := os.Stat(outPath); err == nil {
+		summary.Bytes = info.Size()
+	}
+	GoLog("[Snapshot] Exported %d files, %d covers under %s to %s (audio: %v)\n",
+		summary.Files, summary.Covers, rootPath, outPath, includeAudio)
+
+	jsonBytes, err := json.Marshal(summary)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+func writeLibrarySnapshot(w io.Writer, rootPath string, hashes []ChecksumEntry, includeAudio bool) (LibrarySnapshotSummary, error) {
+	archive := zip.NewWriter(w)
+	summary := LibrarySnapshotSummary{IncludesAudio: includeAudio}
+	snapshot := LibrarySnapshot{
+		Version:       librarySnapshotVersion,
+		GeneratedAt:   time.Now().UTC().Format(time.RFC3339),
+		IncludesAudio: includeAudio,
+		Files:         make([]LibrarySnapshotEntry, 0, len(hashes)),
+	}
+	covers := make(map[string]bool)
+
+	for _, hash := range hashes {
+		filePath := filepath.Join(rootPath, filepath.FromSlash(hash.Path))
+		entry := LibrarySnapshotEntry{Path: hash.Path, Size: hash.Size, AudioHash: hash.Hash, HashKind: hash.Kind}
+		tags, err := snapshotTags(filePath)
+		if err != nil {
+			return summary, fmt.Errorf("failed to read tags of %s: %w", hash.Path, err)
+		}
+		entry.Tags = tags
+		if isrc := tags["ISRC"]; len(isrc) > 0 {
+			entry.ISRC = isrc[0]
+		}
+		if lyrics, err := os.ReadFile(lyricsSidecarPath(filePath)); err == nil {
+			entry.Lyrics = string(lyrics)
+			summary.Lyrics++
+		}
+		if data, _, err := extractAnyCoverArt(filePath); err == nil && len(data) > 0 {
+			sum := sha256.Sum256(data)
+			entry.Cover = snapshotCoverDir + albumArtTargetName(hex.EncodeToString(sum[:16]), detectCoverMIME("", data))
+			if !covers[entry.Cover] {
+				covers[entry.Cover] = true
+				if err := writeZipFile(archive, entry.Cover, bytes.NewReader(data), zip.Store); err != nil {
+					return summary, err
+				}
+			}
+		}
+		if includeAudio {
+			f, err := os.Open(filePath)
+			if err != nil {
+				return summary, err
+			}
+			err = writeZipFile(archive, snapshotAudioDir+hash.Path, f, zip.Store)
+			f.Close()
+			if err != nil {
+				return summary, err
+			}
+			entry.Archived = true
+		}
+		snapshot.Files = append(snapshot.Files, entry)
+	}
+
+	manifest, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return summary, err
+	}
+	if err := writeZipFile(archive, snapshotManifestName, bytes.NewReader(manifest), zip.Deflate); err != nil {
+		return summary, err
+	}
+	summary.Files, summary.Covers = len(snapshot.Files), len(covers)
+	return summary, archive.Close()
+}
+
+func writeZipFile(archive *zip.Writer, name string, r io.Reader, method uint16) error {
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyBuffer(w, r, make([]byte, flacRewriteChunkSize))
+	return err
+}
+
+func readZipFile(archive *zip.Reader, name string) ([]byte, error) {
+	f, err := archive.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// snapshotTargets indexes the audio files under rootPath by audio hash and,
+// for FLAC files, by ISRC.
+type snapshotTargets struct {
+	byHash map[string][]string
+	byISRC map[string][]string
+}
+
+func indexSnapshotTargets(rootPath string) (snapshotTargets, error) {
+	targets := snapshotTargets{byHash: make(map[string][]string), byISRC: make(map[string][]string)}
+	relPaths, err := checksumLibraryFiles(rootPath)
+	if err != nil {
+		return targets, err
+	}
+	entries, errs, err := hashChecksumFiles(context.Background(), rootPath, relPaths, nil)
+	if err != nil {
+		return targets, err
+	}
+	for i, entry := range entries {
+		filePath := filepath.Join(rootPath, filepath.FromSlash(relPaths[i]))
+		if errs[i] != nil {
+			GoLog("[Snapshot] Skipping unreadable %s: %v\n", filePath, errs[i])
+			continue
+		}
+		targets.byHash[entry.Hash] = append(targets.byHash[entry.Hash], filePath)
+		if !strings.EqualFold(filepath.Ext(filePath), ".flac") {
+			continue
+		}
+		if tags, err := GetTags(filePath, []string{"ISRC"}); err == nil && len(tags["ISRC"]) > 0 {
+			isrc := strings.ToUpper(tags["ISRC"][0])
+			targets.byISRC[isrc] = append(targets.byISRC[isrc], filePath)
+		}
+	}
+	return targets, nil
+}
+
+// match finds the file an entry belongs to. Only a single hash match is
+// returned as the target; anything else comes back as candidates.
+func (t snapshotTargets) match(entry LibrarySnapshotEntry) (match, target string, candidates []string) {
+	if paths := t.byHash[entry.AudioHash]; len(paths) == 1 {
+		return SnapshotMatchHash, paths[0], nil
+	} else if len(paths) > 1 {
+		return SnapshotMatchAmbiguous, "", paths
+	}
+	if entry.ISRC == "" {
+		return SnapshotMatchNone, "", nil
+	}
+	if paths := t.byISRC[strings.ToUpper(entry.ISRC)]; len(paths) == 1 {
+		return SnapshotMatchISRC, "", paths
+	} else if len(paths) > 1 {
+		return SnapshotMatchAmbiguous, "", paths
+	}
+	return SnapshotMatchNone, "", nil
+}
+
+// applySnapshotEntry writes the tags, cover and lyrics of entry that
+// target lacks and reports those it has with other values.
+func applySnapshotEntry(archive *zip.Reader, entry LibrarySnapshotEntry, target string, dryRun bool, file *SnapshotImportFile) error {
+	isFLAC := strings.EqualFold(filepath.Ext(target), ".flac")
+	if isFLAC && len(entry.Tags) > 0 {
+		current, err := GetTags(target, nil)
+		if err != nil {
+			return err
+		}
+		missing := make(map[string][]string)
+		keys := make([]string, 0, len(entry.Tags))
+		for key := range entry.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			values := entry.Tags[key]
+			switch {
+			case len(current[key]) == 0:
+				missing[key] = values
+				file.Applied = append(file.Applied, key)
+			case !slices.Equal(current[key], values):
+				file.Conflicts = append(file.Conflicts, SnapshotConflict{Field: key, Snapshot: values, Current: current[key]})
+			}
+		}
+		if len(missing) > 0 && !dryRun {
+			err := editVorbisComments(target, "import_snapshot", func(comments *vorbisCommentMap) bool {
+				for _, key := range keys {
+					if values, ok := missing[key]; ok {
+						comments.setValues(key, values)
+					}
+				}
+				return true
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if entry.Cover != "" && isFLAC {
+		cover, err := readZipFile(archive, entry.Cover)
+		if err != nil {
+			return fmt.Errorf("snapshot cover %s: %w", entry.Cover, err)
+		}
+		existing, _, _ := extractAnyCoverArt(target)
+		switch {
+		case len(existing) == 0:
+			file.Applied = append(file.Applied, "cover")
+			if !dryRun {
+				if failures := embedCoverBatch([]string{target}, cover); failures[target] != nil {
+					return failures[target]
+				}
+			}
+		case !bytes.Equal(existing, cover):
+			file.Conflicts = append(file.Conflicts, SnapshotConflict{Field: "cover", Snapshot: []string{entry.Cover}, Current: []string{"embedded"}})
+		}
+	}
+
+	if entry.Lyrics != "" {
+		sidecar := lyricsSidecarPath(target)
+		existing, err := os.ReadFile(sidecar)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			file.Applied = append(file.Applied, "lyrics")
+			if !dryRun {
+				if err := os.WriteFile(sidecar, []byte(entry.Lyrics), 0644); err != nil {
+					return classifyWriteError(sidecar, err)
+				}
+			}
+		case err != nil:
+			return err
+		case string(existing) != entry.Lyrics:
+			file.Conflicts = append(file.Conflicts, SnapshotConflict{Field: "lyrics", Snapshot: []string{entry.Lyrics}, Current: []string{string(existing)}})
+		}
+	}
+	return nil
+}
+
+// ImportLibrarySnapshot reapplies a snapshot written by
+// ExportLibrarySnapshot to the library under rootPath. Each entry is
+// matched to the file with the same audio hash, which gets the snapshot's
+// tags, cover and lyrics it does not have yet. Fields the file has with
+// another value are reported as conflicts and left alone, and so are
+// entries matched only by ISRC or to several files; nothing is written for
+// them. With dryRun nothing is written at all.
+func ImportLibrarySnapshot(snapshotPath, rootPath string, dryRun bool) (string, error) {
+	archive, err := zip.OpenReader(snapshotPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer archive.Close()
+	data, err := readZipFile(&archive.Reader, snapshotManifestName)
+	if err != nil {
+		return "", fmt.Errorf("snapshot has no manifest: %w", err)
+	}
+	var snapshot LibrarySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return "", fmt.Errorf("invalid snapshot manifest: %w", err)
+	}
+	if snapshot.Version != librarySnapshotVersion {
+		return "", fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+	if !dryRun {
+		if err := checkWriteAllowed(rootPath); err != nil {
+			return "", err
+		}
+	}
+	targets, err := indexSnapshotTargets(rootPath)
+	if err != nil {
+		return "", err
+	}
+
+	report := SnapshotImportReport{Snapshot: snapshotPath, Root: rootPath, DryRun: dryRun, Entries: len(snapshot.Files), Files: []SnapshotImportFile{}}
+	for _, entry := range snapshot.Files {
+		file := SnapshotImportFile{Path: entry.Path}
+		file.Match, file.Target, file.Candidates = targets.match(entry)
+		switch file.Match {
+		case SnapshotMatchHash:
+			report.Matched++
+			if err := applySnapshotEntry(&archive.Reader, entry, file.Target, dryRun, &file); err != nil {
+				file.Error = err.Error()
+				report.Failed++
+			} else if len(file.Applied) > 0 {
+				report.Changed++
+			}
+			if len(file.Conflicts) > 0 {
+				report.Conflicts++
+			}
+		case SnapshotMatchNone:
+			report.Unmatched++
+		default:
+			report.Partial++
+		}
+		report.Files = append(report.Files, file)
+	}
+	GoLog("[Snapshot] Imported %s into %s: %d matched, %d changed, %d partial, %d unmatched, %d with conflicts (dry run: %v)\n",
+		snapshotPath, rootPath, report.Matched, report.Changed, report.Partial, report.Unmatched, report.Conflicts, dryRun)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
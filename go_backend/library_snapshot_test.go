@@ -0,0 +1,151 @@
+package gobackend
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeSnapshotFixture writes a FLAC file under root whose audio differs
+// from the other fixtures by variant.
+func writeSnapshotFixture(t *testing.T, root, rel string, md Metadata, variant byte) string {
+	t.Helper()
+	path := writeConsistencyFixture(t, root, rel, md)
+	data := mustReadFile(t, path)
+	data[len(data)-1] ^= variant
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
+
+func zipNames(t *testing.T, archivePath string) []string {
+	t.Helper()
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		t.Fatalf("OpenReader: %v", err)
+	}
+	defer archive.Close()
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func TestLibrarySnapshotExportAndImport(t *testing.T) {
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+
+	src := t.TempDir()
+	a := writeSnapshotFixture(t, src, "A/a.flac", Metadata{Title: "A", ISRC: "USAAA0000001"}, 1)
+	if err := SetTags(a, []TagPair{{Key: "RATING", Value: "80"}}, true); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+	if failures := embedCoverBatch([]string{a}, cover); len(failures) > 0 {
+		t.Fatalf("embedCoverBatch: %v", failures)
+	}
+	if err := os.WriteFile(lyricsSidecarPath(a), []byte("[00:01.00]la la"), 0644); err != nil {
+		t.Fatalf("write lyrics: %v", err)
+	}
+	writeSnapshotFixture(t, src, "A/b.flac", Metadata{Title: "B", ISRC: "USBBB0000002"}, 2)
+	writeSnapshotFixture(t, src, "B/c.flac", Metadata{Title: "C", ISRC: "USCCC0000003"}, 3)
+	writeSnapshotFixture(t, src, "B/d.flac", Metadata{Title: "D"}, 4)
+
+	archivePath := filepath.Join(t.TempDir(), "library.zip")
+	out, err := ExportLibrarySnapshot(src, archivePath, false)
+	summary := mustDecodeJSON[LibrarySnapshotSummary](t, out, err)
+	if summary.Files != 4 || summary.Covers != 1 || summary.Lyrics != 1 || summary.IncludesAudio {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if names := zipNames(t, archivePath); len(names) != 2 || !slices.Contains(names, snapshotManifestName) {
+		t.Fatalf("metadata-only archive holds %q", names)
+	}
+
+	fullPath := filepath.Join(t.TempDir(), "full.zip")
+	if _, err := ExportLibrarySnapshot(src, fullPath, true); err != nil {
+		t.Fatalf("ExportLibrarySnapshot with audio: %v", err)
+	}
+	if names := zipNames(t, fullPath); !slices.Contains(names, snapshotAudioDir+"A/a.flac") || len(names) != 6 {
+		t.Fatalf("full archive holds %q", names)
+	}
+
+	dst := t.TempDir()
+	newA := writeSnapshotFixture(t, dst, "x/a.flac", Metadata{}, 1)
+	newB := writeSnapshotFixture(t, dst, "x/b.flac", Metadata{Title: "B (Remaster)", ISRC: "USBBB0000002"}, 2)
+	newC := writeSnapshotFixture(t, dst, "x/c.flac", Metadata{Title: "Other C", ISRC: "usccc0000003"}, 9)
+	untouched := mustReadFile(t, newC)
+	beforeA := mustReadFile(t, newA)
+
+	importSnapshot := func(dryRun bool) (SnapshotImportReport, map[string]SnapshotImportFile) {
+		t.Helper()
+		out, err := ImportLibrarySnapshot(archivePath, dst, dryRun)
+		report := mustDecodeJSON[SnapshotImportReport](t, out, err)
+		files := make(map[string]SnapshotImportFile)
+		for _, file := range report.Files {
+			files[file.Path] = file
+		}
+		return report, files
+	}
+
+	report, files := importSnapshot(true)
+	if report.Entries != 4 || report.Matched != 2 || report.Partial != 1 || report.Unmatched != 1 || report.Changed != 1 || report.Conflicts != 1 {
+		t.Fatalf("dry run report = %+v", report)
+	}
+	if string(mustReadFile(t, newA)) != string(beforeA) {
+		t.Fatal("dry run modified a file")
+	}
+	if _, err := os.Stat(lyricsSidecarPath(newA)); !os.IsNotExist(err) {
+		t.Fatalf("dry run wrote lyrics: %v", err)
+	}
+
+	report, files = importSnapshot(false)
+	if report.Changed != 1 || report.Failed != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+	fileA := files["A/a.flac"]
+	for _, field := range []string{"TITLE", "ISRC", "RATING", "cover", "lyrics"} {
+		if !slices.Contains(fileA.Applied, field) {
+			t.Fatalf("%s not applied to a: %+v", field, fileA)
+		}
+	}
+	tags, err := GetTags(newA, nil)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if !slices.Equal(tags["TITLE"], []string{"A"}) || !slices.Equal(tags["RATING"], []string{"80"}) {
+		t.Fatalf("tags of a = %v", tags)
+	}
+	if got, err := ExtractCoverArt(newA); err != nil || string(got) != string(cover) {
+		t.Fatalf("cover of a not restored: %v", err)
+	}
+	if lyrics, err := os.ReadFile(lyricsSidecarPath(newA)); err != nil || string(lyrics) != "[00:01.00]la la" {
+		t.Fatalf("lyrics of a = %q %v", lyrics, err)
+	}
+
+	fileB := files["A/b.flac"]
+	if len(fileB.Conflicts) != 1 || fileB.Conflicts[0].Field != "TITLE" {
+		t.Fatalf("b conflicts = %+v", fileB)
+	}
+	if tags, _ := GetTags(newB, []string{"TITLE"}); !slices.Equal(tags["TITLE"], []string{"B (Remaster)"}) {
+		t.Fatalf("conflicting title overwritten: %v", tags)
+	}
+
+	if fileC := files["B/c.flac"]; fileC.Match != SnapshotMatchISRC || len(fileC.Candidates) != 1 || fileC.Target != "" {
+		t.Fatalf("c = %+v", fileC)
+	}
+	if string(mustReadFile(t, newC)) != string(untouched) {
+		t.Fatal("ISRC-only match was written to")
+	}
+	if files["B/d.flac"].Match != SnapshotMatchNone {
+		t.Fatalf("d = %+v", files["B/d.flac"])
+	}
+
+	if report, _ := importSnapshot(false); report.Changed != 0 {
+		t.Fatalf("second import changed files: %+v", report)
+	}
+}
@@ -0,0 +1,120 @@
+package gobackend
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Loudness comments written by other taggers next to the REPLAYGAIN_* ones.
+// R128_*_GAIN is the Opus convention (RFC 7845): a signed Q7.8 integer, in
+// 1/256 dB, relative to the EBU R128 reference of -23 LUFS.
+const (
+	r128TrackGainTag               = "R128_TRACK_GAIN"
+	r128AlbumGainTag               = "R128_ALBUM_GAIN"
+	replayGainReferenceLoudnessTag = "REPLAYGAIN_REFERENCE_LOUDNESS"
+)
+
+// r128ReplayGainOffsetDB is how much louder the ReplayGain 2.0 reference
+// (-18 LUFS) is than the R128 one (-23 LUFS). An R128 gain is the
+// ReplayGain gain minus this offset.
+const r128ReplayGainOffsetDB = 5.0
+
+// r128GainTags pairs each ReplayGain gain comment with its R128 equivalent.
+var r128GainTags = [][2]string{
+	{"REPLAYGAIN_TRACK_GAIN", r128TrackGainTag},
+	{"REPLAYGAIN_ALBUM_GAIN", r128AlbumGainTag},
+}
+
+// parseR128Gain converts a Q7.8 R128 gain to dB. Anything but a plain
+// integer in the signed 16-bit range is rejected.
+func parseR128Gain(value string) (float64, bool) {
+	q, err := strconv.ParseInt(strings.TrimSpace(value), 10, 16)
+	if err != nil {
+		return 0, false
+	}
+	return float64(q) / 256, true
+}
+
+// formatR128Gain converts a gain in dB to Q7.8, rounding to the nearest
+// 1/256 dB and clamping to the signed 16-bit range.
+func formatR128Gain(db float64) string {
+	q := math.Round(db * 256)
+	q = max(min(q, math.MaxInt16), math.MinInt16)
+	return strconv.Itoa(int(q))
+}
+
+// replayGainToR128 converts a ReplayGain gain comment ("-6.50 dB") to the
+// Q7.8 R128 gain that plays at the same level.
+func replayGainToR128(value string) (string, bool) {
+	db, ok := parseReplayGainDb(value)
+	if !ok {
+		return "", false
+	}
+	return formatR128Gain(db - r128ReplayGainOffsetDB), true
+}
+
+// Loudness is the unified view of a file's loudness comments. The text
+// fields hold the comments verbatim; the R128 gains are also given in dB,
+// both as stored (relative to -23 LUFS) and converted to the ReplayGain
+// reference so they compare with TrackGain and AlbumGain.
+type Loudness struct {
+	TrackGain         string `json:"track_gain,omitempty"`
+	TrackPeak         string `json:"track_peak,omitempty"`
+	AlbumGain         string `json:"album_gain,omitempty"`
+	AlbumPeak         string `json:"album_peak,omitempty"`
+	ReferenceLoudness string `json:"reference_loudness,omitempty"`
+
+	R128TrackGain     string   `json:"r128_track_gain,omitempty"`
+	R128AlbumGain     string   `json:"r128_album_gain,omitempty"`
+	R128TrackGainDB   *float64 `json:"r128_track_gain_db,omitempty"`
+	R128AlbumGainDB   *float64 `json:"r128_album_gain_db,omitempty"`
+	R128TrackGainAsRG *float64 `json:"r128_track_gain_as_replaygain_db,omitempty"`
+	R128AlbumGainAsRG *float64 `json:"r128_album_gain_as_replaygain_db,omitempty"`
+}
+
+// buildLoudness returns the Loudness view of the given comment values, or
+// nil when all of them are empty. Unparsable R128 gains keep their text but
+// get no dB values.
+func buildLoudness(trackGain, trackPeak, albumGain, albumPeak, reference, r128Track, r128Album string) *Loudness {
+	l := &Loudness{
+		TrackGain:         trackGain,
+		TrackPeak:         trackPeak,
+		AlbumGain:         albumGain,
+		AlbumPeak:         albumPeak,
+		ReferenceLoudness: reference,
+		R128TrackGain:     r128Track,
+		R128AlbumGain:     r128Album,
+	}
+	if *l == (Loudness{}) {
+		return nil
+	}
+	if db, ok := parseR128Gain(r128Track); ok {
+		asRG := db + r128ReplayGainOffsetDB
+		l.R128TrackGainDB, l.R128TrackGainAsRG = &db, &asRG
+	}
+	if db, ok := parseR128Gain(r128Album); ok {
+		asRG := db + r128ReplayGainOffsetDB
+		l.R128AlbumGainDB, l.R128AlbumGainAsRG = &db, &asRG
+	}
+	return l
+}
+
+func (m *AudioMetadata) loudness() *Loudness {
+	return buildLoudness(m.ReplayGainTrackGain, m.ReplayGainTrackPeak, m.ReplayGainAlbumGain, m.ReplayGainAlbumPeak,
+		m.ReplayGainReferenceLoudness, m.R128TrackGain, m.R128AlbumGain)
+}
+
+// syncR128Gain writes the R128 equivalent of every ReplayGain gain comment
+// that parses, when BackendConfig.WriteR128Gain is set. R128 comments
+// without a ReplayGain counterpart are left alone.
+func (m *vorbisCommentMap) syncR128Gain() {
+	if !GetBackendConfig().WriteR128Gain {
+		return
+	}
+	for _, pair := range r128GainTags {
+		if r128, ok := replayGainToR128(m.get(pair[0])); ok {
+			m.set(pair[1], r128)
+		}
+	}
+}
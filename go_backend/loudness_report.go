@@ -0,0 +1,207 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// replayGainTargetLUFS is the ReplayGain 2.0 reference loudness.
+const replayGainTargetLUFS = -18.0
+
+// AlbumLoudness is the measured loudness of the FLAC tracks of one album
+// directory. Tracks in other formats cannot be decoded and are only
+// counted as Skipped. IntegratedLUFS is gated over all tracks together,
+// as for an album gain, and is missing when every track is silent or
+// failed.
+type AlbumLoudness struct {
+	// Directory is relative to the scanned root ("." for the root itself).
+	Directory      string   `json:"directory"`
+	Tracks         int      `json:"tracks"`
+	Skipped        int      `json:"skipped,omitempty"`
+	Seconds        float64  `json:"seconds"`
+	IntegratedLUFS *float64 `json:"integrated_lufs,omitempty"`
+	TruePeakDBTP   float64  `json:"true_peak_dbtp"`
+	// DeviationLU is IntegratedLUFS minus replayGainTargetLUFS; GainDB is
+	// the album gain a ReplayGain 2.0 player applies, its negation.
+	DeviationLU *float64 `json:"deviation_lu,omitempty"`
+	GainDB      *float64 `json:"gain_db,omitempty"`
+	// ClipsAfterGain is set when GainDB would raise the true peak above
+	// full scale.
+	ClipsAfterGain bool              `json:"clips_after_gain,omitempty"`
+	Errors         map[string]string `json:"errors,omitempty"`
+}
+
+// LibraryLoudnessReport is the result of LoudnessReport. Details is sorted
+// by DeviationLU, loudest album (most attenuated) first, with the albums
+// that have no loudness last.
+type LibraryLoudnessReport struct {
+	Root       string          `json:"root"`
+	TargetLUFS float64         `json:"target_lufs"`
+	Albums     int             `json:"albums"`
+	Tracks     int             `json:"tracks"`
+	Resumed    int             `json:"resumed"`
+	Skipped    int             `json:"skipped"`
+	Failed     int             `json:"failed"`
+	Details    []AlbumLoudness `json:"details"`
+}
+
+// trackR128Scan returns the scan of track, from the journal when a previous
+// run finished it.
+func trackR128Scan(track string, journal *batchJournal) (*r128Scan, bool, error) {
+	if raw := journal.result(track); raw != nil {
+		var scan r128Scan
+		if err := json.Unmarshal(raw, &scan); err == nil {
+			return &scan, true, nil
+		}
+	}
+	scan, err := scanR128(track)
+	if err != nil {
+		journal.record(track, err)
+		return nil, false, err
+	}
+	journal.recordResult(track, scan)
+	return scan, false, nil
+}
+
+var loudnessReportProgress batchProgress
+
+// GetLoudnessReportProgress reports the running LoudnessReport, one item
+// per FLAC track. Tracks resumed from a journal count as done at once.
+func GetLoudnessReportProgress() string {
+	return loudnessReportProgress.json()
+}
+
+func buildLoudnessReport(rootPath string, journal *batchJournal) (*LibraryLoudnessReport, error) {
+	files, err := collectLibraryAudioFiles(rootPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	tracksByDir := make(map[string][]string)
+	sizes := make(map[string]int64)
+	var flacSizes []int64
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file.path), ".cue") {
+			continue
+		}
+		dir := filepath.Dir(file.path)
+		tracksByDir[dir] = append(tracksByDir[dir], file.path)
+		if strings.EqualFold(filepath.Ext(file.path), ".flac") {
+			sizes[file.path] = file.size
+			flacSizes = append(flacSizes, file.size)
+		}
+	}
+	loudnessReportProgress.start(flacSizes, 1)
+	defer loudnessReportProgress.finish()
+
+	report := &LibraryLoudnessReport{Root: rootPath, TargetLUFS: replayGainTargetLUFS, Details: []AlbumLoudness{}}
+	for dir, tracks := range tracksByDir {
+		sort.Strings(tracks)
+		report.Albums++
+
+		album := AlbumLoudness{Directory: dir}
+		if rel, err := filepath.Rel(rootPath, dir); err == nil {
+			album.Directory = rel
+		}
+		var total r128Scan
+		for _, track := range tracks {
+			if !strings.EqualFold(filepath.Ext(track), ".flac") {
+				album.Skipped++
+				continue
+			}
+			done := loudnessReportProgress.item(track, sizes[track])
+			scan, resumed, err := trackR128Scan(track, journal)
+			done()
+			if err != nil {
+				if album.Errors == nil {
+					album.Errors = make(map[string]string)
+				}
+				album.Errors[track] = err.Error()
+				report.Failed++
+				continue
+			}
+			if resumed {
+				report.Resumed++
+			}
+			album.Tracks++
+			total.merge(scan)
+		}
+		report.Tracks += album.Tracks
+		report.Skipped += album.Skipped
+
+		album.Seconds = total.Seconds
+		album.TruePeakDBTP = total.truePeakDBTP()
+		if lufs, ok := total.integrated(); ok {
+			deviation := lufs - replayGainTargetLUFS
+			gain := -deviation
+			album.IntegratedLUFS, album.DeviationLU, album.GainDB = &lufs, &deviation, &gain
+			album.ClipsAfterGain = album.TruePeakDBTP+gain > 0
+		}
+		report.Details = append(report.Details, album)
+	}
+
+	sort.Slice(report.Details, func(i, j int) bool {
+		a, b := report.Details[i], report.Details[j]
+		switch {
+		case (a.DeviationLU == nil) != (b.DeviationLU == nil):
+			return a.DeviationLU != nil
+		case a.DeviationLU != nil && *a.DeviationLU != *b.DeviationLU:
+			return *a.DeviationLU > *b.DeviationLU
+		}
+		return a.Directory < b.Directory
+	})
+	return report, nil
+}
+
+// LoudnessReport decodes every FLAC track under rootPath and reports the
+// integrated loudness and true peak of each album directory, measured as
+// EBU R128 does, sorted by how far the album is from the -18 LUFS
+// ReplayGain reference. No tags are written.
+func LoudnessReport(rootPath string) (string, error) {
+	return LoudnessReportWithJournal(rootPath, "")
+}
+
+// LoudnessReportWithJournal is LoudnessReport that records the measurement
+// of each track in the journal at journalPath. If the scan is interrupted,
+// calling it again with the same journal, or Resume(journalPath), measures
+// only the tracks not yet done and still reports the whole library.
+func LoudnessReportWithJournal(rootPath, journalPath string) (string, error) {
+	if strings.TrimSpace(rootPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(rootPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	journal, err := openBatchJournal(journalPath, batchOpLoudnessReport, loudnessReportJournalArgs{Root: rootPath})
+	if err != nil {
+		return "", err
+	}
+	defer journal.close()
+
+	report, err := buildLoudnessReport(rootPath, journal)
+	if err != nil {
+		return "", err
+	}
+	journal.finish()
+
+	GoLog("[Loudness] %d albums, %d tracks measured under %s (%d resumed, %d failed)\n",
+		report.Albums, report.Tracks, rootPath, report.Resumed, report.Failed)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
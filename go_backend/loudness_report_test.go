@@ -0,0 +1,80 @@
+package gobackend
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoudnessReport(t *testing.T) {
+	root := t.TempDir()
+	writeSineFLAC(t, filepath.Join(root, "Quiet", "01.flac"), 2, 0.05, 2)
+	writeSineFLAC(t, filepath.Join(root, "Loud", "01.flac"), 2, 0.5, 2)
+	writeSineFLAC(t, filepath.Join(root, "Loud", "02.flac"), 2, 0.5, 1)
+	writeSineFLAC(t, filepath.Join(root, "Silent", "01.flac"), 2, 0, 1)
+	if err := os.WriteFile(filepath.Join(root, "Loud", "03.mp3"), []byte("ID3"), 0644); err != nil {
+		t.Fatalf("write mp3: %v", err)
+	}
+	before, _ := os.Stat(filepath.Join(root, "Loud", "01.flac"))
+
+	out, err := LoudnessReport(root)
+	report := mustDecodeJSON[LibraryLoudnessReport](t, out, err)
+	if report.Albums != 3 || report.Tracks != 4 || report.Skipped != 1 || report.Failed != 0 || report.TargetLUFS != -18 {
+		t.Fatalf("report = %+v", report)
+	}
+	loud, quiet, silent := report.Details[0], report.Details[1], report.Details[2]
+	if loud.Directory != "Loud" || quiet.Directory != "Quiet" || silent.Directory != "Silent" {
+		t.Fatalf("order = %s, %s, %s", loud.Directory, quiet.Directory, silent.Directory)
+	}
+	if loud.Tracks != 2 || loud.Skipped != 1 || loud.Seconds != 3 || math.Abs(*loud.IntegratedLUFS+6.02) > 0.1 {
+		t.Fatalf("loud = %+v", loud)
+	}
+	if math.Abs(*loud.DeviationLU-11.98) > 0.1 || *loud.GainDB != -*loud.DeviationLU || loud.ClipsAfterGain {
+		t.Fatalf("loud gain = %v, %v", *loud.DeviationLU, *loud.GainDB)
+	}
+	// -26 LUFS needs +8 dB, which takes a -26 dBTP peak only to -18.
+	if math.Abs(*quiet.GainDB-8) > 0.1 || quiet.ClipsAfterGain {
+		t.Fatalf("quiet = %+v", quiet)
+	}
+	if silent.IntegratedLUFS != nil || silent.GainDB != nil || silent.Tracks != 1 {
+		t.Fatalf("silent = %+v", silent)
+	}
+
+	if after, _ := os.Stat(filepath.Join(root, "Loud", "01.flac")); !after.ModTime().Equal(before.ModTime()) || after.Size() != before.Size() {
+		t.Fatal("LoudnessReport modified a track")
+	}
+}
+
+func TestLoudnessReportResumesFromJournal(t *testing.T) {
+	root := t.TempDir()
+	first := filepath.Join(root, "Album", "01.flac")
+	second := filepath.Join(root, "Album", "02.flac")
+	writeSineFLAC(t, first, 2, 0.5, 1)
+	writeSineFLAC(t, second, 2, 0.5, 1)
+	journalPath := filepath.Join(t.TempDir(), "loudness.jsonl")
+
+	// A run killed after the first track; its recorded result stands in
+	// for a rescan, so the resumed report must use it as is.
+	journal, err := openBatchJournal(journalPath, batchOpLoudnessReport, loudnessReportJournalArgs{Root: root})
+	if err != nil {
+		t.Fatalf("openBatchJournal: %v", err)
+	}
+	journal.recordResult(first, &r128Scan{TruePeak: 1, Seconds: 60})
+	journal.close()
+
+	out, err := Resume(journalPath)
+	report := mustDecodeJSON[LibraryLoudnessReport](t, out, err)
+	album := report.Details[0]
+	if report.Resumed != 1 || report.Tracks != 2 || album.Seconds != 61 || album.TruePeakDBTP != 0 || album.IntegratedLUFS == nil {
+		t.Fatalf("resumed report = %+v", report)
+	}
+
+	parsed, err := readBatchJournal(journalPath)
+	if err != nil || !parsed.complete || parsed.results[second] == nil {
+		t.Fatalf("journal = %+v, %v", parsed, err)
+	}
+	if _, err := Resume(journalPath); err == nil {
+		t.Fatal("Resume of a finished loudness report succeeded")
+	}
+}
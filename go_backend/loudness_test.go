@@ -0,0 +1,157 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestParseR128Gain(t *testing.T) {
+	cases := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"-1280", -5, true},
+		{"256", 1, true},
+		{"0", 0, true},
+		{" -3 ", -0.01171875, true},
+		{"-32768", -128, true},
+		{"32767", 127.99609375, true},
+		{"32768", 0, false},
+		{"-32769", 0, false},
+		{"1.5", 0, false},
+		{"-5 dB", 0, false},
+		{"", 0, false},
+	}
+	for _, c := range cases {
+		if got, ok := parseR128Gain(c.in); got != c.want || ok != c.ok {
+			t.Fatalf("parseR128Gain(%q) = %v, %v; want %v, %v", c.in, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestFormatR128Gain(t *testing.T) {
+	cases := []struct {
+		db   float64
+		want string
+	}{
+		{-5, "-1280"},
+		{0, "0"},
+		{0.001, "0"},
+		{1.0 / 512, "1"},
+		{-1.0 / 512, "-1"},
+		{-11.5, "-2944"},
+		{127.99609375, "32767"},
+		{200, "32767"},
+		{-200, "-32768"},
+	}
+	for _, c := range cases {
+		if got := formatR128Gain(c.db); got != c.want {
+			t.Fatalf("formatR128Gain(%v) = %q, want %q", c.db, got, c.want)
+		}
+	}
+}
+
+func TestReplayGainToR128(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"-6.50 dB", "-2944", true},
+		{"+2.34 dB", "-681", true},
+		{"5.00 dB", "0", true},
+		{"-0.01", "-1283", true},
+		{"n/a", "", false},
+	}
+	for _, c := range cases {
+		if got, ok := replayGainToR128(c.in); got != c.want || ok != c.ok {
+			t.Fatalf("replayGainToR128(%q) = %q, %v; want %q, %v", c.in, got, ok, c.want, c.ok)
+		}
+	}
+
+	// The R128 gain converted back lands within 1/512 dB of the original.
+	r128, _ := replayGainToR128("-7.21 dB")
+	l := buildLoudness("", "", "", "", "", r128, "")
+	if l == nil || l.R128TrackGainAsRG == nil || *l.R128TrackGainAsRG < -7.21-1.0/512 || *l.R128TrackGainAsRG > -7.21+1.0/512 {
+		t.Fatalf("round trip of -7.21 dB via %s = %+v", r128, l)
+	}
+}
+
+func TestBuildLoudness(t *testing.T) {
+	if l := buildLoudness("", "", "", "", "", "", ""); l != nil {
+		t.Fatalf("empty loudness = %+v", l)
+	}
+	l := buildLoudness("-6.50 dB", "0.98", "", "", "89.0 dB", "-2944", "bad")
+	if l.TrackGain != "-6.50 dB" || l.ReferenceLoudness != "89.0 dB" || l.R128AlbumGain != "bad" {
+		t.Fatalf("loudness = %+v", l)
+	}
+	if *l.R128TrackGainDB != -11.5 || *l.R128TrackGainAsRG != -6.5 || l.R128AlbumGainDB != nil || l.R128AlbumGainAsRG != nil {
+		t.Fatalf("converted gains = %+v", l)
+	}
+}
+
+func TestReadMetadataLoudness(t *testing.T) {
+	path := writeMalformedCommentFLAC(t, "REPLAYGAIN_TRACK_GAIN=-6.50 dB", "R128_TRACK_GAIN=-1280", "REPLAYGAIN_REFERENCE_LOUDNESS=89.0 dB")
+	md, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if md.R128TrackGain != "-1280" || md.ReplayGainReferenceLoudness != "89.0 dB" || md.Loudness == nil {
+		t.Fatalf("metadata = %+v", md)
+	}
+	if *md.Loudness.R128TrackGainDB != -5 || *md.Loudness.R128TrackGainAsRG != 0 || md.Loudness.TrackGain != "-6.50 dB" {
+		t.Fatalf("loudness = %+v", md.Loudness)
+	}
+
+	out, err := ReadFileMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadFileMetadata: %v", err)
+	}
+	var result struct {
+		Loudness *Loudness `json:"loudness"`
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil || result.Loudness == nil || result.Loudness.R128TrackGain != "-1280" {
+		t.Fatalf("ReadFileMetadata loudness = %s %v", out, err)
+	}
+
+	plain := writeTestFLACWithMetadata(t, Metadata{Title: "x"})
+	if md, err := ReadMetadata(plain); err != nil || md.Loudness != nil {
+		t.Fatalf("file without loudness tags: %+v %v", md, err)
+	}
+}
+
+func TestWriteR128Gain(t *testing.T) {
+	path := writeMalformedCommentFLAC(t, "R128_ALBUM_GAIN=-100")
+	if err := EditFlacFields(path, map[string]string{"replaygain_track_gain": "-6.50 dB"}); err != nil {
+		t.Fatalf("EditFlacFields: %v", err)
+	}
+	if tags, _ := GetTags(path, []string{r128TrackGainTag}); tags[r128TrackGainTag] != nil {
+		t.Fatalf("R128 written without WriteR128Gain: %v", tags)
+	}
+
+	withBackendConfig(t, func(cfg *BackendConfig) { cfg.WriteR128Gain = true })
+	if err := EditFlacFields(path, map[string]string{"replaygain_track_gain": "-6.50 dB"}); err != nil {
+		t.Fatalf("EditFlacFields: %v", err)
+	}
+	tags, err := GetTags(path, nil)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if !slices.Equal(tags[r128TrackGainTag], []string{"-2944"}) || !slices.Equal(tags[r128AlbumGainTag], []string{"-100"}) {
+		t.Fatalf("tags = %v", tags)
+	}
+
+	if err := EditFlacFields(path, map[string]string{"replaygain_track_gain": ""}); err != nil {
+		t.Fatalf("EditFlacFields: %v", err)
+	}
+	if tags, _ := GetTags(path, nil); tags[r128TrackGainTag] != nil || tags[r128AlbumGainTag] == nil {
+		t.Fatalf("tags after clearing the track gain = %v", tags)
+	}
+
+	embedded := writeTestFLACWithMetadata(t, Metadata{ReplayGainAlbumGain: "-3.00 dB"})
+	if tags, _ := GetTags(embedded, []string{r128AlbumGainTag}); !slices.Equal(tags[r128AlbumGainTag], []string{"-2048"}) {
+		t.Fatalf("embed tags = %v", tags)
+	}
+}
@@ -1,7 +1,9 @@
 package gobackend
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
@@ -309,7 +311,7 @@ func NewLyricsClient() *LyricsClient {
 	}
 }
 
-func (c *LyricsClient) FetchLyricsWithMetadata(artist, track string) (*LyricsResponse, error) {
+func (c *LyricsClient) FetchLyricsWithMetadata(ctx context.Context, artist, track string) (*LyricsResponse, error) {
 	baseURL := "https://lrclib.net/api/get"
 	params := url.Values{}
 	params.Set("artist_name", artist)
@@ -317,13 +319,13 @@ func (c *LyricsClient) FetchLyricsWithMetadata(artist, track string) (*LyricsRes
 
 	fullURL := baseURL + "?" + params.Encode()
 
-	req, err := http.NewRequest("GET", fullURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("User-Agent", getRandomUserAgent())
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := doFetchWithRetry(c.httpClient, req, "lrclib lookup")
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch lyrics: %w", err)
 	}
@@ -345,20 +347,20 @@ func (c *LyricsClient) FetchLyricsWithMetadata(artist, track string) (*LyricsRes
 	return c.parseLRCLibResponse(&lrcResp), nil
 }
 
-func (c *LyricsClient) FetchLyricsFromLRCLibSearch(query string, durationSec float64) (*LyricsResponse, error) {
+func (c *LyricsClient) FetchLyricsFromLRCLibSearch(ctx context.Context, query string, durationSec float64) (*LyricsResponse, error) {
 	baseURL := "https://lrclib.net/api/search"
 	params := url.Values{}
 	params.Set("q", query)
 
 	fullURL := baseURL + "?" + params.Encode()
 
-	req, err := http.NewRequest("GET", fullURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("User-Agent", getRandomUserAgent())
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := doFetchWithRetry(c.httpClient, req, "lrclib search")
 	if err != nil {
 		return nil, fmt.Errorf("failed to search lyrics: %w", err)
 	}
@@ -433,6 +435,12 @@ func (c *LyricsClient) durationMatches(lrcDuration, targetDuration float64) bool
 }
 
 func (c *LyricsClient) FetchLyricsAllSources(spotifyID, trackName, artistName string, durationSec float64) (*LyricsResponse, error) {
+	return c.fetchLyricsAllSources(context.Background(), spotifyID, trackName, artistName, durationSec)
+}
+
+// fetchLyricsAllSources is FetchLyricsAllSources with the provider requests
+// bound to ctx, so cancelling it aborts a fetch already in flight.
+func (c *LyricsClient) fetchLyricsAllSources(ctx context.Context, spotifyID, trackName, artistName string, durationSec float64) (*LyricsResponse, error) {
 	primaryArtist := normalizeArtistName(artistName)
 	fetchOptions := GetLyricsFetchOptions()
 
@@ -452,6 +460,9 @@ func (c *LyricsClient) FetchLyricsAllSources(spotifyID, trackName, artistName st
 		extensionProviders = extManager.GetLyricsProviders()
 	}
 
+	// providerErrs keeps every failure wrapped, so callers can still tell
+	// an offline or wifi-only failure from lyrics that do not exist.
+	var providerErrs []error
 	var cachedNonExtension *LyricsResponse
 	if cached, found := globalLyricsCache.Get(artistName, trackName, durationSec); found {
 		isExtensionCache := strings.HasPrefix(cached.Source, "Extension:")
@@ -483,6 +494,7 @@ func (c *LyricsClient) FetchLyricsAllSources(spotifyID, trackName, artistName st
 			}
 			if err != nil {
 				GoLog("[Lyrics] Extension %s failed: %v\n", provider.extension.ID, err)
+				providerErrs = append(providerErrs, fmt.Errorf("%s: %w", provider.extension.ID, err))
 			}
 		}
 	}
@@ -500,6 +512,9 @@ func (c *LyricsClient) FetchLyricsAllSources(spotifyID, trackName, artistName st
 	GoLog("[Lyrics] Searching for: %s - %s (providers: %v)\n", artistName, trackName, providerOrder)
 
 	for _, providerName := range providerOrder {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		GoLog("[Lyrics] Trying provider: %s\n", providerName)
 
 		var lyrics *LyricsResponse
@@ -507,11 +522,12 @@ func (c *LyricsClient) FetchLyricsAllSources(spotifyID, trackName, artistName st
 
 		switch providerName {
 		case LyricsProviderLRCLIB:
-			lyrics, err = c.tryLRCLIB(primaryArtist, artistName, trackName, simplifiedTrack, durationSec)
+			lyrics, err = c.tryLRCLIB(ctx, primaryArtist, artistName, trackName, simplifiedTrack, durationSec)
 
 		case LyricsProviderNetease:
 			neteaseClient := NewNeteaseClient()
 			lyrics, err = neteaseClient.FetchLyrics(
+				ctx,
 				trackName,
 				primaryArtist,
 				durationSec,
@@ -520,6 +536,7 @@ func (c *LyricsClient) FetchLyricsAllSources(spotifyID, trackName, artistName st
 			)
 			if err != nil && primaryArtist != artistName {
 				lyrics, err = neteaseClient.FetchLyrics(
+					ctx,
 					trackName,
 					artistName,
 					durationSec,
@@ -529,6 +546,7 @@ func (c *LyricsClient) FetchLyricsAllSources(spotifyID, trackName, artistName st
 			}
 			if err != nil && simplifiedTrack != trackName {
 				lyrics, err = neteaseClient.FetchLyrics(
+					ctx,
 					simplifiedTrack,
 					primaryArtist,
 					durationSec,
@@ -540,6 +558,7 @@ func (c *LyricsClient) FetchLyricsAllSources(spotifyID, trackName, artistName st
 		case LyricsProviderMusixmatch:
 			musixmatchClient := NewMusixmatchClient()
 			lyrics, err = musixmatchClient.FetchLyrics(
+				ctx,
 				trackName,
 				primaryArtist,
 				durationSec,
@@ -547,6 +566,7 @@ func (c *LyricsClient) FetchLyricsAllSources(spotifyID, trackName, artistName st
 			)
 			if err != nil && primaryArtist != artistName {
 				lyrics, err = musixmatchClient.FetchLyrics(
+					ctx,
 					trackName,
 					artistName,
 					durationSec,
@@ -556,68 +576,69 @@ func (c *LyricsClient) FetchLyricsAllSources(spotifyID, trackName, artistName st
 
 		case LyricsProviderAppleMusic:
 			appleClient := NewAppleMusicClient()
-			lyrics, err = appleClient.FetchLyrics(trackName, primaryArtist, durationSec, fetchOptions.MultiPersonWordByWord, fetchOptions.AppleElrcWordSync)
+			lyrics, err = appleClient.FetchLyrics(ctx, trackName, primaryArtist, durationSec, fetchOptions.MultiPersonWordByWord, fetchOptions.AppleElrcWordSync)
 			if err != nil && primaryArtist != artistName {
-				lyrics, err = appleClient.FetchLyrics(trackName, artistName, durationSec, fetchOptions.MultiPersonWordByWord, fetchOptions.AppleElrcWordSync)
+				lyrics, err = appleClient.FetchLyrics(ctx, trackName, artistName, durationSec, fetchOptions.MultiPersonWordByWord, fetchOptions.AppleElrcWordSync)
 			}
 
 		case LyricsProviderQQMusic:
 			qqClient := NewQQMusicClient()
-			lyrics, err = qqClient.FetchLyrics(trackName, primaryArtist, durationSec, fetchOptions.MultiPersonWordByWord)
+			lyrics, err = qqClient.FetchLyrics(ctx, trackName, primaryArtist, durationSec, fetchOptions.MultiPersonWordByWord)
 			if err != nil && primaryArtist != artistName {
-				lyrics, err = qqClient.FetchLyrics(trackName, artistName, durationSec, fetchOptions.MultiPersonWordByWord)
+				lyrics, err = qqClient.FetchLyrics(ctx, trackName, artistName, durationSec, fetchOptions.MultiPersonWordByWord)
 			}
 
 		case LyricsProviderSpotify:
 			spotifyClient := NewSpotifyLyricsClient()
-			lyrics, err = spotifyClient.FetchLyrics(spotifyID, trackName, primaryArtist, durationSec)
+			lyrics, err = spotifyClient.FetchLyrics(ctx, spotifyID, trackName, primaryArtist, durationSec)
 			if err != nil && primaryArtist != artistName {
-				lyrics, err = spotifyClient.FetchLyrics(spotifyID, trackName, artistName, durationSec)
+				lyrics, err = spotifyClient.FetchLyrics(ctx, spotifyID, trackName, artistName, durationSec)
 			}
 			if err != nil && simplifiedTrack != trackName {
-				lyrics, err = spotifyClient.FetchLyrics("", simplifiedTrack, primaryArtist, durationSec)
+				lyrics, err = spotifyClient.FetchLyrics(ctx, "", simplifiedTrack, primaryArtist, durationSec)
 			}
 
 		case LyricsProviderDeezer:
 			deezerClient := NewDeezerLyricsClient()
-			lyrics, err = deezerClient.FetchLyrics(spotifyID, trackName, primaryArtist, durationSec)
+			lyrics, err = deezerClient.FetchLyrics(ctx, spotifyID, trackName, primaryArtist, durationSec)
 			if err != nil && primaryArtist != artistName {
-				lyrics, err = deezerClient.FetchLyrics(spotifyID, trackName, artistName, durationSec)
+				lyrics, err = deezerClient.FetchLyrics(ctx, spotifyID, trackName, artistName, durationSec)
 			}
 
 		case LyricsProviderYouTube:
 			youtubeClient := NewYouTubeLyricsClient()
-			lyrics, err = youtubeClient.FetchLyrics(trackName, primaryArtist, durationSec)
+			lyrics, err = youtubeClient.FetchLyrics(ctx, trackName, primaryArtist, durationSec)
 			if err != nil && primaryArtist != artistName {
-				lyrics, err = youtubeClient.FetchLyrics(trackName, artistName, durationSec)
+				lyrics, err = youtubeClient.FetchLyrics(ctx, trackName, artistName, durationSec)
 			}
 			if err != nil && simplifiedTrack != trackName {
-				lyrics, err = youtubeClient.FetchLyrics(simplifiedTrack, primaryArtist, durationSec)
+				lyrics, err = youtubeClient.FetchLyrics(ctx, simplifiedTrack, primaryArtist, durationSec)
 			}
 
 		case LyricsProviderKugou:
 			kugouClient := NewKugouLyricsClient()
-			lyrics, err = kugouClient.FetchLyrics(trackName, primaryArtist, durationSec)
+			lyrics, err = kugouClient.FetchLyrics(ctx, trackName, primaryArtist, durationSec)
 			if err != nil && primaryArtist != artistName {
-				lyrics, err = kugouClient.FetchLyrics(trackName, artistName, durationSec)
+				lyrics, err = kugouClient.FetchLyrics(ctx, trackName, artistName, durationSec)
 			}
 			if err != nil && simplifiedTrack != trackName {
-				lyrics, err = kugouClient.FetchLyrics(simplifiedTrack, primaryArtist, durationSec)
+				lyrics, err = kugouClient.FetchLyrics(ctx, simplifiedTrack, primaryArtist, durationSec)
 			}
 
 		case LyricsProviderGenius:
 			geniusClient := NewGeniusLyricsClient()
-			lyrics, err = geniusClient.FetchLyrics(trackName, primaryArtist, durationSec)
+			lyrics, err = geniusClient.FetchLyrics(ctx, trackName, primaryArtist, durationSec)
 			if err != nil && primaryArtist != artistName {
-				lyrics, err = geniusClient.FetchLyrics(trackName, artistName, durationSec)
+				lyrics, err = geniusClient.FetchLyrics(ctx, trackName, artistName, durationSec)
 			}
 			if err != nil && simplifiedTrack != trackName {
-				lyrics, err = geniusClient.FetchLyrics(simplifiedTrack, primaryArtist, durationSec)
+				lyrics, err = geniusClient.FetchLyrics(ctx, simplifiedTrack, primaryArtist, durationSec)
 			}
 
 		case LyricsProviderLyricsPlus:
 			lyricsPlusClient := NewLyricsPlusClient()
 			lyrics, err = lyricsPlusClient.FetchLyrics(
+				ctx,
 				trackName,
 				primaryArtist,
 				"",
@@ -627,6 +648,7 @@ func (c *LyricsClient) FetchLyricsAllSources(spotifyID, trackName, artistName st
 			)
 			if err != nil && primaryArtist != artistName {
 				lyrics, err = lyricsPlusClient.FetchLyrics(
+					ctx,
 					trackName,
 					artistName,
 					"",
@@ -637,6 +659,7 @@ func (c *LyricsClient) FetchLyricsAllSources(spotifyID, trackName, artistName st
 			}
 			if err != nil && simplifiedTrack != trackName {
 				lyrics, err = lyricsPlusClient.FetchLyrics(
+					ctx,
 					simplifiedTrack,
 					primaryArtist,
 					"",
@@ -659,24 +682,28 @@ func (c *LyricsClient) FetchLyricsAllSources(spotifyID, trackName, artistName st
 
 		if err != nil {
 			GoLog("[Lyrics] Provider %s failed: %v\n", providerName, err)
+			providerErrs = append(providerErrs, fmt.Errorf("%s: %w", providerName, err))
 		}
 	}
 
+	if len(providerErrs) > 0 {
+		return nil, fmt.Errorf("lyrics not found from any source: %w", errors.Join(providerErrs...))
+	}
 	return nil, fmt.Errorf("lyrics not found from any source")
 }
 
-func (c *LyricsClient) tryLRCLIB(primaryArtist, artistName, trackName, simplifiedTrack string, durationSec float64) (*LyricsResponse, error) {
+func (c *LyricsClient) tryLRCLIB(ctx context.Context, primaryArtist, artistName, trackName, simplifiedTrack string, durationSec float64) (*LyricsResponse, error) {
 	var lyrics *LyricsResponse
 	var err error
 
-	lyrics, err = c.FetchLyricsWithMetadata(primaryArtist, trackName)
+	lyrics, err = c.FetchLyricsWithMetadata(ctx, primaryArtist, trackName)
 	if err == nil && lyrics != nil && (len(lyrics.Lines) > 0 || lyrics.Instrumental) {
 		lyrics.Source = "LRCLIB"
 		return lyrics, nil
 	}
 
 	if primaryArtist != artistName {
-		lyrics, err = c.FetchLyricsWithMetadata(artistName, trackName)
+		lyrics, err = c.FetchLyricsWithMetadata(ctx, artistName, trackName)
 		if err == nil && lyrics != nil && (len(lyrics.Lines) > 0 || lyrics.Instrumental) {
 			lyrics.Source = "LRCLIB"
 			return lyrics, nil
@@ -684,7 +711,7 @@ func (c *LyricsClient) tryLRCLIB(primaryArtist, artistName, trackName, simplifie
 	}
 
 	if simplifiedTrack != trackName {
-		lyrics, err = c.FetchLyricsWithMetadata(primaryArtist, simplifiedTrack)
+		lyrics, err = c.FetchLyricsWithMetadata(ctx, primaryArtist, simplifiedTrack)
 		if err == nil && lyrics != nil && (len(lyrics.Lines) > 0 || lyrics.Instrumental) {
 			lyrics.Source = "LRCLIB (simplified)"
 			return lyrics, nil
@@ -692,7 +719,7 @@ func (c *LyricsClient) tryLRCLIB(primaryArtist, artistName, trackName, simplifie
 	}
 
 	query := primaryArtist + " " + trackName
-	lyrics, err = c.FetchLyricsFromLRCLibSearch(query, durationSec)
+	lyrics, err = c.FetchLyricsFromLRCLibSearch(ctx, query, durationSec)
 	if err == nil && lyrics != nil && (len(lyrics.Lines) > 0 || lyrics.Instrumental) {
 		lyrics.Source = "LRCLIB Search"
 		return lyrics, nil
@@ -700,7 +727,7 @@ func (c *LyricsClient) tryLRCLIB(primaryArtist, artistName, trackName, simplifie
 
 	if simplifiedTrack != trackName {
 		query = primaryArtist + " " + simplifiedTrack
-		lyrics, err = c.FetchLyricsFromLRCLibSearch(query, durationSec)
+		lyrics, err = c.FetchLyricsFromLRCLibSearch(ctx, query, durationSec)
 		if err == nil && lyrics != nil && (len(lyrics.Lines) > 0 || lyrics.Instrumental) {
 			lyrics.Source = "LRCLIB Search (simplified)"
 			return lyrics, nil
@@ -737,7 +764,18 @@ func (c *LyricsClient) parseLRCLibResponse(resp *LRCLibResponse) *LyricsResponse
 	return result
 }
 
+// maxSyncedLyricsBytes caps the LRC text parseSyncedLyrics reads; real
+// lyrics are a few KB. Longer input is cut at the last line break before
+// the limit.
+const maxSyncedLyricsBytes = 1 << 20
+
 func parseSyncedLyrics(syncedLyrics string) []LyricsLine {
+	if len(syncedLyrics) > maxSyncedLyricsBytes {
+		syncedLyrics = syncedLyrics[:maxSyncedLyricsBytes]
+		if cut := strings.LastIndexByte(syncedLyrics, '\n'); cut >= 0 {
+			syncedLyrics = syncedLyrics[:cut]
+		}
+	}
 	var lines []LyricsLine
 	lrcPattern := regexp.MustCompile(`\[(\d{2}):(\d{2})\.(\d{2,3})\](.*)`)
 
@@ -1019,6 +1057,10 @@ func isLikelyInstrumentalTrack(name string) bool {
 }
 
 func SaveLRCFile(audioFilePath, lrcContent string) (string, error) {
+	if err := checkWriteAllowed(audioFilePath); err != nil {
+		return "", err
+	}
+
 	if lrcContent == "" {
 		return "", fmt.Errorf("empty LRC content")
 	}
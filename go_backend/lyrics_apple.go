@@ -1,6 +1,7 @@
 package gobackend
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -133,7 +134,7 @@ func selectBestAppleMusicSearchResult(results []appleMusicSearchResult, trackNam
 	return &results[bestIndex]
 }
 
-func (c *AppleMusicClient) getAppleMusicToken() (string, error) {
+func (c *AppleMusicClient) getAppleMusicToken(ctx context.Context) (string, error) {
 	appleMusicTokenMu.Lock()
 	defer appleMusicTokenMu.Unlock()
 
@@ -141,13 +142,13 @@ func (c *AppleMusicClient) getAppleMusicToken() (string, error) {
 		return appleMusicCachedToken, nil
 	}
 
-	req, err := http.NewRequest("GET", "https://beta.music.apple.com", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://beta.music.apple.com", nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create apple music page request: %w", err)
 	}
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := doFetchWithRetry(c.httpClient, req, "apple music token page")
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch apple music page: %w", err)
 	}
@@ -167,13 +168,13 @@ func (c *AppleMusicClient) getAppleMusicToken() (string, error) {
 		return "", fmt.Errorf("apple music index script not found")
 	}
 
-	jsReq, err := http.NewRequest("GET", "https://beta.music.apple.com"+indexPath, nil)
+	jsReq, err := http.NewRequestWithContext(ctx, "GET", "https://beta.music.apple.com"+indexPath, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create apple music script request: %w", err)
 	}
 	jsReq.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
 
-	jsResp, err := c.httpClient.Do(jsReq)
+	jsResp, err := doFetchWithRetry(c.httpClient, jsReq, "apple music token script")
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch apple music script: %w", err)
 	}
@@ -203,7 +204,7 @@ func clearAppleMusicToken() {
 	appleMusicCachedToken = ""
 }
 
-func (c *AppleMusicClient) searchSongWithToken(token, query string) ([]appleMusicSearchResult, error) {
+func (c *AppleMusicClient) searchSongWithToken(ctx context.Context, token, query string) ([]appleMusicSearchResult, error) {
 	params := url.Values{}
 	params.Set("term", query)
 	params.Set("types", "songs")
@@ -215,7 +216,7 @@ func (c *AppleMusicClient) searchSongWithToken(token, query string) ([]appleMusi
 	params.Set("extend", "artistUrl")
 
 	searchURL := appleMusicCatalogBaseURL + "/search?" + params.Encode()
-	req, err := http.NewRequest("GET", searchURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create apple music catalog request: %w", err)
 	}
@@ -228,7 +229,7 @@ func (c *AppleMusicClient) searchSongWithToken(token, query string) ([]appleMusi
 	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 	req.Header.Set("x-apple-renewal", "true")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := doFetchWithRetry(c.httpClient, req, "apple music search")
 	if err != nil {
 		return nil, fmt.Errorf("apple music catalog search failed: %w", err)
 	}
@@ -269,25 +270,25 @@ func (c *AppleMusicClient) searchSongWithToken(token, query string) ([]appleMusi
 	return results, nil
 }
 
-func (c *AppleMusicClient) SearchSong(trackName, artistName string, durationSec float64) (string, error) {
+func (c *AppleMusicClient) SearchSong(ctx context.Context, trackName, artistName string, durationSec float64) (string, error) {
 	query := trackName + " " + artistName
 	if strings.TrimSpace(query) == "" {
 		return "", fmt.Errorf("empty search query")
 	}
 
-	token, err := c.getAppleMusicToken()
+	token, err := c.getAppleMusicToken(ctx)
 	if err != nil {
 		return "", err
 	}
 
-	searchResp, err := c.searchSongWithToken(token, strings.TrimSpace(query))
+	searchResp, err := c.searchSongWithToken(ctx, token, strings.TrimSpace(query))
 	if err != nil && strings.Contains(strings.ToLower(err.Error()), "unauthorized") {
 		clearAppleMusicToken()
-		token, tokenErr := c.getAppleMusicToken()
+		token, tokenErr := c.getAppleMusicToken(ctx)
 		if tokenErr != nil {
 			return "", tokenErr
 		}
-		searchResp, err = c.searchSongWithToken(token, strings.TrimSpace(query))
+		searchResp, err = c.searchSongWithToken(ctx, token, strings.TrimSpace(query))
 	}
 	if err != nil {
 		return "", err
@@ -301,17 +302,17 @@ func (c *AppleMusicClient) SearchSong(trackName, artistName string, durationSec
 	return strings.TrimSpace(best.ID), nil
 }
 
-func (c *AppleMusicClient) FetchLyricsByID(songID string) (string, error) {
+func (c *AppleMusicClient) FetchLyricsByID(ctx context.Context, songID string) (string, error) {
 	lyricsURL := fmt.Sprintf("https://lyrics.paxsenix.org/apple-music/lyrics?id=%s", songID)
 
-	req, err := http.NewRequest("GET", lyricsURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", lyricsURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("User-Agent", appUserAgent())
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := doFetchWithRetry(c.httpClient, req, "apple music lyrics")
 	if err != nil {
 		return "", fmt.Errorf("apple music lyrics fetch failed: %w", err)
 	}
@@ -435,18 +436,19 @@ func formatPaxContent(lyricsType string, content []paxLyrics, multiPersonWordByW
 }
 
 func (c *AppleMusicClient) FetchLyrics(
+	ctx context.Context,
 	trackName,
 	artistName string,
 	durationSec float64,
 	multiPersonWordByWord bool,
 	preserveWordTiming bool,
 ) (*LyricsResponse, error) {
-	songID, err := c.SearchSong(trackName, artistName, durationSec)
+	songID, err := c.SearchSong(ctx, trackName, artistName, durationSec)
 	if err != nil {
 		return nil, err
 	}
 
-	rawLyrics, err := c.FetchLyricsByID(songID)
+	rawLyrics, err := c.FetchLyricsByID(ctx, songID)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,194 @@
+package gobackend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type LyricsBatchItem struct {
+	TrackName     string `json:"track_name"`
+	ArtistName    string `json:"artist_name"`
+	SpotifyID     string `json:"spotify_id"`
+	DurationMs    int64  `json:"duration_ms"`
+	OutputPath    string `json:"output_path"`
+	AudioFilePath string `json:"audio_file_path"`
+}
+
+type LyricsBatchResult struct {
+	OutputPath string `json:"output_path"`
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+}
+
+type LyricsBatchProgress struct {
+	Total       int     `json:"total"`
+	Completed   int     `json:"completed"`
+	Failed      int     `json:"failed"`
+	CurrentItem string  `json:"current_item"`
+	ProgressPct float64 `json:"progress_pct"`
+	ETASeconds  float64 `json:"eta_seconds"`
+	IsComplete  bool    `json:"is_complete"`
+	// EstimatedRemainingMs is ETASeconds in milliseconds, as the other
+	// batch progress payloads report it.
+	EstimatedRemainingMs int64 `json:"estimated_remaining_ms"`
+}
+
+var (
+	lyricsBatchProgress   LyricsBatchProgress
+	lyricsBatchProgressMu sync.RWMutex
+	lyricsBatchCancel     context.CancelFunc
+	lyricsBatchCancelMu   sync.Mutex
+)
+
+// fetchLyricsBatchItem is a var so tests can run the batch without providers.
+// ctx is the batch context, cancelled by CancelLyricsBatch.
+var fetchLyricsBatchItem = func(ctx context.Context, item LyricsBatchItem) error {
+	return fetchAndSaveLyrics(ctx, item.TrackName, item.ArtistName, item.SpotifyID, item.DurationMs, item.OutputPath, item.AudioFilePath)
+}
+
+// lyricsBatchPacingHost is the provider whose rate limit bounds a batch
+// before any item has finished and real throughput is known.
+const lyricsBatchPacingHost = "lrclib.net"
+
+// newLyricsBatchETA estimates a batch from the per-item time of its
+// workers, falling back to the host rate limit until the first item
+// completes.
+func newLyricsBatchETA(items, workers int) *batchETA {
+	eta := newBatchETA(make([]int64, items), workers)
+	eta.fallback = func(remaining int) time.Duration {
+		return estimateHostPacing(lyricsBatchPacingHost, remaining)
+	}
+	return eta
+}
+
+func updateLyricsBatchProgress(update func(p *LyricsBatchProgress)) {
+	lyricsBatchProgressMu.Lock()
+	update(&lyricsBatchProgress)
+	lyricsBatchProgressMu.Unlock()
+}
+
+// FetchLyricsBatch fetches and saves lyrics for every item in itemsJSON with
+// a small worker pool. Requests are paced by the per-host rate limiter, so a
+// large batch slows down instead of getting the device banned. Progress,
+// including an ETA, is available from GetLyricsBatchProgress.
+func FetchLyricsBatch(itemsJSON string, workers int) (string, error) {
+	var items []LyricsBatchItem
+	if err := json.Unmarshal([]byte(itemsJSON), &items); err != nil {
+		return "", fmt.Errorf("invalid lyrics batch JSON: %w", err)
+	}
+	if workers <= 0 {
+		workers = GetBackendConfig().MaxConcurrentOperations
+	}
+	workers = min(workers, max(len(items), 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lyricsBatchCancelMu.Lock()
+	if lyricsBatchCancel != nil {
+		lyricsBatchCancel()
+	}
+	lyricsBatchCancel = cancel
+	lyricsBatchCancelMu.Unlock()
+	defer cancel()
+
+	started := time.Now()
+	eta := newLyricsBatchETA(len(items), workers)
+	updateLyricsBatchProgress(func(p *LyricsBatchProgress) {
+		*p = LyricsBatchProgress{
+			Total:                len(items),
+			ETASeconds:           eta.remaining().Seconds(),
+			EstimatedRemainingMs: eta.remainingMs(),
+		}
+	})
+
+	results := make([]LyricsBatchResult, len(items))
+	processed := make([]bool, len(items))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				item := items[idx]
+				updateLyricsBatchProgress(func(p *LyricsBatchProgress) {
+					p.CurrentItem = item.TrackName
+				})
+
+				itemStarted := time.Now()
+				err := fetchLyricsBatchItem(ctx, item)
+				if err != nil && ctx.Err() != nil {
+					err = ErrDownloadCancelled
+				}
+				eta.done(0, time.Since(itemStarted))
+				processed[idx] = true
+				results[idx] = LyricsBatchResult{OutputPath: item.OutputPath, Success: err == nil}
+				if err != nil {
+					results[idx].Error = err.Error()
+				}
+
+				updateLyricsBatchProgress(func(p *LyricsBatchProgress) {
+					p.Completed++
+					if err != nil {
+						p.Failed++
+					}
+					p.ProgressPct = float64(p.Completed) / float64(p.Total) * 100
+					p.ETASeconds = eta.remaining().Seconds()
+					p.EstimatedRemainingMs = eta.remainingMs()
+				})
+			}
+		}()
+	}
+
+dispatch:
+	for idx := range items {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- idx:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for idx := range results {
+		if !processed[idx] {
+			results[idx] = LyricsBatchResult{OutputPath: items[idx].OutputPath, Error: ErrDownloadCancelled.Error()}
+		}
+	}
+
+	updateLyricsBatchProgress(func(p *LyricsBatchProgress) {
+		p.IsComplete = true
+		p.CurrentItem = ""
+		p.ETASeconds = 0
+		p.EstimatedRemainingMs = 0
+	})
+	GoLog("[LyricsBatch] Finished %d item(s) in %v\n", len(items), time.Since(started).Round(time.Millisecond))
+
+	jsonBytes, err := json.Marshal(results)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+func GetLyricsBatchProgress() string {
+	lyricsBatchProgressMu.RLock()
+	defer lyricsBatchProgressMu.RUnlock()
+
+	jsonBytes, _ := json.Marshal(lyricsBatchProgress)
+	return string(jsonBytes)
+}
+
+func CancelLyricsBatch() {
+	lyricsBatchCancelMu.Lock()
+	defer lyricsBatchCancelMu.Unlock()
+
+	if lyricsBatchCancel != nil {
+		lyricsBatchCancel()
+		lyricsBatchCancel = nil
+	}
+}
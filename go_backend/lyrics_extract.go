@@ -0,0 +1,119 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Tag formats ExtractLyricsAuto reads lyrics from.
+const (
+	LyricsSourceFLAC   = "flac"
+	LyricsSourceID3    = "id3"
+	LyricsSourceMP4    = "mp4"
+	LyricsSourceVorbis = "vorbis"
+)
+
+// lrcLinePattern matches a line starting with an LRC timestamp.
+var lrcLinePattern = regexp.MustCompile(`(?m)^\s*\[\d{1,3}:\d{2}(?:[.:]\d{1,3})?\]`)
+
+// EmbeddedLyrics is what ExtractLyricsAuto finds in a file. Synced is LRC
+// text with line timestamps, Plain unsynchronised text; either may be
+// empty.
+type EmbeddedLyrics struct {
+	Synced string `json:"synced,omitempty"`
+	Plain  string `json:"plain,omitempty"`
+	Source string `json:"source"`
+}
+
+// add files text under Synced when it carries LRC timestamps and under
+// Plain otherwise, keeping the first value found for each.
+func (l *EmbeddedLyrics) add(text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	if lrcLinePattern.MatchString(text) {
+		if l.Synced == "" {
+			l.Synced = text
+		}
+	} else if l.Plain == "" {
+		l.Plain = text
+	}
+}
+
+func (l *EmbeddedLyrics) addTags(meta *AudioMetadata) {
+	l.add(meta.SyncedLyrics)
+	l.add(meta.Lyrics)
+	if meta.Lyrics == "" && looksLikeEmbeddedLyrics(meta.Comment) {
+		l.add(meta.Comment)
+	}
+}
+
+func extractLyricsAuto(filePath string) (*EmbeddedLyrics, error) {
+	lyrics := &EmbeddedLyrics{}
+	var (
+		meta *AudioMetadata
+		err  error
+	)
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".flac":
+		// Exactly what ExtractLyrics returns, sidecar .lrc included.
+		text, err := ExtractLyrics(filePath)
+		if err != nil {
+			return nil, err
+		}
+		lyrics.Source = LyricsSourceFLAC
+		lyrics.add(text)
+	case ".mp3":
+		lyrics.Source = LyricsSourceID3
+		meta, err = ReadID3Tags(filePath)
+	case ".wav":
+		lyrics.Source = LyricsSourceID3
+		meta, err = ReadWAVTags(filePath)
+	case ".aiff", ".aif", ".aifc":
+		lyrics.Source = LyricsSourceID3
+		meta, err = ReadAIFFTags(filePath)
+	case ".m4a", ".mp4", ".aac":
+		lyrics.Source = LyricsSourceMP4
+		meta, err = ReadM4ATags(filePath)
+	case ".ogg", ".opus":
+		lyrics.Source = LyricsSourceVorbis
+		meta, err = ReadOggVorbisComments(filePath)
+	default:
+		return nil, fmt.Errorf("unsupported file format for lyrics: %s", filepath.Ext(filePath))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if meta != nil {
+		lyrics.addTags(meta)
+	}
+	if lyrics.Synced == "" && lyrics.Plain == "" {
+		return nil, fmt.Errorf("no lyrics found in file")
+	}
+	return lyrics, nil
+}
+
+// ExtractLyricsAuto returns the embedded lyrics of any supported format as
+// JSON: USLT and SYLT frames of MP3, WAV and AIFF ID3 tags (SYLT converted
+// to LRC), the ©lyr atom of MP4, LYRICS and SYNCEDLYRICS comments of Ogg
+// and Opus, and for FLAC whatever ExtractLyrics returns. Text with LRC
+// timestamps is reported as synced, anything else as plain.
+func ExtractLyricsAuto(filePath string) (string, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, false, func(localPath string) (string, error) {
+			return ExtractLyricsAuto(localPath)
+		})
+	}
+	lyrics, err := extractLyricsAuto(filePath)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(lyrics)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
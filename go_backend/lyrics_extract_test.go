@@ -0,0 +1,98 @@
+package gobackend
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func extractLyricsAutoFile(t *testing.T, path string) EmbeddedLyrics {
+	t.Helper()
+	out, err := ExtractLyricsAuto(path)
+	lyrics := mustDecodeJSON[EmbeddedLyrics](t, out, err)
+	return lyrics
+}
+
+func writeLyricsFixture(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
+
+// id3SyncedLyricsFrame builds a UTF-16 SYLT frame timed in milliseconds.
+func id3SyncedLyricsFrame(lines map[uint32]string, order ...uint32) []byte {
+	utf16 := func(s string) []byte {
+		b := []byte{0xFF, 0xFE}
+		for _, r := range s {
+			b = append(b, byte(r), byte(r>>8))
+		}
+		return append(b, 0, 0)
+	}
+	payload := append([]byte{1, 'e', 'n', 'g', 2, 1}, utf16("")...)
+	for _, ms := range order {
+		payload = append(payload, utf16(lines[ms])...)
+		payload = binary.BigEndian.AppendUint32(payload, ms)
+	}
+	return id3v23Frame("SYLT", payload)
+}
+
+func TestExtractLyricsAutoFLAC(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	if err := EmbedLyrics(path, "[00:01.00]Hello"); err != nil {
+		t.Fatalf("EmbedLyrics: %v", err)
+	}
+	want, _ := ExtractLyrics(path)
+	lyrics := extractLyricsAutoFile(t, path)
+	if lyrics.Source != LyricsSourceFLAC || lyrics.Synced != want || lyrics.Plain != "" {
+		t.Fatalf("lyrics = %+v, ExtractLyrics = %q", lyrics, want)
+	}
+}
+
+func TestExtractLyricsAutoMP3(t *testing.T) {
+	tag := buildID3v23Tag(
+		id3TextFrame("TIT2", "Song"),
+		id3CommentFrame("USLT", "Hello\nWorld"),
+		id3SyncedLyricsFrame(map[uint32]string{1000: "Hello", 62500: "\nWorld"}, 1000, 62500),
+	)
+	path := writeLyricsFixture(t, "song.mp3", append(tag, make([]byte, 64)...))
+	lyrics := extractLyricsAutoFile(t, path)
+	if lyrics.Source != LyricsSourceID3 || lyrics.Plain != "Hello\nWorld" || lyrics.Synced != "[00:01.00]Hello\n[01:02.50]World" {
+		t.Fatalf("lyrics = %+v", lyrics)
+	}
+
+	// MPEG-frame timestamps cannot be converted and are ignored.
+	frames := id3v23Frame("SYLT", append([]byte{3, 'e', 'n', 'g', 1, 1, 0, 'X', 0}, 0, 0, 0, 9))
+	path = writeLyricsFixture(t, "frames.mp3", append(buildID3v23Tag(frames), make([]byte, 64)...))
+	if _, err := ExtractLyricsAuto(path); err == nil {
+		t.Fatal("expected no lyrics from a frame-timed SYLT")
+	}
+}
+
+func TestExtractLyricsAutoM4A(t *testing.T) {
+	ilst := append(buildM4ATextTag("\xa9nam", "Song"), buildM4ATextTag("\xa9lyr", "[00:02.00]Line")...)
+	path := writeLyricsFixture(t, "song.m4a", buildM4AFileWithIlst(ilst, true))
+	lyrics := extractLyricsAutoFile(t, path)
+	if lyrics.Source != LyricsSourceMP4 || lyrics.Synced != "[00:02.00]Line" || lyrics.Plain != "" {
+		t.Fatalf("lyrics = %+v", lyrics)
+	}
+}
+
+func TestExtractLyricsAutoOgg(t *testing.T) {
+	opusHead := make([]byte, 19)
+	copy(opusHead[0:8], "OpusHead")
+	binary.LittleEndian.PutUint32(opusHead[12:16], 48000)
+	tags := append([]byte("OpusTags"), buildVorbisCommentPayload([]string{
+		"TITLE=Song",
+		"LYRICS=Plain words",
+		"SYNCEDLYRICS=[00:03.00]Timed words",
+	})...)
+	data := append(buildOggPage(0x02, 0, opusHead), buildOggPage(0x00, 48000, tags)...)
+	lyrics := extractLyricsAutoFile(t, writeLyricsFixture(t, "song.opus", data))
+	if lyrics.Source != LyricsSourceVorbis || lyrics.Plain != "Plain words" || lyrics.Synced != "[00:03.00]Timed words" {
+		t.Fatalf("lyrics = %+v", lyrics)
+	}
+}
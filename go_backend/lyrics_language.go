@@ -0,0 +1,226 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// lyricsLanguageKey is the Vorbis comment holding the ISO 639-1 code of the
+// embedded lyrics.
+const lyricsLanguageKey = "LYRICSLANGUAGE"
+
+// lyricsLanguageMinConfidence is the confidence below which a detected
+// language is reported but never written to a file.
+const lyricsLanguageMinConfidence = 0.5
+
+// lyricsLanguageFullLetters is how many letters a text needs before its
+// detection can reach full confidence; shorter texts are scaled down.
+const lyricsLanguageFullLetters = 60
+
+// lrcTagPattern matches LRC timestamps and header tags such as [00:12.34]
+// or [ar:Artist], which say nothing about the language.
+var lrcTagPattern = regexp.MustCompile(`\[[^\]\n]*\]|<\d+:\d+(?:\.\d+)?>`)
+
+// latinTrigramProfiles hold frequent trigrams of lyrics and everyday text in
+// each Latin-script language, most frequent first. Words are padded with
+// one space on each side, so " th" is "th" at the start of a word.
+var latinTrigramProfiles = map[string][]string{
+	"en": {
+		" th", "the", "he ", " yo", "you", "ou ", " an", "and", "nd ", "ing",
+		"ng ", " to", "to ", " me", "me ", " my", "my ", " i ", " it", "it ",
+		" in", "in ", " be", " lo", "ove", "lov", "ve ", " wa", "hat", "tha",
+		" ha", "all", "ll ", " do", "on ", " on", "er ", "re ", " so", "ut ",
+		" of", "of ", "ed ", " we", "we ", " no", "ow ", " kn", "now", "is ",
+		"an ", "at ", "oul", "ght", "igh", "e a", "e t", "t t", "ear", " wh",
+	},
+	"es": {
+		" de", "de ", " la", "la ", " qu", "que", "ue ", " el", "el ", " en",
+		"en ", "os ", "as ", " y ", " lo", " me", "me ", " mi", "mi ", " tu",
+		"tu ", " te", "te ", " no", "no ", " es", "es ", " co", "con", " un",
+		"una", "na ", "ar ", "do ", "ado", " se", "ta ", "or ", " po", "por",
+		"amo", "mor", "ent", "nte", "ció", "ión", "ón ", "ra ", "ero", "ier",
+		"ñ", "año", "eña", "orazó", "azó", "zón", "est", "sta", "ndo", "ía ",
+	},
+	"de": {
+		"en ", "er ", " de", "der", "ch ", "ich", " ic", " di", "die", "ie ",
+		" un", "und", "nd ", "ein", " ei", "sch", "cht", "in ", " da", "das",
+		"as ", "ist", " is", "st ", "nic", " ni", "ht ", " du", "du ", "dic",
+		"mic", " mi", "mit", "ein", "ne ", "te ", " wi", "wir", " zu", "zu ",
+		"auf", " au", "ung", "eit", " ge", "gen", "ber", " me", "mei", "ach",
+		"uns", " si", "sie", "ür ", "für", " fü", "ück", "ß", "herz", "erz",
+	},
+	"fr": {
+		" de", "de ", " le", "le ", "es ", " la", "la ", "les", " et", "et ",
+		" qu", "que", "ue ", " je", "je ", " tu", "tu ", " pa", "pas", "as ",
+		"ne ", " ne", " vo", "vou", "ous", " no", "nou", " mo", "moi", "oi ",
+		"mon", "on ", " un", "une", "ent", "nt ", "est", " es", "ais", "ai ",
+		"our", "pou", " po", "eur", "tre", "êtr", " ce", "ce ", "ell", "lle",
+		"qu'", " j'", "j'a", "ça ", " ça", "é ", "è", "amo", "jou", "tou",
+	},
+}
+
+// LyricsLanguageResult is the result of DetectLyricsLanguage. Language is
+// an ISO 639-1 code, or empty when nothing could be detected. Confidence
+// runs from 0 to 1; results under 0.5 are never written to a file.
+type LyricsLanguageResult struct {
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+}
+
+// lyricsScriptCounts counts the letters of text per writing system.
+type lyricsScriptCounts struct {
+	latin, kana, hangul, han, cyrillic, other, total int
+}
+
+func countLyricsScripts(text string) lyricsScriptCounts {
+	var c lyricsScriptCounts
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		c.total++
+		switch {
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			c.kana++
+		case unicode.Is(unicode.Hangul, r):
+			c.hangul++
+		case unicode.Is(unicode.Han, r):
+			c.han++
+		case unicode.Is(unicode.Cyrillic, r):
+			c.cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			c.latin++
+		default:
+			c.other++
+		}
+	}
+	return c
+}
+
+// lengthConfidence scales a confidence down for texts too short to judge.
+func lengthConfidence(confidence float64, letters int) float64 {
+	if letters < lyricsLanguageFullLetters {
+		confidence *= float64(letters) / lyricsLanguageFullLetters
+	}
+	return confidence
+}
+
+// textTrigrams returns the trigrams of every word of text, lower-cased and
+// padded with one space on each side.
+func textTrigrams(text string) []string {
+	var trigrams []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			trigrams = append(trigrams, string(runes[i:i+3]))
+		}
+	}
+	return trigrams
+}
+
+// detectLatinLanguage scores text against latinTrigramProfiles. Each text
+// trigram found in a profile counts more the higher it ranks there, and a
+// profile entry shorter than three runes (such as "ñ") matches any trigram
+// containing it. Confidence is the winner's lead over the runner-up.
+func detectLatinLanguage(text string, letters int) LyricsLanguageResult {
+	trigrams := textTrigrams(text)
+	if len(trigrams) == 0 {
+		return LyricsLanguageResult{}
+	}
+
+	scores := make(map[string]float64, len(latinTrigramProfiles))
+	for lang, profile := range latinTrigramProfiles {
+		weights := make(map[string]float64, len(profile))
+		var fragments []string
+		for rank, trigram := range profile {
+			weight := float64(len(profile) - rank)
+			if len([]rune(trigram)) != 3 {
+				fragments = append(fragments, trigram)
+				weights[trigram] = weight
+				continue
+			}
+			weights[trigram] = max(weights[trigram], weight)
+		}
+		for _, trigram := range trigrams {
+			scores[lang] += weights[trigram]
+			for _, fragment := range fragments {
+				if strings.Contains(trigram, fragment) {
+					scores[lang] += weights[fragment]
+				}
+			}
+		}
+	}
+
+	var best, second string
+	for lang := range scores {
+		switch {
+		case best == "" || scores[lang] > scores[best] || (scores[lang] == scores[best] && lang < best):
+			best, second = lang, best
+		case second == "" || scores[lang] > scores[second] || (scores[lang] == scores[second] && lang < second):
+			second = lang
+		}
+	}
+	if scores[best] == 0 {
+		return LyricsLanguageResult{}
+	}
+	lead := (scores[best] - scores[second]) / scores[best]
+	// A lead of a third over the runner-up is already clear-cut.
+	confidence := min(1, lead*3)
+	return LyricsLanguageResult{Language: best, Confidence: lengthConfidence(confidence, letters)}
+}
+
+// detectLyricsLanguage identifies the language of lyrics text. Japanese,
+// Korean, Chinese and Russian are told apart by script (kana marks Japanese
+// even alongside kanji); Latin-script text is matched against trigram
+// profiles for English, Spanish, German and French.
+func detectLyricsLanguage(text string) LyricsLanguageResult {
+	text = lrcTagPattern.ReplaceAllString(text, " ")
+	counts := countLyricsScripts(text)
+	if counts.total == 0 {
+		return LyricsLanguageResult{}
+	}
+
+	share := func(n int) float64 { return float64(n) / float64(counts.total) }
+	cjk := counts.kana + counts.han
+	switch {
+	case counts.kana > 0 && share(cjk) >= 0.5:
+		return LyricsLanguageResult{Language: "ja", Confidence: lengthConfidence(share(cjk+counts.latin/4), cjk*3)}
+	case share(counts.hangul) >= 0.5:
+		return LyricsLanguageResult{Language: "ko", Confidence: lengthConfidence(share(counts.hangul), counts.hangul*3)}
+	case share(counts.han) >= 0.5:
+		return LyricsLanguageResult{Language: "zh", Confidence: lengthConfidence(share(counts.han), counts.han*3)}
+	case share(counts.cyrillic) >= 0.5:
+		return LyricsLanguageResult{Language: "ru", Confidence: lengthConfidence(share(counts.cyrillic), counts.cyrillic)}
+	case share(counts.latin) >= 0.5:
+		result := detectLatinLanguage(text, counts.latin)
+		result.Confidence *= share(counts.latin)
+		return result
+	}
+	return LyricsLanguageResult{}
+}
+
+// DetectLyricsLanguage returns the language of lyrics text, plain or LRC,
+// as JSON with an ISO 639-1 code and a confidence between 0 and 1.
+// English, Spanish, German, French, Japanese, Korean, Chinese and Russian
+// are recognized; anything else comes back with an empty language.
+func DetectLyricsLanguage(text string) (string, error) {
+	jsonBytes, err := json.Marshal(detectLyricsLanguage(text))
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// lyricsLanguageTag returns the language to tag lyrics with, or "" when
+// the detection is not confident enough to write.
+func lyricsLanguageTag(lyrics string) string {
+	result := detectLyricsLanguage(lyrics)
+	if result.Language == "" || result.Confidence < lyricsLanguageMinConfidence {
+		return ""
+	}
+	return result.Language
+}
@@ -0,0 +1,88 @@
+package gobackend
+
+import "testing"
+
+func TestDetectLyricsLanguage(t *testing.T) {
+	cases := []struct {
+		name, text, want string
+	}{
+		{"english", "[00:01.00]I know that you love me\n[00:05.00]And the night is all we have\n[00:09.00]So hold me in your arms tonight", "en"},
+		{"spanish", "Te quiero con todo mi corazón\nY no puedo vivir sin tu amor\nPorque eres la luz de mi vida", "es"},
+		{"german", "Ich liebe dich und du bist mein Herz\nIch kann nicht ohne dich sein\nDas ist für immer und ewig", "de"},
+		{"french", "Je ne sais pas pourquoi tu es partie\nMon cœur est avec toi pour toujours\nEt je pense à nous chaque jour", "fr"},
+		{"japanese", "君の名前を呼んでいる\n夜空に星が輝いている\nもう一度会いたいよ", "ja"},
+		{"korean", "사랑해 너를 정말 사랑해\n너 없이는 살 수 없어\n내 마음을 알아줘", "ko"},
+		{"chinese", "我爱你就像老鼠爱大米\n不管有多少风雨\n我都会依然陪着你", "zh"},
+		{"russian", "Я люблю тебя всем сердцем\nИ ночь нас снова ждёт\nТы свет моей жизни", "ru"},
+	}
+	for _, tc := range cases {
+		got := detectLyricsLanguage(tc.text)
+		if got.Language != tc.want {
+			t.Fatalf("%s: language = %q (%.2f), want %q", tc.name, got.Language, got.Confidence, tc.want)
+		}
+		if got.Confidence < lyricsLanguageMinConfidence {
+			t.Fatalf("%s: confidence %.2f below threshold", tc.name, got.Confidence)
+		}
+	}
+}
+
+func TestDetectLyricsLanguageLowConfidence(t *testing.T) {
+	for _, text := range []string{"", "[00:01.00]", "la la la", "12345 !!!", "Ahoj, jak se máš"} {
+		if got := lyricsLanguageTag(text); got != "" {
+			t.Fatalf("lyricsLanguageTag(%q) = %q, want no tag", text, got)
+		}
+	}
+
+	raw, err := DetectLyricsLanguage("")
+	result := mustDecodeJSON[LyricsLanguageResult](t, raw, err)
+	if result.Language != "" || result.Confidence != 0 {
+		t.Fatalf("unexpected result for empty text: %+v", result)
+	}
+}
+
+func readLyricsLanguage(t *testing.T, path string) string {
+	t.Helper()
+	comments, err := readVorbisCommentList(path)
+	if err != nil {
+		t.Fatalf("readVorbisCommentList: %v", err)
+	}
+	return newVorbisCommentMap(comments).get(lyricsLanguageKey)
+}
+
+func TestEmbedLyricsTagsDetectedLanguage(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Track"})
+	if err := EmbedLyrics(path, "Te quiero con todo mi corazón\nY no puedo vivir sin tu amor"); err != nil {
+		t.Fatalf("EmbedLyrics: %v", err)
+	}
+	if got := readLyricsLanguage(t, path); got != "es" {
+		t.Fatalf("LYRICSLANGUAGE = %q, want es", got)
+	}
+
+	short := writeTestFLACWithMetadata(t, Metadata{Title: "Track"})
+	if err := EmbedLyrics(short, "oh oh"); err != nil {
+		t.Fatalf("EmbedLyrics: %v", err)
+	}
+	if got := readLyricsLanguage(t, short); got != "" {
+		t.Fatalf("low-confidence lyrics tagged %q", got)
+	}
+}
+
+func TestEmbedAllLyricsLanguage(t *testing.T) {
+	english := "I know that you love me\nAnd the night is all we have\nSo hold me in your arms tonight"
+
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Track"})
+	if err := EmbedAll(path, Metadata{}, Lyrics{Text: english}, nil, EmbedOptions{}); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	if got := readLyricsLanguage(t, path); got != "en" {
+		t.Fatalf("LYRICSLANGUAGE = %q, want en", got)
+	}
+
+	// Provider-supplied language wins over detection.
+	if err := EmbedAll(path, Metadata{}, Lyrics{Text: english, Language: "ja"}, nil, EmbedOptions{}); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	if got := readLyricsLanguage(t, path); got != "ja" {
+		t.Fatalf("LYRICSLANGUAGE = %q, want ja", got)
+	}
+}
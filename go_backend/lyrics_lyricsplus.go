@@ -1,6 +1,7 @@
 package gobackend
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -63,6 +64,7 @@ type lyricsPlusResponse struct {
 // lyrics. multiPersonWordByWord and preserveWordTiming mirror the Apple/QQ
 // options so word/background timing is only emitted when the user enabled it.
 func (c *LyricsPlusClient) FetchLyrics(
+	ctx context.Context,
 	trackName,
 	artistName,
 	isrc string,
@@ -76,7 +78,7 @@ func (c *LyricsPlusClient) FetchLyrics(
 
 	var lastErr error
 	for _, server := range lyricsPlusServers {
-		lyrics, err := c.fetchFromServer(server, trackName, artistName, isrc, durationSec, multiPersonWordByWord, preserveWordTiming)
+		lyrics, err := c.fetchFromServer(ctx, server, trackName, artistName, isrc, durationSec, multiPersonWordByWord, preserveWordTiming)
 		if err == nil && lyricsHasUsableText(lyrics) {
 			return lyrics, nil
 		}
@@ -93,6 +95,7 @@ func (c *LyricsPlusClient) FetchLyrics(
 }
 
 func (c *LyricsPlusClient) fetchFromServer(
+	ctx context.Context,
 	server,
 	trackName,
 	artistName,
@@ -118,14 +121,14 @@ func (c *LyricsPlusClient) fetchFromServer(
 
 	fullURL := base + "/v2/lyrics/get?" + params.Encode()
 
-	req, err := http.NewRequest("GET", fullURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", appUserAgent())
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := doFetchWithRetry(c.httpClient, req, "lyricsplus lyrics")
 	if err != nil {
 		return nil, err
 	}
@@ -134,7 +137,7 @@ func (c *LyricsPlusClient) fetchFromServer(
 	if resp.StatusCode == http.StatusNotFound {
 		// Retry without the ISRC filter, which can be too strict.
 		if strings.TrimSpace(isrc) != "" {
-			return c.fetchFromServer(server, trackName, artistName, "", durationSec, multiPersonWordByWord, preserveWordTiming)
+			return c.fetchFromServer(ctx, server, trackName, artistName, "", durationSec, multiPersonWordByWord, preserveWordTiming)
 		}
 		return nil, fmt.Errorf("lyrics not found")
 	}
@@ -1,6 +1,7 @@
 package gobackend
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -50,7 +51,7 @@ func NewNeteaseClient() *NeteaseClient {
 	}
 }
 
-func (c *NeteaseClient) SearchSong(trackName, artistName string) (int64, error) {
+func (c *NeteaseClient) SearchSong(ctx context.Context, trackName, artistName string) (int64, error) {
 	query := trackName + " " + artistName
 	if strings.TrimSpace(query) == "" {
 		return 0, fmt.Errorf("empty search query")
@@ -62,7 +63,7 @@ func (c *NeteaseClient) SearchSong(trackName, artistName string) (int64, error)
 
 	fullURL := searchURL + "?" + params.Encode()
 
-	req, err := http.NewRequest("GET", fullURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -72,7 +73,7 @@ func (c *NeteaseClient) SearchSong(trackName, artistName string) (int64, error)
 	}
 	req.Header.Set("User-Agent", appUserAgent())
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := doFetchWithRetry(c.httpClient, req, "netease search")
 	if err != nil {
 		return 0, fmt.Errorf("netease search failed: %w", err)
 	}
@@ -94,14 +95,14 @@ func (c *NeteaseClient) SearchSong(trackName, artistName string) (int64, error)
 	return searchResp.Result.Songs[0].ID, nil
 }
 
-func (c *NeteaseClient) FetchLyricsByID(songID int64, includeTranslation, includeRomanization bool) (string, error) {
+func (c *NeteaseClient) FetchLyricsByID(ctx context.Context, songID int64, includeTranslation, includeRomanization bool) (string, error) {
 	lyricsURL := "https://lyrics.paxsenix.org/netease/lyrics"
 	params := url.Values{}
 	params.Set("id", fmt.Sprintf("%d", songID))
 
 	fullURL := lyricsURL + "?" + params.Encode()
 
-	req, err := http.NewRequest("GET", fullURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -111,7 +112,7 @@ func (c *NeteaseClient) FetchLyricsByID(songID int64, includeTranslation, includ
 	}
 	req.Header.Set("User-Agent", appUserAgent())
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := doFetchWithRetry(c.httpClient, req, "netease lyrics")
 	if err != nil {
 		return "", fmt.Errorf("netease lyrics fetch failed: %w", err)
 	}
@@ -144,18 +145,19 @@ func (c *NeteaseClient) FetchLyricsByID(songID int64, includeTranslation, includ
 }
 
 func (c *NeteaseClient) FetchLyrics(
+	ctx context.Context,
 	trackName,
 	artistName string,
 	durationSec float64,
 	includeTranslation,
 	includeRomanization bool,
 ) (*LyricsResponse, error) {
-	songID, err := c.SearchSong(trackName, artistName)
+	songID, err := c.SearchSong(ctx, trackName, artistName)
 	if err != nil {
 		return nil, err
 	}
 
-	lrcText, err := c.FetchLyricsByID(songID, includeTranslation, includeRomanization)
+	lrcText, err := c.FetchLyricsByID(ctx, songID, includeTranslation, includeRomanization)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package gobackend
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -98,20 +99,20 @@ func NewGeniusLyricsClient() *GeniusLyricsClient {
 	return &GeniusLyricsClient{httpClient: NewMetadataHTTPClient(15 * time.Second)}
 }
 
-func fetchPaxsenixBody(httpClient *http.Client, endpoint string, params url.Values) (string, error) {
+func fetchPaxsenixBody(ctx context.Context, httpClient *http.Client, endpoint string, params url.Values) (string, error) {
 	fullURL := endpoint
 	if len(params) > 0 {
 		fullURL += "?" + params.Encode()
 	}
 
-	req, err := http.NewRequest("GET", fullURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", appUserAgent())
 
-	resp, err := httpClient.Do(req)
+	resp, err := doFetchWithRetry(httpClient, req, "paxsenix lyrics")
 	if err != nil {
 		return "", err
 	}
@@ -233,7 +234,7 @@ func normalizeSpotifyLyricsID(raw string) string {
 
 var regexpSpotifyTrackID = regexp.MustCompile(`^[A-Za-z0-9]{22}$`)
 
-func (c *SpotifyLyricsClient) SearchSong(trackName, artistName string, durationSec float64) (string, error) {
+func (c *SpotifyLyricsClient) SearchSong(ctx context.Context, trackName, artistName string, durationSec float64) (string, error) {
 	query := strings.TrimSpace(trackName + " " + artistName)
 	if query == "" {
 		return "", fmt.Errorf("empty search query")
@@ -241,7 +242,7 @@ func (c *SpotifyLyricsClient) SearchSong(trackName, artistName string, durationS
 
 	params := url.Values{}
 	params.Set("q", query)
-	raw, err := fetchPaxsenixBody(c.httpClient, "https://lyrics.paxsenix.org/spotify/search", params)
+	raw, err := fetchPaxsenixBody(ctx, c.httpClient, "https://lyrics.paxsenix.org/spotify/search", params)
 	if err != nil {
 		return "", fmt.Errorf("spotify search failed: %w", err)
 	}
@@ -275,26 +276,26 @@ func selectBestSpotifyLyricsSearchResult(results []spotifyLyricsSearchResult, tr
 	return &results[bestIndex]
 }
 
-func (c *SpotifyLyricsClient) FetchLyricsByID(trackID string) (*LyricsResponse, error) {
+func (c *SpotifyLyricsClient) FetchLyricsByID(ctx context.Context, trackID string) (*LyricsResponse, error) {
 	params := url.Values{}
 	params.Set("id", trackID)
-	raw, err := fetchPaxsenixBody(c.httpClient, "https://lyrics.paxsenix.org/spotify/lyrics", params)
+	raw, err := fetchPaxsenixBody(ctx, c.httpClient, "https://lyrics.paxsenix.org/spotify/lyrics", params)
 	if err != nil {
 		return nil, fmt.Errorf("spotify lyrics fetch failed: %w", err)
 	}
 	return parsePaxsenixLyricsPayload(raw, "Spotify", false)
 }
 
-func (c *SpotifyLyricsClient) FetchLyrics(spotifyID, trackName, artistName string, durationSec float64) (*LyricsResponse, error) {
+func (c *SpotifyLyricsClient) FetchLyrics(ctx context.Context, spotifyID, trackName, artistName string, durationSec float64) (*LyricsResponse, error) {
 	trackID := normalizeSpotifyLyricsID(spotifyID)
 	if trackID == "" {
 		var err error
-		trackID, err = c.SearchSong(trackName, artistName, durationSec)
+		trackID, err = c.SearchSong(ctx, trackName, artistName, durationSec)
 		if err != nil {
 			return nil, err
 		}
 	}
-	return c.FetchLyricsByID(trackID)
+	return c.FetchLyricsByID(ctx, trackID)
 }
 
 func normalizeDeezerLyricsID(raw string) string {
@@ -312,17 +313,17 @@ func normalizeDeezerLyricsID(raw string) string {
 	return ""
 }
 
-func (c *DeezerLyricsClient) FetchLyricsByID(trackID string, multiPersonWordByWord bool) (*LyricsResponse, error) {
+func (c *DeezerLyricsClient) FetchLyricsByID(ctx context.Context, trackID string, multiPersonWordByWord bool) (*LyricsResponse, error) {
 	params := url.Values{}
 	params.Set("id", trackID)
-	raw, err := fetchPaxsenixBody(c.httpClient, "https://lyrics.paxsenix.org/deezer/lyrics", params)
+	raw, err := fetchPaxsenixBody(ctx, c.httpClient, "https://lyrics.paxsenix.org/deezer/lyrics", params)
 	if err != nil {
 		return nil, fmt.Errorf("deezer lyrics fetch failed: %w", err)
 	}
 	return parsePaxsenixLyricsPayload(raw, "Deezer", multiPersonWordByWord)
 }
 
-func (c *DeezerLyricsClient) FetchLyrics(spotifyID, trackName, artistName string, durationSec float64) (*LyricsResponse, error) {
+func (c *DeezerLyricsClient) FetchLyrics(ctx context.Context, spotifyID, trackName, artistName string, durationSec float64) (*LyricsResponse, error) {
 	deezerID := normalizeDeezerLyricsID(spotifyID)
 	if deezerID == "" {
 		spotifyTrackID := normalizeSpotifyLyricsID(spotifyID)
@@ -338,10 +339,10 @@ func (c *DeezerLyricsClient) FetchLyrics(spotifyID, trackName, artistName string
 	if deezerID == "" {
 		return nil, fmt.Errorf("deezer id unavailable")
 	}
-	return c.FetchLyricsByID(deezerID, true)
+	return c.FetchLyricsByID(ctx, deezerID, true)
 }
 
-func (c *YouTubeLyricsClient) SearchSong(trackName, artistName string, durationSec float64) (string, error) {
+func (c *YouTubeLyricsClient) SearchSong(ctx context.Context, trackName, artistName string, durationSec float64) (string, error) {
 	query := strings.TrimSpace(trackName + " " + artistName)
 	if query == "" {
 		return "", fmt.Errorf("empty search query")
@@ -349,7 +350,7 @@ func (c *YouTubeLyricsClient) SearchSong(trackName, artistName string, durationS
 
 	params := url.Values{}
 	params.Set("q", query)
-	raw, err := fetchPaxsenixBody(c.httpClient, "https://lyrics.paxsenix.org/youtube/search", params)
+	raw, err := fetchPaxsenixBody(ctx, c.httpClient, "https://lyrics.paxsenix.org/youtube/search", params)
 	if err != nil {
 		return "", fmt.Errorf("youtube search failed: %w", err)
 	}
@@ -383,22 +384,22 @@ func selectBestYouTubeLyricsSearchResult(results []youtubeLyricsSearchResult, tr
 	return &results[bestIndex]
 }
 
-func (c *YouTubeLyricsClient) FetchLyrics(trackName, artistName string, durationSec float64) (*LyricsResponse, error) {
-	videoID, err := c.SearchSong(trackName, artistName, durationSec)
+func (c *YouTubeLyricsClient) FetchLyrics(ctx context.Context, trackName, artistName string, durationSec float64) (*LyricsResponse, error) {
+	videoID, err := c.SearchSong(ctx, trackName, artistName, durationSec)
 	if err != nil {
 		return nil, err
 	}
 
 	params := url.Values{}
 	params.Set("id", videoID)
-	raw, err := fetchPaxsenixBody(c.httpClient, "https://lyrics.paxsenix.org/youtube/lyrics", params)
+	raw, err := fetchPaxsenixBody(ctx, c.httpClient, "https://lyrics.paxsenix.org/youtube/lyrics", params)
 	if err != nil {
 		return nil, fmt.Errorf("youtube lyrics fetch failed: %w", err)
 	}
 	return parsePaxsenixLyricsPayload(raw, "YouTube", false)
 }
 
-func (c *KugouLyricsClient) SearchSong(trackName, artistName string, durationSec float64) (string, error) {
+func (c *KugouLyricsClient) SearchSong(ctx context.Context, trackName, artistName string, durationSec float64) (string, error) {
 	query := strings.TrimSpace(trackName + " " + artistName)
 	if query == "" {
 		return "", fmt.Errorf("empty search query")
@@ -406,7 +407,7 @@ func (c *KugouLyricsClient) SearchSong(trackName, artistName string, durationSec
 
 	params := url.Values{}
 	params.Set("q", query)
-	raw, err := fetchPaxsenixBody(c.httpClient, "https://lyrics.paxsenix.org/kugou/search", params)
+	raw, err := fetchPaxsenixBody(ctx, c.httpClient, "https://lyrics.paxsenix.org/kugou/search", params)
 	if err != nil {
 		return "", fmt.Errorf("kugou search failed: %w", err)
 	}
@@ -440,22 +441,22 @@ func selectBestKugouLyricsSearchResult(results []kugouLyricsSearchResult, trackN
 	return &results[bestIndex]
 }
 
-func (c *KugouLyricsClient) FetchLyrics(trackName, artistName string, durationSec float64) (*LyricsResponse, error) {
-	hash, err := c.SearchSong(trackName, artistName, durationSec)
+func (c *KugouLyricsClient) FetchLyrics(ctx context.Context, trackName, artistName string, durationSec float64) (*LyricsResponse, error) {
+	hash, err := c.SearchSong(ctx, trackName, artistName, durationSec)
 	if err != nil {
 		return nil, err
 	}
 
 	params := url.Values{}
 	params.Set("id", hash)
-	raw, err := fetchPaxsenixBody(c.httpClient, "https://lyrics.paxsenix.org/kugou/lyrics", params)
+	raw, err := fetchPaxsenixBody(ctx, c.httpClient, "https://lyrics.paxsenix.org/kugou/lyrics", params)
 	if err != nil {
 		return nil, fmt.Errorf("kugou lyrics fetch failed: %w", err)
 	}
 	return parsePaxsenixLyricsPayload(raw, "Kugou", false)
 }
 
-func (c *GeniusLyricsClient) SearchSong(trackName, artistName string, durationSec float64) (string, error) {
+func (c *GeniusLyricsClient) SearchSong(ctx context.Context, trackName, artistName string, durationSec float64) (string, error) {
 	query := strings.TrimSpace(trackName + " " + artistName)
 	if query == "" {
 		return "", fmt.Errorf("empty search query")
@@ -464,7 +465,7 @@ func (c *GeniusLyricsClient) SearchSong(trackName, artistName string, durationSe
 	params := url.Values{}
 	params.Set("q", query)
 	params.Set("per_page", "10")
-	raw, err := fetchPaxsenixBody(c.httpClient, "https://genius.com/api/search/multi", params)
+	raw, err := fetchPaxsenixBody(ctx, c.httpClient, "https://genius.com/api/search/multi", params)
 	if err != nil {
 		return "", fmt.Errorf("genius search failed: %w", err)
 	}
@@ -500,15 +501,15 @@ func (c *GeniusLyricsClient) SearchSong(trackName, artistName string, durationSe
 	return bestURL, nil
 }
 
-func (c *GeniusLyricsClient) FetchLyrics(trackName, artistName string, durationSec float64) (*LyricsResponse, error) {
-	geniusURL, err := c.SearchSong(trackName, artistName, durationSec)
+func (c *GeniusLyricsClient) FetchLyrics(ctx context.Context, trackName, artistName string, durationSec float64) (*LyricsResponse, error) {
+	geniusURL, err := c.SearchSong(ctx, trackName, artistName, durationSec)
 	if err != nil {
 		return nil, err
 	}
 
 	params := url.Values{}
 	params.Set("url", geniusURL)
-	raw, err := fetchPaxsenixBody(c.httpClient, "https://lyrics.paxsenix.org/genius/lyrics", params)
+	raw, err := fetchPaxsenixBody(ctx, c.httpClient, "https://lyrics.paxsenix.org/genius/lyrics", params)
 	if err != nil {
 		return nil, fmt.Errorf("genius lyrics fetch failed: %w", err)
 	}
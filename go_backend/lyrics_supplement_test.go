@@ -1,6 +1,7 @@
 package gobackend
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"path/filepath"
@@ -10,6 +11,7 @@ import (
 )
 
 func TestLyricsCacheParsingAndLRCLibClient(t *testing.T) {
+	ctx := context.Background()
 	SetAppVersion("4.5.0")
 	if ua := appUserAgent(); !strings.Contains(ua, "4.5.0") {
 		t.Fatalf("user agent = %q", ua)
@@ -93,11 +95,11 @@ func TestLyricsCacheParsingAndLRCLibClient(t *testing.T) {
 			return &http.Response{StatusCode: 404, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(`{}`)), Request: req}, nil
 		}
 	})}}
-	got, err := client.FetchLyricsWithMetadata("Artist", "Song")
+	got, err := client.FetchLyricsWithMetadata(ctx, "Artist", "Song")
 	if err != nil || got.SyncType != "LINE_SYNCED" || len(got.Lines) != 1 {
 		t.Fatalf("FetchLyricsWithMetadata = %#v/%v", got, err)
 	}
-	search, err := client.FetchLyricsFromLRCLibSearch("Artist Song", 180)
+	search, err := client.FetchLyricsFromLRCLibSearch(ctx, "Artist Song", 180)
 	if err != nil || len(search.Lines) == 0 {
 		t.Fatalf("FetchLyricsFromLRCLibSearch = %#v/%v", search, err)
 	}
@@ -131,6 +133,7 @@ func TestLyricsCacheParsingAndLRCLibClient(t *testing.T) {
 }
 
 func TestExternalLyricsProvidersWithFakeHTTP(t *testing.T) {
+	ctx := context.Background()
 	clearAppleMusicToken()
 	defer clearAppleMusicToken()
 
@@ -152,15 +155,15 @@ func TestExternalLyricsProvidersWithFakeHTTP(t *testing.T) {
 	if best := selectBestAppleMusicSearchResult([]appleMusicSearchResult{{ID: "1", SongName: "Song", ArtistName: "Artist", Duration: 180000}}, "Song", "Artist", 180); best == nil || best.ID != "1" {
 		t.Fatalf("best apple result = %#v", best)
 	}
-	appleID, err := apple.SearchSong("Song", "Artist", 180)
+	appleID, err := apple.SearchSong(ctx, "Song", "Artist", 180)
 	if err != nil || appleID != "apple-1" {
 		t.Fatalf("apple SearchSong = %q/%v", appleID, err)
 	}
-	rawApple, err := apple.FetchLyricsByID(appleID)
+	rawApple, err := apple.FetchLyricsByID(ctx, appleID)
 	if err != nil || !strings.Contains(rawApple, "Syllable") {
 		t.Fatalf("apple raw = %q/%v", rawApple, err)
 	}
-	appleLyrics, err := apple.FetchLyrics("Song", "Artist", 180, true, true)
+	appleLyrics, err := apple.FetchLyrics(ctx, "Song", "Artist", 180, true, true)
 	if err != nil || appleLyrics.SyncType != "LINE_SYNCED" || appleLyrics.Provider != "Apple Music" {
 		t.Fatalf("apple lyrics = %#v/%v", appleLyrics, err)
 	}
@@ -184,7 +187,7 @@ func TestExternalLyricsProvidersWithFakeHTTP(t *testing.T) {
 	if preferred, err := formatPaxLyricsToLRC(`{"elrcMultiPerson":"[00:01.00]v1:<00:01.00>Hello","content":[{"timestamp":1000,"text":[{"text":"Fallback","part":false}]}]}`, true, true); err != nil || !strings.Contains(preferred, "Hello") {
 		t.Fatalf("preferred apple elrc = %q/%v", preferred, err)
 	}
-	if _, err := apple.SearchSong("", "", 0); err == nil {
+	if _, err := apple.SearchSong(ctx, "", "", 0); err == nil {
 		t.Fatal("expected empty apple search error")
 	}
 
@@ -202,16 +205,16 @@ func TestExternalLyricsProvidersWithFakeHTTP(t *testing.T) {
 		})},
 		baseURL: "https://lyrics.paxsenix.org/musixmatch/lyrics",
 	}
-	if localized, err := musixmatch.FetchLyricsInLanguage("Song", "Artist", 180, "id"); err != nil || localized.Source != "Musixmatch (id)" {
+	if localized, err := musixmatch.FetchLyricsInLanguage(ctx, "Song", "Artist", 180, "id"); err != nil || localized.Source != "Musixmatch (id)" {
 		t.Fatalf("localized musixmatch = %#v/%v", localized, err)
 	}
-	if normal, err := musixmatch.FetchLyrics("Song", "Artist", 180, "xx"); err != nil || normal.Provider != "Musixmatch" {
+	if normal, err := musixmatch.FetchLyrics(ctx, "Song", "Artist", 180, "xx"); err != nil || normal.Provider != "Musixmatch" {
 		t.Fatalf("musixmatch = %#v/%v", normal, err)
 	}
-	if _, err := musixmatch.FetchLyricsInLanguage("Song", "Artist", 180, " "); err == nil {
+	if _, err := musixmatch.FetchLyricsInLanguage(ctx, "Song", "Artist", 180, " "); err == nil {
 		t.Fatal("expected invalid language error")
 	}
-	if _, err := musixmatch.fetchLyricsPayload("bad", "Artist", 0, "word", ""); err == nil {
+	if _, err := musixmatch.fetchLyricsPayload(ctx, "bad", "Artist", 0, "word", ""); err == nil {
 		t.Fatal("expected musixmatch proxy error")
 	}
 
@@ -225,15 +228,15 @@ func TestExternalLyricsProvidersWithFakeHTTP(t *testing.T) {
 			return &http.Response{StatusCode: 404, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(`{}`)), Request: req}, nil
 		}
 	})}}
-	songID, err := netease.SearchSong("Song", "Artist")
+	songID, err := netease.SearchSong(ctx, "Song", "Artist")
 	if err != nil || songID != 123 {
 		t.Fatalf("netease search = %d/%v", songID, err)
 	}
-	netLyrics, err := netease.FetchLyrics("Song", "Artist", 180, true, true)
+	netLyrics, err := netease.FetchLyrics(ctx, "Song", "Artist", 180, true, true)
 	if err != nil || netLyrics.SyncType != "LINE_SYNCED" {
 		t.Fatalf("netease lyrics = %#v/%v", netLyrics, err)
 	}
-	if _, err := netease.SearchSong("", ""); err == nil {
+	if _, err := netease.SearchSong(ctx, "", ""); err == nil {
 		t.Fatal("expected empty netease search error")
 	}
 
@@ -243,11 +246,11 @@ func TestExternalLyricsProvidersWithFakeHTTP(t *testing.T) {
 		}
 		return &http.Response{StatusCode: 200, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(`{"lyrics":[{"timestamp":1000,"text":[{"text":"QQ","part":false,"timestamp":1000}]}]}`)), Request: req}, nil
 	})}}
-	qqRaw, err := qq.fetchLyricsByMetadata("Song", "Artist", 180)
+	qqRaw, err := qq.fetchLyricsByMetadata(ctx, "Song", "Artist", 180)
 	if err != nil || !strings.Contains(qqRaw, "lyrics") {
 		t.Fatalf("qq raw = %q/%v", qqRaw, err)
 	}
-	qqLyrics, err := qq.FetchLyrics("Song", "Artist", 180, false)
+	qqLyrics, err := qq.FetchLyrics(ctx, "Song", "Artist", 180, false)
 	if err != nil || qqLyrics.Provider != "QQ Music" {
 		t.Fatalf("qq lyrics = %#v/%v", qqLyrics, err)
 	}
@@ -265,7 +268,7 @@ func TestExternalLyricsProvidersWithFakeHTTP(t *testing.T) {
 			return &http.Response{StatusCode: 404, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(`{}`)), Request: req}, nil
 		}
 	})}}
-	spotifyLyrics, err := spotify.FetchLyrics("", "Song", "Artist", 180)
+	spotifyLyrics, err := spotify.FetchLyrics(ctx, "", "Song", "Artist", 180)
 	if err != nil || spotifyLyrics.Provider != "Spotify" || spotifyLyrics.SyncType != "LINE_SYNCED" {
 		t.Fatalf("spotify lyrics = %#v/%v", spotifyLyrics, err)
 	}
@@ -273,7 +276,7 @@ func TestExternalLyricsProvidersWithFakeHTTP(t *testing.T) {
 	deezer := &DeezerLyricsClient{httpClient: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
 		return &http.Response{StatusCode: 200, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(`{"lyrics":[{"timestamp":1000,"text":[{"text":"Deezer","part":false}]}]}`)), Request: req}, nil
 	})}}
-	deezerLyrics, err := deezer.FetchLyricsByID("123", false)
+	deezerLyrics, err := deezer.FetchLyricsByID(ctx, "123", false)
 	if err != nil || deezerLyrics.Provider != "Deezer" || deezerLyrics.SyncType != "LINE_SYNCED" {
 		t.Fatalf("deezer lyrics = %#v/%v", deezerLyrics, err)
 	}
@@ -288,7 +291,7 @@ func TestExternalLyricsProvidersWithFakeHTTP(t *testing.T) {
 			return &http.Response{StatusCode: 404, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(`{}`)), Request: req}, nil
 		}
 	})}}
-	youtubeLyrics, err := youtube.FetchLyrics("Song", "Artist", 180)
+	youtubeLyrics, err := youtube.FetchLyrics(ctx, "Song", "Artist", 180)
 	if err != nil || youtubeLyrics.Provider != "YouTube" || youtubeLyrics.SyncType != "LINE_SYNCED" {
 		t.Fatalf("youtube lyrics = %#v/%v", youtubeLyrics, err)
 	}
@@ -303,7 +306,7 @@ func TestExternalLyricsProvidersWithFakeHTTP(t *testing.T) {
 			return &http.Response{StatusCode: 404, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(`{}`)), Request: req}, nil
 		}
 	})}}
-	kugouLyrics, err := kugou.FetchLyrics("Song", "Artist", 180)
+	kugouLyrics, err := kugou.FetchLyrics(ctx, "Song", "Artist", 180)
 	if err != nil || kugouLyrics.Provider != "Kugou" || kugouLyrics.SyncType != "LINE_SYNCED" {
 		t.Fatalf("kugou lyrics = %#v/%v", kugouLyrics, err)
 	}
@@ -318,7 +321,7 @@ func TestExternalLyricsProvidersWithFakeHTTP(t *testing.T) {
 			return &http.Response{StatusCode: 404, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(`{}`)), Request: req}, nil
 		}
 	})}}
-	geniusLyrics, err := genius.FetchLyrics("Song", "Artist", 180)
+	geniusLyrics, err := genius.FetchLyrics(ctx, "Song", "Artist", 180)
 	if err != nil || geniusLyrics.Provider != "Genius" || geniusLyrics.SyncType != "UNSYNCED" {
 		t.Fatalf("genius lyrics = %#v/%v", geniusLyrics, err)
 	}
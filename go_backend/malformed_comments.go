@@ -0,0 +1,127 @@
+package gobackend
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/go-flac/flacvorbis/v2"
+	"github.com/go-flac/go-flac/v2"
+)
+
+var errVorbisCommentLength = errors.New("vorbis comment length runs past the end of the block")
+
+// checkVorbisCommentLengths walks the vendor string and comment lengths of a
+// comment block body without allocating. flacvorbis allocates whatever the
+// vendor length and comment count claim before reading, so a hostile block
+// of a few bytes could ask it for gigabytes.
+func checkVorbisCommentLengths(data []byte) error {
+	rest := data
+	next := func() (uint32, bool) {
+		if len(rest) < 4 {
+			return 0, false
+		}
+		n := binary.LittleEndian.Uint32(rest)
+		rest = rest[4:]
+		return n, true
+	}
+	vendorLen, ok := next()
+	if !ok || uint64(vendorLen) > uint64(len(rest)) {
+		return errVorbisCommentLength
+	}
+	rest = rest[vendorLen:]
+	count, ok := next()
+	if !ok || uint64(count) > uint64(len(rest))/4 {
+		return errVorbisCommentLength
+	}
+	for range count {
+		length, ok := next()
+		if !ok || uint64(length) > uint64(len(rest)) {
+			return errVorbisCommentLength
+		}
+		rest = rest[length:]
+	}
+	return nil
+}
+
+// parseVorbisCommentBlock is flacvorbis.ParseFromMetaDataBlock with the
+// lengths checked first.
+func parseVorbisCommentBlock(meta flac.MetaDataBlock) (*flacvorbis.MetaDataBlockVorbisComment, error) {
+	if meta.Type == flac.VorbisComment {
+		if err := checkVorbisCommentLengths(meta.Data); err != nil {
+			return nil, err
+		}
+	}
+	return flacvorbis.ParseFromMetaDataBlock(meta)
+}
+
+// malformedCommentKey holds the text of a malformed comment when
+// KeepMalformedComments is set.
+const malformedCommentKey = "MALFORMED_COMMENT"
+
+// isMalformedVorbisComment reports a comment with no '=', an empty field
+// name or a field name outside the Vorbis grammar. Broken taggers write bare
+// tokens like these; getComment never sees them and some players reject a
+// block containing one.
+func isMalformedVorbisComment(comment string) bool {
+	for i := 0; i < len(comment); i++ {
+		if comment[i] == '=' {
+			return validateVorbisKey(comment[:i]) != nil
+		}
+	}
+	return true
+}
+
+// repairVorbisComments drops the malformed comments, or with keep re-emits
+// each as MALFORMED_COMMENT=<text>, and returns them in order.
+func repairVorbisComments(comments []string, keep bool) ([]string, []string) {
+	var malformed []string
+	repaired := comments[:0:0]
+	for _, comment := range comments {
+		if !isMalformedVorbisComment(comment) {
+			repaired = append(repaired, comment)
+			continue
+		}
+		malformed = append(malformed, comment)
+		if keep {
+			repaired = append(repaired, malformedCommentKey+"="+comment)
+		}
+	}
+	if malformed == nil {
+		return comments, nil
+	}
+	return repaired, malformed
+}
+
+// repairFLACComments applies repairVorbisComments to the comment block of f
+// as KeepMalformedComments asks, so a save never writes a comment that
+// breaks the spec. It returns the malformed comments found.
+func repairFLACComments(f *flac.File) []string {
+	for i, meta := range f.Meta {
+		if meta.Type != flac.VorbisComment {
+			continue
+		}
+		cmt, err := parseVorbisCommentBlock(*meta)
+		if err != nil {
+			return nil
+		}
+		comments, malformed := repairVorbisComments(cmt.Comments, GetBackendConfig().KeepMalformedComments)
+		if malformed != nil {
+			cmt.Comments = comments
+			block := cmt.Marshal()
+			f.Meta[i] = &block
+		}
+		return malformed
+	}
+	return nil
+}
+
+func logMalformedComments(filePath string, malformed []string) {
+	if len(malformed) == 0 {
+		return
+	}
+	action := "Dropped"
+	if GetBackendConfig().KeepMalformedComments {
+		action = "Renamed"
+	}
+	GoLog("[Metadata] %s %d malformed comment(s) in %s\n", action, len(malformed), filePath)
+}
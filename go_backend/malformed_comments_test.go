@@ -0,0 +1,101 @@
+package gobackend
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/go-flac/flacvorbis/v2"
+	"github.com/go-flac/go-flac/v2"
+)
+
+// writeMalformedCommentFLAC writes a fixture whose comment block holds the
+// given comments verbatim, bypassing the repairing save path.
+func writeMalformedCommentFLAC(t *testing.T, comments ...string) string {
+	t.Helper()
+	path := writeTestFLACWithMetadata(t, Metadata{})
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	for i, meta := range f.Meta {
+		if meta.Type == flac.VorbisComment {
+			cmt, err := flacvorbis.ParseFromMetaDataBlock(*meta)
+			if err != nil {
+				t.Fatalf("parse comments: %v", err)
+			}
+			cmt.Comments = comments
+			block := cmt.Marshal()
+			f.Meta[i] = &block
+		}
+	}
+	if err := f.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return path
+}
+
+func TestRepairVorbisComments(t *testing.T) {
+	comments := []string{"TITLE=Song", "BARETOKEN", "=orphan value", "BAD\x01KEY=x", "ARTIST="}
+	dropped, malformed := repairVorbisComments(comments, false)
+	if !reflect.DeepEqual(dropped, []string{"TITLE=Song", "ARTIST="}) {
+		t.Fatalf("dropped = %q", dropped)
+	}
+	if !reflect.DeepEqual(malformed, []string{"BARETOKEN", "=orphan value", "BAD\x01KEY=x"}) {
+		t.Fatalf("malformed = %q", malformed)
+	}
+	kept, _ := repairVorbisComments(comments, true)
+	want := []string{"TITLE=Song", "MALFORMED_COMMENT=BARETOKEN", "MALFORMED_COMMENT==orphan value", "MALFORMED_COMMENT=BAD\x01KEY=x", "ARTIST="}
+	if !reflect.DeepEqual(kept, want) {
+		t.Fatalf("kept = %q", kept)
+	}
+	if clean, malformed := repairVorbisComments(dropped, false); malformed != nil || !reflect.DeepEqual(clean, dropped) {
+		t.Fatalf("clean comments changed: %q %q", clean, malformed)
+	}
+}
+
+func TestEmbedAllDropsMalformedComments(t *testing.T) {
+	path := writeMalformedCommentFLAC(t, "TITLE=Old", "BARETOKEN", "=orphan value")
+	result, err := EmbedAllWithResult(path, Metadata{Artist: "Artist"}, Lyrics{}, nil, EmbedOptions{})
+	if err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	if len(result.Warnings) != 2 {
+		t.Fatalf("warnings = %q", result.Warnings)
+	}
+	comments, err := readVorbisCommentList(path)
+	if err != nil {
+		t.Fatalf("readVorbisCommentList: %v", err)
+	}
+	if !reflect.DeepEqual(comments, []string{"TITLE=Old", "ARTIST=Artist"}) {
+		t.Fatalf("comments = %q", comments)
+	}
+}
+
+func TestSaveKeepsMalformedCommentsWhenConfigured(t *testing.T) {
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	cfg := original
+	cfg.KeepMalformedComments = true
+	if err := SetBackendConfig(cfg); err != nil {
+		t.Fatalf("SetBackendConfig: %v", err)
+	}
+
+	path := writeMalformedCommentFLAC(t, "BARETOKEN", "TITLE=Old", "=orphan value")
+	if err := EditFlacFields(path, map[string]string{"title": "New"}); err != nil {
+		t.Fatalf("EditFlacFields: %v", err)
+	}
+	comments, err := readVorbisCommentList(path)
+	if err != nil {
+		t.Fatalf("readVorbisCommentList: %v", err)
+	}
+	for _, comment := range comments {
+		if isMalformedVorbisComment(comment) {
+			t.Fatalf("malformed comment written: %q", comments)
+		}
+	}
+	if !slices.Contains(comments, "MALFORMED_COMMENT=BARETOKEN") || !slices.Contains(comments, "MALFORMED_COMMENT==orphan value") ||
+		!slices.Contains(comments, "TITLE=New") {
+		t.Fatalf("comments = %q", comments)
+	}
+}
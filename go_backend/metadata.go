@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	stdimage "image"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
@@ -15,6 +14,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-flac/flacpicture/v2"
 	"github.com/go-flac/flacvorbis/v2"
@@ -85,9 +85,9 @@ func buildPictureBlock(coverPath string, coverData []byte) (flac.MetaDataBlock,
 	}
 
 	// Width/height/depth are optional in practice; keep zero when decode fails.
-	if cfg, format, err := stdimage.DecodeConfig(bytes.NewReader(coverData)); err == nil {
-		picture.Width = uint32(cfg.Width)
-		picture.Height = uint32(cfg.Height)
+	if width, height, format, err := coverDimensions(coverData); err == nil {
+		picture.Width = uint32(width)
+		picture.Height = uint32(height)
 		switch format {
 		case "png":
 			picture.ColorDepth = 32
@@ -126,13 +126,76 @@ type Metadata struct {
 	ReplayGainTrackPeak string // e.g. "0.988831"
 	ReplayGainAlbumGain string // e.g. "-7.20 dB"
 	ReplayGainAlbumPeak string // e.g. "1.000000"
+
+	// Loudness comments of other taggers. The R128 gains are Q7.8 integers
+	// as Opus files carry them, e.g. "-1280" for -5 dB.
+	R128TrackGain               string
+	R128AlbumGain               string
+	ReplayGainReferenceLoudness string // e.g. "89.0 dB"
+
+	// Loudness is set by ReadMetadata when the file has any ReplayGain or
+	// R128 comment; the embeds ignore it.
+	Loudness *Loudness
+
+	// HasCommentPicture is set by ReadMetadata when cover art is stored as a
+	// METADATA_BLOCK_PICTURE comment; see MigrateCommentPicture.
+	HasCommentPicture bool
+
+	// Source, SourceQuality and DownloadedAt are read from the
+	// TagDownloadSource, TagDownloadSourceQuality and TagDownloadedAt
+	// comments. The embeds do not write them; pass those keys in
+	// EmbedOptions.ExtraTags instead.
+	Source        string
+	SourceQuality string
+	DownloadedAt  string
+
+	// CoverSource is the TagCoverArtSource comment: where an auto-fetched
+	// cover came from.
+	CoverSource string
+
+	// YearConflict is set by ReadMetadata to the YEAR comment when it
+	// disagrees with DATE, which Date is taken from.
+	YearConflict string
+
+	// CommentWarning is set by ReadMetadata when the comment block is
+	// bloated, with its comment count and size in bytes.
+	CommentWarning string
+
+	// Genres lists the GENRE comments when the file has more than one;
+	// Genre holds the first.
+	Genres []string
 }
 
 func EmbedMetadata(filePath string, metadata Metadata, coverPath string) error {
-	f, err := flac.ParseFile(filePath)
+	_, err := EmbedMetadataWithResult(filePath, metadata, coverPath)
+	return err
+}
+
+// EmbedMetadataWithResult is EmbedMetadata reporting what changed.
+func EmbedMetadataWithResult(filePath string, metadata Metadata, coverPath string) (*EmbedResult, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, true, func(localPath string) (*EmbedResult, error) {
+			return EmbedMetadataWithResult(localPath, metadata, coverPath)
+		})
+	}
+	started := time.Now()
+	result := newEmbedResult()
+
+	if err := checkWriteAllowed(filePath); err != nil {
+		return nil, err
+	}
+
+	release, err := acquireHeavyOperation()
 	if err != nil {
-		return fmt.Errorf("failed to parse FLAC file: %w", err)
+		return nil, err
+	}
+	defer release()
+
+	f, err := parseFLACStaged(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse FLAC file: %w", err)
 	}
+	before := takeTagSnapshot(f)
 
 	var cmtIdx int = -1
 	var cmt *flacvorbis.MetaDataBlockVorbisComment
@@ -140,9 +203,9 @@ func EmbedMetadata(filePath string, metadata Metadata, coverPath string) error {
 	for idx, meta := range f.Meta {
 		if meta.Type == flac.VorbisComment {
 			cmtIdx = idx
-			cmt, err = flacvorbis.ParseFromMetaDataBlock(*meta)
+			cmt, err = parseVorbisCommentBlock(*meta)
 			if err != nil {
-				return fmt.Errorf("failed to parse vorbis comment: %w", err)
+				return nil, fmt.Errorf("failed to parse vorbis comment: %w", err)
 			}
 			break
 		}
@@ -153,6 +216,9 @@ func EmbedMetadata(filePath string, metadata Metadata, coverPath string) error {
 	}
 
 	writeVorbisMetadata(cmt, metadata)
+	if coverPath != "" && fileExists(coverPath) {
+		clearCoverSource(cmt)
+	}
 
 	cmtBlock := cmt.Marshal()
 	if cmtIdx >= 0 {
@@ -166,6 +232,7 @@ func EmbedMetadata(filePath string, metadata Metadata, coverPath string) error {
 			coverData, err := os.ReadFile(coverPath)
 			if err != nil {
 				fmt.Printf("[Metadata] Warning: Failed to read cover file %s: %v\n", coverPath, err)
+				result.warn("failed to read cover file %s: %v", coverPath, err)
 			} else {
 				for i := len(f.Meta) - 1; i >= 0; i-- {
 					if f.Meta[i].Type == flac.Picture {
@@ -175,24 +242,54 @@ func EmbedMetadata(filePath string, metadata Metadata, coverPath string) error {
 
 				picBlock, err := buildPictureBlock(coverPath, coverData)
 				if err != nil {
-					return fmt.Errorf("failed to create picture block: %w", err)
+					return nil, fmt.Errorf("failed to create picture block: %w", err)
 				}
 				f.Meta = append(f.Meta, &picBlock)
 				fmt.Printf("[Metadata] Cover art embedded successfully (%d bytes)\n", len(coverData))
 			}
 		} else {
 			fmt.Printf("[Metadata] Warning: Cover file does not exist: %s\n", coverPath)
+			result.warn("cover file does not exist: %s", coverPath)
 		}
 	}
 
-	return f.Save(filePath)
+	return saveEmbedResult(f, filePath, "embed_metadata", before, result, started)
 }
 
+// EmbedMetadataWithCoverData writes metadata and, when coverData is set,
+// replaces the front cover. Following it with EmbedLyrics rewrites the file a
+// second time; use EmbedAll to write tags, lyrics and cover in one pass.
 func EmbedMetadataWithCoverData(filePath string, metadata Metadata, coverData []byte) error {
-	f, err := flac.ParseFile(filePath)
+	_, err := EmbedMetadataWithCoverDataResult(filePath, metadata, coverData)
+	return err
+}
+
+// EmbedMetadataWithCoverDataResult is EmbedMetadataWithCoverData reporting
+// what changed.
+func EmbedMetadataWithCoverDataResult(filePath string, metadata Metadata, coverData []byte) (*EmbedResult, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, true, func(localPath string) (*EmbedResult, error) {
+			return EmbedMetadataWithCoverDataResult(localPath, metadata, coverData)
+		})
+	}
+	started := time.Now()
+	result := newEmbedResult()
+
+	if err := checkWriteAllowed(filePath); err != nil {
+		return nil, err
+	}
+
+	release, err := acquireHeavyOperation()
 	if err != nil {
-		return fmt.Errorf("failed to parse FLAC file: %w", err)
+		return nil, err
+	}
+	defer release()
+
+	f, err := parseFLACStaged(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse FLAC file: %w", err)
 	}
+	before := takeTagSnapshot(f)
 
 	var cmtIdx int = -1
 	var cmt *flacvorbis.MetaDataBlockVorbisComment
@@ -200,9 +297,9 @@ func EmbedMetadataWithCoverData(filePath string, metadata Metadata, coverData []
 	for idx, meta := range f.Meta {
 		if meta.Type == flac.VorbisComment {
 			cmtIdx = idx
-			cmt, err = flacvorbis.ParseFromMetaDataBlock(*meta)
+			cmt, err = parseVorbisCommentBlock(*meta)
 			if err != nil {
-				return fmt.Errorf("failed to parse vorbis comment: %w", err)
+				return nil, fmt.Errorf("failed to parse vorbis comment: %w", err)
 			}
 			break
 		}
@@ -213,6 +310,9 @@ func EmbedMetadataWithCoverData(filePath string, metadata Metadata, coverData []
 	}
 
 	writeVorbisMetadata(cmt, metadata)
+	if len(coverData) > 0 {
+		clearCoverSource(cmt)
+	}
 
 	cmtBlock := cmt.Marshal()
 	if cmtIdx >= 0 {
@@ -230,16 +330,320 @@ func EmbedMetadataWithCoverData(filePath string, metadata Metadata, coverData []
 
 		picBlock, err := buildPictureBlock("", coverData)
 		if err != nil {
-			return fmt.Errorf("failed to create picture block: %w", err)
+			return nil, fmt.Errorf("failed to create picture block: %w", err)
 		}
 		f.Meta = append(f.Meta, &picBlock)
 		fmt.Printf("[Metadata] Cover art embedded successfully (%d bytes)\n", len(coverData))
 	}
 
-	return f.Save(filePath)
+	return saveEmbedResult(f, filePath, "embed_metadata", before, result, started)
+}
+
+// Lyrics is the lyrics payload for EmbedAll. Text is usually LRC and is
+// written to LYRICS and UNSYNCEDLYRICS, like EmbedLyrics does. Language is
+// the ISO 639-1 code from the lyrics provider, if any; when empty it is
+// detected from Text.
+type Lyrics struct {
+	Text     string
+	Language string
+}
+
+// Tag policies for EmbedOptions.TagPolicy.
+const (
+	// TagPolicyPreserve keeps every existing comment that the embed does not
+	// overwrite, including keys the app knows nothing about.
+	TagPolicyPreserve = "preserve"
+	// TagPolicyReplace drops every existing comment except KeepKeys and the
+	// gapless playback keys, and writes only what is in Metadata and
+	// ExtraTags.
+	TagPolicyReplace = "replace"
+)
+
+type EmbedOptions struct {
+	// CoverPath is read when coverData is empty, as EmbedMetadata does.
+	CoverPath string `json:"cover_path"`
+	// TagPolicy is TagPolicyPreserve (the default when empty) or
+	// TagPolicyReplace.
+	TagPolicy string `json:"tag_policy"`
+	// KeepKeys lists comments that survive TagPolicyReplace.
+	KeepKeys []string `json:"keep_keys"`
+	// ExtraTags are raw comments written after Metadata, replacing any
+	// existing values of the same keys. TagDownloadSource,
+	// TagDownloadSourceQuality and TagDownloadedAt (RFC 3339) record where
+	// the file came from.
+	ExtraTags []TagPair `json:"extra_tags"`
+	// Preset is a TagPreset JSON run over metadata before anything is
+	// written, with the path template variables of the file. A rule that
+	// empties a field removes its comments from the file. Empty means no
+	// preset.
+	Preset string `json:"preset"`
+	// NormalizeFeaturing is FeaturingKeep, FeaturingTitle or FeaturingTag.
+	// When set, ARTIST, TITLE and the multi-valued ARTISTS tag are rewritten
+	// consistently from the featured artists found in either. Empty leaves
+	// them as given.
+	NormalizeFeaturing string `json:"normalize_featuring"`
+	// CompatProfile limits value and comment block sizes for picky
+	// players: CompatProfileStrictCar or CompatProfileUnlimited (the
+	// default when empty).
+	CompatProfile string `json:"compat_profile"`
+	// CoverPolicy decides between the given cover and the one already in
+	// the file: CoverPolicyReplaceAlways (the default when empty),
+	// CoverPolicyKeepExisting, CoverPolicyReplaceIfSmaller with CoverMinDim,
+	// or CoverPolicyRemoveAll. EmbedResult.CoverDecision reports the outcome.
+	CoverPolicy string `json:"cover_policy"`
+	CoverMinDim int    `json:"cover_min_dim"`
+	// SortTags writes the comments in canonical order instead of keeping the
+	// file's: TITLE, ARTIST, ARTISTS, ALBUM, ALBUMARTIST, TRACKNUMBER,
+	// TRACKTOTAL, DISCNUMBER, DISCTOTAL, DATE, GENRE and ISRC first, then the
+	// other keys alphabetically, with upper-cased keys and each key's values
+	// in input order. The same tags then give a byte-identical comment block
+	// on every device.
+	SortTags bool `json:"sort_tags"`
+	// CoverSource is written to TagCoverArtSource when the cover is
+	// embedded: the URL or provider name it was fetched from. Embedding a
+	// cover without one, or removing the cover, drops the tag.
+	CoverSource string `json:"cover_source"`
+	// OmitCoverSource never writes TagCoverArtSource, for users who do not
+	// want fetch URLs kept in their files.
+	OmitCoverSource bool `json:"omit_cover_source"`
+
+	// presetClearTags are the comments Preset emptied, carried across the
+	// opener copy, where the preset has already run.
+	presetClearTags []string
+}
+
+// applyTagPolicy returns the existing comments the embed starts from.
+func applyTagPolicy(comments []string, opts EmbedOptions) ([]string, error) {
+	switch opts.TagPolicy {
+	case "", TagPolicyPreserve:
+		return comments, nil
+	case TagPolicyReplace:
+	default:
+		return nil, fmt.Errorf("unknown tag policy: %q", opts.TagPolicy)
+	}
+
+	keepKeys, err := normalizeTagKeys(opts.KeepKeys)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool, len(keepKeys))
+	for _, key := range keepKeys {
+		keep[key] = true
+	}
+	kept := make([]string, 0, len(keepKeys))
+	for _, comment := range comments {
+		if key, ok := vorbisCommentKey(comment); ok && (keep[key] || isGaplessTagKey(key)) {
+			kept = append(kept, comment)
+		}
+	}
+	return kept, nil
+}
+
+// EmbedAll writes metadata, lyrics and cover art with a single parse and a
+// single save. It replaces the post-download pattern of calling
+// EmbedMetadataWithCoverData followed by EmbedLyrics, which rewrites the
+// whole file twice. Empty fields, lyrics and cover leave what is already in
+// the file untouched unless opts.TagPolicy is TagPolicyReplace.
+func EmbedAll(filePath string, metadata Metadata, lyrics Lyrics, coverData []byte, opts EmbedOptions) error {
+	_, err := EmbedAllWithResult(filePath, metadata, lyrics, coverData, opts)
+	return err
+}
+
+// EmbedAllWithResult is EmbedAll reporting what changed.
+func EmbedAllWithResult(filePath string, metadata Metadata, lyrics Lyrics, coverData []byte, opts EmbedOptions) (*EmbedResult, error) {
+	if isOpenerPath(filePath) {
+		// The local copy's path is meaningless to path template variables,
+		// so the preset runs here, without them.
+		if strings.TrimSpace(opts.Preset) != "" {
+			var err error
+			if metadata, opts.presetClearTags, err = applyPresetClearing(metadata, opts.Preset, ""); err != nil {
+				return nil, err
+			}
+			opts.Preset = ""
+		}
+		return viaFileOpener(filePath, true, func(localPath string) (*EmbedResult, error) {
+			return EmbedAllWithResult(localPath, metadata, lyrics, coverData, opts)
+		})
+	}
+	started := time.Now()
+	result := newEmbedResult()
+
+	if err := checkWriteAllowed(filePath); err != nil {
+		return nil, err
+	}
+
+	if _, err := applyTagPolicy(nil, opts); err != nil {
+		return nil, err
+	}
+	extraOrder, extraValues, err := groupTagPairs(opts.ExtraTags)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateDownloadSourceTags(extraValues); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(opts.Preset) != "" {
+		if metadata, opts.presetClearTags, err = applyPresetClearing(metadata, opts.Preset, filePath); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateFeaturingMode(opts.NormalizeFeaturing); err != nil {
+		return nil, err
+	}
+	if err := validateCompatProfile(opts.CompatProfile); err != nil {
+		return nil, err
+	}
+	if err := validateCoverPolicy(opts); err != nil {
+		return nil, err
+	}
+
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	coverPath := ""
+	if len(coverData) == 0 && opts.CoverPath != "" {
+		coverPath = opts.CoverPath
+		if !fileExists(opts.CoverPath) {
+			GoLog("[Metadata] Warning: Cover file does not exist: %s\n", opts.CoverPath)
+			result.warn("cover file does not exist: %s", opts.CoverPath)
+		} else if coverData, err = os.ReadFile(opts.CoverPath); err != nil {
+			GoLog("[Metadata] Warning: Failed to read cover file %s: %v\n", opts.CoverPath, err)
+			result.warn("failed to read cover file %s: %v", opts.CoverPath, err)
+		}
+	}
+
+	f, err := parseFLACStaged(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+	before := takeTagSnapshot(f)
+
+	var cmtIdx int = -1
+	var cmt *flacvorbis.MetaDataBlockVorbisComment
+
+	for idx, meta := range f.Meta {
+		if meta.Type == flac.VorbisComment {
+			cmtIdx = idx
+			cmt, err = parseVorbisCommentBlock(*meta)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to parse vorbis comment: %w", err)
+			}
+			break
+		}
+	}
+
+	if cmt == nil {
+		cmt = flacvorbis.New()
+	}
+
+	if lyrics.Text != "" {
+		metadata.Lyrics = lyrics.Text
+	}
+	base, err := applyTagPolicy(cmt.Comments, opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	comments := newVorbisCommentMap(base)
+	if lyrics.Text != "" && lyrics.Language != "" {
+		comments.set(lyricsLanguageKey, lyrics.Language)
+	}
+	if opts.NormalizeFeaturing != "" && metadata.Artist != "" {
+		// The title may carry the featured artists, so use the file's one
+		// when the caller did not pass a title.
+		if metadata.Title == "" {
+			metadata.Title = comments.get("TITLE")
+		}
+		var artists, featured []string
+		metadata, artists, featured = normalizeFeaturing(metadata, opts.NormalizeFeaturing, comments.getValues(featuredArtistTag))
+		comments.setValues("ARTISTS", artists)
+		if opts.NormalizeFeaturing == FeaturingTag && len(featured) > 0 {
+			comments.setValues(featuredArtistTag, featured)
+		} else {
+			comments.remove(featuredArtistTag)
+		}
+	}
+	applyVorbisMetadata(comments, metadata)
+	for _, key := range opts.presetClearTags {
+		comments.remove(key)
+	}
+	for _, key := range extraOrder {
+		comments.setValues(key, extraValues[key])
+	}
+	comments.syncMultiValueTags()
+	comments.syncR128Gain()
+	if compatProfiles[opts.CompatProfile].writeYear {
+		comments.syncYearTag()
+	}
+	result.CoverDecision, result.ExistingCoverWidth, result.ExistingCoverHeight = decideCover(opts, before.cover, len(coverData) > 0)
+	comments.applyCoverSource(result.CoverDecision, opts)
+	cmt.Comments = applyCompatProfile(opts.CompatProfile, filePath, cmt.Vendor, comments.comments())
+	if opts.SortTags {
+		cmt.Comments = sortVorbisComments(cmt.Comments)
+	}
+
+	cmtBlock := cmt.Marshal()
+	if cmtIdx >= 0 {
+		f.Meta[cmtIdx] = &cmtBlock
+	} else {
+		f.Meta = append(f.Meta, &cmtBlock)
+	}
+
+	if result.CoverDecision == CoverDecisionEmbedded || result.CoverDecision == CoverDecisionRemoved {
+		for i := len(f.Meta) - 1; i >= 0; i-- {
+			if f.Meta[i].Type == flac.Picture {
+				f.Meta = append(f.Meta[:i], f.Meta[i+1:]...)
+			}
+		}
+	}
+	if result.CoverDecision == CoverDecisionEmbedded {
+		picBlock, err := buildPictureBlock(coverPath, coverData)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to create picture block: %w", err)
+		}
+		f.Meta = append(f.Meta, &picBlock)
+	} else {
+		coverData = nil
+	}
+
+	if _, err := saveEmbedResult(f, filePath, "embed_all", before, result, started); err != nil {
+		return nil, err
+	}
+	GoLog("[Metadata] Embedded tags, %d byte(s) of lyrics and %d byte(s) of cover in one pass: %s\n",
+		len(lyrics.Text), len(coverData), filePath)
+	return result, nil
 }
 
+// ReadMetadata reads the Vorbis comments of a FLAC file. Results are served
+// from the metadata read cache while the file is unchanged. A file that
+// fails to parse because it is structurally broken is quarantined.
 func ReadMetadata(filePath string) (*Metadata, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, false, ReadMetadata)
+	}
+	metadata, err := cachedFileRead(filePath, metadataCacheMetadata, func() (Metadata, error) {
+		if err := checkIncompleteAudioFile(filePath); err != nil {
+			return Metadata{}, err
+		}
+		m, err := readMetadataUncached(filePath)
+		if err != nil {
+			quarantineIfBroken(filePath, "read_metadata")
+			return Metadata{}, err
+		}
+		return *m, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+func readMetadataUncached(filePath string) (*Metadata, error) {
 	f, err := flac.ParseFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse FLAC file: %w", err)
@@ -249,10 +653,11 @@ func ReadMetadata(filePath string) (*Metadata, error) {
 
 	for _, meta := range f.Meta {
 		if meta.Type == flac.VorbisComment {
-			cmt, err := flacvorbis.ParseFromMetaDataBlock(*meta)
+			cmt, err := parseVorbisCommentBlock(*meta)
 			if err != nil {
 				continue
 			}
+			metadata.CommentWarning = commentBloatWarning(filePath, len(cmt.Comments), len(meta.Data))
 
 			metadata.Title = getComment(cmt, "TITLE")
 			metadata.Artist = getJoinedComment(cmt, "ARTIST")
@@ -264,7 +669,7 @@ func ReadMetadata(filePath string) (*Metadata, error) {
 			if metadata.AlbumArtist == "" {
 				metadata.AlbumArtist = getJoinedComment(cmt, "ALBUM_ARTIST")
 			}
-			metadata.Date = getComment(cmt, "DATE")
+			metadata.Date, metadata.YearConflict = reconcileDateYear(filePath, getComment(cmt, "DATE"), getComment(cmt, "YEAR"))
 			metadata.ISRC = getComment(cmt, "ISRC")
 			metadata.Description = getComment(cmt, "DESCRIPTION")
 
@@ -294,12 +699,14 @@ func ReadMetadata(filePath string) (*Metadata, error) {
 					metadata.DiscNumber, metadata.TotalDiscs = parseIndexPair(discNum)
 				}
 			}
-
-			if metadata.Date == "" {
-				metadata.Date = getComment(cmt, "YEAR")
-			}
+			totals := newVorbisCommentMap(cmt.Comments)
+			metadata.TotalTracks = resolveIndexTotal(filePath, "track", metadata.TotalTracks, totals.indexTotalAliases(trackTotalKeys))
+			metadata.TotalDiscs = resolveIndexTotal(filePath, "disc", metadata.TotalDiscs, totals.indexTotalAliases(discTotalKeys))
 
 			metadata.Genre = getComment(cmt, "GENRE")
+			if genres := getCommentValues(cmt, "GENRE"); len(genres) > 1 {
+				metadata.Genres = genres
+			}
 			metadata.Label = getComment(cmt, "ORGANIZATION")
 			if metadata.Label == "" {
 				metadata.Label = getComment(cmt, "LABEL")
@@ -315,6 +722,17 @@ func ReadMetadata(filePath string) (*Metadata, error) {
 			metadata.ReplayGainTrackPeak = getComment(cmt, "REPLAYGAIN_TRACK_PEAK")
 			metadata.ReplayGainAlbumGain = getComment(cmt, "REPLAYGAIN_ALBUM_GAIN")
 			metadata.ReplayGainAlbumPeak = getComment(cmt, "REPLAYGAIN_ALBUM_PEAK")
+			metadata.R128TrackGain = getComment(cmt, r128TrackGainTag)
+			metadata.R128AlbumGain = getComment(cmt, r128AlbumGainTag)
+			metadata.ReplayGainReferenceLoudness = getComment(cmt, replayGainReferenceLoudnessTag)
+			metadata.Loudness = buildLoudness(metadata.ReplayGainTrackGain, metadata.ReplayGainTrackPeak,
+				metadata.ReplayGainAlbumGain, metadata.ReplayGainAlbumPeak, metadata.ReplayGainReferenceLoudness,
+				metadata.R128TrackGain, metadata.R128AlbumGain)
+			metadata.HasCommentPicture = getComment(cmt, commentPictureKey) != ""
+			metadata.Source = getComment(cmt, TagDownloadSource)
+			metadata.SourceQuality = getComment(cmt, TagDownloadSourceQuality)
+			metadata.CoverSource = getComment(cmt, TagCoverArtSource)
+			metadata.DownloadedAt = getComment(cmt, TagDownloadedAt)
 
 			break
 		}
@@ -329,10 +747,26 @@ func ReadMetadata(filePath string) (*Metadata, error) {
 // absent from the map are left untouched.  This is the correct function for
 // partial edits (e.g. writing only ReplayGain tags) and full editor saves alike.
 func EditFlacFields(filePath string, fields map[string]string) error {
-	f, err := flac.ParseFile(filePath)
+	if isOpenerPath(filePath) {
+		return viaFileOpenerErr(filePath, true, func(localPath string) error {
+			return EditFlacFields(localPath, fields)
+		})
+	}
+	if err := checkWriteAllowed(filePath); err != nil {
+		return err
+	}
+
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	f, err := parseFLACStaged(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to parse FLAC file: %w", err)
 	}
+	before := takeTagSnapshot(f)
 
 	var cmtIdx int = -1
 	var cmt *flacvorbis.MetaDataBlockVorbisComment
@@ -340,7 +774,7 @@ func EditFlacFields(filePath string, fields map[string]string) error {
 	for idx, meta := range f.Meta {
 		if meta.Type == flac.VorbisComment {
 			cmtIdx = idx
-			cmt, err = flacvorbis.ParseFromMetaDataBlock(*meta)
+			cmt, err = parseVorbisCommentBlock(*meta)
 			if err != nil {
 				return fmt.Errorf("failed to parse vorbis comment: %w", err)
 			}
@@ -350,6 +784,7 @@ func EditFlacFields(filePath string, fields map[string]string) error {
 	if cmt == nil {
 		cmt = flacvorbis.New()
 	}
+	comments := newVorbisCommentMap(cmt.Comments)
 
 	artistMode := fields["artist_tag_mode"]
 
@@ -369,11 +804,15 @@ func EditFlacFields(filePath string, fields map[string]string) error {
 		"replaygain_track_peak": "REPLAYGAIN_TRACK_PEAK",
 		"replaygain_album_gain": "REPLAYGAIN_ALBUM_GAIN",
 		"replaygain_album_peak": "REPLAYGAIN_ALBUM_PEAK",
+
+		"replaygain_reference_loudness": replayGainReferenceLoudnessTag,
+		"r128_track_gain":               r128TrackGainTag,
+		"r128_album_gain":               r128AlbumGainTag,
 	}
 
 	for fieldKey, vorbisKey := range simpleKeys {
 		if v, ok := fields[fieldKey]; ok {
-			setOrClearComment(cmt, vorbisKey, v)
+			comments.setOrClear(vorbisKey, v)
 		}
 	}
 
@@ -389,29 +828,45 @@ func EditFlacFields(filePath string, fields map[string]string) error {
 	for fieldKey, aliases := range aliasCleanup {
 		if _, ok := fields[fieldKey]; ok {
 			for _, alias := range aliases {
-				removeCommentKey(cmt, alias)
+				comments.remove(alias)
+			}
+		}
+	}
+	if fields["date"] != "" && GetBackendConfig().WriteYearTag {
+		comments.syncYearTag()
+	}
+	if fields["genre"] != "" {
+		comments.syncMultiValueTags()
+	}
+	if GetBackendConfig().WriteR128Gain {
+		// A cleared ReplayGain gain takes its R128 equivalent with it.
+		for fieldKey, r128Key := range map[string]string{"replaygain_track_gain": r128TrackGainTag, "replaygain_album_gain": r128AlbumGainTag} {
+			if v, ok := fields[fieldKey]; ok && v == "" {
+				comments.remove(r128Key)
 			}
 		}
+		comments.syncR128Gain()
 	}
 
 	// Artist fields: use split-artist logic when mode is set.
 	if v, ok := fields["artist"]; ok {
-		setOrClearArtistComments(cmt, "ARTIST", v, artistMode)
+		comments.setOrClearArtist("ARTIST", v, artistMode)
 	}
 	if v, ok := fields["album_artist"]; ok {
-		setOrClearArtistComments(cmt, "ALBUMARTIST", v, artistMode)
+		comments.setOrClearArtist("ALBUMARTIST", v, artistMode)
 		// Remove aliases from other taggers.
-		removeCommentKey(cmt, "ALBUM ARTIST")
-		removeCommentKey(cmt, "ALBUM_ARTIST")
+		comments.remove("ALBUM ARTIST")
+		comments.remove("ALBUM_ARTIST")
 	}
 
 	// Track/disc numbers: present + empty → clear; when only totals are edited,
 	// preserve the current index number and rewrite the combined value.
 	if _, ok := fields["track_number"]; ok || fields["track_total"] != "" || hasMapKey(fields, "track_total") {
-		currentTrackNum, currentTotalTracks := parseIndexPair(getComment(cmt, "TRACKNUMBER"))
+		currentTrackNum, currentTotalTracks := parseIndexPair(comments.get("TRACKNUMBER"))
 		if currentTrackNum == 0 && currentTotalTracks == 0 {
-			currentTrackNum, currentTotalTracks = parseIndexPair(getComment(cmt, "TRACK"))
+			currentTrackNum, currentTotalTracks = parseIndexPair(comments.get("TRACK"))
 		}
+		currentTotalTracks = resolveIndexTotal(filePath, "track", currentTotalTracks, comments.indexTotalAliases(trackTotalKeys))
 		if v, ok := fields["track_number"]; ok {
 			currentTrackNum = parsePositiveInt(v)
 		}
@@ -419,17 +874,20 @@ func EditFlacFields(filePath string, fields map[string]string) error {
 			currentTotalTracks = parsePositiveInt(v)
 		}
 		if currentTrackNum > 0 {
-			setOrClearComment(cmt, "TRACKNUMBER", formatIndexValue(currentTrackNum, currentTotalTracks))
+			comments.setOrClear("TRACKNUMBER", formatIndexValue(currentTrackNum, currentTotalTracks))
 		} else {
-			removeCommentKey(cmt, "TRACKNUMBER")
+			comments.remove("TRACKNUMBER")
+			currentTotalTracks = 0
 		}
-		removeCommentKey(cmt, "TRACK") // alias
+		comments.setIndexTotal(trackTotalKeys, currentTotalTracks)
+		comments.remove("TRACK") // alias
 	}
 	if _, ok := fields["disc_number"]; ok || fields["disc_total"] != "" || hasMapKey(fields, "disc_total") {
-		currentDiscNum, currentTotalDiscs := parseIndexPair(getComment(cmt, "DISCNUMBER"))
+		currentDiscNum, currentTotalDiscs := parseIndexPair(comments.get("DISCNUMBER"))
 		if currentDiscNum == 0 && currentTotalDiscs == 0 {
-			currentDiscNum, currentTotalDiscs = parseIndexPair(getComment(cmt, "DISC"))
+			currentDiscNum, currentTotalDiscs = parseIndexPair(comments.get("DISC"))
 		}
+		currentTotalDiscs = resolveIndexTotal(filePath, "disc", currentTotalDiscs, comments.indexTotalAliases(discTotalKeys))
 		if v, ok := fields["disc_number"]; ok {
 			currentDiscNum = parsePositiveInt(v)
 		}
@@ -437,24 +895,27 @@ func EditFlacFields(filePath string, fields map[string]string) error {
 			currentTotalDiscs = parsePositiveInt(v)
 		}
 		if currentDiscNum > 0 {
-			setOrClearComment(cmt, "DISCNUMBER", formatIndexValue(currentDiscNum, currentTotalDiscs))
+			comments.setOrClear("DISCNUMBER", formatIndexValue(currentDiscNum, currentTotalDiscs))
 		} else {
-			removeCommentKey(cmt, "DISCNUMBER")
+			comments.remove("DISCNUMBER")
+			currentTotalDiscs = 0
 		}
-		removeCommentKey(cmt, "DISC") // alias
+		comments.setIndexTotal(discTotalKeys, currentTotalDiscs)
+		comments.remove("DISC") // alias
 	}
 
 	// Lyrics: set both LYRICS + UNSYNCEDLYRICS, or clear both.
 	if v, ok := fields["lyrics"]; ok {
 		if v != "" {
-			setOrClearComment(cmt, "LYRICS", v)
-			setOrClearComment(cmt, "UNSYNCEDLYRICS", v)
+			comments.setOrClear("LYRICS", v)
+			comments.setOrClear("UNSYNCEDLYRICS", v)
 		} else {
-			removeCommentKey(cmt, "LYRICS")
-			removeCommentKey(cmt, "UNSYNCEDLYRICS")
+			comments.remove("LYRICS")
+			comments.remove("UNSYNCEDLYRICS")
 		}
 	}
 
+	cmt.Comments = comments.comments()
 	cmtBlock := cmt.Marshal()
 	if cmtIdx >= 0 {
 		f.Meta[cmtIdx] = &cmtBlock
@@ -478,7 +939,7 @@ func EditFlacFields(filePath string, fields map[string]string) error {
 		}
 	}
 
-	return f.Save(filePath)
+	return saveTaggedFLAC(f, filePath, "edit_fields", before)
 }
 
 // writeVorbisMetadata writes all metadata fields to a Vorbis Comment block.
@@ -486,57 +947,86 @@ func EditFlacFields(filePath string, fields map[string]string) error {
 // used by the download embedding path where absent fields should preserve any
 // existing values.  The editor path uses EditFlacFields() instead.
 func writeVorbisMetadata(cmt *flacvorbis.MetaDataBlockVorbisComment, metadata Metadata) {
-	setComment(cmt, "TITLE", metadata.Title)
-	setArtistComments(cmt, "ARTIST", metadata.Artist, metadata.ArtistTagMode)
-	setComment(cmt, "ALBUM", metadata.Album)
-	setArtistComments(cmt, "ALBUMARTIST", metadata.AlbumArtist, metadata.ArtistTagMode)
-	setComment(cmt, "DATE", metadata.Date)
+	comments := newVorbisCommentMap(cmt.Comments)
+	applyVorbisMetadata(comments, metadata)
+	cmt.Comments = comments.comments()
+}
+
+func applyVorbisMetadata(m *vorbisCommentMap, metadata Metadata) {
+	metadata.Lyrics = multilineTagValue(metadata.Lyrics)
+	metadata.Description = multilineTagValue(metadata.Description)
+	metadata.Comment = multilineTagValue(metadata.Comment)
+
+	m.set("TITLE", metadata.Title)
+	m.setArtist("ARTIST", metadata.Artist, metadata.ArtistTagMode)
+	m.set("ALBUM", metadata.Album)
+	m.setArtist("ALBUMARTIST", metadata.AlbumArtist, metadata.ArtistTagMode)
+	m.set("DATE", metadata.Date)
+	if metadata.Date != "" && GetBackendConfig().WriteYearTag {
+		m.syncYearTag()
+	}
 
 	if metadata.TrackNumber > 0 {
-		setComment(cmt, "TRACKNUMBER", formatIndexValue(metadata.TrackNumber, metadata.TotalTracks))
+		m.set("TRACKNUMBER", formatIndexValue(metadata.TrackNumber, metadata.TotalTracks))
+	}
+	if metadata.TotalTracks > 0 {
+		m.setIndexTotal(trackTotalKeys, metadata.TotalTracks)
 	}
 
 	if metadata.DiscNumber > 0 {
-		setComment(cmt, "DISCNUMBER", formatIndexValue(metadata.DiscNumber, metadata.TotalDiscs))
+		m.set("DISCNUMBER", formatIndexValue(metadata.DiscNumber, metadata.TotalDiscs))
+	}
+	if metadata.TotalDiscs > 0 {
+		m.setIndexTotal(discTotalKeys, metadata.TotalDiscs)
 	}
 
 	if metadata.ISRC != "" {
-		setComment(cmt, "ISRC", metadata.ISRC)
+		m.set("ISRC", metadata.ISRC)
 	}
 
 	if metadata.Description != "" {
-		setComment(cmt, "DESCRIPTION", metadata.Description)
+		m.set("DESCRIPTION", metadata.Description)
 	}
 
 	if metadata.Lyrics != "" {
-		setComment(cmt, "LYRICS", metadata.Lyrics)
-		setComment(cmt, "UNSYNCEDLYRICS", metadata.Lyrics)
+		m.set("LYRICS", metadata.Lyrics)
+		m.set("UNSYNCEDLYRICS", metadata.Lyrics)
+		if m.get(lyricsLanguageKey) == "" {
+			if language := lyricsLanguageTag(metadata.Lyrics); language != "" {
+				m.set(lyricsLanguageKey, language)
+			}
+		}
 	}
 
 	if metadata.Genre != "" {
-		setComment(cmt, "GENRE", metadata.Genre)
+		m.set("GENRE", metadata.Genre)
+		m.syncMultiValueTags()
 	}
 
 	if metadata.Label != "" {
-		setComment(cmt, "ORGANIZATION", metadata.Label)
+		m.set("ORGANIZATION", metadata.Label)
 	}
 
 	if metadata.Copyright != "" {
-		setComment(cmt, "COPYRIGHT", metadata.Copyright)
+		m.set("COPYRIGHT", metadata.Copyright)
 	}
 
 	if metadata.Composer != "" {
-		setComment(cmt, "COMPOSER", metadata.Composer)
+		m.set("COMPOSER", metadata.Composer)
 	}
 
 	if metadata.Comment != "" {
-		setComment(cmt, "COMMENT", metadata.Comment)
+		m.set("COMMENT", metadata.Comment)
 	}
 
-	setComment(cmt, "REPLAYGAIN_TRACK_GAIN", metadata.ReplayGainTrackGain)
-	setComment(cmt, "REPLAYGAIN_TRACK_PEAK", metadata.ReplayGainTrackPeak)
-	setComment(cmt, "REPLAYGAIN_ALBUM_GAIN", metadata.ReplayGainAlbumGain)
-	setComment(cmt, "REPLAYGAIN_ALBUM_PEAK", metadata.ReplayGainAlbumPeak)
+	m.set("REPLAYGAIN_TRACK_GAIN", metadata.ReplayGainTrackGain)
+	m.set("REPLAYGAIN_TRACK_PEAK", metadata.ReplayGainTrackPeak)
+	m.set("REPLAYGAIN_ALBUM_GAIN", metadata.ReplayGainAlbumGain)
+	m.set("REPLAYGAIN_ALBUM_PEAK", metadata.ReplayGainAlbumPeak)
+	m.set(replayGainReferenceLoudnessTag, metadata.ReplayGainReferenceLoudness)
+	m.set(r128TrackGainTag, metadata.R128TrackGain)
+	m.set(r128AlbumGainTag, metadata.R128AlbumGain)
+	m.syncR128Gain()
 }
 
 func setComment(cmt *flacvorbis.MetaDataBlockVorbisComment, key, value string) {
@@ -608,14 +1098,30 @@ func setOrClearArtistComments(cmt *flacvorbis.MetaDataBlockVorbisComment, key, v
 // the last value survives when multiple -metadata ARTIST=X flags are used.
 // The native go-flac writer correctly handles multiple Vorbis comments.
 func RewriteSplitArtistTags(filePath, artist, albumArtist string) error {
+	if isOpenerPath(filePath) {
+		return viaFileOpenerErr(filePath, true, func(localPath string) error {
+			return RewriteSplitArtistTags(localPath, artist, albumArtist)
+		})
+	}
+	if err := checkWriteAllowed(filePath); err != nil {
+		return err
+	}
+
 	if !shouldSplitVorbisArtistTags(artistTagModeSplitVorbis) {
 		return nil
 	}
 
-	f, err := flac.ParseFile(filePath)
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	f, err := parseFLACStaged(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to parse FLAC file: %w", err)
 	}
+	before := takeTagSnapshot(f)
 
 	var cmtIdx int = -1
 	var cmt *flacvorbis.MetaDataBlockVorbisComment
@@ -623,7 +1129,7 @@ func RewriteSplitArtistTags(filePath, artist, albumArtist string) error {
 	for idx, meta := range f.Meta {
 		if meta.Type == flac.VorbisComment {
 			cmtIdx = idx
-			cmt, err = flacvorbis.ParseFromMetaDataBlock(*meta)
+			cmt, err = parseVorbisCommentBlock(*meta)
 			if err != nil {
 				return fmt.Errorf("failed to parse vorbis comment: %w", err)
 			}
@@ -645,7 +1151,7 @@ func RewriteSplitArtistTags(filePath, artist, albumArtist string) error {
 		f.Meta = append(f.Meta, &cmtMeta)
 	}
 
-	return f.Save(filePath)
+	return saveTaggedFLAC(f, filePath, "rewrite_artists", before)
 }
 
 func removeCommentKey(cmt *flacvorbis.MetaDataBlockVorbisComment, key string) {
@@ -747,6 +1253,12 @@ func fileExists(path string) bool {
 }
 
 func ExtractCoverArt(filePath string) ([]byte, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, false, ExtractCoverArt)
+	}
+	if err := checkIncompleteAudioFile(filePath); err != nil {
+		return nil, err
+	}
 	f, err := flac.ParseFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse FLAC file: %w", err)
@@ -754,7 +1266,7 @@ func ExtractCoverArt(filePath string) ([]byte, error) {
 
 	for _, meta := range f.Meta {
 		if meta.Type == flac.Picture {
-			pic, err := flacpicture.ParseFromMetaDataBlock(*meta)
+			pic, err := parseFLACPictureMeta(*meta)
 			if err != nil {
 				continue
 			}
@@ -766,7 +1278,7 @@ func ExtractCoverArt(filePath string) ([]byte, error) {
 
 	for _, meta := range f.Meta {
 		if meta.Type == flac.Picture {
-			pic, err := flacpicture.ParseFromMetaDataBlock(*meta)
+			pic, err := parseFLACPictureMeta(*meta)
 			if err != nil {
 				continue
 			}
@@ -776,14 +1288,39 @@ func ExtractCoverArt(filePath string) ([]byte, error) {
 		}
 	}
 
+	// Files converted from Ogg sometimes keep the cover as a comment.
+	if pic := commentPictureFromFLAC(f); pic != nil {
+		return pic.ImageData, nil
+	}
+
 	return nil, fmt.Errorf("no cover art found in file")
 }
 
+// EmbedLyrics writes lyrics to LYRICS and UNSYNCEDLYRICS, and tags their
+// language in LYRICSLANGUAGE when the file has none and it is detected
+// with confidence. When tags or cover are written in the same flow, prefer
+// EmbedAll so the file is only rewritten once.
 func EmbedLyrics(filePath string, lyrics string) error {
-	f, err := flac.ParseFile(filePath)
+	if isOpenerPath(filePath) {
+		return viaFileOpenerErr(filePath, true, func(localPath string) error {
+			return EmbedLyrics(localPath, lyrics)
+		})
+	}
+	if err := checkWriteAllowed(filePath); err != nil {
+		return err
+	}
+
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	f, err := parseFLACStaged(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to parse FLAC file: %w", err)
 	}
+	before := takeTagSnapshot(f)
 
 	var cmtIdx int = -1
 	var cmt *flacvorbis.MetaDataBlockVorbisComment
@@ -791,7 +1328,7 @@ func EmbedLyrics(filePath string, lyrics string) error {
 	for idx, meta := range f.Meta {
 		if meta.Type == flac.VorbisComment {
 			cmtIdx = idx
-			cmt, err = flacvorbis.ParseFromMetaDataBlock(*meta)
+			cmt, err = parseVorbisCommentBlock(*meta)
 			if err != nil {
 				return fmt.Errorf("failed to parse vorbis comment: %w", err)
 			}
@@ -803,8 +1340,14 @@ func EmbedLyrics(filePath string, lyrics string) error {
 		cmt = flacvorbis.New()
 	}
 
+	lyrics = multilineTagValue(lyrics)
 	setComment(cmt, "LYRICS", lyrics)
 	setComment(cmt, "UNSYNCEDLYRICS", lyrics)
+	if getComment(cmt, lyricsLanguageKey) == "" {
+		if language := lyricsLanguageTag(lyrics); language != "" {
+			setComment(cmt, lyricsLanguageKey, language)
+		}
+	}
 
 	cmtBlock := cmt.Marshal()
 	if cmtIdx >= 0 {
@@ -813,18 +1356,34 @@ func EmbedLyrics(filePath string, lyrics string) error {
 		f.Meta = append(f.Meta, &cmtBlock)
 	}
 
-	return f.Save(filePath)
+	return saveTaggedFLAC(f, filePath, "embed_lyrics", before)
 }
 
 func EmbedGenreLabel(filePath string, genre, label string) error {
+	if isOpenerPath(filePath) {
+		return viaFileOpenerErr(filePath, true, func(localPath string) error {
+			return EmbedGenreLabel(localPath, genre, label)
+		})
+	}
+	if err := checkWriteAllowed(filePath); err != nil {
+		return err
+	}
+
 	if genre == "" && label == "" {
 		return nil
 	}
 
-	f, err := flac.ParseFile(filePath)
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	f, err := parseFLACStaged(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to parse FLAC file: %w", err)
 	}
+	before := takeTagSnapshot(f)
 
 	var cmtIdx int = -1
 	var cmt *flacvorbis.MetaDataBlockVorbisComment
@@ -832,7 +1391,7 @@ func EmbedGenreLabel(filePath string, genre, label string) error {
 	for idx, meta := range f.Meta {
 		if meta.Type == flac.VorbisComment {
 			cmtIdx = idx
-			cmt, err = flacvorbis.ParseFromMetaDataBlock(*meta)
+			cmt, err = parseVorbisCommentBlock(*meta)
 			if err != nil {
 				return fmt.Errorf("failed to parse vorbis comment: %w", err)
 			}
@@ -858,81 +1417,112 @@ func EmbedGenreLabel(filePath string, genre, label string) error {
 		f.Meta = append(f.Meta, &cmtBlock)
 	}
 
-	return f.Save(filePath)
+	return saveTaggedFLAC(f, filePath, "embed_genre_label", before)
 }
 
+// ExtractLyrics returns the file's embedded lyrics, falling back to a
+// sidecar .lrc next to it. Embedded lyrics are served from the metadata read
+// cache; the sidecar is always re-read since writing it does not touch the
+// audio file.
 func ExtractLyrics(filePath string) (string, error) {
+	if isOpenerPath(filePath) {
+		// A content URI has no folder to hold a sidecar .lrc.
+		return viaFileOpener(filePath, false, func(localPath string) (string, error) {
+			if lyrics := extractEmbeddedLyrics(localPath); lyrics != "" {
+				return lyrics, nil
+			}
+			return "", fmt.Errorf("no lyrics found in file")
+		})
+	}
+	lyrics, _ := cachedFileRead(filePath, metadataCacheLyrics, func() (string, error) {
+		return extractEmbeddedLyrics(filePath), nil
+	})
+	if lyrics != "" {
+		return lyrics, nil
+	}
+	return extractLyricsFromSidecarLRC(filePath)
+}
+
+// HasLyrics reports whether ExtractLyrics would find non-empty lyrics.
+func HasLyrics(filePath string) bool {
+	lyrics, err := ExtractLyrics(filePath)
+	return err == nil && strings.TrimSpace(lyrics) != ""
+}
+
+// extractEmbeddedLyrics returns lyrics stored in the file's own tags, or ""
+// when there are none.
+func extractEmbeddedLyrics(filePath string) string {
 	lower := strings.ToLower(filePath)
 
 	if strings.HasSuffix(lower, ".flac") {
 		lyrics, err := extractLyricsFromFlac(filePath)
 		if err == nil && strings.TrimSpace(lyrics) != "" {
-			return lyrics, nil
+			return lyrics
 		}
-		return extractLyricsFromSidecarLRC(filePath)
+		return ""
 	}
 
 	if strings.HasSuffix(lower, ".m4a") || strings.HasSuffix(lower, ".mp4") || strings.HasSuffix(lower, ".aac") {
 		lyrics, err := extractLyricsFromM4A(filePath)
 		if err == nil && strings.TrimSpace(lyrics) != "" {
-			return lyrics, nil
+			return lyrics
 		}
-		return extractLyricsFromSidecarLRC(filePath)
+		return ""
 	}
 
 	if strings.HasSuffix(lower, ".mp3") {
 		meta, err := ReadID3Tags(filePath)
 		if err == nil && meta != nil {
 			if strings.TrimSpace(meta.Lyrics) != "" {
-				return meta.Lyrics, nil
+				return meta.Lyrics
 			}
 			if looksLikeEmbeddedLyrics(meta.Comment) {
-				return meta.Comment, nil
+				return meta.Comment
 			}
 		}
-		return extractLyricsFromSidecarLRC(filePath)
+		return ""
 	}
 
 	if strings.HasSuffix(lower, ".opus") || strings.HasSuffix(lower, ".ogg") {
 		meta, err := ReadOggVorbisComments(filePath)
 		if err == nil && meta != nil {
 			if strings.TrimSpace(meta.Lyrics) != "" {
-				return meta.Lyrics, nil
+				return meta.Lyrics
 			}
 			if looksLikeEmbeddedLyrics(meta.Comment) {
-				return meta.Comment, nil
+				return meta.Comment
 			}
 		}
-		return extractLyricsFromSidecarLRC(filePath)
+		return ""
 	}
 
 	if strings.HasSuffix(lower, ".wav") {
 		meta, err := ReadWAVTags(filePath)
 		if err == nil && meta != nil {
 			if strings.TrimSpace(meta.Lyrics) != "" {
-				return meta.Lyrics, nil
+				return meta.Lyrics
 			}
 			if looksLikeEmbeddedLyrics(meta.Comment) {
-				return meta.Comment, nil
+				return meta.Comment
 			}
 		}
-		return extractLyricsFromSidecarLRC(filePath)
+		return ""
 	}
 
 	if strings.HasSuffix(lower, ".aiff") || strings.HasSuffix(lower, ".aif") || strings.HasSuffix(lower, ".aifc") {
 		meta, err := ReadAIFFTags(filePath)
 		if err == nil && meta != nil {
 			if strings.TrimSpace(meta.Lyrics) != "" {
-				return meta.Lyrics, nil
+				return meta.Lyrics
 			}
 			if looksLikeEmbeddedLyrics(meta.Comment) {
-				return meta.Comment, nil
+				return meta.Comment
 			}
 		}
-		return extractLyricsFromSidecarLRC(filePath)
+		return ""
 	}
 
-	return extractLyricsFromSidecarLRC(filePath)
+	return ""
 }
 
 func ReadM4ATags(filePath string) (*AudioMetadata, error) {
@@ -1427,6 +2017,10 @@ func writeAtomSize(buf []byte, header atomHeader, newSize int64) error {
 }
 
 func EditM4AReplayGain(filePath string, fields map[string]string) error {
+	if err := checkWriteAllowed(filePath); err != nil {
+		return err
+	}
+	defer invalidateMetadataCache(filePath)
 	replayGainFields := collectM4AReplayGainFields(fields)
 	if len(replayGainFields) == 0 {
 		return nil
@@ -1448,6 +2042,11 @@ func EditM4AReplayGain(filePath string, fields map[string]string) error {
 		return err
 	}
 
+	// The whole file is patched in memory; a 32-bit build cannot even
+	// address a file past math.MaxInt.
+	if info.Size() > int64(math.MaxInt) {
+		return fmt.Errorf("file too large to edit in memory: %d bytes", info.Size())
+	}
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return err
@@ -1566,7 +2165,7 @@ func extractLyricsFromFlac(filePath string) (string, error) {
 			continue
 		}
 
-		cmt, err := flacvorbis.ParseFromMetaDataBlock(*meta)
+		cmt, err := parseVorbisCommentBlock(*meta)
 		if err != nil {
 			continue
 		}
@@ -1610,9 +2209,26 @@ type AudioQuality struct {
 	Duration     int    `json:"duration"`
 	Bitrate      int    `json:"bitrate,omitempty"` // kbps, estimated for compressed MP4-family streams
 	Codec        string `json:"codec,omitempty"`
+	Channels     int    `json:"channels,omitempty"`
+	// MissingSampleCount is set for a FLAC whose STREAMINFO has a total
+	// sample count of zero, so its duration is unknown. RepairStreamInfo
+	// fixes it.
+	MissingSampleCount bool `json:"missing_sample_count,omitempty"`
 }
 
 func GetAudioQuality(filePath string) (AudioQuality, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, false, GetAudioQuality)
+	}
+	return cachedFileRead(filePath, metadataCacheQuality, func() (AudioQuality, error) {
+		if err := checkIncompleteAudioFile(filePath); err != nil {
+			return AudioQuality{}, err
+		}
+		return getAudioQualityUncached(filePath)
+	})
+}
+
+func getAudioQualityUncached(filePath string) (AudioQuality, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return AudioQuality{}, fmt.Errorf("failed to open file: %w", err)
@@ -1625,43 +2241,14 @@ func GetAudioQuality(filePath string) (AudioQuality, error) {
 	}
 
 	if string(marker) == "fLaC" {
-		header := make([]byte, 4)
-		if _, err := file.Read(header); err != nil {
-			return AudioQuality{}, fmt.Errorf("failed to read header: %w", err)
-		}
-
-		blockType := header[0] & 0x7F
-		if blockType != 0 {
-			return AudioQuality{}, fmt.Errorf("first block is not STREAMINFO")
-		}
-
-		streamInfo := make([]byte, 34)
-		if _, err := file.Read(streamInfo); err != nil {
+		header := make([]byte, flacQualityHeaderSize)
+		copy(header, marker)
+		n, _ := io.ReadFull(file, header[len(marker):])
+		quality, err := parseFLACQualityHeader(header[:len(marker)+n])
+		if err != nil {
 			return AudioQuality{}, fmt.Errorf("failed to read STREAMINFO: %w", err)
 		}
-
-		sampleRate := (int(streamInfo[10]) << 12) | (int(streamInfo[11]) << 4) | (int(streamInfo[12]) >> 4)
-
-		bitsPerSample := ((int(streamInfo[12]) & 0x01) << 4) | (int(streamInfo[13]) >> 4) + 1
-
-		totalSamples := int64(streamInfo[13]&0x0F)<<32 |
-			int64(streamInfo[14])<<24 |
-			int64(streamInfo[15])<<16 |
-			int64(streamInfo[16])<<8 |
-			int64(streamInfo[17])
-
-		duration := 0
-		if sampleRate > 0 && totalSamples > 0 {
-			duration = int(totalSamples / int64(sampleRate))
-		}
-
-		return AudioQuality{
-			BitDepth:     bitsPerSample,
-			SampleRate:   sampleRate,
-			TotalSamples: totalSamples,
-			Duration:     duration,
-			Codec:        "flac",
-		}, nil
+		return quality, nil
 	}
 
 	file.Seek(0, 0)
@@ -1689,8 +2276,12 @@ func GetM4AQuality(filePath string) (AudioQuality, error) {
 	if err != nil {
 		return AudioQuality{}, fmt.Errorf("failed to stat M4A file: %w", err)
 	}
-	fileSize := info.Size()
+	return readM4AQuality(f, info.Size())
+}
 
+// readM4AQuality reads the audio format of an MP4-family stream of fileSize
+// bytes. The bitrate is estimated from fileSize.
+func readM4AQuality(f io.ReaderAt, fileSize int64) (AudioQuality, error) {
 	moovHeader, moovFound, err := findAtomInRange(f, 0, fileSize, "moov", fileSize)
 	if err != nil {
 		return AudioQuality{}, fmt.Errorf("failed to find moov atom: %w", err)
@@ -1724,6 +2315,7 @@ func GetM4AQuality(filePath string) (AudioQuality, error) {
 	//   [26:28] reserved
 	//   [28:32] samplerate (16.16 fixed-point)
 	sampleRate := int(buf[28])<<8 | int(buf[29])
+	channels := int(buf[20])<<8 | int(buf[21])
 	bitDepth := 0
 	codec := normalizeM4AAudioCodec(atomType)
 
@@ -1762,6 +2354,7 @@ func GetM4AQuality(filePath string) (AudioQuality, error) {
 		Duration:   duration,
 		Bitrate:    bitrate,
 		Codec:      codec,
+		Channels:   channels,
 	}, nil
 }
 
@@ -1791,7 +2384,7 @@ func estimateAudioBitrateKbps(fileSize int64, durationSeconds int) int {
 	return int(math.Round(float64(fileSize*8) / float64(durationSeconds) / 1000.0))
 }
 
-func readM4ADurationSeconds(f *os.File, moovHeader atomHeader, fileSize int64) int {
+func readM4ADurationSeconds(f io.ReaderAt, moovHeader atomHeader, fileSize int64) int {
 	childStart := moovHeader.offset + moovHeader.headerSize
 	childSize := moovHeader.size - moovHeader.headerSize
 	mvhdHeader, found, err := findAtomInRange(f, childStart, childSize, "mvhd", fileSize)
@@ -1804,7 +2397,7 @@ func readM4ADurationSeconds(f *os.File, moovHeader atomHeader, fileSize int64) i
 	return readM4ATrackDurationSeconds(f, moovHeader, fileSize)
 }
 
-func readMP4DurationAtomSeconds(f *os.File, header atomHeader, fileSize int64) int {
+func readMP4DurationAtomSeconds(f io.ReaderAt, header atomHeader, fileSize int64) int {
 	payloadOffset := header.offset + header.headerSize
 	versionBuf := make([]byte, 1)
 	if _, err := f.ReadAt(versionBuf, payloadOffset); err != nil {
@@ -1836,7 +2429,7 @@ func readMP4DurationAtomSeconds(f *os.File, header atomHeader, fileSize int64) i
 	return int(math.Round(float64(duration) / float64(timescale)))
 }
 
-func readM4ATrackDurationSeconds(f *os.File, moovHeader atomHeader, fileSize int64) int {
+func readM4ATrackDurationSeconds(f io.ReaderAt, moovHeader atomHeader, fileSize int64) int {
 	childStart := moovHeader.offset + moovHeader.headerSize
 	childSize := moovHeader.size - moovHeader.headerSize
 	bestDuration := 0
@@ -1852,7 +2445,7 @@ func readM4ATrackDurationSeconds(f *os.File, moovHeader atomHeader, fileSize int
 	return bestDuration
 }
 
-func walkMP4AtomsInRange(f *os.File, start, size, fileSize int64, visit func(atomHeader) bool) error {
+func walkMP4AtomsInRange(f io.ReaderAt, start, size, fileSize int64, visit func(atomHeader) bool) error {
 	if size <= 0 {
 		return nil
 	}
@@ -1883,7 +2476,7 @@ func walkMP4AtomsInRange(f *os.File, start, size, fileSize int64, visit func(ato
 	return nil
 }
 
-func readALACSpecificConfig(f *os.File, sampleOffset, fileSize int64) (int, int, bool) {
+func readALACSpecificConfig(f io.ReaderAt, sampleOffset, fileSize int64) (int, int, bool) {
 	if sampleOffset < 4 {
 		return 0, 0, false
 	}
@@ -1917,7 +2510,7 @@ func readALACSpecificConfig(f *os.File, sampleOffset, fileSize int64) (int, int,
 	return parseALACSpecificConfig(payload)
 }
 
-func readMP4FLACSpecificConfig(f *os.File, sampleOffset, fileSize int64) (int, int, int64, bool) {
+func readMP4FLACSpecificConfig(f io.ReaderAt, sampleOffset, fileSize int64) (int, int, int64, bool) {
 	if sampleOffset < 4 {
 		return 0, 0, 0, false
 	}
@@ -1976,6 +2569,20 @@ func parseMP4FLACSpecificConfig(payload []byte) (int, int, int64, bool) {
 	return 0, 0, 0, false
 }
 
+// flacDurationSeconds converts a STREAMINFO sample count to whole seconds.
+// The count is 36 bits wide, so the division is done in int64 and the result
+// clamped to fit a 32-bit int on 32-bit Android builds.
+func flacDurationSeconds(totalSamples int64, sampleRate int) int {
+	if sampleRate <= 0 || totalSamples <= 0 {
+		return 0
+	}
+	seconds := totalSamples / int64(sampleRate)
+	if seconds > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int(seconds)
+}
+
 func parseFLACStreamInfoQuality(streamInfo []byte) (int, int, int64) {
 	if len(streamInfo) < 18 {
 		return 0, 0, 0
@@ -1990,6 +2597,15 @@ func parseFLACStreamInfoQuality(streamInfo []byte) (int, int, int64) {
 	return bitsPerSample, sampleRate, totalSamples
 }
 
+// parseFLACStreamInfoChannels returns the channel count of a STREAMINFO
+// block body, or 0 when it is too short.
+func parseFLACStreamInfoChannels(streamInfo []byte) int {
+	if len(streamInfo) < 13 {
+		return 0
+	}
+	return int(streamInfo[12]>>1&0x07) + 1
+}
+
 func parseALACSpecificConfig(payload []byte) (int, int, bool) {
 	if len(payload) < 24 {
 		return 0, 0, false
@@ -2020,7 +2636,7 @@ type atomHeader struct {
 	typ        string
 }
 
-func readAtomHeaderAt(f *os.File, offset, fileSize int64) (atomHeader, error) {
+func readAtomHeaderAt(f io.ReaderAt, offset, fileSize int64) (atomHeader, error) {
 	if offset+8 > fileSize {
 		return atomHeader{}, io.ErrUnexpectedEOF
 	}
@@ -2048,7 +2664,7 @@ func readAtomHeaderAt(f *os.File, offset, fileSize int64) (atomHeader, error) {
 	return atomHeader{offset: offset, size: int64(size32), headerSize: 8, typ: typ}, nil
 }
 
-func findAtomInRange(f *os.File, start, size int64, target string, fileSize int64) (atomHeader, bool, error) {
+func findAtomInRange(f io.ReaderAt, start, size int64, target string, fileSize int64) (atomHeader, bool, error) {
 	if size <= 0 {
 		return atomHeader{}, false, nil
 	}
@@ -2082,7 +2698,7 @@ func findAtomInRange(f *os.File, start, size int64, target string, fileSize int6
 	return atomHeader{}, false, nil
 }
 
-func findAudioSampleEntry(f *os.File, start, end, fileSize int64) (int64, string, error) {
+func findAudioSampleEntry(f io.ReaderAt, start, end, fileSize int64) (int64, string, error) {
 	const chunkSize = 64 * 1024
 	patterns := [][]byte{
 		[]byte("mp4a"),
@@ -0,0 +1,218 @@
+package gobackend
+
+import (
+	"container/list"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const defaultMetadataCacheEntries = 512
+
+// Kinds of read results kept per file.
+const (
+	metadataCacheMetadata = "metadata"
+	metadataCacheQuality  = "quality"
+	metadataCacheLyrics   = "lyrics"
+)
+
+// metadataCacheKey identifies one version of a file. Size and mtime are part
+// of the key so edits made outside the backend are picked up on the next
+// read; writes made by the backend also invalidate the path explicitly.
+type metadataCacheKey struct {
+	path    string
+	size    int64
+	modTime int64
+}
+
+func newMetadataCacheKey(filePath string) (metadataCacheKey, bool) {
+	// Descriptor numbers are reused for unrelated files, so SAF paths are
+	// never cached.
+	if filePath == "" || strings.HasPrefix(filePath, "/proc/self/fd/") {
+		return metadataCacheKey{}, false
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return metadataCacheKey{}, false
+	}
+	info, err := os.Stat(absPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return metadataCacheKey{}, false
+	}
+	return metadataCacheKey{path: absPath, size: info.Size(), modTime: info.ModTime().UnixNano()}, true
+}
+
+type metadataCacheEntry struct {
+	key    metadataCacheKey
+	values map[string]interface{}
+}
+
+type MetadataCacheStats struct {
+	Entries       int   `json:"entries"`
+	Capacity      int   `json:"capacity"`
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Evictions     int64 `json:"evictions"`
+	Invalidations int64 `json:"invalidations"`
+}
+
+// metadataCache is an LRU of read results (tags, audio quality, embedded
+// lyrics) keyed by absolute path. The UI re-reads the same files every time
+// a screen rebuilds; none of these reads are worth repeating while the file
+// is unchanged.
+type metadataCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+	stats    MetadataCacheStats
+}
+
+func newMetadataCache(capacity int) *metadataCache {
+	return &metadataCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+var metadataReadCache = newMetadataCache(defaultMetadataCacheEntries)
+
+func (c *metadataCache) setCapacity(capacity int) {
+	if capacity <= 0 {
+		capacity = defaultMetadataCacheEntries
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capacity = capacity
+	c.evictLocked()
+}
+
+func (c *metadataCache) evictLocked() {
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*metadataCacheEntry).key.path)
+		c.stats.Evictions++
+	}
+}
+
+func (c *metadataCache) get(key metadataCacheKey, kind string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key.path]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	entry := elem.Value.(*metadataCacheEntry)
+	if entry.key != key {
+		// The file changed on disk since it was cached.
+		c.order.Remove(elem)
+		delete(c.entries, key.path)
+		c.stats.Misses++
+		return nil, false
+	}
+	value, ok := entry.values[kind]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	c.stats.Hits++
+	return value, true
+}
+
+func (c *metadataCache) put(key metadataCacheKey, kind string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key.path]; ok {
+		entry := elem.Value.(*metadataCacheEntry)
+		if entry.key != key {
+			entry.key = key
+			entry.values = make(map[string]interface{})
+		}
+		entry.values[kind] = value
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	entry := &metadataCacheEntry{key: key, values: map[string]interface{}{kind: value}}
+	c.entries[key.path] = c.order.PushFront(entry)
+	c.evictLocked()
+}
+
+func (c *metadataCache) invalidate(filePath string) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[absPath]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, absPath)
+		c.stats.Invalidations++
+	}
+}
+
+func (c *metadataCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+}
+
+func (c *metadataCache) snapshot() MetadataCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	stats.Capacity = c.capacity
+	return stats
+}
+
+// cachedFileRead returns the cached kind result for filePath, or runs read
+// and caches a successful result. Values are stored and returned by value so
+// callers cannot mutate what later readers see.
+func cachedFileRead[T any](filePath, kind string, read func() (T, error)) (T, error) {
+	key, ok := newMetadataCacheKey(filePath)
+	if !ok {
+		return read()
+	}
+	if value, ok := metadataReadCache.get(key, kind); ok {
+		return value.(T), nil
+	}
+
+	value, err := read()
+	if err == nil {
+		metadataReadCache.put(key, kind, value)
+	}
+	return value, err
+}
+
+// invalidateMetadataCache drops cached reads for filePath. Every backend
+// write to an audio file must call it.
+func invalidateMetadataCache(filePath string) {
+	metadataReadCache.invalidate(filePath)
+}
+
+// CacheStats returns the metadata read cache counters as JSON.
+func CacheStats() string {
+	jsonBytes, err := json.Marshal(metadataReadCache.snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(jsonBytes)
+}
+
+// ClearCache empties the metadata read cache. Counters are kept.
+func ClearCache() {
+	metadataReadCache.clear()
+}
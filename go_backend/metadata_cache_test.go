@@ -0,0 +1,163 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readCacheStats(t *testing.T) MetadataCacheStats {
+	t.Helper()
+	var stats MetadataCacheStats
+	if err := json.Unmarshal([]byte(CacheStats()), &stats); err != nil {
+		t.Fatalf("CacheStats JSON: %v", err)
+	}
+	return stats
+}
+
+func TestMetadataCacheServesRepeatedReads(t *testing.T) {
+	ClearCache()
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Cached", Artist: "Artist"})
+
+	first, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	first.Title = "mutated by caller"
+
+	before := readCacheStats(t)
+	second, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if second.Title != "Cached" {
+		t.Fatalf("cached result was mutated through a returned pointer: %q", second.Title)
+	}
+	if _, err := GetAudioQuality(path); err != nil {
+		t.Fatalf("GetAudioQuality: %v", err)
+	}
+	if _, err := GetAudioQuality(path); err != nil {
+		t.Fatalf("GetAudioQuality: %v", err)
+	}
+
+	after := readCacheStats(t)
+	if after.Hits-before.Hits != 2 {
+		t.Fatalf("expected 2 cache hits, got %d", after.Hits-before.Hits)
+	}
+	if after.Entries != 1 {
+		t.Fatalf("expected one cached file, got %d", after.Entries)
+	}
+}
+
+func TestMetadataCacheInvalidatedByEmbed(t *testing.T) {
+	ClearCache()
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Before", Artist: "Artist"})
+
+	if meta, err := ReadMetadata(path); err != nil || meta.Title != "Before" {
+		t.Fatalf("ReadMetadata = %+v, %v", meta, err)
+	}
+	if HasLyrics(path) {
+		t.Fatal("fixture should have no lyrics")
+	}
+
+	// Pin size and mtime so only the explicit invalidation can expose the
+	// new tags.
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if err := EmbedMetadata(path, Metadata{Title: "Aftr!!", Artist: "Artist"}, ""); err != nil {
+		t.Fatalf("EmbedMetadata: %v", err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	meta, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if meta.Title != "Aftr!!" {
+		t.Fatalf("expected fresh title after embed, got %q", meta.Title)
+	}
+
+	if err := EmbedLyrics(path, "[00:01.00]line"); err != nil {
+		t.Fatalf("EmbedLyrics: %v", err)
+	}
+	if !HasLyrics(path) {
+		t.Fatal("expected lyrics after EmbedLyrics")
+	}
+	if readCacheStats(t).Invalidations == 0 {
+		t.Fatal("expected invalidations to be counted")
+	}
+}
+
+func TestMetadataCacheDetectsExternalChanges(t *testing.T) {
+	ClearCache()
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Original"})
+	other := writeTestFLACWithMetadata(t, Metadata{Title: "Replaced by another app"})
+
+	if meta, err := ReadMetadata(path); err != nil || meta.Title != "Original" {
+		t.Fatalf("ReadMetadata = %+v, %v", meta, err)
+	}
+
+	data, err := os.ReadFile(other)
+	if err != nil {
+		t.Fatalf("read other fixture: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("overwrite fixture: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	meta, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if meta.Title != "Replaced by another app" {
+		t.Fatalf("expected stale entry to be dropped, got %q", meta.Title)
+	}
+}
+
+func TestMetadataCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ClearCache()
+	withBackendConfig(t, func(cfg *BackendConfig) { cfg.MetadataCacheEntries = 2 })
+
+	dir := t.TempDir()
+	source := writeTestFLACWithMetadata(t, Metadata{Title: "Track"})
+	data, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var paths []string
+	for _, name := range []string{"a.flac", "b.flac", "c.flac"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+
+	for _, path := range paths {
+		if _, err := ReadMetadata(path); err != nil {
+			t.Fatalf("ReadMetadata: %v", err)
+		}
+	}
+	stats := readCacheStats(t)
+	if stats.Entries != 2 || stats.Capacity != 2 {
+		t.Fatalf("unexpected cache size: %+v", stats)
+	}
+
+	before := stats.Hits
+	if _, err := ReadMetadata(paths[0]); err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if readCacheStats(t).Hits != before {
+		t.Fatal("oldest entry should have been evicted")
+	}
+}
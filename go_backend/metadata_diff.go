@@ -0,0 +1,368 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DiffStatusMatch     = "match"
+	DiffStatusMismatch  = "mismatch"
+	DiffStatusMissing   = "missing"
+	DiffStatusUnchecked = "unchecked"
+	DiffStatusUnknown   = "unknown"
+)
+
+// defaultCoverSizeTolerance allows a re-encoded or re-fetched cover to differ
+// from the expected byte size by this fraction and still count as a match.
+const defaultCoverSizeTolerance = 0.1
+
+type MetadataFieldDiff struct {
+	Field         string `json:"field"`
+	FileValue     string `json:"file_value"`
+	ExpectedValue string `json:"expected_value"`
+	Status        string `json:"status"`
+}
+
+type MetadataDiffOptions struct {
+	IgnoreCase       bool `json:"ignore_case"`
+	IgnoreWhitespace bool `json:"ignore_whitespace"`
+	// ExpectCover and ExpectedCoverSize check the embedded picture; a zero
+	// size only checks presence. CoverSizeTolerance is a fraction of the
+	// expected size (default 0.1).
+	ExpectCover        bool    `json:"expect_cover"`
+	ExpectedCoverSize  int64   `json:"expected_cover_size"`
+	CoverSizeTolerance float64 `json:"cover_size_tolerance"`
+	// DurationToleranceMs applies to tracks with an expected duration_ms
+	// (default 2000).
+	DurationToleranceMs int64 `json:"duration_tolerance_ms"`
+}
+
+func (o MetadataDiffOptions) normalize(value string) string {
+	if o.IgnoreWhitespace {
+		value = strings.Join(strings.Fields(value), " ")
+	}
+	if o.IgnoreCase {
+		value = strings.ToLower(value)
+	}
+	return value
+}
+
+func formatDiffNumber(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// metadataDiffFields lists every comparable text field in a stable order.
+func metadataDiffFields(m *Metadata) [][2]string {
+	return [][2]string{
+		{"title", m.Title},
+		{"artist", m.Artist},
+		{"album", m.Album},
+		{"album_artist", m.AlbumArtist},
+		{"date", m.Date},
+		{"track_number", formatDiffNumber(m.TrackNumber)},
+		{"total_tracks", formatDiffNumber(m.TotalTracks)},
+		{"disc_number", formatDiffNumber(m.DiscNumber)},
+		{"total_discs", formatDiffNumber(m.TotalDiscs)},
+		{"isrc", m.ISRC},
+		{"description", m.Description},
+		{"genre", m.Genre},
+		{"label", m.Label},
+		{"copyright", m.Copyright},
+		{"composer", m.Composer},
+		{"comment", m.Comment},
+		{"replaygain_track_gain", m.ReplayGainTrackGain},
+		{"replaygain_track_peak", m.ReplayGainTrackPeak},
+		{"replaygain_album_gain", m.ReplayGainAlbumGain},
+		{"replaygain_album_peak", m.ReplayGainAlbumPeak},
+	}
+}
+
+func diffStatus(fileValue, expectedValue string, opts MetadataDiffOptions) string {
+	switch {
+	case expectedValue == "":
+		return DiffStatusUnchecked
+	case fileValue == "":
+		return DiffStatusMissing
+	case opts.normalize(fileValue) == opts.normalize(expectedValue):
+		return DiffStatusMatch
+	}
+	return DiffStatusMismatch
+}
+
+func diffPresence(field string, present, expected bool) MetadataFieldDiff {
+	diff := MetadataFieldDiff{
+		Field:         field,
+		FileValue:     strconv.FormatBool(present),
+		ExpectedValue: strconv.FormatBool(expected),
+		Status:        DiffStatusUnchecked,
+	}
+	if expected {
+		diff.Status = DiffStatusMatch
+		if !present {
+			diff.Status = DiffStatusMissing
+		}
+	}
+	return diff
+}
+
+// DiffMetadata compares the tags in a FLAC file with expected. Fields left
+// empty in expected are reported as "unchecked". Lyrics are compared by
+// presence only, since providers reformat them freely; cover art is checked
+// according to opts.
+func DiffMetadata(filePath string, expected Metadata, opts MetadataDiffOptions) ([]MetadataFieldDiff, error) {
+	actual, err := ReadMetadata(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	fileFields := metadataDiffFields(actual)
+	expectedFields := metadataDiffFields(&expected)
+	diffs := make([]MetadataFieldDiff, 0, len(fileFields)+3)
+	for i, field := range fileFields {
+		diffs = append(diffs, MetadataFieldDiff{
+			Field:         field[0],
+			FileValue:     field[1],
+			ExpectedValue: expectedFields[i][1],
+			Status:        diffStatus(field[1], expectedFields[i][1], opts),
+		})
+	}
+
+	diffs = append(diffs, diffPresence("lyrics", strings.TrimSpace(actual.Lyrics) != "",
+		strings.TrimSpace(expected.Lyrics) != ""))
+
+	cover, _ := ExtractCoverArt(filePath)
+	expectCover := opts.ExpectCover || opts.ExpectedCoverSize > 0
+	diffs = append(diffs, diffPresence("cover", len(cover) > 0, expectCover))
+
+	if opts.ExpectedCoverSize > 0 {
+		tolerance := opts.CoverSizeTolerance
+		if tolerance <= 0 {
+			tolerance = defaultCoverSizeTolerance
+		}
+		sizeDiff := MetadataFieldDiff{
+			Field:         "cover_size",
+			FileValue:     strconv.Itoa(len(cover)),
+			ExpectedValue: strconv.FormatInt(opts.ExpectedCoverSize, 10),
+			Status:        DiffStatusMatch,
+		}
+		switch {
+		case len(cover) == 0:
+			sizeDiff.FileValue = ""
+			sizeDiff.Status = DiffStatusMissing
+		case math.Abs(float64(int64(len(cover))-opts.ExpectedCoverSize)) > float64(opts.ExpectedCoverSize)*tolerance:
+			sizeDiff.Status = DiffStatusMismatch
+		}
+		diffs = append(diffs, sizeDiff)
+	}
+
+	return diffs, nil
+}
+
+// ExpectedTrack is one entry of the JSON array taken by DiffMetadataDir.
+// FileName is relative to the directory; when empty the file is matched by
+// disc and track number instead.
+type ExpectedTrack struct {
+	FileName          string `json:"file_name"`
+	Title             string `json:"title"`
+	Artist            string `json:"artist"`
+	Album             string `json:"album"`
+	AlbumArtist       string `json:"album_artist"`
+	Date              string `json:"date"`
+	TrackNumber       int    `json:"track_number"`
+	TotalTracks       int    `json:"total_tracks"`
+	DiscNumber        int    `json:"disc_number"`
+	TotalDiscs        int    `json:"total_discs"`
+	ISRC              string `json:"isrc"`
+	Genre             string `json:"genre"`
+	Label             string `json:"label"`
+	Copyright         string `json:"copyright"`
+	Composer          string `json:"composer"`
+	HasLyrics         bool   `json:"has_lyrics"`
+	HasCover          bool   `json:"has_cover"`
+	ExpectedCoverSize int64  `json:"cover_size"`
+	DurationMs        int64  `json:"duration_ms"`
+}
+
+func (e ExpectedTrack) metadata() Metadata {
+	m := Metadata{
+		Title:       e.Title,
+		Artist:      e.Artist,
+		Album:       e.Album,
+		AlbumArtist: e.AlbumArtist,
+		Date:        e.Date,
+		TrackNumber: e.TrackNumber,
+		TotalTracks: e.TotalTracks,
+		DiscNumber:  e.DiscNumber,
+		TotalDiscs:  e.TotalDiscs,
+		ISRC:        e.ISRC,
+		Genre:       e.Genre,
+		Label:       e.Label,
+		Copyright:   e.Copyright,
+		Composer:    e.Composer,
+	}
+	if e.HasLyrics {
+		// Lyrics are compared by presence, so any non-empty marker will do.
+		m.Lyrics = "*"
+	}
+	return m
+}
+
+type TrackDiffResult struct {
+	FilePath   string              `json:"file_path,omitempty"`
+	Expected   string              `json:"expected"`
+	Found      bool                `json:"found"`
+	Mismatches int                 `json:"mismatches"`
+	Diffs      []MetadataFieldDiff `json:"diffs,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+type DirectoryDiffReport struct {
+	Tracks []TrackDiffResult `json:"tracks"`
+	// ExtraFiles are FLAC files in the directory no expected track matched.
+	ExtraFiles []string `json:"extra_files,omitempty"`
+	AllMatch   bool     `json:"all_match"`
+}
+
+func countDiffMismatches(diffs []MetadataFieldDiff) int {
+	n := 0
+	for _, d := range diffs {
+		switch d.Status {
+		case DiffStatusMismatch, DiffStatusMissing, DiffStatusUnknown:
+			n++
+		}
+	}
+	return n
+}
+
+func discTrackKey(disc, track int) string {
+	return fmt.Sprintf("%d/%d", max(disc, 1), track)
+}
+
+// diffExpectedTrack is DiffMetadata plus the checks only ExpectedTrack can
+// describe, such as duration.
+func diffExpectedTrack(filePath string, track ExpectedTrack, opts MetadataDiffOptions) ([]MetadataFieldDiff, error) {
+	diffs, err := DiffMetadata(filePath, track.metadata(), opts)
+	if err != nil {
+		return nil, err
+	}
+	if track.DurationMs > 0 {
+		diffs = append(diffs, durationFieldDiff(filePath, track.DurationMs, opts.DurationToleranceMs))
+	}
+	return diffs, nil
+}
+
+var metadataDiffProgress batchProgress
+
+// GetMetadataDiffProgress reports the running DiffMetadataDir, one item per
+// expected track.
+func GetMetadataDiffProgress() string {
+	return metadataDiffProgress.json()
+}
+
+// diffDirTrack finds the file in dirPath for track, by file name or by
+// disc and track number, and fills result with its diffs.
+func diffDirTrack(result *TrackDiffResult, dirPath string, track ExpectedTrack, opts MetadataDiffOptions, byTrack map[string]string) {
+	var path string
+	if track.FileName != "" {
+		path = filepath.Join(dirPath, track.FileName)
+		if _, err := os.Stat(path); err != nil {
+			path = ""
+		}
+	} else if track.TrackNumber > 0 {
+		path = byTrack[discTrackKey(track.DiscNumber, track.TrackNumber)]
+	}
+
+	if path == "" {
+		result.Error = "file not found"
+		return
+	}
+	result.FilePath = path
+	result.Found = true
+
+	opts.ExpectCover = track.HasCover
+	opts.ExpectedCoverSize = track.ExpectedCoverSize
+	diffs, err := diffExpectedTrack(path, track, opts)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	result.Diffs = diffs
+	result.Mismatches = countDiffMismatches(diffs)
+}
+
+// DiffMetadataDir runs DiffMetadata for each track in expectedJSON against
+// the FLAC files in dirPath and returns a DirectoryDiffReport as JSON.
+// optionsJSON is a MetadataDiffOptions object and may be empty.
+func DiffMetadataDir(dirPath, expectedJSON, optionsJSON string) (string, error) {
+	var expected []ExpectedTrack
+	if err := json.Unmarshal([]byte(expectedJSON), &expected); err != nil {
+		return "", fmt.Errorf("invalid expected tracks JSON: %w", err)
+	}
+	var opts MetadataDiffOptions
+	if strings.TrimSpace(optionsJSON) != "" {
+		if err := json.Unmarshal([]byte(optionsJSON), &opts); err != nil {
+			return "", fmt.Errorf("invalid diff options JSON: %w", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dirPath, "*.flac"))
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	byTrack := make(map[string]string, len(files))
+	for _, file := range files {
+		meta, err := ReadMetadata(file)
+		if err != nil || meta.TrackNumber == 0 {
+			continue
+		}
+		key := discTrackKey(meta.DiscNumber, meta.TrackNumber)
+		if _, exists := byTrack[key]; !exists {
+			byTrack[key] = file
+		}
+	}
+
+	report := DirectoryDiffReport{Tracks: make([]TrackDiffResult, 0, len(expected)), AllMatch: true}
+	used := make(map[string]bool, len(files))
+	metadataDiffProgress.start(make([]int64, len(expected)), 1)
+	defer metadataDiffProgress.finish()
+	for _, track := range expected {
+		result := TrackDiffResult{Expected: track.FileName}
+		if result.Expected == "" {
+			result.Expected = fmt.Sprintf("%s %s", discTrackKey(track.DiscNumber, track.TrackNumber), track.Title)
+		}
+		done := metadataDiffProgress.item(result.Expected, 0)
+		diffDirTrack(&result, dirPath, track, opts, byTrack)
+		done()
+		if result.Found {
+			used[result.FilePath] = true
+		}
+		if result.Error != "" || result.Mismatches > 0 {
+			report.AllMatch = false
+		}
+		report.Tracks = append(report.Tracks, result)
+	}
+
+	for _, file := range files {
+		if !used[file] {
+			report.ExtraFiles = append(report.ExtraFiles, filepath.Base(file))
+		}
+	}
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
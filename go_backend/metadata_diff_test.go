@@ -0,0 +1,95 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func findFieldDiff(t *testing.T, diffs []MetadataFieldDiff, field string) MetadataFieldDiff {
+	t.Helper()
+	for _, d := range diffs {
+		if d.Field == field {
+			return d
+		}
+	}
+	t.Fatalf("field %q missing from diff", field)
+	return MetadataFieldDiff{}
+}
+
+func TestDiffMetadataStatusesAndOptions(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Hello  World", Artist: "Artist", TrackNumber: 3})
+
+	expected := Metadata{Title: "hello world", Artist: "Artist", Album: "Album", TrackNumber: 4, Lyrics: "x"}
+	diffs, err := DiffMetadata(path, expected, MetadataDiffOptions{})
+	if err != nil {
+		t.Fatalf("DiffMetadata: %v", err)
+	}
+	want := map[string]string{
+		"title":        DiffStatusMismatch,
+		"artist":       DiffStatusMatch,
+		"album":        DiffStatusMissing,
+		"track_number": DiffStatusMismatch,
+		"genre":        DiffStatusUnchecked,
+		"lyrics":       DiffStatusMissing,
+		"cover":        DiffStatusUnchecked,
+	}
+	for field, status := range want {
+		if got := findFieldDiff(t, diffs, field).Status; got != status {
+			t.Fatalf("%s: status %q, want %q", field, got, status)
+		}
+	}
+
+	diffs, _ = DiffMetadata(path, expected, MetadataDiffOptions{IgnoreCase: true, IgnoreWhitespace: true})
+	if got := findFieldDiff(t, diffs, "title").Status; got != DiffStatusMatch {
+		t.Fatalf("normalized title status %q", got)
+	}
+}
+
+func TestDiffMetadataCoverSizeTolerance(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	cover, _ := buildSelfTestCover()
+	if err := EmbedMetadataWithCoverData(path, Metadata{}, cover); err != nil {
+		t.Fatalf("embed cover: %v", err)
+	}
+
+	size := int64(len(cover))
+	diffs, _ := DiffMetadata(path, Metadata{}, MetadataDiffOptions{ExpectedCoverSize: size + size/20})
+	if got := findFieldDiff(t, diffs, "cover_size").Status; got != DiffStatusMatch {
+		t.Fatalf("within tolerance: %q", got)
+	}
+	diffs, _ = DiffMetadata(path, Metadata{}, MetadataDiffOptions{ExpectedCoverSize: size * 2})
+	if got := findFieldDiff(t, diffs, "cover_size").Status; got != DiffStatusMismatch {
+		t.Fatalf("outside tolerance: %q", got)
+	}
+}
+
+func TestDiffMetadataDirMatchesByNameAndTrackNumber(t *testing.T) {
+	dir := t.TempDir()
+	for name, meta := range map[string]Metadata{
+		"01.flac":    {Title: "One", TrackNumber: 1},
+		"02.flac":    {Title: "Two", TrackNumber: 2},
+		"extra.flac": {Title: "Bonus"},
+	} {
+		src := writeTestFLACWithMetadata(t, meta)
+		data, _ := os.ReadFile(src)
+		os.WriteFile(filepath.Join(dir, name), data, 0644)
+	}
+
+	expectedJSON := `[
+		{"file_name": "01.flac", "title": "One"},
+		{"track_number": 2, "title": "Deux"},
+		{"track_number": 9, "title": "Missing"}
+	]`
+	reportJSON, err := DiffMetadataDir(dir, expectedJSON, "")
+	report := mustDecodeJSON[DirectoryDiffReport](t, reportJSON, err)
+	if report.AllMatch || len(report.Tracks) != 3 {
+		t.Fatalf("unexpected report: %s", reportJSON)
+	}
+	if report.Tracks[0].Mismatches != 0 || report.Tracks[1].Mismatches != 1 || report.Tracks[2].Found {
+		t.Fatalf("unexpected track results: %s", reportJSON)
+	}
+	if len(report.ExtraFiles) != 1 || report.ExtraFiles[0] != "extra.flac" {
+		t.Fatalf("extra files = %v", report.ExtraFiles)
+	}
+}
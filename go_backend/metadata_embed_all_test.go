@@ -0,0 +1,244 @@
+package gobackend
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-flac/flacvorbis/v2"
+	"github.com/go-flac/go-flac/v2"
+)
+
+func TestEmbedAllWritesEverythingInOneRewrite(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Old", Composer: "Kept Composer"})
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+
+	writesBefore := flacRewriteCount.Load() + flacInPlaceWriteCount.Load()
+	err = EmbedAll(path, Metadata{Title: "New", Artist: "Artist", TrackNumber: 3},
+		Lyrics{Text: "[00:01.00]first line"}, cover, EmbedOptions{})
+	if err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	if got := flacRewriteCount.Load() + flacInPlaceWriteCount.Load() - writesBefore; got != 1 {
+		t.Fatalf("expected exactly one write, got %d", got)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temp file left behind: %v", err)
+	}
+
+	meta, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if meta.Title != "New" || meta.Artist != "Artist" || meta.TrackNumber != 3 || meta.Composer != "Kept Composer" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+	if lyrics, err := ExtractLyrics(path); err != nil || lyrics != "[00:01.00]first line" {
+		t.Fatalf("ExtractLyrics = %q, %v", lyrics, err)
+	}
+	embedded, err := ExtractCoverArt(path)
+	if err != nil || !bytes.Equal(embedded, cover) {
+		t.Fatalf("expected embedded cover to match input, err=%v", err)
+	}
+}
+
+func TestEmbedAllReadsCoverPathAndKeepsExistingLyrics(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Track", Lyrics: "existing lyrics"})
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	coverPath := filepath.Join(t.TempDir(), "cover.png")
+	if err := os.WriteFile(coverPath, cover, 0644); err != nil {
+		t.Fatalf("write cover: %v", err)
+	}
+
+	if err := EmbedAll(path, Metadata{Album: "Album"}, Lyrics{}, nil, EmbedOptions{CoverPath: coverPath}); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+
+	if lyrics, err := ExtractLyrics(path); err != nil || lyrics != "existing lyrics" {
+		t.Fatalf("empty lyrics should keep existing ones, got %q, %v", lyrics, err)
+	}
+	if embedded, err := ExtractCoverArt(path); err != nil || !bytes.Equal(embedded, cover) {
+		t.Fatalf("expected cover read from CoverPath, err=%v", err)
+	}
+}
+
+func writeTaggedPolicyFixture(t *testing.T) string {
+	t.Helper()
+	path := writeTestFLACWithMetadata(t, Metadata{
+		Title:               "Old Title",
+		ReplayGainTrackGain: "-6.50 dB",
+		ReplayGainAlbumGain: "-7.20 dB",
+	})
+	if err := SetTags(path, []TagPair{
+		{Key: "MUSICBRAINZ_TRACKID", Value: "track-id"},
+		{Key: "MUSICBRAINZ_ALBUMID", Value: "album-id"},
+		{Key: "X_UNKNOWN_VENDOR_KEY", Value: "vendor"},
+		{Key: "ITUNSMPB", Value: " 00000000 00000840 000001CA 0000000000000000"},
+	}, false); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+	return path
+}
+
+func TestEmbedPreservesUnknownTagsByDefault(t *testing.T) {
+	path := writeTaggedPolicyFixture(t)
+	if err := EmbedAll(path, Metadata{Title: "New Title"}, Lyrics{}, nil, EmbedOptions{}); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	if err := EmbedMetadata(path, Metadata{Artist: "Artist"}, ""); err != nil {
+		t.Fatalf("EmbedMetadata: %v", err)
+	}
+
+	tags, err := GetTags(path, nil)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	for _, key := range []string{"REPLAYGAIN_TRACK_GAIN", "REPLAYGAIN_ALBUM_GAIN", "MUSICBRAINZ_TRACKID", "MUSICBRAINZ_ALBUMID", "X_UNKNOWN_VENDOR_KEY", "ITUNSMPB"} {
+		if len(tags[key]) != 1 {
+			t.Fatalf("%s should survive a preserving embed: %v", key, tags)
+		}
+	}
+	if tags["TITLE"][0] != "New Title" || tags["ARTIST"][0] != "Artist" {
+		t.Fatalf("embedded fields not written: %v", tags)
+	}
+}
+
+func TestEmbedReplacePolicyDropsExistingTags(t *testing.T) {
+	path := writeTaggedPolicyFixture(t)
+	opts := EmbedOptions{
+		TagPolicy: TagPolicyReplace,
+		KeepKeys:  []string{"musicbrainz_trackid"},
+		ExtraTags: []TagPair{{Key: "MOOD", Value: "calm"}},
+	}
+	if err := EmbedAll(path, Metadata{Title: "New Title"}, Lyrics{}, nil, opts); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+
+	tags, err := GetTags(path, nil)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	for _, key := range []string{"REPLAYGAIN_TRACK_GAIN", "REPLAYGAIN_ALBUM_GAIN", "MUSICBRAINZ_ALBUMID", "X_UNKNOWN_VENDOR_KEY"} {
+		if _, ok := tags[key]; ok {
+			t.Fatalf("%s should be dropped under replace: %v", key, tags)
+		}
+	}
+	// Gapless info describes the audio and survives replace.
+	if len(tags) != 4 || tags["TITLE"][0] != "New Title" || tags["MUSICBRAINZ_TRACKID"][0] != "track-id" || tags["MOOD"][0] != "calm" || len(tags["ITUNSMPB"]) != 1 {
+		t.Fatalf("unexpected tags after replace: %v", tags)
+	}
+}
+
+func TestEmbedAllRejectsUnknownTagPolicy(t *testing.T) {
+	path := writeTaggedPolicyFixture(t)
+	if err := EmbedAll(path, Metadata{}, Lyrics{}, nil, EmbedOptions{TagPolicy: "merge"}); err == nil {
+		t.Fatal("expected unknown tag policy error")
+	}
+	if err := EmbedAll(path, Metadata{}, Lyrics{}, nil, EmbedOptions{ExtraTags: []TagPair{{Key: "BAD=KEY"}}}); err == nil {
+		t.Fatal("expected invalid extra tag key error")
+	}
+}
+
+func vorbisCommentBlock(t *testing.T, path string) []byte {
+	t.Helper()
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	defer f.Close()
+	for _, meta := range f.Meta {
+		if meta.Type == flac.VorbisComment {
+			return meta.Data
+		}
+	}
+	t.Fatalf("no comment block in %s", path)
+	return nil
+}
+
+func TestEmbedAllSortTagsIsReproducible(t *testing.T) {
+	orders := [][]string{
+		{"mood=calm", "GENRE=Rock", "ARTIST=Old", "COMPOSER=Writer", "TITLE=Old"},
+		{"TITLE=Old", "COMPOSER=Writer", "ARTIST=Old", "GENRE=Rock", "MOOD=calm"},
+	}
+	metadata := Metadata{Title: "Song", Artist: "Artist", Album: "Album", TrackNumber: 2}
+	extra := []TagPair{{Key: "ARTISTS", Value: "Artist"}, {Key: "ARTISTS", Value: "Guest"}}
+
+	embed := func(sort bool) [][]byte {
+		var blocks [][]byte
+		for _, order := range orders {
+			path := writeTestFLACWithMetadata(t, Metadata{})
+			err := editVorbisCommentList(path, "test", func([]string) ([]string, bool) {
+				return order, true
+			})
+			if err != nil {
+				t.Fatalf("write source comments: %v", err)
+			}
+			if err := EmbedAll(path, metadata, Lyrics{}, nil, EmbedOptions{ExtraTags: extra, SortTags: sort}); err != nil {
+				t.Fatalf("EmbedAll: %v", err)
+			}
+			blocks = append(blocks, vorbisCommentBlock(t, path))
+		}
+		return blocks
+	}
+
+	if blocks := embed(false); bytes.Equal(blocks[0], blocks[1]) {
+		t.Fatal("fixtures should differ without SortTags")
+	}
+	blocks := embed(true)
+	if !bytes.Equal(blocks[0], blocks[1]) {
+		t.Fatalf("comment blocks differ with SortTags:\n%q\n%q", blocks[0], blocks[1])
+	}
+	cmt, err := flacvorbis.ParseFromMetaDataBlock(flac.MetaDataBlock{Type: flac.VorbisComment, Data: blocks[0]})
+	if err != nil {
+		t.Fatalf("parse comments: %v", err)
+	}
+	want := []string{"TITLE=Song", "ARTIST=Artist", "ARTISTS=Artist", "ARTISTS=Guest", "ALBUM=Album", "TRACKNUMBER=2", "GENRE=Rock", "COMPOSER=Writer", "MOOD=calm"}
+	if strings.Join(cmt.Comments, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("comments = %q", cmt.Comments)
+	}
+}
+
+func TestEmbedAllJSON(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Old", Genre: "Jazz"})
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+
+	raw, err := EmbedAllJSON(path,
+		`{"title":"New","albumartist":"Band","tracknumber":4}`,
+		`{"text":"[00:01.00]hello","language":"en"}`,
+		cover,
+		`{"tag_policy":"preserve","extra_tags":[{"key":"MOOD","value":"Calm"}]}`)
+	result := mustDecodeJSON[EmbedResult](t, raw, err)
+	if result.CoverAction == "" || result.TagsAdded == 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	meta, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if meta.Title != "New" || meta.AlbumArtist != "Band" || meta.TrackNumber != 4 || meta.Genre != "Jazz" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+	tags, err := GetTags(path, []string{"MOOD", "LANGUAGE"})
+	if err != nil || len(tags["MOOD"]) != 1 || tags["MOOD"][0] != "Calm" {
+		t.Fatalf("GetTags = %v, %v", tags, err)
+	}
+	if lyrics, err := ExtractLyrics(path); err != nil || lyrics != "[00:01.00]hello" {
+		t.Fatalf("ExtractLyrics = %q, %v", lyrics, err)
+	}
+
+	if _, err := EmbedAllJSON(path, `{"title":`, "", nil, ""); err == nil {
+		t.Fatal("EmbedAllJSON accepted malformed metadata JSON")
+	}
+}
@@ -0,0 +1,198 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const defaultFootprintTopFiles = 10
+
+// MetadataFootprint is the storage used by each kind of FLAC metadata block,
+// headers included, next to the audio payload. MetadataBytes counts
+// everything before the first audio frame, the "fLaC" marker included.
+type MetadataFootprint struct {
+	Path          string `json:"path"`
+	FileSize      int64  `json:"file_size"`
+	StreamInfo    int64  `json:"stream_info"`
+	VorbisComment int64  `json:"vorbis_comment"`
+	Pictures      int64  `json:"pictures"`
+	PictureCount  int    `json:"picture_count"`
+	// NonImagePictures counts picture blocks whose MIME type is not an
+	// image, such as PDF booklets; NonImagePictureBytes is their size.
+	NonImagePictures     int   `json:"non_image_pictures"`
+	NonImagePictureBytes int64 `json:"non_image_picture_bytes"`
+	Padding              int64 `json:"padding"`
+	SeekTable            int64 `json:"seek_table"`
+	Application          int64 `json:"application"`
+	CueSheet             int64 `json:"cue_sheet"`
+	Other                int64 `json:"other"`
+	MetadataBytes        int64 `json:"metadata_bytes"`
+	AudioBytes           int64 `json:"audio_bytes"`
+	// RepadRecommended is set when the file has no padding to absorb tag
+	// edits, or more than PaddingMax of it; the next tag save then rewrites
+	// the file with PaddingTarget.
+	RepadRecommended bool `json:"repad_recommended"`
+}
+
+// LibraryFootprint sums MetadataFootprint over the FLAC files of a folder.
+// TopFiles lists the files with the most metadata, largest first.
+type LibraryFootprint struct {
+	Root          string `json:"root"`
+	Files         int    `json:"files"`
+	Skipped       int    `json:"skipped"`
+	TotalBytes    int64  `json:"total_bytes"`
+	AudioBytes    int64  `json:"audio_bytes"`
+	MetadataBytes int64  `json:"metadata_bytes"`
+	PictureBytes  int64  `json:"picture_bytes"`
+	// NonImagePictureBytes sums the files' NonImagePictureBytes.
+	NonImagePictureBytes int64 `json:"non_image_picture_bytes"`
+	PaddingBytes         int64 `json:"padding_bytes"`
+	// RepadCandidates counts files with RepadRecommended set.
+	RepadCandidates int                 `json:"repad_candidates"`
+	TopFiles        []MetadataFootprint `json:"top_files"`
+}
+
+var (
+	lastLibraryFootprintMu sync.Mutex
+	lastLibraryFootprint   *LibraryFootprint
+)
+
+// GetMetadataFootprint walks the metadata block headers of a FLAC file and
+// reports the bytes used per block type. Block bodies are not read, except
+// the MIME type at the start of each picture.
+func GetMetadataFootprint(filePath string) (*MetadataFootprint, error) {
+	if err := checkIncompleteAudioFile(filePath); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	layout, err := scanFLACMetadataBlocks(f, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	footprint := &MetadataFootprint{
+		Path:          filePath,
+		FileSize:      info.Size(),
+		MetadataBytes: layout.AudioOffset,
+		AudioBytes:    info.Size() - layout.AudioOffset,
+	}
+	var paddingBody int64
+	hasPadding := false
+	for _, block := range layout.Blocks {
+		size := 4 + int64(block.Length)
+		switch block.Type {
+		case 0:
+			footprint.StreamInfo += size
+		case 1:
+			footprint.Padding += size
+			paddingBody += int64(block.Length)
+			hasPadding = true
+		case 2:
+			footprint.Application += size
+		case 3:
+			footprint.SeekTable += size
+		case 4:
+			footprint.VorbisComment += size
+		case 5:
+			footprint.CueSheet += size
+		case 6:
+			footprint.Pictures += size
+			footprint.PictureCount++
+			if _, mimeType, err := readPictureBlockHeader(f, block.Offset+4, block.Length); err == nil && mimeType != "" && !isImageMIME(mimeType) {
+				footprint.NonImagePictures++
+				footprint.NonImagePictureBytes += size
+			}
+		default:
+			footprint.Other += size
+		}
+	}
+	footprint.RepadRecommended = !hasPadding || paddingBody > int64(GetBackendConfig().PaddingMax)
+	return footprint, nil
+}
+
+// GetLibraryFootprint aggregates GetMetadataFootprint over every FLAC file
+// under rootPath and lists the topN files carrying the most metadata
+// (10 when topN <= 0). Other formats are counted as skipped. The result is
+// also kept for GetStats.
+func GetLibraryFootprint(rootPath string, topN int) (string, error) {
+	if strings.TrimSpace(rootPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(rootPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+	if topN <= 0 {
+		topN = defaultFootprintTopFiles
+	}
+
+	files, err := collectLibraryAudioFiles(rootPath, nil)
+	if err != nil {
+		return "", err
+	}
+
+	library := &LibraryFootprint{Root: rootPath, TopFiles: []MetadataFootprint{}}
+	var footprints []MetadataFootprint
+	for _, file := range files {
+		if !strings.EqualFold(filepath.Ext(file.path), ".flac") {
+			library.Skipped++
+			continue
+		}
+		footprint, err := GetMetadataFootprint(file.path)
+		if err != nil {
+			GoLog("[Footprint] Skipping %s: %v\n", file.path, err)
+			library.Skipped++
+			continue
+		}
+		library.Files++
+		library.TotalBytes += footprint.FileSize
+		library.AudioBytes += footprint.AudioBytes
+		library.MetadataBytes += footprint.MetadataBytes
+		library.PictureBytes += footprint.Pictures
+		library.NonImagePictureBytes += footprint.NonImagePictureBytes
+		library.PaddingBytes += footprint.Padding
+		if footprint.RepadRecommended {
+			library.RepadCandidates++
+		}
+		footprints = append(footprints, *footprint)
+	}
+
+	sort.SliceStable(footprints, func(i, j int) bool {
+		return footprints[i].MetadataBytes > footprints[j].MetadataBytes
+	})
+	if len(footprints) > topN {
+		footprints = footprints[:topN]
+	}
+	library.TopFiles = append(library.TopFiles, footprints...)
+
+	lastLibraryFootprintMu.Lock()
+	lastLibraryFootprint = library
+	lastLibraryFootprintMu.Unlock()
+
+	jsonBytes, err := json.Marshal(library)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+func lastLibraryFootprintSnapshot() *LibraryFootprint {
+	lastLibraryFootprintMu.Lock()
+	defer lastLibraryFootprintMu.Unlock()
+	return lastLibraryFootprint
+}
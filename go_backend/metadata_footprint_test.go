@@ -0,0 +1,71 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-flac/flacpicture/v2"
+)
+
+func TestGetMetadataFootprintAccountsForEveryByte(t *testing.T) {
+	cover := make([]byte, 5000)
+	path := writeMultiPictureFLAC(t,
+		&flacpicture.MetadataBlockPicture{PictureType: flacpicture.PictureTypeFrontCover, MIME: "image/jpeg", ImageData: cover},
+		&flacpicture.MetadataBlockPicture{PictureType: flacpicture.PictureTypeBackCover, MIME: "image/jpeg", ImageData: cover},
+	)
+
+	footprint, err := GetMetadataFootprint(path)
+	if err != nil {
+		t.Fatalf("GetMetadataFootprint: %v", err)
+	}
+	if footprint.PictureCount != 2 || footprint.Pictures < 10000 {
+		t.Fatalf("unexpected picture accounting: %+v", footprint)
+	}
+	if footprint.StreamInfo != 38 || footprint.VorbisComment == 0 {
+		t.Fatalf("unexpected block accounting: %+v", footprint)
+	}
+	blocks := footprint.StreamInfo + footprint.VorbisComment + footprint.Pictures + footprint.Padding +
+		footprint.SeekTable + footprint.Application + footprint.CueSheet + footprint.Other
+	if 4+blocks != footprint.MetadataBytes || footprint.MetadataBytes+footprint.AudioBytes != footprint.FileSize {
+		t.Fatalf("block sizes do not add up: %+v", footprint)
+	}
+}
+
+func TestGetLibraryFootprintRanksTopFiles(t *testing.T) {
+	root := t.TempDir()
+	small := writeTestFLACWithMetadata(t, Metadata{Title: "Small"})
+	big := writeMultiPictureFLAC(t, &flacpicture.MetadataBlockPicture{PictureType: flacpicture.PictureTypeFrontCover, MIME: "image/jpeg", ImageData: make([]byte, 20000)})
+	for name, src := range map[string]string{"small.flac": small, "big.flac": big} {
+		if err := os.Rename(src, filepath.Join(root, name)); err != nil {
+			t.Fatalf("move fixture: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "other.mp3"), []byte("ID3"), 0644); err != nil {
+		t.Fatalf("write mp3: %v", err)
+	}
+
+	raw, err := GetLibraryFootprint(root, 1)
+	library := mustDecodeJSON[LibraryFootprint](t, raw, err)
+	if library.Files != 2 || library.Skipped != 1 || library.PictureBytes < 20000 {
+		t.Fatalf("unexpected totals: %+v", library)
+	}
+	if len(library.TopFiles) != 1 || filepath.Base(library.TopFiles[0].Path) != "big.flac" {
+		t.Fatalf("expected big.flac as the top offender: %+v", library.TopFiles)
+	}
+	if !strings.Contains(GetStats(), `"library_footprint"`) {
+		t.Fatal("expected GetStats to include the library footprint")
+	}
+}
+
+func TestGetMetadataFootprintFlagsNonImagePictures(t *testing.T) {
+	path := writeMultiPictureFLAC(t, bookletPictures(t)...)
+	footprint, err := GetMetadataFootprint(path)
+	if err != nil {
+		t.Fatalf("GetMetadataFootprint: %v", err)
+	}
+	if footprint.PictureCount != 3 || footprint.NonImagePictures != 1 || footprint.NonImagePictureBytes <= 8192 {
+		t.Fatalf("unexpected footprint: %+v", footprint)
+	}
+}
@@ -0,0 +1,234 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BackendConfig MultiValueTags modes for the GENRE and MOOD comments.
+const (
+	// MultiValueSplit writes every genre or mood as its own comment, per
+	// the Vorbis comment multi-value convention.
+	MultiValueSplit = "split"
+	// MultiValueJoin writes them as one comment joined with "; ", for
+	// players that only read the first comment of a key.
+	MultiValueJoin = "join"
+)
+
+// multiValueTagKeys are the comments MultiValueTags applies to.
+var multiValueTagKeys = []string{"GENRE", "MOOD"}
+
+// defaultMultiValueSeparators split a combined value such as
+// "Pop; Dance; Electro" when BackendConfig MultiValueSeparators is nil.
+var defaultMultiValueSeparators = []string{";", "/"}
+
+func validateMultiValueConfig(mode string, separators []string) error {
+	switch mode {
+	case "", MultiValueSplit, MultiValueJoin:
+	default:
+		return fmt.Errorf("unknown multi-value tag mode: %s", mode)
+	}
+	for _, separator := range separators {
+		if strings.TrimSpace(separator) == "" {
+			return fmt.Errorf("multi-value separator must not be blank")
+		}
+	}
+	return nil
+}
+
+func multiValueSeparators() []string {
+	if separators := GetBackendConfig().MultiValueSeparators; separators != nil {
+		return separators
+	}
+	return defaultMultiValueSeparators
+}
+
+// splitMultiValues splits each value on separators, trims the parts and
+// drops empty ones and those already seen, ignoring case.
+func splitMultiValues(values, separators []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, value := range values {
+		parts := []string{value}
+		for _, separator := range separators {
+			var next []string
+			for _, part := range parts {
+				next = append(next, strings.Split(part, separator)...)
+			}
+			parts = next
+		}
+		for _, part := range parts {
+			part = strings.TrimSpace(part)
+			if key := strings.ToLower(part); part != "" && !seen[key] {
+				seen[key] = true
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+// formatMultiValues returns the values of a multi-value key as mode writes
+// them: split into one value each, or joined into a single one.
+func formatMultiValues(values []string, mode string, separators []string) []string {
+	split := splitMultiValues(values, separators)
+	if mode == MultiValueJoin && len(split) > 1 {
+		return []string{strings.Join(split, genreSeparator)}
+	}
+	return split
+}
+
+// applyMultiValueMode rewrites GENRE and MOOD per mode; an empty mode
+// leaves them as written.
+func (m *vorbisCommentMap) applyMultiValueMode(mode string, separators []string) bool {
+	if mode == "" {
+		return false
+	}
+	changed := false
+	for _, key := range multiValueTagKeys {
+		values := m.getValues(key)
+		if len(values) == 0 {
+			continue
+		}
+		if formatted := formatMultiValues(values, mode, separators); !slices.Equal(values, formatted) {
+			m.setValues(key, formatted)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// syncMultiValueTags applies BackendConfig MultiValueTags on the embed
+// paths.
+func (m *vorbisCommentMap) syncMultiValueTags() {
+	m.applyMultiValueMode(GetBackendConfig().MultiValueTags, multiValueSeparators())
+}
+
+type MultiValueFileChange struct {
+	Path   string        `json:"path"`
+	Fields []FieldChange `json:"fields,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+type MultiValueFixReport struct {
+	Root               string                 `json:"root"`
+	Mode               string                 `json:"mode"`
+	DryRun             bool                   `json:"dry_run"`
+	Checked            int                    `json:"checked"`
+	Changed            int                    `json:"changed"`
+	Failed             int                    `json:"failed"`
+	QuarantinedSkipped int                    `json:"quarantined_skipped"`
+	Skipped            int                    `json:"skipped"`
+	IO                 WriteStats             `json:"io"`
+	Files              []MultiValueFileChange `json:"files"`
+}
+
+// multiValueFieldChanges lists the GENRE and MOOD values of comments that
+// mode rewrites.
+func multiValueFieldChanges(comments *vorbisCommentMap, mode string, separators []string) []FieldChange {
+	var fields []FieldChange
+	for _, key := range multiValueTagKeys {
+		values := comments.getValues(key)
+		if len(values) == 0 {
+			continue
+		}
+		if formatted := formatMultiValues(values, mode, separators); !slices.Equal(values, formatted) {
+			fields = append(fields, FieldChange{Field: key, Old: values, New: formatted})
+		}
+	}
+	return fields
+}
+
+func fixFileMultiValueTags(filePath, mode string, separators []string, dryRun bool) ([]FieldChange, flacSaveStats, error) {
+	if dryRun {
+		raw, err := readVorbisCommentList(filePath)
+		if err != nil {
+			return nil, flacSaveStats{}, err
+		}
+		return multiValueFieldChanges(newVorbisCommentMap(raw), mode, separators), flacSaveStats{}, nil
+	}
+
+	if err := checkWriteAllowed(filePath); err != nil {
+		return nil, flacSaveStats{}, err
+	}
+	var fields []FieldChange
+	stats, err := editVorbisCommentsStats(filePath, "fix_multi_value_tags", func(comments *vorbisCommentMap) bool {
+		fields = multiValueFieldChanges(comments, mode, separators)
+		return comments.applyMultiValueMode(mode, separators)
+	})
+	return fields, stats, err
+}
+
+// FixMultiValueTags rewrites the GENRE and MOOD comments of every FLAC file
+// under rootPath: mode MultiValueSplit splits combined values such as
+// "Pop; Dance" on BackendConfig MultiValueSeparators into one comment each,
+// MultiValueJoin joins several comments into one. Both drop duplicates
+// ignoring case. Other formats are counted as skipped.
+func FixMultiValueTags(rootPath, mode string, dryRun bool) (string, error) {
+	if mode != MultiValueSplit && mode != MultiValueJoin {
+		return "", fmt.Errorf("unknown multi-value tag mode: %s", mode)
+	}
+	if strings.TrimSpace(rootPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(rootPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+	if !dryRun {
+		if err := checkWriteAllowed(rootPath); err != nil {
+			return "", err
+		}
+	}
+
+	files, quarantined, err := collectWritableLibraryFiles(rootPath, nil)
+	if err != nil {
+		return "", err
+	}
+	report := MultiValueFixReport{Root: rootPath, Mode: mode, DryRun: dryRun, Files: []MultiValueFileChange{}}
+	report.QuarantinedSkipped = quarantined
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file.path), ".flac") {
+			paths = append(paths, file.path)
+		} else {
+			report.Skipped++
+		}
+	}
+	sort.Strings(paths)
+
+	started := time.Now()
+	separators := multiValueSeparators()
+	for _, path := range paths {
+		report.Checked++
+		fields, stats, err := fixFileMultiValueTags(path, mode, separators, dryRun)
+		if err != nil {
+			report.Failed++
+			report.Files = append(report.Files, MultiValueFileChange{Path: path, Error: err.Error()})
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		report.Changed++
+		report.IO.add(stats)
+		report.Files = append(report.Files, MultiValueFileChange{Path: path, Fields: fields})
+	}
+	report.IO.DurationMs = time.Since(started).Milliseconds()
+
+	GoLog("[Genres] %s multi-value tags in %d of %d files under %s (dry run: %v)\n",
+		mode, report.Changed, report.Checked, rootPath, dryRun)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,128 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSplitMultiValues(t *testing.T) {
+	cases := []struct {
+		in, want []string
+	}{
+		{[]string{"Pop; Dance; Electro"}, []string{"Pop", "Dance", "Electro"}},
+		{[]string{"Pop/Rock", "pop", "ROCK; Jazz"}, []string{"Pop", "Rock", "Jazz"}},
+		{[]string{" ; "}, nil},
+	}
+	for _, c := range cases {
+		if got := splitMultiValues(c.in, defaultMultiValueSeparators); !slices.Equal(got, c.want) {
+			t.Fatalf("splitMultiValues(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+	if got := formatMultiValues([]string{"Pop", "Dance", "pop"}, MultiValueJoin, defaultMultiValueSeparators); !slices.Equal(got, []string{"Pop; Dance"}) {
+		t.Fatalf("join = %q", got)
+	}
+	if got := splitMultiValues([]string{"Pop | Dance;Soul"}, []string{"|"}); !slices.Equal(got, []string{"Pop", "Dance;Soul"}) {
+		t.Fatalf("custom separators = %q", got)
+	}
+}
+
+func TestEmbedSplitsGenresAndReadMetadataListsThem(t *testing.T) {
+	withBackendConfig(t, func(cfg *BackendConfig) { cfg.MultiValueTags = MultiValueSplit })
+
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "T", Genre: "Pop; Dance; pop"})
+	comments, err := readVorbisCommentList(path)
+	if err != nil {
+		t.Fatalf("readVorbisCommentList: %v", err)
+	}
+	if got := newVorbisCommentMap(comments).getValues("GENRE"); !slices.Equal(got, []string{"Pop", "Dance"}) {
+		t.Fatalf("GENRE comments = %q", got)
+	}
+	md, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if md.Genre != "Pop" || !slices.Equal(md.Genres, []string{"Pop", "Dance"}) {
+		t.Fatalf("genre %q genres %q", md.Genre, md.Genres)
+	}
+
+	single := writeTestFLACWithMetadata(t, Metadata{Title: "T", Genre: "Jazz"})
+	if md, err := ReadMetadata(single); err != nil || md.Genres != nil {
+		t.Fatalf("single genre: %+v %v", md, err)
+	}
+}
+
+func TestMultiValueConfigValidation(t *testing.T) {
+	cfg := GetBackendConfig()
+	cfg.MultiValueTags = "explode"
+	if err := SetBackendConfig(cfg); err == nil {
+		t.Fatal("expected unknown mode to be rejected")
+	}
+	cfg.MultiValueTags = MultiValueJoin
+	cfg.MultiValueSeparators = []string{";", " "}
+	if err := SetBackendConfig(cfg); err == nil {
+		t.Fatal("expected blank separator to be rejected")
+	}
+}
+
+func TestFixMultiValueTags(t *testing.T) {
+	root := t.TempDir()
+	src := writeMalformedCommentFLAC(t, "TITLE=A", "GENRE=Pop; Dance", "MOOD=Happy/Calm", "MOOD=happy")
+	combined := filepath.Join(root, "a.flac")
+	if err := os.Rename(src, combined); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	plain := writeConsistencyFixture(t, root, "b.flac", Metadata{Title: "B", Genre: "Jazz"})
+	before := mustReadFile(t, combined)
+
+	decode := func(mode string, dryRun bool) MultiValueFixReport {
+		t.Helper()
+		out, err := FixMultiValueTags(root, mode, dryRun)
+		report := mustDecodeJSON[MultiValueFixReport](t, out, err)
+		return report
+	}
+	values := func(path, key string) []string {
+		t.Helper()
+		comments, err := readVorbisCommentList(path)
+		if err != nil {
+			t.Fatalf("readVorbisCommentList: %v", err)
+		}
+		return newVorbisCommentMap(comments).getValues(key)
+	}
+
+	report := decode(MultiValueSplit, true)
+	if report.Checked != 2 || report.Changed != 1 || len(report.Files) != 1 || len(report.Files[0].Fields) != 2 {
+		t.Fatalf("unexpected dry run: %+v", report)
+	}
+	if string(mustReadFile(t, combined)) != string(before) {
+		t.Fatal("dry run modified a file")
+	}
+
+	if report := decode(MultiValueSplit, false); report.Changed != 1 || report.Failed != 0 {
+		t.Fatalf("unexpected split: %+v", report)
+	}
+	if got := values(combined, "GENRE"); !slices.Equal(got, []string{"Pop", "Dance"}) {
+		t.Fatalf("split GENRE = %q", got)
+	}
+	if got := values(combined, "MOOD"); !slices.Equal(got, []string{"Happy", "Calm"}) {
+		t.Fatalf("split MOOD = %q", got)
+	}
+	if got := values(plain, "GENRE"); !slices.Equal(got, []string{"Jazz"}) {
+		t.Fatalf("single genre changed: %q", got)
+	}
+
+	if report := decode(MultiValueJoin, false); report.Changed != 1 {
+		t.Fatalf("unexpected join: %+v", report)
+	}
+	if got := values(combined, "GENRE"); !slices.Equal(got, []string{"Pop; Dance"}) {
+		t.Fatalf("joined GENRE = %q", got)
+	}
+	if report := decode(MultiValueJoin, false); report.Changed != 0 {
+		t.Fatalf("second join changed files: %+v", report)
+	}
+
+	if _, err := FixMultiValueTags(root, "", true); err == nil {
+		t.Fatal("expected an empty mode to be rejected")
+	}
+}
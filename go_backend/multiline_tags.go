@@ -0,0 +1,51 @@
+package gobackend
+
+import (
+	"strings"
+	"unicode"
+)
+
+// normalizeMultilineTag tidies a lyrics or description value before it is
+// written: a leading BOM is dropped, CRLF and CR become LF, trailing
+// whitespace is trimmed from every line and runs of three or more blank
+// lines collapse to one. Applying it twice gives the same result.
+func normalizeMultilineTag(value string) string {
+	if value == "" {
+		return value
+	}
+	value = strings.TrimPrefix(value, "\ufeff")
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+	value = strings.ReplaceAll(value, "\r", "\n")
+
+	lines := strings.Split(value, "\n")
+	result := make([]string, 0, len(lines))
+	blankRun := 0
+	flushBlanks := func() {
+		if blankRun >= 3 {
+			blankRun = 1
+		}
+		for ; blankRun > 0; blankRun-- {
+			result = append(result, "")
+		}
+	}
+	for _, line := range lines {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if line == "" {
+			blankRun++
+			continue
+		}
+		flushBlanks()
+		result = append(result, line)
+	}
+	flushBlanks()
+	return strings.Join(result, "\n")
+}
+
+// multilineTagValue applies normalizeMultilineTag unless the backend config
+// asks for values to be written verbatim.
+func multilineTagValue(value string) string {
+	if GetBackendConfig().PreserveMultilineWhitespace {
+		return value
+	}
+	return normalizeMultilineTag(value)
+}
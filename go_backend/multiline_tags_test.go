@@ -0,0 +1,42 @@
+package gobackend
+
+import "testing"
+
+func TestNormalizeMultilineTag(t *testing.T) {
+	input := "\ufeffLine one  \r\nLine two\t\r\rLine three\n\n\n\n\nLast line \n"
+	want := "Line one\nLine two\n\nLine three\n\nLast line\n"
+	got := normalizeMultilineTag(input)
+	if got != want {
+		t.Fatalf("normalizeMultilineTag = %q, want %q", got, want)
+	}
+	if again := normalizeMultilineTag(got); again != got {
+		t.Fatalf("normalization is not idempotent: %q -> %q", got, again)
+	}
+}
+
+func TestEmbedLyricsNormalizesOnWriteOnly(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	if err := EmbedLyrics(path, "first  \r\nsecond\r\n\r\n\r\n\r\nthird"); err != nil {
+		t.Fatalf("EmbedLyrics: %v", err)
+	}
+	lyrics, err := ExtractLyrics(path)
+	if err != nil {
+		t.Fatalf("ExtractLyrics: %v", err)
+	}
+	if lyrics != "first\nsecond\n\nthird" {
+		t.Fatalf("unexpected stored lyrics: %q", lyrics)
+	}
+
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	if err := Configure(`{"preserve_multiline_whitespace":true}`); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	raw := "kept  \r\nas is"
+	if err := EmbedLyrics(path, raw); err != nil {
+		t.Fatalf("EmbedLyrics: %v", err)
+	}
+	if lyrics, _ := ExtractLyrics(path); lyrics != raw {
+		t.Fatalf("ExtractLyrics must return lyrics as stored, got %q", lyrics)
+	}
+}
@@ -0,0 +1,417 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-flac/flacvorbis/v2"
+	"github.com/go-flac/go-flac/v2"
+)
+
+var (
+	// ErrMusicBrainzUnavailable wraps transport and server failures, so the
+	// UI can offer a retry instead of asking the user to pick a match.
+	ErrMusicBrainzUnavailable = errors.New("musicbrainz unavailable")
+	ErrMusicBrainzNoMatch     = errors.New("no musicbrainz match")
+	// ErrMusicBrainzAmbiguous means several candidates disagree on a field
+	// that was asked to be filled.
+	ErrMusicBrainzAmbiguous = errors.New("ambiguous musicbrainz match")
+)
+
+// musicBrainzLookupBase is a var so tests can point it at a local server.
+var musicBrainzLookupBase = musicBrainzAPIBase
+
+type MusicBrainzCandidate struct {
+	RecordingID      string   `json:"recording_id"`
+	Title            string   `json:"title"`
+	Artist           string   `json:"artist"`
+	ArtistIDs        []string `json:"artist_ids,omitempty"`
+	Release          string   `json:"release,omitempty"`
+	ReleaseID        string   `json:"release_id,omitempty"`
+	ReleaseArtist    string   `json:"release_artist,omitempty"`
+	ReleaseArtistIDs []string `json:"release_artist_ids,omitempty"`
+	Date             string   `json:"date,omitempty"`
+}
+
+type musicBrainzCreditArtist struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type musicBrainzLookupCredit struct {
+	Name       string                  `json:"name"`
+	JoinPhrase string                  `json:"joinphrase"`
+	Artist     musicBrainzCreditArtist `json:"artist"`
+}
+
+type musicBrainzISRCResponse struct {
+	Recordings []struct {
+		ID           string                    `json:"id"`
+		Title        string                    `json:"title"`
+		ArtistCredit []musicBrainzLookupCredit `json:"artist-credit"`
+		Releases     []struct {
+			ID           string                    `json:"id"`
+			Title        string                    `json:"title"`
+			Date         string                    `json:"date"`
+			ArtistCredit []musicBrainzLookupCredit `json:"artist-credit"`
+		} `json:"releases"`
+	} `json:"recordings"`
+}
+
+func musicBrainzCreditNames(credits []musicBrainzLookupCredit) (string, []string) {
+	plain := make([]musicBrainzArtistCredit, 0, len(credits))
+	ids := make([]string, 0, len(credits))
+	for _, credit := range credits {
+		plain = append(plain, musicBrainzArtistCredit{Name: credit.Name, JoinPhrase: credit.JoinPhrase})
+		if credit.Artist.ID != "" {
+			ids = append(ids, credit.Artist.ID)
+		}
+	}
+	return formatMusicBrainzArtistCredit(plain), ids
+}
+
+// lookupByISRC returns one candidate per recording/release pair. Recordings
+// without releases still produce a single candidate.
+func lookupByISRC(isrc string) ([]MusicBrainzCandidate, error) {
+	normalizedISRC := strings.ToUpper(strings.TrimSpace(isrc))
+	if normalizedISRC == "" {
+		return nil, fmt.Errorf("no ISRC provided")
+	}
+
+	reqURL := fmt.Sprintf(
+		"%s/isrc/%s?fmt=json&inc=%s",
+		musicBrainzLookupBase,
+		url.PathEscape(normalizedISRC),
+		url.QueryEscape("artist-credits releases"),
+	)
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	ua := configuredUserAgent()
+	if ua == "" {
+		ua = appUserAgent()
+	}
+	req.Header.Set("User-Agent", ua)
+	req.Header.Set("Accept", "application/json")
+
+	// MusicBrainz's one-request-per-second policy is enforced by the
+	// musicbrainz.org entry in defaultHostRateLimits.
+	client := NewMetadataHTTPClient(15 * time.Second)
+	resp, err := doFetchWithRetry(client, req, "musicbrainz lookup")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMusicBrainzUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w for ISRC: %s", ErrMusicBrainzNoMatch, normalizedISRC)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: MusicBrainz API returned status: %d", ErrMusicBrainzUnavailable, resp.StatusCode)
+	}
+
+	var payload musicBrainzISRCResponse
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("%w: invalid response: %v", ErrMusicBrainzUnavailable, err)
+	}
+
+	var candidates []MusicBrainzCandidate
+	for _, rec := range payload.Recordings {
+		artist, artistIDs := musicBrainzCreditNames(rec.ArtistCredit)
+		base := MusicBrainzCandidate{
+			RecordingID: rec.ID,
+			Title:       strings.TrimSpace(rec.Title),
+			Artist:      artist,
+			ArtistIDs:   artistIDs,
+		}
+		if len(rec.Releases) == 0 {
+			candidates = append(candidates, base)
+			continue
+		}
+		for _, rel := range rec.Releases {
+			c := base
+			c.Release = strings.TrimSpace(rel.Title)
+			c.ReleaseID = rel.ID
+			c.Date = strings.TrimSpace(rel.Date)
+			c.ReleaseArtist, c.ReleaseArtistIDs = musicBrainzCreditNames(rel.ArtistCredit)
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w for ISRC: %s", ErrMusicBrainzNoMatch, normalizedISRC)
+	}
+	return candidates, nil
+}
+
+// LookupByISRC queries MusicBrainz for recordings carrying isrc and returns
+// the candidates as a JSON array.
+func LookupByISRC(isrc string) (string, error) {
+	candidates, err := lookupByISRC(isrc)
+	if err != nil {
+		return "", err
+	}
+
+	jsonBytes, err := json.Marshal(candidates)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+type enrichField struct {
+	vorbisKey string
+	value     func(MusicBrainzCandidate) string
+}
+
+// enrichFields maps the field names accepted by EnrichFile to the Vorbis
+// comment they fill and the candidate value they take.
+var enrichFields = map[string]enrichField{
+	"title":  {"TITLE", func(c MusicBrainzCandidate) string { return c.Title }},
+	"artist": {"ARTIST", func(c MusicBrainzCandidate) string { return c.Artist }},
+	"album":  {"ALBUM", func(c MusicBrainzCandidate) string { return c.Release }},
+	"album_artist": {"ALBUMARTIST", func(c MusicBrainzCandidate) string {
+		return c.ReleaseArtist
+	}},
+	"date": {"DATE", func(c MusicBrainzCandidate) string { return c.Date }},
+	"musicbrainz_trackid": {"MUSICBRAINZ_TRACKID", func(c MusicBrainzCandidate) string {
+		return c.RecordingID
+	}},
+	"musicbrainz_albumid": {"MUSICBRAINZ_ALBUMID", func(c MusicBrainzCandidate) string {
+		return c.ReleaseID
+	}},
+	"musicbrainz_artistid": {"MUSICBRAINZ_ARTISTID", func(c MusicBrainzCandidate) string {
+		return strings.Join(c.ArtistIDs, "; ")
+	}},
+	"musicbrainz_albumartistid": {"MUSICBRAINZ_ALBUMARTISTID", func(c MusicBrainzCandidate) string {
+		return strings.Join(c.ReleaseArtistIDs, "; ")
+	}},
+}
+
+type EnrichResult struct {
+	Status     string                 `json:"status"`
+	Applied    map[string]string      `json:"applied,omitempty"`
+	Skipped    []string               `json:"skipped,omitempty"`
+	Ambiguous  []string               `json:"ambiguous,omitempty"`
+	Candidates []MusicBrainzCandidate `json:"candidates,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// narrowEnrichCandidates keeps candidates whose release matches the album
+// already in the file, when there is one and anything matches.
+func narrowEnrichCandidates(candidates []MusicBrainzCandidate, album string) []MusicBrainzCandidate {
+	album = strings.TrimSpace(album)
+	if album == "" {
+		return candidates
+	}
+	var matched []MusicBrainzCandidate
+	for _, c := range candidates {
+		if strings.EqualFold(c.Release, album) {
+			matched = append(matched, c)
+		}
+	}
+	if len(matched) == 0 {
+		return candidates
+	}
+	return matched
+}
+
+// resolveEnrichValue returns the single value the candidates agree on. Dates
+// resolve to the earliest release rather than counting as a conflict.
+func resolveEnrichValue(field string, spec enrichField, candidates []MusicBrainzCandidate) (string, bool) {
+	seen := make(map[string]struct{})
+	var values []string
+	for _, c := range candidates {
+		v := strings.TrimSpace(spec.value(c))
+		if v == "" {
+			continue
+		}
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		values = append(values, v)
+	}
+
+	switch {
+	case len(values) == 0:
+		return "", true
+	case len(values) == 1:
+		return values[0], true
+	case field == "date":
+		sort.Strings(values)
+		return values[0], true
+	}
+	return "", false
+}
+
+// findEnrichComment returns the Vorbis comment block of f and its index,
+// or an empty comment and -1 when the file has none.
+func findEnrichComment(f *flac.File) (int, *flacvorbis.MetaDataBlockVorbisComment, error) {
+	for idx, meta := range f.Meta {
+		if meta.Type == flac.VorbisComment {
+			cmt, err := parseVorbisCommentBlock(*meta)
+			if err != nil {
+				return -1, nil, fmt.Errorf("failed to parse vorbis comment: %w", err)
+			}
+			return idx, cmt, nil
+		}
+	}
+	return -1, flacvorbis.New(), nil
+}
+
+func enrichFile(filePath string, applyFields []string) (*EnrichResult, error) {
+	for _, field := range applyFields {
+		if _, ok := enrichFields[field]; !ok {
+			return nil, fmt.Errorf("unsupported enrich field: %s", field)
+		}
+	}
+
+	// The lookup is paced to one request a second, so the file is only
+	// read here and parsed again for the write once there is one.
+	f, err := parseFLACStaged(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+	_, cmt, err := findEnrichComment(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	isrc := getComment(cmt, "ISRC")
+	if strings.TrimSpace(isrc) == "" {
+		return nil, fmt.Errorf("file has no ISRC: %s", filePath)
+	}
+
+	result := &EnrichResult{Applied: map[string]string{}}
+	var pending []string
+	for _, field := range applyFields {
+		if getComment(cmt, enrichFields[field].vorbisKey) != "" {
+			result.Skipped = append(result.Skipped, field)
+			continue
+		}
+		pending = append(pending, field)
+	}
+	if len(pending) == 0 {
+		result.Status = "unchanged"
+		return result, nil
+	}
+
+	candidates, err := lookupByISRC(isrc)
+	if err != nil {
+		return result, err
+	}
+	candidates = narrowEnrichCandidates(candidates, getComment(cmt, "ALBUM"))
+	result.Candidates = candidates
+
+	updates := make(map[string]string, len(pending))
+	for _, field := range pending {
+		value, ok := resolveEnrichValue(field, enrichFields[field], candidates)
+		if !ok {
+			result.Ambiguous = append(result.Ambiguous, field)
+			continue
+		}
+		if value != "" {
+			updates[field] = value
+		}
+	}
+	if len(result.Ambiguous) > 0 {
+		// Write nothing so the user can choose a candidate first.
+		return result, fmt.Errorf("%w: %s", ErrMusicBrainzAmbiguous, strings.Join(result.Ambiguous, ", "))
+	}
+	if len(updates) == 0 {
+		result.Status = "unchanged"
+		return result, nil
+	}
+
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return result, err
+	}
+	defer release()
+
+	f, err = parseFLACStaged(filePath)
+	if err != nil {
+		return result, fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+	before := takeTagSnapshot(f)
+	cmtIdx, cmt, err := findEnrichComment(f)
+	if err != nil {
+		f.Close()
+		return result, err
+	}
+	for field, value := range updates {
+		// The file may have been tagged during the lookup; existing values
+		// still win.
+		if getComment(cmt, enrichFields[field].vorbisKey) != "" {
+			result.Skipped = append(result.Skipped, field)
+			continue
+		}
+		setComment(cmt, enrichFields[field].vorbisKey, value)
+		result.Applied[field] = value
+	}
+	if len(result.Applied) == 0 {
+		f.Close()
+		result.Status = "unchanged"
+		return result, nil
+	}
+
+	cmtBlock := cmt.Marshal()
+	if cmtIdx >= 0 {
+		f.Meta[cmtIdx] = &cmtBlock
+	} else {
+		f.Meta = append(f.Meta, &cmtBlock)
+	}
+	if err := saveTaggedFLAC(f, filePath, "musicbrainz_enrich", before); err != nil {
+		return result, fmt.Errorf("failed to save FLAC file: %w", err)
+	}
+
+	result.Status = "applied"
+	GoLog("[MusicBrainz] Enriched %s: %d field(s)\n", filePath, len(result.Applied))
+	return result, nil
+}
+
+// EnrichFile fills the listed fields that are empty in a FLAC file from the
+// MusicBrainz recording matching its ISRC. Existing values are never
+// overwritten. The returned JSON always carries a status: "applied",
+// "unchanged", "ambiguous" (with candidates), "no_match" or "network_error".
+// Only local failures such as an unreadable file are returned as errors.
+func EnrichFile(filePath string, applyFields []string) (string, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, true, func(localPath string) (string, error) {
+			return EnrichFile(localPath, applyFields)
+		})
+	}
+	if err := checkWriteAllowed(filePath); err != nil {
+		return "", err
+	}
+
+	result, err := enrichFile(filePath, applyFields)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrMusicBrainzAmbiguous):
+		result.Status = "ambiguous"
+	case errors.Is(err, ErrMusicBrainzNoMatch):
+		result.Status = "no_match"
+	case errors.Is(err, ErrMusicBrainzUnavailable):
+		result.Status = "network_error"
+	default:
+		return "", err
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,167 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testMusicBrainzISRCResponse = `{
+  "recordings": [{
+    "id": "rec-1",
+    "title": "Song",
+    "artist-credit": [{"name": "Artist", "joinphrase": " feat. ", "artist": {"id": "art-1"}}, {"name": "Guest", "artist": {"id": "art-2"}}],
+    "releases": [
+      {"id": "rel-1", "title": "Album", "date": "2001-05-01", "artist-credit": [{"name": "Artist", "artist": {"id": "art-1"}}]},
+      {"id": "rel-2", "title": "Best Of", "date": "2010", "artist-credit": [{"name": "Various Artists", "artist": {"id": "va"}}]}
+    ]
+  }]
+}`
+
+func useTestMusicBrainzServer(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	useFastFetchRetry(t)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	origBase := musicBrainzLookupBase
+	musicBrainzLookupBase = server.URL
+	t.Cleanup(func() { musicBrainzLookupBase = origBase })
+}
+
+func writeTestFLACWithMetadata(t *testing.T, metadata Metadata) string {
+	t.Helper()
+	data, err := buildSelfTestFLAC()
+	if err != nil {
+		t.Fatalf("buildSelfTestFLAC: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "track.flac")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	if err := EmbedMetadata(path, metadata, ""); err != nil {
+		t.Fatalf("EmbedMetadata: %v", err)
+	}
+	return path
+}
+
+func TestLookupByISRCFlattensReleases(t *testing.T) {
+	useTestMusicBrainzServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/isrc/USABC0100001" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testMusicBrainzISRCResponse))
+	})
+
+	candidates, err := lookupByISRC("usabc0100001")
+	if err != nil {
+		t.Fatalf("lookupByISRC: %v", err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("expected 2 candidates, got %d", len(candidates))
+	}
+	if candidates[0].Artist != "Artist feat. Guest" || len(candidates[0].ArtistIDs) != 2 {
+		t.Fatalf("unexpected artist credit: %+v", candidates[0])
+	}
+
+	if _, err := LookupByISRC("MISSING"); err == nil {
+		t.Fatal("expected no-match error")
+	}
+}
+
+func TestEnrichFileFillsOnlyEmptyFields(t *testing.T) {
+	useTestMusicBrainzServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testMusicBrainzISRCResponse))
+	})
+
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Local Title", Album: "Album", ISRC: "USABC0100001"})
+
+	resultJSON, err := EnrichFile(path, []string{"title", "date", "album_artist", "musicbrainz_albumid"})
+	result := mustDecodeJSON[EnrichResult](t, resultJSON, err)
+	if result.Status != "applied" || len(result.Skipped) != 1 || result.Skipped[0] != "title" {
+		t.Fatalf("unexpected result: %s", resultJSON)
+	}
+
+	meta, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if meta.Title != "Local Title" || meta.Date != "2001-05-01" || meta.AlbumArtist != "Artist" {
+		t.Fatalf("unexpected metadata after enrich: %+v", meta)
+	}
+}
+
+func TestEnrichFileHoldsNoSlotDuringLookup(t *testing.T) {
+	activeDuringLookup := -1
+	useTestMusicBrainzServer(t, func(w http.ResponseWriter, r *http.Request) {
+		activeDuringLookup = heavyOperationLimiter.stats().Active
+		w.Write([]byte(testMusicBrainzISRCResponse))
+	})
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", Album: "Album", ISRC: "USABC0100001"})
+
+	resultJSON, err := EnrichFile(path, []string{"date"})
+	if result := mustDecodeJSON[EnrichResult](t, resultJSON, err); result.Status != "applied" {
+		t.Fatalf("unexpected result: %s", resultJSON)
+	}
+	if activeDuringLookup != 0 {
+		t.Fatalf("%d heavy operation slot(s) held during the lookup", activeDuringLookup)
+	}
+}
+
+func TestEnrichFileThroughFileOpener(t *testing.T) {
+	useTestMusicBrainzServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testMusicBrainzISRCResponse))
+	})
+	opener := useDirFileOpener(t)
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", Album: "Album", ISRC: "USABC0100001"})
+	uri := serveThroughOpener(t, opener, path, "song.flac")
+
+	resultJSON, err := EnrichFile(uri, []string{"date"})
+	if result := mustDecodeJSON[EnrichResult](t, resultJSON, err); result.Status != "applied" || opener.writes != 1 {
+		t.Fatalf("unexpected result: %s after %d writes", resultJSON, opener.writes)
+	}
+	if meta, err := ReadMetadata(uri); err != nil || meta.Date != "2001-05-01" {
+		t.Fatalf("metadata after enrich: %+v %v", meta, err)
+	}
+}
+
+func TestEnrichFileReportsAmbiguityAndNetworkErrorsDistinctly(t *testing.T) {
+	useTestMusicBrainzServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testMusicBrainzISRCResponse))
+	})
+
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", ISRC: "USABC0100001"})
+	resultJSON, err := EnrichFile(path, []string{"album"})
+	if err != nil {
+		t.Fatalf("EnrichFile: %v", err)
+	}
+	var result EnrichResult
+	_ = json.Unmarshal([]byte(resultJSON), &result)
+	if result.Status != "ambiguous" || len(result.Candidates) != 2 {
+		t.Fatalf("expected ambiguous with candidates, got %s", resultJSON)
+	}
+	if meta, _ := ReadMetadata(path); meta.Album != "" {
+		t.Fatalf("ambiguous enrich must not write, album = %q", meta.Album)
+	}
+
+	useTestMusicBrainzServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	resultJSON, err = EnrichFile(path, []string{"album"})
+	if err != nil {
+		t.Fatalf("EnrichFile: %v", err)
+	}
+	result = EnrichResult{}
+	_ = json.Unmarshal([]byte(resultJSON), &result)
+	if result.Status != "network_error" {
+		t.Fatalf("expected network_error, got %s", resultJSON)
+	}
+
+	if _, err := EnrichFile(path, []string{"bogus"}); err == nil {
+		t.Fatal("expected error for unsupported field")
+	}
+}
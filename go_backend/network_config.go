@@ -0,0 +1,70 @@
+package gobackend
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNetworkDisallowed is returned by every outgoing request while the app
+// has wifi-only mode enabled and reports a cellular connection.
+var ErrNetworkDisallowed = errors.New("network access disallowed on cellular (wifi-only mode)")
+
+func parseProxyURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	proxy, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch strings.ToLower(proxy.Scheme) {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %q", proxy.Scheme)
+	}
+	if proxy.Host == "" {
+		return nil, fmt.Errorf("proxy URL has no host: %s", raw)
+	}
+	return proxy, nil
+}
+
+// applyNetworkConfig pushes timeouts and proxy settings into the shared
+// transports so every client built on them (covers, lyrics, metadata,
+// extensions) picks them up.
+func applyNetworkConfig(cfg BackendConfig, proxy *url.URL) {
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(cfg.ConnectTimeoutMs) * time.Millisecond,
+		KeepAlive: 30 * time.Second,
+	}
+	readTimeout := time.Duration(cfg.ReadTimeoutMs) * time.Millisecond
+
+	var proxyFunc func(*http.Request) (*url.URL, error)
+	if proxy != nil {
+		proxyFunc = http.ProxyURL(proxy)
+	}
+
+	for _, transport := range []*http.Transport{sharedTransport, extensionAPITransport, metadataTransport} {
+		transport.DialContext = dialer.DialContext
+		transport.ResponseHeaderTimeout = readTimeout
+		transport.Proxy = proxyFunc
+	}
+	CloseIdleConnections()
+}
+
+func checkNetworkAllowed() error {
+	cfg := GetBackendConfig()
+	if cfg.WifiOnly && cfg.Cellular {
+		return ErrNetworkDisallowed
+	}
+	return nil
+}
+
+func configuredUserAgent() string {
+	return GetBackendConfig().UserAgent
+}
@@ -0,0 +1,133 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// ErrOperationLimitReached is returned by a non-blocking heavy operation when
+// every slot is busy.
+var ErrOperationLimitReached = errors.New("too many concurrent operations")
+
+// operationLimiter is a resizable counting semaphore. Heavy operations
+// (full rewrites, decodes, network fetches) take a slot; cheap reads such as
+// ReadMetadata or GetAudioQuality never go through it.
+type operationLimiter struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+	queued int
+}
+
+func newOperationLimiter(limit int) *operationLimiter {
+	l := &operationLimiter{limit: limit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+var heavyOperationLimiter = newOperationLimiter(defaultMaxConcurrentOperations)
+
+func (l *operationLimiter) setLimit(limit int) {
+	if limit <= 0 {
+		limit = 1
+	}
+
+	l.mu.Lock()
+	l.limit = limit
+	l.mu.Unlock()
+
+	// Wake queued callers in case the limit grew.
+	l.cond.Broadcast()
+}
+
+func (l *operationLimiter) acquire(nonBlocking bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active < l.limit {
+		l.active++
+		return nil
+	}
+	if nonBlocking {
+		return ErrOperationLimitReached
+	}
+
+	l.queued++
+	for l.active >= l.limit {
+		l.cond.Wait()
+	}
+	l.queued--
+	l.active++
+	return nil
+}
+
+func (l *operationLimiter) release() {
+	l.mu.Lock()
+	if l.active > 0 {
+		l.active--
+	}
+	l.mu.Unlock()
+
+	l.cond.Signal()
+}
+
+type OperationLimitStats struct {
+	Limit  int `json:"limit"`
+	Active int `json:"active"`
+	Queued int `json:"queued"`
+}
+
+func (l *operationLimiter) stats() OperationLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return OperationLimitStats{
+		Limit:  l.limit,
+		Active: l.active,
+		Queued: l.queued,
+	}
+}
+
+// acquireHeavyOperation takes a slot from the shared limiter, queueing while
+// every slot is busy. Callers must invoke the returned release func exactly
+// once.
+func acquireHeavyOperation() (func(), error) {
+	return acquireHeavySlot(false)
+}
+
+// tryAcquireHeavyOperation is acquireHeavyOperation for calls made
+// non-blocking by their caller: it fails with ErrOperationLimitReached
+// instead of queueing.
+func tryAcquireHeavyOperation() (func(), error) {
+	return acquireHeavySlot(true)
+}
+
+func acquireHeavySlot(nonBlocking bool) (func(), error) {
+	if err := heavyOperationLimiter.acquire(nonBlocking); err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(heavyOperationLimiter.release)
+	}, nil
+}
+
+// GetStats returns backend diagnostics as JSON.
+func GetStats() string {
+	stats := map[string]interface{}{
+		"operations":     heavyOperationLimiter.stats(),
+		"metadata_cache": metadataReadCache.snapshot(),
+		"writes":         sessionWriteStatsSnapshot(),
+	}
+	if footprint := lastLibraryFootprintSnapshot(); footprint != nil {
+		stats["library_footprint"] = footprint
+	}
+
+	jsonBytes, err := json.Marshal(stats)
+	if err != nil {
+		return "{}"
+	}
+	return string(jsonBytes)
+}
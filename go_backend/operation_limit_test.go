@@ -0,0 +1,109 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOperationLimiterQueuesBeyondLimit(t *testing.T) {
+	l := newOperationLimiter(1)
+	if err := l.acquire(false); err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		if err := l.acquire(false); err == nil {
+			close(acquired)
+		}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for l.stats().Queued != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected one queued caller, stats = %+v", l.stats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	select {
+	case <-acquired:
+		t.Fatal("second caller acquired before release")
+	default:
+	}
+
+	l.release()
+	select {
+	case <-acquired:
+	case <-time.After(2 * time.Second):
+		t.Fatal("queued caller was not released")
+	}
+
+	if stats := l.stats(); stats.Active != 1 || stats.Queued != 0 {
+		t.Fatalf("stats after handoff = %+v", stats)
+	}
+}
+
+func TestOperationLimiterNonBlockingFailsWhenBusy(t *testing.T) {
+	l := newOperationLimiter(1)
+	if err := l.acquire(true); err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+	if err := l.acquire(true); !errors.Is(err, ErrOperationLimitReached) {
+		t.Fatalf("expected ErrOperationLimitReached, got %v", err)
+	}
+	l.release()
+	if err := l.acquire(true); err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+}
+
+func TestOperationLimiterGrowingLimitWakesQueue(t *testing.T) {
+	l := newOperationLimiter(1)
+	_ = l.acquire(false)
+
+	done := make(chan struct{})
+	go func() {
+		_ = l.acquire(false)
+		close(done)
+	}()
+
+	for l.stats().Queued != 1 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	l.setLimit(2)
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("raising the limit did not wake the queued caller")
+	}
+}
+
+func TestConfigureUpdatesOperationLimitAndStats(t *testing.T) {
+	original := GetBackendConfig()
+	defer SetBackendConfig(original)
+
+	if err := Configure(`{"max_concurrent_operations":7}`); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if got := GetBackendConfig().MaxConcurrentOperations; got != 7 {
+		t.Fatalf("max_concurrent_operations = %d", got)
+	}
+
+	var stats struct {
+		Operations OperationLimitStats `json:"operations"`
+	}
+	if err := json.Unmarshal([]byte(GetStats()), &stats); err != nil {
+		t.Fatalf("GetStats JSON: %v", err)
+	}
+	if stats.Operations.Limit != 7 {
+		t.Fatalf("stats limit = %d", stats.Operations.Limit)
+	}
+
+	if err := Configure(`{"max_concurrent_operations":`); err == nil {
+		t.Fatal("expected invalid JSON error")
+	}
+}
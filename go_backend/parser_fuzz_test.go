@@ -0,0 +1,138 @@
+package gobackend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+// The corpora in testdata/fuzz hold the seeds below plus every input a
+// campaign found a bug with; plain `go test` replays them. Run a campaign
+// with e.g. `go test -run '^$' -fuzz FuzzFLACStreamInfo -fuzztime 1m`.
+
+func FuzzFLACStreamInfo(f *testing.F) {
+	if data, err := buildSelfTestFLAC(); err == nil {
+		f.Add(data)
+	}
+	f.Add([]byte("fLaC"))
+	f.Add([]byte("fLaC\x00\x00\x00\x00\x01\x00\x00\x00\x81\x00\x00\x00"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		quality, err := parseFLACQualityHeader(data)
+		if err == nil && (quality.TotalSamples < 0 || quality.Duration < 0) {
+			t.Fatalf("negative length: %+v", quality)
+		}
+		GetAudioQualityFromBytes(data)
+		layout, err := scanFLACMetadataBlocks(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return
+		}
+		if layout.AudioOffset < 4 || layout.AudioOffset > int64(len(data)) {
+			t.Fatalf("audio offset %d outside 4..%d", layout.AudioOffset, len(data))
+		}
+		if len(layout.Blocks) > flacMaxMetadataBlocks {
+			t.Fatalf("%d blocks exceed the ceiling", len(layout.Blocks))
+		}
+		readFLACHeadComments(data)
+	})
+}
+
+// vorbisCommentPacket builds a comment packet body: vendor, count and
+// length-prefixed comments.
+func vorbisCommentPacket(vendor string, comments ...string) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(len(vendor)))
+	buf.WriteString(vendor)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(comments)))
+	for _, comment := range comments {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(comment)))
+		buf.WriteString(comment)
+	}
+	return buf.Bytes()
+}
+
+func FuzzVorbisComments(f *testing.F) {
+	f.Add(vorbisCommentPacket("vendor", "TITLE=a", "ARTIST=b", "TRACKNUMBER=1/2", "R128_TRACK_GAIN=-1280"))
+	f.Add(vorbisCommentPacket("", "METADATA_BLOCK_PICTURE=AAAA", "=", "NOEQUALS"))
+	f.Add([]byte{0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parseVorbisComments(data, &AudioMetadata{})
+		extractPictureFromVorbisComments(data)
+		parseFLACPictureBlock(data)
+		parseFLACPictureMeta(flac.MetaDataBlock{Type: flac.Picture, Data: data})
+
+		header := []byte{0x84, byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data))}
+		if len(data) <= 0xFFFFFF {
+			readFLACHeadComments(append(append([]byte("fLaC"), header...), data...))
+		}
+	})
+}
+
+func FuzzParseSyncedLyrics(f *testing.F) {
+	f.Add("[00:01.00]first\n[00:02.50]second\n[bg:backing]\n")
+	f.Add("[99:59.999]last\r\n[00:00.00]\n[ar:artist]\nplain text")
+	f.Add("[bg:orphan]\n[00:01.00]x")
+	f.Fuzz(func(t *testing.T, lrc string) {
+		words := 0
+		for _, line := range parseSyncedLyrics(lrc) {
+			if line.Words == "" || line.StartTimeMs < 0 || line.EndTimeMs < 0 {
+				t.Fatalf("bad line %+v", line)
+			}
+			words += len(line.Words)
+		}
+		if words > maxSyncedLyricsBytes {
+			t.Fatalf("%d bytes of words from a ceiling of %d", words, maxSyncedLyricsBytes)
+		}
+	})
+}
+
+func FuzzCoverDimensions(f *testing.F) {
+	if cover, err := buildSelfTestCover(); err == nil {
+		f.Add(cover)
+	}
+	f.Add([]byte("\xFF\xD8\xFF\xC0\x00\x11\x08\x00\x10\x00\x20\x03\x01\x22\x00\x02\x11\x01\x03\x11\x01"))
+	f.Add([]byte("GIF89a\x10\x00\x20\x00\x00\x00\x00;"))
+	f.Add([]byte("RIFF\x16\x00\x00\x00WEBPVP8L\x05\x00\x00\x00\x2F\x00\x00\x00\x00\x00"))
+	f.Add([]byte("RIFF\x1a\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00\x00\x00\x00\x00\x0f\x00\x00\x0f\x00\x00"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		width, height, format, err := coverDimensions(data)
+		if err == nil && (width <= 0 || height <= 0 || format == "") {
+			t.Fatalf("coverDimensions = %d, %d, %q", width, height, format)
+		}
+		detectCoverMIME("", data)
+		validateCoverImage(data)
+	})
+}
+
+func TestParserLengthChecks(t *testing.T) {
+	if err := checkVorbisCommentLengths(vorbisCommentPacket("v", "A=1", "B=2")); err != nil {
+		t.Fatalf("valid packet rejected: %v", err)
+	}
+	hostile := [][]byte{
+		{},
+		{0xFF, 0xFF, 0xFF, 0x7F},
+		{0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF},
+		{0, 0, 0, 0, 1, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF},
+	}
+	for _, data := range hostile {
+		if _, err := parseVorbisCommentBlock(flac.MetaDataBlock{Type: flac.VorbisComment, Data: data}); err == nil {
+			t.Fatalf("comment block %x accepted", data)
+		}
+	}
+
+	picture := make([]byte, 32, 36)
+	picture[3] = 3
+	picture = append(picture, "abcd"...)
+	picture[31] = 4
+	if pic, err := parseFLACPictureMeta(flac.MetaDataBlock{Type: flac.Picture, Data: picture}); err != nil || string(pic.ImageData) != "abcd" {
+		t.Fatalf("valid picture = %+v, %v", pic, err)
+	}
+	picture[28] = 0x7F
+	if _, err := parseFLACPictureMeta(flac.MetaDataBlock{Type: flac.Picture, Data: picture}); err == nil {
+		t.Fatal("picture with an oversized data length accepted")
+	}
+	if data, _ := parseFLACPictureBlock(picture); data != nil {
+		t.Fatal("parseFLACPictureBlock returned data past the block")
+	}
+}
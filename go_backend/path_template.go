@@ -0,0 +1,93 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Path template variables, usable as {name} in the values of tag preset
+// rules applied to a file.
+const (
+	PathVarFilename  = "filename"
+	PathVarAlbumDir  = "albumdir"
+	PathVarArtistDir = "artistdir"
+	PathVarDisc      = "disc"
+)
+
+// discFolderPattern matches the multi-disc subfolders of an album, such as
+// "CD1", "cd 02", "Disc 2" or "Disk 1 - Bonus", capturing the disc number.
+var discFolderPattern = regexp.MustCompile(`(?i)^(?:cd|dis[ck])[\s._-]*(\d{1,3})(?:\s*[-–:(\[].*)?$`)
+
+var pathTemplateVarNames = []string{PathVarFilename, PathVarAlbumDir, PathVarArtistDir, PathVarDisc}
+
+var pathTemplateVarPattern = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// pathTemplateVars extracts the template variables of a library file from
+// its path: the file name without extension, the album folder, the artist
+// folder above it and, when the file sits in a disc subfolder, the disc
+// number. Disc subfolders are skipped when looking for the album folder, so
+// "Artist/Album/CD2/01.flac" has albumdir "Album". Folders that do not exist
+// in the path are left empty.
+func pathTemplateVars(filePath string) map[string]string {
+	vars := map[string]string{
+		PathVarFilename:  strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)),
+		PathVarAlbumDir:  "",
+		PathVarArtistDir: "",
+		PathVarDisc:      "",
+	}
+	// Folder names nearest the file first.
+	var dirs []string
+	parts := strings.Split(filepath.ToSlash(filepath.Dir(filepath.Clean(filePath))), "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i] != "" && parts[i] != "." {
+			dirs = append(dirs, parts[i])
+		}
+	}
+	if len(dirs) > 0 {
+		if match := discFolderPattern.FindStringSubmatch(dirs[0]); match != nil {
+			if disc, err := strconv.Atoi(match[1]); err == nil {
+				vars[PathVarDisc] = strconv.Itoa(disc)
+			}
+			dirs = dirs[1:]
+		}
+	}
+	if len(dirs) > 0 {
+		vars[PathVarAlbumDir] = dirs[0]
+	}
+	if len(dirs) > 1 {
+		vars[PathVarArtistDir] = dirs[1]
+	}
+	return vars
+}
+
+// expandPathTemplate replaces each {name} in value by its variable. Known
+// names without a value become empty; unknown names are left as written.
+func expandPathTemplate(value string, vars map[string]string) string {
+	if !strings.Contains(value, "{") {
+		return value
+	}
+	return pathTemplateVarPattern.ReplaceAllStringFunc(value, func(token string) string {
+		name := token[1 : len(token)-1]
+		if expanded, ok := vars[name]; ok {
+			return expanded
+		}
+		if slices.Contains(pathTemplateVarNames, name) {
+			return ""
+		}
+		return token
+	})
+}
+
+// ExtractPathVariables returns the template variables of filePath as a JSON
+// object: filename, albumdir, artistdir and disc. See pathTemplateVars.
+func ExtractPathVariables(filePath string) (string, error) {
+	jsonBytes, err := json.Marshal(pathTemplateVars(filePath))
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,77 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPathTemplateVars(t *testing.T) {
+	tests := []struct {
+		path string
+		want map[string]string
+	}{
+		{"/storage/emulated/0/Music/Artist/Album/01 - Song.flac",
+			map[string]string{"filename": "01 - Song", "albumdir": "Album", "artistdir": "Artist", "disc": ""}},
+		{"/sdcard/Music/Artist/Album (2020)/CD1/01 Song.flac",
+			map[string]string{"filename": "01 Song", "albumdir": "Album (2020)", "artistdir": "Artist", "disc": "1"}},
+		{"Artist/Album/Disc 2/03.flac",
+			map[string]string{"filename": "03", "albumdir": "Album", "artistdir": "Artist", "disc": "2"}},
+		{"Artist/Box Set/disk_03 - Live/07 Encore.m4a",
+			map[string]string{"filename": "07 Encore", "albumdir": "Box Set", "artistdir": "Artist", "disc": "3"}},
+		{"Artist/CD Singles/01.mp3",
+			map[string]string{"filename": "01", "albumdir": "CD Singles", "artistdir": "Artist", "disc": ""}},
+		{"Album/01.flac",
+			map[string]string{"filename": "01", "albumdir": "Album", "artistdir": "", "disc": ""}},
+		{"song.flac",
+			map[string]string{"filename": "song", "albumdir": "", "artistdir": "", "disc": ""}},
+	}
+	for _, tt := range tests {
+		if got := pathTemplateVars(filepath.FromSlash(tt.path)); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("pathTemplateVars(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestExpandPathTemplate(t *testing.T) {
+	vars := pathTemplateVars("Artist/Album/CD2/01.flac")
+	if got := expandPathTemplate("{artistdir} - {albumdir} (disc {disc}) {other}", vars); got != "Artist - Album (disc 2) {other}" {
+		t.Fatalf("expanded = %q", got)
+	}
+	if got := expandPathTemplate("{albumdir}", nil); got != "" {
+		t.Fatalf("expanded without a path = %q", got)
+	}
+}
+
+func TestEmbedAllPresetUsesPathVariables(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Folder Artist", "Folder Album", "CD1")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "01 Song.flac")
+	if err := os.Rename(writeTestFLACWithMetadata(t, Metadata{}), path); err != nil {
+		t.Fatal(err)
+	}
+
+	preset := `{"rules":[
+		{"op":"set_if_empty","field":"album","value":"{albumdir}"},
+		{"op":"set_if_empty","field":"artist","value":"{artistdir}"},
+		{"op":"set_if_empty","field":"title","value":"{filename}"}
+	]}`
+	if err := EmbedAll(path, Metadata{Title: "Tagged Title"}, Lyrics{}, nil, EmbedOptions{Preset: preset}); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	meta, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if meta.Album != "Folder Album" || meta.Artist != "Folder Artist" || meta.Title != "Tagged Title" {
+		t.Fatalf("metadata = %+v", meta)
+	}
+
+	withoutPath, err := ApplyPreset(Metadata{}, preset)
+	if err != nil || withoutPath.Album != "" {
+		t.Fatalf("ApplyPreset = %+v, %v", withoutPath, err)
+	}
+}
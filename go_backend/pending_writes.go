@@ -0,0 +1,368 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+const (
+	pendingWritesFileName = "pending_writes.json"
+	pendingWriteSuffix    = ".spotiflac-pending"
+)
+
+// ErrWritePending is returned by operations that rewrite a file's audio or
+// layout, such as renames or trimming, while a tag write to it is still
+// waiting for CommitPendingWrites. Album transactions also return it for a
+// file that is playing. Tag writes instead update the pending write.
+var ErrWritePending = errors.New("file has a pending write; commit it first")
+
+// errPendingWritesUnavailable means no DataDir is configured, so a deferred
+// write could not be recovered after a restart and is not attempted.
+var errPendingWritesUnavailable = errors.New("pending writes need a data directory")
+
+// PendingWrite is a fully written replacement of FilePath kept at TempPath
+// until the file stops playing. OriginalSize and OriginalModTime identify
+// the file it was built from, so a file changed meanwhile is not clobbered.
+type PendingWrite struct {
+	FilePath        string `json:"file_path"`
+	TempPath        string `json:"temp_path"`
+	CreatedAt       int64  `json:"created_at"`
+	OriginalSize    int64  `json:"original_size"`
+	OriginalModTime int64  `json:"original_mod_time"`
+}
+
+// PendingWritesReport is the result of CommitPendingWrites and
+// RecoverPendingWrites. Still playing files stay pending; discarded writes
+// were dropped because the original changed or the temp file is gone.
+type PendingWritesReport struct {
+	Committed int               `json:"committed"`
+	Pending   int               `json:"pending"`
+	Discarded int               `json:"discarded"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+var (
+	pendingWritesMu sync.Mutex
+	// pendingWrites mirrors the registry at pendingWritesLoadedFrom, so a
+	// save does not read it from disk; the file is only read again when
+	// DataDir points somewhere else.
+	pendingWrites           []PendingWrite
+	pendingWritesLoadedFrom string
+	// pendingWriteStaging holds a per-file lock, so two saves of a playing
+	// file stage one after the other instead of both registering a write.
+	pendingWriteStaging sync.Map
+
+	playbackActiveMu sync.RWMutex
+	playbackActive   = make(map[string]bool)
+)
+
+// SetPlaybackActive tells the backend whether filePath is being played.
+// While it is, full FLAC rewrites are written aside and registered instead
+// of replacing the file, and metadata is never overwritten in place; call
+// CommitPendingWrites once playback stops.
+func SetPlaybackActive(filePath string, active bool) {
+	playbackActiveMu.Lock()
+	defer playbackActiveMu.Unlock()
+	if active {
+		playbackActive[filePath] = true
+	} else {
+		delete(playbackActive, filePath)
+	}
+}
+
+func isPlaybackActive(filePath string) bool {
+	playbackActiveMu.RLock()
+	defer playbackActiveMu.RUnlock()
+	return playbackActive[filePath]
+}
+
+func pendingWritesPath() (string, error) {
+	dataDir := GetBackendConfig().DataDir
+	if dataDir == "" {
+		return "", errPendingWritesUnavailable
+	}
+	return filepath.Join(dataDir, pendingWritesFileName), nil
+}
+
+// loadPendingWritesLocked returns the registry, reading it from disk the
+// first time. pendingWritesMu must be held.
+func loadPendingWritesLocked() ([]PendingWrite, error) {
+	path, err := pendingWritesPath()
+	if err != nil {
+		return nil, err
+	}
+	if pendingWritesLoadedFrom == path {
+		return slices.Clone(pendingWrites), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read pending writes: %w", err)
+	}
+	var writes []PendingWrite
+	if err == nil {
+		if err := json.Unmarshal(data, &writes); err != nil {
+			GoLog("[PendingWrites] Discarding unreadable registry: %v\n", err)
+			writes = nil
+		}
+	}
+	pendingWrites, pendingWritesLoadedFrom = writes, path
+	return slices.Clone(writes), nil
+}
+
+// savePendingWritesLocked writes the registry atomically. pendingWritesMu
+// must be held.
+func savePendingWritesLocked(writes []PendingWrite) error {
+	path, err := pendingWritesPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if writes == nil {
+		writes = []PendingWrite{}
+	}
+	data, err := json.Marshal(writes)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write pending writes: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to commit pending writes: %w", err)
+	}
+	pendingWrites, pendingWritesLoadedFrom = slices.Clone(writes), path
+	return nil
+}
+
+// pendingWriteFor returns the write deferred for filePath, if any.
+func pendingWriteFor(filePath string) (PendingWrite, bool) {
+	pendingWritesMu.Lock()
+	defer pendingWritesMu.Unlock()
+	writes, err := loadPendingWritesLocked()
+	if err != nil {
+		return PendingWrite{}, false
+	}
+	for _, write := range writes {
+		if write.FilePath == filePath {
+			return write, true
+		}
+	}
+	return PendingWrite{}, false
+}
+
+func hasPendingWrite(filePath string) bool {
+	_, ok := pendingWriteFor(filePath)
+	return ok
+}
+
+// parseFLACStaged parses filePath for an edit. When a write to it is
+// pending, the staged replacement is parsed instead, so the edit builds on
+// that write rather than dropping it.
+func parseFLACStaged(filePath string) (*flac.File, error) {
+	if write, ok := pendingWriteFor(filePath); ok {
+		return flac.ParseFile(write.TempPath)
+	}
+	return flac.ParseFile(filePath)
+}
+
+// deferFLACRewrite writes the rewritten file next to filePath and registers
+// it for CommitPendingWrites. It returns errPendingWritesUnavailable, having
+// written nothing, when no DataDir is configured.
+func deferFLACRewrite(filePath string, blocks []*flac.MetaDataBlock) (flacSaveStats, error) {
+	if _, err := pendingWritesPath(); err != nil {
+		return flacSaveStats{}, err
+	}
+	stagingLock, _ := pendingWriteStaging.LoadOrStore(filePath, &sync.Mutex{})
+	mu := stagingLock.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if write, ok := pendingWriteFor(filePath); ok {
+		return restageFLACRewrite(write, blocks)
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return flacSaveStats{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	tempPath := filePath + pendingWriteSuffix
+	stats, err := writeFLACStreamingTemp(filePath, tempPath, blocks)
+	if err != nil {
+		return flacSaveStats{}, err
+	}
+
+	pendingWritesMu.Lock()
+	defer pendingWritesMu.Unlock()
+	writes, err := loadPendingWritesLocked()
+	if err == nil {
+		write := PendingWrite{
+			FilePath:        filePath,
+			TempPath:        tempPath,
+			CreatedAt:       time.Now().Unix(),
+			OriginalSize:    info.Size(),
+			OriginalModTime: info.ModTime().UnixNano(),
+		}
+		// A file has at most one pending write; a stale entry is replaced.
+		if i := slices.IndexFunc(writes, func(w PendingWrite) bool { return w.FilePath == filePath }); i >= 0 {
+			if writes[i].TempPath != tempPath {
+				os.Remove(writes[i].TempPath)
+			}
+			writes[i] = write
+		} else {
+			writes = append(writes, write)
+		}
+		err = savePendingWritesLocked(writes)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return flacSaveStats{}, err
+	}
+	GoLog("[PendingWrites] Deferred write of %s until playback stops\n", filePath)
+	stats.kind = RewriteDeferred
+	return stats, nil
+}
+
+// restageFLACRewrite replaces the staged file of a pending write with one
+// carrying blocks. The registry entry stays as it is: the original the
+// write will replace has not changed.
+func restageFLACRewrite(write PendingWrite, blocks []*flac.MetaDataBlock) (flacSaveStats, error) {
+	restagePath := write.TempPath + ".tmp"
+	stats, err := writeFLACStreamingTemp(write.TempPath, restagePath, blocks)
+	if err != nil {
+		return flacSaveStats{}, err
+	}
+	if err := os.Rename(restagePath, write.TempPath); err != nil {
+		os.Remove(restagePath)
+		return flacSaveStats{}, fmt.Errorf("failed to replace pending file: %w", err)
+	}
+	GoLog("[PendingWrites] Updated the deferred write of %s\n", write.FilePath)
+	stats.kind = RewriteDeferred
+	return stats, nil
+}
+
+// commitPendingWrite renames write's temp file over the original when the
+// original is still the file it was built from, and removes it otherwise.
+// retry is set when the rename itself failed and the write should stay
+// registered.
+func commitPendingWrite(write PendingWrite) (retry bool, err error) {
+	defer invalidateMetadataCache(write.FilePath)
+	if _, err := os.Stat(write.TempPath); err != nil {
+		return false, fmt.Errorf("pending file is missing: %w", err)
+	}
+	info, err := os.Stat(write.FilePath)
+	if err != nil || info.Size() != write.OriginalSize || info.ModTime().UnixNano() != write.OriginalModTime {
+		os.Remove(write.TempPath)
+		return false, fmt.Errorf("file changed since the write was deferred")
+	}
+	if err := os.Rename(write.TempPath, write.FilePath); err != nil {
+		return true, fmt.Errorf("failed to replace FLAC file: %w", err)
+	}
+	flacRewriteCount.Add(1)
+	return false, nil
+}
+
+// settlePendingWrites commits every pending write whose file is not being
+// played and keeps the rest registered.
+func settlePendingWrites() (*PendingWritesReport, error) {
+	if err := checkReadOnlyMode(); err != nil {
+		return nil, err
+	}
+	pendingWritesMu.Lock()
+	defer pendingWritesMu.Unlock()
+
+	writes, err := loadPendingWritesLocked()
+	if err != nil {
+		return nil, err
+	}
+	report := &PendingWritesReport{}
+	var remaining []PendingWrite
+	for _, write := range writes {
+		if isPlaybackActive(write.FilePath) {
+			remaining = append(remaining, write)
+			report.Pending++
+			continue
+		}
+		retry, err := commitPendingWrite(write)
+		switch {
+		case err == nil:
+			report.Committed++
+			continue
+		case retry:
+			remaining = append(remaining, write)
+			report.Pending++
+		default:
+			report.Discarded++
+		}
+		if report.Errors == nil {
+			report.Errors = make(map[string]string)
+		}
+		report.Errors[write.FilePath] = err.Error()
+	}
+	if err := savePendingWritesLocked(remaining); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func marshalPendingWritesReport(report *PendingWritesReport, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// CommitPendingWrites applies the writes deferred while their files were
+// playing, for every file no longer marked with SetPlaybackActive.
+func CommitPendingWrites() (string, error) {
+	return marshalPendingWritesReport(settlePendingWrites())
+}
+
+// ListPendingWrites returns the deferred writes as a JSON array.
+func ListPendingWrites() (string, error) {
+	pendingWritesMu.Lock()
+	writes, err := loadPendingWritesLocked()
+	pendingWritesMu.Unlock()
+	if errors.Is(err, errPendingWritesUnavailable) {
+		return "[]", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if writes == nil {
+		writes = []PendingWrite{}
+	}
+	jsonBytes, err := json.Marshal(writes)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// RecoverPendingWrites settles writes left pending by a previous run; call
+// it at startup once DataDir is configured. Nothing is playing yet, so each
+// is committed, or removed when its file changed or its temp file is gone.
+func RecoverPendingWrites() (string, error) {
+	if _, err := pendingWritesPath(); err != nil {
+		return "", err
+	}
+	report, err := settlePendingWrites()
+	if err == nil && report.Committed+report.Discarded > 0 {
+		GoLog("[PendingWrites] Recovered %d, discarded %d\n", report.Committed, report.Discarded)
+	}
+	return marshalPendingWritesReport(report, err)
+}
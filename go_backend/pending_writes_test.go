@@ -0,0 +1,198 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+func configurePendingWritesTest(t *testing.T) {
+	t.Helper()
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	cfg := original
+	cfg.DataDir = t.TempDir()
+	if err := SetBackendConfig(cfg); err != nil {
+		t.Fatalf("SetBackendConfig: %v", err)
+	}
+}
+
+func settlePendingWritesJSON(t *testing.T, settle func() (string, error)) PendingWritesReport {
+	t.Helper()
+	out, err := settle()
+	report := mustDecodeJSON[PendingWritesReport](t, out, err)
+	return report
+}
+
+func TestPendingWriteDeferredUntilPlaybackStops(t *testing.T) {
+	configurePendingWritesTest(t)
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Before"})
+	original := mustReadFile(t, path)
+
+	SetPlaybackActive(path, true)
+	t.Cleanup(func() { SetPlaybackActive(path, false) })
+	if err := EmbedMetadata(path, Metadata{Title: "After"}, ""); err != nil {
+		t.Fatalf("EmbedMetadata: %v", err)
+	}
+	if string(mustReadFile(t, path)) != string(original) {
+		t.Fatal("file being played was modified")
+	}
+	// A second save updates the pending write instead of failing.
+	if err := EmbedMetadata(path, Metadata{Title: "Again"}, ""); err != nil {
+		t.Fatalf("second EmbedMetadata: %v", err)
+	}
+	if string(mustReadFile(t, path)) != string(original) {
+		t.Fatal("file being played was modified by the second save")
+	}
+
+	list, err := ListPendingWrites()
+	if err != nil {
+		t.Fatalf("ListPendingWrites: %v", err)
+	}
+	var writes []PendingWrite
+	if err := json.Unmarshal([]byte(list), &writes); err != nil || len(writes) != 1 || writes[0].FilePath != path {
+		t.Fatalf("unexpected pending writes %s: %v", list, err)
+	}
+
+	report := settlePendingWritesJSON(t, CommitPendingWrites)
+	if report.Committed != 0 || report.Pending != 1 {
+		t.Fatalf("expected write kept while playing, got %+v", report)
+	}
+
+	SetPlaybackActive(path, false)
+	report = settlePendingWritesJSON(t, CommitPendingWrites)
+	if report.Committed != 1 || report.Pending != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	metadata, err := ReadMetadata(path)
+	if err != nil || metadata.Title != "Again" {
+		t.Fatalf("expected committed title, got %+v (%v)", metadata, err)
+	}
+	if _, err := os.Stat(path + pendingWriteSuffix); !os.IsNotExist(err) {
+		t.Fatalf("pending file left behind: %v", err)
+	}
+}
+
+func TestRecoverPendingWritesDiscardsChangedFiles(t *testing.T) {
+	configurePendingWritesTest(t)
+	kept := writeTestFLACWithMetadata(t, Metadata{Title: "Kept"})
+	changed := writeTestFLACWithMetadata(t, Metadata{Title: "Changed"})
+
+	for _, path := range []string{kept, changed} {
+		SetPlaybackActive(path, true)
+		if err := EmbedMetadata(path, Metadata{Title: "Deferred"}, ""); err != nil {
+			t.Fatalf("EmbedMetadata: %v", err)
+		}
+		SetPlaybackActive(path, false)
+	}
+	// Simulate another app editing the file before the restart.
+	if err := os.WriteFile(changed, append(mustReadFile(t, changed), 0), 0644); err != nil {
+		t.Fatalf("modify file: %v", err)
+	}
+
+	report := settlePendingWritesJSON(t, RecoverPendingWrites)
+	if report.Committed != 1 || report.Discarded != 1 || report.Errors[changed] == "" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if metadata, err := ReadMetadata(kept); err != nil || metadata.Title != "Deferred" {
+		t.Fatalf("expected recovered write, got %+v (%v)", metadata, err)
+	}
+	if _, err := os.Stat(changed + pendingWriteSuffix); !os.IsNotExist(err) {
+		t.Fatalf("stale pending file left behind: %v", err)
+	}
+	if list, _ := ListPendingWrites(); list != "[]" {
+		t.Fatalf("expected no pending writes, got %s", list)
+	}
+}
+
+func TestPendingWriteStacksSavesDuringPlayback(t *testing.T) {
+	configurePendingWritesTest(t)
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	SetPlaybackActive(path, true)
+	t.Cleanup(func() { SetPlaybackActive(path, false) })
+
+	if err := EmbedLyrics(path, "[00:01.00]la"); err != nil {
+		t.Fatalf("EmbedLyrics: %v", err)
+	}
+	// The registry is kept in memory once loaded.
+	registry, _ := pendingWritesPath()
+	if err := os.Remove(registry); err != nil {
+		t.Fatalf("remove registry: %v", err)
+	}
+	if !hasPendingWrite(path) {
+		t.Fatal("pending write forgotten once the registry file was gone")
+	}
+	if err := EmbedGenreLabel(path, "Jazz", ""); err != nil {
+		t.Fatalf("EmbedGenreLabel: %v", err)
+	}
+
+	// Playback stopped but nothing committed yet: the next save must still
+	// build on the pending write rather than replace the original.
+	SetPlaybackActive(path, false)
+	if err := EditFlacFields(path, map[string]string{"label": "Indie"}); err != nil {
+		t.Fatalf("EditFlacFields: %v", err)
+	}
+
+	report := settlePendingWritesJSON(t, CommitPendingWrites)
+	if report.Committed != 1 || report.Discarded != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	tags, err := GetTags(path, []string{"TITLE", "LYRICS", "GENRE", "ORGANIZATION"})
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	for _, key := range []string{"TITLE", "LYRICS", "GENRE", "ORGANIZATION"} {
+		if len(tags[key]) == 0 {
+			t.Fatalf("%s lost across stacked saves: %v", key, tags)
+		}
+	}
+}
+
+func TestConcurrentDeferredWritesRegisterOneWrite(t *testing.T) {
+	configurePendingWritesTest(t)
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Before"})
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	const saves = 16
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make(chan error, saves)
+	for range saves {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := deferFLACRewrite(path, f.Meta)
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("deferFLACRewrite: %v", err)
+		}
+	}
+
+	list, err := ListPendingWrites()
+	writes := mustDecodeJSON[[]PendingWrite](t, list, err)
+	if len(writes) != 1 || writes[0].FilePath != path {
+		t.Fatalf("pending writes = %+v", writes)
+	}
+	staged, _ := filepath.Glob(path + pendingWriteSuffix + "*")
+	if len(staged) != 1 || staged[0] != writes[0].TempPath {
+		t.Fatalf("staged files = %v, want only %s", staged, writes[0].TempPath)
+	}
+	if _, err := flac.ParseFile(writes[0].TempPath); err != nil {
+		t.Fatalf("staged file unreadable: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package gobackend
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+)
+
+// Hint codes of PermissionError. Each names the fix the app can deep-link
+// to instead of showing the raw OS error.
+const (
+	// PermissionHintAllFilesAccess: shared storage needs the "All files
+	// access" (MANAGE_EXTERNAL_STORAGE) grant.
+	PermissionHintAllFilesAccess = "manage_external_storage"
+	// PermissionHintSDCardReadOnly: the SD card is read-only, either
+	// write-protected or closed to apps outside the system folder picker.
+	PermissionHintSDCardReadOnly = "sd_card_read_only"
+	// PermissionHintOtherAppSandbox: the path is in another app's private
+	// Android/data, Android/obb or Android/media folder.
+	PermissionHintOtherAppSandbox = "other_app_sandbox"
+	// PermissionHintPendingMedia: the file is a MediaStore entry still being
+	// written (".pending-…"), owned by whichever app created it.
+	PermissionHintPendingMedia = "media_store_pending"
+)
+
+var permissionHintMessages = map[string]string{
+	PermissionHintAllFilesAccess:  "grant All files access to write to shared storage",
+	PermissionHintSDCardReadOnly:  "the SD card is read-only; choose the folder through the system folder picker",
+	PermissionHintOtherAppSandbox: "the folder belongs to another app and cannot be written",
+	PermissionHintPendingMedia:    "the file is still being written to the media library; try again when it is finished",
+}
+
+// ErrPermissionDenied matches every PermissionError via errors.Is.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// PermissionError is a write failure caused by Android storage permissions.
+// Hint is one of the PermissionHint codes; the message carries it as
+// "[hint:<code>]" for callers that only see the string.
+type PermissionError struct {
+	Path string
+	Hint string
+	Err  error
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("cannot write %s: %s [hint:%s]: %v", e.Path, permissionHintMessages[e.Hint], e.Hint, e.Err)
+}
+
+func (e *PermissionError) Unwrap() error {
+	return e.Err
+}
+
+func (e *PermissionError) Is(target error) bool {
+	return target == ErrPermissionDenied
+}
+
+var (
+	// removableVolumePattern matches SD cards and USB drives, mounted by
+	// volume id such as /storage/1A2B-3C4D.
+	removableVolumePattern = regexp.MustCompile(`^/storage/[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}(/|$)`)
+	sharedStoragePattern   = regexp.MustCompile(`^(/storage/emulated/\d+|/storage/self/primary|/sdcard|/mnt/sdcard)(/|$)`)
+	// appSandboxPattern captures the package owning an app-private path.
+	appSandboxPattern     = regexp.MustCompile(`^(?:/storage/emulated/\d+|/storage/self/primary|/sdcard|/storage/[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4})/Android/(?:data|obb|media)/([^/]+)|^/data/(?:data|user/\d+)/([^/]+)`)
+	permissionHintPattern = regexp.MustCompile(`\[hint:([a-z_]+)\]`)
+)
+
+// sandboxPackage returns the package whose private folder holds path, or ""
+// for a path outside any app sandbox.
+func sandboxPackage(path string) string {
+	match := appSandboxPattern.FindStringSubmatch(path)
+	if match == nil {
+		return ""
+	}
+	return match[1] + match[2]
+}
+
+// classifyPermissionError returns the hint code for a failed write to path,
+// or "" when err is not a permission failure the user can act on. The errno
+// says whether it is one; the path says which.
+func classifyPermissionError(path string, err error) string {
+	if errors.Is(err, ErrReadOnlyVolume) || errors.Is(err, syscall.EROFS) {
+		return PermissionHintSDCardReadOnly
+	}
+	if !errors.Is(err, syscall.EACCES) && !errors.Is(err, syscall.EPERM) {
+		return ""
+	}
+	path = filepath.Clean(path)
+	if strings.HasPrefix(filepath.Base(path), ".pending-") {
+		return PermissionHintPendingMedia
+	}
+	if owner := sandboxPackage(path); owner != "" {
+		// Our own sandbox is always writable, so a failure there is not
+		// something a settings screen can fix.
+		if owner == sandboxPackage(GetBackendConfig().DataDir) {
+			return ""
+		}
+		return PermissionHintOtherAppSandbox
+	}
+	switch {
+	case removableVolumePattern.MatchString(path):
+		return PermissionHintSDCardReadOnly
+	case sharedStoragePattern.MatchString(path):
+		return PermissionHintAllFilesAccess
+	}
+	return ""
+}
+
+// classifyWriteError wraps err in a PermissionError when it is a storage
+// permission failure on path, and returns it unchanged otherwise.
+func classifyWriteError(path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var permErr *PermissionError
+	if errors.As(err, &permErr) {
+		return err
+	}
+	if hint := classifyPermissionError(path, err); hint != "" {
+		return &PermissionError{Path: path, Hint: hint, Err: err}
+	}
+	return err
+}
+
+// PermissionHintFromError returns the hint code in the message of an error
+// returned by a write API, or "" when it is not a permission failure. The
+// app uses it to open the matching settings screen.
+func PermissionHintFromError(message string) string {
+	if match := permissionHintPattern.FindStringSubmatch(message); match != nil {
+		return match[1]
+	}
+	return ""
+}
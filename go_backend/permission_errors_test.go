@@ -0,0 +1,85 @@
+package gobackend
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyPermissionError(t *testing.T) {
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	cfg := original
+	cfg.DataDir = "/data/user/0/com.zarz.spotiflac/files"
+	if err := SetBackendConfig(cfg); err != nil {
+		t.Fatalf("SetBackendConfig: %v", err)
+	}
+
+	denied := func(path string) error {
+		return &os.PathError{Op: "open", Path: path, Err: syscall.EACCES}
+	}
+	cases := []struct {
+		path string
+		err  error
+		want string
+	}{
+		{"/storage/emulated/0/Music/a.flac", denied("/storage/emulated/0/Music/a.flac"), PermissionHintAllFilesAccess},
+		{"/sdcard/Music", fmt.Errorf("folder is not writable: %w", &os.PathError{Op: "open", Path: "/sdcard/Music", Err: syscall.EPERM}), PermissionHintAllFilesAccess},
+		{"/storage/1A2B-3C4D/Music/a.flac", denied("/storage/1A2B-3C4D/Music/a.flac"), PermissionHintSDCardReadOnly},
+		{"/storage/emulated/0/Music", &ReadOnlyVolumeError{Path: "/storage/emulated/0/Music"}, PermissionHintSDCardReadOnly},
+		{"/mnt/usb/a.flac", &os.PathError{Op: "open", Path: "/mnt/usb/a.flac", Err: syscall.EROFS}, PermissionHintSDCardReadOnly},
+		{"/storage/emulated/0/Android/data/com.other.player/files/a.flac", denied("x"), PermissionHintOtherAppSandbox},
+		{"/storage/1A2B-3C4D/Android/media/com.other.player/a.flac", denied("x"), PermissionHintOtherAppSandbox},
+		{"/data/user/0/com.other.player/files/a.flac", denied("x"), PermissionHintOtherAppSandbox},
+		{"/storage/emulated/0/Music/.pending-1700000000-a.flac", denied("x"), PermissionHintPendingMedia},
+		// Our own sandbox and paths off Android storage have no fix to hint at.
+		{"/storage/emulated/0/Android/data/com.zarz.spotiflac/files/a.flac", denied("x"), ""},
+		{"/home/user/a.flac", denied("x"), ""},
+		// Not a permission failure at all.
+		{"/storage/emulated/0/Music/a.flac", &os.PathError{Op: "open", Path: "x", Err: syscall.ENOSPC}, ""},
+		{"/storage/emulated/0/Music/a.flac", errors.New("failed to parse FLAC file"), ""},
+	}
+	for _, tc := range cases {
+		if got := classifyPermissionError(tc.path, tc.err); got != tc.want {
+			t.Fatalf("classifyPermissionError(%s, %v) = %q, want %q", tc.path, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestClassifyWriteErrorWrapsPermissionFailures(t *testing.T) {
+	path := "/storage/emulated/0/Music/a.flac"
+	cause := &os.PathError{Op: "open", Path: path, Err: syscall.EACCES}
+	err := classifyWriteError(path, cause)
+
+	var permErr *PermissionError
+	if !errors.As(err, &permErr) || permErr.Hint != PermissionHintAllFilesAccess {
+		t.Fatalf("expected PermissionError, got %v", err)
+	}
+	if !errors.Is(err, ErrPermissionDenied) || !errors.Is(err, syscall.EACCES) {
+		t.Fatalf("PermissionError must match ErrPermissionDenied and its cause: %v", err)
+	}
+	if got := PermissionHintFromError(fmt.Errorf("embed failed: %w", err).Error()); got != PermissionHintAllFilesAccess {
+		t.Fatalf("PermissionHintFromError = %q", got)
+	}
+	if got := classifyDownloadErrorType(err.Error()); got != "permission" {
+		t.Fatalf("classifyDownloadErrorType = %q", got)
+	}
+	if again := classifyWriteError(path, err); again != err {
+		t.Fatalf("classified error must not be wrapped twice")
+	}
+
+	readOnly := classifyWriteError("/storage/1A2B-3C4D", &ReadOnlyVolumeError{Path: "/storage/1A2B-3C4D"})
+	if !errors.Is(readOnly, ErrReadOnlyVolume) || PermissionHintFromError(readOnly.Error()) != PermissionHintSDCardReadOnly {
+		t.Fatalf("read-only volume must keep matching ErrReadOnlyVolume: %v", readOnly)
+	}
+
+	other := errors.New("disk full")
+	if got := classifyWriteError(path, other); got != other {
+		t.Fatalf("unrelated error changed: %v", got)
+	}
+	if classifyWriteError(path, nil) != nil || PermissionHintFromError(other.Error()) != "" {
+		t.Fatalf("unexpected hint for non-permission error")
+	}
+}
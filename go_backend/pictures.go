@@ -0,0 +1,218 @@
+package gobackend
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-flac/flacpicture/v2"
+	"github.com/go-flac/go-flac/v2"
+)
+
+// pictureTypeNames follows the ID3v2 APIC picture types used by FLAC.
+var pictureTypeNames = []string{
+	"Other", "File Icon", "Other File Icon", "Front Cover", "Back Cover",
+	"Leaflet Page", "Media", "Lead Artist", "Artist", "Conductor", "Band",
+	"Composer", "Lyricist", "Recording Location", "During Recording",
+	"During Performance", "Video Screen Capture", "Bright Coloured Fish",
+	"Illustration", "Band Logotype", "Publisher Logotype",
+}
+
+// PictureInfo describes one PICTURE block. Index counts picture blocks in
+// file order and is what ExtractPicture takes.
+type PictureInfo struct {
+	Index       int    `json:"index"`
+	Type        int    `json:"type"`
+	TypeName    string `json:"type_name"`
+	Description string `json:"description"`
+	MIME        string `json:"mime"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ColorDepth  int    `json:"color_depth"`
+	Size        int    `json:"size"`
+	// NonImage is set when the declared MIME type is not an image, as with
+	// PDF booklets embedded by some sources.
+	NonImage bool `json:"non_image,omitempty"`
+}
+
+var errPictureBlockLength = errors.New("picture block length runs past the end of the block")
+
+// checkFLACPictureLengths walks the MIME, description and data lengths of a
+// PICTURE block body. Like flacvorbis, flacpicture allocates what each
+// length claims before reading it.
+func checkFLACPictureLengths(data []byte) error {
+	rest := data
+	skip := func(fixed int) bool {
+		if len(rest) < fixed+4 {
+			return false
+		}
+		n := binary.BigEndian.Uint32(rest[fixed:])
+		rest = rest[fixed+4:]
+		if uint64(n) > uint64(len(rest)) {
+			return false
+		}
+		rest = rest[n:]
+		return true
+	}
+	// type | MIME, description | width, height, depth, colours | data
+	if !skip(4) || !skip(0) || !skip(16) {
+		return errPictureBlockLength
+	}
+	return nil
+}
+
+// parseFLACPictureMeta is flacpicture.ParseFromMetaDataBlock with the
+// lengths checked first.
+func parseFLACPictureMeta(meta flac.MetaDataBlock) (*flacpicture.MetadataBlockPicture, error) {
+	if meta.Type == flac.Picture {
+		if err := checkFLACPictureLengths(meta.Data); err != nil {
+			return nil, err
+		}
+	}
+	return flacpicture.ParseFromMetaDataBlock(meta)
+}
+
+// isImageMIME reports whether a picture block's MIME type is an image.
+func isImageMIME(mimeType string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(mimeType)), "image/")
+}
+
+// isDisposablePicture reports whether booklet cleanup removes a picture
+// block: one whose MIME type is not an image, or a Leaflet Page or Other
+// picture. The front cover is never disposable.
+func isDisposablePicture(pictureType flacpicture.PictureType, mimeType string) bool {
+	if pictureType == flacpicture.PictureTypeFrontCover {
+		return false
+	}
+	return !isImageMIME(mimeType) ||
+		pictureType == flacpicture.PictureTypeLeaflet ||
+		pictureType == flacpicture.PictureTypeOther
+}
+
+// pictureMIME is the declared MIME type of pic, or the one sniffed from its
+// data when none was declared.
+func pictureMIME(pic *flacpicture.MetadataBlockPicture) string {
+	if pic.MIME != "" {
+		return pic.MIME
+	}
+	return detectCoverMIME("", pic.ImageData)
+}
+
+// readPictureBlockHeader reads the picture type and MIME type at the start
+// of the PICTURE block body at offset, without reading the image.
+func readPictureBlockHeader(r io.ReaderAt, offset int64, length int) (flacpicture.PictureType, string, error) {
+	head := make([]byte, 8)
+	if length < len(head) {
+		return 0, "", fmt.Errorf("picture block too short")
+	}
+	if _, err := r.ReadAt(head, offset); err != nil {
+		return 0, "", err
+	}
+	mimeLength := int(binary.BigEndian.Uint32(head[4:]))
+	if mimeLength > length-8 {
+		return 0, "", fmt.Errorf("picture MIME type runs past its block")
+	}
+	mimeType := make([]byte, mimeLength)
+	if _, err := r.ReadAt(mimeType, offset+8); err != nil {
+		return 0, "", err
+	}
+	return flacpicture.PictureType(binary.BigEndian.Uint32(head)), string(mimeType), nil
+}
+
+func pictureTypeName(pictureType int) string {
+	if pictureType >= 0 && pictureType < len(pictureTypeNames) {
+		return pictureTypeNames[pictureType]
+	}
+	return "Unknown"
+}
+
+// readFLACPictures parses every PICTURE block of filePath in file order. A
+// block that fails to parse still takes its index so indices stay stable.
+func readFLACPictures(filePath string) ([]*flacpicture.MetadataBlockPicture, error) {
+	if err := checkIncompleteAudioFile(filePath); err != nil {
+		return nil, err
+	}
+	f, err := flac.ParseFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+	defer f.Close()
+
+	var pictures []*flacpicture.MetadataBlockPicture
+	for _, meta := range f.Meta {
+		if meta.Type != flac.Picture {
+			continue
+		}
+		pic, err := parseFLACPictureMeta(*meta)
+		if err != nil {
+			GoLog("[Metadata] Warning: unreadable picture block in %s: %v\n", filePath, err)
+			pic = nil
+		}
+		pictures = append(pictures, pic)
+	}
+	return pictures, nil
+}
+
+// GetPictures lists every picture block of a FLAC file: type, description,
+// MIME, dimensions and image size. Dimensions missing from the block header
+// are read from the image itself. Unreadable blocks are listed with type -1.
+func GetPictures(filePath string) ([]PictureInfo, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, false, GetPictures)
+	}
+	pictures, err := readFLACPictures(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]PictureInfo, 0, len(pictures))
+	for i, pic := range pictures {
+		if pic == nil {
+			infos = append(infos, PictureInfo{Index: i, Type: -1, TypeName: "Unknown"})
+			continue
+		}
+		info := PictureInfo{
+			Index:       i,
+			Type:        int(pic.PictureType),
+			TypeName:    pictureTypeName(int(pic.PictureType)),
+			Description: pic.Description,
+			MIME:        pic.MIME,
+			Width:       int(pic.Width),
+			Height:      int(pic.Height),
+			ColorDepth:  int(pic.ColorDepth),
+			Size:        len(pic.ImageData),
+		}
+		if info.Width == 0 || info.Height == 0 {
+			if width, height, _, err := coverDimensions(pic.ImageData); err == nil {
+				info.Width, info.Height = width, height
+			}
+		}
+		info.MIME = pictureMIME(pic)
+		info.NonImage = !isImageMIME(info.MIME)
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// ExtractPicture returns the image data and MIME type of the picture at
+// index, as listed by GetPictures.
+func ExtractPicture(filePath string, index int) ([]byte, string, error) {
+	pictures, err := readFLACPictures(filePath)
+	if err != nil {
+		return nil, "", err
+	}
+	if index < 0 || index >= len(pictures) {
+		return nil, "", fmt.Errorf("picture index %d out of range (file has %d)", index, len(pictures))
+	}
+	pic := pictures[index]
+	if pic == nil {
+		return nil, "", fmt.Errorf("picture %d is unreadable", index)
+	}
+	mimeType := pic.MIME
+	if mimeType == "" {
+		mimeType = detectCoverMIME("", pic.ImageData)
+	}
+	return pic.ImageData, mimeType, nil
+}
@@ -0,0 +1,112 @@
+package gobackend
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-flac/flacpicture/v2"
+	"github.com/go-flac/go-flac/v2"
+)
+
+func writeMultiPictureFLAC(t *testing.T, pictures ...*flacpicture.MetadataBlockPicture) string {
+	t.Helper()
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Pictures"})
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	for _, pic := range pictures {
+		block := pic.Marshal()
+		f.Meta = append(f.Meta, &block)
+	}
+	if err := saveFLACAtomic(f, path); err != nil {
+		t.Fatalf("saveFLACAtomic: %v", err)
+	}
+	return path
+}
+
+func TestGetPicturesListsEveryBlockInFileOrder(t *testing.T) {
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	path := writeMultiPictureFLAC(t,
+		&flacpicture.MetadataBlockPicture{PictureType: flacpicture.PictureTypeFrontCover, MIME: "image/png", Description: "Front", Width: 8, Height: 8, ColorDepth: 32, ImageData: cover},
+		// Dimensions left out of the header are read from the image.
+		&flacpicture.MetadataBlockPicture{PictureType: flacpicture.PictureTypeLeaflet, MIME: "image/png", Description: "Booklet p1", ImageData: cover},
+		&flacpicture.MetadataBlockPicture{PictureType: flacpicture.PictureTypeBackCover, MIME: "image/jpeg", ImageData: []byte("not really a jpeg")},
+	)
+
+	pictures, err := GetPictures(path)
+	if err != nil {
+		t.Fatalf("GetPictures: %v", err)
+	}
+	if len(pictures) != 3 {
+		t.Fatalf("expected 3 pictures, got %+v", pictures)
+	}
+	front, booklet, back := pictures[0], pictures[1], pictures[2]
+	if front.Index != 0 || front.TypeName != "Front Cover" || front.Description != "Front" || front.Size != len(cover) || front.ColorDepth != 32 {
+		t.Fatalf("unexpected front cover: %+v", front)
+	}
+	if booklet.Index != 1 || booklet.Type != 5 || booklet.TypeName != "Leaflet Page" || booklet.Width != 8 || booklet.Height != 8 {
+		t.Fatalf("unexpected booklet page: %+v", booklet)
+	}
+	if back.Index != 2 || back.TypeName != "Back Cover" || back.Width != 0 || back.Size != len("not really a jpeg") {
+		t.Fatalf("unexpected back cover: %+v", back)
+	}
+
+	data, mimeType, err := ExtractPicture(path, 2)
+	if err != nil || mimeType != "image/jpeg" || string(data) != "not really a jpeg" {
+		t.Fatalf("ExtractPicture(2) = %q, %q, %v", data, mimeType, err)
+	}
+	if _, _, err := ExtractPicture(path, 3); err == nil {
+		t.Fatal("expected out of range index error")
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "booklet.png")
+	if mimeType, err := ExtractPictureToFile(path, 1, outputPath); err != nil || mimeType != "image/png" {
+		t.Fatalf("ExtractPictureToFile = %q, %v", mimeType, err)
+	}
+	if written, err := os.ReadFile(outputPath); err != nil || !bytes.Equal(written, cover) {
+		t.Fatalf("extracted picture mismatch: %v", err)
+	}
+}
+
+func TestGetPicturesWithoutPictures(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Bare"})
+	pictures, err := GetPictures(path)
+	if err != nil || len(pictures) != 0 {
+		t.Fatalf("GetPictures = %+v, %v", pictures, err)
+	}
+	if raw, err := GetPicturesJSON(path); err != nil || raw != "[]" {
+		t.Fatalf("GetPicturesJSON = %q, %v", raw, err)
+	}
+}
+
+// bookletPictures are a small front cover, a PDF booklet and a leaflet
+// page, as some sources embed them.
+func bookletPictures(t *testing.T) []*flacpicture.MetadataBlockPicture {
+	t.Helper()
+	cover, err := buildSelfTestCover()
+	if err != nil {
+		t.Fatalf("buildSelfTestCover: %v", err)
+	}
+	return []*flacpicture.MetadataBlockPicture{
+		{PictureType: flacpicture.PictureTypeFrontCover, MIME: "image/png", ImageData: cover},
+		{PictureType: flacpicture.PictureTypeLeaflet, MIME: "application/pdf", ImageData: bytes.Repeat([]byte("%PDF"), 2048)},
+		{PictureType: flacpicture.PictureTypeLeaflet, MIME: "image/png", ImageData: cover},
+	}
+}
+
+func TestGetPicturesFlagsNonImageMIME(t *testing.T) {
+	path := writeMultiPictureFLAC(t, bookletPictures(t)...)
+	pictures, err := GetPictures(path)
+	if err != nil || len(pictures) != 3 {
+		t.Fatalf("GetPictures: %+v/%v", pictures, err)
+	}
+	if pictures[0].NonImage || !pictures[1].NonImage || pictures[2].NonImage {
+		t.Fatalf("unexpected non-image flags: %+v", pictures)
+	}
+}
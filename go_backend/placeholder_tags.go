@@ -0,0 +1,189 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Reasons reported in TagQualityIssue.
+const (
+	TagIssueEmpty       = "empty"
+	TagIssuePlaceholder = "placeholder"
+)
+
+// defaultPlaceholderPatterns match whole values, case-insensitively, so
+// "Unknown" is flagged but "Unknown Pleasures" is not.
+var defaultPlaceholderPatterns = []string{
+	`unknown(\s+(artist|album|title|track|genre|composer))?`,
+	`[<\[(]\s*unknown\s*[>\])]`,
+	`(audio\s+)?track\s*\d+`,
+	`untitled(\s+track)?`,
+	`null|nil|none|undefined|n/?a`,
+	`[-?.]+`,
+}
+
+// variousArtistsPattern flags "Various Artists" in ARTIST, which only makes
+// sense as the album artist of a compilation.
+var variousArtistsPattern = regexp.MustCompile(`(?i)^(various(\s+artists)?|va)$`)
+
+// placeholderCheckFields are the ReadFileMetadata/EditFlacFields keys checked
+// for placeholders.
+var placeholderCheckFields = []string{
+	"title", "artist", "album", "album_artist", "genre", "composer", "label",
+}
+
+type TagQualityIssue struct {
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+var (
+	placeholderPatternsMu sync.RWMutex
+	placeholderPatterns   = mustCompilePlaceholderPatterns(defaultPlaceholderPatterns)
+)
+
+func compilePlaceholderPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(`(?i)^(?:` + pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid placeholder pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+func mustCompilePlaceholderPatterns(patterns []string) []*regexp.Regexp {
+	compiled, err := compilePlaceholderPatterns(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return compiled
+}
+
+func setPlaceholderPatterns(compiled []*regexp.Regexp) {
+	placeholderPatternsMu.Lock()
+	placeholderPatterns = compiled
+	placeholderPatternsMu.Unlock()
+}
+
+func isPlaceholderValue(value string) bool {
+	value = strings.TrimSpace(value)
+	placeholderPatternsMu.RLock()
+	defer placeholderPatternsMu.RUnlock()
+	for _, re := range placeholderPatterns {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPlaceholderFields flags blank-but-present and placeholder-looking
+// values among fields. Missing or empty fields are not reported. "Various"
+// in artist is allowed when the album artist marks a compilation.
+func checkPlaceholderFields(fields map[string]string) []TagQualityIssue {
+	var issues []TagQualityIssue
+	compilation := variousArtistsPattern.MatchString(strings.TrimSpace(fields["album_artist"]))
+	for _, field := range placeholderCheckFields {
+		value := fields[field]
+		if value == "" {
+			continue
+		}
+		switch {
+		case strings.TrimSpace(value) == "":
+			issues = append(issues, TagQualityIssue{Field: field, Value: value, Reason: TagIssueEmpty})
+		case isPlaceholderValue(value),
+			field == "artist" && !compilation && variousArtistsPattern.MatchString(strings.TrimSpace(value)):
+			issues = append(issues, TagQualityIssue{Field: field, Value: value, Reason: TagIssuePlaceholder})
+		}
+	}
+	return issues
+}
+
+func placeholderFieldsFromMetadata(metadata *Metadata) map[string]string {
+	return map[string]string{
+		"title":        metadata.Title,
+		"artist":       metadata.Artist,
+		"album":        metadata.Album,
+		"album_artist": metadata.AlbumArtist,
+		"genre":        metadata.Genre,
+		"composer":     metadata.Composer,
+		"label":        metadata.Label,
+	}
+}
+
+// placeholderFieldsFromResult reads the checked fields out of a
+// ReadFileMetadata result map.
+func placeholderFieldsFromResult(result map[string]interface{}) map[string]string {
+	fields := make(map[string]string, len(placeholderCheckFields))
+	for _, field := range placeholderCheckFields {
+		if value, ok := result[field].(string); ok {
+			fields[field] = value
+		}
+	}
+	return fields
+}
+
+func libraryScanQualityIssues(result *LibraryScanResult) []TagQualityIssue {
+	return checkPlaceholderFields(map[string]string{
+		"title":        result.TrackName,
+		"artist":       result.ArtistName,
+		"album":        result.AlbumName,
+		"album_artist": result.AlbumArtist,
+		"genre":        result.Genre,
+		"composer":     result.Composer,
+		"label":        result.Label,
+	})
+}
+
+// QualityCheck returns, as JSON, the empty and placeholder-looking tag
+// values of a FLAC file ("Unknown Artist", "Track 01", "null", ...) so the
+// user can be prompted to fix them. Patterns come from the backend config.
+func QualityCheck(filePath string) (string, error) {
+	metadata, err := ReadMetadata(filePath)
+	if err != nil {
+		return "", err
+	}
+	issues := checkPlaceholderFields(placeholderFieldsFromMetadata(metadata))
+	if issues == nil {
+		issues = []TagQualityIssue{}
+	}
+	jsonBytes, err := json.Marshal(issues)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// ClearPlaceholders clears exactly the fields QualityCheck flags, through
+// EditFlacFields, and returns how many were cleared. A clean file is left
+// untouched.
+func ClearPlaceholders(filePath string) (int, error) {
+	metadata, err := ReadMetadata(filePath)
+	if err != nil {
+		return 0, err
+	}
+	issues := checkPlaceholderFields(placeholderFieldsFromMetadata(metadata))
+	if len(issues) == 0 {
+		return 0, nil
+	}
+	fields := make(map[string]string, len(issues))
+	for _, issue := range issues {
+		fields[issue.Field] = ""
+	}
+	if err := EditFlacFields(filePath, fields); err != nil {
+		return 0, err
+	}
+	GoLog("[Metadata] Cleared %d placeholder tag(s): %s\n", len(issues), filePath)
+	return len(issues), nil
+}
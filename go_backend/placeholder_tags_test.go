@@ -0,0 +1,75 @@
+package gobackend
+
+import "testing"
+
+func TestCheckPlaceholderFields(t *testing.T) {
+	issues := checkPlaceholderFields(map[string]string{
+		"title":  "Track 01",
+		"artist": "UNKNOWN ARTIST",
+		"album":  "Unknown Pleasures",
+		"genre":  "null",
+		"label":  "  ",
+	})
+	got := map[string]string{}
+	for _, issue := range issues {
+		got[issue.Field] = issue.Reason
+	}
+	want := map[string]string{"title": TagIssuePlaceholder, "artist": TagIssuePlaceholder, "genre": TagIssuePlaceholder, "label": TagIssueEmpty}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected issues: %+v", issues)
+	}
+	for field, reason := range want {
+		if got[field] != reason {
+			t.Fatalf("field %s: got %q, want %q (%+v)", field, got[field], reason, issues)
+		}
+	}
+}
+
+func TestCheckPlaceholderFieldsVariousArtists(t *testing.T) {
+	if issues := checkPlaceholderFields(map[string]string{"artist": "Various"}); len(issues) != 1 {
+		t.Fatalf("expected Various in artist to be flagged, got %+v", issues)
+	}
+	if issues := checkPlaceholderFields(map[string]string{"artist": "Various Artists", "album_artist": "Various Artists"}); len(issues) != 0 {
+		t.Fatalf("compilations must not be flagged, got %+v", issues)
+	}
+}
+
+func TestClearPlaceholdersRemovesOnlyFlaggedValues(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Unknown Pleasures", Artist: "Unknown Artist", Album: "n/a", Genre: "Rock"})
+
+	raw, err := QualityCheck(path)
+	issues := mustDecodeJSON[[]TagQualityIssue](t, raw, err)
+	if len(issues) != 2 {
+		t.Fatalf("expected artist and album to be flagged, got %+v", issues)
+	}
+
+	cleared, err := ClearPlaceholders(path)
+	if err != nil || cleared != 2 {
+		t.Fatalf("ClearPlaceholders = %d, %v", cleared, err)
+	}
+	metadata, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if metadata.Artist != "" || metadata.Album != "" || metadata.Title != "Unknown Pleasures" || metadata.Genre != "Rock" {
+		t.Fatalf("unexpected tags after clearing: %+v", metadata)
+	}
+	if cleared, err := ClearPlaceholders(path); err != nil || cleared != 0 {
+		t.Fatalf("second ClearPlaceholders = %d, %v", cleared, err)
+	}
+}
+
+func TestConfigurePlaceholderPatterns(t *testing.T) {
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+
+	if err := Configure(`{"placeholder_patterns":["("]}`); err == nil {
+		t.Fatal("expected invalid pattern to be rejected")
+	}
+	if err := Configure(`{"placeholder_patterns":["tbd"]}`); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if !isPlaceholderValue("TBD") || isPlaceholderValue("null") {
+		t.Fatal("expected configured patterns to replace the defaults")
+	}
+}
@@ -0,0 +1,212 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Quality dimensions CompareQuality can weigh, in BackendConfig
+// QualityPrecedence.
+const (
+	QualityDimensionLossless   = "lossless"
+	QualityDimensionBitDepth   = "bit_depth"
+	QualityDimensionSampleRate = "sample_rate"
+	QualityDimensionBitrate    = "bitrate"
+)
+
+// Results of CompareQuality, describing the candidate against the file.
+const (
+	QualityBetter = "better"
+	QualityEqual  = "equal"
+	QualityWorse  = "worse"
+)
+
+// defaultQualityPrecedence ranks a lossless source above any lossy one, then
+// bit depth over sample rate, so 24/44.1 beats 16/96. The estimated bitrate
+// only separates lossy files.
+var defaultQualityPrecedence = []string{
+	QualityDimensionLossless,
+	QualityDimensionBitDepth,
+	QualityDimensionSampleRate,
+	QualityDimensionBitrate,
+}
+
+func validateQualityPrecedence(precedence []string) error {
+	seen := make(map[string]bool)
+	for _, dimension := range precedence {
+		switch dimension {
+		case QualityDimensionLossless, QualityDimensionBitDepth, QualityDimensionSampleRate, QualityDimensionBitrate:
+		default:
+			return fmt.Errorf("unknown quality dimension: %q", dimension)
+		}
+		if seen[dimension] {
+			return fmt.Errorf("duplicate quality dimension: %q", dimension)
+		}
+		seen[dimension] = true
+	}
+	return nil
+}
+
+func qualityPrecedence() []string {
+	if precedence := GetBackendConfig().QualityPrecedence; precedence != nil {
+		return precedence
+	}
+	return defaultQualityPrecedence
+}
+
+// QualityComparison is the result of CompareQuality. Dimension names what
+// decided a better or worse result and is empty when the two are equal.
+type QualityComparison struct {
+	Result    string       `json:"result"`
+	Dimension string       `json:"dimension,omitempty"`
+	Existing  AudioQuality `json:"existing"`
+	Candidate AudioQuality `json:"candidate"`
+}
+
+// qualityDimensionValue returns the value of one dimension, and false when
+// it is unknown for q. Only lossy streams lack a bit depth, so that stands
+// for lossless.
+func qualityDimensionValue(q AudioQuality, dimension string) (int, bool) {
+	switch dimension {
+	case QualityDimensionLossless:
+		if q.BitDepth > 0 {
+			return 1, true
+		}
+		return 0, true
+	case QualityDimensionBitDepth:
+		return q.BitDepth, q.BitDepth > 0
+	case QualityDimensionSampleRate:
+		return q.SampleRate, q.SampleRate > 0
+	case QualityDimensionBitrate:
+		// Lossless bitrates say more about the encoder than the source.
+		return q.Bitrate, q.Bitrate > 0 && q.BitDepth == 0
+	}
+	return 0, false
+}
+
+// compareAudioQuality walks precedence and stops at the first dimension
+// known on both sides that differs. Dimensions unknown on either side are
+// skipped rather than guessed.
+func compareAudioQuality(existing, candidate AudioQuality, precedence []string) (string, string) {
+	for _, dimension := range precedence {
+		a, okA := qualityDimensionValue(existing, dimension)
+		b, okB := qualityDimensionValue(candidate, dimension)
+		if !okA || !okB || a == b {
+			continue
+		}
+		if b > a {
+			return QualityBetter, dimension
+		}
+		return QualityWorse, dimension
+	}
+	return QualityEqual, ""
+}
+
+// CompareQuality reports whether candidate is better, equal or worse than
+// the audio of existingPath, and which dimension decided it, ranking
+// dimensions by BackendConfig QualityPrecedence.
+func CompareQuality(existingPath string, candidate AudioQuality) (string, error) {
+	existing, err := GetAudioQuality(existingPath)
+	if err != nil {
+		return "", err
+	}
+	result, dimension := compareAudioQuality(existing, candidate, qualityPrecedence())
+	jsonBytes, err := json.Marshal(QualityComparison{
+		Result:    result,
+		Dimension: dimension,
+		Existing:  existing,
+		Candidate: candidate,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// UpgradeCatalogTrack is one catalog entry passed to FindUpgradeCandidates.
+type UpgradeCatalogTrack struct {
+	ISRC       string       `json:"isrc"`
+	TrackName  string       `json:"track_name,omitempty"`
+	ArtistName string       `json:"artist_name,omitempty"`
+	Quality    AudioQuality `json:"quality"`
+}
+
+// UpgradeCandidate is an owned track the catalog offers in better quality.
+// Path is the best copy on disk; Dimension is what makes the catalog
+// version better than it.
+type UpgradeCandidate struct {
+	ISRC       string       `json:"isrc"`
+	TrackName  string       `json:"track_name,omitempty"`
+	ArtistName string       `json:"artist_name,omitempty"`
+	Path       string       `json:"path"`
+	Existing   AudioQuality `json:"existing"`
+	Available  AudioQuality `json:"available"`
+	Dimension  string       `json:"dimension"`
+}
+
+type UpgradeCandidatesReport struct {
+	Candidates   []UpgradeCandidate `json:"candidates"`
+	CatalogCount int                `json:"catalog_count"`
+	Owned        int                `json:"owned"`
+}
+
+// FindUpgradeCandidates matches the tracks of catalogJSON, a JSON array of
+// UpgradeCatalogTrack, to the FLAC files under rootPath by ISRC and lists
+// those whose catalog quality beats every copy already on disk.
+func FindUpgradeCandidates(rootPath, catalogJSON string) (string, error) {
+	if strings.TrimSpace(rootPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(rootPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+	var catalog []UpgradeCatalogTrack
+	if err := json.Unmarshal([]byte(catalogJSON), &catalog); err != nil {
+		return "", fmt.Errorf("failed to parse catalog JSON: %w", err)
+	}
+
+	idx, _, err := indexISRCFiles(rootPath)
+	if err != nil {
+		return "", err
+	}
+
+	precedence := qualityPrecedence()
+	report := UpgradeCandidatesReport{Candidates: []UpgradeCandidate{}, CatalogCount: len(catalog)}
+	for _, track := range catalog {
+		entries := idx.ISRC[strings.ToUpper(strings.TrimSpace(track.ISRC))]
+		if len(entries) == 0 {
+			continue
+		}
+		report.Owned++
+
+		best := entries[0]
+		for _, entry := range entries[1:] {
+			if result, _ := compareAudioQuality(best.Quality, entry.Quality, precedence); result == QualityBetter {
+				best = entry
+			}
+		}
+		result, dimension := compareAudioQuality(best.Quality, track.Quality, precedence)
+		if result != QualityBetter {
+			continue
+		}
+		report.Candidates = append(report.Candidates, UpgradeCandidate{
+			ISRC:       best.ISRC,
+			TrackName:  track.TrackName,
+			ArtistName: track.ArtistName,
+			Path:       best.Path,
+			Existing:   best.Quality,
+			Available:  track.Quality,
+			Dimension:  dimension,
+		})
+	}
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
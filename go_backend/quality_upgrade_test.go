@@ -0,0 +1,72 @@
+package gobackend
+
+import "testing"
+
+func TestCompareAudioQualityPrecedence(t *testing.T) {
+	cd := AudioQuality{BitDepth: 16, SampleRate: 44100}
+	hiRes := AudioQuality{BitDepth: 24, SampleRate: 96000}
+	deep := AudioQuality{BitDepth: 24, SampleRate: 44100}
+	fast := AudioQuality{BitDepth: 16, SampleRate: 96000}
+	aac := AudioQuality{SampleRate: 44100, Bitrate: 256, Codec: "aac"}
+
+	cases := []struct {
+		existing, candidate AudioQuality
+		precedence          []string
+		result, dimension   string
+	}{
+		{cd, hiRes, defaultQualityPrecedence, QualityBetter, QualityDimensionBitDepth},
+		{hiRes, cd, defaultQualityPrecedence, QualityWorse, QualityDimensionBitDepth},
+		{cd, cd, defaultQualityPrecedence, QualityEqual, ""},
+		{fast, deep, defaultQualityPrecedence, QualityBetter, QualityDimensionBitDepth},
+		{deep, fast, []string{QualityDimensionSampleRate, QualityDimensionBitDepth}, QualityBetter, QualityDimensionSampleRate},
+		{aac, cd, defaultQualityPrecedence, QualityBetter, QualityDimensionLossless},
+		{aac, AudioQuality{SampleRate: 44100, Bitrate: 320}, defaultQualityPrecedence, QualityBetter, QualityDimensionBitrate},
+		{cd, AudioQuality{}, defaultQualityPrecedence, QualityWorse, QualityDimensionLossless},
+	}
+	for i, c := range cases {
+		result, dimension := compareAudioQuality(c.existing, c.candidate, c.precedence)
+		if result != c.result || dimension != c.dimension {
+			t.Fatalf("case %d: got %s/%s, want %s/%s", i, result, dimension, c.result, c.dimension)
+		}
+	}
+}
+
+func TestQualityPrecedenceConfig(t *testing.T) {
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+
+	if err := Configure(`{"quality_precedence":["sample_rate","loudness"]}`); err == nil {
+		t.Fatal("expected unknown dimension to be rejected")
+	}
+	if err := Configure(`{"quality_precedence":["sample_rate","bit_depth"]}`); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	out, err := CompareQuality(path, AudioQuality{BitDepth: 24, SampleRate: 44100})
+	comparison := mustDecodeJSON[QualityComparison](t, out, err)
+	if comparison.Result != QualityBetter || comparison.Dimension != QualityDimensionBitDepth ||
+		comparison.Existing.BitDepth != 16 {
+		t.Fatalf("unexpected comparison: %+v", comparison)
+	}
+}
+
+func TestFindUpgradeCandidates(t *testing.T) {
+	root := t.TempDir()
+	owned := writeConsistencyFixture(t, root, "owned.flac", Metadata{Title: "Owned", ISRC: "USRC17607839"})
+	writeConsistencyFixture(t, root, "same.flac", Metadata{Title: "Same", ISRC: "GBAYE0000001"})
+
+	catalog := `[
+		{"isrc":"usrc17607839","track_name":"Owned","quality":{"bit_depth":24,"sample_rate":96000}},
+		{"isrc":"GBAYE0000001","quality":{"bit_depth":16,"sample_rate":44100}},
+		{"isrc":"NOTOWNED0001","quality":{"bit_depth":24,"sample_rate":192000}}
+	]`
+	out, err := FindUpgradeCandidates(root, catalog)
+	report := mustDecodeJSON[UpgradeCandidatesReport](t, out, err)
+	if report.CatalogCount != 3 || report.Owned != 2 || len(report.Candidates) != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	candidate := report.Candidates[0]
+	if candidate.Path != owned || candidate.Dimension != QualityDimensionBitDepth || candidate.Existing.BitDepth != 16 {
+		t.Fatalf("unexpected candidate: %+v", candidate)
+	}
+}
@@ -0,0 +1,307 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const quarantineFileName = "quarantine.json"
+
+// errQuarantineUnavailable means no DataDir is configured, so nothing is
+// quarantined and every file is processed as before.
+var errQuarantineUnavailable = errors.New("quarantine needs a data directory")
+
+// QuarantineEntry is a file found structurally broken. It is skipped by the
+// batch operations that modify files while its modification time (Unix
+// milliseconds, as in library scans) is still ModTime; any change to the
+// file releases it.
+type QuarantineEntry struct {
+	Path          string `json:"path"`
+	Reason        string `json:"reason"`
+	Source        string `json:"source"`
+	ModTime       int64  `json:"mod_time"`
+	QuarantinedAt int64  `json:"quarantined_at"`
+}
+
+// QuarantineRetryReport is the result of RetryQuarantined. Released files
+// now parse and are back in batch operations; missing files were dropped.
+type QuarantineRetryReport struct {
+	Released    []string          `json:"released"`
+	StillBroken int               `json:"still_broken"`
+	Missing     int               `json:"missing"`
+	Entries     []QuarantineEntry `json:"entries"`
+}
+
+var quarantineMu sync.Mutex
+
+func quarantinePath() (string, error) {
+	dataDir := GetBackendConfig().DataDir
+	if dataDir == "" {
+		return "", errQuarantineUnavailable
+	}
+	return filepath.Join(dataDir, quarantineFileName), nil
+}
+
+// loadQuarantineLocked reads the quarantine list keyed by path.
+// quarantineMu must be held.
+func loadQuarantineLocked() (map[string]QuarantineEntry, error) {
+	path, err := quarantinePath()
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]QuarantineEntry)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quarantine: %w", err)
+	}
+	var list []QuarantineEntry
+	if err := json.Unmarshal(data, &list); err != nil {
+		GoLog("[Quarantine] Discarding unreadable list: %v\n", err)
+		return entries, nil
+	}
+	for _, entry := range list {
+		entries[entry.Path] = entry
+	}
+	return entries, nil
+}
+
+func sortedQuarantineEntries(entries map[string]QuarantineEntry) []QuarantineEntry {
+	list := make([]QuarantineEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+// saveQuarantineLocked writes the list atomically. quarantineMu must be
+// held.
+func saveQuarantineLocked(entries map[string]QuarantineEntry) error {
+	path, err := quarantinePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	data, err := json.Marshal(sortedQuarantineEntries(entries))
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write quarantine: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write quarantine: %w", err)
+	}
+	return nil
+}
+
+// brokenFLACReason returns why a verifyFLAC result shows the file is
+// structurally broken, or "" when it is not.
+func brokenFLACReason(report *FLACVerifyReport, err error) string {
+	switch {
+	case err != nil:
+		return err.Error()
+	case report.Valid:
+		return ""
+	case len(report.BlockIssues) > 0:
+		return report.BlockIssues[0]
+	case len(report.BadFrames) > 0:
+		return report.BadFrames[0].Reason
+	}
+	return "FLAC structure is invalid"
+}
+
+// diagnoseBrokenFLAC runs the quick VerifyFLAC checks on filePath and
+// returns why it is structurally broken, or "" when it is not or cannot be
+// opened at all, which is not the file's fault.
+func diagnoseBrokenFLAC(filePath string) string {
+	if _, err := os.Stat(filePath); err != nil {
+		return ""
+	}
+	return brokenFLACReason(verifyFLAC(filePath, false))
+}
+
+// quarantineFile records filePath as broken. Without a DataDir, or for a
+// file that is gone, it does nothing.
+func quarantineFile(filePath, reason, source string) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return
+	}
+	quarantineMu.Lock()
+	defer quarantineMu.Unlock()
+	entries, err := loadQuarantineLocked()
+	if err != nil {
+		return
+	}
+	entries[filePath] = QuarantineEntry{
+		Path:          filePath,
+		Reason:        reason,
+		Source:        source,
+		ModTime:       info.ModTime().UnixMilli(),
+		QuarantinedAt: time.Now().Unix(),
+	}
+	if err := saveQuarantineLocked(entries); err != nil {
+		GoLog("[Quarantine] Failed to record %s: %v\n", filePath, err)
+		return
+	}
+	GoLog("[Quarantine] %s: %s\n", filePath, reason)
+}
+
+// quarantineIfBroken quarantines a FLAC file that failed to parse when
+// VerifyFLAC confirms it is structurally broken.
+func quarantineIfBroken(filePath, source string) {
+	if GetBackendConfig().DataDir == "" || !strings.EqualFold(filepath.Ext(filePath), ".flac") {
+		return
+	}
+	if reason := diagnoseBrokenFLAC(filePath); reason != "" {
+		quarantineFile(filePath, reason, source)
+	}
+}
+
+// collectWritableLibraryFiles is collectLibraryAudioFiles without the files
+// still quarantined, for the batch operations that modify files. Read-only
+// reports and cleanups see every file. It also returns how many were left
+// out, which the operations report as quarantined_skipped.
+func collectWritableLibraryFiles(folderPath string, cancelCh <-chan struct{}) ([]libraryAudioFileInfo, int, error) {
+	files, err := collectLibraryAudioFiles(folderPath, cancelCh)
+	if err != nil {
+		return nil, 0, err
+	}
+	files, skipped := skipQuarantinedFiles(folderPath, files)
+	return files, skipped, nil
+}
+
+// skipQuarantinedFiles drops the files still quarantined from a library
+// walk and returns how many it dropped. Entries for walked files whose
+// modification time changed are released so the file is looked at again.
+func skipQuarantinedFiles(rootPath string, files []libraryAudioFileInfo) ([]libraryAudioFileInfo, int) {
+	quarantineMu.Lock()
+	defer quarantineMu.Unlock()
+	entries, err := loadQuarantineLocked()
+	if err != nil || len(entries) == 0 {
+		return files, 0
+	}
+
+	kept := files[:0]
+	skipped, released := 0, 0
+	for _, file := range files {
+		entry, ok := entries[file.path]
+		switch {
+		case !ok:
+			kept = append(kept, file)
+		case entry.ModTime == file.modTime:
+			skipped++
+		default:
+			delete(entries, file.path)
+			released++
+			kept = append(kept, file)
+		}
+	}
+	if released > 0 {
+		if err := saveQuarantineLocked(entries); err != nil {
+			GoLog("[Quarantine] Failed to release changed files: %v\n", err)
+		}
+	}
+	if skipped > 0 {
+		GoLog("[Quarantine] Skipping %d quarantined files under %s\n", skipped, rootPath)
+	}
+	return kept, skipped
+}
+
+// ListQuarantine returns the quarantined files as a JSON array sorted by
+// path.
+func ListQuarantine() (string, error) {
+	quarantineMu.Lock()
+	entries, err := loadQuarantineLocked()
+	quarantineMu.Unlock()
+	if errors.Is(err, errQuarantineUnavailable) {
+		return "[]", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(sortedQuarantineEntries(entries))
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// RetryQuarantined checks every quarantined file again. Files that now pass
+// VerifyFLAC and parse are released, missing files are dropped, and the
+// rest stay quarantined with their reason and modification time updated.
+func RetryQuarantined() (string, error) {
+	quarantineMu.Lock()
+	defer quarantineMu.Unlock()
+	entries, err := loadQuarantineLocked()
+	if err != nil {
+		return "", err
+	}
+
+	report := QuarantineRetryReport{Released: []string{}}
+	for path, entry := range entries {
+		info, err := os.Stat(path)
+		if err != nil {
+			delete(entries, path)
+			report.Missing++
+			continue
+		}
+		reason := diagnoseBrokenFLAC(path)
+		if reason == "" {
+			if _, err := readMetadataUncached(path); err != nil {
+				reason = err.Error()
+			}
+		}
+		if reason == "" {
+			delete(entries, path)
+			report.Released = append(report.Released, path)
+			continue
+		}
+		entry.Reason, entry.ModTime = reason, info.ModTime().UnixMilli()
+		entries[path] = entry
+		report.StillBroken++
+	}
+	if err := saveQuarantineLocked(entries); err != nil {
+		return "", err
+	}
+	sort.Strings(report.Released)
+	report.Entries = sortedQuarantineEntries(entries)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// RemoveFromQuarantine releases filePath without checking it, so batch
+// operations include it again. Removing a file that is not quarantined is
+// not an error.
+func RemoveFromQuarantine(filePath string) error {
+	quarantineMu.Lock()
+	defer quarantineMu.Unlock()
+	entries, err := loadQuarantineLocked()
+	if err != nil {
+		return err
+	}
+	if _, ok := entries[filePath]; !ok {
+		return nil
+	}
+	delete(entries, filePath)
+	return saveQuarantineLocked(entries)
+}
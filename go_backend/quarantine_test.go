@@ -0,0 +1,124 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func configureQuarantineTest(t *testing.T) {
+	t.Helper()
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	cfg := original
+	cfg.DataDir = t.TempDir()
+	if err := SetBackendConfig(cfg); err != nil {
+		t.Fatalf("SetBackendConfig: %v", err)
+	}
+}
+
+// writeBrokenFLAC writes a fixture cut off inside its metadata blocks.
+func writeBrokenFLAC(t *testing.T, root, name string) (string, []byte) {
+	t.Helper()
+	good := mustReadFile(t, writeTestFLACWithMetadata(t, Metadata{Title: "Broken"}))
+	path := filepath.Join(root, name)
+	if err := os.WriteFile(path, good[:60], 0644); err != nil {
+		t.Fatalf("write broken fixture: %v", err)
+	}
+	return path, good
+}
+
+func listQuarantineEntries(t *testing.T) []QuarantineEntry {
+	t.Helper()
+	out, err := ListQuarantine()
+	entries := mustDecodeJSON[[]QuarantineEntry](t, out, err)
+	return entries
+}
+
+func TestBrokenFileIsQuarantinedAndSkipped(t *testing.T) {
+	configureQuarantineTest(t)
+	root := t.TempDir()
+	writeConsistencyFixture(t, root, "good.flac", Metadata{Title: "Good", Genre: "HipHop"})
+	broken, _ := writeBrokenFLAC(t, root, "broken.flac")
+
+	if _, err := ReadMetadata(broken); err == nil {
+		t.Fatal("expected ReadMetadata to fail on a truncated file")
+	}
+	entries := listQuarantineEntries(t)
+	if len(entries) != 1 || entries[0].Path != broken || entries[0].Source != "read_metadata" || entries[0].Reason == "" {
+		t.Fatalf("unexpected quarantine: %+v", entries)
+	}
+
+	files, quarantined, err := collectWritableLibraryFiles(root, nil)
+	if err != nil {
+		t.Fatalf("collectWritableLibraryFiles: %v", err)
+	}
+	if len(files) != 1 || quarantined != 1 || filepath.Base(files[0].path) != "good.flac" {
+		t.Fatalf("quarantined file not skipped: %+v, %d", files, quarantined)
+	}
+	var genres GenreNormalizationReport
+	if out, err := NormalizeGenres(root, true); err != nil || json.Unmarshal([]byte(out), &genres) != nil || genres.QuarantinedSkipped != 1 {
+		t.Fatalf("NormalizeGenres = %s, %v", out, err)
+	}
+
+	// Read-only walks and cleanups still see the quarantined file.
+	if all, _ := collectLibraryAudioFiles(root, nil); len(all) != 2 {
+		t.Fatalf("read-only walk skipped a quarantined file: %+v", all)
+	}
+	var cleanup IncompleteCleanupReport
+	if out, err := CleanupIncomplete(root, 0, false); err != nil || json.Unmarshal([]byte(out), &cleanup) != nil || cleanup.Checked != 2 {
+		t.Fatalf("CleanupIncomplete = %s, %v", out, err)
+	}
+
+	// Touching the file releases it for the next batch.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(broken, later, later); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	if files, quarantined, _ = collectWritableLibraryFiles(root, nil); len(files) != 2 || quarantined != 0 {
+		t.Fatalf("changed file should be processed again: %+v", files)
+	}
+	if entries := listQuarantineEntries(t); len(entries) != 0 {
+		t.Fatalf("changed file should leave the quarantine: %+v", entries)
+	}
+}
+
+func TestRetryAndRemoveQuarantined(t *testing.T) {
+	configureQuarantineTest(t)
+	root := t.TempDir()
+	fixed, good := writeBrokenFLAC(t, root, "fixed.flac")
+	still, _ := writeBrokenFLAC(t, root, "still.flac")
+	gone, _ := writeBrokenFLAC(t, root, "gone.flac")
+	for _, path := range []string{fixed, still, gone} {
+		if _, err := VerifyFLAC(path, false); err != nil {
+			t.Fatalf("VerifyFLAC: %v", err)
+		}
+	}
+	if entries := listQuarantineEntries(t); len(entries) != 3 || entries[0].Source != "verify_flac" {
+		t.Fatalf("expected VerifyFLAC to quarantine all three: %+v", entries)
+	}
+
+	if err := os.WriteFile(fixed, good, 0644); err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+	if err := os.Remove(gone); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	out, err := RetryQuarantined()
+	report := mustDecodeJSON[QuarantineRetryReport](t, out, err)
+	if len(report.Released) != 1 || report.Released[0] != fixed || report.StillBroken != 1 || report.Missing != 1 {
+		t.Fatalf("unexpected retry report: %+v", report)
+	}
+
+	if err := RemoveFromQuarantine(still); err != nil {
+		t.Fatalf("RemoveFromQuarantine: %v", err)
+	}
+	if err := RemoveFromQuarantine(still); err != nil {
+		t.Fatalf("removing twice should not fail: %v", err)
+	}
+	if entries := listQuarantineEntries(t); len(entries) != 0 {
+		t.Fatalf("expected empty quarantine, got %+v", entries)
+	}
+}
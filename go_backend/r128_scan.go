@@ -0,0 +1,309 @@
+package gobackend
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// ITU-R BS.1770 gating, as used by EBU R128 and ReplayGain 2.0.
+const (
+	r128BlockMs          = 400
+	r128BlockStepMs      = 100
+	r128AbsoluteGateLUFS = -70.0
+	r128RelativeGateLU   = -10.0
+	// Block loudness is kept as a histogram of r128HistogramStepLU wide bins
+	// from the absolute gate up to r128HistogramMaxLUFS, so a scan's state
+	// stays small however long the audio is and albums merge by adding
+	// counts.
+	r128HistogramStepLU  = 0.1
+	r128HistogramMaxLUFS = 30.0
+	r128HistogramBins    = int((r128HistogramMaxLUFS - r128AbsoluteGateLUFS) / r128HistogramStepLU)
+	// r128TruePeakTaps is the length of each oversampling filter phase.
+	r128TruePeakTaps = 12
+)
+
+// r128Loudness converts a mean square to LUFS.
+func r128Loudness(energy float64) float64 {
+	return -0.691 + 10*math.Log10(energy)
+}
+
+func r128Energy(lufs float64) float64 {
+	return math.Pow(10, (lufs+0.691)/10)
+}
+
+// r128ChannelWeights are the BS.1770 weights in FLAC channel order: LFE is
+// left out and the surround channels count 1.41 times.
+func r128ChannelWeights(channels int) []float64 {
+	weights := make([]float64, channels)
+	for i := range weights {
+		weights[i] = 1
+	}
+	switch channels {
+	case 4: // FL FR BL BR
+		weights[2], weights[3] = 1.41, 1.41
+	case 5: // FL FR FC BL BR
+		weights[3], weights[4] = 1.41, 1.41
+	case 6: // FL FR FC LFE BL BR
+		weights[3], weights[4], weights[5] = 0, 1.41, 1.41
+	case 7: // FL FR FC LFE BC SL SR
+		weights[3], weights[4], weights[5], weights[6] = 0, 1.41, 1.41, 1.41
+	case 8: // FL FR FC LFE BL BR SL SR
+		weights[3] = 0
+		for i := 4; i < 8; i++ {
+			weights[i] = 1.41
+		}
+	}
+	return weights
+}
+
+// r128Biquad is one direct form II transposed filter section.
+type r128Biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+func (f *r128Biquad) process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// r128KWeighting returns the high-shelf and high-pass sections of the K
+// filter for sampleRate, derived from the 48 kHz reference as libebur128
+// does.
+func r128KWeighting(sampleRate int) (r128Biquad, r128Biquad) {
+	rate := float64(sampleRate)
+
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / rate)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := r128Biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / rate)
+	a0 = 1 + k/q + k*k
+	highPass := r128Biquad{
+		b0: 1, b1: -2, b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return shelf, highPass
+}
+
+// r128Oversampler estimates the true peak of one channel by interpolating
+// factor-1 points between samples with a windowed-sinc polyphase filter.
+type r128Oversampler struct {
+	phases  [][]float64
+	history []float64
+	pos     int
+}
+
+func newR128Oversampler(factor int) *r128Oversampler {
+	o := &r128Oversampler{history: make([]float64, r128TruePeakTaps)}
+	length := r128TruePeakTaps * factor
+	center := float64(length-1) / 2
+	for phase := range factor {
+		coeffs := make([]float64, r128TruePeakTaps)
+		for tap := range coeffs {
+			n := tap*factor + phase
+			x := (float64(n) - center) / float64(factor)
+			sinc := 1.0
+			if x != 0 {
+				sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+			}
+			window := 0.5 - 0.5*math.Cos(2*math.Pi*(float64(n)+0.5)/float64(length))
+			coeffs[tap] = sinc * window
+		}
+		o.phases = append(o.phases, coeffs)
+	}
+	return o
+}
+
+// peak adds x and returns the largest magnitude among the interpolated
+// points it completes.
+func (o *r128Oversampler) peak(x float64) float64 {
+	o.history[o.pos] = x
+	o.pos = (o.pos + 1) % len(o.history)
+	var best float64
+	for _, coeffs := range o.phases {
+		var y float64
+		for tap, c := range coeffs {
+			y += c * o.history[(o.pos+len(o.history)-1-tap)%len(o.history)]
+		}
+		best = max(best, math.Abs(y))
+	}
+	return best
+}
+
+// r128Scan is the gating state of one or more scanned tracks: how many
+// 400 ms blocks fell into each loudness bin above the absolute gate, and
+// the highest true peak.
+type r128Scan struct {
+	// Histogram maps a bin index to its block count; bin i covers
+	// r128AbsoluteGateLUFS + i*r128HistogramStepLU upwards.
+	Histogram map[int]int64 `json:"histogram,omitempty"`
+	TruePeak  float64       `json:"true_peak"`
+	Seconds   float64       `json:"seconds"`
+}
+
+func (s *r128Scan) addBlock(lufs float64) {
+	if lufs < r128AbsoluteGateLUFS || math.IsNaN(lufs) {
+		return
+	}
+	bin := min(int((lufs-r128AbsoluteGateLUFS)/r128HistogramStepLU), r128HistogramBins-1)
+	if s.Histogram == nil {
+		s.Histogram = make(map[int]int64)
+	}
+	s.Histogram[bin]++
+}
+
+// merge adds other to s, as for the tracks of an album.
+func (s *r128Scan) merge(other *r128Scan) {
+	for bin, count := range other.Histogram {
+		if s.Histogram == nil {
+			s.Histogram = make(map[int]int64)
+		}
+		s.Histogram[bin] += count
+	}
+	s.TruePeak = max(s.TruePeak, other.TruePeak)
+	s.Seconds += other.Seconds
+}
+
+// integrated is the gated loudness in LUFS. It returns false when no block
+// passes the absolute gate, as for digital silence.
+func (s *r128Scan) integrated() (float64, bool) {
+	binEnergy := func(bin int) float64 {
+		return r128Energy(r128AbsoluteGateLUFS + (float64(bin)+0.5)*r128HistogramStepLU)
+	}
+	gatedMean := func(fromBin int) (float64, bool) {
+		var energy float64
+		var count int64
+		for bin, n := range s.Histogram {
+			if bin >= fromBin {
+				energy += binEnergy(bin) * float64(n)
+				count += n
+			}
+		}
+		if count == 0 {
+			return 0, false
+		}
+		return energy / float64(count), true
+	}
+
+	mean, ok := gatedMean(0)
+	if !ok {
+		return 0, false
+	}
+	threshold := r128Loudness(mean) + r128RelativeGateLU
+	fromBin := max(int(math.Ceil((threshold-r128AbsoluteGateLUFS)/r128HistogramStepLU)), 0)
+	if mean, ok = gatedMean(fromBin); !ok {
+		return 0, false
+	}
+	return r128Loudness(mean), true
+}
+
+// truePeakDBTP is the true peak in dB relative to full scale.
+func (s *r128Scan) truePeakDBTP() float64 {
+	if s.TruePeak <= 0 {
+		return audioSilentPeakDBFS
+	}
+	return max(20*math.Log10(s.TruePeak), audioSilentPeakDBFS)
+}
+
+// scanR128 decodes filePath and measures its BS.1770 block loudness and
+// true peak. Audio below 96 kHz is oversampled four times for the true
+// peak, below 192 kHz twice.
+func scanR128(filePath string) (*r128Scan, error) {
+	decoder, f, err := openFLACDecoder(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	format := decoder.format
+	if format.BitsPerSample <= 0 || format.BitsPerSample > 32 || format.SampleRate <= 0 || format.Channels <= 0 {
+		return nil, fmt.Errorf("unsupported format: %d bits at %d Hz", format.BitsPerSample, format.SampleRate)
+	}
+	fullScale := float64(int64(1) << (format.BitsPerSample - 1))
+
+	factor := 1
+	switch {
+	case format.SampleRate < 96000:
+		factor = 4
+	case format.SampleRate < 192000:
+		factor = 2
+	}
+	weights := r128ChannelWeights(format.Channels)
+	shelves := make([]r128Biquad, format.Channels)
+	highPasses := make([]r128Biquad, format.Channels)
+	oversamplers := make([]*r128Oversampler, format.Channels)
+	for ch := range shelves {
+		shelves[ch], highPasses[ch] = r128KWeighting(format.SampleRate)
+		if factor > 1 {
+			oversamplers[ch] = newR128Oversampler(factor)
+		}
+	}
+
+	// A block is four steps; the last four step energies are kept.
+	stepLen := max(format.SampleRate*r128BlockStepMs/1000, 1)
+	stepsPerBlock := r128BlockMs / r128BlockStepMs
+	steps := make([]float64, 0, stepsPerBlock)
+	var stepEnergy float64
+	var stepFill int
+	var frames int64
+
+	scan := &r128Scan{}
+	for {
+		samples, err := decoder.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode frame after sample %d: %w", frames, err)
+		}
+		if len(samples) != format.Channels {
+			return nil, fmt.Errorf("frame after sample %d has %d channels, not %d", frames, len(samples), format.Channels)
+		}
+		for i := range samples[0] {
+			for ch, channel := range samples {
+				x := float64(channel[i]) / fullScale
+				if oversamplers[ch] != nil {
+					scan.TruePeak = max(scan.TruePeak, oversamplers[ch].peak(x))
+				}
+				scan.TruePeak = max(scan.TruePeak, math.Abs(x))
+				if weights[ch] != 0 {
+					y := highPasses[ch].process(shelves[ch].process(x))
+					stepEnergy += weights[ch] * y * y
+				}
+			}
+			stepFill++
+			if stepFill < stepLen {
+				continue
+			}
+			if len(steps) == stepsPerBlock {
+				steps = append(steps[:0], steps[1:]...)
+			}
+			steps = append(steps, stepEnergy)
+			stepEnergy, stepFill = 0, 0
+			if len(steps) == stepsPerBlock {
+				var block float64
+				for _, e := range steps {
+					block += e
+				}
+				scan.addBlock(r128Loudness(block / float64(stepLen*stepsPerBlock)))
+			}
+		}
+		frames += int64(len(samples[0]))
+	}
+	scan.Seconds = float64(frames) / float64(format.SampleRate)
+	return scan, nil
+}
@@ -0,0 +1,107 @@
+package gobackend
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSineFLAC writes seconds of a 997 Hz sine at amplitude (of full scale)
+// on every channel, as a 48 kHz 16-bit FLAC at path.
+func writeSineFLAC(t *testing.T, path string, channels int, amplitude, seconds float64) {
+	t.Helper()
+	const rate = 48000
+	samples := make([][]int32, channels)
+	for ch := range samples {
+		samples[ch] = make([]int32, int(seconds*rate))
+		for i := range samples[ch] {
+			samples[ch][i] = int32(amplitude * 32767 * math.Sin(2*math.Pi*997*float64(i)/rate))
+		}
+	}
+	data, err := encodeVerbatimFLAC(samples, rate, 16, 4096)
+	if err != nil {
+		t.Fatalf("encodeVerbatimFLAC: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func TestScanR128Sine(t *testing.T) {
+	// A stereo 997 Hz sine of peak level L dBFS measures L LUFS (EBU Tech
+	// 3341); the histogram bins allow 0.1 LU.
+	for _, c := range []struct {
+		amplitude, lufs float64
+	}{{0.1, -20}, {0.5, -6.02}} {
+		path := filepath.Join(t.TempDir(), "sine.flac")
+		writeSineFLAC(t, path, 2, c.amplitude, 3)
+		scan, err := scanR128(path)
+		if err != nil {
+			t.Fatalf("scanR128: %v", err)
+		}
+		lufs, ok := scan.integrated()
+		if !ok || math.Abs(lufs-c.lufs) > 0.1 {
+			t.Fatalf("amplitude %v: integrated = %v, %v; want %v", c.amplitude, lufs, ok, c.lufs)
+		}
+		if peak := 20 * math.Log10(c.amplitude); math.Abs(scan.truePeakDBTP()-peak) > 0.1 {
+			t.Fatalf("amplitude %v: true peak = %v dBTP, want %v", c.amplitude, scan.truePeakDBTP(), peak)
+		}
+		if scan.Seconds != 3 {
+			t.Fatalf("seconds = %v", scan.Seconds)
+		}
+	}
+
+	// One channel of the same sine is 3 LU quieter than two.
+	mono := filepath.Join(t.TempDir(), "mono.flac")
+	writeSineFLAC(t, mono, 1, 0.1, 3)
+	scan, err := scanR128(mono)
+	if err != nil {
+		t.Fatalf("scanR128 mono: %v", err)
+	}
+	if lufs, ok := scan.integrated(); !ok || math.Abs(lufs+23.01) > 0.1 {
+		t.Fatalf("mono integrated = %v, %v", lufs, ok)
+	}
+}
+
+func TestScanR128Silence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "silence.flac")
+	writeSineFLAC(t, path, 2, 0, 1)
+	scan, err := scanR128(path)
+	if err != nil {
+		t.Fatalf("scanR128: %v", err)
+	}
+	if lufs, ok := scan.integrated(); ok || scan.Histogram != nil || scan.truePeakDBTP() != audioSilentPeakDBFS {
+		t.Fatalf("silence = %v, %v, %+v", lufs, ok, scan)
+	}
+}
+
+func TestR128ScanGating(t *testing.T) {
+	// Blocks 10 LU or more below the ungated mean do not count, so a quiet
+	// passage does not drag the loudness down.
+	var loud, mixed r128Scan
+	for range 100 {
+		loud.addBlock(-10)
+		mixed.addBlock(-10)
+		mixed.addBlock(-40)
+	}
+	a, _ := loud.integrated()
+	b, _ := mixed.integrated()
+	if math.Abs(a-b) > 1e-9 || math.Abs(a+10) > 0.1 {
+		t.Fatalf("gated loudness %v, ungated %v", b, a)
+	}
+
+	var album r128Scan
+	album.merge(&loud)
+	album.merge(&r128Scan{Histogram: map[int]int64{}, TruePeak: 0.5, Seconds: 2})
+	if album.Histogram[600] != 100 || album.TruePeak != 0.5 || album.Seconds != 2 {
+		t.Fatalf("merged = %+v", album)
+	}
+
+	if weights := r128ChannelWeights(6); weights[3] != 0 || weights[5] != 1.41 || weights[0] != 1 {
+		t.Fatalf("5.1 weights = %v", weights)
+	}
+}
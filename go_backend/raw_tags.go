@@ -0,0 +1,254 @@
+package gobackend
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-flac/flacvorbis/v2"
+	"github.com/go-flac/go-flac/v2"
+)
+
+// TagPair is one raw Vorbis comment. Several pairs with the same key make a
+// multi-valued field, kept in the order given.
+type TagPair struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// validateVorbisKey enforces the Vorbis comment field name grammar: one or
+// more ASCII characters 0x20 through 0x7D, excluding '='.
+func validateVorbisKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("empty tag key")
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c < 0x20 || c > 0x7D || c == '=' {
+			return fmt.Errorf("invalid character %q in tag key %q", c, key)
+		}
+	}
+	return nil
+}
+
+func normalizeTagKeys(keys []string) ([]string, error) {
+	normalized := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if err := validateVorbisKey(key); err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, strings.ToUpper(key))
+	}
+	return normalized, nil
+}
+
+// groupTagPairs validates pairs and groups their values by upper-cased key,
+// returning the keys in first-seen order.
+func groupTagPairs(pairs []TagPair) ([]string, map[string][]string, error) {
+	var order []string
+	values := make(map[string][]string)
+	for _, pair := range pairs {
+		if err := validateVorbisKey(pair.Key); err != nil {
+			return nil, nil, err
+		}
+		if !utf8.ValidString(pair.Value) {
+			return nil, nil, fmt.Errorf("tag %s value is not valid UTF-8", pair.Key)
+		}
+		key := strings.ToUpper(pair.Key)
+		if _, seen := values[key]; !seen {
+			order = append(order, key)
+		}
+		values[key] = append(values[key], pair.Value)
+	}
+	return order, values, nil
+}
+
+// editVorbisComments parses filePath, lets edit change its comments and saves
+// through the same atomic path as the structured writers. The file is left
+// untouched when edit reports no change.
+func editVorbisComments(filePath, op string, edit func(comments *vorbisCommentMap) bool) error {
+	_, err := editVorbisCommentsStats(filePath, op, edit)
+	return err
+}
+
+// editVorbisCommentsStats is editVorbisComments reporting how the file was
+// written; the stats are zero when edit made no change.
+func editVorbisCommentsStats(filePath, op string, edit func(comments *vorbisCommentMap) bool) (flacSaveStats, error) {
+	return editVorbisCommentListStats(filePath, op, func(raw []string) ([]string, bool) {
+		comments := newVorbisCommentMap(raw)
+		if !edit(comments) {
+			return nil, false
+		}
+		return comments.comments(), true
+	})
+}
+
+// editVorbisCommentList is editVorbisComments over the raw KEY=value list,
+// for edits that rename keys rather than set values.
+func editVorbisCommentList(filePath, op string, edit func(raw []string) ([]string, bool)) error {
+	_, err := editVorbisCommentListStats(filePath, op, edit)
+	return err
+}
+
+func editVorbisCommentListStats(filePath, op string, edit func(raw []string) ([]string, bool)) (flacSaveStats, error) {
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return flacSaveStats{}, err
+	}
+	defer release()
+
+	f, err := parseFLACStaged(filePath)
+	if err != nil {
+		return flacSaveStats{}, fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+	before := takeTagSnapshot(f)
+
+	var cmtIdx int = -1
+	var cmt *flacvorbis.MetaDataBlockVorbisComment
+
+	for idx, meta := range f.Meta {
+		if meta.Type == flac.VorbisComment {
+			cmtIdx = idx
+			cmt, err = parseVorbisCommentBlock(*meta)
+			if err != nil {
+				f.Close()
+				return flacSaveStats{}, fmt.Errorf("failed to parse vorbis comment: %w", err)
+			}
+			break
+		}
+	}
+	if cmt == nil {
+		cmt = flacvorbis.New()
+	}
+
+	edited, changed := edit(cmt.Comments)
+	if !changed {
+		f.Close()
+		return flacSaveStats{}, nil
+	}
+	cmt.Comments = edited
+
+	cmtBlock := cmt.Marshal()
+	if cmtIdx >= 0 {
+		f.Meta[cmtIdx] = &cmtBlock
+	} else {
+		f.Meta = append(f.Meta, &cmtBlock)
+	}
+
+	return saveTaggedFLACStats(f, filePath, op, before)
+}
+
+// SetTags writes arbitrary Vorbis comments. Keys are validated against the
+// Vorbis grammar and stored upper-cased. With replace, every key in pairs
+// loses its existing values; otherwise the new values are appended after
+// them.
+func SetTags(filePath string, pairs []TagPair, replace bool) error {
+	if isOpenerPath(filePath) {
+		return viaFileOpenerErr(filePath, true, func(localPath string) error {
+			return SetTags(localPath, pairs, replace)
+		})
+	}
+	if err := checkWriteAllowed(filePath); err != nil {
+		return err
+	}
+
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	order, values, err := groupTagPairs(pairs)
+	if err != nil {
+		return err
+	}
+
+	return editVorbisComments(filePath, "set_tags", func(comments *vorbisCommentMap) bool {
+		for _, key := range order {
+			newValues := values[key]
+			if !replace {
+				newValues = append(comments.getValues(key), newValues...)
+			}
+			comments.setValues(key, newValues)
+		}
+		return true
+	})
+}
+
+// GetTags returns the values of keys, upper-cased and in file order. Keys
+// missing from the file are omitted; an empty keys list returns every tag.
+func GetTags(filePath string, keys []string) (map[string][]string, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, false, func(localPath string) (map[string][]string, error) {
+			return GetTags(localPath, keys)
+		})
+	}
+	wanted, err := normalizeTagKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	filter := make(map[string]bool, len(wanted))
+	for _, key := range wanted {
+		filter[key] = true
+	}
+
+	if err := checkIncompleteAudioFile(filePath); err != nil {
+		return nil, err
+	}
+	f, err := flac.ParseFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+	defer f.Close()
+
+	tags := make(map[string][]string)
+	for _, meta := range f.Meta {
+		if meta.Type != flac.VorbisComment {
+			continue
+		}
+		cmt, err := parseVorbisCommentBlock(*meta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse vorbis comment: %w", err)
+		}
+		for _, comment := range cmt.Comments {
+			key, value := splitVorbisComment(comment)
+			key = strings.ToUpper(key)
+			if len(filter) > 0 && !filter[key] {
+				continue
+			}
+			tags[key] = append(tags[key], value)
+		}
+		break
+	}
+	return tags, nil
+}
+
+// DeleteTags removes every value of keys. Deleting keys the file does not
+// have is not an error and does not rewrite the file.
+func DeleteTags(filePath string, keys []string) error {
+	if isOpenerPath(filePath) {
+		return viaFileOpenerErr(filePath, true, func(localPath string) error {
+			return DeleteTags(localPath, keys)
+		})
+	}
+	if err := checkWriteAllowed(filePath); err != nil {
+		return err
+	}
+
+	normalized, err := normalizeTagKeys(keys)
+	if err != nil {
+		return err
+	}
+	if len(normalized) == 0 {
+		return nil
+	}
+
+	return editVorbisComments(filePath, "delete_tags", func(comments *vorbisCommentMap) bool {
+		changed := false
+		for _, key := range normalized {
+			if len(comments.getValues(key)) > 0 {
+				comments.remove(key)
+				changed = true
+			}
+		}
+		return changed
+	})
+}
@@ -0,0 +1,91 @@
+package gobackend
+
+import "testing"
+
+func TestSetTagsAppendsAndReplacesMultiValues(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Track", Genre: "Rock"})
+
+	err := SetTags(path, []TagPair{
+		{Key: "performer", Value: "Alice (vocals)"},
+		{Key: "PERFORMER", Value: "Bob (drums)"},
+		{Key: "Genre", Value: "Live"},
+	}, false)
+	if err != nil {
+		t.Fatalf("SetTags append: %v", err)
+	}
+
+	tags, err := GetTags(path, []string{"performer", "genre", "missing"})
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if got := tags["PERFORMER"]; len(got) != 2 || got[0] != "Alice (vocals)" || got[1] != "Bob (drums)" {
+		t.Fatalf("unexpected PERFORMER values: %q", got)
+	}
+	if got := tags["GENRE"]; len(got) != 2 || got[0] != "Rock" || got[1] != "Live" {
+		t.Fatalf("append should keep existing GENRE first: %q", got)
+	}
+	if _, ok := tags["MISSING"]; ok {
+		t.Fatal("missing keys should be omitted")
+	}
+
+	if err := SetTags(path, []TagPair{{Key: "GENRE", Value: "Jazz"}}, true); err != nil {
+		t.Fatalf("SetTags replace: %v", err)
+	}
+	all, err := GetTags(path, nil)
+	if err != nil {
+		t.Fatalf("GetTags all: %v", err)
+	}
+	if got := all["GENRE"]; len(got) != 1 || got[0] != "Jazz" {
+		t.Fatalf("replace should drop old GENRE values: %q", got)
+	}
+	if got := all["TITLE"]; len(got) != 1 || got[0] != "Track" {
+		t.Fatalf("unrelated tags should survive: %q", got)
+	}
+}
+
+func TestSetTagsRejectsInvalidKeys(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Track"})
+
+	for _, key := range []string{"", "A=B", "CAFÉ", "TAB\tKEY", "TILDE~"} {
+		if err := SetTags(path, []TagPair{{Key: key, Value: "x"}}, false); err == nil {
+			t.Fatalf("expected key %q to be rejected", key)
+		}
+	}
+	if err := SetTags(path, []TagPair{{Key: "COMMENT", Value: "\xff\xfe"}}, false); err == nil {
+		t.Fatal("expected invalid UTF-8 value to be rejected")
+	}
+	if _, err := GetTags(path, []string{"BAD=KEY"}); err == nil {
+		t.Fatal("expected GetTags to validate keys")
+	}
+}
+
+func TestDeleteTagsThroughJSONBridge(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Track", Label: "Label"})
+
+	if err := SetTagsJSON(path, `[{"key":"MUSICBRAINZ_TRACKID","value":"abc"},{"key":"MOOD","value":"calm"}]`, false); err != nil {
+		t.Fatalf("SetTagsJSON: %v", err)
+	}
+
+	rewritesBefore := flacRewriteCount.Load()
+	if err := DeleteTagsJSON(path, `["not_present"]`); err != nil {
+		t.Fatalf("DeleteTagsJSON no-op: %v", err)
+	}
+	if flacRewriteCount.Load() != rewritesBefore {
+		t.Fatal("deleting absent keys should not rewrite the file")
+	}
+	if err := DeleteTagsJSON(path, `["mood","organization"]`); err != nil {
+		t.Fatalf("DeleteTagsJSON: %v", err)
+	}
+
+	tagsJSON, err := GetTagsJSON(path, "")
+	tags := mustDecodeJSON[map[string][]string](t, tagsJSON, err)
+	if _, ok := tags["MOOD"]; ok {
+		t.Fatalf("MOOD should be deleted: %s", tagsJSON)
+	}
+	if _, ok := tags["ORGANIZATION"]; ok {
+		t.Fatalf("ORGANIZATION should be deleted: %s", tagsJSON)
+	}
+	if got := tags["MUSICBRAINZ_TRACKID"]; len(got) != 1 || got[0] != "abc" {
+		t.Fatalf("unexpected MUSICBRAINZ_TRACKID: %s", tagsJSON)
+	}
+}
@@ -0,0 +1,145 @@
+package gobackend
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrReadOnlyMode is returned by every library write while the backend is
+// configured as read-only (browse-only mode in the app).
+var ErrReadOnlyMode = errors.New("backend is in read-only mode")
+
+// ErrReadOnlyVolume matches every ReadOnlyVolumeError via errors.Is.
+var ErrReadOnlyVolume = errors.New("volume is read-only")
+
+// ReadOnlyVolumeError is returned when the writability probe finds the
+// target directory on a volume mounted read-only, such as a write-protected
+// SD card.
+type ReadOnlyVolumeError struct {
+	Path       string
+	MountPoint string
+}
+
+func (e *ReadOnlyVolumeError) Error() string {
+	if e.MountPoint == "" {
+		return fmt.Sprintf("volume is read-only: %s", e.Path)
+	}
+	return fmt.Sprintf("volume mounted at %s is read-only: %s", e.MountPoint, e.Path)
+}
+
+func (e *ReadOnlyVolumeError) Is(target error) bool {
+	return target == ErrReadOnlyVolume
+}
+
+const writeProbePattern = ".spotiflac-write-probe-*"
+
+// mountPointFromMounts returns the longest mount point in a /proc/mounts
+// listing that contains path.
+func mountPointFromMounts(r io.Reader, path string) string {
+	best := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		mountPoint := unescapeMountField(fields[1])
+		if !pathWithin(path, mountPoint) || len(mountPoint) <= len(best) {
+			continue
+		}
+		best = mountPoint
+	}
+	return best
+}
+
+// unescapeMountField decodes the octal escapes (\040 for a space and so on)
+// the kernel uses in /proc/mounts.
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if n, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
+
+func pathWithin(path, dir string) bool {
+	if dir == "/" || path == dir {
+		return true
+	}
+	return strings.HasPrefix(path, dir+"/")
+}
+
+// mountPointOf returns the mount point holding path, or "" when
+// /proc/mounts is unavailable.
+func mountPointOf(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	mounts, err := os.Open("/proc/mounts")
+	if err != nil {
+		return ""
+	}
+	defer mounts.Close()
+	return mountPointFromMounts(mounts, abs)
+}
+
+// probeWritable creates and removes a temp file in dir. A read-only
+// filesystem is reported as a ReadOnlyVolumeError carrying the mount point;
+// storage permission failures come back as a PermissionError.
+func probeWritable(dir string) error {
+	probe, err := os.CreateTemp(dir, writeProbePattern)
+	if err != nil {
+		if errors.Is(err, syscall.EROFS) {
+			return classifyWriteError(dir, &ReadOnlyVolumeError{Path: dir, MountPoint: mountPointOf(dir)})
+		}
+		return classifyWriteError(dir, fmt.Errorf("folder is not writable: %w", err))
+	}
+	name := probe.Name()
+	probe.Close()
+	if err := os.Remove(name); err != nil {
+		return classifyWriteError(dir, fmt.Errorf("folder is not writable: %w", err))
+	}
+	return nil
+}
+
+func checkReadOnlyMode() error {
+	if GetBackendConfig().ReadOnly {
+		return ErrReadOnlyMode
+	}
+	return nil
+}
+
+// checkWriteAllowed runs before any work in the APIs that modify a file or
+// folder: it fails fast in read-only mode and probes the directory that will
+// receive the write. SAF descriptors cannot be probed and are let through.
+func checkWriteAllowed(path string) error {
+	if err := checkReadOnlyMode(); err != nil {
+		return err
+	}
+	if strings.HasPrefix(path, "/proc/self/fd/") {
+		return nil
+	}
+	dir := path
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+	return probeWritable(dir)
+}
@@ -0,0 +1,99 @@
+package gobackend
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadOnlyModeBlocksWritesButNotReads(t *testing.T) {
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", Artist: "Artist"})
+	before := mustReadFile(t, path)
+
+	if err := Configure(`{"read_only":true}`); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if err := EditFlacFields(path, map[string]string{"title": "Changed"}); !errors.Is(err, ErrReadOnlyMode) {
+		t.Fatalf("EditFlacFields error = %v, want ErrReadOnlyMode", err)
+	}
+	if err := SetTags(path, []TagPair{{Key: "MOOD", Value: "calm"}}, false); !errors.Is(err, ErrReadOnlyMode) {
+		t.Fatalf("SetTags error = %v, want ErrReadOnlyMode", err)
+	}
+	if !bytes.Equal(mustReadFile(t, path), before) {
+		t.Fatalf("file changed in read-only mode")
+	}
+
+	tags, err := GetTags(path, []string{"TITLE"})
+	if err != nil {
+		t.Fatalf("GetTags in read-only mode: %v", err)
+	}
+	if got := tags["TITLE"]; len(got) != 1 || got[0] != "Song" {
+		t.Fatalf("TITLE = %v, want [Song]", got)
+	}
+
+	if err := Configure(`{"read_only":false}`); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if err := EditFlacFields(path, map[string]string{"title": "Changed"}); err != nil {
+		t.Fatalf("EditFlacFields after leaving read-only mode: %v", err)
+	}
+}
+
+func TestProbeWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := probeWritable(dir); err != nil {
+		t.Fatalf("probeWritable: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("probe left %d files behind", len(entries))
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("permission bits are not enforced for root")
+	}
+	locked := filepath.Join(dir, "locked")
+	if err := os.Mkdir(locked, 0555); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if err := checkWriteAllowed(filepath.Join(locked, "track.flac")); err == nil {
+		t.Fatalf("checkWriteAllowed succeeded on a read-only folder")
+	}
+}
+
+func TestMountPointFromMounts(t *testing.T) {
+	mounts := strings.Join([]string{
+		"/dev/root / ext4 rw 0 0",
+		"/dev/block/vold/public:179,1 /mnt/media_rw/1234-ABCD vfat ro 0 0",
+		`/dev/sdb1 /mnt/My\040Card vfat ro 0 0`,
+	}, "\n")
+
+	cases := map[string]string{
+		"/mnt/media_rw/1234-ABCD/Music": "/mnt/media_rw/1234-ABCD",
+		"/mnt/media_rw/1234-ABCDE":      "/",
+		"/mnt/My Card/Album/01.flac":    "/mnt/My Card",
+		"/data/local":                   "/",
+	}
+	for path, want := range cases {
+		if got := mountPointFromMounts(strings.NewReader(mounts), path); got != want {
+			t.Fatalf("mountPointFromMounts(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	err := error(&ReadOnlyVolumeError{Path: "/mnt/My Card/Album", MountPoint: "/mnt/My Card"})
+	if !errors.Is(err, ErrReadOnlyVolume) {
+		t.Fatalf("ReadOnlyVolumeError does not match ErrReadOnlyVolume")
+	}
+	if !strings.Contains(err.Error(), "/mnt/My Card") {
+		t.Fatalf("error %q does not name the mount point", err)
+	}
+}
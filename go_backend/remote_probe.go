@@ -0,0 +1,144 @@
+package gobackend
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-flac/flacvorbis/v2"
+	"github.com/go-flac/go-flac/v2"
+)
+
+const (
+	// remoteProbeBytes is how much of a remote file ProbeRemoteFLAC asks
+	// for: STREAMINFO always, and the comments when no large cover comes
+	// first.
+	remoteProbeBytes = 64 << 10
+	// remoteProbeDrainMax caps how much of a full response from a server
+	// that ignores Range is read and discarded to keep the connection
+	// reusable; longer bodies are cut off instead.
+	remoteProbeDrainMax = 256 << 10
+)
+
+// RemoteFLACProbe is what ProbeRemoteFLAC could see of a remote FLAC file.
+// Tags is only set when the whole comment block was inside the fetched
+// bytes; CommentsComplete tells an untagged file from one whose comments
+// came too late.
+type RemoteFLACProbe struct {
+	URL              string              `json:"url"`
+	Quality          AudioQuality        `json:"quality"`
+	Vendor           string              `json:"vendor,omitempty"`
+	Tags             map[string][]string `json:"tags,omitempty"`
+	CommentsComplete bool                `json:"comments_complete"`
+	RangeSupported   bool                `json:"range_supported"`
+	BytesFetched     int64               `json:"bytes_fetched"`
+}
+
+// fetchRemoteFLACHead returns the first remoteProbeBytes of rawURL and
+// whether the server honoured the Range request.
+func fetchRemoteFLACHead(rawURL string) ([]byte, bool, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, false, fmt.Errorf("invalid probe URL: %q", rawURL)
+	}
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgentForURL(req.URL))
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", remoteProbeBytes-1))
+
+	resp, err := doFetchWithRetry(NewHTTPClientWithTimeout(DefaultTimeout), req, "remote probe")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to probe %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent, http.StatusOK:
+	default:
+		return nil, false, fmt.Errorf("remote probe failed: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, remoteProbeBytes))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read probe data: %w", err)
+	}
+	rangeSupported := resp.StatusCode == http.StatusPartialContent
+	if !rangeSupported {
+		io.CopyN(io.Discard, resp.Body, remoteProbeDrainMax)
+	}
+	return data, rangeSupported, nil
+}
+
+// readFLACHeadComments finds the comment block in the metadata at the start
+// of data and parses it, if it lies entirely inside data.
+func readFLACHeadComments(data []byte) (*flacvorbis.MetaDataBlockVorbisComment, bool) {
+	for offset := 4; offset+4 <= len(data); {
+		header := data[offset : offset+4]
+		blockType := flac.BlockType(header[0] & 0x7F)
+		length := int(binary.BigEndian.Uint32(header) & 0xFFFFFF)
+		end := offset + 4 + length
+		if blockType == flac.VorbisComment {
+			if end > len(data) {
+				return nil, false
+			}
+			cmt, err := parseVorbisCommentBlock(flac.MetaDataBlock{Type: flac.VorbisComment, Data: data[offset+4 : end]})
+			if err != nil {
+				return nil, false
+			}
+			return cmt, true
+		}
+		if header[0]&0x80 != 0 {
+			// Last metadata block and no comments: the file has none.
+			return nil, true
+		}
+		offset = end
+	}
+	return nil, false
+}
+
+// ProbeRemoteFLAC reads the audio quality, and the tags when they are near
+// the start, of a FLAC file at a direct URL without downloading it, so the
+// real stream can be checked against the quality a service advertises. Only
+// the first 64 KB are requested; a server that ignores Range has the rest of
+// its response cut off after a bounded read.
+func ProbeRemoteFLAC(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	data, rangeSupported, err := fetchRemoteFLACHead(rawURL)
+	if err != nil {
+		return "", err
+	}
+	quality, err := parseFLACQualityHeader(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to read STREAMINFO: %w", err)
+	}
+
+	probe := RemoteFLACProbe{URL: rawURL, Quality: quality, RangeSupported: rangeSupported, BytesFetched: int64(len(data))}
+	cmt, complete := readFLACHeadComments(data)
+	probe.CommentsComplete = complete
+	if cmt != nil {
+		probe.Vendor = cmt.Vendor
+		for _, comment := range cmt.Comments {
+			key, ok := vorbisCommentKey(comment)
+			if !ok {
+				continue
+			}
+			if probe.Tags == nil {
+				probe.Tags = make(map[string][]string)
+			}
+			probe.Tags[key] = append(probe.Tags[key], vorbisCommentValue(comment))
+		}
+	}
+	GoLog("[RemoteProbe] %d-bit/%d Hz, comments complete: %v, range: %v: %s\n",
+		quality.BitDepth, quality.SampleRate, complete, rangeSupported, rawURL)
+
+	jsonBytes, err := json.Marshal(probe)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
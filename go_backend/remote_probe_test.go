@@ -0,0 +1,89 @@
+package gobackend
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+func probeRemoteFixture(t *testing.T, rawURL string) RemoteFLACProbe {
+	t.Helper()
+	out, err := ProbeRemoteFLAC(rawURL)
+	probe := mustDecodeJSON[RemoteFLACProbe](t, out, err)
+	return probe
+}
+
+func TestProbeRemoteFLACWithRange(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Remote", Artist: "Artist"})
+	padFLAC(t, path, 1<<20)
+	data := mustReadFile(t, path)
+
+	var rangeHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangeHeader = r.Header.Get("Range")
+		http.ServeContent(w, r, "track.flac", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	probe := probeRemoteFixture(t, server.URL+"/track.flac")
+	if rangeHeader != "bytes=0-65535" || !probe.RangeSupported || probe.BytesFetched != remoteProbeBytes {
+		t.Fatalf("range %q, probe %+v", rangeHeader, probe)
+	}
+	if probe.Quality.Codec != "flac" || probe.Quality.SampleRate == 0 || !probe.CommentsComplete {
+		t.Fatalf("probe = %+v", probe)
+	}
+	if probe.Tags["TITLE"][0] != "Remote" || probe.Tags["ARTIST"][0] != "Artist" {
+		t.Fatalf("tags = %v", probe.Tags)
+	}
+}
+
+func TestProbeRemoteFLACWithoutRange(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Remote"})
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	f.Close()
+	// A large block ahead of the comments pushes them out of the probe.
+	blocks := []*flac.MetaDataBlock{f.Meta[0], {Type: flac.Padding, Data: make([]byte, 100<<10)}}
+	for _, block := range f.Meta[1:] {
+		if block.Type != flac.Padding {
+			blocks = append(blocks, block)
+		}
+	}
+	if _, err := rewriteFLACStreaming(path, blocks); err != nil {
+		t.Fatalf("rewriteFLACStreaming: %v", err)
+	}
+	data := mustReadFile(t, path)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	probe := probeRemoteFixture(t, server.URL)
+	if probe.RangeSupported || probe.CommentsComplete || probe.Tags != nil || probe.Quality.SampleRate == 0 {
+		t.Fatalf("probe = %+v", probe)
+	}
+}
+
+func TestProbeRemoteFLACErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ID3 not a flac file at all, padded to be long enough"))
+	}))
+	defer server.Close()
+
+	for _, rawURL := range []string{"ftp://example.com/a.flac", server.URL + "/missing", server.URL + "/mp3"} {
+		if _, err := ProbeRemoteFLAC(rawURL); err == nil {
+			t.Fatalf("expected error for %s", rawURL)
+		}
+	}
+}
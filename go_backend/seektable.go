@@ -0,0 +1,234 @@
+package gobackend
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+const (
+	defaultSeekTableInterval = 10.0
+
+	// flacSeekPointPlaceholder marks an unused seek point; the spec allows
+	// any number of them after the real points.
+	flacSeekPointPlaceholder = ^uint64(0)
+	flacSeekPointSize        = 18
+)
+
+type flacSeekPoint struct {
+	SampleNumber uint64
+	// Offset is relative to the first byte of the first frame header.
+	Offset       uint64
+	FrameSamples uint16
+}
+
+type SeekTableResult struct {
+	// Status is "added", "rebuilt" or "exists".
+	Status   string  `json:"status"`
+	Points   int     `json:"points"`
+	Interval float64 `json:"interval"`
+}
+
+// buildFLACSeekPoints walks the frames of filePath and returns one point per
+// interval seconds, each pointing at the last frame starting at or before
+// that time.
+func buildFLACSeekPoints(filePath string, interval float64) ([]flacSeekPoint, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	layout, err := scanFLACMetadataBlocks(file, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	if len(layout.Issues) > 0 {
+		return nil, fmt.Errorf("metadata is damaged: %s", layout.Issues[0])
+	}
+	_, sampleRate, _ := parseFLACStreamInfoQuality(layout.StreamInfo)
+	if sampleRate <= 0 {
+		return nil, fmt.Errorf("invalid sample rate in STREAMINFO")
+	}
+	if _, err := file.Seek(layout.AudioOffset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	step := uint64(interval * float64(sampleRate))
+	if step == 0 {
+		step = 1
+	}
+
+	var (
+		points      []flacSeekPoint
+		firstOffset int64 = -1
+		sample      uint64
+		nextTarget  uint64
+	)
+	_, err = walkFLACFrames(file, layout.AudioOffset, func(frame flacFrameInfo) error {
+		if firstOffset < 0 {
+			firstOffset = frame.Offset
+		}
+		// The frame header can code 65536 samples, which the 16-bit seek
+		// point field cannot hold; RFC 9639 caps blocks at 65535.
+		if frame.Header.BlockSize > math.MaxUint16 {
+			return fmt.Errorf("frame at offset %d has %d samples, more than a seek point can describe", frame.Offset, frame.Header.BlockSize)
+		}
+		frameEnd := sample + uint64(frame.Header.BlockSize)
+		if frameEnd > nextTarget {
+			points = append(points, flacSeekPoint{
+				SampleNumber: sample,
+				Offset:       uint64(frame.Offset - firstOffset),
+				FrameSamples: uint16(frame.Header.BlockSize),
+			})
+			for nextTarget < frameEnd {
+				nextTarget += step
+			}
+		}
+		sample = frameEnd
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan frames: %w", err)
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no audio frames found")
+	}
+	return points, nil
+}
+
+// validateFLACSeekPoints checks the spec's rules: real points have strictly
+// increasing sample numbers and all placeholders come after them.
+func validateFLACSeekPoints(points []flacSeekPoint) error {
+	seenPlaceholder := false
+	for i, point := range points {
+		if point.SampleNumber == flacSeekPointPlaceholder {
+			seenPlaceholder = true
+			continue
+		}
+		if seenPlaceholder {
+			return fmt.Errorf("seek point %d follows a placeholder", i)
+		}
+		if i > 0 && point.SampleNumber <= points[i-1].SampleNumber {
+			return fmt.Errorf("seek point %d sample number %d is not increasing", i, point.SampleNumber)
+		}
+	}
+	return nil
+}
+
+func marshalFLACSeekTable(points []flacSeekPoint) []byte {
+	data := make([]byte, len(points)*flacSeekPointSize)
+	for i, point := range points {
+		b := data[i*flacSeekPointSize:]
+		binary.BigEndian.PutUint64(b[0:8], point.SampleNumber)
+		binary.BigEndian.PutUint64(b[8:16], point.Offset)
+		binary.BigEndian.PutUint16(b[16:18], point.FrameSamples)
+	}
+	return data
+}
+
+func parseFLACSeekTable(data []byte) ([]flacSeekPoint, error) {
+	if len(data)%flacSeekPointSize != 0 {
+		return nil, fmt.Errorf("seek table length %d is not a multiple of %d", len(data), flacSeekPointSize)
+	}
+	points := make([]flacSeekPoint, len(data)/flacSeekPointSize)
+	for i := range points {
+		b := data[i*flacSeekPointSize:]
+		points[i] = flacSeekPoint{
+			SampleNumber: binary.BigEndian.Uint64(b[0:8]),
+			Offset:       binary.BigEndian.Uint64(b[8:16]),
+			FrameSamples: binary.BigEndian.Uint16(b[16:18]),
+		}
+	}
+	return points, nil
+}
+
+// AddSeekTable builds a SEEKTABLE with a point every interval seconds
+// (default 10) by walking frame headers, and inserts it right after
+// STREAMINFO. A file that already has a seek table is left alone unless
+// rebuild is set. The file is replaced atomically.
+func AddSeekTable(filePath string, interval float64, rebuild bool) (string, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, true, func(localPath string) (string, error) {
+			return AddSeekTable(localPath, interval, rebuild)
+		})
+	}
+	if err := checkWriteAllowed(filePath); err != nil {
+		return "", err
+	}
+
+	if interval <= 0 {
+		interval = defaultSeekTableInterval
+	}
+
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	f, err := parseFLACStaged(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+
+	existingIdx := -1
+	for idx, meta := range f.Meta {
+		if meta.Type == flac.SeekTable {
+			existingIdx = idx
+			break
+		}
+	}
+
+	result := SeekTableResult{Status: "added", Interval: interval}
+	if existingIdx >= 0 && !rebuild {
+		f.Close()
+		if points, err := parseFLACSeekTable(f.Meta[existingIdx].Data); err == nil {
+			result.Points = len(points)
+		}
+		result.Status = "exists"
+		return marshalSeekTableResult(result)
+	}
+
+	points, err := buildFLACSeekPoints(filePath, interval)
+	if err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := validateFLACSeekPoints(points); err != nil {
+		f.Close()
+		return "", fmt.Errorf("generated invalid seek table: %w", err)
+	}
+
+	block := &flac.MetaDataBlock{Type: flac.SeekTable, Data: marshalFLACSeekTable(points)}
+	if existingIdx >= 0 {
+		f.Meta[existingIdx] = block
+		result.Status = "rebuilt"
+	} else {
+		f.Meta = append(f.Meta[:1], append([]*flac.MetaDataBlock{block}, f.Meta[1:]...)...)
+	}
+
+	if err := saveFLACAtomic(f, filePath); err != nil {
+		return "", err
+	}
+	result.Points = len(points)
+	GoLog("[SeekTable] %s seek table with %d point(s): %s\n", result.Status, len(points), filePath)
+	return marshalSeekTableResult(result)
+}
+
+func marshalSeekTableResult(result SeekTableResult) (string, error) {
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,115 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+func readSeekTable(t *testing.T, path string) (int, []flacSeekPoint) {
+	t.Helper()
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	defer f.Close()
+	for idx, meta := range f.Meta {
+		if meta.Type == flac.SeekTable {
+			points, err := parseFLACSeekTable(meta.Data)
+			if err != nil {
+				t.Fatalf("parseFLACSeekTable: %v", err)
+			}
+			return idx, points
+		}
+	}
+	return -1, nil
+}
+
+func TestAddSeekTableInsertsValidTableAfterStreamInfo(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	hashBefore, _ := AudioHash(path)
+
+	resultJSON, err := AddSeekTable(path, 0.1, false)
+	if err != nil {
+		t.Fatalf("AddSeekTable: %v", err)
+	}
+	var result SeekTableResult
+	_ = json.Unmarshal([]byte(resultJSON), &result)
+	if result.Status != "added" || result.Points != 3 {
+		t.Fatalf("unexpected result: %s", resultJSON)
+	}
+
+	idx, points := readSeekTable(t, path)
+	if idx != 1 {
+		t.Fatalf("seek table at block %d, want 1", idx)
+	}
+	if err := validateFLACSeekPoints(points); err != nil {
+		t.Fatalf("invalid seek table: %v", err)
+	}
+	if points[0].SampleNumber != 0 || points[0].Offset != 0 || points[1].SampleNumber != selfTestBlockSize {
+		t.Fatalf("unexpected points: %+v", points)
+	}
+
+	if hashAfter, _ := AudioHash(path); hashAfter != hashBefore {
+		t.Fatal("audio frames changed")
+	}
+	if report, _ := verifyFLAC(path, true); !report.Valid {
+		t.Fatalf("file invalid after adding seek table: %+v", report)
+	}
+	if meta, _ := ReadMetadata(path); meta.Title != "Song" {
+		t.Fatalf("tags lost: %+v", meta)
+	}
+}
+
+func TestAddSeekTableKeepsExistingUnlessRebuild(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{})
+	if _, err := AddSeekTable(path, 0.1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	resultJSON, _ := AddSeekTable(path, 0, false)
+	var result SeekTableResult
+	_ = json.Unmarshal([]byte(resultJSON), &result)
+	if result.Status != "exists" || result.Points != 3 {
+		t.Fatalf("unexpected result: %s", resultJSON)
+	}
+
+	resultJSON, _ = AddSeekTable(path, 0, true)
+	result = SeekTableResult{}
+	_ = json.Unmarshal([]byte(resultJSON), &result)
+	if result.Status != "rebuilt" || result.Points != 1 {
+		t.Fatalf("unexpected result: %s", resultJSON)
+	}
+	if _, points := readSeekTable(t, path); len(points) != 1 {
+		t.Fatalf("expected 1 point after rebuild, got %d", len(points))
+	}
+}
+
+func TestValidateFLACSeekPoints(t *testing.T) {
+	valid := []flacSeekPoint{{SampleNumber: 0}, {SampleNumber: 10}, {SampleNumber: flacSeekPointPlaceholder}}
+	if err := validateFLACSeekPoints(valid); err != nil {
+		t.Fatalf("placeholders at the end must be allowed: %v", err)
+	}
+	if err := validateFLACSeekPoints([]flacSeekPoint{{SampleNumber: 10}, {SampleNumber: 10}}); err == nil {
+		t.Fatal("expected error for non-increasing sample numbers")
+	}
+	if err := validateFLACSeekPoints([]flacSeekPoint{{SampleNumber: flacSeekPointPlaceholder}, {SampleNumber: 5}}); err == nil {
+		t.Fatal("expected error for point after placeholder")
+	}
+}
+
+func TestAddSeekTableThroughFileOpener(t *testing.T) {
+	opener := useDirFileOpener(t)
+	uri := serveThroughOpener(t, opener, writeTestFLACWithMetadata(t, Metadata{Title: "Song"}), "song.flac")
+
+	out, err := AddSeekTable(uri, 0.1, false)
+	result := mustDecodeJSON[SeekTableResult](t, out, err)
+	if result.Status != "added" || result.Points == 0 || opener.writes != 1 {
+		t.Fatalf("result = %+v after %d writes", result, opener.writes)
+	}
+	if idx, points := readSeekTable(t, filepath.Join(opener.dir, "song.flac")); idx != 1 || len(points) != result.Points {
+		t.Fatalf("document seek table at %d with %d points", idx, len(points))
+	}
+}
@@ -0,0 +1,415 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-flac/flacpicture/v2"
+	"github.com/go-flac/go-flac/v2"
+)
+
+// Per-file statuses reported by ShrinkLibraryCovers.
+const (
+	CoverShrinkShrunk       = "shrunk"
+	CoverShrinkWouldShrink  = "would_shrink"
+	CoverShrinkWithinLimits = "within_limits"
+	CoverShrinkFailed       = "failed"
+)
+
+const coverShrinkJournalFileName = "cover_shrink_journal.json"
+
+type ShrunkCoverFile struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	// Pictures is how many picture blocks were (or would be) re-encoded.
+	Pictures    int   `json:"pictures"`
+	BytesBefore int64 `json:"bytes_before"`
+	BytesAfter  int64 `json:"bytes_after"`
+	BytesSaved  int64 `json:"bytes_saved"`
+	// Removed is how many booklet-like picture blocks were (or would be)
+	// dropped; BytesRemoved is their share of BytesSaved.
+	Removed      int    `json:"removed,omitempty"`
+	BytesRemoved int64  `json:"bytes_removed,omitempty"`
+	InPlace      bool   `json:"in_place,omitempty"`
+	Error        string `json:"error,omitempty"`
+
+	io flacSaveStats
+}
+
+// CoverShrinkReport lists only files that changed, would change or failed;
+// files within limits are only counted.
+type CoverShrinkReport struct {
+	Root               string            `json:"root"`
+	DryRun             bool              `json:"dry_run"`
+	Checked            int               `json:"checked"`
+	Shrunk             int               `json:"shrunk"`
+	WithinLimits       int               `json:"within_limits"`
+	Failed             int               `json:"failed"`
+	QuarantinedSkipped int               `json:"quarantined_skipped"`
+	BytesSaved         int64             `json:"bytes_saved"`
+	BytesRemoved       int64             `json:"bytes_removed"`
+	Recovered          string            `json:"recovered,omitempty"`
+	IO                 WriteStats        `json:"io"`
+	Files              []ShrunkCoverFile `json:"files"`
+	ChangeReportOutput
+}
+
+// coverShrinkJournal holds the metadata region of the file being rewritten
+// in place, so an interrupted write can be undone on the next run.
+type coverShrinkJournal struct {
+	Path        string `json:"path"`
+	AudioOffset int64  `json:"audio_offset"`
+	Region      []byte `json:"region"`
+}
+
+func coverShrinkJournalPath() string {
+	dataDir := GetBackendConfig().DataDir
+	if dataDir == "" {
+		return ""
+	}
+	return filepath.Join(dataDir, coverShrinkJournalFileName)
+}
+
+func writeCoverShrinkJournal(journalPath string, journal coverShrinkJournal) error {
+	if err := os.MkdirAll(filepath.Dir(journalPath), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	data, err := json.Marshal(journal)
+	if err != nil {
+		return err
+	}
+	tmpPath := journalPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cover shrink journal: %w", err)
+	}
+	if err := os.Rename(tmpPath, journalPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to commit cover shrink journal: %w", err)
+	}
+	return nil
+}
+
+// recoverCoverShrinkJournal restores the original metadata of a file whose
+// in-place rewrite was interrupted. The journal only exists between the
+// start of a write and its sync, so the file is restored unconditionally.
+// It returns the restored path, or "" when there was nothing to recover.
+func recoverCoverShrinkJournal(journalPath string) (string, error) {
+	data, err := os.ReadFile(journalPath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read cover shrink journal: %w", err)
+	}
+	var journal coverShrinkJournal
+	if err := json.Unmarshal(data, &journal); err != nil || int64(len(journal.Region))+4 != journal.AudioOffset {
+		GoLog("[ShrinkCovers] Discarding unreadable journal\n")
+		return "", os.Remove(journalPath)
+	}
+	if _, err := writeFLACMetadataInPlace(journal.Path, journal.Region); err != nil {
+		return "", fmt.Errorf("failed to restore %s: %w", journal.Path, err)
+	}
+	GoLog("[ShrinkCovers] Restored metadata of interrupted rewrite: %s\n", journal.Path)
+	return journal.Path, os.Remove(journalPath)
+}
+
+// coverNeedsShrink reports whether any picture block of filePath is larger
+// than maxDim on either side or, with removeNonImages, is disposable, reading
+// only the block headers and images.
+func coverNeedsShrink(filePath string, maxDim int, removeNonImages bool) (bool, error) {
+	pictures, err := GetPictures(filePath)
+	if err != nil {
+		return false, err
+	}
+	for _, pic := range pictures {
+		if pic.Width > maxDim || pic.Height > maxDim {
+			return true, nil
+		}
+		if removeNonImages && pic.Type >= 0 && isDisposablePicture(flacpicture.PictureType(pic.Type), pic.MIME) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// removeDisposablePictures drops the picture blocks isDisposablePicture
+// selects from f.Meta.
+func removeDisposablePictures(f *flac.File, result *ShrunkCoverFile) {
+	kept := f.Meta[:0]
+	for _, meta := range f.Meta {
+		if meta.Type == flac.Picture {
+			if pic, err := parseFLACPictureMeta(*meta); err == nil && isDisposablePicture(pic.PictureType, pictureMIME(pic)) {
+				result.Removed++
+				result.BytesRemoved += 4 + int64(len(meta.Data))
+				continue
+			}
+		}
+		kept = append(kept, meta)
+	}
+	f.Meta = kept
+}
+
+// shrinkPictureBlocks re-encodes every oversized picture block of f in
+// place in f.Meta, after dropping disposable ones with removeNonImages.
+// Pictures that would not get smaller are left alone.
+func shrinkPictureBlocks(f *flac.File, maxDim, quality int, removeNonImages bool, result *ShrunkCoverFile) error {
+	if removeNonImages {
+		removeDisposablePictures(f, result)
+	}
+	for i, meta := range f.Meta {
+		if meta.Type != flac.Picture {
+			continue
+		}
+		pic, err := parseFLACPictureMeta(*meta)
+		if err != nil {
+			continue
+		}
+		width, height := int(pic.Width), int(pic.Height)
+		if width == 0 || height == 0 {
+			if w, h, _, err := coverDimensions(pic.ImageData); err == nil {
+				width, height = w, h
+			}
+		}
+		if width <= maxDim && height <= maxDim {
+			continue
+		}
+
+		resized, bounds, err := resizeCoverJPEG(pic.ImageData, maxDim, quality)
+		if err != nil {
+			return err
+		}
+		if len(resized) >= len(pic.ImageData) {
+			continue
+		}
+		result.BytesBefore += int64(len(pic.ImageData))
+		result.BytesAfter += int64(len(resized))
+		result.Pictures++
+
+		pic.ImageData = resized
+		pic.MIME = "image/jpeg"
+		pic.Width = uint32(bounds.Dx())
+		pic.Height = uint32(bounds.Dy())
+		pic.ColorDepth = 24
+		pic.IndexedColorCount = 0
+		block := pic.Marshal()
+		f.Meta[i] = &block
+	}
+	result.BytesSaved = result.BytesBefore - result.BytesAfter + result.BytesRemoved
+	return nil
+}
+
+// saveShrunkCovers writes f back into the existing metadata region when the
+// smaller pictures fit, journaling the old region first, and otherwise
+// falls back to a full atomic rewrite.
+func saveShrunkCovers(f *flac.File, filePath string, before tagSnapshot) (flacSaveStats, error) {
+	journalPath := coverShrinkJournalPath()
+	if journalPath == "" || strings.HasPrefix(filePath, "/proc/self/fd/") {
+		return saveTaggedFLACStats(f, filePath, "shrink_covers", before)
+	}
+
+	src, err := os.Open(filePath)
+	if err != nil {
+		f.Close()
+		return flacSaveStats{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	info, err := src.Stat()
+	var layout *flacBlockLayout
+	if err == nil {
+		layout, err = scanFLACMetadataBlocks(src, info.Size())
+	}
+	if err != nil || len(layout.Issues) > 0 {
+		src.Close()
+		return saveTaggedFLACStats(f, filePath, "shrink_covers", before)
+	}
+
+	recordTagHistory(f, "shrink_covers", before)
+	region, ok := marshalFLACMetadataRegion(f.Meta, layout.AudioOffset-4)
+	if !ok {
+		src.Close()
+		return saveFLACAtomicStats(f, filePath, "shrink_covers")
+	}
+	original := make([]byte, layout.AudioOffset-4)
+	_, err = src.ReadAt(original, 4)
+	src.Close()
+	if err != nil && err != io.EOF {
+		f.Close()
+		return flacSaveStats{}, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	f.Close()
+
+	if err := writeCoverShrinkJournal(journalPath, coverShrinkJournal{Path: filePath, AudioOffset: layout.AudioOffset, Region: original}); err != nil {
+		return flacSaveStats{}, err
+	}
+	stats, err := writeFLACMetadataInPlace(filePath, region)
+	recordWriteStats("shrink_covers", stats, err)
+	if err != nil {
+		return flacSaveStats{}, err
+	}
+	return stats, os.Remove(journalPath)
+}
+
+func shrinkFileCovers(filePath string, maxDim, quality int, dryRun, removeNonImages bool) ShrunkCoverFile {
+	result := ShrunkCoverFile{Path: filePath, Status: CoverShrinkWithinLimits}
+	fail := func(err error) ShrunkCoverFile {
+		result.Status, result.Error = CoverShrinkFailed, err.Error()
+		return result
+	}
+
+	needed, err := coverNeedsShrink(filePath, maxDim, removeNonImages)
+	if err != nil {
+		return fail(err)
+	}
+	if !needed {
+		return result
+	}
+
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return fail(err)
+	}
+	defer release()
+
+	f, err := parseFLACStaged(filePath)
+	if err != nil {
+		return fail(fmt.Errorf("failed to parse FLAC file: %w", err))
+	}
+	before := takeTagSnapshot(f)
+	if err := shrinkPictureBlocks(f, maxDim, quality, removeNonImages, &result); err != nil {
+		f.Close()
+		return fail(err)
+	}
+	if result.Pictures == 0 && result.Removed == 0 {
+		f.Close()
+		return result
+	}
+	if dryRun {
+		f.Close()
+		result.Status = CoverShrinkWouldShrink
+		return result
+	}
+
+	stats, err := saveShrunkCovers(f, filePath, before)
+	if err != nil {
+		return fail(err)
+	}
+	result.Status, result.InPlace = CoverShrinkShrunk, stats.kind == RewriteInPlace
+	result.io = stats
+	return result
+}
+
+// ShrinkLibraryCovers re-encodes embedded pictures larger than maxDim on
+// either side in every FLAC file under rootPath as JPEG at quality (90 when
+// 0), reporting bytes saved per file and in total. When the smaller
+// pictures fit the existing metadata region the file is patched in place
+// instead of rewritten. Files already within limits are never opened for
+// writing, so an interrupted run is resumed by running it again; a file
+// caught mid-write is restored from the journal in DataDir first.
+func ShrinkLibraryCovers(rootPath string, maxDim int, quality int, dryRun bool) (string, error) {
+	return shrinkLibraryCovers(rootPath, maxDim, quality, dryRun, false, nil, "")
+}
+
+// ShrinkLibraryCoversWithCleanup is ShrinkLibraryCovers that, with
+// removeNonImages, also drops picture blocks whose MIME type is not an image
+// or whose type is Leaflet Page or Other, such as embedded PDF booklets.
+// Front covers are only ever re-encoded. Reclaimed bytes are counted in
+// BytesSaved and, separately, BytesRemoved.
+func ShrinkLibraryCoversWithCleanup(rootPath string, maxDim int, quality int, dryRun, removeNonImages bool) (string, error) {
+	return shrinkLibraryCovers(rootPath, maxDim, quality, dryRun, removeNonImages, nil, "")
+}
+
+// ShrinkLibraryCoversWithReport is ShrinkLibraryCoversWithCleanup that also
+// reports the picture changes per file, by count and size only (see
+// ChangeReportOutput), written as JSON to reportPath and as text next to it
+// when reportPath is not empty.
+func ShrinkLibraryCoversWithReport(rootPath string, maxDim int, quality int, dryRun, removeNonImages bool, reportPath string) (string, error) {
+	changes := newChangeReport("shrink_covers", rootPath, dryRun)
+	return shrinkLibraryCovers(rootPath, maxDim, quality, dryRun, removeNonImages, changes, reportPath)
+}
+
+func shrinkLibraryCovers(rootPath string, maxDim int, quality int, dryRun, removeNonImages bool, changes *changeReport, reportPath string) (string, error) {
+	if maxDim <= 0 {
+		return "", fmt.Errorf("max dimension must be positive")
+	}
+	if quality < 0 || quality > 100 {
+		return "", fmt.Errorf("JPEG quality must be between 1 and 100")
+	}
+	if strings.TrimSpace(rootPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(rootPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+	if !dryRun {
+		if err := checkWriteAllowed(rootPath); err != nil {
+			return "", err
+		}
+	}
+
+	started := time.Now()
+	report := CoverShrinkReport{Root: rootPath, DryRun: dryRun, Files: []ShrunkCoverFile{}}
+	if journalPath := coverShrinkJournalPath(); journalPath != "" {
+		recovered, err := recoverCoverShrinkJournal(journalPath)
+		if err != nil {
+			return "", err
+		}
+		report.Recovered = recovered
+	}
+
+	files, quarantined, err := collectWritableLibraryFiles(rootPath, nil)
+	if err != nil {
+		return "", err
+	}
+	report.QuarantinedSkipped = quarantined
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file.path), ".flac") {
+			paths = append(paths, file.path)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		result := shrinkFileCovers(path, maxDim, quality, dryRun, removeNonImages)
+		report.Checked++
+		switch result.Status {
+		case CoverShrinkWithinLimits:
+			report.WithinLimits++
+			continue
+		case CoverShrinkFailed:
+			report.Failed++
+			GoLog("[ShrinkCovers] %s: %s\n", path, result.Error)
+			changes.add(FileChangeEntry{Path: path, Error: result.Error})
+		default:
+			changes.add(FileChangeEntry{Path: path, Cover: &CoverChange{
+				Reencoded:   result.Pictures,
+				Removed:     result.Removed,
+				BytesBefore: result.BytesBefore,
+				BytesAfter:  result.BytesAfter,
+			}})
+			report.Shrunk++
+			report.BytesSaved += result.BytesSaved
+			report.BytesRemoved += result.BytesRemoved
+			report.IO.add(result.io)
+		}
+		report.Files = append(report.Files, result)
+	}
+	report.IO.DurationMs = time.Since(started).Milliseconds()
+	report.ChangeReportOutput = changes.finish(reportPath)
+
+	GoLog("[ShrinkCovers] %d of %d files shrunk under %s, %d bytes saved (dry run: %v)\n",
+		report.Shrunk, report.Checked, rootPath, report.BytesSaved, dryRun)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
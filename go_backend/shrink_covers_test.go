@@ -0,0 +1,174 @@
+package gobackend
+
+import (
+	"bytes"
+	stdimage "image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-flac/flacpicture/v2"
+)
+
+// buildNoisyCover returns a PNG that compresses badly, like a real photo.
+func buildNoisyCover(t *testing.T, size int) []byte {
+	t.Helper()
+	img := stdimage.NewRGBA(stdimage.Rect(0, 0, size, size))
+	seed := uint32(1)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			seed = seed*1664525 + 1013904223
+			img.Set(x, y, color.RGBA{byte(seed >> 24), byte(seed >> 16), byte(seed >> 8), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode cover: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func writeShrinkFixture(t *testing.T, root, name string, coverSize int) string {
+	t.Helper()
+	cover := buildNoisyCover(t, coverSize)
+	src := writeMultiPictureFLAC(t, &flacpicture.MetadataBlockPicture{
+		PictureType: flacpicture.PictureTypeFrontCover, MIME: "image/png",
+		Width: uint32(coverSize), Height: uint32(coverSize), ColorDepth: 32, ImageData: cover,
+	})
+	dst := filepath.Join(root, name)
+	if err := os.Rename(src, dst); err != nil {
+		t.Fatalf("move fixture: %v", err)
+	}
+	return dst
+}
+
+func useShrinkDataDir(t *testing.T) string {
+	t.Helper()
+	original := GetBackendConfig()
+	t.Cleanup(func() { SetBackendConfig(original) })
+	cfg := original
+	cfg.DataDir = t.TempDir()
+	if err := SetBackendConfig(cfg); err != nil {
+		t.Fatalf("SetBackendConfig: %v", err)
+	}
+	return cfg.DataDir
+}
+
+func TestShrinkLibraryCoversRewritesInPlace(t *testing.T) {
+	useShrinkDataDir(t)
+	root := t.TempDir()
+	big := writeShrinkFixture(t, root, "big.flac", 300)
+	small := writeShrinkFixture(t, root, "small.flac", 40)
+	smallBefore := mustReadFile(t, small)
+	audioBefore, _ := AudioHash(big)
+
+	raw, err := ShrinkLibraryCovers(root, 100, 80, true)
+	report := mustDecodeJSON[CoverShrinkReport](t, raw, err)
+	if report.Shrunk != 1 || report.BytesSaved <= 0 || report.Files[0].Status != CoverShrinkWouldShrink {
+		t.Fatalf("unexpected dry run: %+v", report)
+	}
+	if pictures, _ := GetPictures(big); pictures[0].Width != 300 {
+		t.Fatal("dry run must not modify files")
+	}
+
+	rewritesBefore := flacRewriteCount.Load()
+	raw, err = ShrinkLibraryCovers(root, 100, 80, false)
+	report = mustDecodeJSON[CoverShrinkReport](t, raw, err)
+	if report.Checked != 2 || report.Shrunk != 1 || report.WithinLimits != 1 {
+		t.Fatalf("unexpected counts: %+v", report)
+	}
+	got := report.Files[0]
+	if got.Path != big || !got.InPlace || got.BytesSaved != got.BytesBefore-got.BytesAfter || got.BytesSaved <= 0 {
+		t.Fatalf("unexpected file result: %+v", got)
+	}
+	if flacRewriteCount.Load() != rewritesBefore {
+		t.Fatal("a shrunk cover that fits must not trigger a full rewrite")
+	}
+
+	pictures, err := GetPictures(big)
+	if err != nil || len(pictures) != 1 || pictures[0].Width != 100 || pictures[0].MIME != "image/jpeg" {
+		t.Fatalf("unexpected pictures after shrink: %+v/%v", pictures, err)
+	}
+	if audioAfter, _ := AudioHash(big); audioAfter != audioBefore {
+		t.Fatal("audio frames changed")
+	}
+	if footprint, err := GetMetadataFootprint(big); err != nil || footprint.Padding == 0 {
+		t.Fatalf("expected the freed space to become padding: %+v/%v", footprint, err)
+	}
+	if !bytes.Equal(mustReadFile(t, small), smallBefore) {
+		t.Fatal("files within limits must be untouched")
+	}
+
+	raw, err = ShrinkLibraryCovers(root, 100, 80, false)
+	if report = mustDecodeJSON[CoverShrinkReport](t, raw, err); report.Shrunk != 0 || report.WithinLimits != 2 {
+		t.Fatalf("a second run should find nothing to do: %+v", report)
+	}
+}
+
+func TestShrinkLibraryCoversRestoresInterruptedWrite(t *testing.T) {
+	dataDir := useShrinkDataDir(t)
+	root := t.TempDir()
+	path := writeShrinkFixture(t, root, "track.flac", 200)
+	original := mustReadFile(t, path)
+
+	footprint, err := GetMetadataFootprint(path)
+	if err != nil {
+		t.Fatalf("GetMetadataFootprint: %v", err)
+	}
+	journal := coverShrinkJournal{Path: path, AudioOffset: footprint.MetadataBytes, Region: original[4:footprint.MetadataBytes]}
+	if err := writeCoverShrinkJournal(filepath.Join(dataDir, coverShrinkJournalFileName), journal); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+	// Simulate a write cut off halfway through the metadata region.
+	if _, err := writeFLACMetadataInPlace(path, make([]byte, 64)); err != nil {
+		t.Fatalf("corrupt: %v", err)
+	}
+
+	raw, err := ShrinkLibraryCovers(root, 1000, 0, true)
+	report := mustDecodeJSON[CoverShrinkReport](t, raw, err)
+	if report.Recovered != path || !bytes.Equal(mustReadFile(t, path), original) {
+		t.Fatalf("expected the interrupted file to be restored: %+v", report)
+	}
+	if fileExists(filepath.Join(dataDir, coverShrinkJournalFileName)) {
+		t.Fatal("journal should be removed after recovery")
+	}
+}
+
+func TestShrinkLibraryCoversRejectsBadLimits(t *testing.T) {
+	if _, err := ShrinkLibraryCovers(t.TempDir(), 0, 90, true); err == nil {
+		t.Fatal("expected error for zero max dimension")
+	}
+	if _, err := ShrinkLibraryCovers(t.TempDir(), 500, 101, true); err == nil {
+		t.Fatal("expected error for out-of-range quality")
+	}
+}
+
+func TestShrinkLibraryCoversWithCleanupRemovesBooklets(t *testing.T) {
+	useShrinkDataDir(t)
+	root := t.TempDir()
+	src := writeMultiPictureFLAC(t, bookletPictures(t)...)
+	path := filepath.Join(root, "booklet.flac")
+	if err := os.Rename(src, path); err != nil {
+		t.Fatalf("move fixture: %v", err)
+	}
+
+	raw, err := ShrinkLibraryCovers(root, 1000, 80, false)
+	if report := mustDecodeJSON[CoverShrinkReport](t, raw, err); report.WithinLimits != 1 {
+		t.Fatalf("booklets must be kept without cleanup: %+v", report)
+	}
+
+	raw, err = ShrinkLibraryCoversWithCleanup(root, 1000, 80, false, true)
+	report := mustDecodeJSON[CoverShrinkReport](t, raw, err)
+	if report.Shrunk != 1 || report.BytesRemoved <= 8192 || report.BytesSaved != report.BytesRemoved {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if got := report.Files[0]; got.Removed != 2 || got.Pictures != 0 {
+		t.Fatalf("unexpected file result: %+v", got)
+	}
+	pictures, err := GetPictures(path)
+	if err != nil || len(pictures) != 1 || pictures[0].TypeName != "Front Cover" {
+		t.Fatalf("expected only the front cover kept: %+v/%v", pictures, err)
+	}
+}
@@ -0,0 +1,214 @@
+package gobackend
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+// RepairStreamInfo result statuses.
+const (
+	StreamInfoRepaired = "repaired"
+	StreamInfoOK       = "ok"
+	// StreamInfoMismatch means STREAMINFO holds a sample count that differs
+	// from the frames. That is usually a truncated download, which a
+	// rewrite would hide from VerifyFLAC, so it is only reported.
+	StreamInfoMismatch = "mismatch"
+)
+
+// StreamInfoRepairResult is the result of RepairStreamInfo. MD5 is the
+// audio MD5 computed by a full decode.
+type StreamInfoRepairResult struct {
+	Status         string `json:"status"`
+	StoredSamples  int64  `json:"stored_samples"`
+	CountedSamples int64  `json:"counted_samples"`
+	Duration       int    `json:"duration"`
+	Decoded        bool   `json:"decoded"`
+	MD5            string `json:"md5,omitempty"`
+	MD5Written     bool   `json:"md5_written,omitempty"`
+	MD5Mismatch    bool   `json:"md5_mismatch,omitempty"`
+}
+
+// countFLACFrameSamples sums the block sizes of the frames of filePath
+// without decoding them. Frames with a bad CRC fail the count, since their
+// block size cannot be trusted.
+func countFLACFrameSamples(filePath string) (int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	layout, err := scanFLACMetadataBlocks(file, info.Size())
+	if err != nil {
+		return 0, err
+	}
+	if len(layout.Issues) > 0 {
+		return 0, fmt.Errorf("metadata is damaged: %s", layout.Issues[0])
+	}
+	audioEnd := info.Size()
+	if junk := findFLACTrailingJunk(file, layout.AudioOffset, info.Size()); len(junk) > 0 {
+		audioEnd = junk[0].Offset
+	}
+	if _, err := file.Seek(layout.AudioOffset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	_, err = walkFLACFrames(io.LimitReader(file, audioEnd-layout.AudioOffset), layout.AudioOffset, func(frame flacFrameInfo) error {
+		if !frame.CRCValid {
+			return fmt.Errorf("frame at %d fails its CRC-16 check", frame.Offset)
+		}
+		total += int64(frame.Header.BlockSize)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan frames: %w", err)
+	}
+	return total, nil
+}
+
+// decodeFLACSamples decodes filePath and returns its sample count and the
+// MD5 of the PCM as STREAMINFO defines it: interleaved, little-endian,
+// signed samples of whole bytes.
+func decodeFLACSamples(filePath string) (int64, []byte, error) {
+	dec, file, err := openFLACDecoder(filePath)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+
+	bytesPerSample := (dec.format.BitsPerSample + 7) / 8
+	if bytesPerSample < 1 || bytesPerSample > 4 {
+		return 0, nil, fmt.Errorf("unsupported bits per sample: %d", dec.format.BitsPerSample)
+	}
+	pcmHash := md5.New()
+	sampleBuf := make([]byte, 4)
+	var total int64
+	for {
+		channels, err := dec.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to decode frame after sample %d: %w", total, err)
+		}
+		for i := range channels[0] {
+			for _, ch := range channels {
+				binary.LittleEndian.PutUint32(sampleBuf, uint32(ch[i]))
+				pcmHash.Write(sampleBuf[:bytesPerSample])
+			}
+		}
+		total += int64(len(channels[0]))
+	}
+	return total, pcmHash.Sum(nil), nil
+}
+
+// RepairStreamInfo fills in a STREAMINFO total sample count of zero, which
+// some streaming rippers write and which makes players show 0:00, from the
+// frames of filePath. Without decode the block sizes of the frame headers
+// are summed; with decode the audio is decoded as well and its MD5 is
+// written when STREAMINFO has none. A non-zero count or MD5 that disagrees
+// with the audio is reported and left alone. The file is saved with
+// saveFLACAtomic.
+func RepairStreamInfo(filePath string, decode bool) (string, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, true, func(localPath string) (string, error) {
+			return RepairStreamInfo(localPath, decode)
+		})
+	}
+	if err := checkWriteAllowed(filePath); err != nil {
+		return "", err
+	}
+
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	f, err := parseFLACStaged(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+	if len(f.Meta) == 0 || f.Meta[0].Type != flac.StreamInfo || len(f.Meta[0].Data) != 34 {
+		f.Close()
+		return "", fmt.Errorf("missing STREAMINFO")
+	}
+	info := f.Meta[0].Data
+	_, sampleRate, stored := parseFLACStreamInfoQuality(info)
+	result := StreamInfoRepairResult{StoredSamples: stored, Decoded: decode}
+
+	var audioMD5 []byte
+	if decode {
+		result.CountedSamples, audioMD5, err = decodeFLACSamples(filePath)
+		result.MD5 = hex.EncodeToString(audioMD5)
+	} else {
+		result.CountedSamples, err = countFLACFrameSamples(filePath)
+	}
+	if err != nil {
+		f.Close()
+		return "", err
+	}
+	if result.CountedSamples == 0 {
+		f.Close()
+		return "", fmt.Errorf("no audio frames found")
+	}
+	if result.CountedSamples > 0xFFFFFFFFF {
+		f.Close()
+		return "", fmt.Errorf("sample count %d does not fit STREAMINFO", result.CountedSamples)
+	}
+
+	repaired := bytes.Clone(info)
+	changed := false
+	if stored == 0 {
+		packed := binary.BigEndian.Uint64(repaired[10:18])&^0xFFFFFFFFF | uint64(result.CountedSamples)
+		binary.BigEndian.PutUint64(repaired[10:18], packed)
+		changed = true
+	} else if stored != result.CountedSamples {
+		result.Status = StreamInfoMismatch
+	}
+	if audioMD5 != nil {
+		switch {
+		case bytes.Equal(info[18:34], make([]byte, 16)):
+			copy(repaired[18:34], audioMD5)
+			result.MD5Written = true
+			changed = true
+		case !bytes.Equal(info[18:34], audioMD5):
+			result.MD5Mismatch = true
+		}
+	}
+
+	if result.Status == "" {
+		result.Status = StreamInfoOK
+	}
+	if changed && result.Status != StreamInfoMismatch {
+		f.Meta[0].Data = repaired
+		if _, err := saveFLACAtomicStats(f, filePath, "repair_streaminfo"); err != nil {
+			return "", err
+		}
+		result.Status = StreamInfoRepaired
+		GoLog("[StreamInfo] Repaired %s: %d samples, md5 written: %v\n", filePath, result.CountedSamples, result.MD5Written)
+	} else {
+		f.Close()
+		result.MD5Written = false
+	}
+	result.Duration = flacDurationSeconds(result.CountedSamples, sampleRate)
+
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
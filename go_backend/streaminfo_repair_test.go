@@ -0,0 +1,127 @@
+package gobackend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// breakStreamInfo zeroes the total sample count of the FLAC at path, and
+// its audio MD5 when clearMD5 is set, as streaming rippers leave them.
+func breakStreamInfo(t *testing.T, path string, clearMD5 bool) {
+	t.Helper()
+	data := mustReadFile(t, path)
+	// STREAMINFO data starts after the marker and block header.
+	info := data[8:42]
+	info[13] &^= 0x0F
+	clear(info[14:18])
+	if clearMD5 {
+		clear(info[18:34])
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	invalidateMetadataCache(path)
+}
+
+func repairStreamInfoFixture(t *testing.T, path string, decode bool) StreamInfoRepairResult {
+	t.Helper()
+	out, err := RepairStreamInfo(path, decode)
+	result := mustDecodeJSON[StreamInfoRepairResult](t, out, err)
+	return result
+}
+
+func TestRepairStreamInfoCountsFrames(t *testing.T) {
+	root := t.TempDir()
+	path := writeConsistencyFixture(t, root, "a.flac", Metadata{Title: "A"})
+	want, err := GetAudioQuality(path)
+	if err != nil || want.TotalSamples == 0 || want.MissingSampleCount {
+		t.Fatalf("fixture quality = %+v %v", want, err)
+	}
+
+	breakStreamInfo(t, path, false)
+	broken, err := GetAudioQuality(path)
+	if err != nil || !broken.MissingSampleCount || broken.TotalSamples != 0 {
+		t.Fatalf("broken quality = %+v %v", broken, err)
+	}
+
+	out, err := AuditLibrary(root, "")
+	audit := mustDecodeJSON[LibraryAuditReport](t, out, err)
+	if audit.MissingSampleCountFiles != 1 || audit.Flagged != 1 || !audit.Files[0].MissingSampleCount {
+		t.Fatalf("audit = %+v", audit)
+	}
+
+	result := repairStreamInfoFixture(t, path, false)
+	if result.Status != StreamInfoRepaired || result.StoredSamples != 0 || result.CountedSamples != want.TotalSamples || result.MD5 != "" {
+		t.Fatalf("result = %+v", result)
+	}
+	if got, err := GetAudioQuality(path); err != nil || got != want {
+		t.Fatalf("repaired quality = %+v %v, want %+v", got, err, want)
+	}
+	if md, err := ReadMetadata(path); err != nil || md.Title != "A" {
+		t.Fatalf("tags after repair: %+v %v", md, err)
+	}
+
+	before := mustReadFile(t, path)
+	if result := repairStreamInfoFixture(t, path, false); result.Status != StreamInfoOK {
+		t.Fatalf("second repair = %+v", result)
+	}
+	if string(mustReadFile(t, path)) != string(before) {
+		t.Fatal("second repair rewrote the file")
+	}
+}
+
+func TestRepairStreamInfoDecodeWritesMD5(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{})
+	original := mustReadFile(t, path)
+
+	breakStreamInfo(t, path, true)
+	result := repairStreamInfoFixture(t, path, true)
+	if result.Status != StreamInfoRepaired || !result.Decoded || !result.MD5Written || result.MD5Mismatch {
+		t.Fatalf("result = %+v", result)
+	}
+	if string(mustReadFile(t, path)[8:42]) != string(original[8:42]) {
+		t.Fatal("repaired STREAMINFO differs from the original")
+	}
+
+	data := mustReadFile(t, path)
+	data[30] ^= 0xFF
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if result := repairStreamInfoFixture(t, path, true); result.Status != StreamInfoOK || !result.MD5Mismatch || result.MD5Written {
+		t.Fatalf("md5 mismatch result = %+v", result)
+	}
+	if string(mustReadFile(t, path)) != string(data) {
+		t.Fatal("mismatching MD5 was overwritten")
+	}
+}
+
+func TestRepairStreamInfoLeavesMismatchedCount(t *testing.T) {
+	path := writeConsistencyFixture(t, t.TempDir(), filepath.Join("x", "a.flac"), Metadata{})
+	data := mustReadFile(t, path)
+	data[8+17]++
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if result := repairStreamInfoFixture(t, path, false); result.Status != StreamInfoMismatch || result.StoredSamples != result.CountedSamples+1 {
+		t.Fatalf("result = %+v", result)
+	}
+	if string(mustReadFile(t, path)) != string(data) {
+		t.Fatal("mismatched count was rewritten")
+	}
+}
+
+func TestRepairStreamInfoThroughFileOpener(t *testing.T) {
+	opener := useDirFileOpener(t)
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "A"})
+	breakStreamInfo(t, path, false)
+	uri := serveThroughOpener(t, opener, path, "a.flac")
+
+	if result := repairStreamInfoFixture(t, uri, false); result.Status != StreamInfoRepaired || opener.writes != 1 {
+		t.Fatalf("result = %+v after %d writes", result, opener.writes)
+	}
+	if got, err := GetAudioQuality(uri); err != nil || got.MissingSampleCount || got.TotalSamples == 0 {
+		t.Fatalf("repaired quality = %+v %v", got, err)
+	}
+}
@@ -0,0 +1,157 @@
+package gobackend
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+// standardPaddingSize matches libFLAC's default, leaving room for a typical
+// re-tag without rewriting the audio.
+const standardPaddingSize = 8192
+
+type StripOptions struct {
+	RemovePictures     bool `json:"remove_pictures"`
+	RemoveApplications bool `json:"remove_applications"`
+	// RemoveTagHistory drops only the tag journal block, keeping other
+	// application blocks.
+	RemoveTagHistory bool `json:"remove_tag_history"`
+	// RemoveNonImagePictures drops only picture blocks whose MIME type is
+	// not an image or whose type is Leaflet Page or Other, such as embedded
+	// PDF booklets. The front cover is always kept.
+	RemoveNonImagePictures bool `json:"remove_non_image_pictures"`
+}
+
+type StrippedComment struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type StrippedPicture struct {
+	PictureType int    `json:"picture_type"`
+	MIME        string `json:"mime"`
+	Size        int    `json:"size"`
+}
+
+type StripReport struct {
+	RemovedComments     []StrippedComment `json:"removed_comments"`
+	RemovedPictures     []StrippedPicture `json:"removed_pictures"`
+	RemovedApplications []string          `json:"removed_applications"`
+	KeptComments        int               `json:"kept_comments"`
+	PaddingBefore       int               `json:"padding_before"`
+	PaddingAfter        int               `json:"padding_after"`
+	// PictureBytesReclaimed is the size of the removed picture blocks.
+	PictureBytesReclaimed int64 `json:"picture_bytes_reclaimed"`
+}
+
+func splitVorbisComment(comment string) (string, string) {
+	key, value, _ := strings.Cut(comment, "=")
+	return key, value
+}
+
+// StripMetadata removes every Vorbis comment whose key is not in keep
+// (case-insensitive) and, per opts, all pictures or only booklet-like ones,
+// and application blocks.
+// Existing padding is replaced with a single block of the configured
+// PaddingTarget and the file is replaced atomically. STREAMINFO, SEEKTABLE
+// and CUESHEET are never touched. The report lists exactly what was removed.
+func StripMetadata(filePath string, keep []string, opts StripOptions) (*StripReport, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, true, func(localPath string) (*StripReport, error) {
+			return StripMetadata(localPath, keep, opts)
+		})
+	}
+	if err := checkWriteAllowed(filePath); err != nil {
+		return nil, err
+	}
+
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	f, err := parseFLACStaged(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+
+	keepKeys := make(map[string]bool, len(keep))
+	for _, key := range keep {
+		keepKeys[strings.ToUpper(strings.TrimSpace(key))] = true
+	}
+
+	report := &StripReport{
+		RemovedComments:     []StrippedComment{},
+		RemovedPictures:     []StrippedPicture{},
+		RemovedApplications: []string{},
+		PaddingAfter:        GetBackendConfig().PaddingTarget,
+	}
+	kept := make([]*flac.MetaDataBlock, 0, len(f.Meta)+1)
+
+	for _, meta := range f.Meta {
+		switch meta.Type {
+		case flac.VorbisComment:
+			cmt, err := parseVorbisCommentBlock(*meta)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to parse vorbis comment: %w", err)
+			}
+			comments := cmt.Comments[:0]
+			for _, comment := range cmt.Comments {
+				key, value := splitVorbisComment(comment)
+				if keepKeys[strings.ToUpper(key)] {
+					comments = append(comments, comment)
+					continue
+				}
+				report.RemovedComments = append(report.RemovedComments, StrippedComment{Key: key, Value: value})
+			}
+			cmt.Comments = comments
+			report.KeptComments = len(comments)
+			block := cmt.Marshal()
+			kept = append(kept, &block)
+
+		case flac.Picture:
+			removed := StrippedPicture{Size: len(meta.Data)}
+			pic, err := parseFLACPictureMeta(*meta)
+			if err == nil {
+				removed = StrippedPicture{PictureType: int(pic.PictureType), MIME: pic.MIME, Size: len(pic.ImageData)}
+			}
+			disposable := err == nil && isDisposablePicture(pic.PictureType, pictureMIME(pic))
+			if !opts.RemovePictures && !(opts.RemoveNonImagePictures && disposable) {
+				kept = append(kept, meta)
+				continue
+			}
+			report.RemovedPictures = append(report.RemovedPictures, removed)
+			report.PictureBytesReclaimed += 4 + int64(len(meta.Data))
+
+		case flac.Application:
+			id := ""
+			if len(meta.Data) >= 4 {
+				id = string(meta.Data[:4])
+			}
+			if !opts.RemoveApplications && !(opts.RemoveTagHistory && id == tagHistoryAppID) {
+				kept = append(kept, meta)
+				continue
+			}
+			report.RemovedApplications = append(report.RemovedApplications, id)
+
+		case flac.Padding:
+			report.PaddingBefore += len(meta.Data)
+
+		default:
+			kept = append(kept, meta)
+		}
+	}
+
+	f.Meta = kept
+
+	if _, err := rewriteFLACFile(f, filePath); err != nil {
+		return nil, err
+	}
+
+	GoLog("[Strip] Removed %d comment(s), %d picture(s), %d application block(s): %s\n",
+		len(report.RemovedComments), len(report.RemovedPictures), len(report.RemovedApplications), filePath)
+	return report, nil
+}
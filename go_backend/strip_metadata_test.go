@@ -0,0 +1,109 @@
+package gobackend
+
+import (
+	"testing"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+func TestStripMetadataKeepsAllowListAndReportsRemovals(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", Artist: "Artist", Comment: "ripped by me", ISRC: "USABC0100001"})
+	cover, _ := buildSelfTestCover()
+	if err := EmbedMetadataWithCoverData(path, Metadata{}, cover); err != nil {
+		t.Fatal(err)
+	}
+	hashBefore, _ := AudioHash(path)
+
+	report, err := StripMetadata(path, []string{"title", "ARTIST"}, StripOptions{RemovePictures: true})
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	if report.KeptComments != 2 || len(report.RemovedPictures) != 1 || report.RemovedPictures[0].Size != len(cover) {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	removed := map[string]string{}
+	for _, c := range report.RemovedComments {
+		removed[c.Key] = c.Value
+	}
+	if removed["COMMENT"] != "ripped by me" || removed["ISRC"] != "USABC0100001" {
+		t.Fatalf("removed comments = %v", removed)
+	}
+
+	meta, _ := ReadMetadata(path)
+	if meta.Title != "Song" || meta.Artist != "Artist" || meta.Comment != "" || meta.ISRC != "" {
+		t.Fatalf("unexpected tags after strip: %+v", meta)
+	}
+	if _, err := ExtractCoverArt(path); err == nil {
+		t.Fatal("cover should be removed")
+	}
+	if hashAfter, _ := AudioHash(path); hashAfter != hashBefore {
+		t.Fatal("audio frames changed")
+	}
+
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	last := f.Meta[len(f.Meta)-1]
+	if last.Type != flac.Padding || len(last.Data) != standardPaddingSize {
+		t.Fatalf("expected trailing standard padding, got type %d len %d", last.Type, len(last.Data))
+	}
+}
+
+func TestStripMetadataKeepsPicturesAndApplicationsByDefault(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	f, err := flac.ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Meta = append(f.Meta, &flac.MetaDataBlock{Type: flac.Application, Data: []byte("TEST payload")})
+	if err := saveFLACAtomic(f, path); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := StripMetadata(path, nil, StripOptions{})
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	if len(report.RemovedApplications) != 0 {
+		t.Fatalf("application removed without option: %+v", report)
+	}
+
+	report, _ = StripMetadata(path, nil, StripOptions{RemoveApplications: true})
+	if len(report.RemovedApplications) != 1 || report.RemovedApplications[0] != "TEST" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestStripMetadataRemovesNonImagePicturesOnly(t *testing.T) {
+	path := writeMultiPictureFLAC(t, bookletPictures(t)...)
+	report, err := StripMetadata(path, []string{"TITLE"}, StripOptions{RemoveNonImagePictures: true})
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	if len(report.RemovedPictures) != 2 || report.PictureBytesReclaimed <= 8192 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	pictures, err := GetPictures(path)
+	if err != nil || len(pictures) != 1 || pictures[0].TypeName != "Front Cover" {
+		t.Fatalf("expected only the front cover kept: %+v/%v", pictures, err)
+	}
+}
+
+func TestStripMetadataThroughFileOpener(t *testing.T) {
+	opener := useDirFileOpener(t)
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", Comment: "ripped by me"})
+	uri := serveThroughOpener(t, opener, path, "song.flac")
+
+	report, err := StripMetadata(uri, []string{"TITLE"}, StripOptions{})
+	if err != nil {
+		t.Fatalf("StripMetadata: %v", err)
+	}
+	if report.KeptComments != 1 || opener.writes != 1 {
+		t.Fatalf("unexpected report: %+v after %d writes", report, opener.writes)
+	}
+	if meta, err := ReadMetadata(uri); err != nil || meta.Title != "Song" || meta.Comment != "" {
+		t.Fatalf("tags after strip: %+v %v", meta, err)
+	}
+}
@@ -0,0 +1,399 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-flac/go-flac/v2"
+)
+
+// defaultTagKeyAliases maps Vorbis keys written by other taggers to the key
+// this backend writes. Both total keys are canonical (see trackTotalKeys),
+// so only their misspellings are folded.
+var defaultTagKeyAliases = map[string]string{
+	"ALBUM ARTIST": "ALBUMARTIST",
+	"ALBUM_ARTIST": "ALBUMARTIST",
+	"ALBUM-ARTIST": "ALBUMARTIST",
+	"TRACK":        "TRACKNUMBER",
+	"TRACK NUMBER": "TRACKNUMBER",
+	"TRACK_NUMBER": "TRACKNUMBER",
+	"DISC":         "DISCNUMBER",
+	"DISC NUMBER":  "DISCNUMBER",
+	"DISC_NUMBER":  "DISCNUMBER",
+	"TRACKSTOTAL":  "TRACKTOTAL",
+	"TRACK TOTAL":  "TRACKTOTAL",
+	"TRACK_TOTAL":  "TRACKTOTAL",
+	"TOTAL TRACKS": "TOTALTRACKS",
+	"TOTAL_TRACKS": "TOTALTRACKS",
+	"DISCSTOTAL":   "DISCTOTAL",
+	"DISC TOTAL":   "DISCTOTAL",
+	"DISC_TOTAL":   "DISCTOTAL",
+	"TOTAL DISCS":  "TOTALDISCS",
+	"TOTAL_DISCS":  "TOTALDISCS",
+	"YEAR":         "DATE",
+	"RELEASE DATE": "DATE",
+	"RELEASE_DATE": "DATE",
+	"COMMENTS":     "COMMENT",
+	"DESC":         "DESCRIPTION",
+	"SYNOPSIS":     "DESCRIPTION",
+}
+
+// TagKeyChange is one alias key folded into its canonical key.
+type TagKeyChange struct {
+	Key       string   `json:"key"`
+	Canonical string   `json:"canonical"`
+	Values    []string `json:"values"`
+}
+
+// TagKeyConflict is a canonical key whose spellings carried different
+// values. The values are merged, canonical spelling first, and reported so
+// the result can be reviewed.
+type TagKeyConflict struct {
+	Canonical string              `json:"canonical"`
+	Values    map[string][]string `json:"values"`
+	Merged    []string            `json:"merged"`
+}
+
+type TagCanonicalizationResult struct {
+	Path      string           `json:"path"`
+	DryRun    bool             `json:"dry_run"`
+	Changes   []TagKeyChange   `json:"changes"`
+	Conflicts []TagKeyConflict `json:"conflicts,omitempty"`
+	Error     string           `json:"error,omitempty"`
+
+	io     flacSaveStats
+	fields []FieldChange
+}
+
+type TagCanonicalizationReport struct {
+	Root               string `json:"root"`
+	DryRun             bool   `json:"dry_run"`
+	Checked            int    `json:"checked"`
+	Changed            int    `json:"changed"`
+	Conflicted         int    `json:"conflicted"`
+	Failed             int    `json:"failed"`
+	QuarantinedSkipped int    `json:"quarantined_skipped"`
+	Skipped            int    `json:"skipped"`
+	// Resumed counts files skipped because the journal shows them done.
+	Resumed int                         `json:"resumed,omitempty"`
+	IO      WriteStats                  `json:"io"`
+	Files   []TagCanonicalizationResult `json:"files"`
+	ChangeReportOutput
+}
+
+// parseTagKeyMapping merges mappingJSON, an object of alias to canonical
+// key, over defaultTagKeyAliases. An empty canonical key drops a default
+// alias. Keys are compared upper-cased.
+func parseTagKeyMapping(mappingJSON string) (map[string]string, error) {
+	mapping := make(map[string]string, len(defaultTagKeyAliases))
+	for alias, canonical := range defaultTagKeyAliases {
+		mapping[alias] = canonical
+	}
+	if strings.TrimSpace(mappingJSON) == "" {
+		return mapping, nil
+	}
+
+	var custom map[string]string
+	if err := json.Unmarshal([]byte(mappingJSON), &custom); err != nil {
+		return nil, fmt.Errorf("invalid tag mapping JSON: %w", err)
+	}
+	for alias, canonical := range custom {
+		if err := validateVorbisKey(alias); err != nil {
+			return nil, err
+		}
+		alias = strings.ToUpper(alias)
+		if canonical == "" {
+			delete(mapping, alias)
+			continue
+		}
+		if err := validateVorbisKey(canonical); err != nil {
+			return nil, err
+		}
+		mapping[alias] = strings.ToUpper(canonical)
+	}
+	for alias, canonical := range mapping {
+		if _, chained := mapping[canonical]; chained {
+			return nil, fmt.Errorf("tag mapping %s -> %s maps onto another alias", alias, canonical)
+		}
+	}
+	return mapping, nil
+}
+
+// canonicalizeCommentList folds every alias in raw into its canonical key.
+// Each canonical key is written once, at the position of its first
+// spelling, with the canonical values first and the new alias values after.
+// Differently cased spellings of a canonical key are folded too.
+func canonicalizeCommentList(raw []string, mapping map[string]string) ([]string, []TagKeyChange, []TagKeyConflict) {
+	isCanonical := make(map[string]bool, len(mapping))
+	for _, canonical := range mapping {
+		isCanonical[canonical] = true
+	}
+	canonicalOf := func(key string) string {
+		upper := strings.ToUpper(key)
+		if canonical, ok := mapping[upper]; ok {
+			return canonical
+		}
+		return upper
+	}
+
+	// Spellings per canonical key, in file order.
+	spellings := make(map[string][]string)
+	values := make(map[string][]string)
+	for _, comment := range raw {
+		key, value := splitVorbisComment(comment)
+		canonical := canonicalOf(key)
+		if !isCanonical[canonical] {
+			continue
+		}
+		if _, seen := values[key]; !seen && key != canonical {
+			spellings[canonical] = append(spellings[canonical], key)
+		}
+		values[key] = append(values[key], value)
+	}
+	if len(spellings) == 0 {
+		return raw, nil, nil
+	}
+
+	var changes []TagKeyChange
+	var conflicts []TagKeyConflict
+	merged := make(map[string][]string, len(spellings))
+	for canonical, keys := range spellings {
+		result := slices.Clone(values[canonical])
+		conflict := TagKeyConflict{Canonical: canonical, Values: make(map[string][]string)}
+		if len(result) > 0 {
+			conflict.Values[canonical] = values[canonical]
+		}
+		for _, key := range keys {
+			changes = append(changes, TagKeyChange{Key: key, Canonical: canonical, Values: values[key]})
+			conflict.Values[key] = values[key]
+			for _, value := range values[key] {
+				if !slices.Contains(result, value) {
+					result = append(result, value)
+				}
+			}
+		}
+		merged[canonical] = result
+		for _, spelled := range conflict.Values {
+			if !slices.Equal(spelled, result) {
+				conflict.Merged = result
+				conflicts = append(conflicts, conflict)
+				break
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Canonical != changes[j].Canonical {
+			return changes[i].Canonical < changes[j].Canonical
+		}
+		return changes[i].Key < changes[j].Key
+	})
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Canonical < conflicts[j].Canonical })
+
+	out := make([]string, 0, len(raw))
+	for _, comment := range raw {
+		key, _ := splitVorbisComment(comment)
+		canonical := canonicalOf(key)
+		result, rewritten := merged[canonical]
+		if !rewritten {
+			out = append(out, comment)
+			continue
+		}
+		if result == nil {
+			// Already emitted at the first spelling.
+			continue
+		}
+		for _, value := range result {
+			out = append(out, canonical+"="+value)
+		}
+		merged[canonical] = nil
+	}
+	return out, changes, conflicts
+}
+
+func readVorbisCommentList(filePath string) ([]string, error) {
+	f, err := parseFLACStaged(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+	defer f.Close()
+	for _, meta := range f.Meta {
+		if meta.Type != flac.VorbisComment {
+			continue
+		}
+		cmt, err := parseVorbisCommentBlock(*meta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse vorbis comment: %w", err)
+		}
+		return cmt.Comments, nil
+	}
+	return nil, nil
+}
+
+func canonicalizeFileTags(filePath string, mapping map[string]string, dryRun bool) (*TagCanonicalizationResult, error) {
+	result := &TagCanonicalizationResult{Path: filePath, DryRun: dryRun, Changes: []TagKeyChange{}}
+	if dryRun {
+		raw, err := readVorbisCommentList(filePath)
+		if err != nil {
+			return nil, err
+		}
+		var out []string
+		out, result.Changes, result.Conflicts = canonicalizeCommentList(raw, mapping)
+		result.fields = diffCommentFields(raw, out)
+		if result.Changes == nil {
+			result.Changes = []TagKeyChange{}
+		}
+		return result, nil
+	}
+
+	if err := checkWriteAllowed(filePath); err != nil {
+		return nil, err
+	}
+	stats, err := editVorbisCommentListStats(filePath, "canonicalize_tags", func(raw []string) ([]string, bool) {
+		out, changes, conflicts := canonicalizeCommentList(raw, mapping)
+		if len(changes) == 0 {
+			return nil, false
+		}
+		result.Changes, result.Conflicts = changes, conflicts
+		result.fields = diffCommentFields(raw, out)
+		return out, true
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.io = stats
+	return result, nil
+}
+
+// CanonicalizeTags renames the alias Vorbis keys of a FLAC file ("ALBUM
+// ARTIST", "album_artist", "YEAR", ...) to the keys this backend writes.
+// mappingJSON is an optional object of extra alias to canonical keys merged
+// over the built-in list. When an alias and its canonical key both exist the
+// values are merged, and differing values are reported as conflicts. With
+// dryRun the file is only read.
+func CanonicalizeTags(filePath string, mappingJSON string, dryRun bool) (string, error) {
+	mapping, err := parseTagKeyMapping(mappingJSON)
+	if err != nil {
+		return "", err
+	}
+	result, err := canonicalizeFileTags(filePath, mapping, dryRun)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+// CanonicalizeLibraryTags runs CanonicalizeTags on every FLAC file under
+// rootPath. Only files that changed (or would change) or failed are listed;
+// other formats are counted as skipped.
+func CanonicalizeLibraryTags(rootPath string, mappingJSON string, dryRun bool) (string, error) {
+	return canonicalizeLibraryTags(rootPath, mappingJSON, dryRun, "", nil, "")
+}
+
+// CanonicalizeLibraryTagsWithReport is CanonicalizeLibraryTags that also
+// reports the old and new values of every changed field (see
+// ChangeReportOutput), written as JSON to reportPath and as text next to it
+// when reportPath is not empty.
+func CanonicalizeLibraryTagsWithReport(rootPath, mappingJSON string, dryRun bool, reportPath string) (string, error) {
+	changes := newChangeReport(batchOpCanonicalizeTags, rootPath, dryRun)
+	return canonicalizeLibraryTags(rootPath, mappingJSON, dryRun, "", changes, reportPath)
+}
+
+// CanonicalizeLibraryTagsWithJournal is CanonicalizeLibraryTags (not a dry
+// run) that records each finished file in the journal at journalPath. If
+// the run is interrupted, calling it again with the same journal, or
+// Resume(journalPath), skips the files already done.
+func CanonicalizeLibraryTagsWithJournal(rootPath, mappingJSON, journalPath string) (string, error) {
+	return canonicalizeLibraryTags(rootPath, mappingJSON, false, journalPath, nil, "")
+}
+
+func canonicalizeLibraryTags(rootPath, mappingJSON string, dryRun bool, journalPath string, changes *changeReport, reportPath string) (string, error) {
+	mapping, err := parseTagKeyMapping(mappingJSON)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(rootPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(rootPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+	if !dryRun {
+		if err := checkWriteAllowed(rootPath); err != nil {
+			return "", err
+		}
+	}
+
+	files, quarantined, err := collectWritableLibraryFiles(rootPath, nil)
+	if err != nil {
+		return "", err
+	}
+	paths := make([]string, 0, len(files))
+	report := TagCanonicalizationReport{Root: rootPath, DryRun: dryRun, Files: []TagCanonicalizationResult{}}
+	report.QuarantinedSkipped = quarantined
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file.path), ".flac") {
+			paths = append(paths, file.path)
+		} else {
+			report.Skipped++
+		}
+	}
+	sort.Strings(paths)
+
+	journal, err := openBatchJournal(journalPath, batchOpCanonicalizeTags, canonicalizeTagsJournalArgs{Root: rootPath, Mapping: mappingJSON})
+	if err != nil {
+		return "", err
+	}
+	defer journal.close()
+
+	started := time.Now()
+	for _, path := range paths {
+		if journal.completed(path) {
+			report.Resumed++
+			continue
+		}
+		report.Checked++
+		result, err := canonicalizeFileTags(path, mapping, dryRun)
+		journal.record(path, err)
+		if err != nil {
+			report.Failed++
+			report.Files = append(report.Files, TagCanonicalizationResult{Path: path, DryRun: dryRun, Error: err.Error()})
+			changes.failed(path, err)
+			continue
+		}
+		if len(result.Changes) == 0 {
+			continue
+		}
+		changes.add(FileChangeEntry{Path: path, Fields: result.fields})
+		report.Changed++
+		report.IO.add(result.io)
+		if len(result.Conflicts) > 0 {
+			report.Conflicted++
+		}
+		report.Files = append(report.Files, *result)
+	}
+
+	report.IO.DurationMs = time.Since(started).Milliseconds()
+	journal.finish()
+	report.ChangeReportOutput = changes.finish(reportPath)
+
+	GoLog("[Tags] Canonicalized keys in %d of %d files under %s (%d with conflicts, dry run: %v)\n",
+		report.Changed, report.Checked, rootPath, report.Conflicted, dryRun)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
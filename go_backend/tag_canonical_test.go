@@ -0,0 +1,102 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeAliasedFLAC(t *testing.T, comments ...string) string {
+	t.Helper()
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	err := editVorbisCommentList(path, "test", func(raw []string) ([]string, bool) {
+		return append(raw, comments...), true
+	})
+	if err != nil {
+		t.Fatalf("write aliases: %v", err)
+	}
+	return path
+}
+
+func TestCanonicalizeTagsMergesAliases(t *testing.T) {
+	path := writeAliasedFLAC(t,
+		"Album Artist=Band",
+		"ALBUMARTIST=Band",
+		"album_artist=Other Band",
+		"YEAR=2020",
+		"TRACKSTOTAL=10",
+	)
+	before := mustReadFile(t, path)
+
+	raw, err := CanonicalizeTags(path, "", true)
+	result := mustDecodeJSON[TagCanonicalizationResult](t, raw, err)
+	if len(result.Changes) != 4 || len(result.Conflicts) != 1 || result.Conflicts[0].Canonical != "ALBUMARTIST" {
+		t.Fatalf("unexpected dry run: %+v", result)
+	}
+	if !slices.Equal(mustReadFile(t, path), before) {
+		t.Fatal("dry run modified the file")
+	}
+
+	if _, err := CanonicalizeTags(path, "", false); err != nil {
+		t.Fatalf("CanonicalizeTags: %v", err)
+	}
+	tags, err := GetTags(path, nil)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if got := tags["ALBUMARTIST"]; !slices.Equal(got, []string{"Band", "Other Band"}) {
+		t.Fatalf("ALBUMARTIST = %v", got)
+	}
+	if got := tags["DATE"]; !slices.Equal(got, []string{"2020"}) {
+		t.Fatalf("DATE = %v", got)
+	}
+	if got := tags["TRACKTOTAL"]; !slices.Equal(got, []string{"10"}) {
+		t.Fatalf("TRACKTOTAL = %v", got)
+	}
+	for _, alias := range []string{"ALBUM ARTIST", "ALBUM_ARTIST", "YEAR", "TRACKSTOTAL"} {
+		if _, ok := tags[alias]; ok {
+			t.Fatalf("alias %s survived", alias)
+		}
+	}
+
+	raw, err = CanonicalizeTags(path, "", true)
+	if err != nil || json.Unmarshal([]byte(raw), &result) != nil || len(result.Changes) != 0 {
+		t.Fatalf("second pass should find nothing: %s %v", raw, err)
+	}
+}
+
+func TestCanonicalizeTagsCustomMapping(t *testing.T) {
+	path := writeAliasedFLAC(t, "FEELING=calm", "YEAR=2001")
+
+	if _, err := CanonicalizeTags(path, `{"FEELING":"MOOD","YEAR":""}`, false); err != nil {
+		t.Fatalf("CanonicalizeTags: %v", err)
+	}
+	tags, err := GetTags(path, []string{"MOOD", "YEAR", "DATE"})
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if !slices.Equal(tags["MOOD"], []string{"calm"}) || !slices.Equal(tags["YEAR"], []string{"2001"}) || len(tags["DATE"]) != 0 {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+
+	if _, err := CanonicalizeTags(path, `{"A":"B","B":"C"}`, true); err == nil {
+		t.Fatal("chained mapping should be rejected")
+	}
+}
+
+func TestCanonicalizeLibraryTags(t *testing.T) {
+	root := t.TempDir()
+	aliased := writeAliasedFLAC(t, "COMMENTS=nice")
+	if err := os.Rename(aliased, filepath.Join(root, "a.flac")); err != nil {
+		t.Fatalf("move fixture: %v", err)
+	}
+	writeConsistencyFixture(t, root, "b.flac", Metadata{Title: "Clean"})
+
+	raw, err := CanonicalizeLibraryTags(root, "", false)
+	report := mustDecodeJSON[TagCanonicalizationReport](t, raw, err)
+	if report.Checked != 2 || report.Changed != 1 || len(report.Files) != 1 || report.Files[0].Changes[0].Canonical != "COMMENT" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
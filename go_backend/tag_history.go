@@ -0,0 +1,193 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-flac/flacpicture/v2"
+	"github.com/go-flac/go-flac/v2"
+)
+
+const (
+	// tagHistoryAppID is the 4-byte APPLICATION block ID that holds the
+	// journal. Readers that do not know it skip the block.
+	tagHistoryAppID = "SFLC"
+
+	defaultTagHistoryMaxEntries = 20
+)
+
+// TagHistoryEntry is one journal record. Keys are short because the whole
+// journal is rewritten on every tag save.
+type TagHistoryEntry struct {
+	Timestamp  int64    `json:"ts"`
+	AppVersion string   `json:"v"`
+	Operation  string   `json:"op"`
+	Fields     []string `json:"f"`
+}
+
+// tagSnapshot captures what a write can change so the journal can record
+// only the fields that actually differ afterwards.
+type tagSnapshot struct {
+	comments map[string][]string
+	pictures []int
+	// cover is the image of the front cover (else the first picture),
+	// sharing the block's memory.
+	cover []byte
+}
+
+func takeTagSnapshot(f *flac.File) tagSnapshot {
+	snap := tagSnapshot{comments: map[string][]string{}}
+	coverIsFront := false
+	for _, meta := range f.Meta {
+		switch meta.Type {
+		case flac.VorbisComment:
+			cmt, err := parseVorbisCommentBlock(*meta)
+			if err != nil {
+				continue
+			}
+			for _, comment := range cmt.Comments {
+				key, value := splitVorbisComment(comment)
+				key = strings.ToUpper(key)
+				snap.comments[key] = append(snap.comments[key], value)
+			}
+		case flac.Picture:
+			snap.pictures = append(snap.pictures, len(meta.Data))
+			pictureType, image, ok := flacPictureImage(meta.Data)
+			if ok && (snap.cover == nil || (pictureType == flacpicture.PictureTypeFrontCover && !coverIsFront)) {
+				snap.cover = image
+				coverIsFront = pictureType == flacpicture.PictureTypeFrontCover
+			}
+		}
+	}
+	return snap
+}
+
+func changedTagFields(before, after tagSnapshot) []string {
+	var fields []string
+	for key, values := range after.comments {
+		if !stringSlicesEqual(values, before.comments[key]) {
+			fields = append(fields, key)
+		}
+	}
+	for key := range before.comments {
+		if _, ok := after.comments[key]; !ok {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+
+	picturesChanged := len(before.pictures) != len(after.pictures)
+	for i := 0; !picturesChanged && i < len(after.pictures); i++ {
+		picturesChanged = before.pictures[i] != after.pictures[i]
+	}
+	if picturesChanged {
+		fields = append(fields, "PICTURE")
+	}
+	return fields
+}
+
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func findTagHistoryBlock(f *flac.File) int {
+	for idx, meta := range f.Meta {
+		if meta.Type == flac.Application && len(meta.Data) >= 4 && string(meta.Data[:4]) == tagHistoryAppID {
+			return idx
+		}
+	}
+	return -1
+}
+
+func parseTagHistoryBlock(meta *flac.MetaDataBlock) []TagHistoryEntry {
+	var entries []TagHistoryEntry
+	if err := json.Unmarshal(meta.Data[4:], &entries); err != nil {
+		return nil
+	}
+	return entries
+}
+
+// recordTagHistory appends a journal entry to f's metadata when the feature
+// is enabled and the write changed anything. It must run before f is saved.
+func recordTagHistory(f *flac.File, op string, before tagSnapshot) {
+	cfg := GetBackendConfig()
+	if !cfg.TagHistory {
+		return
+	}
+	fields := changedTagFields(before, takeTagSnapshot(f))
+	if len(fields) == 0 {
+		return
+	}
+
+	idx := findTagHistoryBlock(f)
+	var entries []TagHistoryEntry
+	if idx >= 0 {
+		entries = parseTagHistoryBlock(f.Meta[idx])
+	}
+	entries = append(entries, TagHistoryEntry{
+		Timestamp:  time.Now().Unix(),
+		AppVersion: GetAppVersion(),
+		Operation:  op,
+		Fields:     fields,
+	})
+	if len(entries) > cfg.TagHistoryMaxEntries {
+		entries = entries[len(entries)-cfg.TagHistoryMaxEntries:]
+	}
+
+	payload, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+	block := &flac.MetaDataBlock{Type: flac.Application, Data: append([]byte(tagHistoryAppID), payload...)}
+	if idx >= 0 {
+		f.Meta[idx] = block
+		return
+	}
+	f.Meta = append(f.Meta, block)
+}
+
+// saveTaggedFLAC records the tag history entry for a write and saves f.
+func saveTaggedFLAC(f *flac.File, filePath, op string, before tagSnapshot) error {
+	_, err := saveTaggedFLACStats(f, filePath, op, before)
+	return err
+}
+
+// saveTaggedFLACStats is saveTaggedFLAC reporting how the file was written.
+func saveTaggedFLACStats(f *flac.File, filePath, op string, before tagSnapshot) (flacSaveStats, error) {
+	recordTagHistory(f, op, before)
+	return saveFLACAtomicStats(f, filePath, op)
+}
+
+// GetTagHistory returns the tag journal stored in a FLAC file as a JSON
+// array, oldest first. Files without a journal return an empty array.
+func GetTagHistory(filePath string) (string, error) {
+	f, err := flac.ParseFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse FLAC file: %w", err)
+	}
+	defer f.Close()
+
+	entries := []TagHistoryEntry{}
+	if idx := findTagHistoryBlock(f); idx >= 0 {
+		if parsed := parseTagHistoryBlock(f.Meta[idx]); parsed != nil {
+			entries = parsed
+		}
+	}
+
+	jsonBytes, err := json.Marshal(entries)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,72 @@
+package gobackend
+
+import (
+	"strings"
+	"testing"
+)
+
+func readTagHistory(t *testing.T, path string) []TagHistoryEntry {
+	t.Helper()
+	historyJSON, err := GetTagHistory(path)
+	entries := mustDecodeJSON[[]TagHistoryEntry](t, historyJSON, err)
+	return entries
+}
+
+func TestTagHistoryDisabledByDefault(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	if err := EmbedLyrics(path, "la la"); err != nil {
+		t.Fatal(err)
+	}
+	historyJSON, err := GetTagHistory(path)
+	if err != nil || historyJSON != "[]" {
+		t.Fatalf("expected empty history, got %q (%v)", historyJSON, err)
+	}
+}
+
+func TestTagHistoryRecordsChangedFieldsAndCaps(t *testing.T) {
+	withBackendConfig(t, func(cfg *BackendConfig) {
+		cfg.TagHistory = true
+		cfg.TagHistoryMaxEntries = 2
+	})
+
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", Artist: "Artist"})
+	if err := EditFlacFields(path, map[string]string{"title": "New Title", "artist": "Artist"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readTagHistory(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	last := entries[1]
+	if last.Operation != "edit_fields" || strings.Join(last.Fields, ",") != "TITLE" || last.Timestamp == 0 {
+		t.Fatalf("unexpected entry: %+v", last)
+	}
+
+	// A write that changes nothing adds no entry.
+	if err := EditFlacFields(path, map[string]string{"title": "New Title"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(readTagHistory(t, path)); got != 2 {
+		t.Fatalf("no-op write added an entry: %d", got)
+	}
+
+	if err := EmbedGenreLabel(path, "Rock", ""); err != nil {
+		t.Fatal(err)
+	}
+	entries = readTagHistory(t, path)
+	if len(entries) != 2 || entries[0].Operation != "edit_fields" || entries[1].Operation != "embed_genre_label" {
+		t.Fatalf("history not capped to last 2: %+v", entries)
+	}
+
+	report, err := StripMetadata(path, []string{"TITLE", "GENRE"}, StripOptions{RemoveTagHistory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.RemovedApplications) != 1 || report.RemovedApplications[0] != tagHistoryAppID {
+		t.Fatalf("unexpected strip report: %+v", report)
+	}
+	if got := len(readTagHistory(t, path)); got != 0 {
+		t.Fatalf("history survived strip: %d", got)
+	}
+}
@@ -0,0 +1,191 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// How MergeTagsOnReplace brought a key over from the old file.
+const (
+	// TagMergeCopied replaced the new file's values with the old file's.
+	TagMergeCopied = "copied"
+	// TagMergeFilled copied a key the new file did not have.
+	TagMergeFilled = "filled"
+	// TagMergeCustom copied a key no policy list names that the new file
+	// did not have.
+	TagMergeCustom = "custom"
+)
+
+// defaultUserOwnedTags are the keys a user edits and a re-download must not
+// reset.
+var defaultUserOwnedTags = []string{"RATING", "COMMENT"}
+
+// defaultFillMissingTags are kept from the old file only when the new one
+// has none: lyrics the user fetched or edited, unless the source now ships
+// its own.
+var defaultFillMissingTags = []string{"LYRICS", "UNSYNCEDLYRICS", lyricsLanguageKey}
+
+// defaultSourceOwnedTags always come from the new file: the fields the
+// download embeds and what they describe about the audio. The alias
+// spellings of defaultTagKeyAliases are added to them, so they do not come
+// back as duplicates of the new file's canonical keys.
+var defaultSourceOwnedTags = []string{
+	"TITLE", "ARTIST", "ARTISTS", "ALBUM", "ALBUMARTIST",
+	"TRACKNUMBER", "TRACKTOTAL", "TOTALTRACKS", "DISCNUMBER", "DISCTOTAL", "TOTALDISCS",
+	"DATE", "YEAR", "GENRE", "ISRC", "ORGANIZATION", "LABEL", "PUBLISHER",
+	"COPYRIGHT", "COMPOSER", "DESCRIPTION", "ENCODER", featuredArtistTag,
+	"REPLAYGAIN_TRACK_GAIN", "REPLAYGAIN_TRACK_PEAK", "REPLAYGAIN_ALBUM_GAIN", "REPLAYGAIN_ALBUM_PEAK",
+	replayGainReferenceLoudnessTag, r128TrackGainTag, r128AlbumGainTag,
+	commentPictureKey, TagDownloadSource, TagDownloadSourceQuality, TagDownloadedAt, TagCoverArtSource,
+}
+
+// TagMergePolicy says which keys MergeTagsOnReplace takes from the old file.
+// A key in UserOwned is copied over the new file's values, one in
+// FillMissing only when the new file has none, and one in SourceOwned never;
+// the lists are checked in that order. Keys in none of them are copied when
+// the new file lacks them unless SkipCustom is set. A nil list uses the
+// default.
+type TagMergePolicy struct {
+	UserOwned   []string `json:"user_owned"`
+	FillMissing []string `json:"fill_missing"`
+	SourceOwned []string `json:"source_owned"`
+	SkipCustom  bool     `json:"skip_custom"`
+}
+
+type TagMergeChange struct {
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	// Old is what the new file had before the merge.
+	Old []string `json:"old,omitempty"`
+	New []string `json:"new"`
+}
+
+type TagMergeReport struct {
+	OldPath string           `json:"old_path"`
+	NewPath string           `json:"new_path"`
+	Changes []TagMergeChange `json:"changes"`
+	// Kept lists the keys both files have with different values where the
+	// new file's values were kept.
+	Kept []string `json:"kept"`
+}
+
+func parseTagMergePolicy(policyJSON string) (TagMergePolicy, error) {
+	var policy TagMergePolicy
+	if strings.TrimSpace(policyJSON) != "" {
+		if err := json.Unmarshal([]byte(policyJSON), &policy); err != nil {
+			return TagMergePolicy{}, fmt.Errorf("invalid merge policy JSON: %w", err)
+		}
+	}
+	sourceOwned := append(slices.Clone(defaultSourceOwnedTags), slices.Sorted(maps.Keys(defaultTagKeyAliases))...)
+	lists := []struct {
+		keys     *[]string
+		defaults []string
+	}{
+		{&policy.UserOwned, defaultUserOwnedTags},
+		{&policy.FillMissing, defaultFillMissingTags},
+		{&policy.SourceOwned, sourceOwned},
+	}
+	for _, list := range lists {
+		if *list.keys == nil {
+			*list.keys = list.defaults
+		}
+		keys, err := normalizeTagKeys(*list.keys)
+		if err != nil {
+			return TagMergePolicy{}, err
+		}
+		*list.keys = keys
+	}
+	return policy, nil
+}
+
+// action is how policy treats key: one of the TagMerge constants, or ""
+// for a source-owned key.
+func (p TagMergePolicy) action(key string) string {
+	switch {
+	case slices.Contains(p.UserOwned, key):
+		return TagMergeCopied
+	case slices.Contains(p.FillMissing, key):
+		return TagMergeFilled
+	case slices.Contains(p.SourceOwned, key) || p.SkipCustom:
+		return ""
+	}
+	return TagMergeCustom
+}
+
+// mergeReplacedTags copies old into comments per policy and reports what
+// it changed and which differing keys it left alone.
+func mergeReplacedTags(comments *vorbisCommentMap, old map[string][]string, policy TagMergePolicy) (changes []TagMergeChange, kept []string) {
+	keys := make([]string, 0, len(old))
+	for key := range old {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		values := old[key]
+		current := comments.getValues(key)
+		if slices.Equal(current, values) {
+			continue
+		}
+		action := policy.action(key)
+		if action == "" || (action != TagMergeCopied && len(current) > 0) {
+			if len(current) > 0 {
+				kept = append(kept, key)
+			}
+			continue
+		}
+		comments.setValues(key, values)
+		changes = append(changes, TagMergeChange{Key: key, Action: action, Old: current, New: values})
+	}
+	return changes, kept
+}
+
+// MergeTagsOnReplace copies the tags a user owns from oldPath into newPath,
+// the higher quality download about to replace it, so ratings, comments,
+// custom tags and fetched lyrics survive the swap. policyJSON is a
+// TagMergePolicy; empty uses the defaults. Only newPath is written, and
+// only when something changes; the swap itself is up to the caller.
+func MergeTagsOnReplace(oldPath, newPath string, policyJSON string) (string, error) {
+	if oldPath == "" || newPath == "" {
+		return "", fmt.Errorf("old or new file path is empty")
+	}
+	if isOpenerPath(newPath) {
+		return viaFileOpener(newPath, true, func(localPath string) (string, error) {
+			return MergeTagsOnReplace(oldPath, localPath, policyJSON)
+		})
+	}
+	policy, err := parseTagMergePolicy(policyJSON)
+	if err != nil {
+		return "", err
+	}
+	if err := checkWriteAllowed(newPath); err != nil {
+		return "", err
+	}
+	old, err := GetTags(oldPath, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to read old file: %w", err)
+	}
+
+	report := TagMergeReport{OldPath: oldPath, NewPath: newPath, Changes: []TagMergeChange{}, Kept: []string{}}
+	err = editVorbisComments(newPath, "merge_tags", func(comments *vorbisCommentMap) bool {
+		changes, kept := mergeReplacedTags(comments, old, policy)
+		report.Changes = append(report.Changes, changes...)
+		report.Kept = append(report.Kept, kept...)
+		return len(changes) > 0
+	})
+	if err != nil {
+		return "", err
+	}
+	GoLog("[Metadata] Merged %d tag(s) from %s into %s, kept %d from the new file\n",
+		len(report.Changes), oldPath, newPath, len(report.Kept))
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
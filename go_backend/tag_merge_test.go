@@ -0,0 +1,91 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestMergeTagsOnReplaceDefaultPolicy(t *testing.T) {
+	oldPath := writeMalformedCommentFLAC(t,
+		"TITLE=Old Title", "RATING=80", "COMMENT=my notes", "LYRICS=edited lyrics",
+		"MY_TAG=one", "MY_TAG=two", "COMMENTS=alias", "REPLAYGAIN_TRACK_GAIN=-3.00 dB")
+	newPath := writeMalformedCommentFLAC(t,
+		"TITLE=New Title", "COMMENT=from provider", "REPLAYGAIN_TRACK_GAIN=-6.00 dB")
+
+	out, err := MergeTagsOnReplace(oldPath, newPath, "")
+	report := mustDecodeJSON[TagMergeReport](t, out, err)
+
+	actions := make(map[string]string)
+	for _, change := range report.Changes {
+		actions[change.Key] = change.Action
+	}
+	want := map[string]string{"RATING": TagMergeCopied, "COMMENT": TagMergeCopied, "LYRICS": TagMergeFilled, "MY_TAG": TagMergeCustom}
+	if len(actions) != len(want) {
+		t.Fatalf("unexpected changes: %+v", report.Changes)
+	}
+	for key, action := range want {
+		if actions[key] != action {
+			t.Fatalf("%s: action %q, want %q (%+v)", key, actions[key], action, report.Changes)
+		}
+	}
+	if !slices.Equal(report.Kept, []string{"REPLAYGAIN_TRACK_GAIN", "TITLE"}) {
+		t.Fatalf("kept = %q", report.Kept)
+	}
+
+	tags, err := GetTags(newPath, nil)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	checks := map[string][]string{
+		"TITLE":                 {"New Title"},
+		"COMMENT":               {"my notes"},
+		"RATING":                {"80"},
+		"LYRICS":                {"edited lyrics"},
+		"MY_TAG":                {"one", "two"},
+		"REPLAYGAIN_TRACK_GAIN": {"-6.00 dB"},
+		"COMMENTS":              nil,
+	}
+	for key, values := range checks {
+		if !slices.Equal(tags[key], values) {
+			t.Fatalf("%s = %q, want %q", key, tags[key], values)
+		}
+	}
+}
+
+func TestMergeTagsOnReplaceCustomPolicy(t *testing.T) {
+	oldPath := writeMalformedCommentFLAC(t, "RATING=80", "LYRICS=old lyrics", "MY_TAG=x", "MOOD=calm")
+	newPath := writeMalformedCommentFLAC(t, "LYRICS=new lyrics")
+
+	policy := `{"user_owned":["lyrics"],"fill_missing":[],"source_owned":["RATING"],"skip_custom":true}`
+	if _, err := MergeTagsOnReplace(oldPath, newPath, policy); err != nil {
+		t.Fatalf("MergeTagsOnReplace: %v", err)
+	}
+	tags, err := GetTags(newPath, nil)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if !slices.Equal(tags["LYRICS"], []string{"old lyrics"}) || tags["RATING"] != nil || tags["MY_TAG"] != nil || tags["MOOD"] != nil {
+		t.Fatalf("unexpected tags after merge: %v", tags)
+	}
+
+	merged := mustReadFile(t, newPath)
+	out, err := MergeTagsOnReplace(oldPath, newPath, policy)
+	if err != nil {
+		t.Fatalf("second MergeTagsOnReplace: %v", err)
+	}
+	var report TagMergeReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil || len(report.Changes) != 0 {
+		t.Fatalf("second merge changed tags: %s %v", out, err)
+	}
+	if string(mustReadFile(t, newPath)) != string(merged) {
+		t.Fatal("second merge rewrote the file")
+	}
+
+	if _, err := MergeTagsOnReplace(oldPath, newPath, `{"user_owned":["BAD=KEY"]}`); err == nil {
+		t.Fatal("expected an invalid key to be rejected")
+	}
+	if _, err := MergeTagsOnReplace(oldPath, newPath, `not json`); err == nil {
+		t.Fatal("expected invalid policy JSON to be rejected")
+	}
+}
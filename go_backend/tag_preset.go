@@ -0,0 +1,246 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Tag preset rule operations.
+const (
+	PresetOpSetIfEmpty    = "set_if_empty"
+	PresetOpOverwrite     = "overwrite"
+	PresetOpClear         = "clear"
+	PresetOpCopyFrom      = "copy_from"
+	PresetOpRegexReplace  = "regex_replace"
+	PresetOpNormalizeCase = "normalize_case"
+)
+
+// TagPresetRule is one step of a preset. Field and From use the snake_case
+// names of Metadata's text fields ("title", "album_artist", ...).
+type TagPresetRule struct {
+	Op    string `json:"op"`
+	Field string `json:"field"`
+	// Value is written by set_if_empty and overwrite. When the preset is
+	// applied to a file, {filename}, {albumdir}, {artistdir} and {disc} are
+	// replaced by the path template variables of its path; see
+	// pathTemplateVars. Without a path they are empty.
+	Value string `json:"value,omitempty"`
+	// From is the source field of copy_from; with IfEmpty the copy only
+	// happens when Field is empty.
+	From    string `json:"from,omitempty"`
+	IfEmpty bool   `json:"if_empty,omitempty"`
+	// Pattern and Replace drive regex_replace. Replace may reference
+	// capture groups as $1 or ${name}.
+	Pattern string `json:"pattern,omitempty"`
+	Replace string `json:"replace,omitempty"`
+	// Style is the NormalizeCase style of normalize_case.
+	Style string `json:"style,omitempty"`
+}
+
+// TagPreset is an ordered list of rules applied to Metadata before it is
+// embedded, e.g. for tweaks shared by every download from one source.
+type TagPreset struct {
+	Name  string          `json:"name"`
+	Rules []TagPresetRule `json:"rules"`
+}
+
+type compiledPresetRule struct {
+	TagPresetRule
+	re *regexp.Regexp
+}
+
+// metadataTextField returns a pointer to the Metadata field named name.
+func metadataTextField(m *Metadata, name string) *string {
+	switch name {
+	case "title":
+		return &m.Title
+	case "artist":
+		return &m.Artist
+	case "album":
+		return &m.Album
+	case "album_artist":
+		return &m.AlbumArtist
+	case "date":
+		return &m.Date
+	case "isrc":
+		return &m.ISRC
+	case "description":
+		return &m.Description
+	case "lyrics":
+		return &m.Lyrics
+	case "genre":
+		return &m.Genre
+	case "label":
+		return &m.Label
+	case "copyright":
+		return &m.Copyright
+	case "composer":
+		return &m.Composer
+	case "comment":
+		return &m.Comment
+	case "replaygain_track_gain":
+		return &m.ReplayGainTrackGain
+	case "replaygain_track_peak":
+		return &m.ReplayGainTrackPeak
+	case "replaygain_album_gain":
+		return &m.ReplayGainAlbumGain
+	case "replaygain_album_peak":
+		return &m.ReplayGainAlbumPeak
+	}
+	return nil
+}
+
+// presetFieldTags are the comments EmbedAll writes from each preset field.
+// A rule that empties a field removes them from the file, since an empty
+// Metadata field otherwise leaves the file's value alone.
+var presetFieldTags = map[string][]string{
+	"title":                 {"TITLE"},
+	"artist":                {"ARTIST"},
+	"album":                 {"ALBUM"},
+	"album_artist":          {"ALBUMARTIST"},
+	"date":                  {"DATE"},
+	"isrc":                  {"ISRC"},
+	"description":           {"DESCRIPTION"},
+	"lyrics":                {"LYRICS", "UNSYNCEDLYRICS"},
+	"genre":                 {"GENRE"},
+	"label":                 {"ORGANIZATION"},
+	"copyright":             {"COPYRIGHT"},
+	"composer":              {"COMPOSER"},
+	"comment":               {"COMMENT"},
+	"replaygain_track_gain": {"REPLAYGAIN_TRACK_GAIN"},
+	"replaygain_track_peak": {"REPLAYGAIN_TRACK_PEAK"},
+	"replaygain_album_gain": {"REPLAYGAIN_ALBUM_GAIN"},
+	"replaygain_album_peak": {"REPLAYGAIN_ALBUM_PEAK"},
+}
+
+// compileTagPreset parses presetJSON and validates every rule up front, so a
+// bad preset fails before any file is touched. Errors name the rule index.
+func compileTagPreset(presetJSON string) ([]compiledPresetRule, error) {
+	var preset TagPreset
+	if err := json.Unmarshal([]byte(presetJSON), &preset); err != nil {
+		return nil, fmt.Errorf("invalid preset JSON: %w", err)
+	}
+
+	var probe Metadata
+	rules := make([]compiledPresetRule, 0, len(preset.Rules))
+	for i, rule := range preset.Rules {
+		rule.Field = strings.ToLower(strings.TrimSpace(rule.Field))
+		rule.From = strings.ToLower(strings.TrimSpace(rule.From))
+		if metadataTextField(&probe, rule.Field) == nil {
+			return nil, fmt.Errorf("rules[%d]: unknown field %q", i, rule.Field)
+		}
+
+		compiled := compiledPresetRule{TagPresetRule: rule}
+		switch rule.Op {
+		case PresetOpSetIfEmpty, PresetOpOverwrite, PresetOpClear:
+		case PresetOpCopyFrom:
+			if metadataTextField(&probe, rule.From) == nil {
+				return nil, fmt.Errorf("rules[%d]: unknown source field %q", i, rule.From)
+			}
+		case PresetOpRegexReplace:
+			if rule.Pattern == "" {
+				return nil, fmt.Errorf("rules[%d]: regex_replace needs a pattern", i)
+			}
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rules[%d]: invalid pattern: %w", i, err)
+			}
+			compiled.re = re
+		case PresetOpNormalizeCase:
+			if err := validateCaseStyle(rule.Style); err != nil {
+				return nil, fmt.Errorf("rules[%d]: %w", i, err)
+			}
+		default:
+			return nil, fmt.Errorf("rules[%d]: unknown op %q", i, rule.Op)
+		}
+		rules = append(rules, compiled)
+	}
+	return rules, nil
+}
+
+// applyCompiledPreset runs rules over metadata, expanding path template
+// variables in values from vars, which is nil without a file path. It also
+// returns the comments of the fields the rules emptied (see
+// presetFieldTags): those cleared outright and those a rule turned from a
+// value into "".
+func applyCompiledPreset(metadata Metadata, rules []compiledPresetRule, vars map[string]string) (Metadata, []string) {
+	cleared := make(map[string]bool)
+	for _, rule := range rules {
+		field := metadataTextField(&metadata, rule.Field)
+		before := *field
+		switch rule.Op {
+		case PresetOpSetIfEmpty:
+			if strings.TrimSpace(*field) == "" {
+				*field = expandPathTemplate(rule.Value, vars)
+			}
+		case PresetOpOverwrite:
+			*field = expandPathTemplate(rule.Value, vars)
+		case PresetOpClear:
+			*field = ""
+		case PresetOpCopyFrom:
+			if !rule.IfEmpty || strings.TrimSpace(*field) == "" {
+				*field = *metadataTextField(&metadata, rule.From)
+			}
+		case PresetOpRegexReplace:
+			// Go's semantics apply: a pattern that can match the empty
+			// string also matches an empty field, and between characters.
+			*field = rule.re.ReplaceAllString(*field, rule.Replace)
+		case PresetOpNormalizeCase:
+			*field = normalizeCaseText(*field, rule.Style, defaultCaseExceptionPatterns)
+		}
+		if *field == "" && (rule.Op == PresetOpClear || before != "") {
+			cleared[rule.Field] = true
+		} else if *field != "" {
+			delete(cleared, rule.Field)
+		}
+	}
+
+	var clearTags []string
+	for _, name := range slices.Sorted(maps.Keys(cleared)) {
+		clearTags = append(clearTags, presetFieldTags[name]...)
+	}
+	return metadata, clearTags
+}
+
+// ApplyPreset runs the rules of presetJSON over metadata in order and
+// returns the result. The whole preset is validated before any rule runs.
+func ApplyPreset(metadata Metadata, presetJSON string) (Metadata, error) {
+	rules, err := compileTagPreset(presetJSON)
+	if err != nil {
+		return metadata, err
+	}
+	metadata, _ = applyCompiledPreset(metadata, rules, nil)
+	return metadata, nil
+}
+
+// ApplyPresetForPath is ApplyPreset for the file at filePath, whose path
+// template variables fill the rule values, so "set album to {albumdir} if
+// empty" tags a library organized only by folders.
+func ApplyPresetForPath(metadata Metadata, presetJSON, filePath string) (Metadata, error) {
+	rules, err := compileTagPreset(presetJSON)
+	if err != nil {
+		return metadata, err
+	}
+	metadata, _ = applyCompiledPreset(metadata, rules, pathTemplateVars(filePath))
+	return metadata, nil
+}
+
+// applyPresetClearing runs presetJSON over metadata as ApplyPresetForPath
+// does, or as ApplyPreset when filePath is empty, and also returns the
+// comments to remove for the fields it emptied.
+func applyPresetClearing(metadata Metadata, presetJSON, filePath string) (Metadata, []string, error) {
+	rules, err := compileTagPreset(presetJSON)
+	if err != nil {
+		return metadata, nil, err
+	}
+	var vars map[string]string
+	if filePath != "" {
+		vars = pathTemplateVars(filePath)
+	}
+	metadata, clearTags := applyCompiledPreset(metadata, rules, vars)
+	return metadata, clearTags, nil
+}
@@ -0,0 +1,135 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyPresetRules(t *testing.T) {
+	preset := `{"name":"bandcamp","rules":[
+		{"op":"copy_from","field":"album_artist","from":"artist","if_empty":true},
+		{"op":"set_if_empty","field":"comment","value":"from bandcamp"},
+		{"op":"overwrite","field":"genre","value":"Electronic"},
+		{"op":"clear","field":"description"},
+		{"op":"regex_replace","field":"title","pattern":"\\s*\\(Original Mix\\)$","replace":""}
+	]}`
+
+	got, err := ApplyPreset(Metadata{
+		Title:       "Track (Original Mix)",
+		Artist:      "Artist",
+		Genre:       "Techno",
+		Description: "drop me",
+	}, preset)
+	if err != nil {
+		t.Fatalf("ApplyPreset: %v", err)
+	}
+	if got.AlbumArtist != "Artist" || got.Comment != "from bandcamp" || got.Genre != "Electronic" ||
+		got.Description != "" || got.Title != "Track" {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+
+	kept, err := ApplyPreset(Metadata{Artist: "Artist", AlbumArtist: "Various", Comment: "mine"}, preset)
+	if err != nil {
+		t.Fatalf("ApplyPreset: %v", err)
+	}
+	if kept.AlbumArtist != "Various" || kept.Comment != "mine" {
+		t.Fatalf("if-empty rules should not overwrite: %+v", kept)
+	}
+}
+
+func TestApplyPresetRegexEmptyMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		pattern string
+		replace string
+		want    string
+	}{
+		{"anchored empty pattern fills empty field", "", `^$`, "Unknown", "Unknown"},
+		{"anchored empty pattern leaves text alone", "Title", `^$`, "Unknown", "Title"},
+		{"star pattern matches between characters", "ab", `x*`, "-", "-a-b-"},
+		{"star pattern on empty field matches once", "", `x*`, "-", "-"},
+		{"no match keeps value", "Title", `zzz`, "", "Title"},
+		{"capture groups", "Artist - Title", `^(.*) - (.*)$`, "$2", "Title"},
+	}
+	for _, tt := range tests {
+		preset, _ := json.Marshal(TagPreset{Rules: []TagPresetRule{
+			{Op: PresetOpRegexReplace, Field: "title", Pattern: tt.pattern, Replace: tt.replace},
+		}})
+		got, err := ApplyPreset(Metadata{Title: tt.value}, string(preset))
+		if err != nil {
+			t.Fatalf("%s: ApplyPreset: %v", tt.name, err)
+		}
+		if got.Title != tt.want {
+			t.Fatalf("%s: got %q, want %q", tt.name, got.Title, tt.want)
+		}
+	}
+}
+
+func TestApplyPresetValidatesRulesUpFront(t *testing.T) {
+	tests := []struct {
+		preset string
+		want   string
+	}{
+		{`{"rules":[{"op":"overwrite","field":"title","value":"x"},{"op":"explode","field":"title"}]}`, "rules[1]: unknown op"},
+		{`{"rules":[{"op":"clear","field":"mood"}]}`, "rules[0]: unknown field"},
+		{`{"rules":[{"op":"clear","field":"title"},{"op":"copy_from","field":"title","from":"nope"}]}`, "rules[1]: unknown source field"},
+		{`{"rules":[{"op":"regex_replace","field":"title","pattern":"("}]}`, "rules[0]: invalid pattern"},
+		{`{"rules":[{"op":"regex_replace","field":"title"}]}`, "rules[0]: regex_replace needs a pattern"},
+		{`not json`, "invalid preset JSON"},
+	}
+	for _, tt := range tests {
+		original := Metadata{Title: "Keep"}
+		got, err := ApplyPreset(original, tt.preset)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("preset %s: expected error containing %q, got %v", tt.preset, tt.want, err)
+		}
+		if !reflect.DeepEqual(got, original) {
+			t.Fatalf("metadata must be untouched when validation fails: %+v", got)
+		}
+	}
+}
+
+func TestEmbedAllRunsPreset(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Track"})
+	opts := EmbedOptions{Preset: `{"rules":[{"op":"copy_from","field":"album_artist","from":"artist","if_empty":true}]}`}
+	if err := EmbedAll(path, Metadata{Artist: "Artist"}, Lyrics{}, nil, opts); err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	meta, err := ReadMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadMetadata: %v", err)
+	}
+	if meta.AlbumArtist != "Artist" {
+		t.Fatalf("expected preset to fill album artist, got %+v", meta)
+	}
+
+	opts.Preset = `{"rules":[{"op":"bogus","field":"title"}]}`
+	if err := EmbedAll(path, Metadata{}, Lyrics{}, nil, opts); err == nil {
+		t.Fatal("expected invalid preset to fail the embed")
+	}
+}
+
+func TestEmbedAllPresetClearsTags(t *testing.T) {
+	path := writeMalformedCommentFLAC(t, "TITLE=Song", "GENRE=Rock", "COMMENT=old note", "COMPOSER=C")
+	preset := `{"rules":[
+		{"op":"clear","field":"genre"},
+		{"op":"regex_replace","field":"comment","pattern":"^old.*","replace":""},
+		{"op":"clear","field":"composer"},
+		{"op":"set_if_empty","field":"composer","value":"New"}
+	]}`
+	err := EmbedAll(path, Metadata{Title: "Song", Genre: "Rock", Comment: "old note"}, Lyrics{}, nil, EmbedOptions{Preset: preset})
+	if err != nil {
+		t.Fatalf("EmbedAll: %v", err)
+	}
+	tags, err := GetTags(path, nil)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if tags["GENRE"] != nil || tags["COMMENT"] != nil || !reflect.DeepEqual(tags["COMPOSER"], []string{"New"}) ||
+		!reflect.DeepEqual(tags["TITLE"], []string{"Song"}) {
+		t.Fatalf("tags after a clearing preset = %v", tags)
+	}
+}
@@ -0,0 +1,168 @@
+package gobackend
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Tag text files hold one KEY=value comment per line, in UTF-8 and in file
+// order, as foobar2000 and similar tools exchange them. A value containing
+// line breaks or backslashes is escaped so it stays on one line:
+//
+//	\n   line feed
+//	\r   carriage return
+//	\\   backslash
+//
+// Any other backslash sequence is an error. Blank lines and lines starting
+// with '#' are ignored on import. METADATA_BLOCK_PICTURE comments are binary
+// cover art, so they are neither exported nor touched by an import.
+
+var tagsTextEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+func escapeTagsTextValue(value string) string {
+	return tagsTextEscaper.Replace(value)
+}
+
+func unescapeTagsTextValue(value string) (string, error) {
+	if !strings.Contains(value, `\`) {
+		return value, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if i+1 == len(value) {
+			return "", fmt.Errorf("dangling backslash at end of value")
+		}
+		i++
+		switch value[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case '\\':
+			b.WriteByte('\\')
+		default:
+			return "", fmt.Errorf("unknown escape \\%c", value[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// ExportTagsText returns the Vorbis comments of filePath as KEY=value
+// lines, escaped as described above, for editing in a text editor.
+func ExportTagsText(filePath string) (string, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, false, func(localPath string) (string, error) {
+			return ExportTagsText(localPath)
+		})
+	}
+	comments, err := readVorbisCommentList(filePath)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, comment := range comments {
+		key, value := splitVorbisComment(comment)
+		if strings.EqualFold(key, commentPictureKey) {
+			continue
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(escapeTagsTextValue(value))
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// parseTagsText parses tag text into pairs. Every malformed line is
+// reported with its 1-based line number.
+func parseTagsText(text string) ([]TagPair, error) {
+	if !utf8.ValidString(text) {
+		return nil, fmt.Errorf("tag text is not valid UTF-8")
+	}
+	text = strings.TrimPrefix(text, "\ufeff")
+
+	var pairs []TagPair
+	var errs []error
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, rawValue, found := strings.Cut(line, "=")
+		if !found {
+			errs = append(errs, fmt.Errorf("line %d: missing '=' between key and value", i+1))
+			continue
+		}
+		if err := validateVorbisKey(key); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", i+1, err))
+			continue
+		}
+		if strings.EqualFold(key, commentPictureKey) {
+			errs = append(errs, fmt.Errorf("line %d: %s cannot be imported as text", i+1, commentPictureKey))
+			continue
+		}
+		value, err := unescapeTagsTextValue(rawValue)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", i+1, err))
+			continue
+		}
+		pairs = append(pairs, TagPair{Key: key, Value: value})
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return pairs, nil
+}
+
+// ImportTagsText writes tags parsed from text in the ExportTagsText format.
+// With replace the file's comments become exactly those in text; otherwise
+// each key in text replaces that key's values and other keys are kept.
+// Nothing is written unless every line parses; the error lists each
+// malformed line by number.
+func ImportTagsText(filePath, text string, replace bool) error {
+	if isOpenerPath(filePath) {
+		return viaFileOpenerErr(filePath, true, func(localPath string) error {
+			return ImportTagsText(localPath, text, replace)
+		})
+	}
+	if err := checkWriteAllowed(filePath); err != nil {
+		return err
+	}
+
+	pairs, err := parseTagsText(text)
+	if err != nil {
+		return err
+	}
+	if replace {
+		return editVorbisCommentList(filePath, "import_tags_text", func(raw []string) ([]string, bool) {
+			var kept []string
+			for _, comment := range raw {
+				if key, ok := vorbisCommentKey(comment); ok && key == commentPictureKey {
+					kept = append(kept, comment)
+				}
+			}
+			for _, pair := range pairs {
+				kept = append(kept, pair.Key+"="+pair.Value)
+			}
+			return kept, true
+		})
+	}
+
+	order, values, err := groupTagPairs(pairs)
+	if err != nil {
+		return err
+	}
+	return editVorbisComments(filePath, "import_tags_text", func(comments *vorbisCommentMap) bool {
+		for _, key := range order {
+			comments.setValues(key, values[key])
+		}
+		return true
+	})
+}
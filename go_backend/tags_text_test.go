@@ -0,0 +1,78 @@
+package gobackend
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTagsTextRoundTrip(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", Artist: "Artist"})
+	if err := SetTags(path, []TagPair{{Key: "LYRICS", Value: "line one\nline two\r\nback\\slash"}}, true); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+
+	text, err := ExportTagsText(path)
+	if err != nil {
+		t.Fatalf("ExportTagsText: %v", err)
+	}
+	if !strings.Contains(text, "TITLE=Song\n") || !strings.Contains(text, `=line one\nline two\r\nback\\slash`) {
+		t.Fatalf("unexpected export:\n%s", text)
+	}
+
+	edited := strings.Replace(text, "TITLE=Song", "TITLE=Edited", 1) + "# note\n\nMOOD=Calm\\nNight\n"
+	if err := ImportTagsText(path, edited, true); err != nil {
+		t.Fatalf("ImportTagsText: %v", err)
+	}
+	tags, err := GetTags(path, nil)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if tags["TITLE"][0] != "Edited" || tags["MOOD"][0] != "Calm\nNight" || tags["ARTIST"][0] != "Artist" {
+		t.Fatalf("unexpected tags after import: %v", tags)
+	}
+	if lyrics := tags["LYRICS"]; len(lyrics) != 1 || lyrics[0] != "line one\nline two\r\nback\\slash" {
+		t.Fatalf("multi-line value not preserved: %q", lyrics)
+	}
+}
+
+func TestImportTagsTextReplaceModes(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song", Artist: "Artist"})
+
+	if err := ImportTagsText(path, "TITLE=Merged\r\nGENRE=Jazz\r\n", false); err != nil {
+		t.Fatalf("merge import: %v", err)
+	}
+	tags, _ := GetTags(path, nil)
+	if tags["TITLE"][0] != "Merged" || tags["GENRE"][0] != "Jazz" || tags["ARTIST"][0] != "Artist" {
+		t.Fatalf("unexpected tags after merge: %v", tags)
+	}
+
+	if err := ImportTagsText(path, "TITLE=Only\n", true); err != nil {
+		t.Fatalf("replace import: %v", err)
+	}
+	tags, _ = GetTags(path, nil)
+	if len(tags) != 1 || tags["TITLE"][0] != "Only" {
+		t.Fatalf("expected only the imported tag, got %v", tags)
+	}
+}
+
+func TestImportTagsTextReportsLineNumbers(t *testing.T) {
+	path := writeTestFLACWithMetadata(t, Metadata{Title: "Song"})
+	before := mustReadFile(t, path)
+
+	text := "TITLE=Fine\nno separator\nBAD~KEY=x\nCOMMENT=trailing\\\nLYRICS=bad \\t escape\n"
+	err := ImportTagsText(path, text, false)
+	if err == nil {
+		t.Fatal("expected malformed lines to be rejected")
+	}
+	for _, want := range []string{"line 2:", "line 3:", "line 4:", "line 5:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error %q does not mention %s", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "line 1:") {
+		t.Fatalf("valid line reported: %v", err)
+	}
+	if string(mustReadFile(t, path)) != string(before) {
+		t.Fatal("file modified despite parse errors")
+	}
+}
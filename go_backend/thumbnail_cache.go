@@ -0,0 +1,226 @@
+package gobackend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultThumbnailMaxDim = 256
+
+// thumbnailNamePattern matches the files BuildThumbnailCache owns, so
+// pruning leaves anything else in the cache directory alone.
+var thumbnailNamePattern = regexp.MustCompile(`^[0-9a-f]{32}\.jpg$`)
+
+type ThumbnailCacheProgress struct {
+	Total       int     `json:"total"`
+	Completed   int     `json:"completed"`
+	CurrentFile string  `json:"current_file"`
+	ProgressPct float64 `json:"progress_pct"`
+	IsComplete  bool    `json:"is_complete"`
+	// EstimatedRemainingMs is zero until the first file is done.
+	EstimatedRemainingMs int64 `json:"estimated_remaining_ms"`
+}
+
+// ThumbnailCacheReport maps every audio file with a cover to its thumbnail.
+// Created counts thumbnails written by this run and UpToDate those kept.
+type ThumbnailCacheReport struct {
+	CacheDir   string            `json:"cache_dir"`
+	Thumbnails map[string]string `json:"thumbnails"`
+	Created    int               `json:"created"`
+	UpToDate   int               `json:"up_to_date"`
+	NoCover    int               `json:"no_cover"`
+	Failed     int               `json:"failed"`
+	Pruned     int               `json:"pruned"`
+	Errors     map[string]string `json:"errors,omitempty"`
+}
+
+var (
+	thumbnailCacheProgress   ThumbnailCacheProgress
+	thumbnailCacheProgressMu sync.RWMutex
+)
+
+func updateThumbnailCacheProgress(update func(p *ThumbnailCacheProgress)) {
+	thumbnailCacheProgressMu.Lock()
+	update(&thumbnailCacheProgress)
+	thumbnailCacheProgressMu.Unlock()
+}
+
+// GetThumbnailCacheProgress reports the running BuildThumbnailCache.
+func GetThumbnailCacheProgress() string {
+	thumbnailCacheProgressMu.RLock()
+	defer thumbnailCacheProgressMu.RUnlock()
+
+	jsonBytes, _ := json.Marshal(thumbnailCacheProgress)
+	return string(jsonBytes)
+}
+
+// thumbnailName keys a thumbnail by path and modification time, so a
+// re-tagged file gets a new thumbnail and the old one is pruned.
+func thumbnailName(filePath string, modTime int64) string {
+	sum := sha256.Sum256([]byte(filePath + "\x00" + strconv.FormatInt(modTime, 10)))
+	return hex.EncodeToString(sum[:16]) + ".jpg"
+}
+
+type thumbnailOutcome struct {
+	created bool
+	noCover bool
+	err     error
+}
+
+// writeThumbnail extracts the cover of filePath and stores it downscaled
+// at thumbPath, through a temporary file so a killed run never leaves a
+// truncated image behind.
+func writeThumbnail(filePath, thumbPath string, maxDim int) thumbnailOutcome {
+	if _, err := os.Stat(thumbPath); err == nil {
+		return thumbnailOutcome{}
+	}
+	data, _, err := extractAnyCoverArt(filePath)
+	if err != nil || len(data) == 0 {
+		return thumbnailOutcome{noCover: true}
+	}
+	thumb, _, err := resizeCoverJPEG(data, maxDim, 0)
+	if err != nil {
+		return thumbnailOutcome{err: err}
+	}
+	tmpPath := thumbPath + ".tmp"
+	if err := os.WriteFile(tmpPath, thumb, 0644); err != nil {
+		return thumbnailOutcome{err: fmt.Errorf("failed to write thumbnail: %w", err)}
+	}
+	if err := os.Rename(tmpPath, thumbPath); err != nil {
+		os.Remove(tmpPath)
+		return thumbnailOutcome{err: fmt.Errorf("failed to write thumbnail: %w", err)}
+	}
+	return thumbnailOutcome{created: true}
+}
+
+// BuildThumbnailCache writes the embedded cover of every audio file under
+// rootPath, scaled to fit maxDim (256 when <= 0), to cacheDir/<hash>.jpg,
+// where the hash covers the file's path and modification time. Thumbnails
+// already present are kept, and thumbnails of files that were deleted or
+// changed are removed. The result maps each file with a cover to its
+// thumbnail; progress is available from GetThumbnailCacheProgress.
+func BuildThumbnailCache(rootPath, cacheDir string, maxDim int) (string, error) {
+	if strings.TrimSpace(rootPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(rootPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", rootPath)
+	}
+	if strings.TrimSpace(cacheDir) == "" {
+		return "", fmt.Errorf("cache folder path is empty")
+	}
+	if maxDim <= 0 {
+		maxDim = defaultThumbnailMaxDim
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	files, err := collectLibraryAudioFiles(rootPath, nil)
+	if err != nil {
+		return "", err
+	}
+	var audio []libraryAudioFileInfo
+	for _, file := range files {
+		if !strings.EqualFold(filepath.Ext(file.path), ".cue") {
+			audio = append(audio, file)
+		}
+	}
+
+	updateThumbnailCacheProgress(func(p *ThumbnailCacheProgress) {
+		*p = ThumbnailCacheProgress{Total: len(audio)}
+	})
+
+	outcomes := make([]thumbnailOutcome, len(audio))
+	names := make([]string, len(audio))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	workers := min(GetBackendConfig().MaxConcurrentOperations, max(len(audio), 1))
+	eta := newBatchETA(libraryFileSizes(audio), workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				file := audio[idx]
+				updateThumbnailCacheProgress(func(p *ThumbnailCacheProgress) {
+					p.CurrentFile = file.path
+				})
+				started := time.Now()
+				names[idx] = thumbnailName(file.path, file.modTime)
+				outcomes[idx] = writeThumbnail(file.path, filepath.Join(cacheDir, names[idx]), maxDim)
+				eta.done(file.size, time.Since(started))
+				updateThumbnailCacheProgress(func(p *ThumbnailCacheProgress) {
+					p.Completed++
+					p.ProgressPct = float64(p.Completed) / float64(p.Total) * 100
+					p.EstimatedRemainingMs = eta.remainingMs()
+				})
+			}
+		}()
+	}
+	for idx := range audio {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := ThumbnailCacheReport{CacheDir: cacheDir, Thumbnails: make(map[string]string)}
+	keep := make(map[string]bool)
+	for idx, outcome := range outcomes {
+		path := audio[idx].path
+		switch {
+		case outcome.err != nil:
+			report.Failed++
+			if report.Errors == nil {
+				report.Errors = make(map[string]string)
+			}
+			report.Errors[path] = outcome.err.Error()
+		case outcome.noCover:
+			report.NoCover++
+		default:
+			if outcome.created {
+				report.Created++
+			} else {
+				report.UpToDate++
+			}
+			keep[names[idx]] = true
+			report.Thumbnails[path] = filepath.Join(cacheDir, names[idx])
+		}
+	}
+
+	if entries, err := os.ReadDir(cacheDir); err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() || !thumbnailNamePattern.MatchString(entry.Name()) || keep[entry.Name()] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(cacheDir, entry.Name())); err == nil {
+				report.Pruned++
+			}
+		}
+	}
+
+	updateThumbnailCacheProgress(func(p *ThumbnailCacheProgress) {
+		p.IsComplete = true
+		p.CurrentFile = ""
+		p.EstimatedRemainingMs = 0
+	})
+	GoLog("[Thumbnails] %d created, %d up to date, %d pruned under %s\n",
+		report.Created, report.UpToDate, report.Pruned, cacheDir)
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
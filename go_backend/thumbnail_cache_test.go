@@ -0,0 +1,59 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"image"
+	_ "image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func buildThumbnails(t *testing.T, root, cacheDir string, maxDim int) ThumbnailCacheReport {
+	t.Helper()
+	raw, err := BuildThumbnailCache(root, cacheDir, maxDim)
+	report := mustDecodeJSON[ThumbnailCacheReport](t, raw, err)
+	return report
+}
+
+func TestBuildThumbnailCache(t *testing.T) {
+	root := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "thumbs")
+	covered := writeShrinkFixture(t, root, "covered.flac", 64)
+	doomed := writeShrinkFixture(t, root, "doomed.flac", 40)
+	writeConsistencyFixture(t, root, "plain.flac", Metadata{Title: "No Cover"})
+
+	report := buildThumbnails(t, root, cacheDir, 16)
+	if report.Created != 2 || report.NoCover != 1 || report.Failed != 0 || len(report.Thumbnails) != 2 {
+		t.Fatalf("unexpected first run: %+v", report)
+	}
+	f, err := os.Open(report.Thumbnails[covered])
+	if err != nil {
+		t.Fatalf("open thumbnail: %v", err)
+	}
+	cfg, format, err := image.DecodeConfig(f)
+	f.Close()
+	if err != nil || format != "jpeg" || cfg.Width != 16 || cfg.Height != 16 {
+		t.Fatalf("unexpected thumbnail: %s %+v %v", format, cfg, err)
+	}
+
+	unrelated := filepath.Join(cacheDir, "cover_1234.jpg")
+	if err := os.WriteFile(unrelated, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(doomed); err != nil {
+		t.Fatal(err)
+	}
+	report = buildThumbnails(t, root, cacheDir, 16)
+	if report.Created != 0 || report.UpToDate != 1 || report.Pruned != 1 || len(report.Thumbnails) != 1 {
+		t.Fatalf("unexpected second run: %+v", report)
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Fatal("pruning removed a file it does not own")
+	}
+
+	var progress ThumbnailCacheProgress
+	if err := json.Unmarshal([]byte(GetThumbnailCacheProgress()), &progress); err != nil || !progress.IsComplete || progress.Completed != 2 {
+		t.Fatalf("unexpected progress: %+v %v", progress, err)
+	}
+}
@@ -1,6 +1,7 @@
 package gobackend
 
 import (
+	"regexp"
 	"strings"
 	"unicode"
 
@@ -74,6 +75,26 @@ func normalizeLooseArtistName(name string) string {
 	return strings.Join(strings.Fields(b.String()), " ")
 }
 
+// trackVersionSuffixPattern matches release-level suffixes that do not
+// change the recording: "(Remastered 2011)", "[Explicit]", " - 2009
+// Remaster", " - Single Version". Live, remix and edit suffixes are kept,
+// since those are different recordings.
+var trackVersionSuffixPattern = regexp.MustCompile(`(?i)\s*(?:[\(\[][^\)\]]*\b(?:remaster(?:ed)?|explicit|clean|album version|single version)\b[^\)\]]*[\)\]]|\s-\s[^-]*\b(?:remaster(?:ed)?|album version|single version)\b[^-]*$)`)
+
+// normalizeTrackMatchKey builds the "artist – title" string fuzzy track
+// matching compares: primary artists only, without a leading "The", and the
+// title without its featuring credit or remaster suffixes, both loosened
+// like normalizeLooseArtistName and normalizeLooseTitle.
+func normalizeTrackMatchKey(artist, title string) string {
+	primary, _ := splitFeaturedArtists(artist)
+	artistKey := normalizeLooseArtistName(strings.Join(primary, " "))
+	artistKey = strings.TrimPrefix(artistKey, "the ")
+
+	title, _ = stripTitleFeaturing(title)
+	titleKey := normalizeLooseTitle(trackVersionSuffixPattern.ReplaceAllString(title, ""))
+	return artistKey + " – " + titleKey
+}
+
 func hasAlphaNumericRunes(value string) bool {
 	for _, r := range value {
 		if unicode.IsLetter(r) || unicode.IsNumber(r) {
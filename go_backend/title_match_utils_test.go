@@ -69,3 +69,53 @@ func TestTitlesMatch_EmojiStrict(t *testing.T) {
 		t.Fatal("expected identical emoji titles to match")
 	}
 }
+
+func TestNormalizeTrackMatchKey(t *testing.T) {
+	cases := []struct {
+		artist, title, want string
+	}{
+		{"The Beatles", "Yesterday - Remastered 2009", "beatles – yesterday"},
+		{"Beatles", "Yesterday (2009 Remaster)", "beatles – yesterday"},
+		{"Daft Punk feat. Pharrell Williams", "Get Lucky [Explicit]", "daft punk – get lucky"},
+		{"Daft Punk", "Get Lucky (feat. Pharrell Williams & Nile Rodgers)", "daft punk – get lucky"},
+		{"Beyoncé", "Halo - Single Version", "beyonce – halo"},
+		{"Nirvana", "Lithium (Live at Reading)", "nirvana – lithium live at reading"},
+		{"Queen", "Don't Stop Me Now - Remix", "queen – dont stop me now remix"},
+	}
+	for _, c := range cases {
+		if got := normalizeTrackMatchKey(c.artist, c.title); got != c.want {
+			t.Errorf("normalizeTrackMatchKey(%q, %q) = %q, want %q", c.artist, c.title, got, c.want)
+		}
+	}
+}
+
+func TestTrackKeySimilarityTrickyPairs(t *testing.T) {
+	cfg := GetBackendConfig()
+	cases := []struct {
+		a, b  [2]string
+		match bool
+	}{
+		{[2]string{"The Beatles", "Yesterday"}, [2]string{"Beatles", "Yesterday (Remastered 2009)"}, true},
+		{[2]string{"Simon & Garfunkel", "The Boxer"}, [2]string{"Simon and Garfunkel", "The Boxer"}, true},
+		{[2]string{"AC/DC", "Back In Black"}, [2]string{"ACDC", "Back in Black"}, true},
+		{[2]string{"Mumford & Sons", "Little Lion Man"}, [2]string{"Mumford and Sons", "Little Lion Man"}, true},
+		{[2]string{"Calvin Harris & Rihanna", "This Is What You Came For"}, [2]string{"Rihanna & Calvin Harris", "This Is What You Came For"}, true},
+		{[2]string{"Guns N' Roses", "Sweet Child O' Mine"}, [2]string{"Guns N Roses", "Sweet Child O Mine"}, true},
+		{[2]string{"Nirvana", "Lithium"}, [2]string{"Nirvana", "Lithium (Live at Reading)"}, false},
+		{[2]string{"The Beatles", "Yesterday"}, [2]string{"Frank Sinatra", "Yesterday"}, false},
+		{[2]string{"Queen", "Don't Stop Me Now"}, [2]string{"Queen", "Don't Stop Me Now - Remix"}, false},
+		{[2]string{"Adele", "Hello"}, [2]string{"Lionel Richie", "Hello"}, false},
+		{[2]string{"Pink Floyd", "Another Brick in the Wall, Pt. 1"}, [2]string{"Pink Floyd", "Another Brick in the Wall, Pt. 3"}, false},
+		{[2]string{"Pink Floyd", "Another Brick in the Wall, Pt. 2"}, [2]string{"Pink Floyd", "Another Brick in the Wall Pt 2"}, true},
+		{[2]string{"Artist", "Interlude 1"}, [2]string{"Artist", "Interlude 2"}, false},
+		{[2]string{"Artist", "Symphony No. II"}, [2]string{"Artist", "Symphony No. III"}, false},
+		{[2]string{"Artist", "Chapter IV"}, [2]string{"Artist", "Chapter 4"}, true},
+		{[2]string{"Blink-182", "All the Small Things"}, [2]string{"blink 182", "All The Small Things"}, true},
+	}
+	for _, c := range cases {
+		score := trackKeySimilarity(normalizeTrackMatchKey(c.a[0], c.a[1]), normalizeTrackMatchKey(c.b[0], c.b[1]))
+		if got := score >= cfg.FuzzyMatchThreshold; got != c.match {
+			t.Errorf("%q vs %q: score %.3f, match %v, want %v", c.a, c.b, score, got, c.match)
+		}
+	}
+}
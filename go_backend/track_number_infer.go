@@ -0,0 +1,354 @@
+package gobackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trackNamePattern matches the leading numeral of a file name: a vinyl side
+// and position ("A1"), a disc and track ("2-05", "1.07"), or a track
+// ("01", "1-"), followed by a separator or the end of the name. Four-digit
+// numbers such as years do not match.
+var trackNamePattern = regexp.MustCompile(`^\s*(?:([A-Za-z])(\d{1,2})|(\d{1,2})[-.](\d{1,3})|(\d{1,3}))(?:[\s._\-)\]]|$)`)
+
+// Where InferTrackNumbers took its numbering from.
+const (
+	// TrackNumberFromName: the leading numerals of the file names.
+	TrackNumberFromName = "filename"
+	// TrackNumberFromOrder: no file name starts with a numeral, so the files
+	// are numbered in name order.
+	TrackNumberFromOrder = "order"
+)
+
+// Per-track actions of InferTrackNumbers.
+const (
+	// TrackNumberSet: TRACKNUMBER is empty and is (or, without apply, would
+	// be) set to Track.
+	TrackNumberSet = "set"
+	// TrackNumberKeep: the track already has a number, which is never
+	// overwritten.
+	TrackNumberKeep = "keep"
+	// TrackNumberNoNumeral: the file name has no numeral while others do.
+	TrackNumberNoNumeral = "no_numeral"
+	// TrackNumberDuplicate: another track of the disc has the same number.
+	TrackNumberDuplicate = "duplicate"
+	// TrackNumberAmbiguous: a vinyl side has gaps or repeats, so the
+	// position of its tracks in the album is unknown.
+	TrackNumberAmbiguous = "ambiguous"
+	// TrackNumberUnsupported: a number was inferred but only FLAC files are
+	// written.
+	TrackNumberUnsupported = "unsupported"
+	// TrackNumberFailed: the track could not be read or written.
+	TrackNumberFailed = "failed"
+)
+
+type TrackNumberProposal struct {
+	Path string `json:"path"`
+	// Disc is the disc number from a "2-05" style name, written only to
+	// tracks without one.
+	Disc  int `json:"disc,omitempty"`
+	Track int `json:"track,omitempty"`
+	// Total is the TOTALTRACKS written with Track, zero when the disc has
+	// gaps or duplicates.
+	Total    int    `json:"total,omitempty"`
+	Existing int    `json:"existing,omitempty"`
+	Action   string `json:"action"`
+	Error    string `json:"error,omitempty"`
+}
+
+// TrackNumberGap lists the numbers missing between 1 and the highest track
+// of a disc, or of a vinyl side when Side is set.
+type TrackNumberGap struct {
+	Disc    int    `json:"disc,omitempty"`
+	Side    string `json:"side,omitempty"`
+	Missing []int  `json:"missing"`
+}
+
+// TrackNumberInference is the result of InferTrackNumbers.
+type TrackNumberInference struct {
+	Directory          string                `json:"directory"`
+	Source             string                `json:"source"`
+	Applied            bool                  `json:"applied"`
+	Set                int                   `json:"set"`
+	Duplicates         int                   `json:"duplicates"`
+	QuarantinedSkipped int                   `json:"quarantined_skipped"`
+	Gaps               []TrackNumberGap      `json:"gaps"`
+	Tracks             []TrackNumberProposal `json:"tracks"`
+}
+
+// parsedTrackName is the leading numeral of a file name.
+type parsedTrackName struct {
+	side  string
+	disc  int
+	track int
+	ok    bool
+}
+
+func parseTrackName(name string) parsedTrackName {
+	m := trackNamePattern.FindStringSubmatch(strings.TrimSuffix(name, filepath.Ext(name)))
+	if m == nil {
+		return parsedTrackName{}
+	}
+	switch {
+	case m[1] != "":
+		n, _ := strconv.Atoi(m[2])
+		return parsedTrackName{side: strings.ToUpper(m[1]), track: n, ok: n > 0}
+	case m[3] != "":
+		disc, _ := strconv.Atoi(m[3])
+		n, _ := strconv.Atoi(m[4])
+		return parsedTrackName{disc: disc, track: n, ok: disc > 0 && n > 0}
+	}
+	n, _ := strconv.Atoi(m[5])
+	return parsedTrackName{track: n, ok: n > 0}
+}
+
+// missingNumbers returns the numbers from 1 to the highest of numbers that
+// it lacks.
+func missingNumbers(numbers []int) []int {
+	seen := make(map[int]bool, len(numbers))
+	highest := 0
+	for _, n := range numbers {
+		seen[n] = true
+		highest = max(highest, n)
+	}
+	var missing []int
+	for n := 1; n <= highest; n++ {
+		if !seen[n] {
+			missing = append(missing, n)
+		}
+	}
+	return missing
+}
+
+// numberVinylSides turns side positions into album positions, side A first:
+// A1..A4 then B1 become 1..5. A side with gaps or repeated positions makes
+// every sided track ambiguous, reported with the side's gaps.
+func numberVinylSides(parsed []parsedTrackName, tracks []TrackNumberProposal) []TrackNumberGap {
+	positions := make(map[string][]int)
+	var sided []int
+	for i, p := range parsed {
+		if p.ok && p.side != "" && tracks[i].Action == "" {
+			positions[p.side] = append(positions[p.side], p.track)
+			sided = append(sided, i)
+		}
+	}
+	if len(sided) == 0 {
+		return nil
+	}
+	var gaps []TrackNumberGap
+	sides := make([]string, 0, len(positions))
+	for side, numbers := range positions {
+		sides = append(sides, side)
+		sort.Ints(numbers)
+		repeated := false
+		for j := 1; j < len(numbers); j++ {
+			repeated = repeated || numbers[j] == numbers[j-1]
+		}
+		missing := missingNumbers(numbers)
+		if len(missing) > 0 || repeated {
+			gaps = append(gaps, TrackNumberGap{Side: side, Missing: missing})
+		}
+	}
+	sort.Strings(sides)
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i].Side < gaps[j].Side })
+	if len(gaps) > 0 {
+		for _, i := range sided {
+			tracks[i].Action = TrackNumberAmbiguous
+		}
+		return gaps
+	}
+
+	offset := make(map[string]int, len(sides))
+	total := 0
+	for _, side := range sides {
+		offset[side] = total
+		total += len(positions[side])
+	}
+	for _, i := range sided {
+		tracks[i].Track = offset[parsed[i].side] + parsed[i].track
+	}
+	return nil
+}
+
+// InferTrackNumbers proposes TRACKNUMBER for the audio files directly in
+// dirPath that have none, from the leading numeral of their names ("01
+// Title", "1-Title", "2-05 Title" for disc 2, "A1 Title" for vinyl sides).
+// Only when no name has a numeral are the files numbered in name order.
+// Each disc's TOTALTRACKS is its track count, left unset when the numbers
+// have gaps or duplicates, which are reported rather than resolved.
+// Existing track numbers are never overwritten but count towards
+// duplicates and gaps. With apply the proposed numbers are written to the
+// FLAC files.
+func InferTrackNumbers(dirPath string, apply bool) (string, error) {
+	if strings.TrimSpace(dirPath) == "" {
+		return "", fmt.Errorf("folder path is empty")
+	}
+	if info, err := os.Stat(dirPath); err != nil {
+		return "", fmt.Errorf("folder not found: %w", err)
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("path is not a folder: %s", dirPath)
+	}
+	if apply {
+		if err := checkWriteAllowed(dirPath); err != nil {
+			return "", err
+		}
+	}
+	files, quarantined, err := collectWritableLibraryFiles(dirPath, nil)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+
+	result := TrackNumberInference{Directory: dirPath, Source: TrackNumberFromName, Applied: apply, Gaps: []TrackNumberGap{}, Tracks: []TrackNumberProposal{}}
+	result.QuarantinedSkipped = quarantined
+	var parsed []parsedTrackName
+	var existingDiscs []int
+	anyNumeral := false
+	scanTime := time.Now().UTC().Format(time.RFC3339)
+	for _, file := range files {
+		if filepath.Dir(file.path) != filepath.Clean(dirPath) || strings.EqualFold(filepath.Ext(file.path), ".cue") {
+			continue
+		}
+		track := TrackNumberProposal{Path: file.path}
+		existingDisc := 0
+		if scanned, err := scanAudioFileWithKnownModTime(file.path, scanTime, file.modTime); err != nil {
+			track.Action, track.Error = TrackNumberFailed, err.Error()
+		} else if !scanned.MetadataFromFilename && scanned.TrackNumber > 0 {
+			track.Action, track.Existing, existingDisc = TrackNumberKeep, scanned.TrackNumber, scanned.DiscNumber
+		} else if !scanned.MetadataFromFilename {
+			existingDisc = scanned.DiscNumber
+		}
+		p := parseTrackName(filepath.Base(file.path))
+		anyNumeral = anyNumeral || p.ok
+		parsed = append(parsed, p)
+		existingDiscs = append(existingDiscs, existingDisc)
+		result.Tracks = append(result.Tracks, track)
+	}
+	if len(result.Tracks) == 0 {
+		return "", fmt.Errorf("no audio files in %s", dirPath)
+	}
+
+	if !anyNumeral {
+		result.Source = TrackNumberFromOrder
+		for i := range result.Tracks {
+			if result.Tracks[i].Action == "" {
+				result.Tracks[i].Track = i + 1
+			}
+		}
+	} else {
+		result.Gaps = append(result.Gaps, numberVinylSides(parsed, result.Tracks)...)
+		for i, p := range parsed {
+			track := &result.Tracks[i]
+			switch {
+			case track.Action != "" || p.side != "":
+			case !p.ok:
+				track.Action = TrackNumberNoNumeral
+			default:
+				track.Track = p.track
+				if existingDiscs[i] == 0 {
+					track.Disc = p.disc
+				}
+			}
+		}
+	}
+
+	// Group every numbered track, kept ones included, by disc to find
+	// duplicates, gaps and totals.
+	discOf := func(i int) int {
+		disc := max(existingDiscs[i], result.Tracks[i].Disc, parsed[i].disc)
+		return max(disc, 1)
+	}
+	numberOf := func(i int) int {
+		if result.Tracks[i].Action == TrackNumberKeep {
+			return result.Tracks[i].Existing
+		}
+		return result.Tracks[i].Track
+	}
+	byDisc := make(map[int]map[int][]int)
+	for i := range result.Tracks {
+		if n := numberOf(i); n > 0 {
+			disc := discOf(i)
+			if byDisc[disc] == nil {
+				byDisc[disc] = make(map[int][]int)
+			}
+			byDisc[disc][n] = append(byDisc[disc][n], i)
+		}
+	}
+	discs := make([]int, 0, len(byDisc))
+	for disc := range byDisc {
+		discs = append(discs, disc)
+	}
+	sort.Ints(discs)
+	totals := make(map[int]int, len(discs))
+	for _, disc := range discs {
+		numbers := make([]int, 0, len(byDisc[disc]))
+		clean := true
+		for n, members := range byDisc[disc] {
+			numbers = append(numbers, n)
+			if len(members) < 2 {
+				continue
+			}
+			clean = false
+			for _, i := range members {
+				result.Duplicates++
+				if result.Tracks[i].Action == "" {
+					result.Tracks[i].Action = TrackNumberDuplicate
+				}
+			}
+		}
+		if missing := missingNumbers(numbers); len(missing) > 0 {
+			clean = false
+			gap := TrackNumberGap{Missing: missing}
+			if len(discs) > 1 || disc > 1 {
+				gap.Disc = disc
+			}
+			result.Gaps = append(result.Gaps, gap)
+		}
+		if clean {
+			totals[disc] = len(numbers)
+		}
+	}
+
+	for i := range result.Tracks {
+		track := &result.Tracks[i]
+		if track.Action != "" {
+			continue
+		}
+		track.Total = totals[discOf(i)]
+		if !strings.EqualFold(filepath.Ext(track.Path), ".flac") {
+			track.Action = TrackNumberUnsupported
+			continue
+		}
+		track.Action = TrackNumberSet
+		if apply {
+			fields := map[string]string{"track_number": strconv.Itoa(track.Track)}
+			if track.Total > 0 {
+				fields["track_total"] = strconv.Itoa(track.Total)
+			}
+			if track.Disc > 0 {
+				fields["disc_number"] = strconv.Itoa(track.Disc)
+			}
+			if err := EditFlacFields(track.Path, fields); err != nil {
+				track.Action, track.Error = TrackNumberFailed, err.Error()
+				continue
+			}
+		}
+		result.Set++
+	}
+
+	GoLog("[TrackNumbers] Inferred numbers from %s for %s: %d to set, %d duplicates, %d gaps (apply: %v)\n",
+		result.Source, dirPath, result.Set, result.Duplicates, len(result.Gaps), apply)
+
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,136 @@
+package gobackend
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseTrackName(t *testing.T) {
+	cases := []struct {
+		name  string
+		want  parsedTrackName
+		match bool
+	}{
+		{"01 Intro.flac", parsedTrackName{track: 1, ok: true}, true},
+		{"1-Intro.flac", parsedTrackName{track: 1, ok: true}, true},
+		{"07. Song.flac", parsedTrackName{track: 7, ok: true}, true},
+		{"2-05 Song.flac", parsedTrackName{disc: 2, track: 5, ok: true}, true},
+		{"b3 Song.flac", parsedTrackName{side: "B", track: 3, ok: true}, true},
+		{"12.flac", parsedTrackName{track: 12, ok: true}, true},
+		{"1999 Song.flac", parsedTrackName{}, false},
+		{"B52s Song.flac", parsedTrackName{}, false},
+		{"Intro.flac", parsedTrackName{}, false},
+		{"00 Hidden.flac", parsedTrackName{}, false},
+	}
+	for _, c := range cases {
+		if got := parseTrackName(c.name); got != c.want || got.ok != c.match {
+			t.Fatalf("parseTrackName(%q) = %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func inferTrackNumbersFixture(t *testing.T, dir string, apply bool) (TrackNumberInference, map[string]TrackNumberProposal) {
+	t.Helper()
+	out, err := InferTrackNumbers(dir, apply)
+	result := mustDecodeJSON[TrackNumberInference](t, out, err)
+	byName := make(map[string]TrackNumberProposal)
+	for _, track := range result.Tracks {
+		byName[filepath.Base(track.Path)] = track
+	}
+	return result, byName
+}
+
+func TestInferTrackNumbersFromNames(t *testing.T) {
+	dir := t.TempDir()
+	first := writeConsistencyFixture(t, dir, "1-First.flac", Metadata{Title: "First"})
+	writeConsistencyFixture(t, dir, "02 Second.flac", Metadata{Title: "Second"})
+	kept := writeConsistencyFixture(t, dir, "Third.flac", Metadata{Title: "Third", TrackNumber: 3})
+	writeConsistencyFixture(t, dir, "sub/01 Other.flac", Metadata{Title: "Other"})
+
+	result, tracks := inferTrackNumbersFixture(t, dir, false)
+	if result.Source != TrackNumberFromName || result.Set != 2 || len(result.Tracks) != 3 || len(result.Gaps) != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+	if tr := tracks["1-First.flac"]; tr.Action != TrackNumberSet || tr.Track != 1 || tr.Total != 3 {
+		t.Fatalf("first = %+v", tr)
+	}
+	if tr := tracks["Third.flac"]; tr.Action != TrackNumberKeep || tr.Existing != 3 {
+		t.Fatalf("third = %+v", tr)
+	}
+	if md, _ := ReadMetadata(first); md.TrackNumber != 0 {
+		t.Fatal("proposal without apply wrote a track number")
+	}
+
+	inferTrackNumbersFixture(t, dir, true)
+	if md, err := ReadMetadata(first); err != nil || md.TrackNumber != 1 || md.TotalTracks != 3 {
+		t.Fatalf("first after apply: %+v %v", md, err)
+	}
+	if md, err := ReadMetadata(kept); err != nil || md.TrackNumber != 3 || md.TotalTracks != 0 {
+		t.Fatalf("existing number touched: %+v %v", md, err)
+	}
+	if result, _ := inferTrackNumbersFixture(t, dir, false); result.Set != 0 {
+		t.Fatalf("second run proposes changes: %+v", result)
+	}
+}
+
+func TestInferTrackNumbersReportsGapsAndDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	writeConsistencyFixture(t, dir, "01 A.flac", Metadata{})
+	writeConsistencyFixture(t, dir, "01 B.flac", Metadata{})
+	writeConsistencyFixture(t, dir, "04 D.flac", Metadata{})
+	writeConsistencyFixture(t, dir, "Bonus.flac", Metadata{})
+
+	result, tracks := inferTrackNumbersFixture(t, dir, true)
+	if result.Duplicates != 2 || result.Set != 1 || len(result.Gaps) != 1 || !slices.Equal(result.Gaps[0].Missing, []int{2, 3}) {
+		t.Fatalf("result = %+v", result)
+	}
+	if tracks["01 A.flac"].Action != TrackNumberDuplicate || tracks["01 B.flac"].Action != TrackNumberDuplicate {
+		t.Fatalf("duplicates = %+v", result.Tracks)
+	}
+	if tr := tracks["04 D.flac"]; tr.Action != TrackNumberSet || tr.Total != 0 {
+		t.Fatalf("04 = %+v", tr)
+	}
+	if tracks["Bonus.flac"].Action != TrackNumberNoNumeral {
+		t.Fatalf("bonus = %+v", tracks["Bonus.flac"])
+	}
+	if md, _ := ReadMetadata(filepath.Join(dir, "01 A.flac")); md.TrackNumber != 0 {
+		t.Fatal("duplicate was numbered")
+	}
+}
+
+func TestInferTrackNumbersDiscsSidesAndOrder(t *testing.T) {
+	discs := t.TempDir()
+	writeConsistencyFixture(t, discs, "1-01 A.flac", Metadata{})
+	writeConsistencyFixture(t, discs, "1-02 B.flac", Metadata{})
+	c := writeConsistencyFixture(t, discs, "2-01 C.flac", Metadata{})
+	result, tracks := inferTrackNumbersFixture(t, discs, true)
+	if result.Set != 3 || tracks["1-02 B.flac"].Total != 2 || tracks["2-01 C.flac"].Total != 1 {
+		t.Fatalf("discs = %+v", result)
+	}
+	if md, err := ReadMetadata(c); err != nil || md.DiscNumber != 2 || md.TrackNumber != 1 {
+		t.Fatalf("disc 2 track: %+v %v", md, err)
+	}
+
+	sides := t.TempDir()
+	for _, name := range []string{"A1 x.flac", "A2 x.flac", "B1 x.flac"} {
+		writeConsistencyFixture(t, sides, name, Metadata{})
+	}
+	_, tracks = inferTrackNumbersFixture(t, sides, false)
+	if tracks["A2 x.flac"].Track != 2 || tracks["B1 x.flac"].Track != 3 || tracks["B1 x.flac"].Total != 3 {
+		t.Fatalf("sides = %+v", tracks)
+	}
+	writeConsistencyFixture(t, sides, "B3 x.flac", Metadata{})
+	result, tracks = inferTrackNumbersFixture(t, sides, false)
+	if tracks["A1 x.flac"].Action != TrackNumberAmbiguous || len(result.Gaps) != 1 || result.Gaps[0].Side != "B" {
+		t.Fatalf("side gap = %+v", result)
+	}
+
+	plain := t.TempDir()
+	writeConsistencyFixture(t, plain, "Beta.flac", Metadata{})
+	writeConsistencyFixture(t, plain, "Alpha.flac", Metadata{})
+	result, tracks = inferTrackNumbersFixture(t, plain, false)
+	if result.Source != TrackNumberFromOrder || tracks["Alpha.flac"].Track != 1 || tracks["Beta.flac"].Track != 2 || tracks["Beta.flac"].Total != 2 {
+		t.Fatalf("order = %+v", result)
+	}
+}
@@ -0,0 +1,50 @@
+package gobackend
+
+import (
+	"cmp"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// TrackRef is what SortTracks orders a track by. A disc or track number of
+// zero or less means the tag is missing.
+type TrackRef struct {
+	Path        string `json:"path"`
+	DiscNumber  int    `json:"disc_number"`
+	TrackNumber int    `json:"track_number"`
+}
+
+// compareTrackRefs orders by disc, a missing disc counting as disc 1, then
+// by track number with unnumbered tracks after the numbered ones, then by
+// folder and file name. Vinyl positions such as "A1" do not parse as track
+// numbers, so those tracks fall back to file name order.
+func compareTrackRefs(a, b TrackRef) int {
+	if c := cmp.Compare(max(a.DiscNumber, 1), max(b.DiscNumber, 1)); c != 0 {
+		return c
+	}
+	aNumbered, bNumbered := a.TrackNumber > 0, b.TrackNumber > 0
+	if aNumbered != bNumbered {
+		if aNumbered {
+			return -1
+		}
+		return 1
+	}
+	if c := cmp.Compare(max(a.TrackNumber, 0), max(b.TrackNumber, 0)); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(strings.ToLower(filepath.Dir(a.Path)), strings.ToLower(filepath.Dir(b.Path))); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(strings.ToLower(filepath.Base(a.Path)), strings.ToLower(filepath.Base(b.Path))); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Path, b.Path)
+}
+
+// SortTracks sorts tracks into album order: by disc, then track number,
+// then file name. Missing values sort deterministically, so playlists, cue
+// sheets and album views built from the same files agree on the order.
+func SortTracks(tracks []TrackRef) {
+	slices.SortStableFunc(tracks, compareTrackRefs)
+}
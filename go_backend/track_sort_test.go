@@ -0,0 +1,49 @@
+package gobackend
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSortTracks(t *testing.T) {
+	tracks := []TrackRef{
+		{Path: "/a/Bonus.flac"},
+		{Path: "/a/x.flac", DiscNumber: 2, TrackNumber: 1},
+		{Path: "/a/y.flac", DiscNumber: 1, TrackNumber: 2},
+		{Path: "/a/b.flac", TrackNumber: 1},
+		{Path: "/a/A2 Side.flac", DiscNumber: 1},
+		{Path: "/a/a1 side.flac", TrackNumber: -3},
+		{Path: "/a/z.flac", DiscNumber: 2},
+	}
+	SortTracks(tracks)
+	var got []string
+	for _, track := range tracks {
+		got = append(got, filepath.Base(track.Path))
+	}
+	want := []string{"b.flac", "y.flac", "a1 side.flac", "A2 Side.flac", "Bonus.flac", "x.flac", "z.flac"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("order = %q, want %q", got, want)
+	}
+	SortTracks(nil)
+}
+
+func TestReadAlbumOrdersVinylPositionsByName(t *testing.T) {
+	dir := t.TempDir()
+	writeConsistencyFixture(t, dir, "B1 Last.flac", Metadata{Title: "B1", Album: "LP"})
+	side := writeConsistencyFixture(t, dir, "A1 First.flac", Metadata{Title: "A1", Album: "LP"})
+	if err := SetTags(side, []TagPair{{Key: "TRACKNUMBER", Value: "A1"}}, true); err != nil {
+		t.Fatalf("SetTags: %v", err)
+	}
+	writeConsistencyFixture(t, dir, "Numbered.flac", Metadata{Title: "N", Album: "LP", TrackNumber: 1})
+
+	out, err := ReadAlbum(dir)
+	view := mustDecodeJSON[AlbumView](t, out, err)
+	var titles []string
+	for _, track := range view.Tracks {
+		titles = append(titles, track.Title)
+	}
+	if !slices.Equal(titles, []string{"N", "A1", "B1"}) {
+		t.Fatalf("titles = %q", titles)
+	}
+}
@@ -0,0 +1,254 @@
+package gobackend
+
+import (
+	"slices"
+	"strings"
+)
+
+// vorbisCommentMap is a key→values view of a Vorbis comment list. Tag
+// writers build it once, apply every field update against it and flatten it
+// back at the end, instead of rescanning and re-slicing the comment list for
+// each field; files with hundreds of per-performer credits made the old
+// per-field scan quadratic.
+//
+// The original list is kept as is and updates are overlaid on it, so
+// untouched comments come back in their exact original order and spelling.
+// An updated key is written where its first occurrence was; new keys are
+// appended. Keys are matched case-insensitively.
+type vorbisCommentMap struct {
+	base []string
+	// first maps an upper-cased key to its first index in base.
+	first map[string]int
+	// updates holds the replacement KEY=value entries per upper-cased key;
+	// nil removes the key.
+	updates map[string][]string
+	added   []string
+}
+
+func vorbisCommentKey(comment string) (string, bool) {
+	eqIdx := strings.Index(comment, "=")
+	if eqIdx <= 0 {
+		return "", false
+	}
+	return strings.ToUpper(comment[:eqIdx]), true
+}
+
+func newVorbisCommentMap(comments []string) *vorbisCommentMap {
+	m := &vorbisCommentMap{
+		base:    comments,
+		first:   make(map[string]int),
+		updates: make(map[string][]string),
+	}
+	for i, comment := range comments {
+		if key, ok := vorbisCommentKey(comment); ok {
+			if _, seen := m.first[key]; !seen {
+				m.first[key] = i
+			}
+		}
+	}
+	return m
+}
+
+func vorbisCommentValue(comment string) string {
+	return comment[strings.Index(comment, "=")+1:]
+}
+
+func (m *vorbisCommentMap) getValues(key string) []string {
+	upper := strings.ToUpper(key)
+	var values []string
+	if updated, ok := m.updates[upper]; ok {
+		for _, comment := range updated {
+			values = append(values, vorbisCommentValue(comment))
+		}
+		return values
+	}
+	start, ok := m.first[upper]
+	if !ok {
+		return nil
+	}
+	for _, comment := range m.base[start:] {
+		if commentKey, ok := vorbisCommentKey(comment); ok && commentKey == upper {
+			values = append(values, vorbisCommentValue(comment))
+		}
+	}
+	return values
+}
+
+func (m *vorbisCommentMap) get(key string) string {
+	upper := strings.ToUpper(key)
+	if updated, ok := m.updates[upper]; ok {
+		if len(updated) == 0 {
+			return ""
+		}
+		return vorbisCommentValue(updated[0])
+	}
+	if start, ok := m.first[upper]; ok {
+		return vorbisCommentValue(m.base[start])
+	}
+	return ""
+}
+
+func (m *vorbisCommentMap) setValues(key string, values []string) {
+	upper := strings.ToUpper(key)
+	if _, inBase := m.first[upper]; !inBase {
+		if _, pending := m.updates[upper]; !pending {
+			m.added = append(m.added, key)
+		}
+	}
+	raw := make([]string, len(values))
+	for i, value := range values {
+		raw[i] = key + "=" + value
+	}
+	m.updates[upper] = raw
+}
+
+func (m *vorbisCommentMap) remove(key string) {
+	upper := strings.ToUpper(key)
+	_, inBase := m.first[upper]
+	_, pending := m.updates[upper]
+	if inBase || pending {
+		m.updates[upper] = nil
+	}
+}
+
+// set replaces key with value; an empty value leaves the key untouched.
+func (m *vorbisCommentMap) set(key, value string) {
+	if value == "" {
+		return
+	}
+	m.setValues(key, []string{value})
+}
+
+// setOrClear replaces key with value, or removes it when value is empty.
+func (m *vorbisCommentMap) setOrClear(key, value string) {
+	if value == "" {
+		m.remove(key)
+		return
+	}
+	m.setValues(key, []string{value})
+}
+
+func artistCommentValues(value, mode string) []string {
+	values := []string{value}
+	if shouldSplitVorbisArtistTags(mode) {
+		values = splitArtistTagValues(value)
+	}
+	kept := values[:0]
+	for _, artist := range values {
+		if strings.TrimSpace(artist) != "" {
+			kept = append(kept, artist)
+		}
+	}
+	return kept
+}
+
+func (m *vorbisCommentMap) setArtist(key, value, mode string) {
+	if value == "" {
+		return
+	}
+	values := artistCommentValues(value, mode)
+	if len(values) == 0 {
+		return
+	}
+	m.setValues(key, values)
+}
+
+func (m *vorbisCommentMap) setOrClearArtist(key, value, mode string) {
+	values := artistCommentValues(value, mode)
+	if value == "" || len(values) == 0 {
+		m.remove(key)
+		return
+	}
+	m.setValues(key, values)
+}
+
+// comments flattens the map back into KEY=value entries.
+func (m *vorbisCommentMap) comments() []string {
+	if len(m.updates) == 0 {
+		return m.base
+	}
+
+	out := make([]string, 0, len(m.base)+len(m.added))
+	for i, comment := range m.base {
+		key, ok := vorbisCommentKey(comment)
+		if !ok {
+			out = append(out, comment)
+			continue
+		}
+		values, updated := m.updates[key]
+		if !updated {
+			out = append(out, comment)
+			continue
+		}
+		if m.first[key] == i {
+			out = append(out, values...)
+		}
+	}
+	for _, key := range m.added {
+		out = append(out, m.updates[strings.ToUpper(key)]...)
+	}
+	return out
+}
+
+// dedupeVorbisComments drops comments repeating an earlier one exactly:
+// the same key, ignoring case, and the same value, respecting it. Distinct
+// values of a multi-valued key are kept. It returns how many were dropped.
+func dedupeVorbisComments(comments []string) ([]string, int) {
+	seen := make(map[string]bool, len(comments))
+	kept := make([]string, 0, len(comments))
+	for _, comment := range comments {
+		key, ok := vorbisCommentKey(comment)
+		if !ok {
+			kept = append(kept, comment)
+			continue
+		}
+		entry := key + "=" + vorbisCommentValue(comment)
+		if seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		kept = append(kept, comment)
+	}
+	return kept, len(comments) - len(kept)
+}
+
+// canonicalCommentOrder is the order EmbedOptions.SortTags writes the core
+// fields in; every other key follows alphabetically.
+var canonicalCommentOrder = []string{
+	"TITLE", "ARTIST", "ARTISTS", "ALBUM", "ALBUMARTIST",
+	"TRACKNUMBER", "TRACKTOTAL", "DISCNUMBER", "DISCTOTAL",
+	"DATE", "YEAR", "GENRE", "ISRC",
+}
+
+// sortVorbisComments returns comments in canonical order with upper-cased
+// keys: canonicalCommentOrder first, then the other keys alphabetically,
+// each key's values in their input order. Entries without a key go last.
+// Equal tags therefore always produce the same comment block, whatever
+// order the file had them in.
+func sortVorbisComments(comments []string) []string {
+	rank := func(key string) int {
+		if i := slices.Index(canonicalCommentOrder, key); i >= 0 {
+			return i
+		}
+		return len(canonicalCommentOrder)
+	}
+	sorted := make([]string, 0, len(comments))
+	var malformed []string
+	for _, comment := range comments {
+		key, ok := vorbisCommentKey(comment)
+		if !ok {
+			malformed = append(malformed, comment)
+			continue
+		}
+		sorted = append(sorted, key+"="+vorbisCommentValue(comment))
+	}
+	slices.SortStableFunc(sorted, func(a, b string) int {
+		keyA, _ := vorbisCommentKey(a)
+		keyB, _ := vorbisCommentKey(b)
+		if rankA, rankB := rank(keyA), rank(keyB); rankA != rankB {
+			return rankA - rankB
+		}
+		return strings.Compare(keyA, keyB)
+	})
+	return append(sorted, malformed...)
+}
@@ -0,0 +1,118 @@
+package gobackend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/go-flac/flacvorbis/v2"
+)
+
+func TestVorbisCommentMapPreservesOrderOfUntouchedKeys(t *testing.T) {
+	cmt := flacvorbis.New()
+	cmt.Comments = []string{
+		"PERFORMER=Drums",
+		"title=Old",
+		"CUSTOM_ID=42",
+		"PERFORMER=Bass",
+		"garbage-without-separator",
+		"GENRE=Jazz",
+	}
+
+	writeVorbisMetadata(cmt, Metadata{Title: "New", Album: "Album"})
+
+	want := []string{
+		"PERFORMER=Drums",
+		"TITLE=New",
+		"CUSTOM_ID=42",
+		"PERFORMER=Bass",
+		"garbage-without-separator",
+		"GENRE=Jazz",
+		"ALBUM=Album",
+	}
+	if !reflect.DeepEqual(cmt.Comments, want) {
+		t.Fatalf("comments = %#v, want %#v", cmt.Comments, want)
+	}
+}
+
+func TestVorbisCommentMapSetOrClearAndArtists(t *testing.T) {
+	m := newVorbisCommentMap([]string{"ARTIST=Old", "LYRICS=la", "COMMENT=x"})
+
+	m.remove("GENRE")
+	m.set("GENRE", "Jazz")
+	m.setOrClear("LYRICS", "")
+	m.setOrClearArtist("ARTIST", "A, B", artistTagModeSplitVorbis)
+	m.set("COMMENT", "")
+
+	want := []string{"ARTIST=A", "ARTIST=B", "COMMENT=x", "GENRE=Jazz"}
+	if got := m.comments(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("comments = %#v, want %#v", got, want)
+	}
+	if m.get("artist") != "A" || len(m.getValues("ARTIST")) != 2 {
+		t.Fatalf("unexpected artist values: %#v", m.getValues("ARTIST"))
+	}
+}
+
+// legacyWriteVorbisMetadata applies the same fields as writeVorbisMetadata
+// one comment-list scan per field, as the write path did before the map.
+func legacyWriteVorbisMetadata(cmt *flacvorbis.MetaDataBlockVorbisComment, metadata Metadata) {
+	setComment(cmt, "TITLE", metadata.Title)
+	setArtistComments(cmt, "ARTIST", metadata.Artist, metadata.ArtistTagMode)
+	setComment(cmt, "ALBUM", metadata.Album)
+	setArtistComments(cmt, "ALBUMARTIST", metadata.AlbumArtist, metadata.ArtistTagMode)
+	setComment(cmt, "DATE", metadata.Date)
+	setComment(cmt, "TRACKNUMBER", formatIndexValue(metadata.TrackNumber, metadata.TotalTracks))
+	setComment(cmt, "DISCNUMBER", formatIndexValue(metadata.DiscNumber, metadata.TotalDiscs))
+	setComment(cmt, "ISRC", metadata.ISRC)
+	setComment(cmt, "LYRICS", metadata.Lyrics)
+	setComment(cmt, "UNSYNCEDLYRICS", metadata.Lyrics)
+	setComment(cmt, "GENRE", metadata.Genre)
+	setComment(cmt, "ORGANIZATION", metadata.Label)
+	setComment(cmt, "COPYRIGHT", metadata.Copyright)
+	setComment(cmt, "COMPOSER", metadata.Composer)
+}
+
+func benchmarkComments(n int) []string {
+	comments := make([]string, 0, n+4)
+	for i := 0; i < n; i++ {
+		comments = append(comments, fmt.Sprintf("PERFORMER=Session Player %d (instrument)", i))
+	}
+	return append(comments, "TITLE=Old", "ARTIST=Old", "ALBUM=Old", "GENRE=Old")
+}
+
+var benchmarkMetadata = Metadata{
+	Title: "Song", Artist: "A, B", Album: "Album", AlbumArtist: "A", Date: "2024",
+	TrackNumber: 1, TotalTracks: 10, DiscNumber: 1, TotalDiscs: 1, ISRC: "USABC0100001",
+	Lyrics: "la la", Genre: "Jazz", Label: "Label", Copyright: "(c)", Composer: "C",
+	ArtistTagMode: artistTagModeSplitVorbis,
+}
+
+func BenchmarkWriteVorbisMetadata(b *testing.B) {
+	for _, n := range []int{10, 300, 1000} {
+		base := benchmarkComments(n)
+		b.Run(fmt.Sprintf("map/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				cmt := flacvorbis.New()
+				cmt.Comments = append([]string(nil), base...)
+				writeVorbisMetadata(cmt, benchmarkMetadata)
+			}
+		})
+		b.Run(fmt.Sprintf("legacy/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				cmt := flacvorbis.New()
+				cmt.Comments = append([]string(nil), base...)
+				legacyWriteVorbisMetadata(cmt, benchmarkMetadata)
+			}
+		})
+	}
+}
+
+func TestSortVorbisComments(t *testing.T) {
+	got := sortVorbisComments([]string{"zeta=1", "Artist=B", "broken", "ALBUM=X", "alpha=2", "ARTIST=A", "title=T"})
+	want := []string{"TITLE=T", "ARTIST=B", "ARTIST=A", "ALBUM=X", "ALPHA=2", "ZETA=1", "broken"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("sortVorbisComments = %q", got)
+	}
+}
@@ -648,6 +648,7 @@ func buildID3v24Tag(meta *AudioMetadata, coverData []byte, coverMIME string) []b
 // matched case-insensitively) with a fresh ID3v2.4 chunk appended at the end.
 // The audio data and all other chunks are preserved; container size is patched.
 func writeID3Chunk(filePath, expectMagic, chunkID string, le bool, id3 []byte) error {
+	defer invalidateMetadataCache(filePath)
 	in, err := os.Open(filePath)
 	if err != nil {
 		return err
@@ -853,6 +854,10 @@ func mergeEditFieldsOntoExisting(existing *AudioMetadata, fields map[string]stri
 
 // WriteWAVTags writes/merges tags into a WAV file's "id3 " chunk.
 func WriteWAVTags(filePath string, fields map[string]string) error {
+	if err := checkWriteAllowed(filePath); err != nil {
+		return err
+	}
+
 	existing, _ := ReadWAVTags(filePath)
 	meta := mergeEditFieldsOntoExisting(existing, fields)
 
@@ -873,6 +878,10 @@ func WriteWAVTags(filePath string, fields map[string]string) error {
 
 // WriteAIFFTags writes/merges tags into an AIFF file's "ID3 " chunk.
 func WriteAIFFTags(filePath string, fields map[string]string) error {
+	if err := checkWriteAllowed(filePath); err != nil {
+		return err
+	}
+
 	existing, _ := ReadAIFFTags(filePath)
 	meta := mergeEditFieldsOntoExisting(existing, fields)
 
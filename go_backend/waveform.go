@@ -0,0 +1,265 @@
+package gobackend
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Waveform modes for GenerateWaveformJSON.
+const (
+	WaveformPeak = "peak"
+	WaveformRMS  = "rms"
+)
+
+const (
+	maxWaveformBuckets      = 10000
+	waveformCacheDirName    = "waveforms"
+	waveformChunkSamples    = 256
+	waveformCancelCheckRate = 16
+)
+
+// ErrWaveformCancelled is returned by a waveform generation stopped with
+// CancelWaveforms.
+var ErrWaveformCancelled = errors.New("waveform generation cancelled")
+
+var (
+	waveformCancel   = make(chan struct{})
+	waveformCancelMu sync.Mutex
+)
+
+// CancelWaveforms stops every running waveform generation. Calls started
+// afterwards are not affected.
+func CancelWaveforms() {
+	waveformCancelMu.Lock()
+	defer waveformCancelMu.Unlock()
+	close(waveformCancel)
+	waveformCancel = make(chan struct{})
+}
+
+func waveformCancelChannel() <-chan struct{} {
+	waveformCancelMu.Lock()
+	defer waveformCancelMu.Unlock()
+	return waveformCancel
+}
+
+// waveformCache is what is stored per file, mode and bucket count; a file
+// changed since is generated again and overwrites it.
+type waveformCache struct {
+	ModTime int64     `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Values  []float32 `json:"values"`
+}
+
+func waveformCachePath(filePath, mode string, buckets int) string {
+	dataDir := GetBackendConfig().DataDir
+	if dataDir == "" {
+		return ""
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		absPath = filePath
+	}
+	sum := sha256.Sum256([]byte(absPath))
+	return filepath.Join(dataDir, waveformCacheDirName, fmt.Sprintf("%s-%s-%d.json", hex.EncodeToString(sum[:16]), mode, buckets))
+}
+
+func readWaveformCache(cachePath string, info os.FileInfo) []float32 {
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil
+	}
+	var cached waveformCache
+	if json.Unmarshal(data, &cached) != nil || cached.ModTime != info.ModTime().UnixNano() || cached.Size != info.Size() {
+		return nil
+	}
+	return cached.Values
+}
+
+func writeWaveformCache(cachePath string, info os.FileInfo, values []float32) {
+	data, err := json.Marshal(waveformCache{ModTime: info.ModTime().UnixNano(), Size: info.Size(), Values: values})
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(cachePath), 0755)
+	}
+	if err == nil {
+		tmpPath := cachePath + ".tmp"
+		if err = os.WriteFile(tmpPath, data, 0644); err == nil {
+			if err = os.Rename(tmpPath, cachePath); err != nil {
+				os.Remove(tmpPath)
+			}
+		}
+	}
+	if err != nil {
+		GoLog("[Waveform] Warning: failed to cache waveform of %s: %v\n", filepath.Base(cachePath), err)
+	}
+}
+
+// computeWaveform decodes filePath and returns buckets values in 0..1,
+// relative to full scale. Samples are first reduced to chunks of about one
+// bucket (waveformChunkSamples when the length is unknown), which are then
+// spread over the buckets.
+func computeWaveform(filePath string, buckets int, mode string, cancel <-chan struct{}) ([]float32, error) {
+	decoder, f, err := openFLACDecoder(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	format := decoder.format
+	if format.BitsPerSample <= 0 || format.BitsPerSample > 32 {
+		return nil, fmt.Errorf("unsupported bit depth: %d", format.BitsPerSample)
+	}
+	scale := 1 / math.Ldexp(1, format.BitsPerSample-1)
+
+	chunkSize := int64(waveformChunkSamples)
+	if format.TotalSamples > 0 {
+		chunkSize = max(format.TotalSamples/int64(buckets), 1)
+	}
+	var (
+		peaks   []float32
+		squares []float64
+		counts  []int64
+		peak    float64
+		square  float64
+		count   int64
+	)
+	flush := func() {
+		peaks = append(peaks, float32(peak))
+		squares = append(squares, square)
+		counts = append(counts, count)
+		peak, square, count = 0, 0, 0
+	}
+
+	for frame := 0; ; frame++ {
+		if frame%waveformCancelCheckRate == 0 {
+			select {
+			case <-cancel:
+				return nil, ErrWaveformCancelled
+			default:
+			}
+		}
+		samples, err := decoder.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i := range samples[0] {
+			var level float64
+			for _, ch := range samples {
+				level = max(level, math.Abs(float64(ch[i])*scale))
+			}
+			peak = max(peak, level)
+			square += level * level
+			if count++; count == chunkSize {
+				flush()
+			}
+		}
+	}
+	if count > 0 {
+		flush()
+	}
+
+	values := make([]float32, buckets)
+	if len(peaks) == 0 {
+		return values, nil
+	}
+	bucketSquares := make([]float64, buckets)
+	bucketCounts := make([]int64, buckets)
+	for i := range peaks {
+		bucket := min(i*buckets/len(peaks), buckets-1)
+		values[bucket] = max(values[bucket], peaks[i])
+		bucketSquares[bucket] += squares[i]
+		bucketCounts[bucket] += counts[i]
+	}
+	// Fewer chunks than buckets leave gaps; repeat the chunk before them.
+	for b := 1; b < buckets; b++ {
+		if bucketCounts[b] == 0 {
+			values[b], bucketSquares[b], bucketCounts[b] = values[b-1], bucketSquares[b-1], bucketCounts[b-1]
+		}
+	}
+	if mode == WaveformRMS {
+		for b := range values {
+			if bucketCounts[b] > 0 {
+				values[b] = float32(math.Sqrt(bucketSquares[b] / float64(bucketCounts[b])))
+			}
+		}
+	}
+	for b := range values {
+		values[b] = min(values[b], 1)
+	}
+	return values, nil
+}
+
+func generateWaveform(filePath string, buckets int, mode string) ([]float32, error) {
+	if isOpenerPath(filePath) {
+		return viaFileOpener(filePath, false, func(localPath string) ([]float32, error) {
+			return generateWaveform(localPath, buckets, mode)
+		})
+	}
+	if buckets <= 0 || buckets > maxWaveformBuckets {
+		return nil, fmt.Errorf("buckets must be between 1 and %d", maxWaveformBuckets)
+	}
+	if mode != WaveformPeak && mode != WaveformRMS {
+		return nil, fmt.Errorf("unknown waveform mode: %q", mode)
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	cachePath := waveformCachePath(filePath, mode, buckets)
+	if cachePath != "" {
+		if values := readWaveformCache(cachePath, info); len(values) == buckets {
+			return values, nil
+		}
+	}
+
+	cancel := waveformCancelChannel()
+	release, err := acquireHeavyOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	values, err := computeWaveform(filePath, buckets, mode, cancel)
+	if err != nil {
+		return nil, err
+	}
+	if cachePath != "" {
+		writeWaveformCache(cachePath, info, values)
+	}
+	return values, nil
+}
+
+// GenerateWaveform decodes a FLAC file and returns the peak level of each
+// of buckets equal slices of it, from 0 (silence) to 1 (full scale), for a
+// waveform scrubber. Results are cached under DataDir by path, size and
+// modification time. CancelWaveforms stops a generation in progress.
+func GenerateWaveform(filePath string, buckets int) ([]float32, error) {
+	return generateWaveform(filePath, buckets, WaveformPeak)
+}
+
+// GenerateWaveformJSON is GenerateWaveform for the app bridge, returning a
+// JSON array. mode is WaveformPeak (the default when empty) or WaveformRMS
+// for the root mean square level of each slice.
+func GenerateWaveformJSON(filePath string, buckets int, mode string) (string, error) {
+	if mode == "" {
+		mode = WaveformPeak
+	}
+	values, err := generateWaveform(filePath, buckets, mode)
+	if err != nil {
+		return "", err
+	}
+	jsonBytes, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
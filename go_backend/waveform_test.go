@@ -0,0 +1,106 @@
+package gobackend
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeHalfLoudFLAC writes one second of stereo audio at half scale
+// followed by one second of silence.
+func writeHalfLoudFLAC(t *testing.T) string {
+	t.Helper()
+	left := make([]int32, 88200)
+	right := make([]int32, 88200)
+	for i := range 44100 {
+		left[i] = 16384
+		if i%2 == 0 {
+			left[i] = -16384
+		}
+		right[i] = left[i] / 2
+	}
+	data, err := encodeVerbatimFLAC([][]int32{left, right}, 44100, 16, 4096)
+	if err != nil {
+		t.Fatalf("encodeVerbatimFLAC: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "half.flac")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
+
+func TestGenerateWaveform(t *testing.T) {
+	configureQuarantineTest(t)
+	path := writeHalfLoudFLAC(t)
+
+	values, err := GenerateWaveform(path, 4)
+	if err != nil {
+		t.Fatalf("GenerateWaveform: %v", err)
+	}
+	if !slices.Equal(values, []float32{0.5, 0.5, 0, 0}) {
+		t.Fatalf("peaks = %v", values)
+	}
+	out, err := GenerateWaveformJSON(path, 4, WaveformRMS)
+	if err != nil || out != "[0.5,0.5,0,0]" {
+		t.Fatalf("GenerateWaveformJSON = %s, %v", out, err)
+	}
+
+	cachePath := waveformCachePath(path, WaveformPeak, 4)
+	if filepath.Dir(filepath.Dir(cachePath)) != GetBackendConfig().DataDir {
+		t.Fatalf("cache path %s is outside DataDir", cachePath)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	writeWaveformCache(cachePath, info, []float32{1, 1, 1, 1})
+	if values, _ := GenerateWaveform(path, 4); !slices.Equal(values, []float32{1, 1, 1, 1}) {
+		t.Fatalf("expected cached values, got %v", values)
+	}
+
+	// A changed file is decoded again.
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime().Add(1e9)); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if values, _ := GenerateWaveform(path, 4); values[0] != 0.5 {
+		t.Fatalf("stale cache served: %v", values)
+	}
+
+	// More buckets than chunks repeat the previous level.
+	if values, err := GenerateWaveform(path, 9); err != nil || len(values) != 9 || values[0] != 0.5 || values[8] != 0 {
+		t.Fatalf("GenerateWaveform(9) = %v, %v", values, err)
+	}
+	for _, buckets := range []int{0, maxWaveformBuckets + 1} {
+		if _, err := GenerateWaveform(path, buckets); err == nil {
+			t.Fatalf("expected %d buckets to be refused", buckets)
+		}
+	}
+	if _, err := GenerateWaveformJSON(path, 4, "loudness"); err == nil {
+		t.Fatal("expected unknown mode to be refused")
+	}
+}
+
+func TestGenerateWaveformCancel(t *testing.T) {
+	path := writeHalfLoudFLAC(t)
+	cancel := make(chan struct{})
+	close(cancel)
+	if _, err := computeWaveform(path, 4, WaveformPeak, cancel); !errors.Is(err, ErrWaveformCancelled) {
+		t.Fatalf("computeWaveform = %v", err)
+	}
+
+	running := waveformCancelChannel()
+	CancelWaveforms()
+	select {
+	case <-running:
+	default:
+		t.Fatal("CancelWaveforms did not stop running generations")
+	}
+	select {
+	case <-waveformCancelChannel():
+		t.Fatal("generations started after CancelWaveforms must run")
+	default:
+	}
+}
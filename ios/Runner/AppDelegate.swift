@@ -398,7 +398,8 @@ import Gobackend  // Import Go framework
         case "sanitizeFilename":
             let args = call.arguments as! [String: Any]
             let filename = args["filename"] as! String
-            let response = GobackendSanitizeFilename(filename)
+            let profile = args["profile"] as? String ?? ""
+            let response = GobackendSanitizeFilename(filename, profile)
             return response
             
         case "fetchLyrics":
@@ -524,9 +524,13 @@ class PlatformBridge {
     return result as String;
   }
 
-  static Future<String> sanitizeFilename(String filename) async {
+  static Future<String> sanitizeFilename(
+    String filename, {
+    String profile = '',
+  }) async {
     final result = await _channel.invokeMethod('sanitizeFilename', {
       'filename': filename,
+      'profile': profile,
     });
     return result as String;
   }